@@ -0,0 +1,174 @@
+//go:build js && wasm
+
+// Command poml-wasm exposes the SDK to JavaScript for browser-based prompt editors. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o poml.wasm ./cmd/poml-wasm
+//
+// and load it with the wasm_exec.js shipped in $(go env GOROOT)/lib/wasm (misc/wasm before Go
+// 1.24). It installs globalThis.poml with synchronous functions taking and returning strings:
+//
+//	poml.parse(src)                   // document JSON (see Document.MarshalJSON)
+//	poml.validate(src)                // ValidationError JSON, or "null" when valid
+//	poml.convert(src, format, opts?)  // converted JSON, or plain text for the "text" format
+//	poml.format(src)                  // canonical POML
+//
+// Each returns {result: string} on success or {error: string, code: string} on failure. opts is
+// an optional JSON string: {"schemaName", "schemaStrict", "jsonObject", "systemPrompt",
+// "toolChoice", "parallelToolCalls", "files": {"path": "<base64>"}}. Local
+// <img>/<audio>/<video>/<document> paths resolve only against files; there is no host
+// filesystem or network access.
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"syscall/js"
+	"testing/fstest"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+type convertOptions struct {
+	SchemaName        string            `json:"schemaName"`
+	SchemaStrict      *bool             `json:"schemaStrict"`
+	JSONObject        bool              `json:"jsonObject"`
+	SystemPrompt      bool              `json:"systemPrompt"`
+	ToolChoice        string            `json:"toolChoice"`
+	ParallelToolCalls *bool             `json:"parallelToolCalls"`
+	Profile           string            `json:"profile"`
+	Inputs            map[string]any    `json:"inputs"`
+	StrictInputs      bool              `json:"strictInputs"`
+	Files             map[string]string `json:"files"`
+}
+
+func main() {
+	js.Global().Set("poml", js.ValueOf(map[string]any{
+		"parse":    export(parse),
+		"validate": export(validate),
+		"convert":  export(convert),
+		"format":   export(format),
+	}))
+	select {}
+}
+
+// export adapts fn to a JS function: string arguments in, {result} or {error, code} out. Panics
+// are reported as errors so one bad call cannot kill the module.
+func export(fn func(args []string) (string, error)) js.Func {
+	return js.FuncOf(func(_ js.Value, args []js.Value) (out any) {
+		defer func() {
+			if r := recover(); r != nil {
+				out = errorResult(fmt.Errorf("panic: %v", r))
+			}
+		}()
+		strs := make([]string, len(args))
+		for i, a := range args {
+			if a.Type() == js.TypeString {
+				strs[i] = a.String()
+			}
+		}
+		res, err := fn(strs)
+		if err != nil {
+			return errorResult(err)
+		}
+		return map[string]any{"result": res}
+	})
+}
+
+func errorResult(err error) map[string]any {
+	code := ""
+	var pErr *poml.POMLError
+	var vErr *poml.ValidationError
+	switch {
+	case errors.As(err, &pErr):
+		code = string(pErr.Code)
+	case errors.As(err, &vErr):
+		code = string(poml.CodeValidation)
+	}
+	return map[string]any{"error": err.Error(), "code": code}
+}
+
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+func parse(args []string) (string, error) {
+	doc, err := poml.ParseString(arg(args, 0))
+	if err != nil {
+		return "", err
+	}
+	return marshal(doc)
+}
+
+func validate(args []string) (string, error) {
+	doc, err := poml.ParseString(arg(args, 0))
+	if err != nil {
+		return "", err
+	}
+	var vErr *poml.ValidationError
+	if err := doc.Validate(); errors.As(err, &vErr) {
+		return marshal(vErr)
+	} else if err != nil {
+		return "", err
+	}
+	return "null", nil
+}
+
+func convert(args []string) (string, error) {
+	doc, err := poml.ParseString(arg(args, 0))
+	if err != nil {
+		return "", err
+	}
+	var in convertOptions
+	if raw := arg(args, 2); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &in); err != nil {
+			return "", fmt.Errorf("options: %w", err)
+		}
+	}
+	files := fstest.MapFS{}
+	for name, data := range in.Files {
+		raw, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return "", fmt.Errorf("options: file %s: %w", name, err)
+		}
+		files[name] = &fstest.MapFile{Data: raw}
+	}
+	opts := poml.ConvertOptions{
+		FS:                files,
+		SchemaName:        in.SchemaName,
+		SchemaStrict:      in.SchemaStrict,
+		JSONObject:        in.JSONObject,
+		ToolChoice:        in.ToolChoice,
+		ParallelToolCalls: in.ParallelToolCalls,
+		Profile:           in.Profile,
+		Inputs:            in.Inputs,
+		StrictInputs:      in.StrictInputs,
+	}
+	opts.SystemPrompt.Enabled = in.SystemPrompt
+	out, err := poml.Convert(doc, poml.Format(arg(args, 1)), opts)
+	if err != nil {
+		return "", err
+	}
+	if text, ok := out.(string); ok {
+		return text, nil
+	}
+	return marshal(out)
+}
+
+func format(args []string) (string, error) {
+	doc, err := poml.ParseString(arg(args, 0))
+	if err != nil {
+		return "", err
+	}
+	out, err := poml.FormatDocument(doc, poml.FormatStyle{})
+	return string(out), err
+}
+
+func marshal(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
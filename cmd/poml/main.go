@@ -0,0 +1,634 @@
+// Command poml wraps the Go SDK for CI scripts: parse/validate, convert, format, diagram export,
+// a watch loop for editing, and an HTTP server for non-Go callers.
+//
+// Usage:
+//
+//	poml validate [--allow CODE,...] [--fail-on warning|error] [--meta-id-pattern RE] [--owners a,b] [--ban phrase,...] [--response reply.json] [--legacy-tool-definitions] file.poml [file.poml...]
+//	poml convert --format openai_chat [--base-dir dir] file.poml
+//	poml fmt [--write|--check] [--sort-attrs] [--yaml] file.poml [file.poml...]
+//	poml diagram --to dot|mermaid|json|gltf [--layout force|layered] [--at T] file.poml
+//	poml watch [--allow CODE,...] [--interval 200ms] file.poml [file.poml...]
+//	poml serve [--addr :8080] [--max-bytes N] [--asset-dir dir] [--openapi]
+//
+// A file argument of "-" reads from stdin; .yaml and .yml files are read as the YAML form of a
+// document (see Document.EncodeYAML).
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+	"github.com/atlas-foundry/poml-go-sdk/poml/layout"
+	"github.com/atlas-foundry/poml-go-sdk/poml/server"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+const usage = `usage: poml <command> [flags] [files]
+
+commands:
+  validate   parse and validate one or more POML files (--allow to ignore validation codes, --fail-on warning to fail on warnings too, --response to check a model reply against the output-schema)
+  convert    convert a POML file to a chat format (--format)
+  fmt        print POML files in canonical form (--write to update in place, --check to list unformatted files, --yaml for YAML)
+  diagram    export <diagram> blocks (--to dot|mermaid|json|gltf, --layout force|layered, --at T)
+  watch      re-validate files whenever they change until interrupted
+  serve      serve parse/validate/convert/render as HTTP JSON endpoints until interrupted
+  xsd        print an XML Schema for the POML element set, for editors and XML validators
+`
+
+// run executes the CLI and returns the process exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	cmd, rest := args[0], args[1:]
+	var err error
+	switch cmd {
+	case "validate":
+		err = runValidate(rest, stdin, stdout, stderr)
+	case "convert":
+		err = runConvert(rest, stdin, stdout, stderr)
+	case "fmt":
+		err = runFmt(rest, stdin, stdout, stderr)
+	case "diagram":
+		err = runDiagram(rest, stdin, stdout, stderr)
+	case "watch":
+		err = runWatch(rest, stdout, stderr)
+	case "serve":
+		err = runServe(rest, stdout, stderr)
+	case "xsd":
+		err = runXSD(rest, stdout, stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "poml: unknown command %q\n%s", cmd, usage)
+		return 2
+	}
+	if err == nil {
+		return 0
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	fmt.Fprintf(stderr, "poml %s: %v\n", cmd, err)
+	writeExcerpt(stderr, err)
+	var ue usageError
+	if errors.As(err, &ue) {
+		return 2
+	}
+	return 1
+}
+
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+// errFailed signals that per-file errors were already reported.
+var errFailed = errors.New("one or more files failed")
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+func parseInput(path string, stdin io.Reader) (poml.Document, error) {
+	if path == "-" {
+		return poml.ParseReader(stdin)
+	}
+	// .yaml and .yml files hold the YAML form written by fmt --yaml.
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		f, err := os.Open(path)
+		if err != nil {
+			return poml.Document{}, err
+		}
+		defer f.Close()
+		return poml.ParseYAML(f)
+	}
+	// A <poml extends="..."> file is merged over its parents.
+	return poml.LoadExtends(path, poml.ExtendsOptions{})
+}
+
+// formatInput returns the source bytes of path and their canonical formatting.
+func formatInput(path string, stdin io.Reader, style poml.FormatStyle) ([]byte, []byte, error) {
+	var src []byte
+	var err error
+	if path == "-" {
+		src, err = io.ReadAll(stdin)
+	} else {
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	doc, err := poml.ParseString(string(src))
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err := poml.FormatDocument(doc, style)
+	return src, out, err
+}
+
+// writeFileAtomic replaces path with data via a temporary file in the same directory and a
+// rename, keeping the existing file's permissions. The temporary file is removed on failure.
+func writeFileAtomic(path string, data []byte) (err error) {
+	mode := os.FileMode(0o644)
+	if info, statErr := os.Stat(path); statErr == nil {
+		mode = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Chmod(mode); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func runValidate(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("validate", stderr)
+	allow := fs.String("allow", "", "comma-separated validation codes to ignore (e.g. POML-META-001,POML-TOOL-REQ-REF)")
+	failOn := poml.SeverityError
+	fs.TextVar(&failOn, "fail-on", failOn, "lowest issue severity that fails validation: warning or error")
+	metaID := fs.String("meta-id-pattern", "", "regular expression every meta.id must match")
+	owners := fs.String("owners", "", "comma-separated list of allowed meta.owner values")
+	banned := fs.String("ban", "", "comma-separated phrases no element body may contain")
+	responseFile := fs.String("response", "", "JSON model reply to validate against each document's <output-schema>")
+	legacyTools := fs.Bool("legacy-tool-definitions", false, "accept prose <tool-definition> bodies, as convert --legacy-tool-definitions reads them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError{"expected at least one file"}
+	}
+	var response []byte
+	if *responseFile != "" {
+		var err error
+		if response, err = os.ReadFile(*responseFile); err != nil {
+			return err
+		}
+	}
+	var validators []poml.Validator
+	if *metaID != "" {
+		re, err := regexp.Compile(*metaID)
+		if err != nil {
+			return usageError{fmt.Sprintf("invalid --meta-id-pattern: %v", err)}
+		}
+		validators = append(validators, poml.MetaIDPattern(re))
+	}
+	if *owners != "" {
+		validators = append(validators, poml.AllowedOwners(strings.Split(*owners, ",")...))
+	}
+	if *banned != "" {
+		validators = append(validators, poml.BannedPhrases(strings.Split(*banned, ",")...))
+	}
+	allowed := parseCodes(*allow)
+	failed := false
+	for _, path := range fs.Args() {
+		doc, err := parseInput(path, stdin)
+		var warnings *poml.ValidationError
+		if err == nil {
+			warnings, err = doc.ValidateWithOptions(poml.ValidateOptions{FailOn: failOn, Validators: validators, LegacyToolDefinitions: *legacyTools})
+			err = poml.AllowCodes(err, allowed...)
+			if warnings != nil {
+				warnings = warnings.Without(allowed...)
+			}
+			if err == nil && *responseFile != "" {
+				err = poml.AllowCodes(poml.ValidateOutput(doc, response), allowed...)
+			}
+		}
+		if err != nil {
+			failed = true
+		}
+		reportWarnings(stderr, path, warnings)
+		reportValidation(stdout, stderr, path, err)
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+// parseCodes splits a comma-separated --allow list.
+func parseCodes(list string) []poml.ErrorCode {
+	var codes []poml.ErrorCode
+	for _, code := range strings.Split(list, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, poml.ErrorCode(code))
+		}
+	}
+	return codes
+}
+
+// reportValidation prints "path: ok" to stdout, or one "path: CODE: issue" line per validation
+// issue (or the plain error) to stderr.
+func reportValidation(stdout, stderr io.Writer, path string, err error) {
+	if err == nil {
+		fmt.Fprintf(stdout, "%s: ok\n", path)
+		return
+	}
+	var ve *poml.ValidationError
+	if !errors.As(err, &ve) {
+		fmt.Fprintf(stderr, "%s: %v\n", path, err)
+		writeExcerpt(stderr, err)
+		return
+	}
+	for i, issue := range ve.Issues {
+		if i < len(ve.Details) && ve.Details[i].Code != "" {
+			issue = string(ve.Details[i].Code) + ": " + issue
+		}
+		fmt.Fprintf(stderr, "%s: %s\n", path, issue)
+	}
+}
+
+// reportWarnings prints one "path: warning: CODE: issue" line per issue that did not fail
+// validation.
+func reportWarnings(stderr io.Writer, path string, warnings *poml.ValidationError) {
+	if warnings == nil {
+		return
+	}
+	for i, issue := range warnings.Issues {
+		if i < len(warnings.Details) && warnings.Details[i].Code != "" {
+			issue = string(warnings.Details[i].Code) + ": " + issue
+		}
+		fmt.Fprintf(stderr, "%s: warning: %s\n", path, issue)
+	}
+}
+
+// writeExcerpt prints the source excerpt of a decode failure, indented, under its error line.
+func writeExcerpt(stderr io.Writer, err error) {
+	var pe *poml.POMLError
+	if !errors.As(err, &pe) || pe.Location == nil || pe.Location.Excerpt == "" {
+		return
+	}
+	for _, line := range strings.Split(pe.Location.Excerpt, "\n") {
+		fmt.Fprintf(stderr, "    %s\n", line)
+	}
+}
+
+// interruptContext returns the context poml watch and poml serve run under; tests replace it to
+// stop them.
+var interruptContext = func() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+func runWatch(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("watch", stderr)
+	allow := fs.String("allow", "", "comma-separated validation codes to ignore")
+	interval := fs.Duration("interval", 200*time.Millisecond, "how often to check files for changes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError{"expected at least one file"}
+	}
+	allowed := parseCodes(*allow)
+	ctx, cancel := interruptContext()
+	defer cancel()
+	var mu sync.Mutex
+	errs := make(chan error, fs.NArg())
+	for _, path := range fs.Args() {
+		go func(path string) {
+			errs <- poml.WatchContext(ctx, path, poml.WatchOptions{Interval: *interval}, func(_ poml.Document, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				reportValidation(stdout, stderr, path, poml.AllowCodes(err, allowed...))
+			})
+		}(path)
+	}
+	var first error
+	for range fs.Args() {
+		if err := <-errs; err != nil && ctx.Err() == nil && first == nil {
+			first = err
+			cancel()
+		}
+	}
+	return first
+}
+
+func runServe(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("serve", stderr)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	maxBytes := fs.Int64("max-bytes", 0, "request body limit in bytes (default 4MB)")
+	assetDir := fs.String("asset-dir", "", "directory local <img>/<document> paths resolve in (default: none readable)")
+	openAPI := fs.Bool("openapi", false, "print the OpenAPI spec and exit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	opts := server.Options{MaxRequestBytes: *maxBytes}
+	if *assetDir != "" {
+		opts.Convert.FS = os.DirFS(*assetDir)
+	}
+	srv := server.New(opts)
+	if *openAPI {
+		return writeJSON(stdout, srv.OpenAPI(), false)
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "poml: serving on %s\n", ln.Addr())
+	ctx, cancel := interruptContext()
+	defer cancel()
+	return srv.Serve(ctx, ln)
+}
+
+func runXSD(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("xsd", stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError{"xsd takes no arguments"}
+	}
+	_, err := stdout.Write(poml.XSD())
+	return err
+}
+
+func runConvert(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("convert", stderr)
+	format := fs.String("format", string(poml.FormatOpenAIChat), "message_dict|dict|openai_chat|langchain|pydantic|bedrock_converse|ollama|cohere|text|hf_chat|mistral")
+	baseDir := fs.String("base-dir", "", "directory for resolving relative asset paths (defaults to the file's directory)")
+	compact := fs.Bool("compact", false, "emit compact JSON")
+	chatTemplate := fs.String("chat-template", "", "render the prompt through this Hugging Face chat template file instead of --format")
+	genPrompt := fs.Bool("add-generation-prompt", false, "with --chat-template, open an assistant turn at the end")
+	schemaName := fs.String("schema-name", "", "openai_chat: json_schema name (default \"schema\")")
+	schemaStrict := fs.Bool("schema-strict", true, "openai_chat: set json_schema.strict")
+	jsonObject := fs.Bool("json-object", false, "openai_chat: emit json_object response_format instead of json_schema")
+	systemPrompt := fs.Bool("system-prompt", false, "compose role/task/input sections into a leading system message")
+	legacyTools := fs.Bool("legacy-tool-definitions", false, "use a <tool-definition> body as its description when the attribute is empty")
+	toolChoice := fs.String("tool-choice", "", "openai_chat/mistral: auto|none|required|any or a tool name")
+	parallel := fs.Bool("parallel-tool-calls", true, "openai_chat/mistral: set parallel_tool_calls (omitted unless given)")
+	profile := fs.String("profile", "", "apply <runtime profile=...> entries for this profile on top of the shared ones")
+	inputs := fs.String("inputs", "", "JSON file of input values to bind to <input> elements and {{inputs.NAME}} references")
+	strictInputs := fs.Bool("strict-inputs", false, "fail on {{inputs.NAME}} references without a value")
+	contextFile := fs.String("context", "", "JSON file of variables for if/for attributes and {{ }} expressions")
+	explain := fs.Bool("explain", false, "list which elements the format emits, drops, or downgrades instead of converting")
+	maxImageWidth := fs.Int("max-image-width", 0, "downscale PNG/JPEG images wider than this many pixels")
+	maxImageHeight := fs.Int("max-image-height", 0, "downscale PNG/JPEG images taller than this many pixels")
+	imageQuality := fs.Int("image-quality", 0, "JPEG quality (1-100) for downscaled images (default 75)")
+	imageMode := fs.String("image-mode", "", "openai_chat/mistral/langchain: inline|url (url passes http(s) image sources through)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError{"expected exactly one file"}
+	}
+	path := fs.Arg(0)
+	doc, err := parseInput(path, stdin)
+	if err != nil {
+		return err
+	}
+	opts := poml.ConvertOptions{BaseDir: *baseDir, SchemaName: *schemaName, SchemaStrict: schemaStrict, JSONObject: *jsonObject}
+	opts.SystemPrompt.Enabled = *systemPrompt
+	opts.ToolChoice = *toolChoice
+	opts.Profile = *profile
+	opts.StrictInputs = *strictInputs
+	opts.MaxImageWidth, opts.MaxImageHeight, opts.ImageQuality = *maxImageWidth, *maxImageHeight, *imageQuality
+	opts.ImageMode = poml.ImageMode(*imageMode)
+	if *inputs != "" {
+		data, err := os.ReadFile(*inputs)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &opts.Inputs); err != nil {
+			return fmt.Errorf("inputs %s: %w", *inputs, err)
+		}
+	}
+	if *contextFile != "" {
+		data, err := os.ReadFile(*contextFile)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &opts.Context); err != nil {
+			return fmt.Errorf("context %s: %w", *contextFile, err)
+		}
+	}
+	opts.LegacyToolDefinitions = *legacyTools
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "parallel-tool-calls" {
+			opts.ParallelToolCalls = parallel
+		}
+	})
+	if opts.BaseDir == "" && path != "-" {
+		opts.BaseDir = filepath.Dir(path)
+	}
+	if *explain {
+		exp, err := poml.ExplainConvertWithOptions(doc, poml.Format(*format), opts)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(stdout, exp.String())
+		return err
+	}
+	if *chatTemplate != "" {
+		tmpl, err := os.ReadFile(*chatTemplate)
+		if err != nil {
+			return err
+		}
+		text, err := poml.RenderChatTemplate(doc, string(tmpl), poml.ChatTemplateOptions{Convert: opts, AddGenerationPrompt: *genPrompt})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(stdout, text)
+		return err
+	}
+	out, err := poml.Convert(doc, poml.Format(*format), opts)
+	if err != nil {
+		return err
+	}
+	if text, ok := out.(string); ok {
+		_, err = fmt.Fprintln(stdout, text)
+		return err
+	}
+	return writeJSON(stdout, out, *compact)
+}
+
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("fmt", stderr)
+	write := fs.Bool("write", false, "write result to the source file instead of stdout")
+	check := fs.Bool("check", false, "list files whose formatting differs and exit non-zero")
+	indent := fs.String("indent", "  ", "indentation string")
+	sortAttrs := fs.Bool("sort-attrs", false, "sort attributes by name")
+	asYAML := fs.Bool("yaml", false, "print the YAML form of each file instead")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError{"expected at least one file"}
+	}
+	if *asYAML {
+		if *write || *check {
+			return usageError{"--yaml cannot be combined with --write or --check"}
+		}
+		for _, path := range fs.Args() {
+			doc, err := parseInput(path, stdin)
+			if err == nil {
+				err = doc.EncodeYAML(stdout)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		return nil
+	}
+	style := poml.FormatStyle{Indent: *indent, SortAttrs: *sortAttrs}
+	failed := false
+	for _, path := range fs.Args() {
+		src, out, err := formatInput(path, stdin, style)
+		switch {
+		case err != nil:
+		case *check:
+			if !bytes.Equal(src, out) {
+				failed = true
+				fmt.Fprintln(stdout, path)
+			}
+		case *write && path != "-":
+			err = writeFileAtomic(path, out)
+		default:
+			_, err = stdout.Write(out)
+		}
+		if err != nil {
+			failed = true
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+		}
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+func runDiagram(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("diagram", stderr)
+	to := fs.String("to", "dot", "dot|mermaid|json|gltf")
+	id := fs.String("id", "", "only export the diagram with this id")
+	layoutName := fs.String("layout", "", "force|layered: position nodes that have no coordinates")
+	at := fs.String("at", "", "render the scene at this keyframe time")
+	maxNodes := fs.Int("max-nodes", 0, "split each diagram into connected parts of at most this many nodes, rendered one after another")
+	project := fs.Bool("project", false, "dot: place nodes as the diagram camera sees them instead of by x and y")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError{"expected exactly one file"}
+	}
+	var atTime float64
+	if *at != "" {
+		var err error
+		if atTime, err = strconv.ParseFloat(*at, 64); err != nil {
+			return usageError{fmt.Sprintf("invalid --at %q", *at)}
+		}
+	}
+	var renderer poml.Renderer
+	switch strings.ToLower(*to) {
+	case "dot":
+		renderer = poml.GraphvizRenderer{Project: *project}
+	case "mermaid":
+		renderer = poml.MermaidRenderer{}
+	case "json", "deckgl", "scenejson":
+		renderer = poml.DeckGLRenderer{}
+	case "gltf":
+		renderer = poml.GLTFRenderer{}
+	default:
+		return usageError{fmt.Sprintf("unsupported --to %q", *to)}
+	}
+	var algo layout.LayoutAlgo
+	if *layoutName != "" {
+		var err error
+		if algo, err = layout.ParseLayoutAlgo(*layoutName); err != nil {
+			return usageError{fmt.Sprintf("unsupported --layout %q", *layoutName)}
+		}
+	}
+	doc, err := parseInput(fs.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, d := range doc.Diagrams {
+		if *id != "" && d.ID != *id {
+			continue
+		}
+		found = true
+		scene, err := poml.DiagramToScene(d)
+		if err != nil {
+			return err
+		}
+		if *at != "" {
+			scene = scene.At(atTime)
+		}
+		scenes := []poml.Scene{scene}
+		if *maxNodes > 0 {
+			if scenes, err = poml.SplitScene(scene, *maxNodes); err != nil {
+				return err
+			}
+		}
+		for _, scene := range scenes {
+			if *layoutName != "" {
+				if err := layout.ApplyLayout(&scene, algo, layout.LayoutOptions{}); err != nil {
+					return err
+				}
+			}
+			if _, ok := renderer.(poml.DeckGLRenderer); ok {
+				if err := scene.WriteJSON(stdout); err != nil {
+					return err
+				}
+				continue
+			}
+			out, err := renderer.Render(scene)
+			if err != nil {
+				return err
+			}
+			if _, err := stdout.Write(out); err != nil {
+				return err
+			}
+			if len(out) > 0 && out[len(out)-1] != '\n' {
+				fmt.Fprintln(stdout)
+			}
+		}
+	}
+	if !found {
+		return errors.New("no matching <diagram> found")
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v any, compact bool) error {
+	enc := json.NewEncoder(w)
+	if !compact {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(v)
+}
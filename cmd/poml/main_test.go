@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const validDoc = `<poml>
+  <meta><id>cli.demo</id><version>1.0.0</version><owner>me</owner></meta>
+  <role>Helper</role>
+  <task>Say hi</task>
+  <human-msg>Hello</human-msg>
+</poml>`
+
+func writeTemp(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestValidateCommand(t *testing.T) {
+	good := writeTemp(t, "good.poml", validDoc)
+	bad := writeTemp(t, "bad.poml", `<poml><task>x</task></poml>`)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"validate", good}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected success, got %d: %s", code, stderr.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"validate", good, bad}, nil, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected failure exit code, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "POML-META-001: meta section is required") {
+		t.Fatalf("expected validation message, got %s", stderr.String())
+	}
+	stdout.Reset()
+	allow := "POML-META-001,POML-META-003,POML-META-004,POML-META-005,POML-ROLE-001"
+	if code := run([]string{"validate", "--allow", allow, bad}, nil, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "bad.poml: ok") {
+		t.Fatalf("allow-listed codes should pass, got %d: %s", code, stderr.String())
+	}
+
+	hint := writeTemp(t, "hint.poml", strings.Replace(validDoc, "<task>", "<hint></hint>\n  <task>", 1))
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"validate", "--fail-on", "warning", hint}, nil, &stdout, &stderr); code != 1 {
+		t.Fatalf("--fail-on warning should fail on warnings, got %d", code)
+	}
+	stderr.Reset()
+	if code := run([]string{"validate", hint}, nil, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "hint.poml: ok") {
+		t.Fatalf("warnings should pass by default, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "hint.poml: warning: POML-HINT-BODY: hint[0] requires body content") {
+		t.Fatalf("expected the warning to be printed, got %s", stderr.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"validate", "--meta-id-pattern", `^team\.`, "--owners", "me,you", "--ban", "HELLO", good}, nil, &stdout, &stderr); code != 1 {
+		t.Fatalf("custom rules should fail the document, got %d", code)
+	}
+	if got := stderr.String(); !strings.Contains(got, "POML-META-ID-PATTERN") || strings.Contains(got, "POML-META-OWNER-ALLOWED") || !strings.Contains(got, "POML-BANNED-PHRASE") {
+		t.Fatalf("unexpected custom rule output: %s", got)
+	}
+
+	schema := writeTemp(t, "schema.poml", strings.Replace(validDoc, "</poml>", `<output-schema>{"type":"object","required":["greeting"]}</output-schema></poml>`, 1))
+	reply := writeTemp(t, "reply.json", `{"greet": "hi"}`)
+	stderr.Reset()
+	if code := run([]string{"validate", "--response", reply, schema}, nil, &stdout, &stderr); code != 1 {
+		t.Fatalf("a reply missing a required property should fail, got %d", code)
+	}
+	if got := stderr.String(); !strings.Contains(got, "schema.poml: POML-OUTPUT-FIELD: /greeting: required property is missing") {
+		t.Fatalf("unexpected response output: %s", got)
+	}
+}
+
+func TestConvertCommandFromStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"convert", "--format", "openai_chat", "--compact", "-"}, strings.NewReader(validDoc), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("convert failed (%d): %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"role":"user"`) {
+		t.Fatalf("unexpected convert output: %s", stdout.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"convert", "--format", "text", "-"}, strings.NewReader(validDoc), &stdout, &stderr); code != 0 || !strings.HasPrefix(stdout.String(), "Role:\n") {
+		t.Fatalf("text output should be written raw, got %d: %q", code, stdout.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"convert", "--format", "text", "--explain", "-"}, strings.NewReader(validDoc), &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "human_msg[0]: emitted\n") {
+		t.Fatalf("explain output mismatch, got %d: %q", code, stdout.String())
+	}
+	stdout.Reset()
+	tmpl := writeTemp(t, "chatml.jinja", "{% for m in messages %}<|im_start|>{{ m.role }}\n{{ m.content }}<|im_end|>\n{% endfor %}{% if add_generation_prompt %}<|im_start|>assistant\n{% endif %}")
+	args := []string{"convert", "--chat-template", tmpl, "--add-generation-prompt", "-"}
+	if code := run(args, strings.NewReader(validDoc), &stdout, &stderr); code != 0 || stdout.String() != "<|im_start|>user\nHello<|im_end|>\n<|im_start|>assistant\n" {
+		t.Fatalf("chat template output mismatch, got %d: %q %s", code, stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	ctx := writeTemp(t, "ctx.json", `{"names": ["Ada", "Lin"]}`)
+	loop := `<poml><human-msg for="n in names">Hi {{ n }}</human-msg></poml>`
+	if code := run([]string{"convert", "--format", "text", "--context", ctx, "-"}, strings.NewReader(loop), &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "Hi Ada") || !strings.Contains(stdout.String(), "Hi Lin") {
+		t.Fatalf("context output mismatch, got %d: %q %s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestFmtWriteAndDiagram(t *testing.T) {
+	path := writeTemp(t, "fmt.poml", `<poml><task>a</task>   <role>r</role></poml>`)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"fmt", "--write", path}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("fmt failed: %s", stderr.String())
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read formatted: %v", err)
+	}
+	if !strings.Contains(string(got), "\n  <task>a</task>\n  <role>r</role>\n") {
+		t.Fatalf("unexpected formatted output: %s", got)
+	}
+	stdout.Reset()
+	if code := run([]string{"fmt", "--yaml", path}, nil, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "  - task: a\n") {
+		t.Fatalf("fmt --yaml failed: %s %s", stdout.String(), stderr.String())
+	}
+	yamlPath := writeTemp(t, "fmt.yaml", stdout.String())
+	stdout.Reset()
+	if code := run([]string{"convert", "--format", "text", yamlPath}, nil, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "Role:\nr") {
+		t.Fatalf("convert of a YAML file failed: %s %s", stdout.String(), stderr.String())
+	}
+
+	diagram := writeTemp(t, "d.poml", `<poml><diagram id="d"><graph><node id="a"/><node id="b"/><edge from="a" to="b" directed="true"/></graph></diagram></poml>`)
+	stdout.Reset()
+	if code := run([]string{"diagram", "--to", "dot", diagram}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("diagram failed: %s", stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"a" -> "b"`) {
+		t.Fatalf("unexpected dot output: %s", stdout.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"diagram", "--to", "mermaid", "--layout", "layered", diagram}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("diagram --layout failed: %s", stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "a --> b") {
+		t.Fatalf("unexpected mermaid output: %s", stdout.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"diagram", "--layout", "layered", diagram}, nil, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), `pos="0.000,1.000!"`) {
+		t.Fatalf("layered layout should rank b below a: %s", stdout.String())
+	}
+	animated := writeTemp(t, "anim.poml", `<poml><diagram id="d"><graph><node id="a" x="0" y="0"/></graph><frame t="0"><node id="a" x="0"/></frame><frame t="2"><node id="a" x="4"/></frame></diagram></poml>`)
+	stdout.Reset()
+	if code := run([]string{"diagram", "--at", "1", animated}, nil, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), `pos="2.000,0.000!"`) {
+		t.Fatalf("diagram --at should interpolate positions: %s %s", stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"diagram", "--to", "mermaid", "--max-nodes", "1", diagram}, nil, &stdout, &stderr); code != 0 || strings.Count(stdout.String(), "flowchart TD") != 2 {
+		t.Fatalf("diagram --max-nodes should render one graph per part: %s %s", stdout.String(), stderr.String())
+	}
+	if code := run([]string{"diagram", "--to", "svg", diagram}, nil, &stdout, &stderr); code != 2 {
+		t.Fatalf("expected usage error for unsupported target, got %d", code)
+	}
+	stdout.Reset()
+	if code := run([]string{"xsd"}, nil, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), `<xs:element name="diagram" type="Diagram"/>`) {
+		t.Fatalf("xsd should print the schema, got %d: %.200s", code, stdout.String())
+	}
+	if code := run([]string{"bogus"}, nil, &stdout, &stderr); code != 2 {
+		t.Fatalf("expected usage exit code for unknown command, got %d", code)
+	}
+}
+
+func TestFmtWritePreservesMode(t *testing.T) {
+	path := writeTemp(t, "private.poml", `<poml><task>a</task>   <role>r</role></poml>`)
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"fmt", "--write", path}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("fmt failed: %s", stderr.String())
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+
+	dir := filepath.Join(filepath.Dir(path), "dir.poml")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(dir, []byte("x")); err == nil {
+		t.Fatalf("expected an error replacing a directory")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 2 {
+		t.Fatalf("temporary files left behind after an error: %v", entries)
+	}
+}
+
+func TestWatchReportsUntilCancelled(t *testing.T) {
+	good := writeTemp(t, "good.poml", validDoc)
+	bad := writeTemp(t, "bad.poml", `<poml><task>t</task></poml>`)
+	orig := interruptContext
+	defer func() { interruptContext = orig }()
+	interruptContext = func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(context.Background(), 100*time.Millisecond)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"watch", "--interval", "10ms", "--allow", "POML-ROLE-001", good, bad}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("watch should exit cleanly when cancelled, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "good.poml: ok") || !strings.Contains(stderr.String(), "bad.poml: POML-META-001") || strings.Contains(stderr.String(), "POML-ROLE-001") {
+		t.Fatalf("unexpected watch output: %q / %q", stdout.String(), stderr.String())
+	}
+	if code := run([]string{"watch", filepath.Join(t.TempDir(), "missing.poml")}, nil, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected failure for missing file, got %d", code)
+	}
+}
+
+func TestServe(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"serve", "--openapi", "--max-bytes", "2048"}, nil, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "2KB") {
+		t.Fatalf("serve --openapi failed (%d): %s%s", code, stdout.String(), stderr.String())
+	}
+	orig := interruptContext
+	defer func() { interruptContext = orig }()
+	interruptContext = func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(context.Background(), 50*time.Millisecond)
+	}
+	stderr.Reset()
+	if code := run([]string{"serve", "--addr", "127.0.0.1:0"}, nil, &stdout, &stderr); code != 0 || !strings.Contains(stderr.String(), "serving on 127.0.0.1:") {
+		t.Fatalf("serve should stop cleanly when interrupted, got %d: %s", code, stderr.String())
+	}
+}
@@ -0,0 +1,175 @@
+package poml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AssetFetcher retrieves remote (http/https) sources for <img>, <audio>, <video>, and <document>
+// during conversion. maxBytes is the caller's cap (non-positive disables it); contentType may be
+// empty when the server does not report one.
+type AssetFetcher interface {
+	FetchAsset(src string, maxBytes int64) (data []byte, contentType string, err error)
+}
+
+// ContextAssetFetcher is an AssetFetcher that can abandon a fetch when ctx is done. ConvertContext
+// prefers it over FetchAsset.
+type ContextAssetFetcher interface {
+	AssetFetcher
+	FetchAssetContext(ctx context.Context, src string, maxBytes int64) (data []byte, contentType string, err error)
+}
+
+// ConditionalAssetFetcher is an AssetFetcher that can revalidate a copy held in a MediaCache.
+// When etag is non-empty and the source is unchanged it returns notModified with no data;
+// otherwise it returns the body along with the source's current ETag (empty when there is none).
+type ConditionalAssetFetcher interface {
+	AssetFetcher
+	FetchAssetConditional(ctx context.Context, src string, maxBytes int64, etag string) (data []byte, contentType, newETag string, notModified bool, err error)
+}
+
+const defaultAssetFetchTimeout = 30 * time.Second
+
+// HTTPAssetFetcher fetches assets over HTTP(S). The zero value uses http.DefaultTransport with a
+// 30s timeout and allows any host; set AllowedHosts to restrict where prompts may reach.
+// It also implements DocumentResolver so it can be passed as ParseOptions.DocumentResolver.
+type HTTPAssetFetcher struct {
+	// Client overrides the HTTP client; its Timeout is used when Timeout is zero.
+	Client *http.Client
+	// Timeout bounds each request; zero applies a 30s default unless Client sets its own.
+	Timeout time.Duration
+	// MaxBytes caps response bodies; zero defers to the caller's cap, negative disables this cap.
+	MaxBytes int64
+	// AllowedHosts lists permitted hostnames (no port); "*.example.com" matches subdomains.
+	// Empty allows any host. Redirects are checked against the same list.
+	AllowedHosts []string
+}
+
+// FetchAsset GETs src and returns its body and media type.
+func (f HTTPAssetFetcher) FetchAsset(src string, maxBytes int64) ([]byte, string, error) {
+	return f.FetchAssetContext(context.Background(), src, maxBytes)
+}
+
+// FetchAssetContext is FetchAsset bound to ctx; cancelling ctx aborts the request and body read.
+func (f HTTPAssetFetcher) FetchAssetContext(ctx context.Context, src string, maxBytes int64) ([]byte, string, error) {
+	data, contentType, _, _, err := f.FetchAssetConditional(ctx, src, maxBytes, "")
+	return data, contentType, err
+}
+
+// FetchAssetConditional is FetchAssetContext with If-None-Match revalidation: a 304 response
+// reports notModified.
+func (f HTTPAssetFetcher) FetchAssetConditional(ctx context.Context, src string, maxBytes int64, etag string) ([]byte, string, string, bool, error) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return nil, "", "", false, fmt.Errorf("fetch asset %s: %w", src, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, "", "", false, fmt.Errorf("fetch asset %s: unsupported scheme %q", src, u.Scheme)
+	}
+	if err := f.checkHost(u); err != nil {
+		return nil, "", "", false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", "", false, fmt.Errorf("fetch asset %s: %w", src, err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := f.client().Do(req)
+	if err != nil {
+		return nil, "", "", false, fmt.Errorf("fetch asset %s: %w", src, err)
+	}
+	defer resp.Body.Close()
+	if etag != "" && resp.StatusCode == http.StatusNotModified {
+		return nil, "", etag, true, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, "", "", false, fmt.Errorf("fetch asset %s: unexpected status %s", src, resp.Status)
+	}
+	limit := maxBytes
+	if f.MaxBytes > 0 && (limit <= 0 || f.MaxBytes < limit) {
+		limit = f.MaxBytes
+	}
+	if limit > 0 && resp.ContentLength > limit {
+		return nil, "", "", false, fmt.Errorf("file %s exceeds max size %d bytes", src, limit)
+	}
+	data, err := readAllWithLimit(ctx, resp.Body, limit, src)
+	if err != nil {
+		return nil, "", "", false, err
+	}
+	contentType := ""
+	if mt, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		contentType = mt
+	}
+	return data, contentType, resp.Header.Get("ETag"), false, nil
+}
+
+// ResolveDocument fetches http(s) document sources.
+func (f HTTPAssetFetcher) ResolveDocument(ref DocRef) ([]byte, error) {
+	return f.ResolveDocumentContext(context.Background(), ref)
+}
+
+// ResolveDocumentContext is ResolveDocument bound to ctx.
+func (f HTTPAssetFetcher) ResolveDocumentContext(ctx context.Context, ref DocRef) ([]byte, error) {
+	limit := f.MaxBytes
+	if limit == 0 {
+		limit = defaultMaxDocumentBytes
+	}
+	data, _, err := f.FetchAssetContext(ctx, strings.TrimSpace(ref.Src), limit)
+	return data, err
+}
+
+func (f HTTPAssetFetcher) client() *http.Client {
+	var c http.Client
+	if f.Client != nil {
+		c = *f.Client
+	}
+	if f.Timeout > 0 {
+		c.Timeout = f.Timeout
+	} else if c.Timeout == 0 {
+		c.Timeout = defaultAssetFetchTimeout
+	}
+	next := c.CheckRedirect
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := f.checkHost(req.URL); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &c
+}
+
+func (f HTTPAssetFetcher) checkHost(u *url.URL) error {
+	if len(f.AllowedHosts) == 0 {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range f.AllowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if host == allowed {
+			return nil
+		}
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok && strings.HasSuffix(host, "."+suffix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("fetch asset %s: host %q not in AllowedHosts", u.Redacted(), host)
+}
+
+// isRemoteSrc reports whether src should be fetched via an AssetFetcher.
+func isRemoteSrc(src string) bool {
+	s := strings.ToLower(strings.TrimSpace(src))
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
@@ -0,0 +1,63 @@
+package poml
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPAssetFetcherConvertsRemoteSources(t *testing.T) {
+	png, _ := base64.StdEncoding.DecodeString(pngData)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a.png":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(png)
+		case "/notes.txt":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte("remote notes"))
+		case "/big":
+			_, _ = w.Write([]byte(strings.Repeat("x", 64)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	src := `<poml><img src="` + srv.URL + `/a.png" alt="remote" /><document src="` + srv.URL + `/notes.txt" /></poml>`
+	if _, err := ConvertString(src, FormatOpenAIChat, ConvertOptions{}); err == nil {
+		t.Fatalf("expected remote image to require an AssetFetcher")
+	}
+	outAny, err := ConvertString(src, FormatOpenAIChat, ConvertOptions{AssetFetcher: HTTPAssetFetcher{}})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	msgs := outAny.(map[string]any)["messages"].([]map[string]any)
+	if len(msgs) != 2 {
+		t.Fatalf("expected image and document messages, got %+v", msgs)
+	}
+	url := msgs[0]["content"].([]any)[1].(map[string]any)["image_url"].(map[string]any)["url"].(string)
+	if url != "data:image/png;base64,"+pngData {
+		t.Fatalf("remote image not inlined: %s", url)
+	}
+	if msgs[1]["content"] != "remote notes" {
+		t.Fatalf("remote document not fetched: %+v", msgs[1])
+	}
+
+	fetcher := HTTPAssetFetcher{MaxBytes: 16}
+	if _, _, err := fetcher.FetchAsset(srv.URL+"/big", 0); err == nil || !strings.Contains(err.Error(), "exceeds max size") {
+		t.Fatalf("expected size cap error, got %v", err)
+	}
+	if _, _, err := (HTTPAssetFetcher{}).FetchAsset(srv.URL+"/missing", 0); err == nil {
+		t.Fatalf("expected error for 404")
+	}
+	blocked := HTTPAssetFetcher{AllowedHosts: []string{"*.example.com"}}
+	if _, _, err := blocked.FetchAsset(srv.URL+"/a.png", 0); err == nil || !strings.Contains(err.Error(), "AllowedHosts") {
+		t.Fatalf("expected host allowlist rejection, got %v", err)
+	}
+	if _, _, err := (HTTPAssetFetcher{}).FetchAsset("ftp://example.com/a.png", 0); err == nil {
+		t.Fatalf("expected unsupported scheme error")
+	}
+}
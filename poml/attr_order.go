@@ -0,0 +1,82 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// attrOrder returns the attribute names of a start tag as written, space-separated, or "" when
+// there are too few for order to matter. Names carry their namespace as yamlAttrName spells it.
+func attrOrder(attrs []xml.Attr) string {
+	if len(attrs) < 2 {
+		return ""
+	}
+	names := make([]string, len(attrs))
+	for i, a := range attrs {
+		names[i] = yamlAttrName(a.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+// newScratchEncoder returns an encoder for one element at opts.depth, indenting its children as
+// the document encoder would so the inner markup can be spliced back unchanged.
+func newScratchEncoder(w io.Writer, opts EncodeOptions) *xml.Encoder {
+	enc := xml.NewEncoder(w)
+	if !opts.Compact && opts.Indent != "" {
+		enc.Indent(strings.Repeat(opts.Indent, opts.depth), opts.Indent)
+	}
+	return enc
+}
+
+// orderedElement re-emits an encoded element: Attrs are written in slice order under their
+// literal names and Inner verbatim.
+type orderedElement struct {
+	Attrs []xml.Attr `xml:",any,attr"`
+	Inner string     `xml:",innerxml"`
+}
+
+// encodeInAttrOrder writes the single element encoded in src through enc with its attributes
+// sorted by order, as attrOrder spells it. Attributes order does not name (added since parsing)
+// keep their encoded order after the ones it does.
+func encodeInAttrOrder(enc *xml.Encoder, src []byte, attrOrder string) error {
+	order := strings.Fields(attrOrder)
+	dec := xml.NewDecoder(bytes.NewReader(src))
+	var start xml.StartElement
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			return err
+		}
+		if t, ok := tok.(xml.StartElement); ok {
+			start = t
+			break
+		}
+	}
+	inner := src[dec.InputOffset():]
+	end := bytes.LastIndex(inner, []byte("</"))
+	if end < 0 {
+		return errors.New("encode: element has no end tag")
+	}
+	rank := make(map[string]int, len(order))
+	for i, name := range order {
+		if _, ok := rank[name]; !ok {
+			rank[name] = i
+		}
+	}
+	attrs := make([]xml.Attr, len(start.Attr))
+	for i, a := range start.Attr {
+		attrs[i] = xml.Attr{Name: xml.Name{Local: yamlAttrName(a.Name)}, Value: a.Value}
+	}
+	pos := func(a xml.Attr) int {
+		if i, ok := rank[a.Name.Local]; ok {
+			return i
+		}
+		return len(order)
+	}
+	sort.SliceStable(attrs, func(i, j int) bool { return pos(attrs[i]) < pos(attrs[j]) })
+	return enc.EncodeElement(orderedElement{Attrs: attrs, Inner: string(inner[:end])}, xml.StartElement{Name: xml.Name{Local: yamlAttrName(start.Name)}})
+}
@@ -0,0 +1,59 @@
+package poml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreserveWSKeepsAttributeOrder(t *testing.T) {
+	src := "<poml>\n  <input required=\"true\" name=\"q\">a &amp; b</input>\n  <tool-definition description=\"Look up\" name=\"lookup\">{}</tool-definition>\n</poml>"
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if doc.Elements[0].AttrOrder != "required name" {
+		t.Fatalf("attr order = %q", doc.Elements[0].AttrOrder)
+	}
+	var b strings.Builder
+	if err := doc.EncodeWithOptions(&b, EncodeOptions{PreserveOrder: true, PreserveWS: true}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if b.String() != src {
+		t.Fatalf("round trip changed the source:\n%s", b.String())
+	}
+
+	if err := doc.Inputs[0].SetAttr("default", "x"); err != nil {
+		t.Fatalf("set attr: %v", err)
+	}
+	b.Reset()
+	if err := doc.EncodeElement(&b, doc.Elements[0], EncodeOptions{PreserveWS: true}); err != nil {
+		t.Fatalf("encode element: %v", err)
+	}
+	if want := `<input required="true" name="q" default="x">a &amp; b</input>`; b.String() != want {
+		t.Fatalf("added attribute not appended:\n%s", b.String())
+	}
+
+	b.Reset()
+	if err := doc.EncodeElement(&b, doc.Elements[0], EncodeOptions{}); err != nil {
+		t.Fatalf("encode element: %v", err)
+	}
+	if !strings.HasPrefix(b.String(), `<input name="q" required="true"`) {
+		t.Fatalf("canonical order expected without PreserveWS:\n%s", b.String())
+	}
+}
+
+func TestPreserveWSAttributeOrderKeepsIndentation(t *testing.T) {
+	src := "<poml>\n  <style z=\"1\" a=\"2\">\n    <output format=\"json\"></output>\n  </style>\n</poml>"
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	doc.Elements[0].Leading, doc.Elements[0].Trailing = "", ""
+	var b strings.Builder
+	if err := doc.EncodeWithOptions(&b, EncodeOptions{PreserveOrder: true, PreserveWS: true, Indent: "  "}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if b.String() != src {
+		t.Fatalf("indented output differs:\n%s", b.String())
+	}
+}
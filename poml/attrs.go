@@ -0,0 +1,300 @@
+package poml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Attrs is the list of attributes an element keeps beyond its typed fields, in source order.
+// Names match an attribute's local name, or "prefix:local" for a namespaced one.
+type Attrs []xml.Attr
+
+// Lookup returns the value of the first attribute called name.
+func (a Attrs) Lookup(name string) (string, bool) {
+	if i := a.index(name); i >= 0 {
+		return a[i].Value, true
+	}
+	return "", false
+}
+
+// Get returns the value of attribute name, or "" when it is absent.
+func (a Attrs) Get(name string) string {
+	v, _ := a.Lookup(name)
+	return v
+}
+
+// Set replaces the value of attribute name in place, or appends it when absent.
+func (a *Attrs) Set(name, value string) {
+	if i := a.index(name); i >= 0 {
+		(*a)[i].Value = value
+		return
+	}
+	var n xml.Name
+	if space, local, ok := strings.Cut(name, ":"); ok {
+		n = xml.Name{Space: space, Local: local}
+	} else {
+		n = xml.Name{Local: name}
+	}
+	*a = append(*a, xml.Attr{Name: n, Value: value})
+}
+
+// Delete removes every attribute called name and reports whether there was one.
+func (a *Attrs) Delete(name string) bool {
+	out := (*a)[:0]
+	for _, attr := range *a {
+		if !attrNamed(attr.Name, name) {
+			out = append(out, attr)
+		}
+	}
+	removed := len(out) < len(*a)
+	*a = out
+	return removed
+}
+
+func (a Attrs) index(name string) int {
+	for i, attr := range a {
+		if attrNamed(attr.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func attrNamed(n xml.Name, name string) bool {
+	if n.Space == "" {
+		return n.Local == name
+	}
+	return n.Space+":"+n.Local == name || (n.Local == name && !strings.Contains(name, ":"))
+}
+
+// Attribute accessors for each payload type. Attr and SetAttr go through the typed field that
+// holds an attribute (Name, Src, ID, ...) when the type has one, and through Attrs otherwise, so
+// SetAttr("name", ...) on a ToolDefinition updates Name rather than adding a second name.
+
+func (b Block) Attr(name string) string                    { return attrOf(&b, name) }
+func (b *Block) SetAttr(name, value string) error          { return setAttrOf(b, name, value) }
+func (in Input) Attr(name string) string                   { return attrOf(&in, name) }
+func (in *Input) SetAttr(name, value string) error         { return setAttrOf(in, name, value) }
+func (r DocRef) Attr(name string) string                   { return attrOf(&r, name) }
+func (r *DocRef) SetAttr(name, value string) error         { return setAttrOf(r, name, value) }
+func (s Style) Attr(name string) string                    { return attrOf(&s, name) }
+func (s *Style) SetAttr(name, value string) error          { return setAttrOf(s, name, value) }
+func (o Output) Attr(name string) string                   { return attrOf(&o, name) }
+func (o *Output) SetAttr(name, value string) error         { return setAttrOf(o, name, value) }
+func (f OutputFormat) Attr(name string) string             { return attrOf(&f, name) }
+func (f *OutputFormat) SetAttr(name, value string) error   { return setAttrOf(f, name, value) }
+func (h Hint) Attr(name string) string                     { return attrOf(&h, name) }
+func (h *Hint) SetAttr(name, value string) error           { return setAttrOf(h, name, value) }
+func (e Example) Attr(name string) string                  { return attrOf(&e, name) }
+func (e *Example) SetAttr(name, value string) error        { return setAttrOf(e, name, value) }
+func (c ContentPart) Attr(name string) string              { return attrOf(&c, name) }
+func (c *ContentPart) SetAttr(name, value string) error    { return setAttrOf(c, name, value) }
+func (o ObjectTag) Attr(name string) string                { return attrOf(&o, name) }
+func (o *ObjectTag) SetAttr(name, value string) error      { return setAttrOf(o, name, value) }
+func (i Image) Attr(name string) string                    { return attrOf(&i, name) }
+func (i *Image) SetAttr(name, value string) error          { return setAttrOf(i, name, value) }
+func (m Media) Attr(name string) string                    { return attrOf(&m, name) }
+func (m *Media) SetAttr(name, value string) error          { return setAttrOf(m, name, value) }
+func (m Message) Attr(name string) string                  { return attrOf(&m, name) }
+func (m *Message) SetAttr(name, value string) error        { return setAttrOf(m, name, value) }
+func (t ToolDefinition) Attr(name string) string           { return attrOf(&t, name) }
+func (t *ToolDefinition) SetAttr(name, value string) error { return setAttrOf(t, name, value) }
+func (t ToolRequest) Attr(name string) string              { return attrOf(&t, name) }
+func (t *ToolRequest) SetAttr(name, value string) error    { return setAttrOf(t, name, value) }
+func (t ToolResponse) Attr(name string) string             { return attrOf(&t, name) }
+func (t *ToolResponse) SetAttr(name, value string) error   { return setAttrOf(t, name, value) }
+func (t ToolResult) Attr(name string) string               { return attrOf(&t, name) }
+func (t *ToolResult) SetAttr(name, value string) error     { return setAttrOf(t, name, value) }
+func (t ToolError) Attr(name string) string                { return attrOf(&t, name) }
+func (t *ToolError) SetAttr(name, value string) error      { return setAttrOf(t, name, value) }
+func (s OutputSchema) Attr(name string) string             { return attrOf(&s, name) }
+func (s *OutputSchema) SetAttr(name, value string) error   { return setAttrOf(s, name, value) }
+func (r Runtime) Attr(name string) string                  { return attrOf(&r, name) }
+func (r *Runtime) SetAttr(name, value string) error        { return setAttrOf(r, name, value) }
+func (t Trace) Attr(name string) string                    { return attrOf(&t, name) }
+func (t *Trace) SetAttr(name, value string) error          { return setAttrOf(t, name, value) }
+func (dg Diagram) Attr(name string) string                 { return attrOf(&dg, name) }
+func (dg *Diagram) SetAttr(name, value string) error       { return setAttrOf(dg, name, value) }
+
+// Attr returns attribute name of the payload's element, or "" when it is absent.
+func (p ElementPayload) Attr(name string) string {
+	v, _ := p.LookupAttr(name)
+	return v
+}
+
+// LookupAttr returns attribute name of the payload's element. Typed fields at their zero value
+// (an empty Src, a false Required) count as absent.
+func (p ElementPayload) LookupAttr(name string) (string, bool) {
+	node := p.node()
+	if node == nil {
+		return "", false
+	}
+	return lookupAttrOf(node, name)
+}
+
+// SetAttr sets attribute name on the payload's element. Payloads passed to Mutate point into the
+// document, so the change sticks; call Mutator.MarkModified or use Mutator.SetAttr.
+func (p ElementPayload) SetAttr(name, value string) error {
+	node := p.node()
+	if node == nil {
+		return fmt.Errorf("set attribute %s: element has no editable payload", name)
+	}
+	return setAttrOf(node, name, value)
+}
+
+// DeleteAttr removes attribute name from the payload's element, resetting a typed field to its
+// zero value, and reports whether it was set.
+func (p ElementPayload) DeleteAttr(name string) bool {
+	node := p.node()
+	if node == nil {
+		return false
+	}
+	return deleteAttrOf(node, name)
+}
+
+// attrValues returns pointers to the payload's string attribute values; see attrValuesOf.
+func (p ElementPayload) attrValues() []*string {
+	node := p.node()
+	if node == nil {
+		return nil
+	}
+	return attrValuesOf(node)
+}
+
+// node returns the payload's one non-nil pointer.
+func (p ElementPayload) node() any {
+	rv := reflect.ValueOf(p)
+	for i := 0; i < rv.NumField(); i++ {
+		if f := rv.Field(i); f.Kind() == reflect.Pointer && !f.IsNil() {
+			return f.Interface()
+		}
+	}
+	return nil
+}
+
+// SetAttr sets attribute name on el (matched by ID).
+func (m *Mutator) SetAttr(el Element, name, value string) error {
+	p, ok := m.currentPayload(el)
+	if !ok {
+		return fmt.Errorf("set attribute %s: element %s not found", name, el.ID)
+	}
+	if err := p.SetAttr(name, value); err != nil {
+		return err
+	}
+	m.modified = true
+	return nil
+}
+
+// RemoveAttr removes attribute name from el (matched by ID) and reports whether it was set.
+func (m *Mutator) RemoveAttr(el Element, name string) bool {
+	p, ok := m.currentPayload(el)
+	if !ok || !p.DeleteAttr(name) {
+		return false
+	}
+	m.modified = true
+	return true
+}
+
+// currentPayload resolves el by ID, so indexes shifted by earlier edits in the walk don't matter.
+func (m *Mutator) currentPayload(el Element) (ElementPayload, bool) {
+	for _, cur := range m.doc.Elements {
+		if cur.ID == el.ID {
+			return m.doc.payloadFor(cur), true
+		}
+	}
+	return ElementPayload{}, false
+}
+
+func attrOf(v any, name string) string {
+	s, _ := lookupAttrOf(v, name)
+	return s
+}
+
+// typedAttrField returns the field of struct rv tagged `xml:"name,attr"`.
+func typedAttrField(rv reflect.Value, name string) (reflect.Value, bool) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if tag, opts, _ := strings.Cut(t.Field(i).Tag.Get("xml"), ","); tag == name && opts == "attr" {
+			return rv.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// attrValuesOf returns pointers to the string attribute values of v, a payload pointer: its
+// string fields tagged `xml:"name,attr"` and the values in its Attrs.
+func attrValuesOf(v any) []*string {
+	rv := reflect.ValueOf(v).Elem()
+	t := rv.Type()
+	var out []*string
+	for i := 0; i < t.NumField(); i++ {
+		if _, opts, _ := strings.Cut(t.Field(i).Tag.Get("xml"), ","); opts == "attr" && t.Field(i).Type == reflect.TypeFor[string]() {
+			out = append(out, rv.Field(i).Addr().Interface().(*string))
+		}
+	}
+	if a := rv.FieldByName("Attrs"); a.IsValid() {
+		attrs := a.Interface().(Attrs)
+		for i := range attrs {
+			out = append(out, &attrs[i].Value)
+		}
+	}
+	return out
+}
+
+func lookupAttrOf(v any, name string) (string, bool) {
+	rv := reflect.ValueOf(v).Elem()
+	if f, ok := typedAttrField(rv, name); ok {
+		switch {
+		case f.IsZero():
+			return "", false
+		case f.Kind() == reflect.Bool:
+			return strconv.FormatBool(f.Bool()), true
+		}
+		return f.String(), true
+	}
+	if a := rv.FieldByName("Attrs"); a.IsValid() {
+		return a.Interface().(Attrs).Lookup(name)
+	}
+	return "", false
+}
+
+func setAttrOf(v any, name, value string) error {
+	if !validXMLName(name) {
+		return fmt.Errorf("set attribute: invalid name %q", name)
+	}
+	rv := reflect.ValueOf(v).Elem()
+	if f, ok := typedAttrField(rv, name); ok {
+		if f.Kind() == reflect.Bool {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("set attribute %s: %w", name, err)
+			}
+			f.SetBool(b)
+			return nil
+		}
+		f.SetString(value)
+		return nil
+	}
+	a := rv.FieldByName("Attrs")
+	if !a.IsValid() {
+		return fmt.Errorf("set attribute %s: <%s> takes no attributes", name, strings.ToLower(rv.Type().Name()))
+	}
+	a.Addr().Interface().(*Attrs).Set(name, value)
+	return nil
+}
+
+func deleteAttrOf(v any, name string) bool {
+	rv := reflect.ValueOf(v).Elem()
+	if f, ok := typedAttrField(rv, name); ok {
+		was := !f.IsZero()
+		f.Set(reflect.Zero(f.Type()))
+		return was
+	}
+	if a := rv.FieldByName("Attrs"); a.IsValid() {
+		return a.Addr().Interface().(*Attrs).Delete(name)
+	}
+	return false
+}
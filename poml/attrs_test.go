@@ -0,0 +1,81 @@
+package poml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttrsAccessors(t *testing.T) {
+	var a Attrs
+	a.Set("caption", "One")
+	a.Set("xml:lang", "en")
+	a.Set("caption", "Two")
+	if v, ok := a.Lookup("caption"); !ok || v != "Two" || len(a) != 2 {
+		t.Fatalf("unexpected attrs: %+v", a)
+	}
+	if a.Get("xml:lang") != "en" || a.Get("missing") != "" {
+		t.Fatalf("unexpected lookups: %+v", a)
+	}
+	if !a.Delete("caption") || a.Delete("caption") || len(a) != 1 {
+		t.Fatalf("unexpected delete: %+v", a)
+	}
+
+	td := ToolDefinition{Name: "lookup"}
+	if err := td.SetAttr("name", "search"); err != nil || td.Name != "search" || len(td.Attrs) != 0 {
+		t.Fatalf("SetAttr should update the typed field: %+v %v", td, err)
+	}
+	if err := td.SetAttr("strict", "true"); err != nil || td.Attr("strict") != "true" || td.Attr("name") != "search" {
+		t.Fatalf("unexpected tool attrs: %+v %v", td, err)
+	}
+	in := Input{Name: "q"}
+	if err := in.SetAttr("required", "maybe"); err == nil {
+		t.Fatalf("expected a bool parse error")
+	}
+	if err := (&Hint{}).SetAttr("bad name", "x"); err == nil {
+		t.Fatalf("expected an invalid name error")
+	}
+}
+
+func TestMutatorSetAttr(t *testing.T) {
+	doc, err := ParseString(`<poml><role>r</role><hint caption="Old">h</hint><img src="a.png"/></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	err = doc.Mutate(func(el Element, p ElementPayload, m *Mutator) error {
+		switch el.Type {
+		case ElementRole:
+			return m.SetAttr(el, "caption", "Persona")
+		case ElementHint:
+			if p.Attr("caption") != "Old" {
+				t.Fatalf("unexpected caption %q", p.Attr("caption"))
+			}
+			return m.SetAttr(el, "caption", "New")
+		case ElementImage:
+			if !m.RemoveAttr(el, "src") {
+				t.Fatalf("src should have been set")
+			}
+			return p.SetAttr("alt", "chart")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("mutate: %v", err)
+	}
+	if doc.Role.Attr("caption") != "Persona" || doc.Hints[0].Attrs.Get("caption") != "New" || doc.Images[0].Src != "" || doc.Images[0].Alt != "chart" {
+		t.Fatalf("edits not applied: %+v %+v %+v", doc.Role, doc.Hints[0], doc.Images[0])
+	}
+	var b strings.Builder
+	if err := doc.Encode(&b); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if !strings.Contains(b.String(), `<hint caption="New">`) || !strings.Contains(b.String(), `<role caption="Persona">`) {
+		t.Fatalf("unexpected encoding: %s", b.String())
+	}
+	if _, p, _ := doc.ElementByID(doc.Elements[0].ID); p.SetAttr("x", "1") != nil {
+		t.Fatalf("role payload should accept attributes")
+	}
+	meta := ElementPayload{Meta: &Meta{}}
+	if err := meta.SetAttr("id", "x"); err == nil {
+		t.Fatalf("expected an error for <meta>")
+	}
+}
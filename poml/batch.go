@@ -0,0 +1,71 @@
+package poml
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// ConvertBatch converts docs with up to concurrency workers (GOMAXPROCS when concurrency <= 0)
+// and returns one output and one error per document, in input order. Every document is converted
+// with opts under ctx as in ConvertContext; once ctx is done, documents not yet started fail with
+// ctx.Err(). Images and media referenced by several documents are read and Base64-encoded once
+// per batch, which dominates the cost when prompts share assets.
+func ConvertBatch(ctx context.Context, docs []Document, format Format, opts ConvertOptions, concurrency int) ([]any, []error) {
+	outs := make([]any, len(docs))
+	errs := make([]error, len(docs))
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	concurrency = min(concurrency, len(docs))
+	if opts.assets == nil {
+		opts.assets = &assetMemo{}
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				outs[i], errs[i] = ConvertContext(ctx, docs[i], format, opts)
+			}
+		}()
+	}
+	for i := range docs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return outs, errs
+}
+
+// assetMemo shares encoded assets between the conversions of one batch. Concurrent requests for
+// the same key wait for a single read; failures are shared too, since every document in the
+// batch would hit the same error.
+type assetMemo struct {
+	mu      sync.Mutex
+	entries map[string]*assetMemoEntry
+}
+
+type assetMemoEntry struct {
+	once        sync.Once
+	data        string
+	contentType string
+	err         error
+}
+
+func (m *assetMemo) load(key string, read func() (string, string, error)) (string, string, error) {
+	m.mu.Lock()
+	if m.entries == nil {
+		m.entries = map[string]*assetMemoEntry{}
+	}
+	e, ok := m.entries[key]
+	if !ok {
+		e = &assetMemoEntry{}
+		m.entries[key] = e
+	}
+	m.mu.Unlock()
+	e.once.Do(func() { e.data, e.contentType, e.err = read() })
+	return e.data, e.contentType, e.err
+}
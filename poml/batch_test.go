@@ -0,0 +1,65 @@
+package poml
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+type countingFetcher struct{ calls atomic.Int32 }
+
+func (f *countingFetcher) FetchAsset(src string, maxBytes int64) ([]byte, string, error) {
+	f.calls.Add(1)
+	data, err := base64.StdEncoding.DecodeString(pngData)
+	return data, "image/png", err
+}
+
+func TestConvertBatch(t *testing.T) {
+	var docs []Document
+	for i := 0; i < 20; i++ {
+		src := fmt.Sprintf(`<poml><human-msg>doc %d</human-msg><img src="https://example.com/shared.png" alt="x"/></poml>`, i)
+		if i == 7 {
+			src = `<poml><img src="missing.png" alt="x"/></poml>`
+		}
+		doc, err := ParseString(src)
+		if err != nil {
+			t.Fatalf("parse %d: %v", i, err)
+		}
+		docs = append(docs, doc)
+	}
+	fetcher := &countingFetcher{}
+	outs, errs := ConvertBatch(context.Background(), docs, FormatOpenAIChat, ConvertOptions{AssetFetcher: fetcher, BaseDir: t.TempDir()}, 4)
+	if len(outs) != len(docs) || len(errs) != len(docs) {
+		t.Fatalf("expected %d results, got %d/%d", len(docs), len(outs), len(errs))
+	}
+	for i, out := range outs {
+		if i == 7 {
+			if errs[i] == nil {
+				t.Fatalf("doc 7 should fail on its missing image")
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Fatalf("doc %d: %v", i, errs[i])
+		}
+		msgs := out.(map[string]any)["messages"].([]map[string]any)
+		if msgs[0]["content"] != fmt.Sprintf("doc %d", i) {
+			t.Fatalf("result %d out of order: %+v", i, msgs[0])
+		}
+	}
+	if n := fetcher.calls.Load(); n != 1 {
+		t.Fatalf("shared image fetched %d times, want 1", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, errs = ConvertBatch(ctx, docs[:3], FormatOpenAIChat, ConvertOptions{}, 0)
+	for i, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("doc %d: expected context.Canceled, got %v", i, err)
+		}
+	}
+}
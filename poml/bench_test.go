@@ -69,3 +69,39 @@ func BenchmarkDiagramToScene(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkIncrementalEdit(b *testing.B) {
+	var src bytes.Buffer
+	src.WriteString("<poml>\n")
+	for i := 0; i < 5000; i++ {
+		src.WriteString("  <task>Summarize section of the report</task>\n")
+	}
+	src.WriteString("</poml>")
+	p, err := NewIncrementalParser(src.Bytes(), defaultParseOptions)
+	if err != nil {
+		b.Fatalf("parse: %v", err)
+	}
+	at := bytes.Index(src.Bytes(), []byte("section")) + len("section")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Apply(TextEdit{Start: at, End: at, Text: "x"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendEncode(b *testing.B) {
+	doc, err := ParseString(benchDoc)
+	if err != nil {
+		b.Fatalf("parse: %v", err)
+	}
+	opts := EncodeOptions{Indent: "  ", PreserveOrder: true}
+	b.ReportAllocs()
+	b.ResetTimer()
+	var buf []byte
+	for i := 0; i < b.N; i++ {
+		if buf, err = AppendEncode(buf[:0], doc, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 // Builder provides a fluent API for constructing a Document in code (similar to the Python Prompt builder).
@@ -154,6 +156,28 @@ func (b *Builder) Runtime(attrs map[string]any) *Builder {
 	return b
 }
 
+// Trace appends a trace entry from a map of metadata, with attributes in key order.
+func (b *Builder) Trace(metadata map[string]string) *Builder {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var attrs []xml.Attr
+	for _, k := range keys {
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: k}, Value: metadata[k]})
+	}
+	b.doc.AddTrace(attrs...)
+	return b
+}
+
+// RuntimeOptions appends a runtime entry from typed options.
+func (b *Builder) RuntimeOptions(opts RuntimeOptions) *Builder {
+	b.doc.Runtimes = append(b.doc.Runtimes, opts.Runtime())
+	b.doc.Elements = append(b.doc.Elements, b.doc.newElement(ElementRuntime, len(b.doc.Runtimes)-1, ""))
+	return b
+}
+
 // Image appends an image element.
 func (b *Builder) Image(img Image) *Builder {
 	b.doc.Images = append(b.doc.Images, img)
@@ -189,6 +213,15 @@ func (b *Builder) Example(body string, attrs ...xml.Attr) *Builder {
 	return b
 }
 
+// ExamplePair appends an <example> of one <input> and one <output>, which the chat converters
+// emit as a user/assistant message pair. input and output are inserted as markup.
+func (b *Builder) ExamplePair(input, output string, attrs ...xml.Attr) *Builder {
+	body := "<input>" + input + "</input><output>" + output + "</output>"
+	b.doc.Examples = append(b.doc.Examples, Example{Body: body, Attrs: attrs, Pair: &ExamplePair{Input: strings.TrimSpace(input), Output: strings.TrimSpace(output)}})
+	b.doc.Elements = append(b.doc.Elements, b.doc.newElement(ElementExample, len(b.doc.Examples)-1, ""))
+	return b
+}
+
 // ContentPart appends a content part (<cp>).
 func (b *Builder) ContentPart(body string, attrs ...xml.Attr) *Builder {
 	b.doc.ContentParts = append(b.doc.ContentParts, ContentPart{Body: body, Attrs: attrs})
@@ -216,6 +249,15 @@ func (b *Builder) Raw(rawXML string) *Builder {
 	return b
 }
 
+// Comment annotates the most recently added element; it is written as a <!-- --> comment
+// before the element when the document is encoded.
+func (b *Builder) Comment(text string) *Builder {
+	if n := len(b.doc.Elements); n > 0 {
+		b.doc.Elements[n-1].Comment = text
+	}
+	return b
+}
+
 func marshalAny(v any) string {
 	switch val := v.(type) {
 	case nil:
@@ -0,0 +1,341 @@
+// Package bundle packs a POML document and the files it references into a single archive, so a
+// prompt ships as one artifact instead of a .poml file plus loose assets.
+//
+// A bundle is a zip or tar archive holding manifest.json, the prompt as prompt.poml, and every
+// local <img>, <audio>, <video>, and <document> source under assets/, named by content hash so
+// repeated files are stored once. The packed prompt's src attributes point at those entries.
+// Read loads a bundle back and serves its entries through an fs.FS, so converting it never
+// touches the host filesystem:
+//
+//	var buf bytes.Buffer
+//	if _, err := bundle.Write(&buf, doc, bundle.Options{BaseDir: "prompts"}); err != nil { ... }
+//	b, err := bundle.Read(&buf, poml.ParseOptions{PreserveWhitespace: true})
+//	out, err := b.Convert(poml.FormatOpenAIChat, poml.ConvertOptions{})
+package bundle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+// Archive entry names.
+const (
+	ManifestName = "manifest.json"
+	PromptName   = "prompt.poml"
+	AssetDir     = "assets"
+)
+
+// ManifestVersion is the manifest format Write produces and Read accepts.
+const ManifestVersion = 1
+
+// Format selects the archive container.
+type Format string
+
+const (
+	Zip Format = "zip"
+	Tar Format = "tar"
+)
+
+// Manifest describes a bundle's contents.
+type Manifest struct {
+	Version int     `json:"version"`
+	Prompt  string  `json:"prompt"`
+	Assets  []Asset `json:"assets,omitempty"`
+}
+
+// Asset is one bundled file.
+type Asset struct {
+	Path      string   `json:"path"`    // entry name inside the archive
+	Sources   []string `json:"sources"` // src values in the original document that pointed at it
+	MediaType string   `json:"media_type,omitempty"`
+	Size      int64    `json:"size"`
+	SHA256    string   `json:"sha256"`
+}
+
+// Options controls Write.
+type Options struct {
+	// Format is the archive container; empty means Zip.
+	Format Format
+	// BaseDir resolves relative sources on disk, or inside FS when FS is set.
+	BaseDir string
+	// FS, when non-nil, serves local sources instead of the host filesystem.
+	FS fs.FS
+	// AllowAbsPaths permits absolute source paths on disk when BaseDir is empty.
+	AllowAbsPaths bool
+	// AssetFetcher, when set, downloads http(s) sources into the bundle; otherwise URLs are
+	// left as they are.
+	AssetFetcher poml.AssetFetcher
+	// MaxAssetBytes caps each asset; zero applies the SDK's 10MB default, negative disables it.
+	MaxAssetBytes int64
+}
+
+// Bundle is a loaded archive.
+type Bundle struct {
+	Manifest Manifest
+	// Document is the packed prompt; its sources are paths inside FS.
+	Document poml.Document
+	// FS serves the archive entries.
+	FS fs.FS
+}
+
+// Write packs doc and the files its sources reference into an archive written to w and returns
+// the manifest. data: URIs and template expressions are left in place, as are http(s) URLs
+// unless opts.AssetFetcher is set; a local source that cannot be read fails the write.
+func Write(w io.Writer, doc poml.Document, opts Options) (Manifest, error) {
+	doc = doc.Clone()
+	m := Manifest{Version: ManifestVersion, Prompt: PromptName}
+	files := map[string][]byte{}
+	packed := map[string]string{} // source -> entry name
+	byName := map[string]int{}    // entry name -> index in m.Assets
+	err := doc.RewriteSources(func(tag, src string) (string, error) {
+		if name, ok := packed[src]; ok {
+			return name, nil
+		}
+		data, contentType, ok, err := loadSource(src, opts)
+		if err != nil || !ok {
+			return src, err
+		}
+		sum := sha256.Sum256(data)
+		digest := hex.EncodeToString(sum[:])
+		name := path.Join(AssetDir, digest[:12]+"-"+assetName(src))
+		i, seen := byName[name]
+		if !seen {
+			if contentType == "" {
+				contentType = mime.TypeByExtension(path.Ext(name))
+			}
+			if contentType == "" {
+				contentType = http.DetectContentType(data)
+			}
+			i = len(m.Assets)
+			byName[name] = i
+			m.Assets = append(m.Assets, Asset{Path: name, MediaType: contentType, Size: int64(len(data)), SHA256: digest})
+			files[name] = data
+		}
+		m.Assets[i].Sources = append(m.Assets[i].Sources, src)
+		packed[src] = name
+		return name, nil
+	})
+	if err != nil {
+		return Manifest{}, fmt.Errorf("bundle: %w", err)
+	}
+	sort.Slice(m.Assets, func(i, j int) bool { return m.Assets[i].Path < m.Assets[j].Path })
+	var prompt bytes.Buffer
+	if err := doc.EncodeWithOptions(&prompt, poml.EncodeOptions{IncludeHeader: true, PreserveOrder: true, PreserveWS: true, Compact: true}); err != nil {
+		return Manifest{}, fmt.Errorf("bundle: encode prompt: %w", err)
+	}
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("bundle: %w", err)
+	}
+	entries := []entry{{ManifestName, manifest}, {PromptName, prompt.Bytes()}}
+	for _, a := range m.Assets {
+		entries = append(entries, entry{a.Path, files[a.Path]})
+	}
+	switch opts.Format {
+	case "", Zip:
+		err = writeZip(w, entries)
+	case Tar:
+		err = writeTar(w, entries)
+	default:
+		err = fmt.Errorf("unsupported format %q", opts.Format)
+	}
+	if err != nil {
+		return Manifest{}, fmt.Errorf("bundle: %w", err)
+	}
+	return m, nil
+}
+
+// Read loads a zip or tar bundle (detected from its content), checks every asset against the
+// manifest, and parses the prompt with opts. <document> sources resolve inside the bundle when
+// opts.ResolveDocuments is set without a DocumentResolver.
+func Read(r io.Reader, opts poml.ParseOptions) (*Bundle, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		// Repack tar entries as a zip so both formats are served by archive/zip's fs.FS.
+		if data, err = tarToZip(data); err != nil {
+			return nil, fmt.Errorf("bundle: %w", err)
+		}
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	b := &Bundle{FS: zr}
+	raw, err := fs.ReadFile(zr, ManifestName)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	if err := json.Unmarshal(raw, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("bundle: manifest: %w", err)
+	}
+	if b.Manifest.Version != ManifestVersion {
+		return nil, fmt.Errorf("bundle: unsupported manifest version %d", b.Manifest.Version)
+	}
+	for _, a := range b.Manifest.Assets {
+		data, err := fs.ReadFile(zr, a.Path)
+		if err != nil {
+			return nil, fmt.Errorf("bundle: asset: %w", err)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != a.SHA256 {
+			return nil, fmt.Errorf("bundle: asset %s does not match its manifest checksum", a.Path)
+		}
+	}
+	prompt := b.Manifest.Prompt
+	if prompt == "" {
+		prompt = PromptName
+	}
+	if b.Document, err = poml.ParseFS(zr, prompt, opts); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Open reads the bundle file at name.
+func Open(name string, opts poml.ParseOptions) (*Bundle, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, opts)
+}
+
+// Convert converts the bundled prompt with opts, serving its sources from the bundle.
+func (b *Bundle) Convert(format poml.Format, opts poml.ConvertOptions) (any, error) {
+	opts.FS, opts.BaseDir = b.FS, ""
+	if dir := path.Dir(b.Manifest.Prompt); dir != "." {
+		opts.BaseDir = dir
+	}
+	return poml.Convert(b.Document, format, opts)
+}
+
+// loadSource reads a bundleable source. ok is false for sources that stay as they are.
+func loadSource(src string, opts Options) (data []byte, contentType string, ok bool, err error) {
+	src = strings.TrimSpace(src)
+	if strings.HasPrefix(src, "data:") || strings.Contains(src, "{{") {
+		return nil, "", false, nil
+	}
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		if opts.AssetFetcher == nil {
+			return nil, "", false, nil
+		}
+		data, contentType, err = opts.AssetFetcher.FetchAsset(src, opts.MaxAssetBytes)
+		return data, contentType, err == nil, err
+	}
+	resolver := poml.FileDocumentResolver{BaseDir: opts.BaseDir, FS: opts.FS, AllowAbsPaths: opts.AllowAbsPaths, MaxBytes: opts.MaxAssetBytes}
+	if opts.FS != nil && opts.BaseDir != "" {
+		resolver.BaseDir = ""
+		if resolver.FS, err = fs.Sub(opts.FS, opts.BaseDir); err != nil {
+			return nil, "", false, err
+		}
+	}
+	data, err = resolver.ResolveDocument(poml.DocRef{Src: src})
+	return data, "", err == nil, err
+}
+
+// assetName derives a safe entry name from the last path element of src.
+func assetName(src string) string {
+	if u, err := url.Parse(src); err == nil && u.Path != "" {
+		src = u.Path
+	}
+	name := path.Base(strings.ReplaceAll(src, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+	if strings.Trim(name, "._") == "" {
+		return "asset"
+	}
+	return name
+}
+
+type entry struct {
+	name string
+	data []byte
+}
+
+// archiveTime stamps entries so identical inputs produce identical archives.
+var archiveTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func writeZip(w io.Writer, entries []entry) error {
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: archiveTime})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(e.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeTar(w io.Writer, entries []entry) error {
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.data)), ModTime: archiveTime, Typeflag: tar.TypeReg, Format: tar.FormatPAX}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// tarToZip copies the regular files of a tar archive into an uncompressed zip.
+func tarToZip(data []byte) ([]byte, error) {
+	tr := tar.NewReader(bytes.NewReader(data))
+	var out bytes.Buffer
+	zw := zip.NewWriter(&out)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("not a zip or tar archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: hdr.Name, Method: zip.Store, Modified: hdr.ModTime})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
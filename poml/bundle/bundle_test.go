@@ -0,0 +1,120 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+var pngBytes = []byte("\x89PNG\r\n\x1a\nfake image")
+
+const bundleSample = `<poml>
+  <task>Describe the chart.</task>
+  <document src="notes.txt"/>
+  <img src="charts/q1.png" alt="chart"/>
+  <human-msg>Compare <img src='charts/q1.png' alt="again"/> with <img src="https://example.com/q2.png"/> and <img src="data:image/png;base64,AA=="/>.</human-msg>
+</poml>`
+
+type stubFetcher struct{}
+
+func (stubFetcher) FetchAsset(src string, maxBytes int64) ([]byte, string, error) {
+	return []byte("remote"), "image/png", nil
+}
+
+func TestWriteAndReadBundle(t *testing.T) {
+	doc, err := poml.ParseString(bundleSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	fsys := fstest.MapFS{
+		"prompts/charts/q1.png": {Data: pngBytes},
+		"prompts/notes.txt":     {Data: []byte("Q1 revenue grew.")},
+	}
+	for _, format := range []Format{Zip, Tar} {
+		var buf bytes.Buffer
+		m, err := Write(&buf, doc, Options{Format: format, FS: fsys, BaseDir: "prompts"})
+		if err != nil {
+			t.Fatalf("%s: write: %v", format, err)
+		}
+		if len(m.Assets) != 2 {
+			t.Fatalf("%s: expected the image once and the document, got %+v", format, m.Assets)
+		}
+		var img Asset
+		for _, a := range m.Assets {
+			if strings.HasSuffix(a.Path, "-q1.png") {
+				img = a
+			}
+		}
+		if img.MediaType != "image/png" || img.Size != int64(len(pngBytes)) || len(img.Sources) != 1 || img.Sources[0] != "charts/q1.png" {
+			t.Fatalf("%s: unexpected image asset: %+v", format, img)
+		}
+
+		b, err := Read(&buf, poml.ParseOptions{PreserveWhitespace: true, ResolveDocuments: true})
+		if err != nil {
+			t.Fatalf("%s: read: %v", format, err)
+		}
+		if b.Document.Images[0].Src != img.Path || b.Document.Documents[0].Content != "Q1 revenue grew." {
+			t.Fatalf("%s: sources not rewritten: %+v %+v", format, b.Document.Images, b.Document.Documents)
+		}
+		body := b.Document.Messages[0].Body
+		if !strings.Contains(body, `src="`+img.Path+`"`) || !strings.Contains(body, `src="https://example.com/q2.png"`) || !strings.Contains(body, "data:image/png;base64,AA==") {
+			t.Fatalf("%s: unexpected message body: %s", format, body)
+		}
+		if doc.Images[0].Src != "charts/q1.png" {
+			t.Fatalf("%s: Write modified the caller's document", format)
+		}
+
+		out, err := b.Convert(poml.FormatOpenAIChat, poml.ConvertOptions{AssetFetcher: stubFetcher{}})
+		if err != nil {
+			t.Fatalf("%s: convert: %v", format, err)
+		}
+		raw, _ := json.Marshal(out)
+		if !strings.Contains(string(raw), base64.StdEncoding.EncodeToString(pngBytes)) {
+			t.Fatalf("%s: bundled image not inlined: %s", format, raw)
+		}
+	}
+}
+
+func TestReadRejectsTamperedAsset(t *testing.T) {
+	doc, err := poml.ParseString(`<poml><img src="a.png"/></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var buf bytes.Buffer
+	m, err := Write(&buf, doc, Options{FS: fstest.MapFS{"a.png": {Data: pngBytes}}})
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	var tampered bytes.Buffer
+	zw := zip.NewWriter(&tampered)
+	for _, f := range zr.File {
+		w, _ := zw.Create(f.Name)
+		if f.Name == m.Assets[0].Path {
+			w.Write([]byte("swapped"))
+			continue
+		}
+		rc, _ := f.Open()
+		var data bytes.Buffer
+		data.ReadFrom(rc)
+		rc.Close()
+		w.Write(data.Bytes())
+	}
+	zw.Close()
+	if _, err := Read(&tampered, poml.ParseOptions{}); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+
+	if _, err := Write(&bytes.Buffer{}, doc, Options{FS: fstest.MapFS{}}); err == nil {
+		t.Fatalf("expected an error for a missing source")
+	}
+}
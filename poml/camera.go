@@ -0,0 +1,223 @@
+package poml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Camera is a SceneCamera with its numbers parsed. The eye orbits Target: Azimuth turns it
+// about +Y and Elevation raises it above the XZ plane, both in degrees, at Distance from
+// Target. Azimuth and elevation zero put the eye on +Z looking down -Z with +Y up, the
+// convention glTF and WebGL use.
+type Camera struct {
+	Azimuth   float64
+	Elevation float64
+	Distance  float64
+	Target    [3]float64
+}
+
+// Mat4 is a 4×4 matrix in column-major order, as WebGL and glTF store them: element (row r,
+// column c) is m[c*4+r].
+type Mat4 [16]float64
+
+// Identity4 returns the identity matrix.
+func Identity4() Mat4 {
+	return Mat4{0: 1, 5: 1, 10: 1, 15: 1}
+}
+
+// ParseCamera parses the azimuth, elevation, and distance of c. Empty fields are zero; a field
+// that is not a number is an error. The target is the origin; see Scene.ViewCamera.
+func ParseCamera(c SceneCamera) (Camera, error) {
+	var cam Camera
+	for _, f := range []struct {
+		name, val string
+		dst       *float64
+	}{{"azimuth", c.Azimuth, &cam.Azimuth}, {"elevation", c.Elevation, &cam.Elevation}, {"distance", c.Distance, &cam.Distance}} {
+		if f.val == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(f.val, 64)
+		if err != nil {
+			return Camera{}, fmt.Errorf("camera %s %q is not a number", f.name, f.val)
+		}
+		*f.dst = v
+	}
+	return cam, nil
+}
+
+// SceneCamera formats c's angles and distance back into a SceneCamera; the target is dropped.
+func (c Camera) SceneCamera() SceneCamera {
+	return SceneCamera{Azimuth: formatFloat(c.Azimuth), Elevation: formatFloat(c.Elevation), Distance: formatFloat(c.Distance)}
+}
+
+// ViewCamera parses the scene's camera and aims it at the centroid of the nodes, where the
+// glTF export places it.
+func (s Scene) ViewCamera() (Camera, error) {
+	cam, err := ParseCamera(s.Camera)
+	if err != nil {
+		return Camera{}, err
+	}
+	for _, n := range s.Nodes {
+		for i := range cam.Target {
+			cam.Target[i] += n.Position[i] / float64(len(s.Nodes))
+		}
+	}
+	return cam, nil
+}
+
+// Eye returns the camera position.
+func (c Camera) Eye() [3]float64 {
+	az, el := c.Azimuth*math.Pi/180, c.Elevation*math.Pi/180
+	return [3]float64{
+		c.Target[0] + c.Distance*math.Cos(el)*math.Sin(az),
+		c.Target[1] + c.Distance*math.Sin(el),
+		c.Target[2] + c.Distance*math.Cos(el)*math.Cos(az),
+	}
+}
+
+// Up returns the world direction that appears upward on screen: +Y, except looking straight
+// down or up, where it is the horizontal direction the azimuth faces, so View stays defined.
+func (c Camera) Up() [3]float64 {
+	el := c.Elevation * math.Pi / 180
+	if math.Abs(math.Cos(el)) > 1e-9 {
+		return [3]float64{0, 1, 0}
+	}
+	az := c.Azimuth * math.Pi / 180
+	s := -math.Copysign(1, math.Sin(el))
+	return [3]float64{s * math.Sin(az), 0, s * math.Cos(az)}
+}
+
+// View returns the world-to-camera matrix of c; see LookAt.
+func (c Camera) View() Mat4 {
+	return LookAt(c.Eye(), c.Target, c.Up())
+}
+
+// Project maps p orthographically onto the camera's image plane for planar renderers (DOT,
+// Mermaid): x runs to the right of the view and y up it, measured from Target and offset by
+// Target's own x and y, so the default camera (azimuth and elevation zero) leaves x and y as
+// they are. Distance does not matter.
+func (c Camera) Project(p [3]float64) [2]float64 {
+	right, up, _ := c.basis()
+	d := sub3(p, c.Target)
+	return [2]float64{c.Target[0] + dot3(d, right), c.Target[1] + dot3(d, up)}
+}
+
+// basis returns the camera's right, up, and backward unit vectors in world space.
+func (c Camera) basis() (right, up, back [3]float64) {
+	az, el := c.Azimuth*math.Pi/180, c.Elevation*math.Pi/180
+	back = [3]float64{math.Cos(el) * math.Sin(az), math.Sin(el), math.Cos(el) * math.Cos(az)}
+	right = normalize3(cross3(c.Up(), back))
+	up = cross3(back, right)
+	return right, up, back
+}
+
+// ProjectScene returns a copy of s with node positions, edge waypoints, and edge paths
+// projected through s.ViewCamera (z becomes 0), so planar renderers draw the scene as the camera
+// sees it. Keyframe coordinates are not projected.
+func ProjectScene(s Scene) (Scene, error) {
+	cam, err := s.ViewCamera()
+	if err != nil {
+		return Scene{}, err
+	}
+	flat := func(p [3]float64) [3]float64 {
+		xy := cam.Project(p)
+		return [3]float64{xy[0], xy[1], 0}
+	}
+	out := s
+	out.Nodes = make([]SceneNode, len(s.Nodes))
+	for i, n := range s.Nodes {
+		n.Position = flat(n.Position)
+		out.Nodes[i] = n
+	}
+	out.Edges = make([]SceneEdge, len(s.Edges))
+	for i, e := range s.Edges {
+		e.Waypoints = projectPath(e.Waypoints, flat)
+		e.Path = projectPath(e.Path, flat)
+		out.Edges[i] = e
+	}
+	return out, nil
+}
+
+func projectPath(path [][3]float64, flat func([3]float64) [3]float64) [][3]float64 {
+	if path == nil {
+		return nil
+	}
+	out := make([][3]float64, len(path))
+	for i, p := range path {
+		out[i] = flat(p)
+	}
+	return out
+}
+
+// LookAt returns the view matrix of an eye at eye looking at target with the given up
+// direction, as gluLookAt builds it: the camera looks down its -Z axis with +Y up.
+func LookAt(eye, target, up [3]float64) Mat4 {
+	f := normalize3(sub3(target, eye))
+	s := normalize3(cross3(f, up))
+	u := cross3(s, f)
+	return Mat4{
+		s[0], u[0], -f[0], 0,
+		s[1], u[1], -f[1], 0,
+		s[2], u[2], -f[2], 0,
+		-dot3(s, eye), -dot3(u, eye), dot3(f, eye), 1,
+	}
+}
+
+// Perspective returns an OpenGL-style projection matrix (clip z in [-1, 1]) for a vertical
+// field of view in degrees, an aspect ratio of width over height, and positive near and far
+// planes.
+func Perspective(fovY, aspect, near, far float64) Mat4 {
+	f := 1 / math.Tan(fovY*math.Pi/360)
+	return Mat4{
+		0:  f / aspect,
+		5:  f,
+		10: (far + near) / (near - far),
+		11: -1,
+		14: 2 * far * near / (near - far),
+	}
+}
+
+// Mul returns m·n, the transform that applies n and then m.
+func (m Mat4) Mul(n Mat4) Mat4 {
+	var out Mat4
+	for c := 0; c < 4; c++ {
+		for r := 0; r < 4; r++ {
+			var v float64
+			for k := 0; k < 4; k++ {
+				v += m[k*4+r] * n[c*4+k]
+			}
+			out[c*4+r] = v
+		}
+	}
+	return out
+}
+
+// Apply transforms point p by m, dividing by w when it is not 1, as after a perspective
+// projection.
+func (m Mat4) Apply(p [3]float64) [3]float64 {
+	var out [4]float64
+	for r := 0; r < 4; r++ {
+		out[r] = m[r]*p[0] + m[4+r]*p[1] + m[8+r]*p[2] + m[12+r]
+	}
+	if out[3] != 1 && out[3] != 0 {
+		return [3]float64{out[0] / out[3], out[1] / out[3], out[2] / out[3]}
+	}
+	return [3]float64{out[0], out[1], out[2]}
+}
+
+func sub3(a, b [3]float64) [3]float64 { return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]} }
+
+func dot3(a, b [3]float64) float64 { return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] }
+
+func cross3(a, b [3]float64) [3]float64 {
+	return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func normalize3(a [3]float64) [3]float64 {
+	l := math.Sqrt(dot3(a, a))
+	if l == 0 {
+		return a
+	}
+	return [3]float64{a[0] / l, a[1] / l, a[2] / l}
+}
@@ -0,0 +1,92 @@
+package poml
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func near3(a, b [3]float64) bool {
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCameraViewMatchesEye(t *testing.T) {
+	cam, err := ParseCamera(SceneCamera{Azimuth: "90", Elevation: "30", Distance: "10"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	cam.Target = [3]float64{1, 2, 3}
+	eye := cam.Eye()
+	if !near3(eye, [3]float64{1 + 10*math.Cos(math.Pi/6), 2 + 5, 3}) {
+		t.Fatalf("eye = %v", eye)
+	}
+	view := cam.View()
+	if got := view.Apply(eye); !near3(got, [3]float64{}) {
+		t.Fatalf("eye should map to the view origin, got %v", got)
+	}
+	if got := view.Apply(cam.Target); !near3(got, [3]float64{0, 0, -10}) {
+		t.Fatalf("target should lie on -Z at the distance, got %v", got)
+	}
+	clip := Perspective(90, 1, 1, 100).Mul(view).Apply(cam.Target)
+	if math.Abs(clip[0]) > 1e-9 || math.Abs(clip[1]) > 1e-9 || clip[2] <= -1 || clip[2] >= 1 {
+		t.Fatalf("target should project to the center of the frustum, got %v", clip)
+	}
+	if got := Identity4().Mul(view); got != view {
+		t.Fatalf("identity product changed the matrix")
+	}
+
+	// Straight down stays well defined and keeps the azimuth's forward direction up on screen.
+	top := Camera{Elevation: 90, Distance: 5}
+	if xy := top.Project([3]float64{0, 7, -2}); math.Abs(xy[0]) > 1e-9 || math.Abs(xy[1]-2) > 1e-9 {
+		t.Fatalf("top-down projection = %v", xy)
+	}
+	for _, v := range top.View() {
+		if math.IsNaN(v) {
+			t.Fatalf("top-down view has NaN: %v", top.View())
+		}
+	}
+	if _, err := ParseCamera(SceneCamera{Azimuth: "north"}); err == nil || !strings.Contains(err.Error(), `azimuth "north"`) {
+		t.Fatalf("non-numeric azimuth: %v", err)
+	}
+	if got := (Camera{Azimuth: 45.5, Distance: 3}).SceneCamera(); got != (SceneCamera{Azimuth: "45.5", Elevation: "0", Distance: "3"}) {
+		t.Fatalf("SceneCamera = %+v", got)
+	}
+}
+
+func TestProjectSceneForPlanarRenderers(t *testing.T) {
+	scene := Scene{
+		ID: "s",
+		Nodes: []SceneNode{
+			{ID: "a", Position: [3]float64{0, 0, 0}},
+			{ID: "b", Position: [3]float64{0, 0, -4}},
+		},
+		Edges:  []SceneEdge{{From: "a", To: "b", Path: [][3]float64{{0, 0, 0}, {0, 0, -4}}}},
+		Camera: SceneCamera{Azimuth: "90", Distance: "10"},
+	}
+	flat, err := ProjectScene(scene)
+	if err != nil {
+		t.Fatalf("project: %v", err)
+	}
+	// Looking from +X, depth along -Z becomes screen x: b sits 4 units right of a.
+	if a, b := flat.Nodes[0].Position, flat.Nodes[1].Position; !near3(a, [3]float64{-2, 0, 0}) || !near3(b, [3]float64{2, 0, 0}) {
+		t.Fatalf("projected positions a=%v b=%v", a, b)
+	}
+	if p := flat.Edges[0].Path; len(p) != 2 || !near3(p[1], [3]float64{2, 0, 0}) {
+		t.Fatalf("projected path = %v", p)
+	}
+	if scene.Nodes[1].Position[2] != -4 {
+		t.Fatalf("input scene was modified")
+	}
+	dot, err := GraphvizRenderer{Project: true}.Render(scene)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(string(dot), `pos="2.000,0.000!"`) {
+		t.Fatalf("dot should use projected positions:\n%s", dot)
+	}
+}
@@ -0,0 +1,191 @@
+package poml
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// bodyRef points at the innerxml body of el's payload, or returns nil for elements without one.
+func (d *Document) bodyRef(el Element) *string {
+	in := func(n int) bool { return el.Index >= 0 && el.Index < n }
+	switch el.Type {
+	case ElementRole:
+		return &d.Role.Body
+	case ElementOutputSchema:
+		return &d.Schema.Body
+	case ElementTask:
+		if in(len(d.Tasks)) {
+			return &d.Tasks[el.Index].Body
+		}
+	case ElementInput:
+		if in(len(d.Inputs)) {
+			return &d.Inputs[el.Index].Body
+		}
+	case ElementOutputFormat:
+		if in(len(d.OutFormats)) {
+			return &d.OutFormats[el.Index].Body
+		}
+	case ElementHint:
+		if in(len(d.Hints)) {
+			return &d.Hints[el.Index].Body
+		}
+	case ElementExample:
+		if in(len(d.Examples)) {
+			return &d.Examples[el.Index].Body
+		}
+	case ElementContentPart:
+		if in(len(d.ContentParts)) {
+			return &d.ContentParts[el.Index].Body
+		}
+	case ElementObject:
+		if in(len(d.Objects)) {
+			return &d.Objects[el.Index].Body
+		}
+	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+		if in(len(d.Messages)) {
+			return &d.Messages[el.Index].Body
+		}
+	case ElementToolDefinition:
+		if in(len(d.ToolDefs)) {
+			return &d.ToolDefs[el.Index].Body
+		}
+	case ElementToolResponse:
+		if in(len(d.ToolResps)) {
+			return &d.ToolResps[el.Index].Body
+		}
+	case ElementToolResult:
+		if in(len(d.ToolResults)) {
+			return &d.ToolResults[el.Index].Body
+		}
+	case ElementToolError:
+		if in(len(d.ToolErrors)) {
+			return &d.ToolErrors[el.Index].Body
+		}
+	}
+	return nil
+}
+
+// markCDATABodies sets Element.CDATA for elements whose body was written as CDATA.
+func (d *Document) markCDATABodies() {
+	for i, el := range d.Elements {
+		if body := d.bodyRef(el); body != nil && isCDATABody(*body) {
+			d.Elements[i].CDATA = true
+		}
+	}
+}
+
+// isCDATABody reports whether body, ignoring surrounding whitespace, is one or more CDATA
+// sections and nothing else.
+func isCDATABody(body string) bool {
+	rest := strings.TrimSpace(body)
+	if rest == "" {
+		return false
+	}
+	for rest != "" {
+		if !strings.HasPrefix(rest, "<![CDATA[") {
+			return false
+		}
+		end := strings.Index(rest, "]]>")
+		if end < 0 {
+			return false
+		}
+		rest = strings.TrimSpace(rest[end+len("]]>"):])
+	}
+	return true
+}
+
+// withCDATABody returns d and el prepared for encoding el's body as CDATA (callers check
+// Element.CDATA or EncodeOptions.ForceCDATA first): when the element came from CDATA, or its
+// text holds '<' or '&', and its body is plain escaped text, the payload is swapped for a copy whose body is a CDATA section. d is a copy
+// owned by the encoder, so only the swapped slice header changes and callers are unaffected.
+func (d Document) withCDATABody(el Element) (Document, Element) {
+	body := d.bodyRef(el)
+	if body == nil || isCDATABody(*body) {
+		return d, el
+	}
+	text, ok := charDataText(*body)
+	if !ok || strings.TrimSpace(text) == "" || (!el.CDATA && !strings.ContainsAny(text, "<&")) {
+		return d, el
+	}
+	wrapped := wrapCDATA(text)
+	switch el.Type {
+	case ElementRole:
+		d.Role.Body = wrapped
+		return d, el
+	case ElementOutputSchema:
+		d.Schema.Body = wrapped
+		return d, el
+	case ElementTask:
+		d.Tasks = isolatePayload(d.Tasks, el.Index)
+	case ElementInput:
+		d.Inputs = isolatePayload(d.Inputs, el.Index)
+	case ElementOutputFormat:
+		d.OutFormats = isolatePayload(d.OutFormats, el.Index)
+	case ElementHint:
+		d.Hints = isolatePayload(d.Hints, el.Index)
+	case ElementExample:
+		d.Examples = isolatePayload(d.Examples, el.Index)
+	case ElementContentPart:
+		d.ContentParts = isolatePayload(d.ContentParts, el.Index)
+	case ElementObject:
+		d.Objects = isolatePayload(d.Objects, el.Index)
+	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+		d.Messages = isolatePayload(d.Messages, el.Index)
+	case ElementToolDefinition:
+		d.ToolDefs = isolatePayload(d.ToolDefs, el.Index)
+	case ElementToolResponse:
+		d.ToolResps = isolatePayload(d.ToolResps, el.Index)
+	case ElementToolResult:
+		d.ToolResults = isolatePayload(d.ToolResults, el.Index)
+	case ElementToolError:
+		d.ToolErrors = isolatePayload(d.ToolErrors, el.Index)
+	}
+	el.Index = 0
+	*d.bodyRef(el) = wrapped
+	return d, el
+}
+
+// isolatePayload returns a one-item slice holding a copy of items[i].
+func isolatePayload[T any](items []T, i int) []T {
+	return []T{items[i]}
+}
+
+// charDataText decodes an innerxml body that holds only text (entities and CDATA allowed).
+// ok is false when the body contains elements, comments, or processing instructions.
+func charDataText(body string) (string, bool) {
+	dec := xml.NewDecoder(strings.NewReader("<body>" + body + "</body>"))
+	var text strings.Builder
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return text.String(), true
+		}
+		if err != nil {
+			return "", false
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth++; depth > 1 {
+				return "", false
+			}
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			text.Write(t)
+		default:
+			return "", false
+		}
+	}
+}
+
+// wrapCDATA writes text as a CDATA section, keeping surrounding whitespace outside it and
+// splitting the section wherever text itself contains "]]>".
+func wrapCDATA(text string) string {
+	core := strings.TrimSpace(text)
+	start := strings.Index(text, core)
+	lead, trail := text[:start], text[start+len(core):]
+	return lead + "<![CDATA[" + strings.ReplaceAll(core, "]]>", "]]]]><![CDATA[>") + "]]>" + trail
+}
@@ -0,0 +1,91 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const cdataSample = `<poml>
+  <task><![CDATA[Match a < b && c > d with /<(\w+)>/]]></task>
+  <hint>plain &amp; escaped</hint>
+</poml>`
+
+func encodeString(t *testing.T, doc Document, opts EncodeOptions) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := doc.EncodeWithOptions(&buf, opts); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return buf.String()
+}
+
+func TestCDATABodiesRoundTrip(t *testing.T) {
+	doc, err := ParseString(cdataSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !doc.Elements[0].CDATA || doc.Elements[1].CDATA {
+		t.Fatalf("unexpected CDATA flags: %+v", doc.Elements)
+	}
+
+	formatted, err := FormatDocument(doc, FormatStyle{})
+	if err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	if !strings.Contains(string(formatted), `<task><![CDATA[Match a < b && c > d with /<(\w+)>/]]></task>`) ||
+		!strings.Contains(string(formatted), "<hint>plain &amp; escaped</hint>") {
+		t.Fatalf("format lost CDATA or escaping:\n%s", formatted)
+	}
+
+	// Replacing the body with escaped text keeps the element's CDATA form on encode.
+	if err := doc.Mutate(func(el Element, _ ElementPayload, m *Mutator) error {
+		if el.Type == ElementTask {
+			m.ReplaceBody(el, "x &lt; y ]]&gt; z")
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("mutate: %v", err)
+	}
+	out := encodeString(t, doc, EncodeOptions{PreserveOrder: true})
+	if !strings.Contains(out, "<task><![CDATA[x < y ]]]]><![CDATA[> z]]></task>") {
+		t.Fatalf("expected replaced body re-emitted as CDATA:\n%s", out)
+	}
+	if doc.Tasks[0].Body != "x &lt; y ]]&gt; z" {
+		t.Fatalf("encode modified the document: %q", doc.Tasks[0].Body)
+	}
+	reparsed, err := ParseString(out)
+	if err != nil || plainText(reparsed.Tasks[0].Body) != "x < y ]]> z" {
+		t.Fatalf("reparse: %v %q", err, reparsed.Tasks[0].Body)
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var back Document
+	if err := json.Unmarshal(raw, &back); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !back.Elements[0].CDATA {
+		t.Fatalf("JSON round trip dropped the CDATA flag")
+	}
+}
+
+func TestEncodeForceCDATA(t *testing.T) {
+	doc, err := ParseString(`<poml><hint>a &lt; b</hint><hint>no markup</hint><example>has <b>child</b> markup &amp; text</example></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out := encodeString(t, doc, EncodeOptions{PreserveOrder: true, Compact: true, ForceCDATA: true})
+	for _, want := range []string{
+		"<hint><![CDATA[a < b]]></hint>",
+		"<hint>no markup</hint>",
+		"<example>has <b>child</b> markup &amp; text</example>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %s in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package poml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// prologEncodingRe matches the encoding declaration of an XML prolog.
+var prologEncodingRe = regexp.MustCompile(`\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// prologPeek bounds how far utf8Input looks for the end of the XML prolog.
+const prologPeek = 1024
+
+// utf8Input returns r as UTF-8 for the XML decoder: a UTF-8 byte order mark is dropped, UTF-16
+// input (recognized by its BOM, or by the "<?" its prolog starts with) is transcoded, and so is
+// input whose prolog declares ISO-8859-1 or windows-1252. Anything else passes through. Decode
+// offsets and ParseOptions.MaxDocumentBytes then count the transcoded bytes.
+func utf8Input(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(head, utf8BOM):
+		_, _ = br.Discard(len(utf8BOM))
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		_, _ = br.Discard(2)
+		return &utf16Reader{r: br, order: binary.LittleEndian}
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		_, _ = br.Discard(2)
+		return &utf16Reader{r: br, order: binary.BigEndian}
+	case bytes.HasPrefix(head, []byte{'<', 0, '?', 0}):
+		return &utf16Reader{r: br, order: binary.LittleEndian}
+	case bytes.HasPrefix(head, []byte{0, '<', 0, '?'}):
+		return &utf16Reader{r: br, order: binary.BigEndian}
+	}
+	if head, _ := br.Peek(prologPeek); bytes.HasPrefix(head, []byte("<?xml")) {
+		if end := bytes.Index(head, []byte("?>")); end > 0 {
+			if m := prologEncodingRe.FindSubmatch(head[:end]); m != nil && charsetKind(string(m[1])) == "windows-1252" {
+				return &windows1252Reader{r: br}
+			}
+		}
+	}
+	return br
+}
+
+// charsetKind reports which decoding utf8Input applies for an encoding label: "utf-8",
+// "utf-16", "windows-1252" (which, as in browsers, also covers ISO-8859-1 and ASCII), or ""
+// when the label is not supported.
+func charsetKind(label string) string {
+	switch strings.ToLower(label) {
+	case "utf-8", "utf8":
+		return "utf-8"
+	case "utf-16", "utf-16le", "utf-16be", "utf16", "ucs-2":
+		return "utf-16"
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "latin-1", "l1", "windows-1252", "cp1252", "us-ascii", "ascii":
+		return "windows-1252"
+	}
+	return ""
+}
+
+// charsetReader is the xml.Decoder CharsetReader for input utf8Input has already transcoded:
+// it accepts the encodings utf8Input handles and passes the UTF-8 stream through.
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	if charsetKind(label) == "" {
+		return nil, fmt.Errorf("unsupported encoding %q (want UTF-8, UTF-16, ISO-8859-1, or windows-1252)", label)
+	}
+	return input, nil
+}
+
+// utf16Reader transcodes UTF-16 code units to UTF-8. Unpaired surrogates and a trailing odd
+// byte become U+FFFD.
+type utf16Reader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	buf   []byte
+	err   error
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.buf) == 0 {
+		if u.err != nil {
+			return 0, u.err
+		}
+		u.fill()
+	}
+	n := copy(p, u.buf)
+	u.buf = u.buf[n:]
+	return n, nil
+}
+
+// fill decodes up to a few hundred code units into buf, or records the read error.
+func (u *utf16Reader) fill() {
+	for i := 0; i < 256; i++ {
+		c, err := u.unit()
+		if err != nil {
+			u.err = err
+			return
+		}
+		if c >= 0xD800 && c < 0xDC00 {
+			low, err := u.unit()
+			if err != nil {
+				u.buf = utf8.AppendRune(u.buf, utf8.RuneError)
+				u.err = err
+				return
+			}
+			if r := utf16.DecodeRune(c, low); r != utf8.RuneError {
+				u.buf = utf8.AppendRune(u.buf, r)
+				continue
+			}
+			u.buf = utf8.AppendRune(u.buf, utf8.RuneError)
+			c = low
+		}
+		if utf16.IsSurrogate(c) {
+			c = utf8.RuneError
+		}
+		u.buf = utf8.AppendRune(u.buf, c)
+		if u.r.Buffered() < 2 {
+			return
+		}
+	}
+}
+
+// unit reads one code unit. A lone trailing byte reads as U+FFFD followed by io.EOF.
+func (u *utf16Reader) unit() (rune, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(u.r, b[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			u.buf = utf8.AppendRune(u.buf, utf8.RuneError)
+			err = io.EOF
+		}
+		return 0, err
+	}
+	return rune(u.order.Uint16(b[:])), nil
+}
+
+// windows1252High maps bytes 0x80-0x9F; the rest of windows-1252 matches ISO-8859-1 and the
+// first 256 code points. The five unassigned bytes map to the C1 controls, as browsers do.
+var windows1252High = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡',
+	'ˆ', '‰', 'Š', '‹', 'Œ', '\u008D', 'Ž', '\u008F',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—',
+	'˜', '™', 'š', '›', 'œ', '\u009D', 'ž', 'Ÿ',
+}
+
+// windows1252Reader transcodes windows-1252 (and so ISO-8859-1) bytes to UTF-8.
+type windows1252Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func (w *windows1252Reader) Read(p []byte) (int, error) {
+	if len(w.buf) == 0 {
+		var chunk [512]byte
+		n, err := w.r.Read(chunk[:])
+		for _, b := range chunk[:n] {
+			r := rune(b)
+			if b >= 0x80 && b < 0xA0 {
+				r = windows1252High[b-0x80]
+			}
+			w.buf = utf8.AppendRune(w.buf, r)
+		}
+		if n == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, w.buf)
+	w.buf = w.buf[n:]
+	return n, nil
+}
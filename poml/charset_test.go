@@ -0,0 +1,64 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func encodeUTF16(s string, order binary.ByteOrder, bom bool) []byte {
+	var b bytes.Buffer
+	if bom {
+		_ = binary.Write(&b, order, uint16(0xFEFF))
+	}
+	_ = binary.Write(&b, order, utf16.Encode([]rune(s)))
+	return b.Bytes()
+}
+
+func TestParseCharsets(t *testing.T) {
+	const body = `<poml><role>Café “quoted” 🙂</role></poml>`
+	utf16Doc := `<?xml version="1.0" encoding="UTF-16"?>` + "\r\n" + body
+	const want = "Café “quoted” 🙂"
+	cases := map[string][]byte{
+		"utf-8 bom":       append([]byte{0xEF, 0xBB, 0xBF}, body...),
+		"utf-16le bom":    encodeUTF16(utf16Doc, binary.LittleEndian, true),
+		"utf-16be no bom": encodeUTF16(utf16Doc, binary.BigEndian, false),
+	}
+	for name, src := range cases {
+		doc, err := ParseReader(bytes.NewReader(src))
+		if err != nil {
+			t.Fatalf("%s: parse: %v", name, err)
+		}
+		if doc.Role.Body != want {
+			t.Fatalf("%s: role body %q, want %q", name, doc.Role.Body, want)
+		}
+	}
+	// Single-byte prologs; recovery buffers the transcoded input and slices it by offset.
+	latin1 := []byte("<?xml version='1.0' encoding='ISO-8859-1'?>\n<poml><role>Caf\xe9</role><x a='\xe9'/></poml>")
+	doc, err := ParseReaderWithOptions(bytes.NewReader(latin1), ParseOptions{Recover: true})
+	if err != nil || doc.Role.Body != "Café" || len(doc.Elements) != 2 || doc.Elements[1].RawXML != "<x a='é'/>" {
+		t.Fatalf("latin-1: %q %+v %v", doc.Role.Body, doc.Elements, err)
+	}
+	cp1252 := []byte("<?xml version=\"1.0\" encoding=\"windows-1252\"?><poml><role>Caf\xe9 \x93quoted\x94</role></poml>")
+	if doc, err := ParseReader(bytes.NewReader(cp1252)); err != nil || doc.Role.Body != "Café “quoted”" {
+		t.Fatalf("windows-1252: %q %v", doc.Role.Body, err)
+	}
+
+	stream := NewStreamDecoder(bytes.NewReader(encodeUTF16(utf16Doc, binary.LittleEndian, true)))
+	if _, payload, err := stream.Next(); err != nil || payload.Role == nil || payload.Role.Body != want {
+		t.Fatalf("stream: %+v %v", payload, err)
+	}
+	if _, _, err := stream.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("stream end: %v", err)
+	}
+
+	_, err = ParseString(`<?xml version="1.0" encoding="KOI8-R"?><poml/>`)
+	var pe *POMLError
+	if !errors.As(err, &pe) || pe.Code != CodeDecode || !strings.Contains(err.Error(), `unsupported encoding "KOI8-R"`) {
+		t.Fatalf("expected unsupported encoding error, got %v", err)
+	}
+}
@@ -0,0 +1,924 @@
+package poml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrChatTemplateException wraps the message a chat template passes to raise_exception.
+var ErrChatTemplateException = errors.New("chat template raised an exception")
+
+const (
+	// tmplMaxDepth bounds block and expression nesting, so a malformed template cannot exhaust
+	// the stack.
+	tmplMaxDepth = 100
+	// tmplMaxRange bounds range() and string repetition, as MAX_RANGE does in Jinja's sandbox.
+	tmplMaxRange = 100000
+)
+
+// ChatTemplateOptions configures RenderChatTemplate.
+type ChatTemplateOptions struct {
+	// Convert is passed to the FormatHFChat conversion (asset resolution, size caps, ...).
+	Convert ConvertOptions
+	// AddGenerationPrompt sets add_generation_prompt, asking the template to open an assistant turn.
+	AddGenerationPrompt bool
+	// BOSToken and EOSToken set bos_token and eos_token.
+	BOSToken string
+	EOSToken string
+	// Vars adds or overrides template variables (e.g. "tools", "date_string", "enable_thinking").
+	Vars map[string]any
+}
+
+// RenderChatTemplate converts doc with FormatHFChat and renders the messages through a Hugging
+// Face chat template (the "chat_template" string from a model's tokenizer_config.json), producing
+// the raw prompt text-generation-inference /generate or a local transformers pipeline expects.
+// Templates see messages, tools (the document's tool definitions, when present),
+// add_generation_prompt, bos_token, eos_token, and opts.Vars.
+func RenderChatTemplate(doc Document, template string, opts ChatTemplateOptions) (string, error) {
+	tmpl, err := ParseChatTemplate(template)
+	if err != nil {
+		return "", err
+	}
+	messages, err := Convert(doc, FormatHFChat, opts.Convert)
+	if err != nil {
+		return "", err
+	}
+	vars := map[string]any{
+		"messages":              messages,
+		"add_generation_prompt": opts.AddGenerationPrompt,
+		"bos_token":             opts.BOSToken,
+		"eos_token":             opts.EOSToken,
+	}
+	if len(doc.ToolDefs) > 0 {
+		tools := make([]any, 0, len(doc.ToolDefs))
+		for _, td := range doc.ToolDefs {
+			tools = append(tools, buildOpenAIToolDefinition(td, opts.Convert))
+		}
+		vars["tools"] = tools
+	}
+	for k, v := range opts.Vars {
+		vars[k] = v
+	}
+	return tmpl.Execute(vars)
+}
+
+// ChatTemplate is a compiled chat template. It implements the Jinja subset chat templates use,
+// with the environment transformers configures (trim_blocks and lstrip_blocks on):
+//
+//   - tags: {{ expr }}, {% if/elif/else/endif %}, {% for x[, y] in expr [if cond] %} with
+//     {% else %} and the loop variable, {% set name = expr %} and {% set ns.attr = expr %},
+//     {% generation %} blocks, comments, and the "-"/"+" whitespace modifiers (macro, call,
+//     include, extends, block, raw, filter, and recursive loops are rejected);
+//   - expressions: literals, lists, dicts, attribute and item access, slices, calls, arithmetic,
+//     ~, comparisons, in, and/or/not, and "a if cond else b";
+//   - filters: trim, length, count, upper, lower, title, capitalize, default, tojson, string,
+//     int, float, first, last, join, list, reverse, replace, items, map, selectattr,
+//     rejectattr, safe, and indent;
+//   - tests: defined, undefined, none, string, number, integer, float, boolean, mapping,
+//     iterable, sequence, true, false, odd, even, equalto, eq, ne, and sameas;
+//   - globals: raise_exception, namespace, range, and strftime_now, plus the common string and
+//     dict methods (strip, split, startswith, items, get, ...).
+//
+// Missing keys and variables are undefined, as in Jinja: they render as "" and are falsy. Blocks
+// and expressions nest at most 100 deep, and range() and string repetition stop at 100000
+// items. Errors name the line of the offending tag.
+type ChatTemplate struct {
+	nodes []tmplNode
+}
+
+// ParseChatTemplate compiles a chat template.
+func ParseChatTemplate(src string) (*ChatTemplate, error) {
+	toks, err := lexChatTemplate(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &tmplParser{toks: toks}
+	nodes, end, err := p.parseBody()
+	if err != nil {
+		return nil, err
+	}
+	if end != nil {
+		return nil, fmt.Errorf("chat template: line %d: unexpected {%% %s %%}", end.line, end.body)
+	}
+	return &ChatTemplate{nodes: nodes}, nil
+}
+
+// Execute renders the template with vars. Slices and maps of strings, maps, or numbers are
+// accepted alongside []any and map[string]any.
+func (t *ChatTemplate) Execute(vars map[string]any) (string, error) {
+	st := newTmplState(vars)
+	var out strings.Builder
+	if err := execNodes(t.nodes, st, &out); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// ---- lexing ----
+
+type tmplTokenKind int
+
+const (
+	tmplTokText tmplTokenKind = iota
+	tmplTokOutput
+	tmplTokBlock
+	tmplTokComment
+)
+
+type tmplToken struct {
+	kind      tmplTokenKind
+	body      string
+	line      int
+	trimLeft  bool // "{%-": strip whitespace before the tag
+	trimRight bool // "-%}": strip whitespace after the tag
+	keepLeft  bool // "{%+": disable lstrip_blocks
+	keepRight bool // "+%}": disable trim_blocks
+}
+
+func lexChatTemplate(src string) ([]tmplToken, error) {
+	var toks []tmplToken
+	line := 1
+	pos := 0
+	for pos < len(src) {
+		i := indexTagStart(src[pos:])
+		if i < 0 {
+			toks = append(toks, tmplToken{kind: tmplTokText, body: src[pos:], line: line})
+			break
+		}
+		if i > 0 {
+			toks = append(toks, tmplToken{kind: tmplTokText, body: src[pos : pos+i], line: line})
+			line += strings.Count(src[pos:pos+i], "\n")
+			pos += i
+		}
+		tok := tmplToken{line: line}
+		var closer string
+		switch src[pos+1] {
+		case '{':
+			tok.kind, closer = tmplTokOutput, "}}"
+		case '%':
+			tok.kind, closer = tmplTokBlock, "%}"
+		default:
+			tok.kind, closer = tmplTokComment, "#}"
+		}
+		start := pos + 2
+		if start < len(src) && src[start] == '-' {
+			tok.trimLeft = true
+			start++
+		} else if start < len(src) && src[start] == '+' {
+			tok.keepLeft = true
+			start++
+		}
+		end := indexTagEnd(src[start:], closer, tok.kind != tmplTokComment)
+		if end < 0 {
+			return nil, fmt.Errorf("chat template: line %d: unclosed %s", line, src[pos:pos+2])
+		}
+		body := src[start : start+end]
+		if strings.HasSuffix(body, "-") {
+			tok.trimRight = true
+			body = body[:len(body)-1]
+		} else if strings.HasSuffix(body, "+") && tok.kind == tmplTokBlock {
+			tok.keepRight = true
+			body = body[:len(body)-1]
+		}
+		tok.body = strings.TrimSpace(body)
+		next := start + end + len(closer)
+		line += strings.Count(src[pos:next], "\n")
+		pos = next
+		toks = append(toks, tok)
+	}
+	applyTmplWhitespace(toks)
+	return toks, nil
+}
+
+func indexTagStart(s string) int {
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] == '{' && (s[i+1] == '{' || s[i+1] == '%' || s[i+1] == '#') {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexTagEnd finds closer, skipping quoted strings inside expression tags.
+func indexTagEnd(s, closer string, quoted bool) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case quoted && (c == '\'' || c == '"'):
+			quote = c
+		case strings.HasPrefix(s[i:], closer):
+			return i
+		}
+	}
+	return -1
+}
+
+// applyTmplWhitespace applies "-" modifiers plus trim_blocks (drop the newline after a block
+// tag) and lstrip_blocks (drop indentation before a block tag that starts its line).
+func applyTmplWhitespace(toks []tmplToken) {
+	const space = " \t\r\n"
+	for i := range toks {
+		if toks[i].kind != tmplTokText {
+			continue
+		}
+		s := toks[i].body
+		start, end := 0, len(s)
+		if i+1 < len(toks) {
+			next := toks[i+1]
+			switch {
+			case next.trimLeft:
+				end = len(strings.TrimRight(s, space))
+			case next.kind != tmplTokOutput && !next.keepLeft:
+				nl := strings.LastIndexByte(s, '\n')
+				if strings.Trim(s[nl+1:], " \t") == "" && (nl >= 0 || i == 0) {
+					end = nl + 1
+				}
+			}
+		}
+		if i > 0 {
+			prev := toks[i-1]
+			switch {
+			case prev.trimRight:
+				start = len(s) - len(strings.TrimLeft(s, space))
+			case prev.kind != tmplTokOutput && !prev.keepRight:
+				if strings.HasPrefix(s, "\r\n") {
+					start = 2
+				} else if strings.HasPrefix(s, "\n") {
+					start = 1
+				}
+			}
+		}
+		if start >= end {
+			toks[i].body = ""
+		} else {
+			toks[i].body = s[start:end]
+		}
+	}
+}
+
+// ---- statements ----
+
+type tmplParser struct {
+	toks  []tmplToken
+	pos   int
+	depth int
+}
+
+// parseBody reads nodes until a block tag it does not handle (an end/else tag), which it
+// returns unconsumed-by-the-caller; end is nil at the end of input.
+func (p *tmplParser) parseBody() (nodes []tmplNode, end *tmplToken, err error) {
+	if p.depth++; p.depth > tmplMaxDepth {
+		return nil, nil, fmt.Errorf("chat template: line %d: blocks nest more than %d deep", p.toks[p.pos-1].line, tmplMaxDepth)
+	}
+	defer func() { p.depth-- }()
+	for p.pos < len(p.toks) {
+		tok := p.toks[p.pos]
+		p.pos++
+		switch tok.kind {
+		case tmplTokText:
+			if tok.body != "" {
+				nodes = append(nodes, tmplText(tok.body))
+			}
+		case tmplTokComment:
+		case tmplTokOutput:
+			x, err := parseTmplExpr(tok.body, tok.line)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, &tmplOutput{x: x, line: tok.line})
+		case tmplTokBlock:
+			keyword, rest := splitTmplKeyword(tok.body)
+			var node tmplNode
+			switch keyword {
+			case "if":
+				node, err = p.parseIf(rest, tok.line)
+			case "for":
+				node, err = p.parseFor(rest, tok.line)
+			case "set":
+				node, err = parseTmplSet(rest, tok.line)
+			case "generation":
+				node, err = p.parseGeneration(tok.line)
+			case "elif", "else", "endif", "endfor", "endgeneration":
+				return nodes, &tok, nil
+			default:
+				return nil, nil, fmt.Errorf("chat template: line %d: unsupported tag {%% %s %%}", tok.line, keyword)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil, nil
+}
+
+func splitTmplKeyword(body string) (string, string) {
+	keyword, rest, _ := strings.Cut(body, " ")
+	return keyword, strings.TrimSpace(rest)
+}
+
+func (p *tmplParser) expectEnd(end *tmplToken, line int, want ...string) (string, error) {
+	if end == nil {
+		return "", fmt.Errorf("chat template: line %d: missing {%% %s %%}", line, want[len(want)-1])
+	}
+	keyword, _ := splitTmplKeyword(end.body)
+	for _, w := range want {
+		if keyword == w {
+			return keyword, nil
+		}
+	}
+	return "", fmt.Errorf("chat template: line %d: unexpected {%% %s %%}", end.line, end.body)
+}
+
+func (p *tmplParser) parseIf(cond string, line int) (tmplNode, error) {
+	node := &tmplIf{}
+	for {
+		x, err := parseTmplExpr(cond, line)
+		if err != nil {
+			return nil, err
+		}
+		body, end, err := p.parseBody()
+		if err != nil {
+			return nil, err
+		}
+		node.conds = append(node.conds, x)
+		node.lines = append(node.lines, line)
+		node.bodies = append(node.bodies, body)
+		keyword, err := p.expectEnd(end, line, "elif", "else", "endif")
+		if err != nil {
+			return nil, err
+		}
+		switch keyword {
+		case "elif":
+			_, cond = splitTmplKeyword(end.body)
+			line = end.line
+			continue
+		case "else":
+			body, end, err := p.parseBody()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expectEnd(end, line, "endif"); err != nil {
+				return nil, err
+			}
+			node.els = body
+		}
+		return node, nil
+	}
+}
+
+func (p *tmplParser) parseFor(header string, line int) (tmplNode, error) {
+	ep, err := newTmplExprParser(header, line)
+	if err != nil {
+		return nil, err
+	}
+	node := &tmplFor{line: line}
+	if node.targets, node.iter, node.filter, err = ep.parseLoopHeader(); err != nil {
+		return nil, err
+	}
+	body, end, err := p.parseBody()
+	if err != nil {
+		return nil, err
+	}
+	keyword, err := p.expectEnd(end, line, "else", "endfor")
+	if err != nil {
+		return nil, err
+	}
+	node.body = body
+	if keyword == "else" {
+		body, end, err := p.parseBody()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expectEnd(end, line, "endfor"); err != nil {
+			return nil, err
+		}
+		node.els = body
+	}
+	return node, nil
+}
+
+func (p *tmplParser) parseGeneration(line int) (tmplNode, error) {
+	body, end, err := p.parseBody()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expectEnd(end, line, "endgeneration"); err != nil {
+		return nil, err
+	}
+	return tmplBlock(body), nil
+}
+
+func parseTmplSet(stmt string, line int) (tmplNode, error) {
+	target, value, ok := strings.Cut(stmt, "=")
+	if !ok {
+		return nil, fmt.Errorf("chat template: line %d: block {%% set %%} is not supported", line)
+	}
+	node := &tmplSet{line: line}
+	node.name, node.attr, _ = strings.Cut(strings.TrimSpace(target), ".")
+	if !isTmplIdent(node.name) || (node.attr != "" && !isTmplIdent(node.attr)) {
+		return nil, fmt.Errorf("chat template: line %d: invalid set target %q", line, strings.TrimSpace(target))
+	}
+	x, err := parseTmplExpr(value, line)
+	if err != nil {
+		return nil, err
+	}
+	node.x = x
+	return node, nil
+}
+
+func isTmplIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+type tmplNode interface {
+	exec(st *tmplState, out *strings.Builder) error
+}
+
+type tmplText string
+
+func (t tmplText) exec(_ *tmplState, out *strings.Builder) error {
+	out.WriteString(string(t))
+	return nil
+}
+
+type tmplBlock []tmplNode
+
+func (b tmplBlock) exec(st *tmplState, out *strings.Builder) error {
+	return execNodes(b, st, out)
+}
+
+type tmplOutput struct {
+	x    tmplExpr
+	line int
+}
+
+func (o *tmplOutput) exec(st *tmplState, out *strings.Builder) error {
+	v, err := o.x.eval(st)
+	if err != nil {
+		return tmplLineError(o.line, err)
+	}
+	out.WriteString(tmplString(v))
+	return nil
+}
+
+type tmplIf struct {
+	conds  []tmplExpr
+	lines  []int
+	bodies [][]tmplNode
+	els    []tmplNode
+}
+
+func (n *tmplIf) exec(st *tmplState, out *strings.Builder) error {
+	for i, cond := range n.conds {
+		v, err := cond.eval(st)
+		if err != nil {
+			return tmplLineError(n.lines[i], err)
+		}
+		if tmplTruth(v) {
+			return execNodes(n.bodies[i], st, out)
+		}
+	}
+	return execNodes(n.els, st, out)
+}
+
+// parseLoopHeader parses "x[, y] in expr [if cond]", the header of a for loop.
+func (ep *tmplExprParser) parseLoopHeader() (targets []string, iter, filter tmplExpr, err error) {
+	for {
+		tok := ep.next()
+		if tok.kind != 'n' {
+			return nil, nil, nil, ep.errorf("expected loop variable")
+		}
+		targets = append(targets, tok.val)
+		if !ep.accept('o', ",") {
+			break
+		}
+	}
+	if !ep.accept('n', "in") {
+		return nil, nil, nil, ep.errorf("expected 'in'")
+	}
+	if iter, err = ep.parseOr(); err != nil {
+		return nil, nil, nil, err
+	}
+	if ep.accept('n', "if") {
+		if filter, err = ep.parseOr(); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if ep.peek() == (tmplExprTok{'n', "recursive"}) {
+		return nil, nil, nil, ep.errorf("recursive loops are not supported")
+	}
+	if err := ep.done(); err != nil {
+		return nil, nil, nil, err
+	}
+	return targets, iter, filter, nil
+}
+
+type tmplFor struct {
+	targets []string
+	iter    tmplExpr
+	filter  tmplExpr
+	body    []tmplNode
+	els     []tmplNode
+	line    int
+}
+
+func (n *tmplFor) exec(st *tmplState, out *strings.Builder) error {
+	v, err := n.iter.eval(st)
+	if err != nil {
+		return tmplLineError(n.line, err)
+	}
+	items, err := tmplIterate(v)
+	if err != nil {
+		return tmplLineError(n.line, err)
+	}
+	st.push()
+	defer st.pop()
+	if n.filter != nil {
+		var kept []any
+		for _, item := range items {
+			if err := n.bind(st, item); err != nil {
+				return err
+			}
+			ok, err := n.filter.eval(st)
+			if err != nil {
+				return tmplLineError(n.line, err)
+			}
+			if tmplTruth(ok) {
+				kept = append(kept, item)
+			}
+		}
+		items = kept
+	}
+	if len(items) == 0 {
+		return execNodes(n.els, st, out)
+	}
+	for i, item := range items {
+		if err := n.bind(st, item); err != nil {
+			return err
+		}
+		loop := map[string]any{
+			"index": i + 1, "index0": i, "revindex": len(items) - i, "revindex0": len(items) - i - 1,
+			"first": i == 0, "last": i == len(items)-1, "length": len(items),
+			"previtem": tmplUndefined{name: "loop.previtem"}, "nextitem": tmplUndefined{name: "loop.nextitem"},
+		}
+		if i > 0 {
+			loop["previtem"] = items[i-1]
+		}
+		if i+1 < len(items) {
+			loop["nextitem"] = items[i+1]
+		}
+		st.top()["loop"] = loop
+		if err := execNodes(n.body, st, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *tmplFor) bind(st *tmplState, item any) error {
+	if err := st.bind(n.targets, item); err != nil {
+		return tmplLineError(n.line, err)
+	}
+	return nil
+}
+
+type tmplSet struct {
+	name, attr string
+	x          tmplExpr
+	line       int
+}
+
+func (n *tmplSet) exec(st *tmplState, _ *strings.Builder) error {
+	v, err := n.x.eval(st)
+	if err != nil {
+		return tmplLineError(n.line, err)
+	}
+	if n.attr == "" {
+		st.top()[n.name] = v
+		return nil
+	}
+	ns, ok := st.lookup(n.name).(*tmplNamespace)
+	if !ok {
+		return fmt.Errorf("chat template: line %d: cannot assign attribute on %s (only namespace objects)", n.line, n.name)
+	}
+	ns.vals[n.attr] = v
+	return nil
+}
+
+func execNodes(nodes []tmplNode, st *tmplState, out *strings.Builder) error {
+	for _, n := range nodes {
+		if err := n.exec(st, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tmplLineError(line int, err error) error {
+	if errors.Is(err, ErrChatTemplateException) || strings.HasPrefix(err.Error(), "chat template:") {
+		return err
+	}
+	return fmt.Errorf("chat template: line %d: %w", line, err)
+}
+
+// tmplState holds the variable scopes; for loops push a scope so their assignments stay local.
+type tmplState struct {
+	scopes []map[string]any
+}
+
+// newTmplState returns a state whose outer scope holds the globals and vars.
+func newTmplState(vars map[string]any) *tmplState {
+	globals := map[string]any{
+		"raise_exception": tmplFunc(tmplRaise),
+		"namespace":       tmplFunc(tmplNamespaceFunc),
+		"range":           tmplFunc(tmplRange),
+		"strftime_now":    tmplFunc(tmplStrftimeNow),
+	}
+	for k, v := range vars {
+		globals[k] = tmplNormalize(v)
+	}
+	return &tmplState{scopes: []map[string]any{globals}}
+}
+
+func (st *tmplState) push()               { st.scopes = append(st.scopes, map[string]any{}) }
+func (st *tmplState) pop()                { st.scopes = st.scopes[:len(st.scopes)-1] }
+func (st *tmplState) top() map[string]any { return st.scopes[len(st.scopes)-1] }
+
+// bind assigns item to the loop variables targets in the innermost scope, unpacking a list when
+// there are several.
+func (st *tmplState) bind(targets []string, item any) error {
+	if len(targets) == 1 {
+		st.top()[targets[0]] = item
+		return nil
+	}
+	parts, ok := item.([]any)
+	if !ok || len(parts) != len(targets) {
+		return fmt.Errorf("cannot unpack %s into %d variables", tmplTypeName(item), len(targets))
+	}
+	for i, name := range targets {
+		st.top()[name] = parts[i]
+	}
+	return nil
+}
+
+func (st *tmplState) lookup(name string) any {
+	for i := len(st.scopes) - 1; i >= 0; i-- {
+		if v, ok := st.scopes[i][name]; ok {
+			return v
+		}
+	}
+	return tmplUndefined{name: name}
+}
+
+// ---- runtime values ----
+
+// tmplUndefined is a missing variable, key, or attribute.
+type tmplUndefined struct{ name string }
+
+// tmplNamespace is the mutable object namespace() returns.
+type tmplNamespace struct{ vals map[string]any }
+
+// tmplFunc is a callable value: globals, bound methods, and filters share this shape.
+type tmplFunc func(args []any, kwargs map[string]any) (any, error)
+
+// tmplNormalize deep-copies caller values into the types the evaluator understands.
+func tmplNormalize(v any) any {
+	switch x := v.(type) {
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = tmplNormalize(e)
+		}
+		return out
+	case []map[string]any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = tmplNormalize(e)
+		}
+		return out
+	case []string:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = e
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, e := range x {
+			out[k] = tmplNormalize(e)
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]any, len(x))
+		for k, e := range x {
+			out[k] = e
+		}
+		return out
+	case int64:
+		return int(x)
+	case int32:
+		return int(x)
+	case float32:
+		return float64(x)
+	}
+	return v
+}
+
+func tmplTruth(v any) bool {
+	switch x := v.(type) {
+	case nil, tmplUndefined:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case int:
+		return x != 0
+	case float64:
+		return x != 0
+	case []any:
+		return len(x) > 0
+	case map[string]any:
+		return len(x) > 0
+	}
+	return true
+}
+
+func tmplTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "none"
+	case tmplUndefined:
+		return "undefined"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case int:
+		return "int"
+	case float64:
+		return "float"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "dict"
+	case *tmplNamespace:
+		return "namespace"
+	case tmplFunc:
+		return "function"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func tmplIterate(v any) ([]any, error) {
+	switch x := v.(type) {
+	case tmplUndefined:
+		return nil, nil
+	case []any:
+		return x, nil
+	case map[string]any:
+		keys := sortedKeys(x)
+		out := make([]any, len(keys))
+		for i, k := range keys {
+			out[i] = k
+		}
+		return out, nil
+	case string:
+		out := make([]any, 0, len(x))
+		for _, r := range x {
+			out = append(out, string(r))
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("%s is not iterable", tmplTypeName(v))
+}
+
+func tmplRaise(args []any, _ map[string]any) (any, error) {
+	msg := ""
+	if len(args) > 0 {
+		msg = tmplString(args[0])
+	}
+	return nil, fmt.Errorf("%w: %s", ErrChatTemplateException, msg)
+}
+
+func tmplNamespaceFunc(args []any, kwargs map[string]any) (any, error) {
+	ns := &tmplNamespace{vals: map[string]any{}}
+	for _, a := range args {
+		if m, ok := a.(map[string]any); ok {
+			for k, v := range m {
+				ns.vals[k] = v
+			}
+		}
+	}
+	for k, v := range kwargs {
+		ns.vals[k] = v
+	}
+	return ns, nil
+}
+
+func tmplRange(args []any, _ map[string]any) (any, error) {
+	var bounds []int
+	for _, a := range args {
+		n, ok := a.(int)
+		if !ok {
+			return nil, fmt.Errorf("range: %s is not an integer", tmplTypeName(a))
+		}
+		bounds = append(bounds, n)
+	}
+	start, stop, step := 0, 0, 1
+	switch len(bounds) {
+	case 1:
+		stop = bounds[0]
+	case 2:
+		start, stop = bounds[0], bounds[1]
+	case 3:
+		start, stop, step = bounds[0], bounds[1], bounds[2]
+	default:
+		return nil, fmt.Errorf("range expects 1 to 3 arguments, got %d", len(bounds))
+	}
+	if step == 0 {
+		return nil, errors.New("range step must not be zero")
+	}
+	var out []any
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		if len(out) == tmplMaxRange {
+			return nil, fmt.Errorf("range has more than %d items", tmplMaxRange)
+		}
+		out = append(out, i)
+	}
+	return out, nil
+}
+
+// tmplStrftimeNow formats the current local time with the common strftime directives.
+func tmplStrftimeNow(args []any, _ map[string]any) (any, error) {
+	if len(args) != 1 {
+		return nil, errors.New("strftime_now expects a format string")
+	}
+	format, _ := args[0].(string)
+	return strftime(time.Now(), format), nil
+}
+
+func strftime(t time.Time, format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'I':
+			fmt.Fprintf(&b, "%02d", (t.Hour()+11)%12+1)
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'b':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,1788 @@
+package poml
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ---- expression lexing and parsing ----
+
+// tmplExprTok is an expression token: 'n' name, 's' string, 'd' number, 'o' operator, 0 end.
+type tmplExprTok struct {
+	kind byte
+	val  string
+}
+
+var tmplOps = []string{"//", "===", "!==", "==", "!=", "<=", ">=", "||", "&&", "!", "+", "-", "*", "/", "%", "~", "|", ".", ",", ":", "(", ")", "[", "]", "{", "}", "<", ">", "="}
+
+// tmplJSOps maps the JavaScript spellings upstream POML expressions use onto their Jinja tokens.
+var tmplJSOps = map[string]tmplExprTok{
+	"===": {'o', "=="},
+	"!==": {'o', "!="},
+	"||":  {'n', "or"},
+	"&&":  {'n', "and"},
+	"!":   {'n', "not"},
+}
+
+func lexTmplExpr(src string) ([]tmplExprTok, error) {
+	var toks []tmplExprTok
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			toks = append(toks, tmplExprTok{'n', src[i:j]})
+			i = j
+		case c >= '0' && c <= '9':
+			j := i + 1
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '_') {
+				j++
+			}
+			if j+1 < len(src) && src[j] == '.' && src[j+1] >= '0' && src[j+1] <= '9' {
+				j++
+				for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+					j++
+				}
+			}
+			toks = append(toks, tmplExprTok{'d', strings.ReplaceAll(src[i:j], "_", "")})
+			i = j
+		case c == '\'' || c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] != '\\' || j+1 == len(src) {
+					b.WriteByte(src[j])
+					continue
+				}
+				j++
+				switch src[j] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case 'r':
+					b.WriteByte('\r')
+				case '\\', '\'', '"':
+					b.WriteByte(src[j])
+				default:
+					b.WriteByte('\\')
+					b.WriteByte(src[j])
+				}
+			}
+			if j >= len(src) {
+				return nil, errors.New("unterminated string")
+			}
+			toks = append(toks, tmplExprTok{'s', b.String()})
+			i = j + 1
+		default:
+			op := ""
+			for _, candidate := range tmplOps {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tok, ok := tmplJSOps[op]
+			if !ok {
+				tok = tmplExprTok{'o', op}
+			}
+			toks = append(toks, tok)
+			i += len(op)
+		}
+	}
+	return append(toks, tmplExprTok{}), nil
+}
+
+type tmplExprParser struct {
+	toks  []tmplExprTok
+	pos   int
+	depth int
+	src   string
+	where string // error prefix, such as "chat template: line 3"
+}
+
+func newTmplExprParser(src string, line int) (*tmplExprParser, error) {
+	return newExprParserAt(src, fmt.Sprintf("chat template: line %d", line))
+}
+
+// newExprParserAt is newTmplExprParser for expressions outside chat templates; where prefixes
+// syntax errors.
+func newExprParserAt(src, where string) (*tmplExprParser, error) {
+	toks, err := lexTmplExpr(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v in %q", where, err, src)
+	}
+	return &tmplExprParser{toks: toks, src: src, where: where}, nil
+}
+
+func parseTmplExpr(src string, line int) (tmplExpr, error) {
+	return parseExprAt(src, fmt.Sprintf("chat template: line %d", line))
+}
+
+// parseExprAt parses src as one whole expression; where prefixes syntax errors.
+func parseExprAt(src, where string) (tmplExpr, error) {
+	ep, err := newExprParserAt(src, where)
+	if err != nil {
+		return nil, err
+	}
+	x, err := ep.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := ep.done(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (ep *tmplExprParser) peek() tmplExprTok { return ep.toks[ep.pos] }
+
+func (ep *tmplExprParser) peekAt(n int) tmplExprTok {
+	if ep.pos+n < len(ep.toks) {
+		return ep.toks[ep.pos+n]
+	}
+	return tmplExprTok{}
+}
+
+func (ep *tmplExprParser) next() tmplExprTok {
+	tok := ep.toks[ep.pos]
+	if tok.kind != 0 {
+		ep.pos++
+	}
+	return tok
+}
+
+func (ep *tmplExprParser) accept(kind byte, val string) bool {
+	if tok := ep.peek(); tok.kind == kind && tok.val == val {
+		ep.pos++
+		return true
+	}
+	return false
+}
+
+func (ep *tmplExprParser) expect(kind byte, val string) error {
+	if !ep.accept(kind, val) {
+		return ep.errorf("expected %q", val)
+	}
+	return nil
+}
+
+func (ep *tmplExprParser) done() error {
+	if ep.peek().kind != 0 {
+		return ep.errorf("unexpected %q", ep.peek().val)
+	}
+	return nil
+}
+
+func (ep *tmplExprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s: %s in %q", ep.where, fmt.Sprintf(format, args...), ep.src)
+}
+
+func (ep *tmplExprParser) parseExpr() (tmplExpr, error) {
+	x, err := ep.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for ep.accept('n', "if") {
+		cond, err := ep.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		var els tmplExpr
+		if ep.accept('n', "else") {
+			if els, err = ep.parseExpr(); err != nil {
+				return nil, err
+			}
+		}
+		x = &tmplCondExpr{cond: cond, a: x, b: els}
+	}
+	return x, nil
+}
+
+func (ep *tmplExprParser) parseOr() (tmplExpr, error) {
+	x, err := ep.parseAnd()
+	for err == nil && ep.accept('n', "or") {
+		var r tmplExpr
+		r, err = ep.parseAnd()
+		x = &tmplBinaryExpr{op: "or", l: x, r: r}
+	}
+	return x, err
+}
+
+func (ep *tmplExprParser) parseAnd() (tmplExpr, error) {
+	x, err := ep.parseNot()
+	for err == nil && ep.accept('n', "and") {
+		var r tmplExpr
+		r, err = ep.parseNot()
+		x = &tmplBinaryExpr{op: "and", l: x, r: r}
+	}
+	return x, err
+}
+
+// nest tracks recursion through parseNot and parseUnary, which every nested expression passes.
+func (ep *tmplExprParser) nest() error {
+	if ep.depth++; ep.depth > tmplMaxDepth {
+		return ep.errorf("expression nests more than %d deep", tmplMaxDepth)
+	}
+	return nil
+}
+
+func (ep *tmplExprParser) parseNot() (tmplExpr, error) {
+	if err := ep.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { ep.depth-- }()
+	if ep.accept('n', "not") {
+		x, err := ep.parseNot()
+		return &tmplUnaryExpr{op: "not", x: x}, err
+	}
+	return ep.parseCompare()
+}
+
+func (ep *tmplExprParser) parseCompare() (tmplExpr, error) {
+	first, err := ep.parseMath1()
+	if err != nil {
+		return nil, err
+	}
+	cmp := &tmplCompareExpr{first: first}
+	for {
+		tok := ep.peek()
+		var op string
+		switch {
+		case tok.kind == 'o' && (tok.val == "==" || tok.val == "!=" || tok.val == "<" || tok.val == ">" || tok.val == "<=" || tok.val == ">="):
+			op = tok.val
+			ep.pos++
+		case tok.kind == 'n' && tok.val == "in":
+			op = "in"
+			ep.pos++
+		case tok.kind == 'n' && tok.val == "not" && ep.peekAt(1) == tmplExprTok{'n', "in"}:
+			op = "not in"
+			ep.pos += 2
+		default:
+			if len(cmp.ops) == 0 {
+				return first, nil
+			}
+			return cmp, nil
+		}
+		r, err := ep.parseMath1()
+		if err != nil {
+			return nil, err
+		}
+		cmp.ops = append(cmp.ops, op)
+		cmp.rest = append(cmp.rest, r)
+	}
+}
+
+func (ep *tmplExprParser) parseMath1() (tmplExpr, error) {
+	x, err := ep.parseConcat()
+	for err == nil && (ep.peek() == tmplExprTok{'o', "+"} || ep.peek() == tmplExprTok{'o', "-"}) {
+		op := ep.next().val
+		var r tmplExpr
+		r, err = ep.parseConcat()
+		x = &tmplBinaryExpr{op: op, l: x, r: r}
+	}
+	return x, err
+}
+
+func (ep *tmplExprParser) parseConcat() (tmplExpr, error) {
+	x, err := ep.parseMath2()
+	for err == nil && ep.accept('o', "~") {
+		var r tmplExpr
+		r, err = ep.parseMath2()
+		x = &tmplBinaryExpr{op: "~", l: x, r: r}
+	}
+	return x, err
+}
+
+func (ep *tmplExprParser) parseMath2() (tmplExpr, error) {
+	x, err := ep.parseUnary(true)
+	for err == nil {
+		tok := ep.peek()
+		if tok.kind != 'o' || (tok.val != "*" && tok.val != "/" && tok.val != "//" && tok.val != "%") {
+			break
+		}
+		ep.pos++
+		var r tmplExpr
+		r, err = ep.parseUnary(true)
+		x = &tmplBinaryExpr{op: tok.val, l: x, r: r}
+	}
+	return x, err
+}
+
+func (ep *tmplExprParser) parseUnary(withFilter bool) (tmplExpr, error) {
+	if err := ep.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { ep.depth-- }()
+	var x tmplExpr
+	var err error
+	if tok := ep.peek(); tok.kind == 'o' && (tok.val == "-" || tok.val == "+") {
+		ep.pos++
+		var inner tmplExpr
+		if inner, err = ep.parseUnary(false); err != nil {
+			return nil, err
+		}
+		x = &tmplUnaryExpr{op: tok.val, x: inner}
+	} else {
+		if x, err = ep.parsePrimary(); err != nil {
+			return nil, err
+		}
+		if x, err = ep.parsePostfix(x); err != nil {
+			return nil, err
+		}
+	}
+	if withFilter {
+		return ep.parseFilters(x)
+	}
+	return x, nil
+}
+
+func (ep *tmplExprParser) parsePrimary() (tmplExpr, error) {
+	tok := ep.next()
+	switch tok.kind {
+	case 'n':
+		switch tok.val {
+		case "true", "True":
+			return tmplLit{true}, nil
+		case "false", "False":
+			return tmplLit{false}, nil
+		case "none", "None":
+			return tmplLit{nil}, nil
+		}
+		return tmplName(tok.val), nil
+	case 's':
+		s := tok.val
+		for ep.peek().kind == 's' {
+			s += ep.next().val
+		}
+		return tmplLit{s}, nil
+	case 'd':
+		if strings.Contains(tok.val, ".") {
+			f, err := strconv.ParseFloat(tok.val, 64)
+			if err != nil {
+				return nil, ep.errorf("invalid number %q", tok.val)
+			}
+			return tmplLit{f}, nil
+		}
+		n, err := strconv.Atoi(tok.val)
+		if err != nil {
+			return nil, ep.errorf("invalid number %q", tok.val)
+		}
+		return tmplLit{n}, nil
+	case 'o':
+		switch tok.val {
+		case "(":
+			if ep.accept('o', ")") {
+				return tmplListExpr(nil), nil
+			}
+			x, err := ep.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if ep.peek() == (tmplExprTok{'o', ","}) {
+				items := tmplListExpr{x}
+				for ep.accept('o', ",") && ep.peek() != (tmplExprTok{'o', ")"}) {
+					item, err := ep.parseExpr()
+					if err != nil {
+						return nil, err
+					}
+					items = append(items, item)
+				}
+				x = items
+			}
+			return x, ep.expect('o', ")")
+		case "[":
+			var items tmplListExpr
+			for !ep.accept('o', "]") {
+				item, err := ep.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+				if !ep.accept('o', ",") {
+					if err := ep.expect('o', "]"); err != nil {
+						return nil, err
+					}
+					break
+				}
+			}
+			return items, nil
+		case "{":
+			dict := &tmplDictExpr{}
+			for !ep.accept('o', "}") {
+				k, err := ep.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				if err := ep.expect('o', ":"); err != nil {
+					return nil, err
+				}
+				v, err := ep.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				dict.keys = append(dict.keys, k)
+				dict.vals = append(dict.vals, v)
+				if !ep.accept('o', ",") {
+					if err := ep.expect('o', "}"); err != nil {
+						return nil, err
+					}
+					break
+				}
+			}
+			return dict, nil
+		}
+	case 0:
+		return nil, ep.errorf("unexpected end of expression")
+	}
+	return nil, ep.errorf("unexpected %q", tok.val)
+}
+
+func (ep *tmplExprParser) parsePostfix(x tmplExpr) (tmplExpr, error) {
+	for {
+		switch {
+		case ep.accept('o', "."):
+			tok := ep.next()
+			if tok.kind != 'n' && tok.kind != 'd' {
+				return nil, ep.errorf("expected attribute name")
+			}
+			if tok.kind == 'd' {
+				n, _ := strconv.Atoi(tok.val)
+				x = &tmplIndexExpr{x: x, idx: tmplLit{n}}
+				continue
+			}
+			x = &tmplAttrExpr{x: x, name: tok.val}
+		case ep.accept('o', "["):
+			var lo, hi, step tmplExpr
+			var err error
+			if ep.peek() != (tmplExprTok{'o', ":"}) {
+				if lo, err = ep.parseExpr(); err != nil {
+					return nil, err
+				}
+				if ep.accept('o', "]") {
+					x = &tmplIndexExpr{x: x, idx: lo}
+					continue
+				}
+			}
+			if err := ep.expect('o', ":"); err != nil {
+				return nil, err
+			}
+			if p := ep.peek(); p != (tmplExprTok{'o', "]"}) && p != (tmplExprTok{'o', ":"}) {
+				if hi, err = ep.parseExpr(); err != nil {
+					return nil, err
+				}
+			}
+			if ep.accept('o', ":") && ep.peek() != (tmplExprTok{'o', "]"}) {
+				if step, err = ep.parseExpr(); err != nil {
+					return nil, err
+				}
+			}
+			if err := ep.expect('o', "]"); err != nil {
+				return nil, err
+			}
+			x = &tmplSliceExpr{x: x, lo: lo, hi: hi, step: step}
+		case ep.peek() == tmplExprTok{'o', "("}:
+			args, err := ep.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			x = &tmplCallExpr{fn: x, args: args}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (ep *tmplExprParser) parseFilters(x tmplExpr) (tmplExpr, error) {
+	for {
+		switch {
+		case ep.accept('o', "|"):
+			tok := ep.next()
+			if tok.kind != 'n' {
+				return nil, ep.errorf("expected filter name")
+			}
+			f := &tmplFilterExpr{x: x, name: tok.val}
+			if ep.peek() == (tmplExprTok{'o', "("}) {
+				args, err := ep.parseArgs()
+				if err != nil {
+					return nil, err
+				}
+				f.args = args
+			}
+			x = f
+		case ep.accept('n', "is"):
+			t := &tmplTestExpr{x: x, negate: ep.accept('n', "not")}
+			tok := ep.next()
+			if tok.kind != 'n' {
+				return nil, ep.errorf("expected test name")
+			}
+			t.name = tok.val
+			switch p := ep.peek(); {
+			case p == tmplExprTok{'o', "("}:
+				args, err := ep.parseArgs()
+				if err != nil {
+					return nil, err
+				}
+				t.args = args
+			case p.kind == 's' || p.kind == 'd' || p.kind == 'n' && !slices.Contains([]string{"else", "or", "and", "if", "in", "not", "is"}, p.val):
+				arg, err := ep.parsePrimary()
+				if err != nil {
+					return nil, err
+				}
+				if arg, err = ep.parsePostfix(arg); err != nil {
+					return nil, err
+				}
+				t.args.pos = []tmplExpr{arg}
+			}
+			x = t
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (ep *tmplExprParser) parseArgs() (tmplArgs, error) {
+	var args tmplArgs
+	if err := ep.expect('o', "("); err != nil {
+		return args, err
+	}
+	for !ep.accept('o', ")") {
+		if tok := ep.peek(); tok.kind == 'n' && ep.peekAt(1) == (tmplExprTok{'o', "="}) {
+			ep.pos += 2
+			v, err := ep.parseExpr()
+			if err != nil {
+				return args, err
+			}
+			args.names = append(args.names, tok.val)
+			args.kw = append(args.kw, v)
+		} else {
+			v, err := ep.parseExpr()
+			if err != nil {
+				return args, err
+			}
+			args.pos = append(args.pos, v)
+		}
+		if !ep.accept('o', ",") {
+			return args, ep.expect('o', ")")
+		}
+	}
+	return args, nil
+}
+
+// ---- expression evaluation ----
+
+type tmplExpr interface {
+	eval(st *tmplState) (any, error)
+}
+
+type tmplLit struct{ v any }
+
+func (l tmplLit) eval(*tmplState) (any, error) { return l.v, nil }
+
+type tmplName string
+
+func (n tmplName) eval(st *tmplState) (any, error) { return st.lookup(string(n)), nil }
+
+type tmplListExpr []tmplExpr
+
+func (l tmplListExpr) eval(st *tmplState) (any, error) {
+	out := make([]any, 0, len(l))
+	for _, x := range l {
+		v, err := x.eval(st)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+type tmplDictExpr struct{ keys, vals []tmplExpr }
+
+func (d *tmplDictExpr) eval(st *tmplState) (any, error) {
+	out := make(map[string]any, len(d.keys))
+	for i := range d.keys {
+		k, err := d.keys[i].eval(st)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.vals[i].eval(st)
+		if err != nil {
+			return nil, err
+		}
+		out[tmplString(k)] = v
+	}
+	return out, nil
+}
+
+type tmplAttrExpr struct {
+	x    tmplExpr
+	name string
+}
+
+func (a *tmplAttrExpr) eval(st *tmplState) (any, error) {
+	v, err := a.x.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	return tmplGetAttr(v, a.name)
+}
+
+type tmplIndexExpr struct{ x, idx tmplExpr }
+
+func (e *tmplIndexExpr) eval(st *tmplState) (any, error) {
+	v, err := e.x.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	k, err := e.idx.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	return tmplGetItem(v, k)
+}
+
+type tmplSliceExpr struct{ x, lo, hi, step tmplExpr }
+
+func (e *tmplSliceExpr) eval(st *tmplState) (any, error) {
+	v, err := e.x.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	var bounds [3]any
+	for i, b := range []tmplExpr{e.lo, e.hi, e.step} {
+		if b == nil {
+			continue
+		}
+		if bounds[i], err = b.eval(st); err != nil {
+			return nil, err
+		}
+	}
+	switch x := v.(type) {
+	case []any:
+		idx, err := tmplSliceIndexes(len(x), bounds)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, 0, len(idx))
+		for _, i := range idx {
+			out = append(out, x[i])
+		}
+		return out, nil
+	case string:
+		runes := []rune(x)
+		idx, err := tmplSliceIndexes(len(runes), bounds)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]rune, 0, len(idx))
+		for _, i := range idx {
+			out = append(out, runes[i])
+		}
+		return string(out), nil
+	}
+	return nil, fmt.Errorf("cannot slice %s", tmplTypeName(v))
+}
+
+// tmplSliceIndexes applies Python slice semantics to a sequence of length n.
+func tmplSliceIndexes(n int, bounds [3]any) ([]int, error) {
+	var vals [3]int
+	var set [3]bool
+	for i, b := range bounds {
+		if b == nil {
+			continue
+		}
+		v, ok := b.(int)
+		if !ok {
+			return nil, fmt.Errorf("slice indices must be integers, not %s", tmplTypeName(b))
+		}
+		vals[i], set[i] = v, true
+	}
+	step := 1
+	if set[2] {
+		step = vals[2]
+	}
+	if step == 0 {
+		return nil, errors.New("slice step cannot be zero")
+	}
+	clamp := func(i, lo, hi int) int {
+		if i < 0 {
+			i += n
+		}
+		return min(max(i, lo), hi)
+	}
+	var start, stop int
+	if step > 0 {
+		start, stop = 0, n
+		if set[0] {
+			start = clamp(vals[0], 0, n)
+		}
+		if set[1] {
+			stop = clamp(vals[1], 0, n)
+		}
+	} else {
+		start, stop = n-1, -1
+		if set[0] {
+			start = clamp(vals[0], -1, n-1)
+		}
+		if set[1] {
+			stop = clamp(vals[1], -1, n-1)
+		}
+	}
+	var out []int
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		out = append(out, i)
+	}
+	return out, nil
+}
+
+type tmplArgs struct {
+	pos   []tmplExpr
+	names []string
+	kw    []tmplExpr
+}
+
+func (a tmplArgs) eval(st *tmplState) ([]any, map[string]any, error) {
+	pos := make([]any, 0, len(a.pos))
+	for _, x := range a.pos {
+		v, err := x.eval(st)
+		if err != nil {
+			return nil, nil, err
+		}
+		pos = append(pos, v)
+	}
+	var kw map[string]any
+	for i, x := range a.kw {
+		v, err := x.eval(st)
+		if err != nil {
+			return nil, nil, err
+		}
+		if kw == nil {
+			kw = map[string]any{}
+		}
+		kw[a.names[i]] = v
+	}
+	return pos, kw, nil
+}
+
+type tmplCallExpr struct {
+	fn   tmplExpr
+	args tmplArgs
+}
+
+func (c *tmplCallExpr) eval(st *tmplState) (any, error) {
+	fn, err := c.fn.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := fn.(tmplFunc)
+	if !ok {
+		if u, isUndef := fn.(tmplUndefined); isUndef {
+			return nil, fmt.Errorf("%q is undefined", u.name)
+		}
+		return nil, fmt.Errorf("%s is not callable", tmplTypeName(fn))
+	}
+	pos, kw, err := c.args.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	return f(pos, kw)
+}
+
+type tmplFilterExpr struct {
+	x    tmplExpr
+	name string
+	args tmplArgs
+}
+
+func (f *tmplFilterExpr) eval(st *tmplState) (any, error) {
+	v, err := f.x.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	pos, kw, err := f.args.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	return tmplApplyFilter(f.name, v, pos, kw)
+}
+
+type tmplTestExpr struct {
+	x      tmplExpr
+	name   string
+	args   tmplArgs
+	negate bool
+}
+
+func (t *tmplTestExpr) eval(st *tmplState) (any, error) {
+	v, err := t.x.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	pos, _, err := t.args.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := tmplApplyTest(t.name, v, pos)
+	return ok != t.negate, err
+}
+
+type tmplUnaryExpr struct {
+	op string
+	x  tmplExpr
+}
+
+func (u *tmplUnaryExpr) eval(st *tmplState) (any, error) {
+	v, err := u.x.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	switch u.op {
+	case "not":
+		return !tmplTruth(v), nil
+	case "-":
+		return tmplArith("-", 0, v)
+	}
+	return tmplArith("+", 0, v)
+}
+
+type tmplBinaryExpr struct {
+	op   string
+	l, r tmplExpr
+}
+
+func (b *tmplBinaryExpr) eval(st *tmplState) (any, error) {
+	l, err := b.l.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	switch b.op {
+	case "and":
+		if !tmplTruth(l) {
+			return l, nil
+		}
+		return b.r.eval(st)
+	case "or":
+		if tmplTruth(l) {
+			return l, nil
+		}
+		return b.r.eval(st)
+	}
+	r, err := b.r.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	if b.op == "~" {
+		return tmplString(l) + tmplString(r), nil
+	}
+	return tmplArith(b.op, l, r)
+}
+
+type tmplCompareExpr struct {
+	first tmplExpr
+	ops   []string
+	rest  []tmplExpr
+}
+
+func (c *tmplCompareExpr) eval(st *tmplState) (any, error) {
+	l, err := c.first.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range c.ops {
+		r, err := c.rest[i].eval(st)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := tmplCompare(op, l, r)
+		if err != nil || !ok {
+			return false, err
+		}
+		l = r
+	}
+	return true, nil
+}
+
+type tmplCondExpr struct{ cond, a, b tmplExpr }
+
+func (c *tmplCondExpr) eval(st *tmplState) (any, error) {
+	v, err := c.cond.eval(st)
+	if err != nil {
+		return nil, err
+	}
+	if tmplTruth(v) {
+		return c.a.eval(st)
+	}
+	if c.b == nil {
+		return tmplUndefined{name: "else branch"}, nil
+	}
+	return c.b.eval(st)
+}
+
+// ---- operators ----
+
+func tmplNumber(v any) (float64, bool, bool) {
+	switch x := v.(type) {
+	case int:
+		return float64(x), true, true
+	case float64:
+		return x, false, true
+	}
+	return 0, false, false
+}
+
+func tmplArith(op string, l, r any) (any, error) {
+	if op == "+" {
+		switch x := l.(type) {
+		case string:
+			if y, ok := r.(string); ok {
+				return x + y, nil
+			}
+		case []any:
+			if y, ok := r.([]any); ok {
+				return append(slices.Clone(x), y...), nil
+			}
+		}
+	}
+	if op == "*" {
+		if s, ok := l.(string); ok {
+			if n, ok := r.(int); ok {
+				if n > 0 && len(s) > tmplMaxRange/n {
+					return nil, fmt.Errorf("string repetition is longer than %d bytes", tmplMaxRange)
+				}
+				return strings.Repeat(s, max(n, 0)), nil
+			}
+		}
+	}
+	a, aInt, ok1 := tmplNumber(l)
+	b, bInt, ok2 := tmplNumber(r)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("unsupported operand types for %s: %s and %s", op, tmplTypeName(l), tmplTypeName(r))
+	}
+	if (op == "/" || op == "//" || op == "%") && b == 0 {
+		return nil, errors.New("division by zero")
+	}
+	if aInt && bInt {
+		x, y := l.(int), r.(int)
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "//":
+			q := x / y
+			if (x%y != 0) && ((x < 0) != (y < 0)) {
+				q--
+			}
+			return q, nil
+		case "%":
+			m := x % y
+			if m != 0 && (m < 0) != (y < 0) {
+				m += y
+			}
+			return m, nil
+		}
+	}
+	switch op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		return a / b, nil
+	case "//":
+		return math.Floor(a / b), nil
+	case "%":
+		return a - b*math.Floor(a/b), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", op)
+}
+
+func tmplCompare(op string, l, r any) (bool, error) {
+	switch op {
+	case "==":
+		return tmplEqual(l, r), nil
+	case "!=":
+		return !tmplEqual(l, r), nil
+	case "in", "not in":
+		ok, err := tmplContains(r, l)
+		return ok == (op == "in"), err
+	}
+	var c int
+	if a, _, ok := tmplNumber(l); ok {
+		b, _, ok := tmplNumber(r)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %s and %s", tmplTypeName(l), tmplTypeName(r))
+		}
+		c = cmpFloat(a, b)
+	} else if a, ok := l.(string); ok {
+		b, ok := r.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %s and %s", tmplTypeName(l), tmplTypeName(r))
+		}
+		c = strings.Compare(a, b)
+	} else {
+		return false, fmt.Errorf("cannot compare %s and %s", tmplTypeName(l), tmplTypeName(r))
+	}
+	switch op {
+	case "<":
+		return c < 0, nil
+	case ">":
+		return c > 0, nil
+	case "<=":
+		return c <= 0, nil
+	}
+	return c >= 0, nil
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func tmplEqual(l, r any) bool {
+	if a, _, ok := tmplNumber(l); ok {
+		b, _, ok := tmplNumber(r)
+		return ok && a == b
+	}
+	switch x := l.(type) {
+	case []any:
+		y, ok := r.([]any)
+		return ok && slices.EqualFunc(x, y, tmplEqual)
+	case map[string]any:
+		y, ok := r.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !tmplEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case tmplUndefined:
+		_, ok := r.(tmplUndefined)
+		return ok
+	case nil, bool, string:
+		return l == r
+	}
+	return false
+}
+
+func tmplContains(container, item any) (bool, error) {
+	switch c := container.(type) {
+	case string:
+		s, ok := item.(string)
+		if !ok {
+			return false, fmt.Errorf("'in <string>' requires string as left operand, not %s", tmplTypeName(item))
+		}
+		return strings.Contains(c, s), nil
+	case []any:
+		return slices.ContainsFunc(c, func(v any) bool { return tmplEqual(v, item) }), nil
+	case map[string]any:
+		s, ok := item.(string)
+		if !ok {
+			return false, nil
+		}
+		_, found := c[s]
+		return found, nil
+	case *tmplNamespace:
+		s, _ := item.(string)
+		_, found := c.vals[s]
+		return found, nil
+	case tmplUndefined:
+		return false, nil
+	}
+	return false, fmt.Errorf("argument of type %s is not iterable", tmplTypeName(container))
+}
+
+// ---- attribute and item access ----
+
+func tmplGetAttr(v any, name string) (any, error) {
+	switch x := v.(type) {
+	case tmplUndefined:
+		return nil, fmt.Errorf("%q is undefined", x.name)
+	case map[string]any:
+		if e, ok := x[name]; ok {
+			return e, nil
+		}
+		if m := tmplDictMethod(x, name); m != nil {
+			return m, nil
+		}
+	case *tmplNamespace:
+		if e, ok := x.vals[name]; ok {
+			return e, nil
+		}
+	case string:
+		if m := tmplStringMethod(x, name); m != nil {
+			return m, nil
+		}
+	}
+	return tmplUndefined{name: name}, nil
+}
+
+func tmplGetItem(v, key any) (any, error) {
+	switch x := v.(type) {
+	case tmplUndefined:
+		return nil, fmt.Errorf("%q is undefined", x.name)
+	case map[string]any:
+		if s, ok := key.(string); ok {
+			if e, ok := x[s]; ok {
+				return e, nil
+			}
+			return tmplUndefined{name: s}, nil
+		}
+	case []any:
+		if i, ok := key.(int); ok {
+			if i < 0 {
+				i += len(x)
+			}
+			if i >= 0 && i < len(x) {
+				return x[i], nil
+			}
+			return tmplUndefined{name: fmt.Sprintf("[%d]", key)}, nil
+		}
+	case string:
+		if i, ok := key.(int); ok {
+			runes := []rune(x)
+			if i < 0 {
+				i += len(runes)
+			}
+			if i >= 0 && i < len(runes) {
+				return string(runes[i]), nil
+			}
+			return tmplUndefined{name: fmt.Sprintf("[%d]", key)}, nil
+		}
+	}
+	if s, ok := key.(string); ok {
+		return tmplGetAttr(v, s)
+	}
+	return tmplUndefined{name: tmplString(key)}, nil
+}
+
+func tmplDictMethod(m map[string]any, name string) tmplFunc {
+	switch name {
+	case "items":
+		return func([]any, map[string]any) (any, error) { return tmplItems(m), nil }
+	case "keys":
+		return func([]any, map[string]any) (any, error) { return tmplIterate(m) }
+	case "values":
+		return func([]any, map[string]any) (any, error) {
+			var out []any
+			for _, k := range sortedKeys(m) {
+				out = append(out, m[k])
+			}
+			return out, nil
+		}
+	case "get":
+		return func(args []any, _ map[string]any) (any, error) {
+			if len(args) == 0 {
+				return nil, errors.New("get expects a key")
+			}
+			if v, ok := m[tmplString(args[0])]; ok {
+				return v, nil
+			}
+			if len(args) > 1 {
+				return args[1], nil
+			}
+			return nil, nil
+		}
+	}
+	return nil
+}
+
+// tmplItems lists a dict's [key, value] pairs in key order (Go maps have no insertion order).
+func tmplItems(m map[string]any) []any {
+	out := make([]any, 0, len(m))
+	for _, k := range sortedKeys(m) {
+		out = append(out, []any{k, m[k]})
+	}
+	return out
+}
+
+func tmplStringMethod(s, name string) tmplFunc {
+	arg := func(args []any, i int) (string, bool) {
+		if i < len(args) {
+			if v, ok := args[i].(string); ok {
+				return v, true
+			}
+		}
+		return "", false
+	}
+	switch name {
+	case "strip", "lstrip", "rstrip":
+		return func(args []any, _ map[string]any) (any, error) {
+			cut, ok := arg(args, 0)
+			if !ok {
+				cut = " \t\r\n\v\f"
+			}
+			switch name {
+			case "lstrip":
+				return strings.TrimLeft(s, cut), nil
+			case "rstrip":
+				return strings.TrimRight(s, cut), nil
+			}
+			return strings.Trim(s, cut), nil
+		}
+	case "startswith", "endswith", "startsWith", "endsWith": // the camel-case spellings are JavaScript's
+		prefix := strings.ToLower(name) == "startswith"
+		return func(args []any, _ map[string]any) (any, error) {
+			var candidates []any
+			if len(args) > 0 {
+				if list, ok := args[0].([]any); ok {
+					candidates = list
+				} else {
+					candidates = args[:1]
+				}
+			}
+			for _, c := range candidates {
+				affix, _ := c.(string)
+				if prefix && strings.HasPrefix(s, affix) || !prefix && strings.HasSuffix(s, affix) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+	case "includes":
+		return func(args []any, _ map[string]any) (any, error) {
+			sub, _ := arg(args, 0)
+			return strings.Contains(s, sub), nil
+		}
+	case "split":
+		return func(args []any, _ map[string]any) (any, error) {
+			var parts []string
+			sep, ok := arg(args, 0)
+			limit := -1
+			if len(args) > 1 {
+				if n, isInt := args[1].(int); isInt && n >= 0 {
+					limit = n + 1
+				}
+			}
+			if ok {
+				parts = strings.SplitN(s, sep, limit)
+			} else {
+				parts = strings.Fields(s)
+			}
+			return tmplNormalize(parts), nil
+		}
+	case "upper", "lower", "title", "capitalize":
+		return func([]any, map[string]any) (any, error) { return tmplCase(name, s), nil }
+	case "replace":
+		return func(args []any, _ map[string]any) (any, error) {
+			old, _ := arg(args, 0)
+			repl, _ := arg(args, 1)
+			n := -1
+			if len(args) > 2 {
+				n, _ = args[2].(int)
+			}
+			return strings.Replace(s, old, repl, n), nil
+		}
+	case "find":
+		return func(args []any, _ map[string]any) (any, error) {
+			sub, _ := arg(args, 0)
+			return strings.Index(s, sub), nil
+		}
+	case "join":
+		return func(args []any, _ map[string]any) (any, error) {
+			if len(args) == 0 {
+				return nil, errors.New("join expects an iterable")
+			}
+			items, err := tmplIterate(args[0])
+			if err != nil {
+				return nil, err
+			}
+			parts := make([]string, len(items))
+			for i, item := range items {
+				parts[i] = tmplString(item)
+			}
+			return strings.Join(parts, s), nil
+		}
+	}
+	return nil
+}
+
+func tmplCase(name, s string) string {
+	switch name {
+	case "upper":
+		return strings.ToUpper(s)
+	case "lower":
+		return strings.ToLower(s)
+	case "capitalize":
+		if s == "" {
+			return s
+		}
+		r := []rune(strings.ToLower(s))
+		return strings.ToUpper(string(r[0])) + string(r[1:])
+	}
+	var b strings.Builder
+	prevLetter := false
+	for _, r := range s {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 127
+		if letter && !prevLetter {
+			b.WriteString(strings.ToUpper(string(r)))
+		} else {
+			b.WriteString(strings.ToLower(string(r)))
+		}
+		prevLetter = letter
+	}
+	return b.String()
+}
+
+// ---- filters and tests ----
+
+func tmplApplyFilter(name string, v any, args []any, kw map[string]any) (any, error) {
+	arg := func(i int, key string) (any, bool) {
+		if i < len(args) {
+			return args[i], true
+		}
+		a, ok := kw[key]
+		return a, ok
+	}
+	switch name {
+	case "safe", "string":
+		if name == "string" {
+			return tmplString(v), nil
+		}
+		return v, nil
+	case "trim":
+		return strings.TrimSpace(tmplString(v)), nil
+	case "upper", "lower", "title", "capitalize":
+		return tmplCase(name, tmplString(v)), nil
+	case "length", "count":
+		switch x := v.(type) {
+		case string:
+			return len([]rune(x)), nil
+		case []any:
+			return len(x), nil
+		case map[string]any:
+			return len(x), nil
+		case tmplUndefined:
+			return 0, nil
+		}
+		return nil, fmt.Errorf("object of type %s has no length", tmplTypeName(v))
+	case "default", "d":
+		def, _ := arg(0, "default_value")
+		boolean, _ := arg(1, "boolean")
+		if _, undef := v.(tmplUndefined); undef || tmplTruth(boolean) && !tmplTruth(v) {
+			if def == nil && len(args) == 0 {
+				return "", nil
+			}
+			return def, nil
+		}
+		return v, nil
+	case "tojson":
+		indent := 0
+		if a, ok := arg(0, "indent"); ok {
+			indent, _ = a.(int)
+		}
+		var b strings.Builder
+		if err := tmplJSON(&b, v, indent, 0); err != nil {
+			return nil, err
+		}
+		return b.String(), nil
+	case "int":
+		switch x := v.(type) {
+		case int:
+			return x, nil
+		case float64:
+			return int(x), nil
+		case bool:
+			if x {
+				return 1, nil
+			}
+			return 0, nil
+		case string:
+			if n, err := strconv.Atoi(strings.TrimSpace(x)); err == nil {
+				return n, nil
+			}
+		}
+		def, _ := arg(0, "default")
+		if def == nil {
+			def = 0
+		}
+		return def, nil
+	case "float":
+		if f, _, ok := tmplNumber(v); ok {
+			return f, nil
+		}
+		if s, ok := v.(string); ok {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return f, nil
+			}
+		}
+		return 0.0, nil
+	case "first", "last":
+		items, err := tmplIterate(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			return tmplUndefined{name: name}, nil
+		}
+		if name == "first" {
+			return items[0], nil
+		}
+		return items[len(items)-1], nil
+	case "join":
+		items, err := tmplIterate(v)
+		if err != nil {
+			return nil, err
+		}
+		sep := ""
+		if a, ok := arg(0, "d"); ok {
+			sep = tmplString(a)
+		}
+		attr, hasAttr := arg(1, "attribute")
+		parts := make([]string, len(items))
+		for i, item := range items {
+			if hasAttr {
+				if item, err = tmplGetItem(item, attr); err != nil {
+					return nil, err
+				}
+			}
+			parts[i] = tmplString(item)
+		}
+		return strings.Join(parts, sep), nil
+	case "list":
+		return tmplIterate(v)
+	case "reverse":
+		if s, ok := v.(string); ok {
+			r := []rune(s)
+			slices.Reverse(r)
+			return string(r), nil
+		}
+		items, err := tmplIterate(v)
+		if err != nil {
+			return nil, err
+		}
+		out := slices.Clone(items)
+		slices.Reverse(out)
+		return out, nil
+	case "replace":
+		old, _ := arg(0, "old")
+		repl, _ := arg(1, "new")
+		n := -1
+		if a, ok := arg(2, "count"); ok {
+			n, _ = a.(int)
+		}
+		return strings.Replace(tmplString(v), tmplString(old), tmplString(repl), n), nil
+	case "items":
+		switch x := v.(type) {
+		case map[string]any:
+			return tmplItems(x), nil
+		case tmplUndefined:
+			return []any{}, nil
+		}
+		return nil, fmt.Errorf("items filter expects a dict, got %s", tmplTypeName(v))
+	case "indent":
+		width := 4
+		if a, ok := arg(0, "width"); ok {
+			width, _ = a.(int)
+		}
+		first, _ := arg(1, "first")
+		blank, _ := arg(2, "blank")
+		pad := strings.Repeat(" ", width)
+		lines := strings.Split(tmplString(v), "\n")
+		for i, line := range lines {
+			if (i > 0 || tmplTruth(first)) && (line != "" || tmplTruth(blank)) {
+				lines[i] = pad + line
+			}
+		}
+		return strings.Join(lines, "\n"), nil
+	case "map", "selectattr", "rejectattr":
+		items, err := tmplIterate(v)
+		if err != nil {
+			return nil, err
+		}
+		return tmplSelect(name, items, args, kw)
+	}
+	return nil, fmt.Errorf("unknown filter %q", name)
+}
+
+// tmplSelect implements map, selectattr, and rejectattr.
+func tmplSelect(name string, items, args []any, kw map[string]any) (any, error) {
+	out := []any{}
+	if name == "map" {
+		attr, hasAttr := kw["attribute"]
+		for _, item := range items {
+			var v any
+			var err error
+			switch {
+			case hasAttr:
+				v, err = tmplGetItem(item, attr)
+				if _, undef := v.(tmplUndefined); undef && kw["default"] != nil {
+					v = kw["default"]
+				}
+			case len(args) > 0:
+				v, err = tmplApplyFilter(tmplString(args[0]), item, args[1:], nil)
+			default:
+				v = item
+			}
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s expects an attribute name", name)
+	}
+	keep := name == "selectattr"
+	for _, item := range items {
+		subject, err := tmplGetItem(item, args[0])
+		if err != nil {
+			return nil, err
+		}
+		ok := tmplTruth(subject)
+		if len(args) > 1 {
+			if ok, err = tmplApplyTest(tmplString(args[1]), subject, args[2:]); err != nil {
+				return nil, err
+			}
+		}
+		if ok == keep {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+func tmplApplyTest(name string, v any, args []any) (bool, error) {
+	_, undef := v.(tmplUndefined)
+	switch name {
+	case "defined":
+		return !undef, nil
+	case "undefined":
+		return undef, nil
+	case "none":
+		return v == nil, nil
+	case "string":
+		_, ok := v.(string)
+		return ok, nil
+	case "number":
+		_, _, ok := tmplNumber(v)
+		return ok, nil
+	case "integer":
+		_, ok := v.(int)
+		return ok, nil
+	case "float":
+		_, ok := v.(float64)
+		return ok, nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok, nil
+	case "true", "false":
+		b, ok := v.(bool)
+		return ok && b == (name == "true"), nil
+	case "mapping":
+		_, ok := v.(map[string]any)
+		return ok, nil
+	case "iterable", "sequence":
+		switch v.(type) {
+		case []any, map[string]any, string:
+			return true, nil
+		}
+		return false, nil
+	case "odd", "even":
+		n, ok := v.(int)
+		return ok && (n%2 != 0) == (name == "odd"), nil
+	case "equalto", "eq", "sameas":
+		return tmplEqual(v, tmplArg(args, 0)), nil
+	case "ne":
+		return !tmplEqual(v, tmplArg(args, 0)), nil
+	}
+	return false, fmt.Errorf("unknown test %q", name)
+}
+
+func tmplArg(args []any, i int) any {
+	if i < len(args) {
+		return args[i]
+	}
+	return tmplUndefined{name: "argument"}
+}
+
+// ---- rendering ----
+
+// tmplString renders a value the way Python's str() does.
+func tmplString(v any) string {
+	switch x := v.(type) {
+	case tmplUndefined:
+		return ""
+	case string:
+		return x
+	case nil:
+		return "None"
+	case bool:
+		if x {
+			return "True"
+		}
+		return "False"
+	case int:
+		return strconv.Itoa(x)
+	case float64:
+		return tmplFloat(x)
+	case *tmplNamespace:
+		return tmplReprSeen(x, nil)
+	case tmplFunc:
+		return "<function>"
+	}
+	return tmplRepr(v)
+}
+
+// tmplRepr renders a value the way Python's repr() does (strings quoted, containers recursed).
+func tmplRepr(v any) string { return tmplReprSeen(v, nil) }
+
+// tmplReprSeen is tmplRepr that renders a namespace already being printed as "<Namespace ...>":
+// namespaces are the only mutable values, so "{% set ns.self = ns %}" is the only way to build
+// a cycle.
+func tmplReprSeen(v any, seen map[*tmplNamespace]bool) string {
+	switch x := v.(type) {
+	case *tmplNamespace:
+		if seen[x] {
+			return "<Namespace ...>"
+		}
+		if seen == nil {
+			seen = map[*tmplNamespace]bool{}
+		}
+		seen[x] = true
+		defer delete(seen, x)
+		return "<Namespace " + tmplReprSeen(x.vals, seen) + ">"
+	case string:
+		quote := "'"
+		if strings.Contains(x, "'") && !strings.Contains(x, `"`) {
+			quote = `"`
+		}
+		r := strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`, quote, `\`+quote)
+		return quote + r.Replace(x) + quote
+	case []any:
+		parts := make([]string, len(x))
+		for i, e := range x {
+			parts[i] = tmplReprSeen(e, seen)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case map[string]any:
+		parts := make([]string, 0, len(x))
+		for _, k := range sortedKeys(x) {
+			parts = append(parts, tmplRepr(k)+": "+tmplReprSeen(x[k], seen))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	return tmplString(v)
+}
+
+func tmplFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	if abs := math.Abs(f); abs >= 1e16 || abs != 0 && abs < 1e-4 {
+		return strconv.FormatFloat(f, 'e', -1, 64)
+	}
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// tmplJSON mirrors the tojson filter transformers installs (json.dumps with ensure_ascii off):
+// ", " and ": " separators, or newlines when indent is positive. Keys are sorted.
+func tmplJSON(b *strings.Builder, v any, indent, depth int) error {
+	newline := func(d int) {
+		if indent > 0 {
+			b.WriteString("\n" + strings.Repeat(" ", indent*d))
+		}
+	}
+	itemSep := ", "
+	if indent > 0 {
+		itemSep = ","
+	}
+	switch x := v.(type) {
+	case nil, tmplUndefined:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(x))
+	case int:
+		b.WriteString(strconv.Itoa(x))
+	case float64:
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			return fmt.Errorf("tojson: unsupported float %v", x)
+		}
+		b.WriteString(tmplFloat(x))
+	case string:
+		b.WriteString(tmplJSONString(x))
+	case []any:
+		if len(x) == 0 {
+			b.WriteString("[]")
+			return nil
+		}
+		b.WriteString("[")
+		for i, e := range x {
+			if i > 0 {
+				b.WriteString(itemSep)
+			}
+			newline(depth + 1)
+			if err := tmplJSON(b, e, indent, depth+1); err != nil {
+				return err
+			}
+		}
+		newline(depth)
+		b.WriteString("]")
+	case map[string]any:
+		if len(x) == 0 {
+			b.WriteString("{}")
+			return nil
+		}
+		b.WriteString("{")
+		for i, k := range sortedKeys(x) {
+			if i > 0 {
+				b.WriteString(itemSep)
+			}
+			newline(depth + 1)
+			b.WriteString(tmplJSONString(k) + ": ")
+			if err := tmplJSON(b, x[k], indent, depth+1); err != nil {
+				return err
+			}
+		}
+		newline(depth)
+		b.WriteString("}")
+	default:
+		return fmt.Errorf("tojson: cannot encode %s", tmplTypeName(v))
+	}
+	return nil
+}
+
+func tmplJSONString(s string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
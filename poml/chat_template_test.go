@@ -0,0 +1,214 @@
+package poml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChatTemplateExpressions(t *testing.T) {
+	cases := []struct {
+		name, src, want string
+	}{
+		{"namespace", `{% set ns = namespace(found=false) %}{% for m in messages %}{% if m.role == 'system' %}{% set ns.found = true %}{% endif %}{% endfor %}{{ ns.found }}`, "True"},
+		{"loop", `{% for m in messages if m.role != 'system' %}{{ loop.index }}:{{ m.role }}{% if not loop.last %},{% endif %}{% endfor %}`, "1:user,2:assistant"},
+		{"reverse slice", `{% for m in messages[::-1] %}{{ m['role'][0] }}{% endfor %}`, "aus"},
+		{"tojson", `{{ tools | tojson }}`, `[{"function": {"name": "search"}, "type": "function"}]`},
+		{"tests", `{{ messages[0].tool_calls is defined }}/{{ x is none }}/{{ messages | length is odd }}/{{ 'ab' is string }}`, "False/False/True/True"},
+		{"filters", `{{ messages | map(attribute='role') | join(', ') }}|{{ messages | selectattr('role', 'equalto', 'user') | list | length }}|{{ missing | default('dflt') }}`, "system, user, assistant|1|dflt"},
+		{"methods", `{{ '  x  '.strip() ~ '|' ~ 'a,b'.split(',')[1] ~ '|' ~ 'Hello'.startswith('He') }}`, "x|b|True"},
+		{"arith", `{{ 7 // 2 }} {{ -7 % 3 }} {{ 1 / 2 }} {{ 'ab' * 2 }} {{ 3 if 2 > 1 else 4 }}`, "3 2 0.5 abab 3"},
+		{"in", `{{ 'u' in 'user' }} {{ 'role' in messages[0] }} {{ 4 not in [1, 2] }}`, "True True True"},
+		{"whitespace", "{% for m in messages %}\n  {%- if loop.first %}\n[{{ m.role }}]\n  {% endif %}\n{% endfor %}", "[system]\n"},
+	}
+	vars := map[string]any{
+		"messages": []map[string]any{
+			{"role": "system", "content": "s"},
+			{"role": "user", "content": "u"},
+			{"role": "assistant", "content": "a"},
+		},
+		"tools": []any{map[string]any{"type": "function", "function": map[string]any{"name": "search"}}},
+		"x":     1,
+	}
+	for _, tc := range cases {
+		tmpl, err := ParseChatTemplate(tc.src)
+		if err != nil {
+			t.Fatalf("%s: parse: %v", tc.name, err)
+		}
+		got, err := tmpl.Execute(vars)
+		if err != nil {
+			t.Fatalf("%s: execute: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestChatTemplateErrors(t *testing.T) {
+	if _, err := ParseChatTemplate("{% for m in messages %}{{ m }}"); err == nil || !strings.Contains(err.Error(), "endfor") {
+		t.Fatalf("expected missing endfor error, got %v", err)
+	}
+	if _, err := ParseChatTemplate("line\n{{ 'unterminated }}"); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected unclosed tag error on line 2, got %v", err)
+	}
+	tmpl, err := ParseChatTemplate("ok\n{{ missing.attr }}")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := tmpl.Execute(nil); err == nil || !strings.Contains(err.Error(), "line 2") || !strings.Contains(err.Error(), "undefined") {
+		t.Fatalf("expected undefined error on line 2, got %v", err)
+	}
+}
+
+// chatTemplateVars is the context the table-driven chat template tests render against.
+func chatTemplateVars() map[string]any {
+	return map[string]any{
+		"messages": []map[string]any{
+			{"role": "system", "content": "s"},
+			{"role": "user", "content": "u"},
+			{"role": "assistant", "content": "a"},
+		},
+		"x": 1,
+	}
+}
+
+func TestChatTemplateGrammar(t *testing.T) {
+	cases := []struct {
+		name, src, want string
+	}{
+		// filters
+		{"trim", `{{ '  a b  ' | trim }}`, "a b"},
+		{"length", `{{ 'abc' | length }}|{{ [1, 2] | count }}|{{ {'a': 1} | length }}|{{ missing | length }}`, "3|2|1|0"},
+		{"case", `{{ 'hello wORLD' | upper }} {{ 'ABC' | lower }} {{ 'hello wORLD' | title }} {{ 'hELLO' | capitalize }}`, "HELLO WORLD abc Hello World Hello"},
+		{"default", `{{ missing | default('x') }}|{{ '' | default('x') }}|{{ '' | default('x', true) }}|{{ none | d('n') }}|{{ missing | default }}`, "x||x|None|"},
+		{"tojson", `{{ {'b': [1, 2.5, none, true], 'a': 'é"'} | tojson }}`, `{"a": "é\"", "b": [1, 2.5, null, true]}`},
+		{"tojson indent", `{{ {'a': [1]} | tojson(indent=2) }}`, "{\n  \"a\": [\n    1\n  ]\n}"},
+		{"conversions", `{{ 3 | string ~ 'x' }}|{{ '42' | int + 1 }}|{{ 'x' | int(7) }}|{{ '2.5' | float }}|{{ 3.7 | int }}`, "3x|43|7|2.5|3"},
+		{"sequences", `{{ [1, 2, 3] | first }}{{ [1, 2, 3] | last }}{{ 'ab' | list }}{{ [1, 2] | reverse | list }}{{ 'abc' | reverse }}`, "13['a', 'b'][2, 1]cba"},
+		{"join", `{{ [1, 2] | join }}|{{ messages | join(', ', attribute='role') }}`, "12|system, user, assistant"},
+		{"replace", `{{ 'aaa' | replace('a', 'b', 2) }}`, "bba"},
+		{"items", `{% for k, v in {'b': 2, 'a': 1} | items %}{{ k }}={{ v }};{% endfor %}`, "a=1;b=2;"},
+		{"indent", `{{ 'a\nb\n\nc' | indent(2) }}|{{ 'a\nb' | indent(2, true) }}`, "a\n  b\n\n  c|  a\n  b"},
+		{"map", `{{ [1, 2] | map('string') | join('-') }}|{{ messages | map(attribute='name', default='anon') | first }}`, "1-2|anon"},
+		{"selectattr", `{{ messages | rejectattr('role', 'equalto', 'system') | map(attribute='content') | join }}|{{ messages | selectattr('tool_calls') | list }}`, "ua|[]"},
+		{"safe", `{{ '<b>' | safe }}`, "<b>"},
+
+		// tests
+		{"type tests", `{{ 1 is number }}{{ 1.5 is float }}{{ 1 is integer }}{{ true is boolean }}{{ {} is mapping }}{{ 's' is iterable }}{{ [1] is sequence }}`, "TrueTrueTrueTrueTrueTrueTrue"},
+		{"value tests", `{{ true is true }}{{ 0 is false }}{{ 3 is odd }}{{ 4 is even }}{{ none is none }}{{ x is not none }}{{ missing is undefined }}`, "TrueFalseTrueTrueTrueTrueTrue"},
+		{"comparison tests", `{{ 'a' is eq 'a' }}{{ 'a' is ne('b') }}{{ 'a' is equalto 'b' }}{{ x is sameas 1 }}`, "TrueTrueFalseTrue"},
+
+		// loops
+		{"loop counters", `{% for m in messages %}{{ loop.index0 }}{{ loop.index }}{{ loop.revindex }}{{ loop.revindex0 }}{{ loop.length }}{{ loop.first }}{{ loop.last }} {% endfor %}`, "01323TrueFalse 12213FalseFalse 23103FalseTrue "},
+		{"loop neighbours", `{% for n in [1, 2, 3] %}{{ loop.previtem | default('-') }}{{ loop.nextitem | default('-') }} {% endfor %}`, "-2 13 2- "},
+		{"loop else", `{% for m in [] %}x{% else %}empty{% endfor %}`, "empty"},
+		{"loop filter", `{% for m in messages if m.role != 'system' %}{{ loop.length }}{% endfor %}`, "22"},
+		{"nested loops", `{% for a in [1, 2] %}{% for b in 'xy' %}{{ loop.index }}{% endfor %}{{ loop.index }}{% endfor %}`, "121122"},
+		{"loop scope", `{% set x = 1 %}{% for i in [1] %}{% set x = 2 %}{% endfor %}{{ x }}`, "1"},
+		{"unpacking", `{% for a, b in [[1, 2], [3, 4]] %}{{ a + b }}{% endfor %}`, "37"},
+		{"range", `{% for i in range(3) %}{{ i }}{% endfor %}{% for i in range(5, 0, -2) %}{{ i }}{% endfor %}`, "012531"},
+		{"dict iteration", `{% for k in {'b': 1, 'a': 2} %}{{ k }}{% endfor %}`, "ab"},
+
+		// expressions
+		{"slices", `{{ 'abcdef'[1:4] }}{{ [1, 2, 3, 4][::2] }}{{ 'abc'[-1] }}`, "bcd[1, 3]c"},
+		{"dict methods", `{{ {'a': 1}.get('b', 2) }}{{ {'a': 1}.items() | list }}`, "2[['a', 1]]"},
+		{"chained comparison", `{{ 1 < 2 < 3 }}{{ 3 > 2 > 2 }}`, "TrueFalse"},
+		{"string literals", `{{ 'a' "b" }}{{ 'it\'s' }}`, "abit's"},
+		{"conditional", `[{{ 'y' if false }}]{{ 'a' if x == 2 else 'b' if x == 1 else 'c' }}`, "[]b"},
+		{"javascript operators", `{{ 1 === 1 && !false }}{{ 1 !== 1 || false }}`, "TrueFalse"},
+		{"elif", `{% if x == 0 %}a{% elif x == 1 %}b{% else %}c{% endif %}`, "b"},
+		{"namespace cycle", `{% set ns = namespace(a=1) %}{% set ns.self = ns %}{{ ns }}`, "<Namespace {'a': 1, 'self': <Namespace ...>}>"},
+		{"strftime_now", `{{ strftime_now('%Y') | length }}`, "4"},
+		{"nesting", strings.Repeat("{% if true %}", 50) + "deep" + strings.Repeat("{% endif %}", 50), "deep"},
+
+		// whitespace control: trim_blocks and lstrip_blocks, "-" and "+"
+		{"block lines", "a\n  {% if true %}\nb\n  {% endif %}\nc", "a\nb\nc"},
+		{"leading block", "  {% if true %}x{% endif %}  ", "x  "},
+		{"minus", "a  {%- if true -%}  b  {%- endif %}", "ab"},
+		{"output tags", "a\n  {{ 'x' }}\n", "a\n  x\n"},
+		{"plus", "a\n  {%+ if true %}b{% endif +%}\nc", "a\n  b\nc"},
+		{"comments", "a {# note #} b\n{# own line #}\nc", "a  b\nc"},
+	}
+	for _, tc := range cases {
+		tmpl, err := ParseChatTemplate(tc.src)
+		if err != nil {
+			t.Fatalf("%s: parse: %v", tc.name, err)
+		}
+		got, err := tmpl.Execute(chatTemplateVars())
+		if err != nil {
+			t.Fatalf("%s: execute: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestChatTemplateErrorPaths(t *testing.T) {
+	cases := []struct {
+		name, src, want string
+	}{
+		// lexing and parsing
+		{"unclosed output", "a\n\n{{ x", "line 3: unclosed {{"},
+		{"unclosed comment", "{# x", "line 1: unclosed {#"},
+		{"missing endif", "{% if x %}\n{% for m in messages %}{% endfor %}", "line 1: missing {% endif %}"},
+		{"mismatched end", "{% if x %}\n{% endfor %}", "line 2: unexpected {% endfor %}"},
+		{"stray end", "a\n{% endif %}", "line 2: unexpected {% endif %}"},
+		{"double else", "{% if x %}{% else %}\n{% else %}{% endif %}", "line 2: unexpected {% else %}"},
+		{"macro", "{% macro m() %}{% endmacro %}", "line 1: unsupported tag {% macro %}"},
+		{"include", "\n{% include 'x.jinja' %}", "line 2: unsupported tag {% include %}"},
+		{"recursive loop", "{% for m in messages recursive %}{{ loop(m.children) }}{% endfor %}", "line 1: recursive loops are not supported"},
+		{"block set", "{% set x %}y{% endset %}", "line 1: block {% set %} is not supported"},
+		{"set target", "{% set a.b.c = 1 %}", `line 1: invalid set target "a.b.c"`},
+		{"loop header", "{% for 1 in messages %}{% endfor %}", "line 1: expected loop variable"},
+		{"incomplete expression", "{{ 1 + }}", "line 1: unexpected end of expression"},
+		{"bad character", "{{ a @ b }}", "line 1: unexpected character '@'"},
+		{"unclosed list", "\n{{ [1, 2 }}", `line 2: expected "]"`},
+		{"trailing tokens", "{{ a b }}", `line 1: unexpected "b"`},
+		{"filter name", "{{ x | }}", "line 1: expected filter name"},
+		{"block nesting", strings.Repeat("{% if true %}\n", 101) + strings.Repeat("{% endif %}", 101), "line 100: blocks nest more than 100 deep"},
+		{"expression nesting", "{{ " + strings.Repeat("(", 200) + "1" + strings.Repeat(")", 200) + " }}", "line 1: expression nests more than 100 deep"},
+		{"not nesting", "{{ " + strings.Repeat("not ", 200) + "x }}", "line 1: expression nests more than 100 deep"},
+
+		// execution
+		{"unknown filter", "\n{{ x | shout }}", `line 2: unknown filter "shout"`},
+		{"unknown test", "{{ x is shiny }}", `line 1: unknown test "shiny"`},
+		{"not iterable", "{% for i in 5 %}{% endfor %}", "line 1: int is not iterable"},
+		{"unpacking", "{% for a, b in [1] %}{% endfor %}", "line 1: cannot unpack int into 2 variables"},
+		{"division by zero", "{{ 1 // 0 }}", "line 1: division by zero"},
+		{"nested line", "{% for m in messages %}\n{% if m.role == 'user' %}\n{{ m.content + 1 }}\n{% endif %}\n{% endfor %}", "line 3: unsupported operand types for +: string and int"},
+		{"set attribute", "{% set x = 1 %}{% set x.y = 2 %}", "line 1: cannot assign attribute on x"},
+		{"range limit", "{{ range(200000) | length }}", "line 1: range has more than 100000 items"},
+		{"repetition limit", "{{ 'ab' * 60000 }}", "line 1: string repetition is longer than 100000 bytes"},
+		{"tojson namespace", "{{ namespace() | tojson }}", "line 1: tojson: cannot encode namespace"},
+	}
+	for _, tc := range cases {
+		tmpl, err := ParseChatTemplate(tc.src)
+		if err == nil {
+			_, err = tmpl.Execute(chatTemplateVars())
+		}
+		if err == nil || !strings.Contains(err.Error(), "chat template: "+tc.want) {
+			t.Fatalf("%s: got %v, want an error containing %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestChatTemplateRaiseException(t *testing.T) {
+	tmpl, err := ParseChatTemplate("{% if messages[0].role != 'user' %}{{ raise_exception('Conversation must start with user') }}{% endif %}")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	_, err = tmpl.Execute(chatTemplateVars())
+	if !errors.Is(err, ErrChatTemplateException) || !strings.HasSuffix(err.Error(), ": Conversation must start with user") {
+		t.Fatalf("want ErrChatTemplateException, got %v", err)
+	}
+}
+
+func TestStrftime(t *testing.T) {
+	got := strftime(time.Date(2024, 3, 5, 14, 7, 9, 0, time.UTC), "%d %b %Y %I:%M:%S %p %A %j %y/%m %B %a %% %q")
+	if want := "05 Mar 2024 02:07:09 PM Tuesday 065 24/03 March Tue % %q"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
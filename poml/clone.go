@@ -0,0 +1,146 @@
+package poml
+
+import (
+	"encoding/xml"
+	"slices"
+)
+
+// Clone returns a deep copy of d that shares no slices with it, so edits to the copy
+// (including Mutate, and attribute, inline content, and diagram slices reached through payload
+// pointers) never show up in d. Plain assignment copies only slice headers, so the two documents
+// would share payloads. Nil slices stay nil.
+func (d Document) Clone() Document {
+	out := d
+	out.Role = cloneBlock(d.Role)
+	out.Tasks = cloneEach(d.Tasks, cloneBlock)
+	out.Inputs = cloneEach(d.Inputs, func(in Input) Input { in.Attrs = cloneAttrs(in.Attrs); return in })
+	out.Documents = cloneEach(d.Documents, func(r DocRef) DocRef { r.Attrs = cloneAttrs(r.Attrs); return r })
+	out.Styles = cloneEach(d.Styles, func(st Style) Style {
+		st.Attrs = cloneAttrs(st.Attrs)
+		st.Outputs = cloneEach(st.Outputs, func(o Output) Output { o.Attrs = cloneAttrs(o.Attrs); return o })
+		return st
+	})
+	out.OutFormats = cloneEach(d.OutFormats, func(f OutputFormat) OutputFormat { f.Attrs = cloneAttrs(f.Attrs); return f })
+	out.Hints = cloneEach(d.Hints, func(h Hint) Hint {
+		h.Attrs, h.Content = cloneAttrs(h.Attrs), cloneInline(h.Content)
+		return h
+	})
+	out.Examples = cloneEach(d.Examples, func(ex Example) Example {
+		ex.Attrs, ex.Content = cloneAttrs(ex.Attrs), cloneInline(ex.Content)
+		if ex.Pair != nil {
+			pair := *ex.Pair
+			ex.Pair = &pair
+		}
+		return ex
+	})
+	out.ContentParts = cloneEach(d.ContentParts, func(cp ContentPart) ContentPart {
+		cp.Attrs, cp.Content = cloneAttrs(cp.Attrs), cloneInline(cp.Content)
+		return cp
+	})
+	out.Objects = cloneEach(d.Objects, cloneObject)
+	out.Audios = cloneEach(d.Audios, cloneMedia)
+	out.Videos = cloneEach(d.Videos, cloneMedia)
+	out.Messages = cloneEach(d.Messages, func(msg Message) Message {
+		msg.Attrs, msg.Content = cloneAttrs(msg.Attrs), cloneInline(msg.Content)
+		return msg
+	})
+	out.ToolDefs = cloneEach(d.ToolDefs, func(td ToolDefinition) ToolDefinition { td.Attrs = cloneAttrs(td.Attrs); return td })
+	out.ToolReqs = cloneEach(d.ToolReqs, func(tr ToolRequest) ToolRequest { tr.Attrs = cloneAttrs(tr.Attrs); return tr })
+	out.ToolResps = cloneEach(d.ToolResps, func(tr ToolResponse) ToolResponse { tr.Attrs = cloneAttrs(tr.Attrs); return tr })
+	out.ToolResults = cloneEach(d.ToolResults, func(tr ToolResult) ToolResult { tr.Attrs = cloneAttrs(tr.Attrs); return tr })
+	out.ToolErrors = cloneEach(d.ToolErrors, func(te ToolError) ToolError { te.Attrs = cloneAttrs(te.Attrs); return te })
+	out.Runtimes = cloneEach(d.Runtimes, func(rt Runtime) Runtime { rt.Attrs = cloneAttrs(rt.Attrs); return rt })
+	out.Traces = cloneEach(d.Traces, func(tr Trace) Trace { tr.Attrs = cloneAttrs(tr.Attrs); return tr })
+	out.Schema.Attrs = cloneAttrs(d.Schema.Attrs)
+	out.Images = cloneEach(d.Images, cloneImage)
+	out.Diagrams = cloneEach(d.Diagrams, cloneDiagram)
+	out.Elements = slices.Clone(d.Elements)
+	out.Issues = slices.Clone(d.Issues)
+	return out
+}
+
+// cloneEach copies s, passing every item through f.
+func cloneEach[T any](s []T, f func(T) T) []T {
+	if s == nil {
+		return nil
+	}
+	out := make([]T, len(s))
+	for i, v := range s {
+		out[i] = f(v)
+	}
+	return out
+}
+
+func cloneAttrs(attrs []xml.Attr) []xml.Attr {
+	return slices.Clone(attrs)
+}
+
+func cloneBlock(b Block) Block {
+	b.Attrs = cloneAttrs(b.Attrs)
+	return b
+}
+
+func cloneObject(o ObjectTag) ObjectTag {
+	o.Attrs = cloneAttrs(o.Attrs)
+	return o
+}
+
+func cloneImage(img Image) Image {
+	img.Attrs = cloneAttrs(img.Attrs)
+	return img
+}
+
+func cloneMedia(m Media) Media {
+	m.Attrs = cloneAttrs(m.Attrs)
+	return m
+}
+
+func cloneInline(nodes []InlineNode) []InlineNode {
+	return cloneEach(nodes, func(n InlineNode) InlineNode {
+		if n.Image != nil {
+			img := cloneImage(*n.Image)
+			n.Image = &img
+		}
+		if n.Object != nil {
+			obj := cloneObject(*n.Object)
+			n.Object = &obj
+		}
+		n.Attrs = cloneAttrs(n.Attrs)
+		n.Children = cloneInline(n.Children)
+		return n
+	})
+}
+
+func cloneDiagram(dg Diagram) Diagram {
+	styles := func(s []DiagramStyle) []DiagramStyle {
+		return cloneEach(s, func(st DiagramStyle) DiagramStyle { st.Attrs = cloneAttrs(st.Attrs); return st })
+	}
+	dg.Attrs = cloneAttrs(dg.Attrs)
+	dg.Camera.Attrs = cloneAttrs(dg.Camera.Attrs)
+	dg.Layers = cloneEach(dg.Layers, func(l DiagramLayer) DiagramLayer { l.Attrs = cloneAttrs(l.Attrs); return l })
+	dg.Graph.Nodes = cloneEach(dg.Graph.Nodes, func(n DiagramNode) DiagramNode {
+		n.Styles, n.Data, n.Attrs = styles(n.Styles), slices.Clone(n.Data), cloneAttrs(n.Attrs)
+		return n
+	})
+	dg.Graph.Edges = cloneEach(dg.Graph.Edges, func(e DiagramEdge) DiagramEdge {
+		if e.Directed != nil {
+			directed := *e.Directed
+			e.Directed = &directed
+		}
+		e.Styles, e.Points, e.Attrs = styles(e.Styles), slices.Clone(e.Points), cloneAttrs(e.Attrs)
+		return e
+	})
+	dg.Graph.Groups = cloneEach(dg.Graph.Groups, func(g DiagramGroup) DiagramGroup {
+		g.Members, g.Styles, g.Attrs = slices.Clone(g.Members), styles(g.Styles), cloneAttrs(g.Attrs)
+		return g
+	})
+	dg.Frames = cloneEach(dg.Frames, func(f DiagramFrame) DiagramFrame {
+		f.Nodes = cloneEach(f.Nodes, func(n DiagramNode) DiagramNode {
+			n.Styles, n.Data, n.Attrs = styles(n.Styles), slices.Clone(n.Data), cloneAttrs(n.Attrs)
+			return n
+		})
+		f.Attrs = cloneAttrs(f.Attrs)
+		return f
+	})
+	return dg
+}
@@ -0,0 +1,59 @@
+package poml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCloneSharesNothingWithOriginal(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <task lang="en">Describe <b>this</b>.</task>
+  <human-msg>Look at <img src="data:image/png;base64,AA==" alt="a"/></human-msg>
+  <example><input>q</input><output>a</output></example>
+  <runtime temperature="0.2"/>
+  <diagram id="d">
+    <graph>
+      <node id="n1" x="0"><style color="red"/></node>
+      <node id="n2"/>
+      <edge from="n1" to="n2" directed="true"/>
+      <group id="g"><member node="n1"/></group>
+    </graph>
+  </diagram>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	doc.Messages[0].Content, _ = ParseInline(doc.Messages[0].Body)
+	doc.Examples[0].Pair = doc.examplePair(doc.Elements[2], ConvertOptions{})
+	want := doc.Clone()
+	if !reflect.DeepEqual(want, doc) {
+		t.Fatalf("clone differs from original")
+	}
+
+	clone := doc.Clone()
+	if err := clone.Mutate(func(el Element, p ElementPayload, m *Mutator) error {
+		switch el.Type {
+		case ElementTask:
+			m.ReplaceBody(el, "changed")
+			p.Task.Attrs[0].Value = "fr"
+		case ElementHumanMsg:
+			p.Message.Content[1].Image.Alt = "b"
+		case ElementRuntime:
+			m.Remove(el)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("mutate: %v", err)
+	}
+	clone.Examples[0].Pair.Input = "changed"
+	dg := &clone.Diagrams[0]
+	dg.Graph.Nodes[0].X = "9"
+	dg.Graph.Nodes[0].Styles[0].Color = "blue"
+	*dg.Graph.Edges[0].Directed = false
+	dg.Graph.Groups[0].Members[0].Node = "n2"
+	clone.Elements[0].Comment = "changed"
+
+	if !reflect.DeepEqual(doc, want) {
+		t.Fatalf("mutating the clone changed the original:\n%+v\n%+v", doc, want)
+	}
+}
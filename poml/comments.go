@@ -0,0 +1,55 @@
+package poml
+
+import "strings"
+
+// leadingComment extracts the text of the comments in a Leading run, trimmed and joined with
+// newlines.
+func leadingComment(leading string) string {
+	var parts []string
+	rest := leading
+	for {
+		start := strings.Index(rest, "<!--")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest[start+4:], "-->")
+		if end < 0 {
+			break
+		}
+		parts = append(parts, strings.TrimSpace(rest[start+4:start+4+end]))
+		rest = rest[start+4+end+3:]
+	}
+	return strings.Join(parts, "\n")
+}
+
+// withComment rewrites a preserved Leading run so its comments read comment: the run is kept
+// verbatim when they already match, the span of existing comments is replaced otherwise, and a
+// run without comments gains one placed on its own line at the element's indentation.
+func withComment(leading, comment string) string {
+	if leadingComment(leading) == comment {
+		return leading
+	}
+	first, last := strings.Index(leading, "<!--"), strings.LastIndex(leading, "-->")
+	if first >= 0 && last > first {
+		replacement := ""
+		if comment != "" {
+			replacement = commentMarkup(comment)
+		}
+		return leading[:first] + replacement + leading[last+3:]
+	}
+	indent := leading[strings.LastIndex(leading, "\n")+1:]
+	return leading + commentMarkup(comment) + "\n" + indent
+}
+
+func commentMarkup(comment string) string {
+	return "<!--" + commentText(comment) + "-->"
+}
+
+// commentText pads comment for an xml.Comment token, breaking up "--", which XML forbids inside
+// comments.
+func commentText(comment string) string {
+	for strings.Contains(comment, "--") {
+		comment = strings.ReplaceAll(comment, "--", "- -")
+	}
+	return " " + comment + " "
+}
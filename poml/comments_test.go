@@ -0,0 +1,71 @@
+package poml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestElementComments(t *testing.T) {
+	src := `<poml>
+  <!-- keep answers short: users read on mobile -->
+  <task>Answer briefly.</task>
+  <!-- added after incident 42 -->
+  <!-- remove once the model improves -->
+  <hint>Never guess dates.</hint>
+  <role>r</role>
+</poml>`
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	els := doc.Elements
+	if els[0].Comment != "keep answers short: users read on mobile" || els[1].Comment != "added after incident 42\nremove once the model improves" || els[2].Comment != "" {
+		t.Fatalf("comments = %q, %q, %q", els[0].Comment, els[1].Comment, els[2].Comment)
+	}
+
+	// Edits survive both the default encoder and whitespace-preserving round-trips.
+	err = doc.Mutate(func(el Element, _ ElementPayload, m *Mutator) error {
+		switch el.Type {
+		case ElementHint:
+			m.SetComment(el, "see incident 42 -- postmortem")
+		case ElementRole:
+			m.SetComment(el, "persona")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("mutate: %v", err)
+	}
+	var preserved bytes.Buffer
+	if err := doc.EncodeWithOptions(&preserved, EncodeOptions{PreserveOrder: true, PreserveWS: true}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	want := strings.NewReplacer(
+		"<!-- added after incident 42 -->\n  <!-- remove once the model improves -->", "<!-- see incident 42 - - postmortem -->",
+		"\n  <role>", "\n  <!-- persona -->\n  <role>",
+	).Replace(src)
+	if preserved.String() != want {
+		t.Fatalf("preserved encode:\n%s\nwant\n%s", preserved.String(), want)
+	}
+	var plain bytes.Buffer
+	if err := doc.Encode(&plain); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	reparsed, err := ParseString(plain.String())
+	if err != nil {
+		t.Fatalf("reparse: %v\n%s", err, plain.String())
+	}
+	if got := reparsed.Elements[2].Comment; got != "persona" || reparsed.Elements[0].Comment != els[0].Comment {
+		t.Fatalf("comments lost in default encode:\n%s", plain.String())
+	}
+
+	built := NewBuilder().Task("t").Comment("why this task exists").Build()
+	plain.Reset()
+	if err := built.EncodeWithOptions(&plain, EncodeOptions{PreserveOrder: true, Compact: true}); err != nil {
+		t.Fatalf("encode built: %v", err)
+	}
+	if plain.String() != "<poml><!-- why this task exists --><task>t</task></poml>" {
+		t.Fatalf("builder comment: %s", plain.String())
+	}
+}
@@ -0,0 +1,412 @@
+package poml
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// flowTagRe matches a start tag with an if or for attribute, the cue that markup needs expanding.
+var flowTagRe = regexp.MustCompile(`<[^<>!?/]*\s(?:if|for)\s*=`)
+
+// ExpandControlFlow returns a copy of d with POML's control-flow attributes applied, evaluating
+// expressions against vars; Convert does this with ConvertOptions.Context.
+//
+//   - for="x in expr" repeats an element once per item of expr, with x and loop in scope
+//     (loop.index counts from 0, as upstream POML does; loop.length, loop.first, and loop.last
+//     are also set). "k, v in expr" unpacks pairs and "x in expr if cond" skips items.
+//   - if="expr" keeps an element only when expr is truthy; with for, it is tested per item.
+//
+// Both work on top-level elements and on tags nested in element bodies, and are removed from the
+// output. Each {{ expr }} in a body, or in an attribute of a nested tag, is replaced by its value
+// when every variable it names is bound by vars or a loop; other {{ }} text, such as
+// {{inputs.NAME}} references, is left for later stages. Expressions use the ChatTemplate
+// language, also accepting the JavaScript spellings upstream templates use (||, &&, !, ===,
+// !==, startsWith, endsWith, includes), and may be wrapped in {{ }}. Failures are POMLErrors with
+// code POML-CONTROL-FLOW.
+func (d Document) ExpandControlFlow(vars map[string]any) (Document, error) {
+	out := d.Clone()
+	if len(out.Elements) == 0 {
+		out.Elements = out.defaultElements()
+	}
+	st := newTmplState(vars)
+	for i := len(out.Elements) - 1; i >= 0; i-- {
+		el := out.Elements[i]
+		frag, changed, err := out.expandElement(el, st)
+		if err != nil {
+			return Document{}, &POMLError{Type: ErrTemplate, Code: CodeControlFlow, Message: fmt.Sprintf("expand %s (%s)", el.Type, el.ID), Err: err}
+		}
+		if changed {
+			out.spliceElements(i, i+1, frag)
+		}
+	}
+	return out, nil
+}
+
+// expandElement returns the copies of el its attributes and body expand to, or changed false
+// when el is unaffected.
+func (d *Document) expandElement(el Element, st *tmplState) (frag Document, changed bool, err error) {
+	if el.Type == ElementUnknown {
+		return expandUnknown(el, st)
+	}
+	p := d.payloadFor(el)
+	var flow flowAttrs
+	flow.forSrc, flow.hasFor = p.LookupAttr("for")
+	flow.ifSrc, flow.hasIf = p.LookupAttr("if")
+	if !flow.hasFor && !flow.hasIf && !d.hasExpressions(el, p) {
+		return Document{}, false, nil
+	}
+	if flow.hasFor && (el.Type == ElementMeta || el.Type == ElementRole || el.Type == ElementOutputSchema) {
+		return Document{}, false, fmt.Errorf("%s cannot repeat", el.Type)
+	}
+	one := d.detach(el)
+	err = flow.expand(st, func() error {
+		inst := one.Clone()
+		instEl := inst.Elements[0]
+		ip := inst.payloadFor(instEl)
+		ip.DeleteAttr("for")
+		ip.DeleteAttr("if")
+		for _, v := range ip.attrValues() {
+			expanded, err := interpolate(*v, st, nil)
+			if err != nil {
+				return err
+			}
+			*v = expanded
+		}
+		if b := inst.bodyRef(instEl); b != nil {
+			expanded, err := expandBody(*b, st, instEl.CDATA)
+			if err != nil {
+				return err
+			}
+			*b = expanded
+			inst.refreshContent(instEl)
+		}
+		frag.appendDetached(inst)
+		return nil
+	})
+	return frag, true, err
+}
+
+// hasExpressions reports whether el, whose payload is p, has {{ }} in an attribute or its body,
+// or a nested tag with control flow.
+func (d *Document) hasExpressions(el Element, p ElementPayload) bool {
+	if body := d.bodyRef(el); body != nil && (strings.Contains(*body, "{{") || flowTagRe.MatchString(*body)) {
+		return true
+	}
+	for _, v := range p.attrValues() {
+		if strings.Contains(*v, "{{") {
+			return true
+		}
+	}
+	return false
+}
+
+// expandUnknown expands the raw markup of an unknown element.
+func expandUnknown(el Element, st *tmplState) (Document, bool, error) {
+	var frag Document
+	if !flowTagRe.MatchString(el.RawXML) {
+		if !strings.Contains(el.RawXML, "{{") {
+			return frag, false, nil
+		}
+		raw, err := interpolate(el.RawXML, st, xmlTemplateEscaper.Replace)
+		if err != nil {
+			return frag, false, err
+		}
+		el.RawXML = raw
+		frag.Elements = []Element{el}
+		return frag, true, nil
+	}
+	n, err := ParseNode(el.RawXML)
+	if err != nil {
+		return frag, false, err
+	}
+	nodes, err := expandNodes([]Node{n}, st)
+	if err != nil {
+		return frag, false, err
+	}
+	for _, n := range nodes {
+		inst := el
+		inst.RawXML = n.XML()
+		frag.Elements = append(frag.Elements, inst)
+	}
+	return frag, true, nil
+}
+
+// detach returns a document holding only el and a copy of its payload, at index 0.
+func (d *Document) detach(el Element) Document {
+	var one Document
+	switch el.Type {
+	case ElementMeta:
+		one.Meta = d.Meta
+	case ElementRole:
+		one.Role = d.Role
+	case ElementOutputSchema:
+		one.Schema = d.Schema
+	default:
+		one.insertPayloadAt(0, d.payloadFor(el))
+		el.Index = 0
+	}
+	one.Elements = []Element{el}
+	return one.Clone()
+}
+
+// appendDetached appends the element of one, a document from detach, to frag, whose elements
+// all share its type.
+func (frag *Document) appendDetached(one Document) {
+	el := one.Elements[0]
+	switch el.Type {
+	case ElementMeta:
+		frag.Meta = one.Meta
+	case ElementRole:
+		frag.Role = one.Role
+	case ElementOutputSchema:
+		frag.Schema = one.Schema
+	default:
+		el.Index = len(frag.Elements)
+		frag.insertPayloadAt(el.Index, one.payloadFor(one.Elements[0]))
+	}
+	frag.Elements = append(frag.Elements, el)
+}
+
+// expandBody applies control flow to the tags nested in an element body and substitutes its
+// {{ }} expressions. A CDATA body is text, so only the substitution applies, unescaped.
+func expandBody(body string, st *tmplState, cdata bool) (string, error) {
+	if cdata {
+		return interpolate(body, st, nil)
+	}
+	if !flowTagRe.MatchString(body) {
+		return interpolate(body, st, escapeBodyText)
+	}
+	root, err := ParseNode("<body>" + body + "</body>")
+	if err != nil {
+		return "", err
+	}
+	nodes, err := expandNodes(root.Children, st)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		n.writeXML(&b)
+	}
+	return b.String(), nil
+}
+
+// expandNodes applies control flow and {{ }} substitution to a list of sibling nodes.
+func expandNodes(nodes []Node, st *tmplState) ([]Node, error) {
+	var out []Node
+	for _, n := range nodes {
+		switch n.Kind {
+		case NodeText:
+			text, err := interpolate(n.Text, st, nil)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, Node{Kind: NodeText, Text: text})
+		case NodeComment:
+			out = append(out, n)
+		default:
+			var flow flowAttrs
+			flow.forSrc, flow.hasFor = n.Attrs.Lookup("for")
+			flow.ifSrc, flow.hasIf = n.Attrs.Lookup("if")
+			err := flow.expand(st, func() error {
+				c := Node{Kind: n.Kind, Name: n.Name, Attrs: Attrs(cloneAttrs(n.Attrs))}
+				c.Attrs.Delete("for")
+				c.Attrs.Delete("if")
+				for i := range c.Attrs {
+					v, err := interpolate(c.Attrs[i].Value, st, nil)
+					if err != nil {
+						return err
+					}
+					c.Attrs[i].Value = v
+				}
+				children, err := expandNodes(n.Children, st)
+				if err != nil {
+					return err
+				}
+				c.Children = children
+				out = append(out, c)
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("<%s>: %w", n.Name, err)
+			}
+		}
+	}
+	return out, nil
+}
+
+// flowAttrs holds an element's for and if attributes.
+type flowAttrs struct {
+	forSrc, ifSrc string
+	hasFor, hasIf bool
+}
+
+// expand calls emit once per copy of the element the attributes produce, with the loop variables
+// of that copy bound in st.
+func (f flowAttrs) expand(st *tmplState, emit func() error) error {
+	var cond tmplExpr
+	if f.hasIf {
+		x, err := parseExprAt(unwrapExpr(f.ifSrc), "if")
+		if err != nil {
+			return err
+		}
+		cond = x
+	}
+	keep := func() (bool, error) {
+		if cond == nil {
+			return true, nil
+		}
+		v, err := cond.eval(st)
+		if err != nil {
+			return false, fmt.Errorf("if %q: %w", f.ifSrc, err)
+		}
+		return tmplTruth(v), nil
+	}
+	if !f.hasFor {
+		if ok, err := keep(); err != nil || !ok {
+			return err
+		}
+		return emit()
+	}
+	ep, err := newExprParserAt(unwrapExpr(f.forSrc), "for")
+	if err != nil {
+		return err
+	}
+	targets, iter, filter, err := ep.parseLoopHeader()
+	if err != nil {
+		return err
+	}
+	v, err := iter.eval(st)
+	if err != nil {
+		return fmt.Errorf("for %q: %w", f.forSrc, err)
+	}
+	items, err := tmplIterate(v)
+	if err != nil {
+		return fmt.Errorf("for %q: %w", f.forSrc, err)
+	}
+	st.push()
+	defer st.pop()
+	if filter != nil {
+		var kept []any
+		for _, item := range items {
+			if err := st.bind(targets, item); err != nil {
+				return fmt.Errorf("for %q: %w", f.forSrc, err)
+			}
+			ok, err := filter.eval(st)
+			if err != nil {
+				return fmt.Errorf("for %q: %w", f.forSrc, err)
+			}
+			if tmplTruth(ok) {
+				kept = append(kept, item)
+			}
+		}
+		items = kept
+	}
+	for i, item := range items {
+		if err := st.bind(targets, item); err != nil {
+			return fmt.Errorf("for %q: %w", f.forSrc, err)
+		}
+		st.top()["loop"] = map[string]any{"index": i, "length": len(items), "first": i == 0, "last": i == len(items)-1}
+		ok, err := keep()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := emit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unwrapExpr strips the optional {{ }} around an if or for attribute.
+func unwrapExpr(src string) string {
+	src = strings.TrimSpace(src)
+	if strings.HasPrefix(src, "{{") && strings.HasSuffix(src, "}}") {
+		return strings.TrimSpace(src[2 : len(src)-2])
+	}
+	return src
+}
+
+// interpolate replaces each {{ expr }} in s whose variables are all bound in st with its value,
+// passed through esc unless esc is nil, and leaves the others as written.
+func interpolate(s string, st *tmplState, esc func(string) string) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range templateExprRe.FindAllStringSubmatchIndex(s, -1) {
+		src := s[m[2]:m[3]]
+		if !exprBound(src, st) {
+			continue
+		}
+		x, err := parseExprAt(src, "{{ }}")
+		if err != nil {
+			return "", err
+		}
+		v, err := x.eval(st)
+		if err != nil {
+			return "", fmt.Errorf("{{%s}}: %w", src, err)
+		}
+		if _, undefined := v.(tmplUndefined); undefined {
+			v = nil
+		}
+		text := inputText(v)
+		if esc != nil {
+			text = esc(text)
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(text)
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// exprBound reports whether expression src names at least one variable and st binds them all.
+func exprBound(src string, st *tmplState) bool {
+	names := exprVarNames(src)
+	for _, name := range names {
+		if _, undefined := st.lookup(name).(tmplUndefined); undefined {
+			return false
+		}
+	}
+	return len(names) > 0
+}
+
+// exprVarNames returns the variables expression src reads: its names other than keywords,
+// attributes, filters, tests, and keyword arguments. It returns nil when src does not lex.
+func exprVarNames(src string) []string {
+	toks, err := lexTmplExpr(src)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for i, tok := range toks {
+		if tok.kind != 'n' {
+			continue
+		}
+		switch tok.val {
+		case "and", "or", "not", "in", "is", "if", "else", "true", "True", "false", "False", "none", "None":
+			continue
+		}
+		if i > 0 {
+			prev := toks[i-1]
+			if prev.kind == 'o' && (prev.val == "." || prev.val == "|") || prev.kind == 'n' && prev.val == "is" {
+				continue
+			}
+			if prev.kind == 'n' && prev.val == "not" && i > 1 && toks[i-2].kind == 'n' && toks[i-2].val == "is" {
+				continue
+			}
+		}
+		if next := toks[i+1]; next.kind == 'o' && next.val == "=" {
+			continue
+		}
+		names = append(names, tok.val)
+	}
+	return names
+}
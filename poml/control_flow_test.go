@@ -0,0 +1,120 @@
+package poml
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandControlFlow(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <role if="!formal">Be casual.</role>
+  <task for="t in tasks" if="t.on &amp;&amp; t.name !== 'skip'" id="task-{{ loop.index }}">Step {{ loop.index }}: {{ t.name }} for {{inputs.user}}<list><item for="s in t.steps">{{ s }}</item></list></task>
+  <hint if="{{ tasks | length > 1 }}">Pace yourself.</hint>
+  <x:note for="k, v in limits.items()" key="{{ k }}">{{ v }}</x:note>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	vars := map[string]any{
+		"formal": true,
+		"tasks": []map[string]any{
+			{"name": "read", "on": true, "steps": []string{"open", "a < b"}},
+			{"name": "skip", "on": true},
+			{"name": "write", "on": true},
+			{"name": "off", "on": false},
+		},
+		"limits": map[string]any{"tokens": 100},
+	}
+	out, err := doc.ExpandControlFlow(vars)
+	if err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	if out.Role.Body != "" || len(out.Hints) != 1 || len(out.Tasks) != 2 {
+		t.Fatalf("unexpected elements: role %q, %d hints, %d tasks", out.Role.Body, len(out.Hints), len(out.Tasks))
+	}
+	if got := out.Tasks[0].Body; got != "Step 0: read for {{inputs.user}}<list><item>open</item><item>a &lt; b</item></list>" {
+		t.Fatalf("task 0 body = %q", got)
+	}
+	if got := out.Tasks[1].Body; got != "Step 2: write for {{inputs.user}}<list/>" {
+		t.Fatalf("task 1 body = %q", got)
+	}
+	if id := out.Tasks[1].Attr("id"); id != "task-2" || out.Tasks[1].Attr("for") != "" || out.Tasks[1].Attr("if") != "" {
+		t.Fatalf("task attributes = %+v", out.Tasks[1].Attrs)
+	}
+	var raw []string
+	for _, el := range out.Elements {
+		if el.Type == ElementUnknown {
+			raw = append(raw, el.RawXML)
+		}
+	}
+	if len(raw) != 1 || raw[0] != `<x:note key="tokens">100</x:note>` {
+		t.Fatalf("unknown elements = %q", raw)
+	}
+	if len(doc.Tasks) != 1 || doc.Role.Body == "" {
+		t.Fatalf("the source document changed: %+v", doc.Tasks)
+	}
+
+	text, err := Convert(doc, FormatText, ConvertOptions{Context: vars, Inputs: map[string]any{"user": "Ada"}})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if s, _ := text.(string); !strings.Contains(s, "Step 2: write for Ada") || strings.Contains(s, "Be casual") {
+		t.Fatalf("inputs should bind after expansion: %q", s)
+	}
+}
+
+func TestConvertUpstreamExampleWithContext(t *testing.T) {
+	doc, err := ParseFile(filepath.Join("testdata", "examples", "203_expense_extract_document.poml"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", "examples", "context", "203_expense_extract_document.context.json"))
+	if err != nil {
+		t.Fatalf("read context: %v", err)
+	}
+	var vars map[string]any
+	if err := json.Unmarshal(data, &vars); err != nil {
+		t.Fatalf("decode context: %v", err)
+	}
+	out, err := doc.ExpandControlFlow(vars)
+	if err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	cp := out.ContentParts[0]
+	if cp.Attr("caption") != "File: assets/203_flight_itinerary.pdf" || strings.Contains(cp.Body, "<img") || !strings.Contains(cp.Body, `<document src="assets/203_flight_itinerary.pdf" parser="pdf"/>`) {
+		t.Fatalf("unexpected cp: %+v", cp)
+	}
+	if !strings.HasPrefix(out.Schema.Body, `{"$defs":{`) {
+		t.Fatalf("schema should be rendered as JSON, got %.40q", out.Schema.Body)
+	}
+
+	for _, f := range []Format{FormatMessageDict, FormatOpenAIChat} {
+		if _, err := Convert(doc, f, ConvertOptions{}); err != nil {
+			t.Fatalf("convert %s without a context: %v", f, err)
+		}
+	}
+}
+
+func TestExpandControlFlowErrors(t *testing.T) {
+	for _, src := range []string{
+		`<poml><task for="x in 3">a</task></poml>`,
+		`<poml><task for="x of xs">a</task></poml>`,
+		`<poml><task if="a ==">a</task></poml>`,
+		`<poml><role for="x in xs">a</role></poml>`,
+		`<poml><task>{{ xs[ }}<b for="x in xs">{{ x.y() }}</b></task></poml>`,
+	} {
+		doc, err := ParseString(src)
+		if err != nil {
+			t.Fatalf("parse %s: %v", src, err)
+		}
+		_, err = doc.ExpandControlFlow(map[string]any{"xs": []any{1}})
+		var pe *POMLError
+		if !errors.As(err, &pe) || pe.Code != CodeControlFlow {
+			t.Fatalf("%s: want a POML-CONTROL-FLOW error, got %v", src, err)
+		}
+	}
+}
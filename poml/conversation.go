@@ -0,0 +1,127 @@
+package poml
+
+import "strings"
+
+// Conversation is the threaded view of a document's chat elements returned by
+// Document.Conversation.
+type Conversation struct {
+	Turns      []Turn        // messages and tool-call groups in document order
+	Unanswered []ToolCall    // tool requests with no response, result, or error
+	Orphans    []ToolOutcome // outcomes whose id matches no earlier tool-request
+}
+
+// Turn is one entry of a Conversation. A message turn has Message set; an assistant message
+// directly followed by tool requests carries them in Calls, as converters send them in one
+// message. Tool requests with no assistant message before them form a call turn of their own,
+// and an outcome with no matching request is kept in place as an Orphan turn with Role "tool".
+type Turn struct {
+	Element Element      // the message, or the first tool-request of a call turn, or the orphan
+	Role    string       // "system", "user", "assistant", "tool", or a <msg> element's own role
+	Message *Message     // nil for call and orphan turns
+	Calls   []ToolCall   // tool requests issued in this turn, each with its outcomes
+	Orphan  *ToolOutcome // set for orphan turns
+}
+
+// ToolCall pairs a tool request with the responses, results, and errors answering it.
+type ToolCall struct {
+	Element  Element
+	Request  ToolRequest
+	Outcomes []ToolOutcome // in document order
+}
+
+// Answered reports whether the call received at least one outcome.
+func (c ToolCall) Answered() bool { return len(c.Outcomes) > 0 }
+
+// ToolOutcome is a <tool-response>, <tool-result>, or <tool-error>.
+type ToolOutcome struct {
+	Element Element
+	ID      string
+	Name    string
+	Body    string // trimmed
+}
+
+// IsError reports whether the outcome is a <tool-error>.
+func (o ToolOutcome) IsError() bool { return o.Element.Type == ElementToolError }
+
+// Conversation returns the document's messages with tool requests grouped into the turn that
+// issued them and each outcome attached to the request with its id. Outcomes are matched to
+// the latest earlier request with that id; ones that answer nothing are reported as Orphans,
+// and requests never answered as Unanswered. Non-chat elements (role, task, hints, media) are
+// not part of the view.
+func (d Document) Conversation() Conversation {
+	var conv Conversation
+	calls := map[string][2]int{} // request id -> turn and call index
+	grouping := false            // whether a tool request may join the last turn
+	for _, el := range d.resolveOrder() {
+		switch el.Type {
+		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+			if el.Index < 0 || el.Index >= len(d.Messages) {
+				continue
+			}
+			role := "user"
+			switch el.Type {
+			case ElementAssistantMsg:
+				role = "assistant"
+			case ElementSystemMsg:
+				role = "system"
+			case ElementMsg:
+				role = d.Messages[el.Index].Role
+			}
+			conv.Turns = append(conv.Turns, Turn{Element: el, Role: role, Message: &d.Messages[el.Index]})
+			grouping = role == "assistant"
+		case ElementToolRequest:
+			if el.Index < 0 || el.Index >= len(d.ToolReqs) {
+				continue
+			}
+			if !grouping {
+				conv.Turns = append(conv.Turns, Turn{Element: el, Role: "assistant"})
+				grouping = true
+			}
+			t := len(conv.Turns) - 1
+			req := d.ToolReqs[el.Index]
+			conv.Turns[t].Calls = append(conv.Turns[t].Calls, ToolCall{Element: el, Request: req})
+			if id := strings.TrimSpace(req.ID); id != "" {
+				calls[id] = [2]int{t, len(conv.Turns[t].Calls) - 1}
+			}
+		case ElementToolResponse, ElementToolResult, ElementToolError:
+			out, ok := d.toolOutcome(el)
+			if !ok {
+				continue
+			}
+			grouping = false
+			if at, ok := calls[strings.TrimSpace(out.ID)]; ok {
+				call := &conv.Turns[at[0]].Calls[at[1]]
+				call.Outcomes = append(call.Outcomes, out)
+				continue
+			}
+			conv.Turns = append(conv.Turns, Turn{Element: el, Role: "tool", Orphan: &out})
+			conv.Orphans = append(conv.Orphans, out)
+		}
+	}
+	for _, t := range conv.Turns {
+		for _, c := range t.Calls {
+			if !c.Answered() {
+				conv.Unanswered = append(conv.Unanswered, c)
+			}
+		}
+	}
+	return conv
+}
+
+func (d Document) toolOutcome(el Element) (ToolOutcome, bool) {
+	var id, name, body string
+	switch {
+	case el.Type == ElementToolResponse && el.Index >= 0 && el.Index < len(d.ToolResps):
+		r := d.ToolResps[el.Index]
+		id, name, body = r.ID, r.Name, r.Body
+	case el.Type == ElementToolResult && el.Index >= 0 && el.Index < len(d.ToolResults):
+		r := d.ToolResults[el.Index]
+		id, name, body = r.ID, r.Name, r.Body
+	case el.Type == ElementToolError && el.Index >= 0 && el.Index < len(d.ToolErrors):
+		r := d.ToolErrors[el.Index]
+		id, name, body = r.ID, r.Name, r.Body
+	default:
+		return ToolOutcome{}, false
+	}
+	return ToolOutcome{Element: el, ID: id, Name: name, Body: strings.TrimSpace(body)}, true
+}
@@ -0,0 +1,126 @@
+package poml
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ToolCallSpec is a tool call issued by AppendAssistantTurn.
+type ToolCallSpec struct {
+	ID   string // empty generates an id unused in the document, "call_N"
+	Name string
+	// Args becomes the request's parameters: a string, []byte, or json.RawMessage is taken as
+	// JSON text, nil as {}, and any other value is marshaled.
+	Args any
+}
+
+// AppendUserTurn appends text as a <human-msg> and returns its element. text is plain text;
+// markup characters are escaped so it reads back literally.
+func (d *Document) AppendUserTurn(text string) Element {
+	d.AddMessage("user", escapeBodyText(text))
+	return d.Elements[len(d.Elements)-1]
+}
+
+// AppendAssistantTurn appends an <assistant-msg> with text followed by a <tool-request> for each call,
+// which Conversation and the converters treat as one assistant turn, and returns the calls' ids
+// in order. With calls, an empty text adds only the requests. A call without a name
+// (CodeToolName) or with an id the document already uses (CodeToolReqDuplicate), or Args that
+// cannot be marshaled, fails and leaves the document unchanged.
+func (d *Document) AppendAssistantTurn(text string, calls ...ToolCallSpec) ([]string, error) {
+	used := map[string]bool{}
+	for _, tr := range d.ToolReqs {
+		used[strings.TrimSpace(tr.ID)] = true
+	}
+	for _, id := range d.toolOutcomeIDs() {
+		used[id] = true
+	}
+	ids := make([]string, len(calls))
+	params := make([]string, len(calls))
+	for i, call := range calls {
+		if strings.TrimSpace(call.Name) == "" {
+			return nil, &POMLError{Type: ErrValidate, Code: CodeToolName, Message: fmt.Sprintf("append assistant turn: tool call %d has no name", i)}
+		}
+		id := strings.TrimSpace(call.ID)
+		if id != "" && used[id] {
+			return nil, &POMLError{Type: ErrValidate, Code: CodeToolReqDuplicate, Message: fmt.Sprintf("append assistant turn: tool call id %q is already used", id)}
+		}
+		p, err := toolCallParams(call.Args)
+		if err != nil {
+			return nil, fmt.Errorf("append assistant turn: arguments of %s: %w", call.Name, err)
+		}
+		ids[i], params[i] = id, p
+		if id != "" {
+			used[id] = true
+		}
+	}
+	next := len(d.ToolReqs) + 1
+	for i := range ids {
+		for ids[i] == "" {
+			if id := "call_" + strconv.Itoa(next); !used[id] {
+				ids[i] = id
+				used[id] = true
+			}
+			next++
+		}
+	}
+	if text != "" || len(calls) == 0 {
+		d.AddMessage("assistant", escapeBodyText(text))
+	}
+	for i, call := range calls {
+		d.AddToolRequest(ids[i], call.Name, params[i])
+	}
+	return ids, nil
+}
+
+// AppendToolResult appends a <tool-result> answering the tool request with callID, named after
+// it, with body as plain text. It fails with CodeToolReqRef when no request has that id, since
+// the result would answer nothing.
+func (d *Document) AppendToolResult(callID, body string) error {
+	id := strings.TrimSpace(callID)
+	name, found := "", false
+	for _, tr := range d.ToolReqs {
+		if id != "" && strings.TrimSpace(tr.ID) == id {
+			name, found = tr.Name, true // the latest request with the id, as Conversation pairs them
+		}
+	}
+	if !found {
+		return &POMLError{Type: ErrValidate, Code: CodeToolReqRef, Message: fmt.Sprintf("append tool result: no tool request with id %q", callID)}
+	}
+	d.AddToolResult(id, name, escapeBodyText(body))
+	return nil
+}
+
+// toolOutcomeIDs returns the ids of every response, result, and error, trimmed.
+func (d Document) toolOutcomeIDs() []string {
+	var ids []string
+	for _, r := range d.ToolResps {
+		ids = append(ids, strings.TrimSpace(r.ID))
+	}
+	for _, r := range d.ToolResults {
+		ids = append(ids, strings.TrimSpace(r.ID))
+	}
+	for _, r := range d.ToolErrors {
+		ids = append(ids, strings.TrimSpace(r.ID))
+	}
+	return ids
+}
+
+func toolCallParams(args any) (string, error) {
+	switch v := args.(type) {
+	case nil:
+		return "{}", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case json.RawMessage:
+		return string(v), nil
+	}
+	b, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
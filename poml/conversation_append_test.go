@@ -0,0 +1,80 @@
+package poml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAppendTurnsBuildsValidTrace(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <human-msg>Hi</human-msg>
+  <tool-request id="call_2" name="weather" parameters="{}"/>
+  <tool-result id="call_2" name="weather">cloudy</tool-result>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	doc.AppendUserTurn("Is 1 < 2 & 3?")
+	ids, err := doc.AppendAssistantTurn("Checking.",
+		ToolCallSpec{Name: "compare", Args: map[string]int{"a": 1, "b": 2}},
+		ToolCallSpec{Name: "weather", Args: `{"city":"Oslo"}`},
+		ToolCallSpec{ID: "mine", Name: "noop"})
+	if err != nil {
+		t.Fatalf("append assistant turn: %v", err)
+	}
+	if strings.Join(ids, ",") != "call_3,call_4,mine" {
+		t.Fatalf("generated ids should skip call_2: %v", ids)
+	}
+	for _, id := range ids {
+		if err := doc.AppendToolResult(id, "ok <"+id+">"); err != nil {
+			t.Fatalf("append result %s: %v", id, err)
+		}
+	}
+
+	var buf strings.Builder
+	if err := doc.Encode(&buf); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	back, err := ParseString(buf.String())
+	if err != nil {
+		t.Fatalf("reparse: %v\n%s", err, buf.String())
+	}
+	conv := back.Conversation()
+	if len(conv.Unanswered) != 0 || len(conv.Orphans) != 0 {
+		t.Fatalf("trace should be fully paired: %+v", conv)
+	}
+	last := conv.Turns[len(conv.Turns)-1]
+	if last.Role != "assistant" || last.Message == nil || len(last.Calls) != 3 {
+		t.Fatalf("assistant text and calls should form one turn: %+v", last)
+	}
+	if c := last.Calls[0]; c.Request.Parameters != `{"a":1,"b":2}` || c.Outcomes[0].Body != "ok &lt;call_3&gt;" || c.Outcomes[0].Name != "compare" {
+		t.Fatalf("compare call = %+v", c)
+	}
+	if user := conv.Turns[len(conv.Turns)-2]; user.Role != "user" || user.Message.Body != "Is 1 &lt; 2 &amp; 3?" {
+		t.Fatalf("user turn = %+v", user.Message)
+	}
+}
+
+func TestAppendTurnsRejectBadReferences(t *testing.T) {
+	var doc Document
+	if _, err := doc.AppendAssistantTurn("", ToolCallSpec{ID: "a", Name: "x"}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if len(doc.Messages) != 0 || len(doc.ToolReqs) != 1 {
+		t.Fatalf("empty text with calls should add only requests: %+v", doc.Elements)
+	}
+	before := len(doc.Elements)
+	if _, err := doc.AppendAssistantTurn("again", ToolCallSpec{Name: "y"}, ToolCallSpec{ID: "a", Name: "x"}); !errors.Is(err, CodeToolReqDuplicate) {
+		t.Fatalf("reused id: got %v", err)
+	}
+	if _, err := doc.AppendAssistantTurn("", ToolCallSpec{Args: 1}); !errors.Is(err, CodeToolName) {
+		t.Fatalf("nameless call: got %v", err)
+	}
+	if err := doc.AppendToolResult("nope", "x"); !errors.Is(err, CodeToolReqRef) {
+		t.Fatalf("unknown call id: got %v", err)
+	}
+	if len(doc.Elements) != before {
+		t.Fatalf("failed appends changed the document: %+v", doc.Elements)
+	}
+}
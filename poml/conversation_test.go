@@ -0,0 +1,49 @@
+package poml
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestConversationPairsToolCalls(t *testing.T) {
+	src := `<poml>
+  <system-msg>Be brief.</system-msg>
+  <human-msg>Weather in Oslo and Rome?</human-msg>
+  <assistant-msg>Checking.</assistant-msg>
+  <tool-request id="c1" name="weather" parameters='{"city":"Oslo"}'/>
+  <tool-request id="c2" name="weather" parameters='{"city":"Rome"}'/>
+  <tool-result id="c2" name="weather">sunny</tool-result>
+  <tool-error id="c9" name="weather">no such call</tool-error>
+  <tool-request id="c3" name="forecast"/>
+  <tool-response id="c3" name="forecast">rain</tool-response>
+  <assistant-msg>Rome is sunny.</assistant-msg>
+</poml>`
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	conv := doc.Conversation()
+	var roles []string
+	for _, turn := range conv.Turns {
+		roles = append(roles, turn.Role)
+	}
+	if got := fmt.Sprint(roles); got != "[system user assistant tool assistant assistant]" {
+		t.Fatalf("turn roles = %s", got)
+	}
+	calls := conv.Turns[2].Calls
+	if conv.Turns[2].Message == nil || len(calls) != 2 || calls[0].Request.ID != "c1" || !calls[1].Answered() {
+		t.Fatalf("assistant turn did not group its calls: %+v", conv.Turns[2])
+	}
+	if out := calls[1].Outcomes[0]; out.Body != "sunny" || out.IsError() || out.Element.Type != ElementToolResult {
+		t.Fatalf("c2 outcome = %+v", out)
+	}
+	if turn := conv.Turns[4]; turn.Message != nil || len(turn.Calls) != 1 || turn.Calls[0].Outcomes[0].Body != "rain" {
+		t.Fatalf("call turn after an outcome should stand alone: %+v", turn)
+	}
+	if len(conv.Unanswered) != 1 || conv.Unanswered[0].Request.ID != "c1" {
+		t.Fatalf("unanswered = %+v", conv.Unanswered)
+	}
+	if len(conv.Orphans) != 1 || conv.Orphans[0].ID != "c9" || !conv.Orphans[0].IsError() || conv.Turns[3].Orphan == nil {
+		t.Fatalf("orphans = %+v", conv.Orphans)
+	}
+}
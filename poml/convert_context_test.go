@@ -0,0 +1,62 @@
+package poml
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestConvertContextCancelsAssetReads(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.png":     {Data: []byte("png")},
+		"notes.txt": {Data: []byte("notes")},
+	}
+	doc, err := ParseString(`<poml><img src="a.png" /><document src="notes.txt" /></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	opts := ConvertOptions{FS: fsys}
+	if _, err := ConvertContext(context.Background(), doc, FormatOpenAIChat, opts); err != nil {
+		t.Fatalf("convert with live context: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ConvertContext(ctx, doc, FormatOpenAIChat, opts); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	docOnly, _ := ParseString(`<poml><document src="notes.txt" /></poml>`)
+	if _, err := Convert(docOnly, FormatMessageDict, ConvertOptions{FS: fsys, ctx: ctx}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected document read to observe cancellation, got %v", err)
+	}
+}
+
+func TestConvertContextDeadlineAbortsRemoteFetch(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	doc, err := ParseString(`<poml><img src="` + srv.URL + `/slow.png" /></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = ConvertContext(ctx, doc, FormatOpenAIChat, ConvertOptions{AssetFetcher: HTTPAssetFetcher{}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("fetch was not aborted promptly (%s)", elapsed)
+	}
+}
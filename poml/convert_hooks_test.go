@@ -0,0 +1,48 @@
+package poml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConvertHooks(t *testing.T) {
+	doc, err := ParseString(`<poml><human-msg>my key is sk-123</human-msg></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var seen []Format
+	opts := ConvertOptions{
+		Before: []func(*Document) error{func(d *Document) error {
+			for i := range d.Messages {
+				d.Messages[i].Body = strings.ReplaceAll(d.Messages[i].Body, "sk-123", "[redacted]")
+			}
+			return nil
+		}},
+		After: []func(Format, any) error{func(format Format, out any) error {
+			seen = append(seen, format)
+			out.(map[string]any)["metadata"] = map[string]any{"trace_id": "t-1"}
+			return nil
+		}},
+	}
+	out, err := Convert(doc, FormatOpenAIChat, opts)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	res := out.(map[string]any)
+	if got := res["messages"].([]map[string]any)[0]["content"]; got != "my key is [redacted]" {
+		t.Fatalf("before hook not applied: %v", got)
+	}
+	if doc.Messages[0].Body != "my key is sk-123" {
+		t.Fatalf("before hook modified the caller's document")
+	}
+	if res["metadata"] == nil || len(seen) != 1 || seen[0] != FormatOpenAIChat {
+		t.Fatalf("after hook not applied: %+v %v", res, seen)
+	}
+
+	boom := errors.New("boom")
+	opts.Before = append(opts.Before, func(*Document) error { return boom })
+	if _, err := Convert(doc, FormatOpenAIChat, opts); !errors.Is(err, boom) || len(seen) != 1 {
+		t.Fatalf("before hook error should abort conversion, got %v", err)
+	}
+}
@@ -1,15 +1,19 @@
 package poml
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -17,17 +21,25 @@ import (
 type Format string
 
 const (
-	FormatMessageDict Format = "message_dict"
-	FormatDict        Format = "dict"
-	FormatOpenAIChat  Format = "openai_chat"
-	FormatLangChain   Format = "langchain"
-	FormatPydantic    Format = "pydantic"
+	FormatMessageDict     Format = "message_dict"
+	FormatDict            Format = "dict"
+	FormatOpenAIChat      Format = "openai_chat"
+	FormatLangChain       Format = "langchain"
+	FormatPydantic        Format = "pydantic"
+	FormatBedrockConverse Format = "bedrock_converse"
+	FormatOllama          Format = "ollama"
+	FormatCohere          Format = "cohere"
+	FormatText            Format = "text"    // a single plain-text prompt string; see TextOptions
+	FormatHFChat          Format = "hf_chat" // Hugging Face chat-template messages; see RenderChatTemplate
+	FormatMistral         Format = "mistral" // openai_chat adjusted to Mistral's stricter tool validation
 )
 
 // ConvertOptions holds knobs for conversion (context, runtime flags, etc.).
 // This will expand when format support is implemented.
 type ConvertOptions struct {
-	// Context may include auxiliary data (e.g., file paths) needed for conversions.
+	// Context holds the variables of if and for attributes and {{ }} expressions. When it is
+	// non-nil (an empty map counts), Convert expands them with Document.ExpandControlFlow after
+	// the Before hooks and before binding inputs; when nil, they are left as written.
 	Context map[string]any
 	// BaseDir is used to resolve relative asset paths (e.g., <img src>).
 	BaseDir string
@@ -35,8 +47,105 @@ type ConvertOptions struct {
 	AllowAbsImagePaths bool
 	// MaxImageBytes caps bytes read before Base64 encoding; zero applies a default cap, negative disables the cap.
 	MaxImageBytes int64
+	// MaxImageWidth and MaxImageHeight, when positive, downscale PNG and JPEG images that exceed
+	// them before they are sent, keeping the aspect ratio, so full-resolution screenshots do not
+	// inflate requests. The image is re-encoded in its own format; other formats, and images over
+	// about 33 megapixels, which would take too much memory to decode, are sent as is.
+	// MediaCache and ConvertBatch keep the original bytes, so the limits may vary per call.
+	MaxImageWidth  int
+	MaxImageHeight int
+	// ImageQuality is the JPEG quality (1-100) for downscaled JPEG images; zero uses 75.
+	ImageQuality int
+	// ImageMode selects how openai_chat, mistral, and langchain send images: inline Base64 (the
+	// default), http(s) sources passed through as URLs, or uploaded through ImageUploader; see
+	// the ImageMode constants. Other formats always inline.
+	ImageMode     ImageMode
+	ImageUploader ImageUploader
 	// MaxMediaBytes caps bytes read for audio/video; zero applies a default cap, negative disables the cap.
 	MaxMediaBytes int64
+	// DocumentResolver fetches <document src> contents not already resolved at parse time.
+	// When nil, unresolved documents are skipped.
+	DocumentResolver DocumentResolver
+	// FS, when non-nil, serves local image/media/document sources instead of the host filesystem
+	// (e.g. an embed.FS prompt bundle). BaseDir is then a directory inside FS.
+	FS fs.FS
+	// AssetFetcher retrieves http(s) image/media/document sources (see HTTPAssetFetcher).
+	// When nil, remote image/media sources are rejected and remote documents are skipped.
+	AssetFetcher AssetFetcher
+	// Text configures section headers and separators for FormatText.
+	Text TextOptions
+	// SchemaName names the OpenAI json_schema response format (default "schema"). Some providers
+	// require a specific name or reject the default.
+	SchemaName string
+	// SchemaStrict sets json_schema.strict for OpenAI; nil means true. Set it to false for
+	// providers that reject strict mode or schemas strict mode cannot express.
+	SchemaStrict *bool
+	// JSONObject emits OpenAI's {"type": "json_object"} response format instead of json_schema,
+	// dropping the schema itself, for providers that only support JSON mode.
+	JSONObject bool
+	// LegacyToolDefinitions restores the old reading of <tool-definition>, where the body doubles
+	// as the description when the description attribute is empty. By default the body is only
+	// the JSON parameters schema and the description attribute is the only human text.
+	LegacyToolDefinitions bool
+	// ToolChoice sets tool_choice for openai_chat and mistral when the document defines tools:
+	// "auto", "none", "required" (or Mistral's "any"), or the name of a tool the model must call.
+	// Empty leaves it to the provider.
+	ToolChoice string
+	// ParallelToolCalls sets parallel_tool_calls for openai_chat and mistral when the document
+	// defines tools; nil omits it.
+	ParallelToolCalls *bool
+	// Inputs binds <input> values by name at convert time: each value replaces the matching
+	// input's body and every {{inputs.NAME}} reference in element bodies ({{inputs.NAME.key}}
+	// reads into map values). Strings are inserted as text, numbers and booleans formatted, and
+	// other values JSON-encoded. A non-nil map also makes a required <input> with neither a value
+	// nor a body fail with CodeInputMissing; references with no value are left as written.
+	Inputs map[string]any
+	// StrictInputs binds inputs even when Inputs is nil and also fails with CodeInputMissing on
+	// {{inputs.NAME}} references that have no value.
+	StrictInputs bool
+	// Profile selects the <runtime profile="..."> entries that apply, for example per
+	// environment. Runtimes without a profile attribute always apply; the selected profile's
+	// entries are merged after them, so their values win. Empty applies only the unprofiled
+	// runtimes.
+	Profile string
+	// SystemPrompt composes role/task/input sections into a leading system message for the chat
+	// converters.
+	SystemPrompt SystemPromptOptions
+	// FlattenExamples sends an <example> of <input> and <output> children as one user message
+	// holding its raw body instead of a user/assistant few-shot pair.
+	FlattenExamples bool
+	// MediaCache, when set, keeps Base64-encoded image/audio/video payloads across conversions
+	// (see NewMemoryMediaCache).
+	MediaCache MediaCache
+	// Before hooks run in order on a private copy of the document before conversion (e.g. to
+	// redact inputs); the caller's document is never modified. An error aborts the conversion.
+	Before []func(doc *Document) error
+	// After hooks run in order on the converted output (e.g. to log or add trace metadata to the
+	// returned map). An error discards the output. With ConvertBatch, hooks run concurrently.
+	After []func(format Format, out any) error
+	// RoleMap renames message roles before conversion, for <msg role="..."> custom roles such as
+	// {"critic": "assistant", "planner": "system"}. Keys are roles as written in the document;
+	// values are "human" (or "user"), "assistant", or "system". Unmapped custom roles are sent as
+	// user messages.
+	RoleMap map[string]string
+	// Parse, when non-nil, replaces the options ConvertString parses with (by default
+	// ParseString's, which preserve whitespace and skip validation). &ParseOptions{Validate: true}
+	// parses without whitespace preservation and validates, for high-volume paths.
+	Parse *ParseOptions
+
+	// ctx is set by ConvertContext and checked before every file, FS, or network read.
+	ctx context.Context
+	// assets is set by ConvertBatch so documents sharing an image or media src read and encode it
+	// once.
+	assets *assetMemo
+}
+
+// readContext returns the context asset reads run under (context.Background outside ConvertContext).
+func (o ConvertOptions) readContext() context.Context {
+	if o.ctx != nil {
+		return o.ctx
+	}
+	return context.Background()
 }
 
 const defaultMaxImageBytes int64 = 10 << 20 // 10MB safeguard
@@ -45,8 +154,62 @@ const defaultMaxMediaBytes int64 = 10 << 20 // 10MB safeguard for audio/video
 // ErrNotImplemented signals that a conversion target is not yet supported.
 var ErrNotImplemented = errors.New("conversion not implemented")
 
-// Convert transforms a parsed Document into the requested format.
+// ConvertContext is Convert with cancellation: image, media, and document reads (local files,
+// ConvertOptions.FS, AssetFetcher, DocumentResolver) stop once ctx is done and the conversion
+// returns an error wrapping ctx.Err(). Fetchers and resolvers that implement
+// ContextAssetFetcher or ContextDocumentResolver receive ctx directly.
+func ConvertContext(ctx context.Context, doc Document, format Format, opts ConvertOptions) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	opts.ctx = ctx
+	return Convert(doc, format, opts)
+}
+
+// Convert transforms a parsed Document into the requested format, running opts.Before and
+// opts.After around the converter. Control flow is expanded (see ConvertOptions.Context) and
+// inputs are bound (see ConvertOptions.Inputs) after the Before hooks.
 func Convert(doc Document, format Format, opts ConvertOptions) (any, error) {
+	owned := len(opts.Before) > 0 // doc is a copy Convert may edit
+	if owned {
+		doc = doc.Clone()
+		for i, hook := range opts.Before {
+			if err := hook(&doc); err != nil {
+				return nil, fmt.Errorf("before hook %d: %w", i, err)
+			}
+		}
+	}
+	if opts.Context != nil {
+		expanded, err := doc.ExpandControlFlow(opts.Context)
+		if err != nil {
+			return nil, err
+		}
+		doc, owned = expanded, true
+	}
+	if opts.Inputs != nil || opts.StrictInputs {
+		if !owned {
+			doc = doc.Clone()
+		}
+		if err := doc.bindInputs(opts); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.RoleMap) > 0 {
+		doc = doc.mapRoles(opts.RoleMap)
+	}
+	out, err := convertFormat(doc, format, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, hook := range opts.After {
+		if err := hook(format, out); err != nil {
+			return nil, fmt.Errorf("after hook %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
+
+func convertFormat(doc Document, format Format, opts ConvertOptions) (any, error) {
 	switch format {
 	case FormatMessageDict:
 		return convertMessageDict(doc, opts)
@@ -58,14 +221,31 @@ func Convert(doc Document, format Format, opts ConvertOptions) (any, error) {
 		return convertOpenAIChat(doc, opts)
 	case FormatLangChain:
 		return convertLangChain(doc, opts)
+	case FormatBedrockConverse:
+		return convertBedrockConverse(doc, opts)
+	case FormatOllama:
+		return convertOllama(doc, opts)
+	case FormatCohere:
+		return convertCohere(doc, opts)
+	case FormatText:
+		return convertText(doc, opts)
+	case FormatHFChat:
+		return convertHFChat(doc, opts)
+	case FormatMistral:
+		return convertMistral(doc, opts)
 	default:
 		return nil, ErrNotImplemented
 	}
 }
 
-// ConvertString parses a POML string and converts it in one step.
+// ConvertString parses a POML string with opts.Parse (ParseString's options when nil) and
+// converts it in one step.
 func ConvertString(body string, format Format, opts ConvertOptions) (any, error) {
-	doc, err := ParseString(body)
+	parseOpts := defaultParseOptions
+	if opts.Parse != nil {
+		parseOpts = *opts.Parse
+	}
+	doc, err := parseWithOptions(strings.NewReader(body), parseOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -81,9 +261,16 @@ func convertMessageDict(doc Document, opts ConvertOptions) ([]messageDict, error
 	var msgs []messageDict
 	for _, el := range doc.resolveOrder() {
 		switch el.Type {
-		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg:
+		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
 			payload := doc.Messages[el.Index]
-			content := strings.TrimSpace(payload.Body)
+			var content any = strings.TrimSpace(payload.Body)
+			parts, ok, err := inlineParts(doc.elementContent(el), messageDictInlinePart(opts))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				content = parts
+			}
 			msgs = append(msgs, messageDict{Speaker: roleToSpeaker(payload.Role), Content: content})
 		case ElementToolResult:
 			payload := doc.ToolResults[el.Index]
@@ -95,21 +282,43 @@ func convertMessageDict(doc Document, opts ConvertOptions) ([]messageDict, error
 			payload := doc.ToolResps[el.Index]
 			msgs = append(msgs, messageDict{Speaker: "tool", Content: strings.TrimSpace(payload.Body)})
 		case ElementHint, ElementExample, ElementContentPart:
+			if pair := doc.examplePair(el, opts); pair != nil {
+				msgs = append(msgs, messageDict{Speaker: "human", Content: pair.Input}, messageDict{Speaker: "assistant", Content: pair.Output})
+				continue
+			}
+			parts, ok, err := inlineParts(doc.elementContent(el), messageDictInlinePart(opts))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				msgs = append(msgs, messageDict{Speaker: "human", Content: parts})
+				continue
+			}
 			body := strings.TrimSpace(doc.elementBody(el))
 			if body != "" {
 				msgs = append(msgs, messageDict{Speaker: "human", Content: body})
 			}
+		case ElementDocument:
+			content, ok, err := doc.documentContent(el, opts)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				msgs = append(msgs, messageDict{Speaker: "human", Content: content})
+			}
 		case ElementObject:
 			obj := doc.Objects[el.Index]
-			msgs = append(msgs, messageDict{
-				Speaker: "human",
-				Content: map[string]any{
-					"type":   "object",
-					"data":   obj.Data,
-					"syntax": obj.Syntax,
-					"body":   strings.TrimSpace(obj.Body),
-				},
-			})
+			content := map[string]any{
+				"type":   "object",
+				"data":   obj.Data,
+				"syntax": obj.Syntax,
+				"body":   strings.TrimSpace(obj.Body),
+			}
+			// Payloads that parse (not, say, an unrendered template) also carry their structure.
+			if v, err := obj.Value(); err == nil {
+				content["value"] = v
+			}
+			msgs = append(msgs, messageDict{Speaker: "human", Content: content})
 		case ElementImage:
 			im := doc.Images[el.Index]
 			part, err := buildImagePart(im, opts)
@@ -137,11 +346,13 @@ func convertMessageDict(doc Document, opts ConvertOptions) ([]messageDict, error
 }
 
 type dictOutput struct {
-	Messages []messageDict  `json:"messages"`
-	Schema   any            `json:"schema,omitempty"`
-	Tools    []any          `json:"tools,omitempty"`
-	Runtime  map[string]any `json:"runtime,omitempty"`
-	Media    []any          `json:"media,omitempty"`
+	Messages  []messageDict     `json:"messages"`
+	Schema    any               `json:"schema,omitempty"`
+	Tools     []any             `json:"tools,omitempty"`
+	Runtime   map[string]any    `json:"runtime,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	ExtraBody map[string]any    `json:"extra_body,omitempty"`
+	Media     []any             `json:"media,omitempty"`
 }
 
 func convertDict(doc Document, opts ConvertOptions) (dictOutput, error) {
@@ -155,12 +366,14 @@ func convertDict(doc Document, opts ConvertOptions) (dictOutput, error) {
 	}
 	if len(doc.ToolDefs) > 0 {
 		for _, td := range doc.ToolDefs {
-			out.Tools = append(out.Tools, buildFlatToolDefinition(td))
+			out.Tools = append(out.Tools, buildFlatToolDefinition(td, opts))
 		}
 	}
-	if rt := collectRuntime(doc); rt != nil {
+	if rt := collectRuntime(doc, opts.Profile); rt != nil {
 		out.Runtime = rt
 	}
+	out.Metadata = doc.TraceMetadata()
+	out.ExtraBody = collectExtraBody(doc, opts.Profile)
 	return out, nil
 }
 
@@ -200,17 +413,45 @@ func collectMedia(doc Document, opts ConvertOptions) []any {
 func convertOpenAIChat(doc Document, opts ConvertOptions) (map[string]any, error) {
 	result := map[string]any{}
 	var messages []map[string]any
+	sys, err := systemPrompt(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	if sys != "" {
+		messages = append(messages, map[string]any{"role": "system", "content": sys})
+	}
 	for _, el := range doc.resolveOrder() {
 		switch el.Type {
-		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg:
+		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
 			payload := doc.Messages[el.Index]
 			role := roleToOpenAI(payload.Role)
-			content := strings.TrimSpace(payload.Body)
+			var content any = strings.TrimSpace(payload.Body)
+			parts, ok, err := inlineParts(doc.elementContent(el), openAIInlinePart(opts))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				content = parts
+			}
 			messages = append(messages, map[string]any{
 				"role":    role,
 				"content": content,
 			})
 		case ElementHint, ElementExample, ElementContentPart:
+			if pair := doc.examplePair(el, opts); pair != nil {
+				messages = append(messages,
+					map[string]any{"role": "user", "content": pair.Input},
+					map[string]any{"role": "assistant", "content": pair.Output})
+				continue
+			}
+			parts, ok, err := inlineParts(doc.elementContent(el), openAIInlinePart(opts))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				messages = append(messages, map[string]any{"role": "user", "content": parts})
+				continue
+			}
 			body := strings.TrimSpace(doc.elementBody(el))
 			if body != "" {
 				messages = append(messages, map[string]any{
@@ -218,6 +459,17 @@ func convertOpenAIChat(doc Document, opts ConvertOptions) (map[string]any, error
 					"content": body,
 				})
 			}
+		case ElementDocument:
+			content, ok, err := doc.documentContent(el, opts)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				messages = append(messages, map[string]any{
+					"role":    "user",
+					"content": content,
+				})
+			}
 		case ElementObject:
 			obj := doc.Objects[el.Index]
 			content := strings.TrimSpace(obj.Body)
@@ -306,7 +558,7 @@ func convertOpenAIChat(doc Document, opts ConvertOptions) (map[string]any, error
 			})
 		case ElementImage:
 			im := doc.Images[el.Index]
-			imgPart, err := buildImagePart(im, opts)
+			src, err := resolveImageSource(im, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -314,34 +566,34 @@ func convertOpenAIChat(doc Document, opts ConvertOptions) (map[string]any, error
 				"role": "user",
 				"content": []any{
 					map[string]any{"type": "text", "text": im.Alt},
-					map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:" + imgPart["type"].(string) + ";base64," + imgPart["base64"].(string)}},
+					map[string]any{"type": "image_url", "image_url": map[string]any{"url": src.dataURL()}},
 				},
 			})
 		}
 	}
 	result["messages"] = messages
 	if doc.hasSchema() {
-		result["response_format"] = map[string]any{
-			"type": "json_schema",
-			"json_schema": map[string]any{
-				"name":   "schema",
-				"schema": parseJSONFallback(doc.Schema.Body),
-				"strict": true,
-			},
-		}
+		result["response_format"] = openAIResponseFormat(doc, opts)
 	}
-	if rt := collectRuntime(doc); rt != nil {
+	if rt := collectRuntime(doc, opts.Profile); rt != nil {
 		for k, v := range rt {
 			result[k] = v
 		}
 	}
+	if md := doc.TraceMetadata(); md != nil {
+		result["metadata"] = md
+	}
+	if extra := collectExtraBody(doc, opts.Profile); extra != nil {
+		result["extra_body"] = extra
+	}
 	if len(doc.ToolDefs) > 0 {
 		var tools []any
 		for _, td := range doc.ToolDefs {
-			tools = append(tools, buildOpenAIToolDefinition(td))
+			tools = append(tools, buildOpenAIToolDefinition(td, opts))
 		}
 		result["tools"] = tools
 	}
+	applyToolChoice(result, opts)
 	return result, nil
 }
 
@@ -364,6 +616,33 @@ func normalizeToolArgsJSON(raw string) string {
 	return body
 }
 
+// toolArgumentsObject parses tool parameters into a JSON value for formats that reject string
+// arguments (Bedrock Converse, Ollama); unparseable parameters are wrapped as {"input": raw}.
+func toolArgumentsObject(raw string) any {
+	body := normalizeToolArgs(raw)
+	if body == "" {
+		return map[string]any{}
+	}
+	if val, ok := parseLooseJSONValue(body); ok {
+		return val
+	}
+	return map[string]any{"input": body}
+}
+
+// Arguments returns the request's parameters as compact JSON, accepting what the converters
+// accept: a {{ ... }} wrapper, single quotes, and bare keys. Empty parameters are {}.
+func (t ToolRequest) Arguments() (json.RawMessage, error) {
+	body := normalizeToolArgs(t.Parameters)
+	if body == "" {
+		return json.RawMessage("{}"), nil
+	}
+	val, ok := parseLooseJSONValue(body)
+	if !ok {
+		return nil, fmt.Errorf("tool request %s: parameters are not JSON: %q", t.ID, body)
+	}
+	return json.Marshal(val)
+}
+
 var bareKeyRe = regexp.MustCompile(`([{\s,])([A-Za-z0-9_\-]+)\s*:`)
 
 func parseLooseJSON(body string) any {
@@ -428,15 +707,44 @@ func parseRuntimeValue(val string) any {
 
 func convertLangChain(doc Document, opts ConvertOptions) (map[string]any, error) {
 	var messages []map[string]any
+	sys, err := systemPrompt(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	if sys != "" {
+		messages = append(messages, map[string]any{"type": "system", "data": map[string]any{"content": sys}})
+	}
 	for _, el := range doc.resolveOrder() {
 		switch el.Type {
-		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg:
+		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
 			msg := doc.Messages[el.Index]
+			var content any = strings.TrimSpace(msg.Body)
+			parts, ok, err := inlineParts(doc.elementContent(el), langChainInlinePart(opts))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				content = parts
+			}
 			messages = append(messages, map[string]any{
 				"type": roleToLangChain(msg.Role),
-				"data": map[string]any{"content": strings.TrimSpace(msg.Body)},
+				"data": map[string]any{"content": content},
 			})
 		case ElementHint, ElementExample, ElementContentPart:
+			if pair := doc.examplePair(el, opts); pair != nil {
+				messages = append(messages,
+					map[string]any{"type": "human", "data": map[string]any{"content": pair.Input}},
+					map[string]any{"type": "ai", "data": map[string]any{"content": pair.Output}})
+				continue
+			}
+			parts, ok, err := inlineParts(doc.elementContent(el), langChainInlinePart(opts))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				messages = append(messages, map[string]any{"type": "human", "data": map[string]any{"content": parts}})
+				continue
+			}
 			body := strings.TrimSpace(doc.elementBody(el))
 			if body != "" {
 				messages = append(messages, map[string]any{
@@ -472,6 +780,17 @@ func convertLangChain(doc Document, opts ConvertOptions) (map[string]any, error)
 					},
 				},
 			})
+		case ElementDocument:
+			content, ok, err := doc.documentContent(el, opts)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				messages = append(messages, map[string]any{
+					"type": "human",
+					"data": map[string]any{"content": content},
+				})
+			}
 		case ElementObject:
 			obj := doc.Objects[el.Index]
 			content := strings.TrimSpace(obj.Body)
@@ -538,7 +857,7 @@ func convertLangChain(doc Document, opts ConvertOptions) (map[string]any, error)
 			})
 		case ElementImage:
 			im := doc.Images[el.Index]
-			part, err := buildImagePart(im, opts)
+			src, err := resolveImageSource(im, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -546,7 +865,7 @@ func convertLangChain(doc Document, opts ConvertOptions) (map[string]any, error)
 				"type": "human",
 				"data": map[string]any{
 					"content": []any{
-						map[string]any{"type": "image", "source_type": "base64", "mime_type": part["type"], "data": part["base64"]},
+						langChainImageBlock(src),
 					},
 				},
 			})
@@ -559,24 +878,35 @@ func convertLangChain(doc Document, opts ConvertOptions) (map[string]any, error)
 	if len(doc.ToolDefs) > 0 {
 		var tools []any
 		for _, td := range doc.ToolDefs {
-			tools = append(tools, buildFlatToolDefinition(td))
+			tools = append(tools, buildFlatToolDefinition(td, opts))
 		}
 		out["tools"] = tools
 	}
-	if rt := collectRuntime(doc); rt != nil {
+	if rt := collectRuntime(doc, opts.Profile); rt != nil {
 		out["runtime"] = rt
 	}
+	if md := doc.TraceMetadata(); md != nil {
+		out["metadata"] = md
+	}
+	if extra := collectExtraBody(doc, opts.Profile); extra != nil {
+		out["extra_body"] = extra
+	}
 	return out, nil
 }
 
-func collectRuntime(doc Document) map[string]any {
+// collectRuntime merges the <runtime> entries that apply under profile (see
+// ConvertOptions.Profile) into one parameter map; later entries override earlier ones.
+func collectRuntime(doc Document, profile string) map[string]any {
 	if len(doc.Runtimes) == 0 {
 		return nil
 	}
 	rt := make(map[string]any)
-	for _, runtime := range doc.Runtimes {
-		for _, attr := range runtime.Attrs {
+	for _, i := range doc.activeRuntimes(profile) {
+		for _, attr := range doc.Runtimes[i].Attrs {
 			key := normalizeRuntimeKey(attr.Name.Local)
+			if _, vendor := vendorParam(attr.Name.Local); vendor || key == "profile" {
+				continue
+			}
 			rt[key] = parseRuntimeValue(attr.Value)
 		}
 	}
@@ -586,6 +916,23 @@ func collectRuntime(doc Document) map[string]any {
 	return rt
 }
 
+// collectExtraBody gathers the x- prefixed <runtime> attributes that apply under profile, keyed
+// by the name after the prefix, with the same precedence and value parsing as collectRuntime.
+func collectExtraBody(doc Document, profile string) map[string]any {
+	extra := make(map[string]any)
+	for _, i := range doc.activeRuntimes(profile) {
+		for _, attr := range doc.Runtimes[i].Attrs {
+			if param, ok := vendorParam(attr.Name.Local); ok {
+				extra[param] = parseRuntimeValue(attr.Value)
+			}
+		}
+	}
+	if len(extra) == 0 {
+		return nil
+	}
+	return extra
+}
+
 func buildImagePart(im Image, opts ConvertOptions) (map[string]any, error) {
 	limit := opts.MaxImageBytes
 	if limit == 0 {
@@ -600,15 +947,14 @@ func buildImagePart(im Image, opts ConvertOptions) (map[string]any, error) {
 			data = payload
 		}
 	case im.Src != "":
-		src, err := resolveImagePath(im.Src, opts)
+		payload, contentType, err := loadAsset(im.Src, limit, opts, "image")
 		if err != nil {
 			return nil, err
 		}
-		bytes, err := readFileWithLimit(src, limit)
-		if err != nil {
-			return nil, fmt.Errorf("read image %s: %w", src, err)
+		data = payload
+		if im.Syntax == "" && strings.HasPrefix(contentType, "image/") {
+			im.Syntax = contentType
 		}
-		data = base64.StdEncoding.EncodeToString(bytes)
 	case im.Body != "":
 		body := []byte(im.Body)
 		if err := enforceByteLimit(int64(len(body)), limit, "inline image body"); err != nil {
@@ -623,6 +969,7 @@ func buildImagePart(im Image, opts ConvertOptions) (map[string]any, error) {
 	if mime == "" {
 		mime = "image/png"
 	}
+	data, mime = downscaleImage(data, mime, opts)
 	return map[string]any{
 		"type":      mime,
 		"mime":      mime,
@@ -649,15 +996,14 @@ func buildMediaPart(m Media, opts ConvertOptions) (map[string]any, error) {
 			data = payload
 		}
 	case m.Src != "":
-		src, err := resolveMediaPath(m.Src, opts)
+		payload, contentType, err := loadAsset(m.Src, limit, opts, "media")
 		if err != nil {
 			return nil, err
 		}
-		bytes, err := readFileWithLimit(src, limit)
-		if err != nil {
-			return nil, fmt.Errorf("read media %s: %w", src, err)
+		data = payload
+		if m.Syntax == "" && contentType != "" {
+			m.Syntax = contentType
 		}
-		data = base64.StdEncoding.EncodeToString(bytes)
 	case m.Body != "":
 		body := []byte(m.Body)
 		if err := enforceByteLimit(int64(len(body)), limit, "inline media body"); err != nil {
@@ -681,6 +1027,51 @@ func buildMediaPart(m Media, opts ConvertOptions) (map[string]any, error) {
 	}, nil
 }
 
+// loadAsset reads a remote or local src and returns it Base64-encoded, along with the content type
+// a remote fetch reported. kind labels errors ("image" or "media").
+func loadAsset(src string, limit int64, opts ConvertOptions, kind string) (string, string, error) {
+	read := func() (string, string, error) {
+		if opts.MediaCache != nil {
+			return readAssetCached(src, limit, opts, kind)
+		}
+		return readAsset(src, limit, opts, kind)
+	}
+	if opts.assets == nil {
+		return read()
+	}
+	return opts.assets.load(kind+"\x00"+src, read)
+}
+
+func readAsset(src string, limit int64, opts ConvertOptions, kind string) (string, string, error) {
+	if isRemoteSrc(src) {
+		raw, contentType, err := fetchRemoteAsset(src, limit, opts)
+		if err != nil {
+			return "", "", err
+		}
+		return base64.StdEncoding.EncodeToString(raw), contentType, nil
+	}
+	raw, err := readLocalAsset(src, limit, opts, kind)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), "", nil
+}
+
+// fetchRemoteAsset reads an http(s) source through opts.AssetFetcher.
+func fetchRemoteAsset(src string, limit int64, opts ConvertOptions) ([]byte, string, error) {
+	if opts.AssetFetcher == nil {
+		return nil, "", fmt.Errorf("remote source %s requires ConvertOptions.AssetFetcher", src)
+	}
+	ctx := opts.readContext()
+	if err := ctx.Err(); err != nil {
+		return nil, "", fmt.Errorf("fetch asset %s: %w", src, err)
+	}
+	if f, ok := opts.AssetFetcher.(ContextAssetFetcher); ok {
+		return f.FetchAssetContext(ctx, src, limit)
+	}
+	return opts.AssetFetcher.FetchAsset(src, limit)
+}
+
 func resolveImagePath(raw string, opts ConvertOptions) (string, error) {
 	cleaned := filepath.Clean(raw)
 	base := strings.TrimSpace(opts.BaseDir)
@@ -725,32 +1116,70 @@ func resolveImagePath(raw string, opts ConvertOptions) (string, error) {
 	return candidate, nil
 }
 
-func resolveMediaPath(raw string, opts ConvertOptions) (string, error) {
-	return resolveImagePath(raw, opts)
-}
-
-func readFileWithLimit(path string, limit int64) ([]byte, error) {
-	if limit <= 0 {
-		return os.ReadFile(path)
+// readLocalAsset reads a non-remote src from opts.FS when set, otherwise from disk under the
+// BaseDir/absolute-path rules. kind labels errors ("image" or "media").
+func readLocalAsset(raw string, limit int64, opts ConvertOptions, kind string) ([]byte, error) {
+	ctx := opts.readContext()
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("read %s %s: %w", kind, raw, err)
+	}
+	if opts.FS != nil {
+		name, err := fsAssetPath(raw, opts.BaseDir)
+		if err != nil {
+			return nil, err
+		}
+		f, err := opts.FS.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("read %s %s: %w", kind, raw, err)
+		}
+		defer f.Close()
+		data, err := readAllWithLimit(ctx, f, limit, raw)
+		if err != nil {
+			return nil, fmt.Errorf("read %s %s: %w", kind, raw, err)
+		}
+		return data, nil
 	}
-	f, err := os.Open(path)
+	if err := hostFileAccess(); err != nil {
+		return nil, fmt.Errorf("read %s %s: %w", kind, raw, err)
+	}
+	src, err := resolveImagePath(raw, opts)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	info, err := f.Stat()
-	if err == nil && info.Size() > limit {
-		return nil, fmt.Errorf("file %s exceeds max size %d bytes", path, limit)
+	data, err := readFileWithLimit(ctx, src, limit)
+	if err != nil {
+		return nil, fmt.Errorf("read %s %s: %w", kind, src, err)
 	}
-	r := io.LimitReader(f, limit+1)
-	data, err := io.ReadAll(r)
+	return data, nil
+}
+
+// fsAssetPath maps a src onto an fs.FS name: file:// and leading slashes are dropped and
+// relative paths join BaseDir. Paths escaping the FS root are rejected.
+func fsAssetPath(raw, baseDir string) (string, error) {
+	src := strings.TrimPrefix(strings.TrimSpace(raw), "file://")
+	src = filepath.ToSlash(src)
+	if !strings.HasPrefix(src, "/") && strings.TrimSpace(baseDir) != "" {
+		src = path.Join(filepath.ToSlash(strings.TrimSpace(baseDir)), src)
+	}
+	name := path.Clean(strings.TrimPrefix(src, "/"))
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("asset path %s escapes the FS root", raw)
+	}
+	return name, nil
+}
+
+func readFileWithLimit(ctx context.Context, path string, limit int64) ([]byte, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	if int64(len(data)) > limit {
-		return nil, fmt.Errorf("file %s exceeds max size %d bytes", path, limit)
+	defer f.Close()
+	if limit > 0 {
+		if info, err := f.Stat(); err == nil && info.Size() > limit {
+			return nil, fmt.Errorf("file %s exceeds max size %d bytes", path, limit)
+		}
 	}
-	return data, nil
+	return readAllWithLimit(ctx, f, limit, path)
 }
 
 func enforceByteLimit(size int64, limit int64, label string) error {
@@ -824,6 +1253,30 @@ func guessMediaMime(path string) string {
 	return "application/octet-stream"
 }
 
+// openAIResponseFormat builds response_format for <output-schema> per the Schema* and JSONObject
+// options.
+func openAIResponseFormat(doc Document, opts ConvertOptions) map[string]any {
+	if opts.JSONObject {
+		return map[string]any{"type": "json_object"}
+	}
+	name := opts.SchemaName
+	if name == "" {
+		name = "schema"
+	}
+	strict := true
+	if opts.SchemaStrict != nil {
+		strict = *opts.SchemaStrict
+	}
+	return map[string]any{
+		"type": "json_schema",
+		"json_schema": map[string]any{
+			"name":   name,
+			"schema": parseJSONFallback(doc.Schema.Body),
+			"strict": strict,
+		},
+	}
+}
+
 func parseJSONFallback(body string) any {
 	var out any
 	if err := json.Unmarshal([]byte(strings.TrimSpace(body)), &out); err != nil {
@@ -885,9 +1338,21 @@ func attrsToMap(attrs []xml.Attr) map[string]string {
 	return res
 }
 
+// mapRoles returns d with message roles renamed through roles. Messages is copied, so the
+// caller's document is unchanged.
+func (d Document) mapRoles(roles map[string]string) Document {
+	d.Messages = slices.Clone(d.Messages)
+	for i, msg := range d.Messages {
+		if to, ok := roles[msg.Role]; ok {
+			d.Messages[i].Role = to
+		}
+	}
+	return d
+}
+
 func roleToSpeaker(role string) string {
 	switch role {
-	case "assistant":
+	case "assistant", "ai":
 		return "assistant"
 	case "system":
 		return "system"
@@ -898,7 +1363,7 @@ func roleToSpeaker(role string) string {
 
 func roleToOpenAI(role string) string {
 	switch role {
-	case "assistant":
+	case "assistant", "ai":
 		return "assistant"
 	case "system":
 		return "system"
@@ -909,7 +1374,7 @@ func roleToOpenAI(role string) string {
 
 func roleToLangChain(role string) string {
 	switch role {
-	case "assistant":
+	case "assistant", "ai":
 		return "ai"
 	case "system":
 		return "system"
@@ -918,12 +1383,22 @@ func roleToLangChain(role string) string {
 	}
 }
 
-func buildFlatToolDefinition(td ToolDefinition) map[string]any {
+// toolDefinitionParts splits a tool definition into its description (the description attribute)
+// and parameters (the body, when it is a JSON object or array). With
+// ConvertOptions.LegacyToolDefinitions the body also stands in for an empty description, as it
+// did before the two were separated.
+func toolDefinitionParts(td ToolDefinition, opts ConvertOptions) (string, any, bool) {
 	desc := stripCDATA(strings.TrimSpace(td.Description))
 	body := stripCDATA(strings.TrimSpace(td.Body))
-	if desc == "" {
+	if desc == "" && opts.LegacyToolDefinitions {
 		desc = body
 	}
+	params, ok := parseJSONIfStruct(body)
+	return desc, params, ok
+}
+
+func buildFlatToolDefinition(td ToolDefinition, opts ConvertOptions) map[string]any {
+	desc, params, hasParams := toolDefinitionParts(td, opts)
 	tool := map[string]any{
 		"type": "function",
 		"name": td.Name,
@@ -931,7 +1406,7 @@ func buildFlatToolDefinition(td ToolDefinition) map[string]any {
 	if desc != "" {
 		tool["description"] = desc
 	}
-	if params, ok := parseJSONIfStruct(body); ok {
+	if hasParams {
 		tool["parameters"] = params
 	}
 	if len(td.Attrs) > 0 {
@@ -940,19 +1415,15 @@ func buildFlatToolDefinition(td ToolDefinition) map[string]any {
 	return tool
 }
 
-func buildOpenAIToolDefinition(td ToolDefinition) map[string]any {
-	desc := stripCDATA(strings.TrimSpace(td.Description))
-	body := stripCDATA(strings.TrimSpace(td.Body))
-	if desc == "" {
-		desc = body
-	}
+func buildOpenAIToolDefinition(td ToolDefinition, opts ConvertOptions) map[string]any {
+	desc, params, hasParams := toolDefinitionParts(td, opts)
 	fn := map[string]any{
 		"name": td.Name,
 	}
 	if desc != "" {
 		fn["description"] = desc
 	}
-	if params, ok := parseJSONIfStruct(body); ok {
+	if hasParams {
 		fn["parameters"] = params
 	}
 	if len(td.Attrs) > 0 {
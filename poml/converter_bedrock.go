@@ -0,0 +1,276 @@
+package poml
+
+import (
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// convertBedrockConverse renders the AWS Bedrock Converse request shape: system prompts go to a
+// top-level "system" list, turns become "messages" of content blocks, tool definitions populate
+// "toolConfig", runtime keys split into "inferenceConfig" and "additionalModelRequestFields"
+// (which also takes x- runtime parameters), and <trace> metadata goes to "requestMetadata".
+// Converse requires alternating roles, so consecutive blocks for the same role share one message.
+// Bedrock has no native response_format, so <output-schema> is not emitted.
+func convertBedrockConverse(doc Document, opts ConvertOptions) (map[string]any, error) {
+	var system []any
+	var messages []map[string]any
+	appendBlocks := func(role string, blocks ...any) {
+		if n := len(messages); n > 0 && messages[n-1]["role"] == role {
+			messages[n-1]["content"] = append(messages[n-1]["content"].([]any), blocks...)
+			return
+		}
+		messages = append(messages, map[string]any{"role": role, "content": blocks})
+	}
+	for _, el := range doc.resolveOrder() {
+		switch el.Type {
+		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+			msg := doc.Messages[el.Index]
+			text := strings.TrimSpace(msg.Body)
+			if msg.Role == "system" {
+				system = append(system, map[string]any{"text": text})
+				continue
+			}
+			blocks, ok, err := inlineParts(doc.elementContent(el), bedrockInlinePart(opts))
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				blocks = []any{map[string]any{"text": text}}
+			}
+			appendBlocks(roleToOpenAI(msg.Role), blocks...)
+		case ElementHint, ElementExample, ElementContentPart:
+			if pair := doc.examplePair(el, opts); pair != nil {
+				appendBlocks("user", map[string]any{"text": pair.Input})
+				appendBlocks("assistant", map[string]any{"text": pair.Output})
+				continue
+			}
+			blocks, ok, err := inlineParts(doc.elementContent(el), bedrockInlinePart(opts))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				appendBlocks("user", blocks...)
+			} else if body := strings.TrimSpace(doc.elementBody(el)); body != "" {
+				appendBlocks("user", map[string]any{"text": body})
+			}
+		case ElementObject:
+			obj := doc.Objects[el.Index]
+			content := strings.TrimSpace(obj.Body)
+			if content == "" {
+				content = strings.TrimSpace(obj.Data)
+			}
+			appendBlocks("user", map[string]any{"text": content})
+		case ElementDocument:
+			content, ok, err := doc.documentContent(el, opts)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				appendBlocks("user", bedrockDocumentBlock(doc.Documents[el.Index].Src, el.Index, content))
+			}
+		case ElementImage:
+			im := doc.Images[el.Index]
+			part, err := buildImagePart(im, opts)
+			if err != nil {
+				return nil, err
+			}
+			var blocks []any
+			if im.Alt != "" {
+				blocks = append(blocks, map[string]any{"text": im.Alt})
+			}
+			blocks = append(blocks, bedrockImageBlock(im, part))
+			appendBlocks("user", blocks...)
+		case ElementVideo:
+			vd := doc.Videos[el.Index]
+			part, err := buildMediaPart(vd, opts)
+			if err != nil {
+				return nil, err
+			}
+			appendBlocks("user", map[string]any{
+				"video": map[string]any{
+					"format": bedrockMediaFormat(dataURIMime(vd.Src, part["mime"].(string))),
+					"source": map[string]any{"bytes": part["base64"]},
+				},
+			})
+		case ElementAudio:
+			return nil, fmt.Errorf("bedrock_converse: audio[%d]: audio content is not supported by Converse", el.Index)
+		case ElementToolRequest:
+			tr := doc.ToolReqs[el.Index]
+			appendBlocks("assistant", map[string]any{
+				"toolUse": map[string]any{
+					"toolUseId": tr.ID,
+					"name":      tr.Name,
+					"input":     toolArgumentsObject(tr.Parameters),
+				},
+			})
+		case ElementToolResponse:
+			resp := doc.ToolResps[el.Index]
+			appendBlocks("user", bedrockToolResultBlock(resp.ID, resp.Body, ""))
+		case ElementToolResult:
+			resp := doc.ToolResults[el.Index]
+			appendBlocks("user", bedrockToolResultBlock(resp.ID, resp.Body, "success"))
+		case ElementToolError:
+			resp := doc.ToolErrors[el.Index]
+			appendBlocks("user", bedrockToolResultBlock(resp.ID, resp.Body, "error"))
+		}
+	}
+
+	result := map[string]any{"messages": messages}
+	if len(system) > 0 {
+		result["system"] = system
+	}
+	if len(doc.ToolDefs) > 0 {
+		var tools []any
+		for _, td := range doc.ToolDefs {
+			flat := buildFlatToolDefinition(td, opts)
+			spec := map[string]any{"name": td.Name}
+			if desc, ok := flat["description"]; ok {
+				spec["description"] = desc
+			}
+			schema, ok := flat["parameters"]
+			if !ok {
+				schema = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+			spec["inputSchema"] = map[string]any{"json": schema}
+			tools = append(tools, map[string]any{"toolSpec": spec})
+		}
+		result["toolConfig"] = map[string]any{"tools": tools}
+	}
+	extra := map[string]any{}
+	if rt := collectRuntime(doc, opts.Profile); rt != nil {
+		inference := map[string]any{}
+		for k, v := range rt {
+			switch k {
+			case "max_tokens":
+				inference["maxTokens"] = v
+			case "temperature":
+				inference["temperature"] = v
+			case "top_p":
+				inference["topP"] = v
+			case "stop", "stop_sequences":
+				if s, ok := v.(string); ok {
+					v = []any{s}
+				}
+				inference["stopSequences"] = v
+			default:
+				extra[k] = v
+			}
+		}
+		if len(inference) > 0 {
+			result["inferenceConfig"] = inference
+		}
+	}
+	maps.Copy(extra, collectExtraBody(doc, opts.Profile))
+	if len(extra) > 0 {
+		result["additionalModelRequestFields"] = extra
+	}
+	if md := doc.TraceMetadata(); md != nil {
+		result["requestMetadata"] = md
+	}
+	return result, nil
+}
+
+func bedrockImageBlock(im Image, part map[string]any) map[string]any {
+	return map[string]any{
+		"image": map[string]any{
+			"format": bedrockMediaFormat(dataURIMime(im.Src, part["mime"].(string))),
+			"source": map[string]any{"bytes": part["base64"]},
+		},
+	}
+}
+
+func bedrockInlinePart(opts ConvertOptions) func(inlineSegment) (any, error) {
+	return func(seg inlineSegment) (any, error) {
+		if seg.Audio != nil {
+			return nil, fmt.Errorf("bedrock_converse: audio content is not supported by Converse")
+		}
+		if seg.Image == nil {
+			return map[string]any{"text": seg.Text}, nil
+		}
+		part, err := buildImagePart(*seg.Image, opts)
+		if err != nil {
+			return nil, err
+		}
+		return bedrockImageBlock(*seg.Image, part), nil
+	}
+}
+
+func bedrockToolResultBlock(id, body, status string) map[string]any {
+	body = strings.TrimSpace(body)
+	content := []any{map[string]any{"text": body}}
+	if val, ok := parseJSONIfStruct(stripCDATA(body)); ok {
+		content = []any{map[string]any{"json": val}}
+	}
+	res := map[string]any{"toolUseId": id, "content": content}
+	if status != "" {
+		res["status"] = status
+	}
+	return map[string]any{"toolResult": res}
+}
+
+var bedrockDocNameRe = regexp.MustCompile(`[^A-Za-z0-9\s\-\(\)\[\]]+`)
+
+// bedrockDocumentBlock wraps resolved document text in a Converse document block. Format comes
+// from the src extension (txt when unknown); name is sanitized to the characters Bedrock accepts.
+func bedrockDocumentBlock(src string, idx int, content string) map[string]any {
+	base := path.Base(strings.TrimPrefix(src, "file://"))
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(base), "."))
+	format := "txt"
+	switch ext {
+	case "pdf", "csv", "doc", "docx", "xls", "xlsx", "html", "txt", "md":
+		format = ext
+	case "htm":
+		format = "html"
+	case "markdown":
+		format = "md"
+	}
+	name := strings.TrimSpace(bedrockDocNameRe.ReplaceAllString(strings.TrimSuffix(base, path.Ext(base)), "-"))
+	if name == "" || name == "." {
+		name = fmt.Sprintf("document-%d", idx)
+	}
+	return map[string]any{
+		"document": map[string]any{
+			"format": format,
+			"name":   name,
+			"source": map[string]any{"bytes": base64.StdEncoding.EncodeToString([]byte(content))},
+		},
+	}
+}
+
+// dataURIMime prefers the MIME type declared in a data: URI over the fallback.
+func dataURIMime(src, fallback string) string {
+	if strings.HasPrefix(src, "data:") {
+		meta := strings.TrimPrefix(strings.SplitN(src, ",", 2)[0], "data:")
+		if mime := strings.TrimSuffix(meta, ";base64"); mime != "" {
+			return mime
+		}
+	}
+	return fallback
+}
+
+// bedrockMediaFormat maps a MIME type to a Converse format name (e.g. image/jpeg -> jpeg).
+func bedrockMediaFormat(mime string) string {
+	sub := mime
+	if i := strings.IndexByte(mime, '/'); i >= 0 {
+		sub = mime[i+1:]
+	}
+	switch sub {
+	case "jpg":
+		return "jpeg"
+	case "quicktime":
+		return "mov"
+	case "x-matroska":
+		return "mkv"
+	case "x-flv":
+		return "flv"
+	case "x-ms-wmv":
+		return "wmv"
+	case "3gpp":
+		return "three_gp"
+	}
+	return sub
+}
@@ -0,0 +1,88 @@
+package poml
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestBedrockConverseShape(t *testing.T) {
+	src := `<poml>
+  <system-msg>Be terse.</system-msg>
+  <human-msg>Weather in Paris?</human-msg>
+  <img src="data:image/jpeg;base64,` + pngData + `" alt="sky" />
+  <tool-definition name="weather" description="Get weather">{"type":"object","properties":{"city":{"type":"string"}}}</tool-definition>
+  <tool-request id="t1" name="weather" parameters="{{ { city: 'Paris' } }}" />
+  <tool-result id="t1" name="weather">{"temp": 21}</tool-result>
+  <tool-error id="t2" name="weather">timeout</tool-error>
+  <runtime max-tokens="256" temperature="0.2" stop="END" seed="7" />
+</poml>`
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	outAny, err := Convert(doc, FormatBedrockConverse, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	out := outAny.(map[string]any)
+	if sys := out["system"].([]any); len(sys) != 1 || sys[0].(map[string]any)["text"] != "Be terse." {
+		t.Fatalf("system mismatch: %+v", out["system"])
+	}
+	msgs := out["messages"].([]map[string]any)
+	if len(msgs) != 3 {
+		t.Fatalf("expected user/assistant/user turns, got %d: %+v", len(msgs), msgs)
+	}
+	user := msgs[0]["content"].([]any)
+	if len(user) != 3 {
+		t.Fatalf("expected text, alt, and image blocks, got %+v", user)
+	}
+	img := user[2].(map[string]any)["image"].(map[string]any)
+	if img["format"] != "jpeg" || img["source"].(map[string]any)["bytes"] != pngData {
+		t.Fatalf("image block mismatch: %+v", img)
+	}
+	use := msgs[1]["content"].([]any)[0].(map[string]any)["toolUse"].(map[string]any)
+	if use["toolUseId"] != "t1" || use["input"].(map[string]any)["city"] != "Paris" {
+		t.Fatalf("toolUse mismatch: %+v", use)
+	}
+	results := msgs[2]["content"].([]any)
+	ok := results[0].(map[string]any)["toolResult"].(map[string]any)
+	if ok["status"] != "success" || ok["content"].([]any)[0].(map[string]any)["json"].(map[string]any)["temp"] != float64(21) {
+		t.Fatalf("toolResult mismatch: %+v", ok)
+	}
+	if bad := results[1].(map[string]any)["toolResult"].(map[string]any); bad["status"] != "error" {
+		t.Fatalf("tool error status mismatch: %+v", bad)
+	}
+	spec := out["toolConfig"].(map[string]any)["tools"].([]any)[0].(map[string]any)["toolSpec"].(map[string]any)
+	if spec["description"] != "Get weather" || spec["inputSchema"].(map[string]any)["json"] == nil {
+		t.Fatalf("toolSpec mismatch: %+v", spec)
+	}
+	inf := out["inferenceConfig"].(map[string]any)
+	if inf["maxTokens"] != 256 || inf["temperature"] != 0.2 || inf["stopSequences"].([]any)[0] != "END" {
+		t.Fatalf("inferenceConfig mismatch: %+v", inf)
+	}
+	if out["additionalModelRequestFields"].(map[string]any)["seed"] != 7 {
+		t.Fatalf("additional fields mismatch: %+v", out["additionalModelRequestFields"])
+	}
+}
+
+func TestBedrockConverseDocumentBlock(t *testing.T) {
+	doc, err := ParseString(`<poml><document src="docs/Q3 report.md" /></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	doc.Documents[0].Content = "# Q3"
+	outAny, err := Convert(doc, FormatBedrockConverse, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	block := outAny.(map[string]any)["messages"].([]map[string]any)[0]["content"].([]any)[0].(map[string]any)["document"].(map[string]any)
+	if block["format"] != "md" || block["name"] != "Q3 report" {
+		t.Fatalf("document block mismatch: %+v", block)
+	}
+	if block["source"].(map[string]any)["bytes"] != base64.StdEncoding.EncodeToString([]byte("# Q3")) {
+		t.Fatalf("document bytes mismatch: %+v", block["source"])
+	}
+	if _, err := ConvertString(`<poml><audio src="data:audio/wav;base64,AAAA" /></poml>`, FormatBedrockConverse, ConvertOptions{}); err == nil {
+		t.Fatalf("expected audio to be rejected")
+	}
+}
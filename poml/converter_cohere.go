@@ -0,0 +1,227 @@
+package poml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// convertCohere renders the Cohere Chat (v1) request shape. System messages join into
+// "preamble", earlier turns become "chat_history" (USER/CHATBOT/TOOL), and the final user turn is
+// lifted into "message". Tool results that follow the last CHATBOT turn are sent as top-level
+// "tool_results", which is how Cohere expects a tool round trip to continue.
+func convertCohere(doc Document, opts ConvertOptions) (map[string]any, error) {
+	var preamble []string
+	var history []map[string]any
+	calls := map[string]map[string]any{} // tool-request id -> call, for tool_results
+	addTurn := func(role, text string) {
+		if text == "" {
+			return
+		}
+		history = append(history, map[string]any{"role": role, "message": text})
+	}
+	addResult := func(id, name string, output map[string]any) {
+		call, ok := calls[id]
+		if !ok {
+			call = map[string]any{"name": name, "parameters": map[string]any{}}
+		}
+		result := map[string]any{"call": call, "outputs": []any{output}}
+		if n := len(history); n > 0 && history[n-1]["role"] == "TOOL" {
+			history[n-1]["tool_results"] = append(history[n-1]["tool_results"].([]any), result)
+			return
+		}
+		history = append(history, map[string]any{"role": "TOOL", "tool_results": []any{result}})
+	}
+	for _, el := range doc.resolveOrder() {
+		switch el.Type {
+		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+			msg := doc.Messages[el.Index]
+			text := strings.TrimSpace(msg.Body)
+			switch roleToOpenAI(msg.Role) {
+			case "system":
+				if text != "" {
+					preamble = append(preamble, text)
+				}
+			case "assistant":
+				addTurn("CHATBOT", text)
+			default:
+				addTurn("USER", text)
+			}
+		case ElementHint, ElementExample, ElementContentPart:
+			if pair := doc.examplePair(el, opts); pair != nil {
+				addTurn("USER", pair.Input)
+				addTurn("CHATBOT", pair.Output)
+				continue
+			}
+			addTurn("USER", strings.TrimSpace(doc.elementBody(el)))
+		case ElementDocument:
+			content, ok, err := doc.documentContent(el, opts)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				addTurn("USER", content)
+			}
+		case ElementObject:
+			obj := doc.Objects[el.Index]
+			content := strings.TrimSpace(obj.Body)
+			if content == "" {
+				content = strings.TrimSpace(obj.Data)
+			}
+			addTurn("USER", content)
+		case ElementImage:
+			return nil, fmt.Errorf("cohere: img[%d]: image content is not supported by Cohere chat", el.Index)
+		case ElementAudio:
+			return nil, fmt.Errorf("cohere: audio[%d]: audio content is not supported by Cohere chat", el.Index)
+		case ElementVideo:
+			return nil, fmt.Errorf("cohere: video[%d]: video content is not supported by Cohere chat", el.Index)
+		case ElementToolRequest:
+			tr := doc.ToolReqs[el.Index]
+			call := map[string]any{"name": tr.Name, "parameters": toolArgumentsObject(tr.Parameters)}
+			if tr.ID != "" {
+				calls[tr.ID] = call
+			}
+			if n := len(history); n > 0 && history[n-1]["role"] == "CHATBOT" {
+				existing, _ := history[n-1]["tool_calls"].([]any)
+				history[n-1]["tool_calls"] = append(existing, call)
+				continue
+			}
+			history = append(history, map[string]any{"role": "CHATBOT", "message": "", "tool_calls": []any{call}})
+		case ElementToolResponse:
+			resp := doc.ToolResps[el.Index]
+			addResult(resp.ID, resp.Name, cohereToolOutput(resp.Body))
+		case ElementToolResult:
+			resp := doc.ToolResults[el.Index]
+			addResult(resp.ID, resp.Name, cohereToolOutput(resp.Body))
+		case ElementToolError:
+			resp := doc.ToolErrors[el.Index]
+			addResult(resp.ID, resp.Name, map[string]any{"error": strings.TrimSpace(resp.Body)})
+		}
+	}
+
+	result := map[string]any{"message": ""}
+	if n := len(history); n > 0 {
+		switch last := history[n-1]; last["role"] {
+		case "USER":
+			result["message"] = last["message"]
+			history = history[:n-1]
+		case "TOOL":
+			result["tool_results"] = last["tool_results"]
+			history = history[:n-1]
+		}
+	}
+	if len(history) > 0 {
+		result["chat_history"] = history
+	}
+	if len(preamble) > 0 {
+		result["preamble"] = strings.Join(preamble, "\n\n")
+	}
+	if len(doc.ToolDefs) > 0 {
+		var tools []any
+		for _, td := range doc.ToolDefs {
+			tools = append(tools, buildCohereToolDefinition(td, opts))
+		}
+		result["tools"] = tools
+	}
+	if doc.hasSchema() {
+		result["response_format"] = map[string]any{
+			"type":   "json_object",
+			"schema": parseJSONFallback(doc.Schema.Body),
+		}
+	}
+	if rt := collectRuntime(doc, opts.Profile); rt != nil {
+		for k, v := range rt {
+			switch k {
+			case "top_p":
+				result["p"] = v
+			case "top_k":
+				result["k"] = v
+			case "stop", "stop_sequences":
+				if s, ok := v.(string); ok {
+					v = []any{s}
+				}
+				result["stop_sequences"] = v
+			default:
+				result[k] = v
+			}
+		}
+	}
+	return result, nil
+}
+
+// cohereToolOutput wraps a tool body as a Cohere output object; JSON objects pass through as-is.
+func cohereToolOutput(body string) map[string]any {
+	body = strings.TrimSpace(body)
+	if val, ok := parseJSONIfStruct(stripCDATA(body)); ok {
+		if obj, ok := val.(map[string]any); ok {
+			return obj
+		}
+		return map[string]any{"result": val}
+	}
+	return map[string]any{"result": body}
+}
+
+// buildCohereToolDefinition translates a tool's JSON Schema parameters into Cohere's flat
+// parameter_definitions map ({"city": {"type": "str", "description": ..., "required": true}}).
+func buildCohereToolDefinition(td ToolDefinition, opts ConvertOptions) map[string]any {
+	flat := buildFlatToolDefinition(td, opts)
+	tool := map[string]any{"name": td.Name}
+	if desc, ok := flat["description"]; ok {
+		tool["description"] = desc
+	}
+	schema, _ := flat["parameters"].(map[string]any)
+	props, _ := schema["properties"].(map[string]any)
+	if len(props) == 0 {
+		return tool
+	}
+	required := map[string]bool{}
+	if list, ok := schema["required"].([]any); ok {
+		for _, name := range list {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+	defs := make(map[string]any, len(props))
+	for name, raw := range props {
+		prop, _ := raw.(map[string]any)
+		def := map[string]any{"type": cohereParamType(prop), "required": required[name]}
+		if desc, ok := prop["description"].(string); ok && desc != "" {
+			def["description"] = desc
+		}
+		defs[name] = def
+	}
+	tool["parameter_definitions"] = defs
+	return tool
+}
+
+// cohereParamType maps a JSON Schema property onto Cohere's Python-style type names.
+func cohereParamType(prop map[string]any) string {
+	typ, _ := prop["type"].(string)
+	if list, ok := prop["type"].([]any); ok {
+		// ["string", "null"] style unions: use the first non-null member.
+		for _, t := range list {
+			if s, ok := t.(string); ok && s != "null" {
+				typ = s
+				break
+			}
+		}
+	}
+	switch typ {
+	case "string":
+		return "str"
+	case "integer":
+		return "int"
+	case "number":
+		return "float"
+	case "boolean":
+		return "bool"
+	case "array":
+		if items, ok := prop["items"].(map[string]any); ok {
+			return "List[" + cohereParamType(items) + "]"
+		}
+		return "list"
+	case "object":
+		return "Dict"
+	}
+	return "str"
+}
@@ -0,0 +1,64 @@
+package poml
+
+import (
+	"testing"
+)
+
+func TestCohereChatShape(t *testing.T) {
+	src := `<poml>
+  <system-msg>Be terse.</system-msg>
+  <human-msg>Weather in Paris?</human-msg>
+  <ai-msg>Checking.</ai-msg>
+  <tool-definition name="weather" description="Get weather">{"type":"object","properties":{"city":{"type":"string","description":"City name"},"days":{"type":"integer"},"units":{"type":"array","items":{"type":"string"}}},"required":["city"]}</tool-definition>
+  <tool-request id="t1" name="weather" parameters="{{ { city: 'Paris' } }}" />
+  <tool-result id="t1" name="weather">{"temp": 21}</tool-result>
+  <tool-error id="t2" name="weather">timeout</tool-error>
+  <runtime model="command-r" temperature="0.3" top-p="0.9" stop="END" />
+</poml>`
+	outAny, err := ConvertString(src, FormatCohere, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	out := outAny.(map[string]any)
+	if out["preamble"] != "Be terse." || out["message"] != "" {
+		t.Fatalf("preamble/message mismatch: %+v", out)
+	}
+	history := out["chat_history"].([]map[string]any)
+	if len(history) != 2 || history[0]["role"] != "USER" || history[1]["role"] != "CHATBOT" {
+		t.Fatalf("chat_history mismatch: %+v", history)
+	}
+	call := history[1]["tool_calls"].([]any)[0].(map[string]any)
+	if call["name"] != "weather" || call["parameters"].(map[string]any)["city"] != "Paris" {
+		t.Fatalf("tool call mismatch: %+v", call)
+	}
+	results := out["tool_results"].([]any)
+	if len(results) != 2 {
+		t.Fatalf("expected two tool results, got %+v", results)
+	}
+	first := results[0].(map[string]any)
+	if first["call"].(map[string]any)["parameters"].(map[string]any)["city"] != "Paris" || first["outputs"].([]any)[0].(map[string]any)["temp"] != float64(21) {
+		t.Fatalf("tool result mismatch: %+v", first)
+	}
+	if errOut := results[1].(map[string]any)["outputs"].([]any)[0].(map[string]any); errOut["error"] != "timeout" {
+		t.Fatalf("tool error mismatch: %+v", errOut)
+	}
+	defs := out["tools"].([]any)[0].(map[string]any)["parameter_definitions"].(map[string]any)
+	city := defs["city"].(map[string]any)
+	if city["type"] != "str" || city["required"] != true || city["description"] != "City name" {
+		t.Fatalf("city definition mismatch: %+v", city)
+	}
+	if defs["days"].(map[string]any)["type"] != "int" || defs["units"].(map[string]any)["type"] != "List[str]" || defs["days"].(map[string]any)["required"] != false {
+		t.Fatalf("parameter definitions mismatch: %+v", defs)
+	}
+	if out["model"] != "command-r" || out["p"] != 0.9 || out["stop_sequences"].([]any)[0] != "END" {
+		t.Fatalf("runtime mismatch: %+v", out)
+	}
+
+	last, err := ConvertString(`<poml><human-msg>hi</human-msg><ai-msg>hello</ai-msg><human-msg>bye</human-msg></poml>`, FormatCohere, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if m := last.(map[string]any); m["message"] != "bye" || len(m["chat_history"].([]map[string]any)) != 2 {
+		t.Fatalf("expected final user turn as message: %+v", m)
+	}
+}
@@ -0,0 +1,164 @@
+package poml
+
+import "strings"
+
+// convertHFChat renders the message list Hugging Face chat templates consume
+// (tokenizer.apply_chat_template, text-generation-inference /v1/chat/completions): a plain
+// []{"role", "content"} with no request envelope. Messages with inline images use the
+// processor content-part form ({"type": "text"} / {"type": "image", "url"}), tool requests become
+// assistant "tool_calls" with argument objects, and tool responses, results, and errors become
+// role "tool" messages. Tool definitions, the output schema, and runtime keys are not part of the
+// list; RenderChatTemplate passes the tools to the template separately.
+func convertHFChat(doc Document, opts ConvertOptions) ([]map[string]any, error) {
+	messages := []map[string]any{}
+	user := func(content string) {
+		messages = append(messages, map[string]any{"role": "user", "content": content})
+	}
+	sys, err := systemPrompt(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	if sys != "" {
+		messages = append(messages, map[string]any{"role": "system", "content": sys})
+	}
+	for _, el := range doc.resolveOrder() {
+		switch el.Type {
+		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+			msg := doc.Messages[el.Index]
+			var content any = strings.TrimSpace(msg.Body)
+			parts, ok, err := inlineParts(doc.elementContent(el), hfInlinePart(opts))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				content = parts
+			}
+			messages = append(messages, map[string]any{"role": roleToOpenAI(msg.Role), "content": content})
+		case ElementHint, ElementExample, ElementContentPart:
+			if pair := doc.examplePair(el, opts); pair != nil {
+				user(pair.Input)
+				messages = append(messages, map[string]any{"role": "assistant", "content": pair.Output})
+				continue
+			}
+			parts, ok, err := inlineParts(doc.elementContent(el), hfInlinePart(opts))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				messages = append(messages, map[string]any{"role": "user", "content": parts})
+				continue
+			}
+			if body := strings.TrimSpace(doc.elementBody(el)); body != "" {
+				user(body)
+			}
+		case ElementDocument:
+			content, ok, err := doc.documentContent(el, opts)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				user(content)
+			}
+		case ElementObject:
+			obj := doc.Objects[el.Index]
+			content := strings.TrimSpace(obj.Body)
+			if content == "" {
+				content = strings.TrimSpace(obj.Data)
+			}
+			user(content)
+		case ElementImage:
+			im := doc.Images[el.Index]
+			part, err := hfInlinePart(opts)(inlineSegment{Image: &im})
+			if err != nil {
+				return nil, err
+			}
+			content := []any{part}
+			if alt := strings.TrimSpace(im.Alt); alt != "" {
+				content = append(content, map[string]any{"type": "text", "text": alt})
+			}
+			messages = append(messages, map[string]any{"role": "user", "content": content})
+		case ElementAudio, ElementVideo:
+			var m Media
+			kind := "audio"
+			if el.Type == ElementAudio {
+				m = doc.Audios[el.Index]
+			} else {
+				m, kind = doc.Videos[el.Index], "video"
+			}
+			part, err := buildMediaPart(m, opts)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, map[string]any{
+				"role": "user",
+				"content": []any{
+					map[string]any{"type": kind, "url": "data:" + part["type"].(string) + ";base64," + part["base64"].(string)},
+				},
+			})
+		case ElementToolRequest:
+			tr := doc.ToolReqs[el.Index]
+			call := map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name":      tr.Name,
+					"arguments": toolArgumentsObject(tr.Parameters),
+				},
+			}
+			if tr.ID != "" {
+				call["id"] = tr.ID
+			}
+			if n := len(messages); n > 0 && messages[n-1]["role"] == "assistant" {
+				calls, _ := messages[n-1]["tool_calls"].([]any)
+				messages[n-1]["tool_calls"] = append(calls, call)
+				continue
+			}
+			messages = append(messages, map[string]any{
+				"role":       "assistant",
+				"content":    "",
+				"tool_calls": []any{call},
+			})
+		case ElementToolResponse:
+			resp := doc.ToolResps[el.Index]
+			messages = append(messages, hfToolMessage(resp.ID, resp.Name, strings.TrimSpace(resp.Body)))
+		case ElementToolResult:
+			resp := doc.ToolResults[el.Index]
+			messages = append(messages, hfToolMessage(resp.ID, resp.Name, strings.TrimSpace(resp.Body)))
+		case ElementToolError:
+			resp := doc.ToolErrors[el.Index]
+			messages = append(messages, hfToolMessage(resp.ID, resp.Name, "error: "+strings.TrimSpace(resp.Body)))
+		}
+	}
+	return messages, nil
+}
+
+// hfInlinePart maps inline segments to processor content parts; images travel as data URLs.
+func hfInlinePart(opts ConvertOptions) func(inlineSegment) (any, error) {
+	return func(seg inlineSegment) (any, error) {
+		if seg.Audio != nil {
+			part, err := buildMediaPart(*seg.Audio, opts)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"type": "audio", "url": "data:" + part["type"].(string) + ";base64," + part["base64"].(string)}, nil
+		}
+		if seg.Image == nil {
+			return map[string]any{"type": "text", "text": seg.Text}, nil
+		}
+		part, err := buildImagePart(*seg.Image, opts)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "image", "url": "data:" + part["type"].(string) + ";base64," + part["base64"].(string)}, nil
+	}
+}
+
+func hfToolMessage(id, name, content string) map[string]any {
+	msg := map[string]any{"role": "tool", "content": content}
+	if id != "" {
+		msg["tool_call_id"] = id
+	}
+	if name != "" {
+		msg["name"] = name
+	}
+	return msg
+}
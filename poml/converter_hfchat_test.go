@@ -0,0 +1,108 @@
+package poml
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHFChatMessages(t *testing.T) {
+	src := `<poml>
+  <system-msg>Be terse.</system-msg>
+  <human-msg>Weather in Paris?</human-msg>
+  <img src="data:image/png;base64,` + pngData + `" alt="sky" />
+  <tool-definition name="weather" description="Get weather">{"type":"object","properties":{"city":{"type":"string"}}}</tool-definition>
+  <tool-request id="t1" name="weather" parameters="{{ { city: 'Paris' } }}" />
+  <tool-result id="t1" name="weather">{"temp": 21}</tool-result>
+  <ai-msg>21C.</ai-msg>
+  <runtime model="ignored" />
+</poml>`
+	outAny, err := ConvertString(src, FormatHFChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	msgs := outAny.([]map[string]any)
+	if len(msgs) != 6 {
+		t.Fatalf("expected 6 messages, got %d: %+v", len(msgs), msgs)
+	}
+	if msgs[0]["role"] != "system" || msgs[1]["content"] != "Weather in Paris?" || msgs[5]["role"] != "assistant" {
+		t.Fatalf("message mismatch: %+v", msgs)
+	}
+	img := msgs[2]["content"].([]any)
+	if part := img[0].(map[string]any); part["type"] != "image" || part["url"] != "data:image/png;base64,"+pngData {
+		t.Fatalf("image part mismatch: %+v", img)
+	}
+	if part := img[1].(map[string]any); part["type"] != "text" || part["text"] != "sky" {
+		t.Fatalf("alt part mismatch: %+v", img)
+	}
+	call := msgs[3]["tool_calls"].([]any)[0].(map[string]any)
+	fn := call["function"].(map[string]any)
+	if call["id"] != "t1" || fn["name"] != "weather" || fn["arguments"].(map[string]any)["city"] != "Paris" {
+		t.Fatalf("tool call mismatch: %+v", call)
+	}
+	if msgs[4]["role"] != "tool" || msgs[4]["tool_call_id"] != "t1" || msgs[4]["name"] != "weather" || msgs[4]["content"] != `{"temp": 21}` {
+		t.Fatalf("tool message mismatch: %+v", msgs[4])
+	}
+}
+
+func TestRenderChatTemplate(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <system-msg>You are helpful.</system-msg>
+  <human-msg>Hi</human-msg>
+  <ai-msg>Hello!</ai-msg>
+  <human-msg>Name a color.</human-msg>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	// ChatML, as shipped by Qwen and others.
+	chatML := `{% for message in messages %}{{'<|im_start|>' + message['role'] + '\n' + message['content'] + '<|im_end|>' + '\n'}}{% endfor %}{% if add_generation_prompt %}{{ '<|im_start|>assistant\n' }}{% endif %}`
+	out, err := RenderChatTemplate(doc, chatML, ChatTemplateOptions{AddGenerationPrompt: true})
+	if err != nil {
+		t.Fatalf("render chatml: %v", err)
+	}
+	want := "<|im_start|>system\nYou are helpful.<|im_end|>\n<|im_start|>user\nHi<|im_end|>\n<|im_start|>assistant\nHello!<|im_end|>\n<|im_start|>user\nName a color.<|im_end|>\n<|im_start|>assistant\n"
+	if out != want {
+		t.Fatalf("chatml mismatch:\n%q\nwant\n%q", out, want)
+	}
+
+	// Llama 2 style: folds the system prompt into the first turn, enforces alternation, and
+	// relies on trim_blocks/lstrip_blocks for its layout.
+	llama2 := `{% if messages[0]['role'] == 'system' %}
+    {% set loop_messages = messages[1:] %}
+    {% set system_message = '<<SYS>>\n' + messages[0]['content'] | trim + '\n<</SYS>>\n\n' %}
+{% else %}
+    {% set loop_messages = messages %}
+    {% set system_message = '' %}
+{% endif %}
+{% for message in loop_messages %}
+    {% if (message['role'] == 'user') != (loop.index0 % 2 == 0) %}
+        {{- raise_exception('Conversation roles must alternate user/assistant/user/assistant/...') -}}
+    {% endif %}
+    {% if loop.index0 == 0 %}
+        {% set content = system_message + message['content'] %}
+    {% else %}
+        {% set content = message['content'] %}
+    {% endif %}
+    {% if message['role'] == 'user' %}
+        {{- bos_token + '[INST] ' + content | trim + ' [/INST]' -}}
+    {% elif message['role'] == 'assistant' %}
+        {{- ' ' + content | trim + ' ' + eos_token -}}
+    {% endif %}
+{% endfor %}`
+	out, err = RenderChatTemplate(doc, llama2, ChatTemplateOptions{BOSToken: "<s>", EOSToken: "</s>"})
+	if err != nil {
+		t.Fatalf("render llama2: %v", err)
+	}
+	want = "<s>[INST] <<SYS>>\nYou are helpful.\n<</SYS>>\n\nHi [/INST] Hello! </s><s>[INST] Name a color. [/INST]"
+	if out != want {
+		t.Fatalf("llama2 mismatch:\n%q\nwant\n%q", out, want)
+	}
+
+	bad, err := ParseString(`<poml><human-msg>a</human-msg><human-msg>b</human-msg></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := RenderChatTemplate(bad, llama2, ChatTemplateOptions{}); !errors.Is(err, ErrChatTemplateException) {
+		t.Fatalf("expected raise_exception error, got %v", err)
+	}
+}
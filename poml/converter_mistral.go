@@ -0,0 +1,87 @@
+package poml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+)
+
+// convertMistral renders the Mistral chat completions request shape. It is openai_chat with
+// Mistral's stricter validation applied: tools carry no "attrs" key and always have a
+// "parameters" object, tool messages drop the extra "type" key, assistant tool-call turns get an
+// empty content string, and tool call IDs that are not nine alphanumerics (as Mistral requires)
+// are replaced by a stable hash so calls and responses still pair up. Mistral has no metadata
+// or extension field, so <trace> metadata and x- runtime parameters are dropped.
+func convertMistral(doc Document, opts ConvertOptions) (map[string]any, error) {
+	out, err := convertOpenAIChat(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	delete(out, "metadata")
+	delete(out, "extra_body")
+	messages, _ := out["messages"].([]map[string]any)
+	for _, msg := range messages {
+		switch msg["role"] {
+		case "assistant":
+			calls, ok := msg["tool_calls"].([]any)
+			if !ok {
+				continue
+			}
+			if _, ok := msg["content"]; !ok {
+				msg["content"] = ""
+			}
+			for _, call := range calls {
+				if c, ok := call.(map[string]any); ok {
+					c["id"] = mistralToolCallID(c["id"])
+				}
+			}
+		case "tool":
+			delete(msg, "type")
+			msg["tool_call_id"] = mistralToolCallID(msg["tool_call_id"])
+		}
+	}
+	if len(doc.ToolDefs) > 0 {
+		tools := make([]any, 0, len(doc.ToolDefs))
+		for _, td := range doc.ToolDefs {
+			tool := buildOpenAIToolDefinition(td, opts)
+			fn := tool["function"].(map[string]any)
+			delete(fn, "attrs")
+			if _, ok := fn["parameters"].(map[string]any); !ok {
+				fn["parameters"] = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+			tools = append(tools, tool)
+		}
+		out["tools"] = tools
+	}
+	return out, nil
+}
+
+var mistralToolCallIDPattern = regexp.MustCompile(`^[A-Za-z0-9]{9}$`)
+
+func mistralToolCallID(v any) any {
+	id, ok := v.(string)
+	if !ok || mistralToolCallIDPattern.MatchString(id) {
+		return v
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])[:9]
+}
+
+// applyToolChoice sets tool_choice and parallel_tool_calls on an OpenAI-style request that
+// declares tools. "auto", "none", and "required" (and Mistral's "any") pass through; any other
+// value names the function the model must call.
+func applyToolChoice(out map[string]any, opts ConvertOptions) {
+	if _, ok := out["tools"]; !ok {
+		return
+	}
+	switch opts.ToolChoice {
+	case "":
+	case "auto", "none", "required", "any":
+		out["tool_choice"] = opts.ToolChoice
+	default:
+		out["tool_choice"] = map[string]any{"type": "function", "function": map[string]any{"name": opts.ToolChoice}}
+	}
+	if opts.ParallelToolCalls != nil {
+		out["parallel_tool_calls"] = *opts.ParallelToolCalls
+	}
+}
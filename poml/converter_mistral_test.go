@@ -0,0 +1,81 @@
+package poml
+
+import (
+	"reflect"
+	"testing"
+)
+
+const mistralToolDoc = `<poml>
+  <task>Check the weather</task>
+  <tool-definition name="weather" description="Get weather" category="io">{"type":"object","properties":{"city":{"type":"string"}}}</tool-definition>
+  <tool-definition name="now" description="Current time"/>
+  <human-msg>Paris?</human-msg>
+  <tool-request id="call_weather_1" name="weather" parameters="{{ { city: 'Paris' } }}"/>
+  <tool-result id="call_weather_1" name="weather">sunny</tool-result>
+</poml>`
+
+func TestConvertMistral(t *testing.T) {
+	doc, err := ParseString(mistralToolDoc)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	parallel := false
+	out, err := Convert(doc, FormatMistral, ConvertOptions{ToolChoice: "any", ParallelToolCalls: &parallel})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	res := out.(map[string]any)
+	if res["tool_choice"] != "any" || res["parallel_tool_calls"] != false {
+		t.Fatalf("unexpected tool options %v / %v", res["tool_choice"], res["parallel_tool_calls"])
+	}
+	tools := res["tools"].([]any)
+	first := tools[0].(map[string]any)["function"].(map[string]any)
+	if _, ok := first["attrs"]; ok {
+		t.Fatalf("mistral tools must not carry attrs: %v", first)
+	}
+	second := tools[1].(map[string]any)["function"].(map[string]any)
+	if want := map[string]any{"type": "object", "properties": map[string]any{}}; !reflect.DeepEqual(second["parameters"], want) {
+		t.Fatalf("missing parameters should default to an empty object, got %v", second["parameters"])
+	}
+
+	msgs := res["messages"].([]map[string]any)
+	call := msgs[1]["tool_calls"].([]any)[0].(map[string]any)
+	result := msgs[2]
+	if id := call["id"].(string); len(id) != 9 || result["tool_call_id"] != id {
+		t.Fatalf("tool call ids should be 9 characters and paired: %v / %v", call["id"], result["tool_call_id"])
+	}
+	if _, ok := result["type"]; ok || msgs[1]["content"] != "" {
+		t.Fatalf("unexpected tool messages %v", msgs[1:])
+	}
+}
+
+func TestOpenAIChatToolChoice(t *testing.T) {
+	doc, err := ParseString(mistralToolDoc)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out, err := Convert(doc, FormatOpenAIChat, ConvertOptions{ToolChoice: "weather"})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	res := out.(map[string]any)
+	want := map[string]any{"type": "function", "function": map[string]any{"name": "weather"}}
+	if !reflect.DeepEqual(res["tool_choice"], want) {
+		t.Fatalf("tool_choice = %v", res["tool_choice"])
+	}
+	if _, ok := res["parallel_tool_calls"]; ok {
+		t.Fatal("parallel_tool_calls should be omitted when unset")
+	}
+	fn := res["tools"].([]any)[0].(map[string]any)["function"].(map[string]any)
+	if fn["attrs"] == nil {
+		t.Fatal("openai_chat keeps tool attrs")
+	}
+
+	noTools, err := Convert(Document{}, FormatOpenAIChat, ConvertOptions{ToolChoice: "auto"})
+	if err != nil {
+		t.Fatalf("convert empty: %v", err)
+	}
+	if _, ok := noTools.(map[string]any)["tool_choice"]; ok {
+		t.Fatal("tool_choice requires tools")
+	}
+}
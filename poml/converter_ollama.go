@@ -0,0 +1,180 @@
+package poml
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// convertOllama renders the Ollama /api/chat request shape. Images travel as base64 strings in a
+// message-level "images" array, <output-schema> becomes "format", and runtime keys go to
+// "options" except model/stream/keep_alive/think, which Ollama reads from the top level. x- runtime
+// parameters go to "options" as well.
+func convertOllama(doc Document, opts ConvertOptions) (map[string]any, error) {
+	var messages []map[string]any
+	user := func(content string) {
+		messages = append(messages, map[string]any{"role": "user", "content": content})
+	}
+	sys, err := systemPrompt(doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	if sys != "" {
+		messages = append(messages, map[string]any{"role": "system", "content": sys})
+	}
+	for _, el := range doc.resolveOrder() {
+		switch el.Type {
+		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+			msg := doc.Messages[el.Index]
+			out, err := ollamaMessage(roleToOpenAI(msg.Role), strings.TrimSpace(msg.Body), doc.elementContent(el), opts)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, out)
+		case ElementHint, ElementExample, ElementContentPart:
+			if pair := doc.examplePair(el, opts); pair != nil {
+				user(pair.Input)
+				messages = append(messages, map[string]any{"role": "assistant", "content": pair.Output})
+				continue
+			}
+			body := strings.TrimSpace(doc.elementBody(el))
+			out, err := ollamaMessage("user", body, doc.elementContent(el), opts)
+			if err != nil {
+				return nil, err
+			}
+			if body != "" {
+				messages = append(messages, out)
+			}
+		case ElementDocument:
+			content, ok, err := doc.documentContent(el, opts)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				user(content)
+			}
+		case ElementObject:
+			obj := doc.Objects[el.Index]
+			content := strings.TrimSpace(obj.Body)
+			if content == "" {
+				content = strings.TrimSpace(obj.Data)
+			}
+			user(content)
+		case ElementImage:
+			im := doc.Images[el.Index]
+			part, err := buildImagePart(im, opts)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, map[string]any{
+				"role":    "user",
+				"content": im.Alt,
+				"images":  []any{part["base64"]},
+			})
+		case ElementAudio:
+			return nil, fmt.Errorf("ollama: audio[%d]: audio content is not supported by /api/chat", el.Index)
+		case ElementVideo:
+			return nil, fmt.Errorf("ollama: video[%d]: video content is not supported by /api/chat", el.Index)
+		case ElementToolRequest:
+			tr := doc.ToolReqs[el.Index]
+			call := map[string]any{
+				"function": map[string]any{
+					"name":      tr.Name,
+					"arguments": toolArgumentsObject(tr.Parameters),
+				},
+			}
+			if n := len(messages); n > 0 && messages[n-1]["role"] == "assistant" {
+				calls, _ := messages[n-1]["tool_calls"].([]any)
+				messages[n-1]["tool_calls"] = append(calls, call)
+				continue
+			}
+			messages = append(messages, map[string]any{
+				"role":       "assistant",
+				"content":    "",
+				"tool_calls": []any{call},
+			})
+		case ElementToolResponse:
+			resp := doc.ToolResps[el.Index]
+			messages = append(messages, ollamaToolMessage(resp.Name, resp.Body))
+		case ElementToolResult:
+			resp := doc.ToolResults[el.Index]
+			messages = append(messages, ollamaToolMessage(resp.Name, resp.Body))
+		case ElementToolError:
+			resp := doc.ToolErrors[el.Index]
+			messages = append(messages, ollamaToolMessage(resp.Name, "error: "+strings.TrimSpace(resp.Body)))
+		}
+	}
+
+	result := map[string]any{"messages": messages}
+	if doc.hasSchema() {
+		result["format"] = parseJSONFallback(doc.Schema.Body)
+	}
+	if len(doc.ToolDefs) > 0 {
+		var tools []any
+		for _, td := range doc.ToolDefs {
+			tools = append(tools, buildOpenAIToolDefinition(td, opts))
+		}
+		result["tools"] = tools
+	}
+	options := map[string]any{}
+	if rt := collectRuntime(doc, opts.Profile); rt != nil {
+		for k, v := range rt {
+			switch k {
+			case "model", "stream", "keep_alive", "think":
+				result[k] = v
+			case "max_tokens":
+				options["num_predict"] = v
+			case "stop", "stop_sequences":
+				if s, ok := v.(string); ok {
+					v = []any{s}
+				}
+				options["stop"] = v
+			default:
+				options[k] = v
+			}
+		}
+	}
+	// Vendor parameters (x-min_p) are model options too.
+	maps.Copy(options, collectExtraBody(doc, opts.Profile))
+	if len(options) > 0 {
+		result["options"] = options
+	}
+	return result, nil
+}
+
+// ollamaMessage builds a chat message; inline images (ParseInlineContent) move to "images" and
+// the remaining text runs are joined with blank lines.
+func ollamaMessage(role, body string, content []InlineNode, opts ConvertOptions) (map[string]any, error) {
+	msg := map[string]any{"role": role, "content": body}
+	segs, ok := inlineSegments(content)
+	if !ok {
+		return msg, nil
+	}
+	var texts []string
+	var images []any
+	for _, seg := range segs {
+		if seg.Audio != nil {
+			return nil, fmt.Errorf("ollama: audio content is not supported by /api/chat")
+		}
+		if seg.Image == nil {
+			texts = append(texts, seg.Text)
+			continue
+		}
+		part, err := buildImagePart(*seg.Image, opts)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, part["base64"])
+	}
+	msg["content"] = strings.Join(texts, "\n\n")
+	msg["images"] = images
+	return msg, nil
+}
+
+func ollamaToolMessage(name, body string) map[string]any {
+	msg := map[string]any{"role": "tool", "content": strings.TrimSpace(body)}
+	if name != "" {
+		msg["tool_name"] = name
+	}
+	return msg
+}
@@ -0,0 +1,56 @@
+package poml
+
+import (
+	"testing"
+)
+
+func TestOllamaChatShape(t *testing.T) {
+	src := `<poml>
+  <system-msg>Be terse.</system-msg>
+  <human-msg>Weather in Paris?</human-msg>
+  <img src="data:image/png;base64,` + pngData + `" alt="sky" />
+  <tool-definition name="weather" description="Get weather">{"type":"object","properties":{"city":{"type":"string"}}}</tool-definition>
+  <tool-request id="t1" name="weather" parameters="{{ { city: 'Paris' } }}" />
+  <tool-result id="t1" name="weather">{"temp": 21}</tool-result>
+  <output-schema>{"type":"object","properties":{"answer":{"type":"string"}}}</output-schema>
+  <runtime model="llama3.2" max-tokens="256" temperature="0.2" stop="END" keep-alive="5m" />
+</poml>`
+	outAny, err := ConvertString(src, FormatOllama, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	out := outAny.(map[string]any)
+	msgs := out["messages"].([]map[string]any)
+	if len(msgs) != 5 {
+		t.Fatalf("expected 5 messages, got %d: %+v", len(msgs), msgs)
+	}
+	if msgs[0]["role"] != "system" || msgs[1]["content"] != "Weather in Paris?" {
+		t.Fatalf("message mismatch: %+v", msgs[:2])
+	}
+	if imgs := msgs[2]["images"].([]any); len(imgs) != 1 || imgs[0] != pngData || msgs[2]["content"] != "sky" {
+		t.Fatalf("image message mismatch: %+v", msgs[2])
+	}
+	call := msgs[3]["tool_calls"].([]any)[0].(map[string]any)["function"].(map[string]any)
+	if call["name"] != "weather" || call["arguments"].(map[string]any)["city"] != "Paris" {
+		t.Fatalf("tool call mismatch: %+v", call)
+	}
+	if msgs[4]["role"] != "tool" || msgs[4]["tool_name"] != "weather" || msgs[4]["content"] != `{"temp": 21}` {
+		t.Fatalf("tool message mismatch: %+v", msgs[4])
+	}
+	if out["format"].(map[string]any)["type"] != "object" {
+		t.Fatalf("format mismatch: %+v", out["format"])
+	}
+	if out["model"] != "llama3.2" || out["keep_alive"] != "5m" {
+		t.Fatalf("top-level runtime mismatch: %+v", out)
+	}
+	options := out["options"].(map[string]any)
+	if options["num_predict"] != 256 || options["temperature"] != 0.2 || options["stop"].([]any)[0] != "END" {
+		t.Fatalf("options mismatch: %+v", options)
+	}
+	if fn := out["tools"].([]any)[0].(map[string]any)["function"].(map[string]any); fn["name"] != "weather" {
+		t.Fatalf("tools mismatch: %+v", out["tools"])
+	}
+	if _, err := ConvertString(`<poml><audio src="data:audio/wav;base64,AAAA" /></poml>`, FormatOllama, ConvertOptions{}); err == nil {
+		t.Fatalf("expected audio to be rejected")
+	}
+}
@@ -0,0 +1,193 @@
+package poml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TextOptions configures FormatText.
+type TextOptions struct {
+	// Headers overrides section headers per element type; types without an entry use
+	// DefaultTextHeaders. An empty header emits the body alone.
+	Headers map[ElementType]string
+	// HeaderFormat is a fmt pattern applied to each header (default "%s:").
+	HeaderFormat string
+	// Separator goes between sections (default a blank line).
+	Separator string
+}
+
+// DefaultTextHeaders are the section headers FormatText uses unless TextOptions.Headers
+// overrides them. A caption attribute on an element replaces its header.
+var DefaultTextHeaders = map[ElementType]string{
+	ElementRole:         "Role",
+	ElementTask:         "Task",
+	ElementInput:        "Input",
+	ElementDocument:     "Document",
+	ElementStyle:        "Style",
+	ElementOutputFormat: "Output format",
+	ElementHint:         "Hint",
+	ElementExample:      "Example",
+	ElementContentPart:  "Content",
+	ElementObject:       "Data",
+	ElementHumanMsg:     "Human",
+	ElementAssistantMsg: "Assistant",
+	ElementSystemMsg:    "System",
+	ElementMsg:          "Message",
+	ElementOutputSchema: "Output schema",
+}
+
+// convertText flattens the document into one plain-text prompt for endpoints that take a
+// string. Text sections (role, tasks, inputs, documents, styles, output formats, hints,
+// examples, content parts, objects, messages, and the output schema) are emitted in document
+// order with entities and CDATA decoded and common indentation removed. Media, tools, runtime,
+// meta, and diagrams have no text form and are skipped.
+func convertText(doc Document, opts ConvertOptions) (string, error) {
+	return textSections(doc, opts, nil)
+}
+
+// textSections renders the text sections convertText emits, restricted to the element types in
+// only when it is non-nil.
+func textSections(doc Document, opts ConvertOptions, only map[ElementType]bool) (string, error) {
+	topts := opts.Text
+	headerFormat := topts.HeaderFormat
+	if headerFormat == "" {
+		headerFormat = "%s:"
+	}
+	sep := topts.Separator
+	if sep == "" {
+		sep = "\n\n"
+	}
+	var sections []string
+	add := func(t ElementType, qualifier string, attrs []xml.Attr, body string) {
+		body = dedentText(plainText(body))
+		if body == "" {
+			return
+		}
+		header, ok := topts.Headers[t]
+		if !ok {
+			header = DefaultTextHeaders[t]
+		}
+		if header != "" && qualifier != "" {
+			header += " (" + qualifier + ")"
+		}
+		for _, a := range attrs {
+			if a.Name.Local == "caption" && strings.TrimSpace(a.Value) != "" {
+				header = strings.TrimSpace(a.Value)
+			}
+		}
+		if header == "" {
+			sections = append(sections, body)
+			return
+		}
+		sections = append(sections, fmt.Sprintf(headerFormat, header)+"\n"+body)
+	}
+	for _, el := range doc.resolveOrder() {
+		if only != nil && !only[el.Type] {
+			continue
+		}
+		switch el.Type {
+		case ElementRole:
+			add(el.Type, "", doc.Role.Attrs, doc.Role.Body)
+		case ElementTask:
+			t := doc.Tasks[el.Index]
+			add(el.Type, "", t.Attrs, t.Body)
+		case ElementInput:
+			in := doc.Inputs[el.Index]
+			add(el.Type, in.Name, in.Attrs, in.Body)
+		case ElementDocument:
+			content, ok, err := doc.documentContent(el, opts)
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				ref := doc.Documents[el.Index]
+				add(el.Type, ref.Src, ref.Attrs, escapeBodyText(content))
+			}
+		case ElementStyle:
+			for _, out := range doc.Styles[el.Index].Outputs {
+				add(el.Type, out.Format, out.Attrs, out.Body)
+			}
+		case ElementOutputFormat:
+			f := doc.OutFormats[el.Index]
+			add(el.Type, "", f.Attrs, f.Body)
+		case ElementHint:
+			h := doc.Hints[el.Index]
+			add(el.Type, "", h.Attrs, h.Body)
+		case ElementExample:
+			ex := doc.Examples[el.Index]
+			add(el.Type, "", ex.Attrs, ex.Body)
+		case ElementContentPart:
+			cp := doc.ContentParts[el.Index]
+			add(el.Type, "", cp.Attrs, cp.Body)
+		case ElementObject:
+			obj := doc.Objects[el.Index]
+			body := obj.Body
+			if strings.TrimSpace(body) == "" {
+				body = escapeBodyText(obj.Data)
+			}
+			add(el.Type, obj.Syntax, obj.Attrs, body)
+		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+			msg := doc.Messages[el.Index]
+			qualifier := ""
+			if el.Type == ElementMsg {
+				qualifier = msg.Role
+			}
+			add(el.Type, qualifier, msg.Attrs, msg.Body)
+		case ElementOutputSchema:
+			add(el.Type, "", doc.Schema.Attrs, doc.Schema.Body)
+		}
+	}
+	return strings.Join(sections, sep), nil
+}
+
+// plainText decodes an innerxml body to its character data: entities and CDATA are resolved and
+// nested markup contributes only its text. Bodies that are not well-formed are returned with
+// just CDATA markers stripped.
+func plainText(body string) string {
+	dec := xml.NewDecoder(strings.NewReader("<x>" + body + "</x>"))
+	dec.Strict = false
+	var b strings.Builder
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return b.String()
+		}
+		if err != nil {
+			return stripCDATA(body)
+		}
+		if cd, ok := tok.(xml.CharData); ok {
+			b.Write(cd)
+		}
+	}
+}
+
+// dedentText trims surrounding blank lines and strips the indentation shared by every
+// non-blank line, so bodies indented to match the surrounding markup read naturally.
+func dedentText(s string) string {
+	lines := strings.Split(strings.TrimRight(s, " \t\r\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	prefix := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(strings.TrimPrefix(line, prefix), " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,64 @@
+package poml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvertTextFlattensSections(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <role>You are a careful analyst &amp; editor.</role>
+  <task>
+    Summarize the report:
+      - key numbers
+      - risks
+  </task>
+  <input name="audience">executives</input>
+  <hint caption="Background"><![CDATA[Revenue <grew> 4%.]]></hint>
+  <img src="chart.png" alt="chart"/>
+  <example>Q: ping <b>A</b>: pong</example>
+  <tool-definition name="search" description="find"/>
+  <human-msg>Go.</human-msg>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out, err := Convert(doc, FormatText, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	want := `Role:
+You are a careful analyst & editor.
+
+Task:
+Summarize the report:
+  - key numbers
+  - risks
+
+Input (audience):
+executives
+
+Background:
+Revenue <grew> 4%.
+
+Example:
+Q: ping A: pong
+
+Human:
+Go.`
+	if out != want {
+		t.Fatalf("text mismatch.\n got:\n%s\nwant:\n%s", out, want)
+	}
+
+	out, err = Convert(doc, FormatText, ConvertOptions{Text: TextOptions{
+		Headers:      map[ElementType]string{ElementRole: "", ElementHumanMsg: "USER"},
+		HeaderFormat: "### %s",
+		Separator:    "\n---\n",
+	}})
+	if err != nil {
+		t.Fatalf("convert custom: %v", err)
+	}
+	if s := out.(string); !strings.HasPrefix(s, "You are a careful analyst & editor.\n---\n### Task\n") || !strings.HasSuffix(s, "\n---\n### USER\nGo.") {
+		t.Fatalf("custom headers not applied:\n%s", s)
+	}
+}
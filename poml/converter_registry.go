@@ -21,11 +21,17 @@ type Converter interface {
 }
 
 // ConverterRegistry is a threadsafe registry for converters.
+// When no direct from->to converter exists, Convert chains registered converters along the
+// shortest path (up to MaxHops converters).
 type ConverterRegistry struct {
 	mu         sync.RWMutex
 	converters map[string]Converter
+	maxHops    int
 }
 
+// defaultMaxHops bounds multi-hop conversion chains when no explicit limit is set.
+const defaultMaxHops = 4
+
 // NewConverterRegistry builds an empty registry.
 func NewConverterRegistry() *ConverterRegistry {
 	return &ConverterRegistry{converters: make(map[string]Converter)}
@@ -72,16 +78,89 @@ type ConverterDescriptor struct {
 	To   string
 }
 
-// Convert dispatches to a registered converter.
+// SetMaxHops limits how many converters Convert may chain; zero restores the default and
+// one disables chaining (direct conversions only).
+func (r *ConverterRegistry) SetMaxHops(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxHops = n
+}
+
+// Convert dispatches to a registered converter, composing a multi-hop chain when needed.
+// Each hop receives the same opts map.
 func (r *ConverterRegistry) Convert(ctx context.Context, from, to string, input any, opts map[string]any) (any, error) {
+	path, err := r.Path(from, to)
+	if err != nil {
+		return nil, err
+	}
+	out := input
+	for _, conv := range path {
+		if ctx != nil {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		out, err = conv.Convert(ctx, out, opts)
+		if err != nil {
+			if len(path) > 1 {
+				return nil, fmt.Errorf("%s: %w", converterKey(conv.From(), conv.To()), err)
+			}
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Path returns the converters Convert would run for from->to: the direct converter when registered,
+// otherwise the shortest chain found by breadth-first search. Visited formats are tracked so cycles
+// in the registry cannot loop.
+func (r *ConverterRegistry) Path(from, to string) ([]Converter, error) {
 	key := converterKey(from, to)
 	r.mu.RLock()
-	conv, ok := r.converters[key]
-	r.mu.RUnlock()
-	if !ok {
-		return nil, fmt.Errorf("no converter for %s", key)
+	defer r.mu.RUnlock()
+	if conv, ok := r.converters[key]; ok {
+		return []Converter{conv}, nil
 	}
-	return conv.Convert(ctx, input, opts)
+	maxHops := r.maxHops
+	if maxHops == 0 {
+		maxHops = defaultMaxHops
+	}
+	src, dst := strings.ToLower(from), strings.ToLower(to)
+	adjacency := make(map[string][]Converter)
+	for _, c := range r.converters {
+		f := strings.ToLower(c.From())
+		adjacency[f] = append(adjacency[f], c)
+	}
+	for f := range adjacency {
+		edges := adjacency[f]
+		sort.Slice(edges, func(i, j int) bool { return strings.ToLower(edges[i].To()) < strings.ToLower(edges[j].To()) })
+	}
+	type step struct {
+		format string
+		path   []Converter
+	}
+	visited := map[string]bool{src: true}
+	queue := []step{{format: src}}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if len(cur.path) >= maxHops {
+			continue
+		}
+		for _, c := range adjacency[cur.format] {
+			next := strings.ToLower(c.To())
+			if visited[next] {
+				continue
+			}
+			path := append(append([]Converter(nil), cur.path...), c)
+			if next == dst {
+				return path, nil
+			}
+			visited[next] = true
+			queue = append(queue, step{format: next, path: path})
+		}
+	}
+	return nil, fmt.Errorf("no converter for %s", key)
 }
 
 // DefaultConverterRegistry is pre-populated with built-in converters for poml/diagram/scene.
@@ -124,6 +203,34 @@ func registerDefaultConverters(reg *ConverterRegistry) {
 			}
 		},
 	})
+	_ = reg.Register(basicConverter{
+		from: "dot",
+		to:   "diagram",
+		fn: func(_ context.Context, input any, _ map[string]any) (any, error) {
+			switch v := input.(type) {
+			case string:
+				return ParseDOT(v)
+			case []byte:
+				return ParseDOT(string(v))
+			default:
+				return nil, fmt.Errorf("dot->diagram converter expects string or []byte, got %T", input)
+			}
+		},
+	})
+	_ = reg.Register(basicConverter{
+		from: "graphml",
+		to:   "diagram",
+		fn: func(_ context.Context, input any, _ map[string]any) (any, error) {
+			switch v := input.(type) {
+			case string:
+				return ParseGraphML([]byte(v))
+			case []byte:
+				return ParseGraphML(v)
+			default:
+				return nil, fmt.Errorf("graphml->diagram converter expects string or []byte, got %T", input)
+			}
+		},
+	})
 	_ = reg.Register(basicConverter{
 		from: "diagram",
 		to:   "poml",
@@ -191,19 +298,38 @@ func registerDefaultConverters(reg *ConverterRegistry) {
 	_ = reg.Register(basicConverter{
 		from: "scene",
 		to:   "diagram",
-		fn: func(_ context.Context, input any, _ map[string]any) (any, error) {
+		// opts["max_nodes"] (int) splits each scene with SplitScene first, so the output is a
+		// []Diagram that diagram->poml writes as one <diagram> per part.
+		fn: func(_ context.Context, input any, opts map[string]any) (any, error) {
+			var scenes []Scene
 			switch v := input.(type) {
 			case Scene:
-				return sceneToDiagram(v), nil
+				scenes = []Scene{v}
 			case []Scene:
-				out := make([]Diagram, 0, len(v))
-				for _, sc := range v {
-					out = append(out, sceneToDiagram(sc))
-				}
-				return out, nil
+				scenes = v
 			default:
 				return nil, fmt.Errorf("scene->diagram converter expects Scene or []Scene, got %T", input)
 			}
+			maxNodes, split := opts["max_nodes"].(int)
+			if split {
+				var parts []Scene
+				for _, sc := range scenes {
+					p, err := SplitScene(sc, maxNodes)
+					if err != nil {
+						return nil, err
+					}
+					parts = append(parts, p...)
+				}
+				scenes = parts
+			}
+			if sc, ok := input.(Scene); ok && !split {
+				return sceneToDiagram(sc), nil
+			}
+			out := make([]Diagram, 0, len(scenes))
+			for _, sc := range scenes {
+				out = append(out, sceneToDiagram(sc))
+			}
+			return out, nil
 		},
 	})
 	_ = reg.Register(basicConverter{
@@ -312,6 +438,19 @@ func sceneToDiagram(scene Scene) Diagram {
 			Attrs:    attrsFromMap(e.Attrs),
 		})
 	}
+	for _, g := range scene.Groups {
+		group := DiagramGroup{
+			ID:     g.ID,
+			Label:  g.Label,
+			Parent: g.Parent,
+			Styles: stylesFromMap(g.Style),
+			Attrs:  attrsFromMap(g.Attrs),
+		}
+		for _, m := range g.Members {
+			group.Members = append(group.Members, DiagramMember{Node: m})
+		}
+		diagram.Graph.Groups = append(diagram.Graph.Groups, group)
+	}
 	for _, l := range scene.Layers {
 		diagram.Layers = append(diagram.Layers, DiagramLayer{
 			ID:    l.ID,
@@ -320,6 +459,24 @@ func sceneToDiagram(scene Scene) Diagram {
 			Attrs: attrsFromMap(l.Attrs),
 		})
 	}
+	for _, kf := range scene.Keyframes {
+		frame := DiagramFrame{T: formatFloat(kf.T)}
+		for _, n := range kf.Nodes {
+			node := DiagramNode{
+				ID:          n.ID,
+				Label:       n.Label,
+				PctComplete: n.PctComplete,
+				X:           formatOptionalFloat(n.X),
+				Y:           formatOptionalFloat(n.Y),
+				Z:           formatOptionalFloat(n.Z),
+			}
+			if len(n.Style) > 0 {
+				node.Styles = append(node.Styles, styleFromMap(n.Style))
+			}
+			frame.Nodes = append(frame.Nodes, node)
+		}
+		diagram.Frames = append(diagram.Frames, frame)
+	}
 	return diagram
 }
 
@@ -381,6 +538,13 @@ func formatFloat(f float64) string {
 	return strconv.FormatFloat(f, 'f', -1, 64)
 }
 
+func formatOptionalFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return formatFloat(*f)
+}
+
 func decodeSceneJSON(body []byte) (any, error) {
 	trim := strings.TrimSpace(string(body))
 	if strings.HasPrefix(trim, "{") {
@@ -164,3 +164,48 @@ func TestDiagramRoundTripWithBaseDocument(t *testing.T) {
 		t.Fatalf("context not preserved in round-trip: meta=%#v role=%q tasks=%d", parsed.Meta, parsed.Role.Body, len(parsed.Tasks))
 	}
 }
+
+func TestRegistryChainsMultiHopConversions(t *testing.T) {
+	reg := NewConverterRegistry()
+	registerDefaultConverters(reg)
+
+	path, err := reg.Path("poml", "scenejson")
+	if err != nil {
+		t.Fatalf("path: %v", err)
+	}
+	var hops []string
+	for _, c := range path {
+		hops = append(hops, converterKey(c.From(), c.To()))
+	}
+	if strings.Join(hops, ",") != "poml->diagram,diagram->scene,scene->scenejson" {
+		t.Fatalf("unexpected path: %v", hops)
+	}
+	out, err := reg.Convert(context.Background(), "POML", "scenejson", diagramSample, map[string]any{"pretty": false})
+	if err != nil {
+		t.Fatalf("poml->scenejson: %v", err)
+	}
+	if body, ok := out.([]byte); !ok || !strings.Contains(string(body), `"id":"chain-sample"`) {
+		t.Fatalf("unexpected chained output: %T %s", out, out)
+	}
+
+	reg.SetMaxHops(2)
+	if _, err := reg.Convert(context.Background(), "poml", "scenejson", diagramSample, nil); err == nil {
+		t.Fatalf("expected MaxHops to block a three-hop chain")
+	}
+}
+
+func TestRegistryPathCyclesTerminate(t *testing.T) {
+	reg := NewConverterRegistry()
+	noop := func(_ context.Context, in any, _ map[string]any) (any, error) { return in, nil }
+	_ = reg.Register(basicConverter{from: "a", to: "b", fn: noop})
+	_ = reg.Register(basicConverter{from: "b", to: "a", fn: noop})
+	_ = reg.Register(basicConverter{from: "b", to: "c", fn: func(context.Context, any, map[string]any) (any, error) {
+		return nil, errors.New("boom")
+	}})
+	if _, err := reg.Path("a", "z"); err == nil || !strings.Contains(err.Error(), "no converter for a->z") {
+		t.Fatalf("expected missing path error, got %v", err)
+	}
+	if _, err := reg.Convert(context.Background(), "a", "c", 1, nil); err == nil || !strings.Contains(err.Error(), "b->c: boom") {
+		t.Fatalf("expected hop error with context, got %v", err)
+	}
+}
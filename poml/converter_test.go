@@ -228,6 +228,13 @@ func TestConvertStringHelper(t *testing.T) {
 	if _, ok := out.(dictOutput); !ok {
 		t.Fatalf("unexpected type from ConvertString")
 	}
+
+	if _, err := ConvertString(src, FormatDict, ConvertOptions{Parse: &ParseOptions{Validate: true}}); !errors.Is(err, CodeValidation) {
+		t.Fatalf("expected validation with Parse options, got %v", err)
+	}
+	if _, err := ConvertString(`<poml><tsak>x</tsak></poml>`, FormatDict, ConvertOptions{Parse: &ParseOptions{DisallowUnknown: true}}); !errors.Is(err, CodeUnknownElement) {
+		t.Fatalf("expected unknown element error, got %v", err)
+	}
 }
 
 func TestConvertLangChainWithToolCallAndImage(t *testing.T) {
@@ -351,6 +358,31 @@ func TestOpenAIChatArgumentsAndRuntimeMerge(t *testing.T) {
 	}
 }
 
+func TestOpenAIChatResponseFormatOptions(t *testing.T) {
+	doc, err := ParseString(`<poml><task>t</task><output-schema>{"type":"object"}</output-schema></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	responseFormat := func(opts ConvertOptions) map[string]any {
+		out, err := Convert(doc, FormatOpenAIChat, opts)
+		if err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+		return out.(map[string]any)["response_format"].(map[string]any)
+	}
+	js := responseFormat(ConvertOptions{})["json_schema"].(map[string]any)
+	if js["name"] != "schema" || js["strict"] != true {
+		t.Fatalf("default json_schema = %+v", js)
+	}
+	js = responseFormat(ConvertOptions{SchemaName: "answer", SchemaStrict: ptrBool(false)})["json_schema"].(map[string]any)
+	if js["name"] != "answer" || js["strict"] != false || js["schema"] == nil {
+		t.Fatalf("custom json_schema = %+v", js)
+	}
+	if rf := responseFormat(ConvertOptions{JSONObject: true}); len(rf) != 1 || rf["type"] != "json_object" {
+		t.Fatalf("json_object mode = %+v", rf)
+	}
+}
+
 func TestConvertHintAndObjectContent(t *testing.T) {
 	src := `<poml>
   <hint caption="background">See this</hint>
@@ -525,3 +557,61 @@ func TestImageDefaultSizeLimit(t *testing.T) {
 		t.Fatalf("expected unlimited max to allow large file: %v", err)
 	}
 }
+
+func TestToolDefinitionBodyAndDescription(t *testing.T) {
+	src := `<poml>
+  <tool-definition name="calc">{"type":"object","properties":{"x":{"type":"number"}}}</tool-definition>
+  <tool-definition name="search">Search the web</tool-definition>
+  <tool-definition name="email" parser="eval">z.object({ to: z.string() })</tool-definition>
+</poml>`
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	tools := func(opts ConvertOptions) []any {
+		out, err := Convert(doc, FormatOpenAIChat, opts)
+		if err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+		return out.(map[string]any)["tools"].([]any)
+	}
+	fn := func(tool any) map[string]any { return tool.(map[string]any)["function"].(map[string]any) }
+
+	current := tools(ConvertOptions{})
+	if _, ok := fn(current[0])["description"]; ok {
+		t.Fatalf("schema body must not become the description: %v", fn(current[0]))
+	}
+	if _, ok := fn(current[0])["parameters"].(map[string]any); !ok {
+		t.Fatalf("body should be the parameters schema: %v", fn(current[0]))
+	}
+	if _, ok := fn(current[1])["description"]; ok {
+		t.Fatalf("prose body is ignored without LegacyToolDefinitions: %v", fn(current[1]))
+	}
+
+	legacy := tools(ConvertOptions{LegacyToolDefinitions: true})
+	if fn(legacy[1])["description"] != "Search the web" || !strings.HasPrefix(fn(legacy[0])["description"].(string), `{"type"`) {
+		t.Fatalf("legacy descriptions not restored: %v / %v", fn(legacy[0]), fn(legacy[1]))
+	}
+
+	if err := doc.Validate(); errors.Is(err, CodeToolDefBody) {
+		t.Fatalf("a prose body is a warning, not a failure: %v", err)
+	}
+	warnings, _ := doc.ValidateWithOptions(ValidateOptions{})
+	if warnings == nil || len(warnings.Details) != 1 || warnings.Details[0].Code != CodeToolDefBody || warnings.Details[0].Severity != SeverityWarning {
+		t.Fatalf("only the prose body without a parser should be flagged, got %v", warnings)
+	}
+	if warnings, _ := doc.ValidateWithOptions(ValidateOptions{LegacyToolDefinitions: true}); warnings != nil {
+		t.Fatalf("legacy mode accepts prose bodies, got %v", warnings)
+	}
+	if _, err := doc.ValidateWithOptions(ValidateOptions{FailOn: SeverityWarning}); !errors.Is(err, CodeToolDefBody) {
+		t.Fatalf("FailOn warning should fail on the prose body, got %v", err)
+	}
+
+	upstream, err := ParseFile(filepath.Join("testdata", "examples", "206_expense_send_email.poml"))
+	if err != nil {
+		t.Fatalf("parse upstream example: %v", err)
+	}
+	if _, err := upstream.ValidateWithOptions(ValidateOptions{FailOn: SeverityWarning}); errors.Is(err, CodeToolDefBody) {
+		t.Fatalf("parser=\"eval\" bodies are not JSON schemas and should not be checked: %v", err)
+	}
+}
@@ -0,0 +1,363 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+)
+
+// Content is a message body: plain text, or a list of parts when the message mixes text with
+// media. It marshals to a JSON string or array, matching the untyped converter output.
+type Content[P any] struct {
+	Text  string
+	Parts []P // non-nil when the body is multi-part; Text is then empty
+}
+
+func (c Content[P]) MarshalJSON() ([]byte, error) {
+	if c.Parts != nil {
+		return json.Marshal(c.Parts)
+	}
+	return json.Marshal(c.Text)
+}
+
+func (c *Content[P]) UnmarshalJSON(data []byte) error {
+	*c = Content[P]{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		c.Parts = []P{}
+		return json.Unmarshal(trimmed, &c.Parts)
+	}
+	return json.Unmarshal(data, &c.Text)
+}
+
+// MediaPart is an encoded image, audio, or video asset. Type, Mime, and MimeType all carry the
+// MIME type, and Base64 and Data both carry the payload, mirroring the keys of the untyped output.
+type MediaPart struct {
+	Type     string `json:"type"`
+	Mime     string `json:"mime"`
+	MimeType string `json:"mime_type"`
+	Alt      string `json:"alt"`
+	Base64   string `json:"base64"`
+	Source   string `json:"source"`
+	Syntax   string `json:"syntax"`
+	Data     string `json:"data"`
+}
+
+// ToolSpec is a tool definition in the flat form used by the dict, pydantic, and langchain formats.
+type ToolSpec struct {
+	Type        string            `json:"type"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Parameters  any               `json:"parameters,omitempty"` // JSON schema
+	Attrs       map[string]string `json:"attrs,omitempty"`
+}
+
+// OpenAIChatRequest is the typed form of the openai_chat output.
+type OpenAIChatRequest struct {
+	Messages          []OpenAIMessage       `json:"messages"`
+	Tools             []OpenAITool          `json:"tools,omitempty"`
+	ToolChoice        any                   `json:"tool_choice,omitempty"` // a mode string or a named-function object
+	ParallelToolCalls *bool                 `json:"parallel_tool_calls,omitempty"`
+	ResponseFormat    *OpenAIResponseFormat `json:"response_format,omitempty"`
+	Metadata          map[string]string     `json:"metadata,omitempty"`   // see Document.TraceMetadata
+	ExtraBody         map[string]any        `json:"extra_body,omitempty"` // x- runtime parameters; see RuntimeOptions.ExtraBody
+	// Params holds the <runtime> parameters (temperature, max_tokens, ...), which the request
+	// carries as top-level keys.
+	Params map[string]any `json:"-"`
+}
+
+// openAIChatFields has the fields of OpenAIChatRequest without its JSON methods.
+type openAIChatFields OpenAIChatRequest
+
+func (r OpenAIChatRequest) MarshalJSON() ([]byte, error) {
+	raw, err := json.Marshal(openAIChatFields(r))
+	if err != nil || len(r.Params) == 0 {
+		return raw, err
+	}
+	var merged map[string]any
+	if err := json.Unmarshal(raw, &merged); err != nil {
+		return nil, err
+	}
+	params := maps.Clone(r.Params)
+	maps.Copy(params, merged)
+	return json.Marshal(params)
+}
+
+func (r *OpenAIChatRequest) UnmarshalJSON(data []byte) error {
+	var fields openAIChatFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	for _, known := range []string{"messages", "tools", "tool_choice", "parallel_tool_calls", "response_format", "metadata", "extra_body"} {
+		delete(all, known)
+	}
+	for k, v := range all {
+		var val any
+		if err := json.Unmarshal(v, &val); err != nil {
+			return err
+		}
+		if fields.Params == nil {
+			fields.Params = map[string]any{}
+		}
+		fields.Params[k] = val
+	}
+	*r = OpenAIChatRequest(fields)
+	return nil
+}
+
+// OpenAIMessage is one chat message. Content is nil on assistant messages that only carry
+// tool calls; Type is "result" or "error" on tool messages built from <tool-result> and
+// <tool-error>.
+type OpenAIMessage struct {
+	Role       string                      `json:"role"`
+	Content    *Content[OpenAIContentPart] `json:"content,omitempty"`
+	ToolCalls  []OpenAIToolCall            `json:"tool_calls,omitempty"`
+	ToolCallID string                      `json:"tool_call_id,omitempty"`
+	Name       string                      `json:"name,omitempty"`
+	Type       string                      `json:"type,omitempty"`
+}
+
+// OpenAIContentPart is one part of a multi-part message: "text", "image_url", "input_audio",
+// or "input_video".
+type OpenAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+	Audio    *MediaPart      `json:"audio,omitempty"`
+	Video    *MediaPart      `json:"video,omitempty"`
+}
+
+// OpenAIImageURL holds an image as a data URI.
+type OpenAIImageURL struct {
+	URL string `json:"url"`
+}
+
+// OpenAIToolCall is a function call requested by the assistant.
+type OpenAIToolCall struct {
+	ID       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+// OpenAIFunctionCall names the called function; Arguments is a JSON-encoded object.
+type OpenAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// OpenAITool is a tool definition in the request's tools list.
+type OpenAITool struct {
+	Type     string         `json:"type"`
+	Function OpenAIFunction `json:"function"`
+}
+
+// OpenAIFunction describes a callable function.
+type OpenAIFunction struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Parameters  any               `json:"parameters,omitempty"` // JSON schema
+	Attrs       map[string]string `json:"attrs,omitempty"`
+}
+
+// OpenAIResponseFormat is "json_schema" with a JSONSchema, or "json_object" without one.
+type OpenAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+// OpenAIJSONSchema is the structured-output schema built from <output-schema>.
+type OpenAIJSONSchema struct {
+	Name   string `json:"name"`
+	Schema any    `json:"schema"`
+	Strict bool   `json:"strict"`
+}
+
+// LangChainPayload is the typed form of the langchain output.
+type LangChainPayload struct {
+	Messages  []LangChainMessage `json:"messages"`
+	Schema    any                `json:"schema,omitempty"`
+	Tools     []ToolSpec         `json:"tools,omitempty"`
+	Runtime   map[string]any     `json:"runtime,omitempty"`
+	Metadata  map[string]string  `json:"metadata,omitempty"`
+	ExtraBody map[string]any     `json:"extra_body,omitempty"`
+}
+
+// LangChainMessage is a serialized LangChain message: Type is "system", "human", "ai", or "tool".
+type LangChainMessage struct {
+	Type string               `json:"type"`
+	Data LangChainMessageData `json:"data"`
+}
+
+// LangChainMessageData is a message's fields. Result and Error mark tool messages built from
+// <tool-result> and <tool-error>.
+type LangChainMessageData struct {
+	Content    *Content[LangChainContentPart] `json:"content,omitempty"`
+	ToolCalls  []LangChainToolCall            `json:"tool_calls,omitempty"`
+	ToolCallID string                         `json:"tool_call_id,omitempty"`
+	Name       string                         `json:"name,omitempty"`
+	Result     bool                           `json:"result,omitempty"`
+	Error      bool                           `json:"error,omitempty"`
+}
+
+// LangChainContentPart is one part of a multi-part message: "text", "image", or "audio", the
+// media kinds carrying Base64 Data.
+type LangChainContentPart struct {
+	Type       string `json:"type"`
+	Text       string `json:"text,omitempty"`
+	SourceType string `json:"source_type,omitempty"`
+	MimeType   string `json:"mime_type,omitempty"`
+	Data       string `json:"data,omitempty"`
+}
+
+// LangChainToolCall is a tool call on an "ai" message; Args is the parsed parameters.
+type LangChainToolCall struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Args any    `json:"args"`
+}
+
+// DictResult is the typed form of the dict and pydantic outputs.
+type DictResult struct {
+	Messages  []DictMessage     `json:"messages"`
+	Schema    any               `json:"schema,omitempty"`
+	Tools     []ToolSpec        `json:"tools,omitempty"`
+	Runtime   map[string]any    `json:"runtime,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	ExtraBody map[string]any    `json:"extra_body,omitempty"`
+	Media     []MediaPart       `json:"media,omitempty"` // pydantic only
+}
+
+// DictMessage is one message: Speaker is "human", "assistant", "system", or "tool".
+type DictMessage struct {
+	Speaker string      `json:"speaker"`
+	Content DictContent `json:"content"`
+}
+
+// DictContent is a dict message body. Exactly one field is set, except that Text is also
+// used, empty, for an empty body.
+type DictContent struct {
+	Text      string
+	Parts     []DictPart     // text mixed with inline media
+	Media     *MediaPart     // a standalone <img>, <audio>, or <video>
+	Object    *DictObject    // an <object>
+	ToolError *DictToolError // a <tool-error>
+}
+
+// DictObject is the content of an <object> message; Type is always "object".
+type DictObject struct {
+	Type   string `json:"type"`
+	Data   string `json:"data"`
+	Syntax string `json:"syntax"`
+	Body   string `json:"body"`
+	Value  any    `json:"value,omitempty"` // the payload parsed by ObjectTag.Value, when it parses
+}
+
+// DictToolError is the content of a <tool-error> message.
+type DictToolError struct {
+	Error string `json:"error"`
+	Name  string `json:"name"`
+}
+
+func (c DictContent) MarshalJSON() ([]byte, error) {
+	switch {
+	case c.Parts != nil:
+		return json.Marshal(c.Parts)
+	case c.Media != nil:
+		return json.Marshal(c.Media)
+	case c.Object != nil:
+		return json.Marshal(c.Object)
+	case c.ToolError != nil:
+		return json.Marshal(c.ToolError)
+	}
+	return json.Marshal(c.Text)
+}
+
+func (c *DictContent) UnmarshalJSON(data []byte) error {
+	*c = DictContent{}
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) > 0 && trimmed[0] == '[':
+		c.Parts = []DictPart{}
+		return json.Unmarshal(trimmed, &c.Parts)
+	case len(trimmed) > 0 && trimmed[0] == '{':
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &keys); err != nil {
+			return err
+		}
+		if _, ok := keys["error"]; ok {
+			c.ToolError = &DictToolError{}
+			return json.Unmarshal(trimmed, c.ToolError)
+		}
+		if string(keys["type"]) == `"object"` {
+			c.Object = &DictObject{}
+			return json.Unmarshal(trimmed, c.Object)
+		}
+		c.Media = &MediaPart{}
+		return json.Unmarshal(trimmed, c.Media)
+	}
+	return json.Unmarshal(data, &c.Text)
+}
+
+// DictPart is one part of a multi-part dict message: text, or a media asset.
+type DictPart struct {
+	Text  string
+	Media *MediaPart
+}
+
+func (p DictPart) MarshalJSON() ([]byte, error) {
+	if p.Media != nil {
+		return json.Marshal(p.Media)
+	}
+	return json.Marshal(p.Text)
+}
+
+func (p *DictPart) UnmarshalJSON(data []byte) error {
+	*p = DictPart{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		p.Media = &MediaPart{}
+		return json.Unmarshal(trimmed, p.Media)
+	}
+	return json.Unmarshal(data, &p.Text)
+}
+
+// ConvertTypedOpenAIChat is Convert with FormatOpenAIChat, returning a struct instead of a map.
+func ConvertTypedOpenAIChat(doc Document, opts ConvertOptions) (OpenAIChatRequest, error) {
+	return convertTyped[OpenAIChatRequest](doc, FormatOpenAIChat, opts)
+}
+
+// ConvertTypedLangChain is Convert with FormatLangChain, returning a struct instead of a map.
+func ConvertTypedLangChain(doc Document, opts ConvertOptions) (LangChainPayload, error) {
+	return convertTyped[LangChainPayload](doc, FormatLangChain, opts)
+}
+
+// ConvertTypedDict is Convert with FormatDict, returning an exported struct.
+func ConvertTypedDict(doc Document, opts ConvertOptions) (DictResult, error) {
+	return convertTyped[DictResult](doc, FormatDict, opts)
+}
+
+// ConvertTypedPydantic is Convert with FormatPydantic, returning an exported struct.
+func ConvertTypedPydantic(doc Document, opts ConvertOptions) (DictResult, error) {
+	return convertTyped[DictResult](doc, FormatPydantic, opts)
+}
+
+// convertTyped runs Convert, hooks included, and decodes its JSON form into T, so the typed
+// and untyped outputs cannot drift apart.
+func convertTyped[T any](doc Document, format Format, opts ConvertOptions) (T, error) {
+	var typed T
+	out, err := Convert(doc, format, opts)
+	if err != nil {
+		return typed, err
+	}
+	raw, err := json.Marshal(out)
+	if err != nil {
+		return typed, fmt.Errorf("%s: encode output: %w", format, err)
+	}
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return typed, fmt.Errorf("%s: decode typed output: %w", format, err)
+	}
+	return typed, nil
+}
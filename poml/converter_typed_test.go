@@ -0,0 +1,125 @@
+package poml
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const typedSample = `<poml>
+  <role>Helper</role>
+  <task>Look things up.</task>
+  <tool-definition name="search" description="Search the web">{"type":"object","properties":{"q":{"type":"string"}}}</tool-definition>
+  <human-msg>Find <img src="data:image/png;base64,iVBORw0KGgo=" alt="logo"/> please</human-msg>
+  <tool-request id="c1" name="search" parameters='{"q":"logo"}'/>
+  <tool-response id="c1" name="search">found it</tool-response>
+  <assistant-msg>Here it is.</assistant-msg>
+  <output-schema>{"type":"object"}</output-schema>
+  <runtime temperature="0.2" max-tokens="64"/>
+</poml>`
+
+// sameJSON reports whether a and b marshal to equal JSON values.
+func sameJSON(t *testing.T, a, b any) bool {
+	t.Helper()
+	var va, vb any
+	for _, p := range []struct {
+		in  any
+		out *any
+	}{{a, &va}, {b, &vb}} {
+		raw, err := json.Marshal(p.in)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		if err := json.Unmarshal(raw, p.out); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestConvertTypedOpenAIChat(t *testing.T) {
+	doc, err := ParseString(typedSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	req, err := ConvertTypedOpenAIChat(doc, ConvertOptions{ToolChoice: "auto"})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if len(req.Messages) != 4 || req.Messages[0].Role != "user" {
+		t.Fatalf("unexpected messages: %+v", req.Messages)
+	}
+	human := req.Messages[0].Content
+	if len(human.Parts) != 3 || human.Parts[1].ImageURL == nil || human.Parts[1].ImageURL.URL != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Fatalf("expected a multi-part human message, got %+v", human)
+	}
+	if call := req.Messages[1]; call.Content != nil || len(call.ToolCalls) != 1 || call.ToolCalls[0].Function.Arguments != `{"q":"logo"}` {
+		t.Fatalf("unexpected tool call message: %+v", call)
+	}
+	if req.Messages[2].ToolCallID != "c1" || req.Messages[3].Content.Text != "Here it is." {
+		t.Fatalf("unexpected tail messages: %+v", req.Messages[2:])
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Function.Name != "search" || req.ToolChoice != "auto" {
+		t.Fatalf("unexpected tools: %+v %v", req.Tools, req.ToolChoice)
+	}
+	if req.ResponseFormat == nil || req.ResponseFormat.JSONSchema == nil || !req.ResponseFormat.JSONSchema.Strict {
+		t.Fatalf("unexpected response format: %+v", req.ResponseFormat)
+	}
+	if req.Params["temperature"] != 0.2 || req.Params["max_tokens"] != float64(64) {
+		t.Fatalf("unexpected params: %v", req.Params)
+	}
+
+	untyped, err := Convert(doc, FormatOpenAIChat, ConvertOptions{ToolChoice: "auto"})
+	if err != nil {
+		t.Fatalf("convert untyped: %v", err)
+	}
+	if !sameJSON(t, req, untyped) {
+		t.Fatalf("typed and untyped JSON differ")
+	}
+}
+
+func TestConvertTypedLangChainAndDict(t *testing.T) {
+	doc, err := ParseString(typedSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	lc, err := ConvertTypedLangChain(doc, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("langchain: %v", err)
+	}
+	if len(lc.Messages) != 4 || lc.Messages[1].Type != "ai" || len(lc.Messages[1].Data.ToolCalls) != 1 {
+		t.Fatalf("unexpected langchain messages: %+v", lc.Messages)
+	}
+	if part := lc.Messages[0].Data.Content.Parts[1]; part.Type != "image" || part.MimeType != "image/png" {
+		t.Fatalf("unexpected image part: %+v", part)
+	}
+	if len(lc.Tools) != 1 || lc.Tools[0].Name != "search" || lc.Runtime["temperature"] != 0.2 {
+		t.Fatalf("unexpected tools or runtime: %+v %v", lc.Tools, lc.Runtime)
+	}
+	untyped, err := Convert(doc, FormatLangChain, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert untyped: %v", err)
+	}
+	if !sameJSON(t, lc, untyped) {
+		t.Fatalf("typed and untyped langchain JSON differ")
+	}
+
+	dict, err := ConvertTypedPydantic(doc, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("pydantic: %v", err)
+	}
+	parts := dict.Messages[0].Content.Parts
+	if len(parts) != 3 || parts[0].Text != "Find" || parts[1].Media == nil || parts[1].Media.MimeType != "image/png" {
+		t.Fatalf("unexpected dict content: %+v", dict.Messages[0])
+	}
+	if dict.Messages[1].Content.Text != "found it" || dict.Messages[2].Content.Text != "Here it is." || dict.Schema == nil || len(dict.Tools) != 1 {
+		t.Fatalf("unexpected dict result: %+v", dict)
+	}
+	untyped, err = Convert(doc, FormatPydantic, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert untyped: %v", err)
+	}
+	if !sameJSON(t, dict, untyped) {
+		t.Fatalf("typed and untyped dict JSON differ")
+	}
+}
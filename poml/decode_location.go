@@ -0,0 +1,182 @@
+package poml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// DecodeLocation pinpoints a decode failure in the source. Parse attaches one to every
+// CodeDecode POMLError it returns, so a broken tag can be found in a large file.
+type DecodeLocation struct {
+	Element   string // name of the innermost element open at the failure; empty at document level
+	Offset    int64  // byte offset of the failure in the input
+	Line      int    // 1-based line of the failure
+	Column    int    // 1-based column of the failure
+	NearestID string // id attribute of the failing element or the closest start tag before it
+	Previous  string // Element.ID of the last top-level element decoded before the failure
+	// Excerpt is the source line at the failure, clipped to about 60 bytes either side,
+	// followed by a line with a caret under the failure.
+	Excerpt string
+}
+
+func (l DecodeLocation) String() string {
+	var b strings.Builder
+	if l.Element != "" {
+		fmt.Fprintf(&b, "<%s> at ", l.Element)
+	}
+	fmt.Fprintf(&b, "line %d, column %d (byte %d)", l.Line, l.Column, l.Offset)
+	if l.NearestID != "" {
+		fmt.Fprintf(&b, ", near id %q", l.NearestID)
+	}
+	return b.String()
+}
+
+// excerptRadius is how many bytes of the failing line an excerpt keeps on each side.
+const excerptRadius = 60
+
+// idAttrRe matches an id attribute inside a start tag.
+var idAttrRe = regexp.MustCompile(`(?:^|\s)id\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// locate attaches a DecodeLocation to a CodeDecode POMLError, working from the bytes retained
+// since the last mark and the decoder's position. prev is the Element.ID of the last decoded
+// top-level element. Other errors are returned unchanged. Reading the rest of the failing line
+// for the excerpt consumes input, so call it only once decoding has failed.
+func (d *pomlDecoder) locate(err error, prev string) error {
+	var pe *POMLError
+	if !errors.As(err, &pe) || pe.Code != CodeDecode || pe.Location != nil {
+		return err
+	}
+	off := d.InputOffset()
+	line, col := d.InputPos()
+	src := d.raw.slice(d.raw.base, off)
+	loc := &DecodeLocation{Offset: off, Line: line, Column: col, Previous: prev}
+	loc.Element, loc.NearestID = scanOpenElement(src)
+	loc.Excerpt = sourceExcerpt(src[strings.LastIndexByte(src, '\n')+1:], d.raw.restOfLine(off, excerptRadius+1))
+	pe.Location = loc
+	// The location supersedes the line wrapXMLError puts in syntax error messages.
+	var se *xml.SyntaxError
+	if errors.As(err, &se) {
+		pe.Message = strings.TrimSuffix(pe.Message, fmt.Sprintf(" (line %d)", se.Line))
+	}
+	where := loc.String()
+	switch {
+	case loc.Element == "" || pe.Message == "<"+loc.Element+">":
+		where = " at " + strings.TrimPrefix(where, "<"+loc.Element+"> at ")
+	default:
+		where = " in " + where
+	}
+	pe.Message += where
+	return err
+}
+
+// scanOpenElement returns the innermost element left open at the end of src, or the element
+// whose start tag src ends inside, and the last id attribute seen on a start tag.
+func scanOpenElement(src string) (element, id string) {
+	var stack []string
+	for i := 0; i < len(src); {
+		lt := strings.IndexByte(src[i:], '<')
+		if lt < 0 {
+			break
+		}
+		i += lt
+		rest := src[i:]
+		closer := ">"
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			closer = "-->"
+		case strings.HasPrefix(rest, "<![CDATA["):
+			closer = "]]>"
+		case strings.HasPrefix(rest, "<?"), strings.HasPrefix(rest, "<!"):
+		case strings.HasPrefix(rest, "</"):
+			// A mismatched end tag is the failure itself; keep the element it failed to close.
+			name := strings.TrimSpace(strings.TrimSuffix(strings.SplitN(rest[2:], ">", 2)[0], ">"))
+			if len(stack) > 0 && stack[len(stack)-1] == name {
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			end := tagEnd(rest)
+			tag := rest[1:]
+			if end >= 0 {
+				tag = rest[1:end]
+			}
+			name := tag
+			if n := strings.IndexAny(tag, " \t\r\n/>"); n >= 0 {
+				name = tag[:n]
+			}
+			if m := idAttrRe.FindStringSubmatch(tag[len(name):]); m != nil {
+				id = m[1] + m[2]
+			}
+			if end < 0 {
+				return name, id
+			}
+			if !strings.HasSuffix(tag, "/") {
+				stack = append(stack, name)
+			}
+			i += end + 1
+			continue
+		}
+		end := strings.Index(rest, closer)
+		if end < 0 {
+			break
+		}
+		i += end + len(closer)
+	}
+	if len(stack) == 0 {
+		return "", id
+	}
+	return stack[len(stack)-1], id
+}
+
+// tagEnd returns the index of the '>' closing the start tag at the beginning of s, skipping
+// quoted attribute values, or -1 when the tag is unterminated.
+func tagEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+// sourceExcerpt joins the text before and after a failure on its line, clipped to
+// excerptRadius bytes each side, and adds a caret line pointing at the failure.
+func sourceExcerpt(before, after string) string {
+	before = strings.TrimRight(before, "\r")
+	after = strings.TrimRight(after, "\r\n")
+	if len(before) > excerptRadius {
+		cut := len(before) - excerptRadius
+		for cut < len(before) && !utf8.RuneStart(before[cut]) {
+			cut++
+		}
+		before = "..." + before[cut:]
+	}
+	if len(after) > excerptRadius {
+		cut := excerptRadius
+		for cut > 0 && !utf8.RuneStart(after[cut]) {
+			cut--
+		}
+		after = after[:cut] + "..."
+	}
+	if strings.TrimSpace(before+after) == "" {
+		return ""
+	}
+	caret := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, before)
+	return before + after + "\n" + caret + "^"
+}
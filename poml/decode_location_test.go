@@ -0,0 +1,55 @@
+package poml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeErrorLocation(t *testing.T) {
+	src := "<poml>\n  <role>r</role>\n  <diagram id=\"d\"><graph>\n    <node id=\"a\"/>\n    <node id=\"b\" x=\"1\" & />\n  </graph></diagram>\n</poml>"
+	_, err := ParseString(src)
+	var pe *POMLError
+	if !errors.As(err, &pe) || pe.Code != CodeDecode || pe.Location == nil {
+		t.Fatalf("expected a located decode error, got %#v", err)
+	}
+	loc := *pe.Location
+	if loc.Element != "node" || loc.NearestID != "b" || loc.Line != 5 || loc.Previous != "el-1" {
+		t.Fatalf("unexpected location: %+v", loc)
+	}
+	if src[loc.Offset-2:loc.Offset] != "\" " {
+		t.Fatalf("offset %d does not point at the failure: %q", loc.Offset, src[loc.Offset-5:])
+	}
+	want := "    <node id=\"b\" x=\"1\" & />\n                       ^"
+	if loc.Excerpt != want {
+		t.Fatalf("unexpected excerpt:\n%s\nwant:\n%s", loc.Excerpt, want)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, `<diagram> in <node> at line 5, column 24 (byte 92), near id "b": `) {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+
+	// Mismatched tags report the element left open; streaming attaches locations too.
+	dec := NewStreamDecoder(strings.NewReader("<poml>\n<task>t</task>\n<hint>a <b>bold</hint> and a long tail of text after it\n</poml>"))
+	if _, _, err := dec.Next(); err != nil {
+		t.Fatalf("first element: %v", err)
+	}
+	_, _, err = dec.Next()
+	if !errors.As(err, &pe) || pe.Location == nil || pe.Location.Element != "b" || pe.Location.Previous != "el-1" {
+		t.Fatalf("expected stream error located in <b>, got %v", err)
+	}
+	if !strings.HasPrefix(pe.Location.Excerpt, "<hint>a <b>bold</hint> and") {
+		t.Fatalf("unexpected excerpt: %q", pe.Location.Excerpt)
+	}
+}
+
+func TestSourceExcerptClipsLongLines(t *testing.T) {
+	before, after := strings.Repeat("a", 100), strings.Repeat("é", 50)+"\n"
+	got := sourceExcerpt(before, after)
+	lines := strings.Split(got, "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "...aaa") || !strings.HasSuffix(lines[0], "é...") {
+		t.Fatalf("unexpected excerpt: %q", got)
+	}
+	if caret := strings.Index(lines[1], "^"); caret != len("...")+excerptRadius {
+		t.Fatalf("caret at %d: %q", caret, got)
+	}
+}
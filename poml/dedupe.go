@@ -0,0 +1,102 @@
+package poml
+
+import "slices"
+
+// DedupeOptions controls Document.Dedupe.
+type DedupeOptions struct {
+	// Types lists the element types to deduplicate; empty means tasks, hints, and examples.
+	Types []ElementType
+	// KeepWhitespace compares text exactly instead of collapsing runs of whitespace.
+	KeepWhitespace bool
+	// KeepLast keeps the last copy of each duplicate instead of the first.
+	KeepLast bool
+}
+
+var defaultDedupeTypes = []ElementType{ElementTask, ElementHint, ElementExample}
+
+// Dedupe removes repeated elements of opts.Types, keeping one copy of each, and returns how many
+// were removed. Elements match when their canonical forms agree, as in Hash: formatting,
+// comments, and attribute order are ignored; element type, attributes, and text are not.
+func (d *Document) Dedupe(opts DedupeOptions) (int, error) {
+	types := opts.Types
+	if len(types) == 0 {
+		types = defaultDedupeTypes
+	}
+	groups, err := d.duplicateGroups(types, opts.KeepWhitespace)
+	if err != nil {
+		return 0, err
+	}
+	drop := map[string]bool{}
+	for _, group := range groups {
+		keep := group[0]
+		if opts.KeepLast {
+			keep = group[len(group)-1]
+		}
+		for _, el := range group {
+			if el.ID != keep.ID {
+				drop[el.ID] = true
+			}
+		}
+	}
+	if len(drop) == 0 {
+		return 0, nil
+	}
+	d.Elements = d.resolveOrder()
+	for i := len(d.Elements) - 1; i >= 0; i-- {
+		if drop[d.Elements[i].ID] {
+			d.removePayload(d.Elements[i])
+			d.Elements = slices.Delete(d.Elements, i, i+1)
+		}
+	}
+	d.reindex()
+	return len(drop), nil
+}
+
+// FindDuplicates returns the IDs of elements that repeat another element of the same type, one
+// group per distinct element in document order, using the comparison Dedupe makes. Every element
+// type is considered, so repeated messages are reported even though Dedupe leaves them alone by
+// default.
+func (d Document) FindDuplicates() ([][]string, error) {
+	groups, err := d.duplicateGroups(nil, false)
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]string, len(groups))
+	for i, group := range groups {
+		for _, el := range group {
+			out[i] = append(out[i], el.ID)
+		}
+	}
+	return out, nil
+}
+
+// duplicateGroups groups elements of types (all types when nil) by canonical form, returning
+// only groups with more than one member.
+func (d *Document) duplicateGroups(types []ElementType, keepWS bool) ([][]Element, error) {
+	index := map[string]int{}
+	var groups [][]Element
+	for _, el := range d.resolveOrder() {
+		if el.Type == ElementUnknown || (types != nil && !slices.Contains(types, el.Type)) {
+			continue
+		}
+		canon, err := canonicalElementXML(elementXML(*d, el), keepWS)
+		if err != nil {
+			return nil, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "dedupe " + diffTypeLabel(el), Err: err}
+		}
+		key := string(el.Type) + "\x00" + canon
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], el)
+	}
+	out := groups[:0]
+	for _, group := range groups {
+		if len(group) > 1 {
+			out = append(out, group)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,68 @@
+package poml
+
+import (
+	"reflect"
+	"testing"
+)
+
+const duplicateDoc = `<poml>
+  <task>Be concise.</task>
+  <hint>Use bullet points.</hint>
+  <task>
+    Be   concise.
+  </task>
+  <human-msg>ok</human-msg>
+  <!-- repeated -->
+  <hint>Use bullet points.</hint>
+  <task lang="fr">Be concise.</task>
+  <human-msg>ok</human-msg>
+  <task>Be concise.</task>
+</poml>`
+
+func TestFindDuplicates(t *testing.T) {
+	doc, err := ParseString(duplicateDoc)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	groups, err := doc.FindDuplicates()
+	if err != nil {
+		t.Fatalf("FindDuplicates: %v", err)
+	}
+	id := func(i int) string { return doc.Elements[i].ID }
+	want := [][]string{{id(0), id(2), id(7)}, {id(1), id(4)}, {id(3), id(6)}}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatalf("groups = %v, want %v", groups, want)
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	doc, err := ParseString(duplicateDoc)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	removed, err := doc.Dedupe(DedupeOptions{})
+	if err != nil {
+		t.Fatalf("Dedupe: %v", err)
+	}
+	if removed != 3 || len(doc.Tasks) != 2 || len(doc.Hints) != 1 || len(doc.Messages) != 2 {
+		t.Fatalf("removed %d; tasks=%d hints=%d messages=%d", removed, len(doc.Tasks), len(doc.Hints), len(doc.Messages))
+	}
+	if _, err := Convert(doc, FormatOpenAIChat, ConvertOptions{}); err != nil {
+		t.Fatalf("convert after dedupe: %v", err)
+	}
+	if doc.Tasks[1].Attrs[0].Value != "fr" || doc.Elements[3].Type != ElementTask || doc.Elements[3].Index != 1 {
+		t.Fatalf("unexpected elements after dedupe: %+v", doc.Elements)
+	}
+
+	last, err := ParseString(duplicateDoc)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	keep := last.Elements[7].ID
+	if n, err := last.Dedupe(DedupeOptions{Types: []ElementType{ElementTask}, KeepLast: true, KeepWhitespace: true}); err != nil || n != 1 {
+		t.Fatalf("KeepLast removed %d: %v", n, err)
+	}
+	if last.Elements[len(last.Elements)-1].ID != keep || len(last.Hints) != 2 {
+		t.Fatalf("KeepLast kept the wrong copies: %+v", last.Elements)
+	}
+}
@@ -18,13 +18,67 @@ type Diagram struct {
 	Graph      DiagramGraph   `xml:"graph"`
 	Layers     []DiagramLayer `xml:"layer"`
 	Camera     DiagramCamera  `xml:"camera"`
-	Attrs      []xml.Attr     `xml:",any,attr"`
+	Frames     []DiagramFrame `xml:"frame"`
+	Attrs      Attrs          `xml:",any,attr"`
 }
 
-// DiagramGraph holds nodes and edges.
+// DiagramGraph holds nodes, edges, and groups. Groups may be written as <group> or <subgraph>;
+// both decode into Groups and encode as <group>.
 type DiagramGraph struct {
-	Nodes []DiagramNode `xml:"node"`
-	Edges []DiagramEdge `xml:"edge"`
+	Nodes  []DiagramNode  `xml:"node"`
+	Edges  []DiagramEdge  `xml:"edge"`
+	Groups []DiagramGroup `xml:"group"`
+}
+
+// UnmarshalXML decodes graph children, accepting <subgraph> as an alias for <group>.
+func (g *DiagramGraph) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "node":
+				var n DiagramNode
+				err = dec.DecodeElement(&n, &t)
+				g.Nodes = append(g.Nodes, n)
+			case "edge":
+				var e DiagramEdge
+				err = dec.DecodeElement(&e, &t)
+				g.Edges = append(g.Edges, e)
+			case "group", "subgraph":
+				var grp DiagramGroup
+				err = dec.DecodeElement(&grp, &t)
+				g.Groups = append(g.Groups, grp)
+			default:
+				err = dec.Skip()
+			}
+			if err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// DiagramGroup is a container (a DOT cluster or Mermaid subgraph) around member nodes. Nodes
+// join a group by being listed as a <member node="..."/> or by naming the group's ID in their
+// group attribute; Parent nests the group inside another group.
+type DiagramGroup struct {
+	ID      string          `xml:"id,attr"`
+	Label   string          `xml:"label,attr"`
+	Parent  string          `xml:"parent,attr"`
+	Members []DiagramMember `xml:"member"`
+	Styles  []DiagramStyle  `xml:"style"`
+	Attrs   Attrs           `xml:",any,attr"`
+}
+
+// DiagramMember references a node that belongs to a group.
+type DiagramMember struct {
+	Node string `xml:"node,attr"`
 }
 
 // DiagramNode describes a node in the diagram.
@@ -40,10 +94,11 @@ type DiagramNode struct {
 	Z           string         `xml:"z,attr"`
 	Styles      []DiagramStyle `xml:"style"`
 	Data        []DiagramData  `xml:"data"`
-	Attrs       []xml.Attr     `xml:",any,attr"`
+	Attrs       Attrs          `xml:",any,attr"`
 }
 
-// DiagramEdge describes a directed/undirected edge.
+// DiagramEdge describes a directed/undirected edge. Points are waypoints the edge is routed
+// through between its end nodes, in order; an edge without them is a straight line.
 type DiagramEdge struct {
 	From     string         `xml:"from,attr"`
 	To       string         `xml:"to,attr"`
@@ -51,36 +106,53 @@ type DiagramEdge struct {
 	Directed *bool          `xml:"directed,attr"`
 	Weight   string         `xml:"weight,attr"`
 	Styles   []DiagramStyle `xml:"style"`
-	Attrs    []xml.Attr     `xml:",any,attr"`
+	Points   []DiagramPoint `xml:"point"`
+	Attrs    Attrs          `xml:",any,attr"`
+}
+
+// DiagramPoint is an edge waypoint, written <point x="10" y="4"/> with z optional.
+type DiagramPoint struct {
+	X string `xml:"x,attr"`
+	Y string `xml:"y,attr"`
+	Z string `xml:"z,attr"`
 }
 
 // DiagramStyle carries styling hints.
 type DiagramStyle struct {
-	Color     string     `xml:"color,attr"`
-	Shape     string     `xml:"shape,attr"`
-	Size      string     `xml:"size,attr"`
-	Stroke    string     `xml:"stroke,attr"`
-	Width     string     `xml:"width,attr"`
-	Dash      string     `xml:"dash,attr"`
-	Curvature string     `xml:"curvature,attr"`
-	Texture   string     `xml:"texture,attr"`
-	Attrs     []xml.Attr `xml:",any,attr"`
+	Color     string `xml:"color,attr"`
+	Shape     string `xml:"shape,attr"`
+	Size      string `xml:"size,attr"`
+	Stroke    string `xml:"stroke,attr"`
+	Width     string `xml:"width,attr"`
+	Dash      string `xml:"dash,attr"`
+	Curvature string `xml:"curvature,attr"`
+	Texture   string `xml:"texture,attr"`
+	Attrs     Attrs  `xml:",any,attr"`
 }
 
 // DiagramLayer describes background/overlay layers.
 type DiagramLayer struct {
-	ID    string     `xml:"id,attr"`
-	Z     string     `xml:"z,attr"`
-	Kind  string     `xml:"kind,attr"`
-	Attrs []xml.Attr `xml:",any,attr"`
+	ID    string `xml:"id,attr"`
+	Z     string `xml:"z,attr"`
+	Kind  string `xml:"kind,attr"`
+	Attrs Attrs  `xml:",any,attr"`
 }
 
 // DiagramCamera defines camera positioning.
 type DiagramCamera struct {
-	Azimuth   string     `xml:"azimuth,attr"`
-	Elevation string     `xml:"elevation,attr"`
-	Distance  string     `xml:"distance,attr"`
-	Attrs     []xml.Attr `xml:",any,attr"`
+	Azimuth   string `xml:"azimuth,attr"`
+	Elevation string `xml:"elevation,attr"`
+	Distance  string `xml:"distance,attr"`
+	Attrs     Attrs  `xml:",any,attr"`
+}
+
+// DiagramFrame is a keyframe at time T. Each <node id="..."> inside it overrides that node's
+// x/y/z, label, pct_complete, or style at T; attributes left empty keep the node's own value.
+// Scene.At interpolates between keyframes.
+type DiagramFrame struct {
+	T     string        `xml:"t,attr"`
+	Nodes []DiagramNode `xml:"node"`
+	Attrs Attrs         `xml:",any,attr"`
 }
 
 // DiagramData carries arbitrary JSON-ish payload keyed by name.
@@ -95,8 +167,11 @@ type Scene struct {
 	Nodes  []SceneNode    `json:"nodes"`
 	Edges  []SceneEdge    `json:"edges"`
 	Layers []SceneLayer   `json:"layers,omitempty"`
+	Groups []SceneGroup   `json:"groups,omitempty"`
 	Camera SceneCamera    `json:"camera"`
 	Meta   map[string]any `json:"meta,omitempty"`
+	// Keyframes are sorted by T; see At and Timeline.
+	Keyframes []SceneKeyframe `json:"keyframes,omitempty"`
 }
 
 type SceneNode struct {
@@ -120,6 +195,11 @@ type SceneEdge struct {
 	Weight   string            `json:"weight,omitempty"`
 	Style    map[string]string `json:"style,omitempty"`
 	Attrs    map[string]string `json:"attrs,omitempty"`
+	// Waypoints are the edge's <point> children. Path, set only when there are waypoints, is
+	// the polyline from the source node's position through them to the target's, the shape
+	// deck.gl's PathLayer draws.
+	Waypoints [][3]float64 `json:"waypoints,omitempty"`
+	Path      [][3]float64 `json:"path,omitempty"`
 }
 
 type SceneLayer struct {
@@ -129,6 +209,34 @@ type SceneLayer struct {
 	Attrs map[string]string `json:"attrs,omitempty"`
 }
 
+// SceneGroup is a node container; Members lists the IDs of nodes directly inside it.
+type SceneGroup struct {
+	ID      string            `json:"id"`
+	Label   string            `json:"label,omitempty"`
+	Parent  string            `json:"parent,omitempty"`
+	Members []string          `json:"members,omitempty"`
+	Style   map[string]string `json:"style,omitempty"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// SceneKeyframe overrides node properties at time T.
+type SceneKeyframe struct {
+	T     float64          `json:"t"`
+	Nodes []SceneNodeFrame `json:"nodes"`
+}
+
+// SceneNodeFrame is one node's overrides within a keyframe; nil coordinates and empty strings
+// keep the node's own value.
+type SceneNodeFrame struct {
+	ID          string            `json:"id"`
+	X           *float64          `json:"x,omitempty"`
+	Y           *float64          `json:"y,omitempty"`
+	Z           *float64          `json:"z,omitempty"`
+	Label       string            `json:"label,omitempty"`
+	PctComplete string            `json:"pct_complete,omitempty"`
+	Style       map[string]string `json:"style,omitempty"`
+}
+
 type SceneCamera struct {
 	Azimuth   string `json:"azimuth,omitempty"`
 	Elevation string `json:"elevation,omitempty"`
@@ -174,6 +282,7 @@ func DiagramToSceneWithOptions(d Diagram, opts SceneExportOptions) (Scene, error
 	nodes := append([]DiagramNode(nil), d.Graph.Nodes...)
 	edges := append([]DiagramEdge(nil), d.Graph.Edges...)
 	layers := append([]DiagramLayer(nil), d.Layers...)
+	groups := append([]DiagramGroup(nil), d.Graph.Groups...)
 	if deterministic {
 		sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
 		sort.Slice(edges, func(i, j int) bool {
@@ -195,7 +304,9 @@ func DiagramToSceneWithOptions(d Diagram, opts SceneExportOptions) (Scene, error
 			}
 			return layers[i].ID < layers[j].ID
 		})
+		sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
 	}
+	owner := diagramGroupOwners(d.Graph)
 	for _, n := range nodes {
 		pos := [3]float64{parseFloat(n.X), parseFloat(n.Y), parseFloat(n.Z)}
 		node := SceneNode{
@@ -209,6 +320,9 @@ func DiagramToSceneWithOptions(d Diagram, opts SceneExportOptions) (Scene, error
 			Style:       styleMap(n.Styles),
 			Attrs:       attrsMap(n.Attrs),
 		}
+		if g, ok := owner[n.ID]; ok {
+			node.Group = g
+		}
 		for _, ds := range n.Data {
 			if ds.Key == "tags" {
 				if tags, ok := parseStringArray(ds.Body); ok {
@@ -218,12 +332,16 @@ func DiagramToSceneWithOptions(d Diagram, opts SceneExportOptions) (Scene, error
 		}
 		scene.Nodes = append(scene.Nodes, node)
 	}
+	positions := make(map[string][3]float64, len(scene.Nodes))
+	for _, n := range scene.Nodes {
+		positions[n.ID] = n.Position
+	}
 	for _, e := range edges {
 		directed := false
 		if e.Directed != nil {
 			directed = *e.Directed
 		}
-		scene.Edges = append(scene.Edges, SceneEdge{
+		se := SceneEdge{
 			From:     e.From,
 			To:       e.To,
 			Kind:     e.Kind,
@@ -231,7 +349,14 @@ func DiagramToSceneWithOptions(d Diagram, opts SceneExportOptions) (Scene, error
 			Weight:   e.Weight,
 			Style:    styleMap(e.Styles),
 			Attrs:    attrsMap(e.Attrs),
-		})
+		}
+		for _, p := range e.Points {
+			se.Waypoints = append(se.Waypoints, [3]float64{parseFloat(p.X), parseFloat(p.Y), parseFloat(p.Z)})
+		}
+		if len(se.Waypoints) > 0 {
+			se.Path = append(append([][3]float64{positions[e.From]}, se.Waypoints...), positions[e.To])
+		}
+		scene.Edges = append(scene.Edges, se)
 	}
 	for _, l := range layers {
 		scene.Layers = append(scene.Layers, SceneLayer{
@@ -241,29 +366,85 @@ func DiagramToSceneWithOptions(d Diagram, opts SceneExportOptions) (Scene, error
 			Attrs: attrsMap(l.Attrs),
 		})
 	}
+	for _, g := range groups {
+		sg := SceneGroup{
+			ID:     g.ID,
+			Label:  g.Label,
+			Parent: g.Parent,
+			Style:  styleMap(g.Styles),
+			Attrs:  attrsMap(g.Attrs),
+		}
+		for _, n := range nodes {
+			if owner[n.ID] == g.ID {
+				sg.Members = append(sg.Members, n.ID)
+			}
+		}
+		scene.Groups = append(scene.Groups, sg)
+	}
+	for _, f := range d.Frames {
+		kf := SceneKeyframe{T: parseFloat(f.T)}
+		for _, n := range f.Nodes {
+			kf.Nodes = append(kf.Nodes, SceneNodeFrame{
+				ID:          n.ID,
+				X:           parseOptionalFloat(n.X),
+				Y:           parseOptionalFloat(n.Y),
+				Z:           parseOptionalFloat(n.Z),
+				Label:       n.Label,
+				PctComplete: n.PctComplete,
+				Style:       styleMap(n.Styles),
+			})
+		}
+		if deterministic {
+			sort.SliceStable(kf.Nodes, func(i, j int) bool { return kf.Nodes[i].ID < kf.Nodes[j].ID })
+		}
+		scene.Keyframes = append(scene.Keyframes, kf)
+	}
+	sort.SliceStable(scene.Keyframes, func(i, j int) bool { return scene.Keyframes[i].T < scene.Keyframes[j].T })
 	if len(scene.Meta) == 0 {
 		scene.Meta = nil
 	}
 	return scene, nil
 }
 
+// diagramGroupOwners maps node IDs to the group that contains them. Explicit <member> entries
+// win (first group listed first); otherwise a node's group attribute counts when it names a
+// declared group.
+func diagramGroupOwners(g DiagramGraph) map[string]string {
+	owner := make(map[string]string)
+	declared := make(map[string]bool, len(g.Groups))
+	for _, grp := range g.Groups {
+		declared[grp.ID] = true
+		for _, m := range grp.Members {
+			if _, ok := owner[m.Node]; !ok {
+				owner[m.Node] = grp.ID
+			}
+		}
+	}
+	for _, n := range g.Nodes {
+		if _, ok := owner[n.ID]; !ok && n.Group != "" && declared[n.Group] {
+			owner[n.ID] = n.Group
+		}
+	}
+	return owner
+}
+
 // ValidateDiagram performs structural validation of a diagram.
 func ValidateDiagram(d Diagram) error {
 	var errs []string
 	var details []ValidationDetail
 	if strings.TrimSpace(d.ID) == "" {
 		errs = append(errs, "diagram missing id")
-		details = append(details, ValidationDetail{Element: ElementDiagram, Field: "id", Message: "missing id"})
+		details = append(details, ValidationDetail{Code: CodeDiagramID, Element: ElementDiagram, Field: "id", Message: "missing id"})
 	}
 	nodeIDs := make(map[string]struct{})
 	for i, n := range d.Graph.Nodes {
 		if strings.TrimSpace(n.ID) == "" {
 			errs = append(errs, fmt.Sprintf("node[%d] missing id", i))
-			details = append(details, ValidationDetail{Element: ElementDiagram, Field: "node.id", Message: fmt.Sprintf("node %d missing id", i)})
+			details = append(details, ValidationDetail{Code: CodeDiagramNodeID, Element: ElementDiagram, Field: "node.id", Message: fmt.Sprintf("node %d missing id", i)})
 		} else {
 			if _, dup := nodeIDs[n.ID]; dup {
 				errs = append(errs, "duplicate node id "+n.ID)
-				details = append(details, ValidationDetail{Element: ElementDiagram, Field: "node.id", Message: fmt.Sprintf("duplicate node id %s", n.ID)})
+				details = append(details, ValidationDetail{Code: CodeDiagramNodeDuplicate, Element: ElementDiagram, Field: "node.id", Message: fmt.Sprintf("duplicate node id %s", n.ID)})
 			}
 			nodeIDs[n.ID] = struct{}{}
 		}
@@ -271,24 +452,86 @@ func ValidateDiagram(d Diagram) error {
 	for i, e := range d.Graph.Edges {
 		if strings.TrimSpace(e.From) == "" || strings.TrimSpace(e.To) == "" {
 			errs = append(errs, fmt.Sprintf("edge[%d] missing from/to", i))
-			details = append(details, ValidationDetail{Element: ElementDiagram, Field: "edge.from_to", Message: fmt.Sprintf("edge %d missing from/to", i)})
+			details = append(details, ValidationDetail{Code: CodeDiagramEdgeEnds, Element: ElementDiagram, Field: "edge.from_to", Message: fmt.Sprintf("edge %d missing from/to", i)})
 		} else {
 			if _, ok := nodeIDs[e.From]; !ok {
 				errs = append(errs, "edge from references missing node "+e.From)
-				details = append(details, ValidationDetail{Element: ElementDiagram, Field: "edge.from", Message: fmt.Sprintf("edge %d references missing node %s", i, e.From)})
+				details = append(details, ValidationDetail{Code: CodeDiagramEdgeRef, Element: ElementDiagram, Field: "edge.from", Message: fmt.Sprintf("edge %d references missing node %s", i, e.From)})
 			}
 			if _, ok := nodeIDs[e.To]; !ok {
 				errs = append(errs, "edge to references missing node "+e.To)
-				details = append(details, ValidationDetail{Element: ElementDiagram, Field: "edge.to", Message: fmt.Sprintf("edge %d references missing node %s", i, e.To)})
+				details = append(details, ValidationDetail{Code: CodeDiagramEdgeRef, Element: ElementDiagram, Field: "edge.to", Message: fmt.Sprintf("edge %d references missing node %s", i, e.To)})
 			}
 		}
 		if e.Directed == nil {
 			errs = append(errs, fmt.Sprintf("edge[%d] missing directed flag", i))
-			details = append(details, ValidationDetail{Element: ElementDiagram, Field: "edge.directed", Message: fmt.Sprintf("edge %d missing directed flag", i)})
+			details = append(details, ValidationDetail{Code: CodeDiagramEdgeDirected, Element: ElementDiagram, Field: "edge.directed", Message: fmt.Sprintf("edge %d missing directed flag", i)})
+		}
+		for j, p := range e.Points {
+			if parseOptionalFloat(p.X) == nil || parseOptionalFloat(p.Y) == nil || p.Z != "" && parseOptionalFloat(p.Z) == nil {
+				errs = append(errs, fmt.Sprintf("edge[%d] point[%d] has invalid coordinates", i, j))
+				details = append(details, ValidationDetail{Code: CodeDiagramEdgePoint, Element: ElementDiagram, Field: "edge.point", Message: fmt.Sprintf("edge %d point %d needs numeric x and y", i, j)})
+			}
+		}
+	}
+	groupIDs := make(map[string]DiagramGroup)
+	for i, g := range d.Graph.Groups {
+		if strings.TrimSpace(g.ID) == "" {
+			errs = append(errs, fmt.Sprintf("group[%d] missing id", i))
+			details = append(details, ValidationDetail{Code: CodeDiagramGroupID, Element: ElementDiagram, Field: "group.id", Message: fmt.Sprintf("group %d missing id", i)})
+			continue
+		}
+		if _, dup := groupIDs[g.ID]; dup {
+			errs = append(errs, "duplicate group id "+g.ID)
+			details = append(details, ValidationDetail{Code: CodeDiagramGroupDuplicate, Element: ElementDiagram, Field: "group.id", Message: fmt.Sprintf("duplicate group id %s", g.ID)})
+		}
+		groupIDs[g.ID] = g
+	}
+	memberOf := make(map[string]string)
+	for _, g := range d.Graph.Groups {
+		for _, m := range g.Members {
+			if _, ok := nodeIDs[m.Node]; !ok {
+				errs = append(errs, fmt.Sprintf("group %s member references missing node %s", g.ID, m.Node))
+				details = append(details, ValidationDetail{Code: CodeDiagramGroupMember, Element: ElementDiagram, Field: "group.member", Message: fmt.Sprintf("group %s references missing node %s", g.ID, m.Node)})
+			}
+			if prev, ok := memberOf[m.Node]; ok && prev != g.ID {
+				errs = append(errs, fmt.Sprintf("node %s is a member of groups %s and %s", m.Node, prev, g.ID))
+				details = append(details, ValidationDetail{Code: CodeDiagramGroupOverlap, Element: ElementDiagram, Field: "group.member", Message: fmt.Sprintf("node %s belongs to more than one group", m.Node)})
+			}
+			memberOf[m.Node] = g.ID
+		}
+		if g.Parent == "" {
+			continue
+		}
+		if _, ok := groupIDs[g.Parent]; !ok {
+			errs = append(errs, fmt.Sprintf("group %s parent references missing group %s", g.ID, g.Parent))
+			details = append(details, ValidationDetail{Code: CodeDiagramGroupParent, Element: ElementDiagram, Field: "group.parent", Message: fmt.Sprintf("group %s references missing parent %s", g.ID, g.Parent)})
+			continue
+		}
+		seen := map[string]bool{g.ID: true}
+		for p := g.Parent; p != ""; p = groupIDs[p].Parent {
+			if seen[p] {
+				errs = append(errs, "group parent cycle at "+g.ID)
+				details = append(details, ValidationDetail{Code: CodeDiagramGroupCycle, Element: ElementDiagram, Field: "group.parent", Message: fmt.Sprintf("group %s is its own ancestor", g.ID)})
+				break
+			}
+			seen[p] = true
+		}
+	}
+	for i, f := range d.Frames {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(f.T), 64); err != nil {
+			errs = append(errs, fmt.Sprintf("frame[%d] has invalid time %q", i, f.T))
+			details = append(details, ValidationDetail{Code: CodeDiagramFrameTime, Element: ElementDiagram, Field: "frame.t", Message: fmt.Sprintf("frame %d time %q is not a number", i, f.T)})
+		}
+		for _, n := range f.Nodes {
+			if _, ok := nodeIDs[n.ID]; !ok {
+				errs = append(errs, fmt.Sprintf("frame[%d] references missing node %s", i, n.ID))
+				details = append(details, ValidationDetail{Code: CodeDiagramFrameNode, Element: ElementDiagram, Field: "frame.node", Message: fmt.Sprintf("frame %d references missing node %s", i, n.ID)})
+			}
 		}
 	}
 	if len(errs) > 0 {
-		return &ValidationError{Issues: errs, Details: details}
+		return &ValidationError{Issues: errs, Details: withSeverities(details)}
 	}
 	return nil
 }
@@ -304,6 +547,16 @@ func parseFloat(val string) float64 {
 	return f
 }
 
+// parseOptionalFloat is parseFloat for attributes where absence matters: it returns nil for an
+// empty or malformed value.
+func parseOptionalFloat(val string) *float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
+
 func styleMap(styles []DiagramStyle) map[string]string {
 	var m map[string]string
 	set := func(k, v string) {
@@ -0,0 +1,174 @@
+package poml
+
+import "encoding/xml"
+
+// DiagramBuilder assembles a Diagram in code with typed coordinates; Build validates the
+// result. Attach it to a document with Builder.Diagram:
+//
+//	d, err := NewDiagramBuilder().ID("plan").
+//		Node("a", NodeLabel("Start"), NodeAt(0, 0, 0)).
+//		Node("b", NodeAt(1, 0, 0)).
+//		Edge("a", "b", EdgeWeight(2)).
+//		Build()
+//	if err != nil { ... }
+//	doc := NewBuilder().Diagram(d).Build()
+type DiagramBuilder struct {
+	d Diagram
+}
+
+// NodeOption sets a field of a node added with DiagramBuilder.Node.
+type NodeOption func(*DiagramNode)
+
+// EdgeOption sets a field of an edge added with DiagramBuilder.Edge.
+type EdgeOption func(*DiagramEdge)
+
+// NewDiagramBuilder creates an empty diagram builder.
+func NewDiagramBuilder() *DiagramBuilder {
+	return &DiagramBuilder{}
+}
+
+// ID sets the diagram id, which validation requires.
+func (b *DiagramBuilder) ID(id string) *DiagramBuilder {
+	b.d.ID = id
+	return b
+}
+
+// Projection sets the projection attribute (for example "isometric" or "orthographic").
+func (b *DiagramBuilder) Projection(projection string) *DiagramBuilder {
+	b.d.Projection = projection
+	return b
+}
+
+// Layout sets the layout attribute (for example "dagre", "grid", or "manual").
+func (b *DiagramBuilder) Layout(layout string) *DiagramBuilder {
+	b.d.Layout = layout
+	return b
+}
+
+// Unit sets the unit coordinates are measured in.
+func (b *DiagramBuilder) Unit(unit string) *DiagramBuilder {
+	b.d.Unit = unit
+	return b
+}
+
+// Node appends a node.
+func (b *DiagramBuilder) Node(id string, opts ...NodeOption) *DiagramBuilder {
+	n := DiagramNode{ID: id}
+	for _, opt := range opts {
+		opt(&n)
+	}
+	b.d.Graph.Nodes = append(b.d.Graph.Nodes, n)
+	return b
+}
+
+// Edge appends an edge from one node to another. Edges are directed unless EdgeUndirected is given.
+func (b *DiagramBuilder) Edge(from, to string, opts ...EdgeOption) *DiagramBuilder {
+	e := DiagramEdge{From: from, To: to, Directed: ptrBool(true)}
+	for _, opt := range opts {
+		opt(&e)
+	}
+	b.d.Graph.Edges = append(b.d.Graph.Edges, e)
+	return b
+}
+
+// Group appends a group holding the given node IDs.
+func (b *DiagramBuilder) Group(id, label string, members ...string) *DiagramBuilder {
+	g := DiagramGroup{ID: id, Label: label}
+	for _, m := range members {
+		g.Members = append(g.Members, DiagramMember{Node: m})
+	}
+	b.d.Graph.Groups = append(b.d.Graph.Groups, g)
+	return b
+}
+
+// Layer appends a layer at depth z.
+func (b *DiagramBuilder) Layer(id string, z float64, kind string) *DiagramBuilder {
+	b.d.Layers = append(b.d.Layers, DiagramLayer{ID: id, Z: formatFloat(z), Kind: kind})
+	return b
+}
+
+// Camera sets the camera's azimuth and elevation (degrees) and its distance.
+func (b *DiagramBuilder) Camera(azimuth, elevation, distance float64) *DiagramBuilder {
+	b.d.Camera = DiagramCamera{Azimuth: formatFloat(azimuth), Elevation: formatFloat(elevation), Distance: formatFloat(distance)}
+	return b
+}
+
+// Build returns a copy of the assembled diagram, or the ValidateDiagram error when it is
+// incomplete (missing id, edges to unknown nodes, duplicate IDs, ...).
+func (b *DiagramBuilder) Build() (Diagram, error) {
+	d := cloneDiagram(b.d)
+	if err := ValidateDiagram(d); err != nil {
+		return Diagram{}, err
+	}
+	return d, nil
+}
+
+// NodeLabel sets a node's label.
+func NodeLabel(label string) NodeOption {
+	return func(n *DiagramNode) { n.Label = label }
+}
+
+// NodeAt places a node at x, y, z.
+func NodeAt(x, y, z float64) NodeOption {
+	return func(n *DiagramNode) { n.X, n.Y, n.Z = formatFloat(x), formatFloat(y), formatFloat(z) }
+}
+
+// NodeGroup sets a node's group attribute.
+func NodeGroup(group string) NodeOption {
+	return func(n *DiagramNode) { n.Group = group }
+}
+
+// NodeOwner sets a node's owner.
+func NodeOwner(owner string) NodeOption {
+	return func(n *DiagramNode) { n.Owner = owner }
+}
+
+// NodeWeight sets a node's weight.
+func NodeWeight(weight float64) NodeOption {
+	return func(n *DiagramNode) { n.Weight = formatFloat(weight) }
+}
+
+// NodeProgress sets a node's pct_complete, the fraction done (0.45 for 45%).
+func NodeProgress(pct float64) NodeOption {
+	return func(n *DiagramNode) { n.PctComplete = formatFloat(pct) }
+}
+
+// NodeStyle appends a style to a node.
+func NodeStyle(style DiagramStyle) NodeOption {
+	return func(n *DiagramNode) { n.Styles = append(n.Styles, style) }
+}
+
+// NodeData attaches a <data key="..."> entry to a node; body is inserted as markup.
+func NodeData(key, body string) NodeOption {
+	return func(n *DiagramNode) { n.Data = append(n.Data, DiagramData{Key: key, Body: body}) }
+}
+
+// NodeAttr adds an extension attribute to a node.
+func NodeAttr(name, value string) NodeOption {
+	return func(n *DiagramNode) { n.Attrs = append(n.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value}) }
+}
+
+// EdgeKind sets an edge's kind (for example "depends").
+func EdgeKind(kind string) EdgeOption {
+	return func(e *DiagramEdge) { e.Kind = kind }
+}
+
+// EdgeUndirected marks an edge as undirected.
+func EdgeUndirected() EdgeOption {
+	return func(e *DiagramEdge) { e.Directed = ptrBool(false) }
+}
+
+// EdgeWeight sets an edge's weight.
+func EdgeWeight(weight float64) EdgeOption {
+	return func(e *DiagramEdge) { e.Weight = formatFloat(weight) }
+}
+
+// EdgeStyle appends a style to an edge.
+func EdgeStyle(style DiagramStyle) EdgeOption {
+	return func(e *DiagramEdge) { e.Styles = append(e.Styles, style) }
+}
+
+// EdgeAttr adds an extension attribute to an edge.
+func EdgeAttr(name, value string) EdgeOption {
+	return func(e *DiagramEdge) { e.Attrs = append(e.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value}) }
+}
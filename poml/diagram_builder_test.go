@@ -0,0 +1,63 @@
+package poml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDiagramBuilderBuildsValidDiagram(t *testing.T) {
+	d, err := NewDiagramBuilder().ID("plan").Layout("dagre").
+		Node("a", NodeLabel("Start"), NodeAt(0, 1.5, 0), NodeWeight(0.25), NodeStyle(DiagramStyle{Color: "#fff"})).
+		Node("b", NodeAt(2, 0, -1), NodeProgress(0.5), NodeData("tags", `["x"]`)).
+		Edge("a", "b", EdgeKind("depends"), EdgeWeight(3)).
+		Edge("b", "a", EdgeUndirected()).
+		Group("g1", "Phase 1", "a", "b").
+		Layer("grid", -1, "grid").
+		Camera(35, 30, 8).
+		Build()
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if n := d.Graph.Nodes[0]; n.X != "0" || n.Y != "1.5" || n.Weight != "0.25" || n.Label != "Start" {
+		t.Fatalf("unexpected node: %+v", n)
+	}
+	if e := d.Graph.Edges; !*e[0].Directed || *e[1].Directed || e[0].Weight != "3" {
+		t.Fatalf("unexpected edges: %+v", e)
+	}
+	if d.Camera.Azimuth != "35" || d.Layers[0].Z != "-1" {
+		t.Fatalf("unexpected camera or layer: %+v %+v", d.Camera, d.Layers)
+	}
+
+	doc := NewBuilder().Meta("id", "1", "o").Role("r").Task("t").Diagram(d).Build()
+	var buf bytes.Buffer
+	if err := doc.Encode(&buf); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	back, err := ParseString(buf.String())
+	if err != nil {
+		t.Fatalf("reparse: %v\n%s", err, buf.String())
+	}
+	scene, err := DiagramToScene(back.Diagrams[0])
+	if err != nil {
+		t.Fatalf("scene: %v", err)
+	}
+	if scene.Nodes[1].Position != [3]float64{2, 0, -1} || len(scene.Groups) != 1 {
+		t.Fatalf("unexpected scene: %+v", scene)
+	}
+}
+
+func TestDiagramBuilderReportsInvalidDiagram(t *testing.T) {
+	_, err := NewDiagramBuilder().Node("a").Node("a").Edge("a", "missing").Build()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	msg := err.Error()
+	for _, want := range []string{"diagram missing id", "duplicate node id a", "missing node missing"} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("missing %q in %v", want, msg)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package poml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DiagramDiff lists the structural differences between two diagrams. Nodes match by id and
+// edges by from, to, and kind; removed and changed items keep a's order, added items b's.
+// Numeric attributes (x, y, z, weight, pct_complete) compare by value, so "1" and "1.0" are
+// the same position.
+type DiagramDiff struct {
+	AddedNodes   []DiagramNode
+	RemovedNodes []DiagramNode
+	ChangedNodes []DiagramNodeChange
+	AddedEdges   []DiagramEdge
+	RemovedEdges []DiagramEdge
+	ChangedEdges []DiagramEdgeChange
+}
+
+// DiagramNodeChange is a node both diagrams define differently. Fields names what differs:
+// attribute names such as label or x, "style" for <style> children, and "data" for <data>.
+type DiagramNodeChange struct {
+	Before DiagramNode
+	After  DiagramNode
+	Fields []string
+}
+
+// DiagramEdgeChange is an edge both diagrams define differently; see DiagramNodeChange.
+type DiagramEdgeChange struct {
+	Before DiagramEdge
+	After  DiagramEdge
+	Fields []string
+}
+
+// Empty reports whether the diagrams have the same nodes and edges.
+func (d DiagramDiff) Empty() bool {
+	return len(d.AddedNodes)+len(d.RemovedNodes)+len(d.ChangedNodes)+len(d.AddedEdges)+len(d.RemovedEdges)+len(d.ChangedEdges) == 0
+}
+
+// String renders one line per change, nodes first, in the marks Change uses:
+// "+ node c", "- edge a->b", "~ node a: label, x".
+func (d DiagramDiff) String() string {
+	var b strings.Builder
+	line := func(mark, item, key string, fields []string) {
+		fmt.Fprintf(&b, "%s %s %s", mark, item, key)
+		if len(fields) > 0 {
+			b.WriteString(": " + strings.Join(fields, ", "))
+		}
+		b.WriteByte('\n')
+	}
+	for _, n := range d.RemovedNodes {
+		line("-", "node", n.ID, nil)
+	}
+	for _, c := range d.ChangedNodes {
+		line("~", "node", c.Before.ID, c.Fields)
+	}
+	for _, n := range d.AddedNodes {
+		line("+", "node", n.ID, nil)
+	}
+	for _, e := range d.RemovedEdges {
+		line("-", "edge", diagramEdgeKey(e), nil)
+	}
+	for _, c := range d.ChangedEdges {
+		line("~", "edge", diagramEdgeKey(c.Before), c.Fields)
+	}
+	for _, e := range d.AddedEdges {
+		line("+", "edge", diagramEdgeKey(e), nil)
+	}
+	return b.String()
+}
+
+// DiffDiagram compares the graphs of a and b node by node and edge by edge, so regenerated
+// diagrams can be reviewed structurally instead of as XML text. Groups, layers, frames, and
+// the camera are not compared.
+func DiffDiagram(a, b Diagram) DiagramDiff {
+	var out DiagramDiff
+	nodesB := keyedItems(b.Graph.Nodes, diagramNodeKey)
+	seen := map[string]bool{}
+	for i, key := range itemKeys(a.Graph.Nodes, diagramNodeKey) {
+		seen[key] = true
+		before := a.Graph.Nodes[i]
+		after, ok := nodesB[key]
+		if !ok {
+			out.RemovedNodes = append(out.RemovedNodes, before)
+		} else if fields := changedFields(diagramNodeFields(before), diagramNodeFields(after)); len(fields) > 0 {
+			out.ChangedNodes = append(out.ChangedNodes, DiagramNodeChange{Before: before, After: after, Fields: fields})
+		}
+	}
+	for i, key := range itemKeys(b.Graph.Nodes, diagramNodeKey) {
+		if !seen[key] {
+			out.AddedNodes = append(out.AddedNodes, b.Graph.Nodes[i])
+		}
+	}
+	edgesB := keyedItems(b.Graph.Edges, diagramEdgeKey)
+	seen = map[string]bool{}
+	for i, key := range itemKeys(a.Graph.Edges, diagramEdgeKey) {
+		seen[key] = true
+		before := a.Graph.Edges[i]
+		after, ok := edgesB[key]
+		if !ok {
+			out.RemovedEdges = append(out.RemovedEdges, before)
+		} else if fields := changedFields(diagramEdgeFields(before), diagramEdgeFields(after)); len(fields) > 0 {
+			out.ChangedEdges = append(out.ChangedEdges, DiagramEdgeChange{Before: before, After: after, Fields: fields})
+		}
+	}
+	for i, key := range itemKeys(b.Graph.Edges, diagramEdgeKey) {
+		if !seen[key] {
+			out.AddedEdges = append(out.AddedEdges, b.Graph.Edges[i])
+		}
+	}
+	return out
+}
+
+// DiagramMergePolicy decides which side wins when both diagrams define the same item (a node,
+// edge, group, layer, frame, the camera, or a diagram attribute) differently.
+type DiagramMergePolicy string
+
+const (
+	DiagramPreferOverlay DiagramMergePolicy = "prefer_overlay" // the overlay's version wins; the default
+	DiagramPreferBase    DiagramMergePolicy = "prefer_base"    // the base's version wins
+	DiagramMergeError    DiagramMergePolicy = "error"          // any conflict fails the merge
+)
+
+// MergeDiagrams combines base and overlay into a new diagram. Items match as in DiffDiagram,
+// with groups and layers by id and frames by time; the result keeps base's items in order,
+// resolving conflicts with policy, followed by the overlay's new items in their order. Diagram
+// attributes the base leaves empty are taken from the overlay. With DiagramMergeError the
+// error is a POMLError with CodeMerge naming every conflict. Neither input is modified.
+func MergeDiagrams(base, overlay Diagram, policy DiagramMergePolicy) (Diagram, error) {
+	switch policy {
+	case "":
+		policy = DiagramPreferOverlay
+	case DiagramPreferOverlay, DiagramPreferBase, DiagramMergeError:
+	default:
+		return Diagram{}, fmt.Errorf("merge diagrams: unknown policy %q", policy)
+	}
+	base, overlay = cloneDiagram(base), cloneDiagram(overlay)
+	var conflicts []string
+	// resolve reports whether the overlay's value replaces the base's differing one.
+	resolve := func(what string) bool {
+		conflicts = append(conflicts, what)
+		return policy == DiagramPreferOverlay
+	}
+	out := base
+	for _, f := range []struct {
+		name   string
+		dst    *string
+		theirs string
+	}{{"id", &out.ID, overlay.ID}, {"projection", &out.Projection, overlay.Projection}, {"layout", &out.Layout, overlay.Layout}, {"unit", &out.Unit, overlay.Unit}} {
+		switch {
+		case f.theirs == "" || f.theirs == *f.dst:
+		case *f.dst == "" || resolve("diagram "+f.name):
+			*f.dst = f.theirs
+		}
+	}
+	for _, a := range overlay.Attrs {
+		name := yamlAttrName(a.Name)
+		if v, ok := out.Attrs.Lookup(name); !ok || (v != a.Value && resolve("diagram "+name)) {
+			out.Attrs.Set(name, a.Value)
+		}
+	}
+	switch {
+	case reflect.DeepEqual(overlay.Camera, DiagramCamera{}) || reflect.DeepEqual(out.Camera, overlay.Camera):
+	case reflect.DeepEqual(out.Camera, DiagramCamera{}) || resolve("camera"):
+		out.Camera = overlay.Camera
+	}
+	same := func(a, b any) bool { return reflect.DeepEqual(a, b) }
+	out.Graph.Nodes = mergeItems(base.Graph.Nodes, overlay.Graph.Nodes, diagramNodeKey, func(a, b DiagramNode) bool {
+		return len(changedFields(diagramNodeFields(a), diagramNodeFields(b))) == 0
+	}, "node ", resolve)
+	out.Graph.Edges = mergeItems(base.Graph.Edges, overlay.Graph.Edges, diagramEdgeKey, func(a, b DiagramEdge) bool {
+		return len(changedFields(diagramEdgeFields(a), diagramEdgeFields(b))) == 0
+	}, "edge ", resolve)
+	out.Graph.Groups = mergeItems(base.Graph.Groups, overlay.Graph.Groups, func(g DiagramGroup) string { return strings.TrimSpace(g.ID) },
+		func(a, b DiagramGroup) bool { return same(a, b) }, "group ", resolve)
+	out.Layers = mergeItems(base.Layers, overlay.Layers, func(l DiagramLayer) string { return strings.TrimSpace(l.ID) },
+		func(a, b DiagramLayer) bool { return same(a, b) }, "layer ", resolve)
+	out.Frames = mergeItems(base.Frames, overlay.Frames, func(f DiagramFrame) string { return diagramNumber(f.T) },
+		func(a, b DiagramFrame) bool { return same(a, b) }, "frame t=", resolve)
+	if policy == DiagramMergeError && len(conflicts) > 0 {
+		return Diagram{}, &POMLError{Type: ErrValidate, Code: CodeMerge, Message: "merge diagrams: conflicting " + strings.Join(conflicts, ", ")}
+	}
+	return out, nil
+}
+
+// mergeItems keeps base's items, replacing those the overlay defines differently when resolve
+// says so, and appends the overlay's items with new keys.
+func mergeItems[T any](base, overlay []T, key func(T) string, equal func(a, b T) bool, label string, resolve func(string) bool) []T {
+	over := keyedItems(overlay, key)
+	out := base
+	seen := map[string]bool{}
+	for i, k := range itemKeys(base, key) {
+		seen[k] = true
+		if o, ok := over[k]; ok && !equal(base[i], o) && resolve(label+k) {
+			out[i] = o
+		}
+	}
+	for i, k := range itemKeys(overlay, key) {
+		if !seen[k] {
+			out = append(out, overlay[i])
+		}
+	}
+	return out
+}
+
+// itemKeys returns each item's key, suffixed with #n for its n-th repeat so duplicates pair up
+// by position.
+func itemKeys[T any](items []T, key func(T) string) []string {
+	used := map[string]int{}
+	keys := make([]string, len(items))
+	for i, it := range items {
+		k := key(it)
+		if n := used[k]; n > 0 {
+			keys[i] = fmt.Sprintf("%s#%d", k, n)
+		} else {
+			keys[i] = k
+		}
+		used[k]++
+	}
+	return keys
+}
+
+func keyedItems[T any](items []T, key func(T) string) map[string]T {
+	out := make(map[string]T, len(items))
+	for i, k := range itemKeys(items, key) {
+		out[k] = items[i]
+	}
+	return out
+}
+
+func diagramNodeKey(n DiagramNode) string { return strings.TrimSpace(n.ID) }
+
+// diagramEdgeKey identifies an edge as "from->to", with "[kind]" appended when it has one.
+func diagramEdgeKey(e DiagramEdge) string {
+	key := strings.TrimSpace(e.From) + "->" + strings.TrimSpace(e.To)
+	if kind := strings.TrimSpace(e.Kind); kind != "" {
+		key += "[" + kind + "]"
+	}
+	return key
+}
+
+func diagramNodeFields(n DiagramNode) map[string]string {
+	f := map[string]string{
+		"label": n.Label, "group": n.Group, "owner": n.Owner,
+		"weight": diagramNumber(n.Weight), "pct_complete": diagramNumber(n.PctComplete),
+		"x": diagramNumber(n.X), "y": diagramNumber(n.Y), "z": diagramNumber(n.Z),
+		"style": diagramChildXML(n.Styles), "data": diagramChildXML(n.Data),
+	}
+	addAttrFields(f, n.Attrs)
+	return f
+}
+
+func diagramEdgeFields(e DiagramEdge) map[string]string {
+	f := map[string]string{"weight": diagramNumber(e.Weight), "style": diagramChildXML(e.Styles)}
+	var points []string
+	for _, p := range e.Points {
+		points = append(points, diagramNumber(p.X)+","+diagramNumber(p.Y)+","+diagramNumber(p.Z))
+	}
+	f["points"] = strings.Join(points, " ")
+	if e.Directed != nil {
+		f["directed"] = strconv.FormatBool(*e.Directed)
+	}
+	addAttrFields(f, e.Attrs)
+	return f
+}
+
+func addAttrFields(f map[string]string, attrs Attrs) {
+	for _, a := range attrs {
+		f[yamlAttrName(a.Name)] = a.Value
+	}
+}
+
+// diagramNumber normalizes a numeric attribute so equal values compare equal; other text is
+// kept as written.
+func diagramNumber(v string) string {
+	if f := parseOptionalFloat(v); f != nil {
+		return strconv.FormatFloat(*f, 'g', -1, 64)
+	}
+	return v
+}
+
+func diagramChildXML(v any) string {
+	if reflect.ValueOf(v).Len() == 0 {
+		return ""
+	}
+	raw, err := xml.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(raw)
+}
+
+// changedFields returns the sorted names whose values differ between a and b.
+func changedFields(a, b map[string]string) []string {
+	var out []string
+	for k, v := range a {
+		if b[k] != v {
+			out = append(out, k)
+		}
+	}
+	for k, v := range b {
+		if _, ok := a[k]; !ok && v != "" {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
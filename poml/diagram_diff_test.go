@@ -0,0 +1,81 @@
+package poml
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func parseTestDiagram(t *testing.T, src string) Diagram {
+	t.Helper()
+	doc, err := ParseString("<poml>" + src + "</poml>")
+	if err != nil || len(doc.Diagrams) != 1 {
+		t.Fatalf("parse diagram: %v", err)
+	}
+	return doc.Diagrams[0]
+}
+
+func TestDiffDiagram(t *testing.T) {
+	a := parseTestDiagram(t, `<diagram id="plan"><graph>
+  <node id="a" label="Start" x="1"/>
+  <node id="b" label="Build"/>
+  <node id="c"/>
+  <edge from="a" to="b" directed="true"/>
+  <edge from="b" to="c" directed="true" weight="1"/>
+</graph></diagram>`)
+	b := parseTestDiagram(t, `<diagram id="plan"><graph>
+  <node id="a" label="Begin" x="1.0"><style color="red"/></node>
+  <node id="b" label="Build"/>
+  <node id="d"/>
+  <edge from="a" to="b" directed="true"/>
+  <edge from="b" to="d" directed="true"/>
+  <edge from="a" to="b" kind="blocks" directed="true"/>
+</graph></diagram>`)
+	d := DiffDiagram(a, b)
+	if len(d.ChangedNodes) != 1 || !reflect.DeepEqual(d.ChangedNodes[0].Fields, []string{"label", "style"}) {
+		t.Fatalf("unexpected node changes: %+v", d.ChangedNodes)
+	}
+	want := "- node c\n~ node a: label, style\n+ node d\n- edge b->c\n+ edge b->d\n+ edge a->b[blocks]\n"
+	if got := d.String(); got != want {
+		t.Fatalf("unexpected diff:\n%s", got)
+	}
+	if !DiffDiagram(a, a).Empty() {
+		t.Fatalf("a diagram should not differ from itself")
+	}
+}
+
+func TestMergeDiagrams(t *testing.T) {
+	base := parseTestDiagram(t, `<diagram id="plan" layout="grid"><graph>
+  <node id="a" label="Start"/>
+  <node id="b" label="Build"/>
+  <edge from="a" to="b" directed="true"/>
+</graph></diagram>`)
+	overlay := parseTestDiagram(t, `<diagram id="plan" unit="m"><graph>
+  <node id="b" label="Compile"/>
+  <node id="c" label="Ship"/>
+  <edge from="b" to="c" directed="true"/>
+</graph></diagram>`)
+
+	merged, err := MergeDiagrams(base, overlay, "")
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if merged.Layout != "grid" || merged.Unit != "m" || len(merged.Graph.Nodes) != 3 || merged.Graph.Nodes[1].Label != "Compile" || len(merged.Graph.Edges) != 2 {
+		t.Fatalf("unexpected merge: %+v", merged)
+	}
+	if err := ValidateDiagram(merged); err != nil {
+		t.Fatalf("merged diagram is invalid: %v", err)
+	}
+	if base.Graph.Nodes[1].Label != "Build" || len(base.Graph.Nodes) != 2 {
+		t.Fatalf("merge modified its input")
+	}
+
+	kept, err := MergeDiagrams(base, overlay, DiagramPreferBase)
+	if err != nil || kept.Graph.Nodes[1].Label != "Build" || len(kept.Graph.Nodes) != 3 {
+		t.Fatalf("prefer base: %+v %v", kept.Graph.Nodes, err)
+	}
+	_, err = MergeDiagrams(base, overlay, DiagramMergeError)
+	if !errors.Is(err, CodeMerge) || err.Error() != "merge diagrams: conflicting node b" {
+		t.Fatalf("expected a conflict error, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package poml
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNoNumber is returned by the numeric accessors (WeightValue, PctCompleteValue, ...) for an
+// attribute that is absent or empty, so callers can tell it from one that is malformed.
+var ErrNoNumber = errors.New("attribute has no value")
+
+// parseNumber parses an attribute written by a person or a tool: surrounding space is ignored,
+// empty is ErrNoNumber, and anything strconv cannot parse is an error naming field.
+func parseNumber(field, val string) (float64, error) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return 0, fmt.Errorf("%s: %w", field, ErrNoNumber)
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s %q is not a number", field, val)
+	}
+	return f, nil
+}
+
+// parsePercent is parseNumber for pct_complete, which is a fraction (0.45) but may also be
+// written as a percentage ("45%").
+func parsePercent(field, val string) (float64, error) {
+	trimmed := strings.TrimSpace(val)
+	if pct, ok := strings.CutSuffix(trimmed, "%"); ok {
+		f, err := parseNumber(field, pct)
+		if err != nil {
+			return 0, fmt.Errorf("%s %q is not a percentage", field, val)
+		}
+		return f / 100, nil
+	}
+	return parseNumber(field, trimmed)
+}
+
+// parsePosition parses x and y, which are required, and z, which defaults to 0.
+func parsePosition(x, y, z string) ([3]float64, error) {
+	var pos [3]float64
+	var err error
+	if pos[0], err = parseNumber("x", x); err != nil {
+		return pos, err
+	}
+	if pos[1], err = parseNumber("y", y); err != nil {
+		return pos, err
+	}
+	if pos[2], err = parseNumber("z", z); err != nil && !errors.Is(err, ErrNoNumber) {
+		return pos, err
+	}
+	return pos, nil
+}
+
+// WeightValue parses the node's weight.
+func (n DiagramNode) WeightValue() (float64, error) { return parseNumber("weight", n.Weight) }
+
+// PctCompleteValue parses pct_complete as a fraction, so "0.45" and "45%" both return 0.45.
+func (n DiagramNode) PctCompleteValue() (float64, error) {
+	return parsePercent("pct_complete", n.PctComplete)
+}
+
+// PositionValue parses x, y, and z; x and y are required and z defaults to 0.
+func (n DiagramNode) PositionValue() ([3]float64, error) { return parsePosition(n.X, n.Y, n.Z) }
+
+// SizeValue parses the node's style size; with several <style> children the last size wins.
+func (n DiagramNode) SizeValue() (float64, error) {
+	return parseNumber("size", styleMap(n.Styles)["size"])
+}
+
+// WeightValue parses the edge's weight.
+func (e DiagramEdge) WeightValue() (float64, error) { return parseNumber("weight", e.Weight) }
+
+// PositionValue parses the waypoint's x, y, and z; z defaults to 0.
+func (p DiagramPoint) PositionValue() ([3]float64, error) { return parsePosition(p.X, p.Y, p.Z) }
+
+// SizeValue parses the style's size.
+func (s DiagramStyle) SizeValue() (float64, error) { return parseNumber("size", s.Size) }
+
+// WidthValue parses the style's stroke width.
+func (s DiagramStyle) WidthValue() (float64, error) { return parseNumber("width", s.Width) }
+
+// WeightValue parses the node's weight.
+func (n SceneNode) WeightValue() (float64, error) { return parseNumber("weight", n.Weight) }
+
+// PctCompleteValue parses pct_complete as a fraction; see DiagramNode.PctCompleteValue.
+func (n SceneNode) PctCompleteValue() (float64, error) {
+	return parsePercent("pct_complete", n.PctComplete)
+}
+
+// SizeValue parses Style["size"].
+func (n SceneNode) SizeValue() (float64, error) { return parseNumber("size", n.Style["size"]) }
+
+// WeightValue parses the edge's weight.
+func (e SceneEdge) WeightValue() (float64, error) { return parseNumber("weight", e.Weight) }
+
+// WidthValue parses Style["width"].
+func (e SceneEdge) WidthValue() (float64, error) { return parseNumber("width", e.Style["width"]) }
+
+// ValidateDiagramOptions controls ValidateDiagramWithOptions.
+type ValidateDiagramOptions struct {
+	// StrictNumbers also reports, with CodeDiagramNumber, node and edge weights, pct_complete,
+	// node coordinates, and style sizes and widths that are present but not numbers, which
+	// DiagramToScene otherwise reads as zero. pct_complete must also lie between 0 and 1.
+	StrictNumbers bool
+}
+
+// ValidateDiagramWithOptions is ValidateDiagram with the checks opts enables.
+func ValidateDiagramWithOptions(d Diagram, opts ValidateDiagramOptions) error {
+	err := ValidateDiagram(d)
+	if !opts.StrictNumbers {
+		return err
+	}
+	details := diagramNumberDetails(d)
+	if len(details) == 0 {
+		return err
+	}
+	var ve *ValidationError
+	if err != nil && !errors.As(err, &ve) {
+		return err
+	}
+	if ve == nil {
+		ve = &ValidationError{}
+	}
+	for _, det := range details {
+		ve.Issues = append(ve.Issues, det.Message)
+		ve.Details = append(ve.Details, det)
+	}
+	return ve
+}
+
+// StrictDiagramNumbers is a Validator applying the StrictNumbers checks to every diagram of a
+// document, for use with RegisterValidator or ValidateOptions.Validators.
+func StrictDiagramNumbers() Validator {
+	return func(doc Document) []ValidationDetail {
+		var out []ValidationDetail
+		for i, dg := range doc.Diagrams {
+			for _, det := range diagramNumberDetails(dg) {
+				det.Message = fmt.Sprintf("diagram[%d]: %s", i, det.Message)
+				out = append(out, det)
+			}
+		}
+		return out
+	}
+}
+
+// diagramNumberDetails reports the numeric attributes of d that are present but malformed.
+func diagramNumberDetails(d Diagram) []ValidationDetail {
+	var out []ValidationDetail
+	check := func(field, what string, err error) {
+		if err == nil || errors.Is(err, ErrNoNumber) {
+			return
+		}
+		out = append(out, ValidationDetail{Code: CodeDiagramNumber, Element: ElementDiagram, Field: field, Message: what + ": " + err.Error(), Severity: CodeDiagramNumber.Severity()})
+	}
+	styles := func(field, what string, styles []DiagramStyle) {
+		for _, st := range styles {
+			_, err := st.SizeValue()
+			check(field+".style.size", what, err)
+			_, err = st.WidthValue()
+			check(field+".style.width", what, err)
+		}
+	}
+	for _, n := range d.Graph.Nodes {
+		what := "node " + n.ID
+		_, err := n.WeightValue()
+		check("node.weight", what, err)
+		pct, err := n.PctCompleteValue()
+		if err == nil && (pct < 0 || pct > 1) {
+			err = fmt.Errorf("pct_complete %q is outside 0..1", n.PctComplete)
+		}
+		check("node.pct_complete", what, err)
+		for _, c := range []struct{ name, val string }{{"x", n.X}, {"y", n.Y}, {"z", n.Z}} {
+			_, err := parseNumber(c.name, c.val)
+			check("node."+c.name, what, err)
+		}
+		styles("node", what, n.Styles)
+	}
+	for i, e := range d.Graph.Edges {
+		what := fmt.Sprintf("edge %d (%s -> %s)", i, e.From, e.To)
+		_, err := e.WeightValue()
+		check("edge.weight", what, err)
+		styles("edge", what, e.Styles)
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package poml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDiagramNumericAccessors(t *testing.T) {
+	n := DiagramNode{ID: "a", Weight: " 2.5 ", PctComplete: "45%", X: "1", Y: "-2", Styles: []DiagramStyle{{Size: "1"}, {Size: "1.5"}}}
+	if w, err := n.WeightValue(); err != nil || w != 2.5 {
+		t.Fatalf("weight = %v, %v", w, err)
+	}
+	if p, err := n.PctCompleteValue(); err != nil || p != 0.45 {
+		t.Fatalf("pct = %v, %v", p, err)
+	}
+	if pos, err := n.PositionValue(); err != nil || pos != [3]float64{1, -2, 0} {
+		t.Fatalf("position = %v, %v", pos, err)
+	}
+	if s, err := n.SizeValue(); err != nil || s != 1.5 {
+		t.Fatalf("size should take the last style: %v, %v", s, err)
+	}
+	if _, err := (DiagramEdge{}).WeightValue(); !errors.Is(err, ErrNoNumber) {
+		t.Fatalf("absent weight should be ErrNoNumber, got %v", err)
+	}
+	if _, err := (DiagramNode{X: "1", Y: "north"}).PositionValue(); err == nil || errors.Is(err, ErrNoNumber) || !strings.Contains(err.Error(), `y "north"`) {
+		t.Fatalf("malformed y should name the field, got %v", err)
+	}
+
+	scene, err := DiagramToScene(Diagram{ID: "d", Graph: DiagramGraph{
+		Nodes: []DiagramNode{n},
+		Edges: []DiagramEdge{{From: "a", To: "a", Weight: "3", Styles: []DiagramStyle{{Width: "0.5"}}}},
+	}})
+	if err != nil {
+		t.Fatalf("scene: %v", err)
+	}
+	sn, se := scene.Nodes[0], scene.Edges[0]
+	if p, err := sn.PctCompleteValue(); err != nil || p != 0.45 {
+		t.Fatalf("scene pct = %v, %v", p, err)
+	}
+	if s, err := sn.SizeValue(); err != nil || s != 1.5 {
+		t.Fatalf("scene size = %v, %v", s, err)
+	}
+	if w, err := se.WeightValue(); err != nil || w != 3 {
+		t.Fatalf("scene edge weight = %v, %v", w, err)
+	}
+	if w, err := se.WidthValue(); err != nil || w != 0.5 {
+		t.Fatalf("scene edge width = %v, %v", w, err)
+	}
+}
+
+func TestValidateDiagramStrictNumbers(t *testing.T) {
+	yes := true
+	dg := Diagram{ID: "d", Graph: DiagramGraph{
+		Nodes: []DiagramNode{{ID: "a", Weight: "heavy", PctComplete: "1.5", X: "1", Y: "1"}, {ID: "b", Styles: []DiagramStyle{{Size: "big"}}}},
+		Edges: []DiagramEdge{{From: "a", To: "b", Directed: &yes, Weight: "1e"}},
+	}}
+	if err := ValidateDiagram(dg); err != nil {
+		t.Fatalf("numbers are not checked by default: %v", err)
+	}
+	err := ValidateDiagramWithOptions(dg, ValidateDiagramOptions{StrictNumbers: true})
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Details) != 4 {
+		t.Fatalf("want four number issues, got %v", err)
+	}
+	fields := []string{"node.weight", "node.pct_complete", "node.style.size", "edge.weight"}
+	for i, det := range ve.Details {
+		if det.Code != CodeDiagramNumber || det.Field != fields[i] {
+			t.Fatalf("detail %d = %+v, want %s", i, det, fields[i])
+		}
+	}
+
+	doc := Document{Diagrams: []Diagram{dg}}
+	details := StrictDiagramNumbers()(doc)
+	if len(details) != 4 || !strings.HasPrefix(details[0].Message, "diagram[0]: node a: weight") {
+		t.Fatalf("validator details: %+v", details)
+	}
+}
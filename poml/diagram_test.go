@@ -1,7 +1,9 @@
 package poml
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -243,3 +245,89 @@ func TestInvalidDiagramFixtures(t *testing.T) {
 		})
 	}
 }
+
+func TestDiagramGroupsValidateAndEncode(t *testing.T) {
+	bad := Diagram{
+		ID: "g",
+		Graph: DiagramGraph{
+			Nodes: []DiagramNode{{ID: "a"}},
+			Groups: []DiagramGroup{
+				{ID: "x", Parent: "y", Members: []DiagramMember{{Node: "a"}, {Node: "ghost"}}},
+				{ID: "y", Parent: "x", Members: []DiagramMember{{Node: "a"}}},
+				{ID: "z", Parent: "missing"},
+			},
+		},
+	}
+	err := ValidateDiagram(bad)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	for _, want := range []string{"missing node ghost", "member of groups x and y", "cycle at x", "missing group missing"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+
+	doc, err := ParseString(`<poml><diagram id="d"><graph><node id="a"/><subgraph id="s" label="S"><member node="a"/></subgraph></graph></diagram></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := doc.Encode(&buf); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, `<group id="s" label="S"`) || !strings.Contains(out, `<member node="a">`) || strings.Contains(out, "subgraph") {
+		t.Fatalf("subgraph should encode as group: %s", buf.String())
+	}
+}
+
+func TestDiagramEdgeWaypoints(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <diagram id="routed">
+    <graph>
+      <node id="a" x="0" y="0"/>
+      <node id="b" x="4" y="0"/>
+      <edge from="a" to="b" directed="true">
+        <point x="0" y="2"/>
+        <point x="4" y="2" z="1"/>
+      </edge>
+    </graph>
+  </diagram>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	dg := doc.Diagrams[0]
+	if err := ValidateDiagram(dg); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	scene, err := DiagramToScene(dg)
+	if err != nil {
+		t.Fatalf("scene: %v", err)
+	}
+	e := scene.Edges[0]
+	if want := [][3]float64{{0, 0, 0}, {0, 2, 0}, {4, 2, 1}, {4, 0, 0}}; !reflect.DeepEqual(e.Path, want) || len(e.Waypoints) != 2 {
+		t.Fatalf("edge path = %v, waypoints = %v", e.Path, e.Waypoints)
+	}
+	dot, err := (GraphvizRenderer{}).Render(scene)
+	if err != nil {
+		t.Fatalf("render dot: %v", err)
+	}
+	if want := `pos="0.000,0.000 0.000,0.000 0.000,2.000 0.000,2.000 0.000,2.000 4.000,2.000 4.000,2.000 4.000,2.000 4.000,0.000 4.000,0.000"`; !strings.Contains(string(dot), want) {
+		t.Fatalf("dot edge pos missing:\n%s", dot)
+	}
+	back, err := ParseDOT(string(dot))
+	if err != nil {
+		t.Fatalf("parse dot: %v", err)
+	}
+	if pts := back.Graph.Edges[0].Points; len(pts) != 2 || pts[0].Y != "2.000" || pts[1].X != "4.000" {
+		t.Fatalf("dot import waypoints = %+v", pts)
+	}
+
+	dg.Graph.Edges[0].Points = append(dg.Graph.Edges[0].Points, DiagramPoint{X: "left"})
+	var ve *ValidationError
+	if err := ValidateDiagram(dg); !errors.As(err, &ve) || ve.Details[0].Code != CodeDiagramEdgePoint {
+		t.Fatalf("expected %s, got %v", CodeDiagramEdgePoint, err)
+	}
+}
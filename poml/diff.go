@@ -0,0 +1,202 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ChangeKind classifies a Change.
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// Change is a single element-level difference between two documents.
+// Key identifies the element across both sides: a natural identity where one exists
+// (input name, tool name, tool call id, diagram id, document src) and the per-type
+// ordinal otherwise, e.g. "input:topic" or "task#1". Before/After hold the element XML.
+type Change struct {
+	Kind   ChangeKind
+	Type   ElementType
+	Key    string
+	ID     string // element ID in a (removed/modified) or b (added)
+	Before string
+	After  string
+}
+
+// String renders a one-line summary such as "~ task#1 (el-3)".
+func (c Change) String() string {
+	mark := "~"
+	switch c.Kind {
+	case ChangeAdded:
+		mark = "+"
+	case ChangeRemoved:
+		mark = "-"
+	}
+	if c.ID == "" {
+		return fmt.Sprintf("%s %s", mark, c.Key)
+	}
+	return fmt.Sprintf("%s %s (%s)", mark, c.Key, c.ID)
+}
+
+// Diff compares two documents element by element. Changes for elements of a (removed or modified)
+// come first in a's order, followed by additions in b's order. Reordering alone is not a change.
+func Diff(a, b Document) []Change {
+	left := diffEntries(a)
+	right := diffEntries(b)
+	rightByKey := make(map[string]diffEntry, len(right))
+	for _, e := range right {
+		rightByKey[e.key] = e
+	}
+	seen := make(map[string]struct{}, len(left))
+	var changes []Change
+	for _, l := range left {
+		seen[l.key] = struct{}{}
+		r, ok := rightByKey[l.key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Kind: ChangeRemoved, Type: l.el.Type, Key: l.key, ID: l.el.ID, Before: l.xml})
+		case r.xml != l.xml:
+			changes = append(changes, Change{Kind: ChangeModified, Type: l.el.Type, Key: l.key, ID: l.el.ID, Before: l.xml, After: r.xml})
+		}
+	}
+	for _, r := range right {
+		if _, ok := seen[r.key]; ok {
+			continue
+		}
+		changes = append(changes, Change{Kind: ChangeAdded, Type: r.el.Type, Key: r.key, ID: r.el.ID, After: r.xml})
+	}
+	return changes
+}
+
+// WriteChanges renders changes as text: a summary line per change followed by the removed (-)
+// and added (+) element XML, indented by two spaces.
+func WriteChanges(w io.Writer, changes []Change) error {
+	var buf bytes.Buffer
+	for _, c := range changes {
+		buf.WriteString(c.String())
+		buf.WriteByte('\n')
+		writeDiffLines(&buf, "  - ", c.Before)
+		writeDiffLines(&buf, "  + ", c.After)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeDiffLines(buf *bytes.Buffer, prefix, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(prefix)
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+}
+
+type diffEntry struct {
+	key string
+	el  Element
+	xml string
+}
+
+// diffEntries keys each element of d and renders it to compact XML for comparison.
+func diffEntries(d Document) []diffEntry {
+	ordinals := make(map[ElementType]int)
+	used := make(map[string]int)
+	var out []diffEntry
+	for _, el := range d.resolveOrder() {
+		key := diffNaturalKey(d, el)
+		if key == "" {
+			key = fmt.Sprintf("%s#%d", diffTypeLabel(el), ordinals[el.Type])
+		}
+		ordinals[el.Type]++
+		if n := used[key]; n > 0 {
+			key = fmt.Sprintf("%s#%d", key, n)
+		}
+		used[key]++
+		out = append(out, diffEntry{key: key, el: el, xml: elementXML(d, el)})
+	}
+	return out
+}
+
+func diffTypeLabel(el Element) string {
+	if el.Type == ElementUnknown && el.Name != "" {
+		return string(el.Type) + ":" + el.Name
+	}
+	return string(el.Type)
+}
+
+// diffNaturalKey returns a content identity for el, or "" when only its position identifies it.
+func diffNaturalKey(d Document, el Element) string {
+	ident := ""
+	p := d.payloadFor(el)
+	switch el.Type {
+	case ElementMeta, ElementRole, ElementOutputSchema:
+		return string(el.Type)
+	case ElementInput:
+		if p.Input != nil {
+			ident = p.Input.Name
+		}
+	case ElementDocument:
+		if p.DocRef != nil {
+			ident = p.DocRef.Src
+		}
+	case ElementToolDefinition:
+		if p.ToolDef != nil {
+			ident = p.ToolDef.Name
+		}
+	case ElementToolRequest:
+		if p.ToolReq != nil {
+			ident = firstNonEmpty(p.ToolReq.ID, p.ToolReq.Name)
+		}
+	case ElementToolResponse:
+		if p.ToolResp != nil {
+			ident = firstNonEmpty(p.ToolResp.ID, p.ToolResp.Name)
+		}
+	case ElementToolResult:
+		if p.ToolResult != nil {
+			ident = firstNonEmpty(p.ToolResult.ID, p.ToolResult.Name)
+		}
+	case ElementToolError:
+		if p.ToolError != nil {
+			ident = firstNonEmpty(p.ToolError.ID, p.ToolError.Name)
+		}
+	case ElementDiagram:
+		if p.Diagram != nil {
+			ident = p.Diagram.ID
+		}
+	}
+	if ident == "" {
+		return ""
+	}
+	return string(el.Type) + ":" + ident
+}
+
+// elementXML renders a single element without surrounding whitespace.
+func elementXML(d Document, el Element) string {
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	if err := encodeElement(enc, &buf, d, el, EncodeOptions{}); err != nil {
+		return ""
+	}
+	if err := enc.Flush(); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package poml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiffReportsElementChanges(t *testing.T) {
+	a, err := ParseString(`<poml><meta><id>x</id><version>1</version><owner>o</owner></meta><role>r</role><task>one</task><input name="topic">t</input><input name="old">o</input></poml>`)
+	if err != nil {
+		t.Fatalf("parse a: %v", err)
+	}
+	b, err := ParseString(`<poml><meta><id>x</id><version>1</version><owner>o</owner></meta><input name="topic">t2</input><role>r</role><task>one</task><task>two</task></poml>`)
+	if err != nil {
+		t.Fatalf("parse b: %v", err)
+	}
+	changes := Diff(a, b)
+	got := make([]string, 0, len(changes))
+	for _, c := range changes {
+		got = append(got, string(c.Kind)+" "+c.Key)
+	}
+	want := []string{"modified input:topic", "removed input:old", "added task#1"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected changes: %v", got)
+	}
+	if changes[0].Before != `<input name="topic" required="false">t</input>` || changes[0].ID != "el-4" {
+		t.Fatalf("unexpected modified change: %+v", changes[0])
+	}
+	if len(Diff(a, a)) != 0 {
+		t.Fatalf("expected no changes when diffing a document with itself")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteChanges(&buf, changes[2:]); err != nil {
+		t.Fatalf("write changes: %v", err)
+	}
+	if buf.String() != "+ task#1 (el-5)\n  + <task>two</task>\n" {
+		t.Fatalf("unexpected rendering:\n%s", buf.String())
+	}
+}
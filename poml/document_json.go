@@ -0,0 +1,310 @@
+package poml
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// documentJSONVersion tags the JSON envelope so future layouts can stay backward compatible.
+const documentJSONVersion = 1
+
+// documentJSON is the stable JSON envelope for a Document: an ordered element list where each
+// entry carries its own payload, so order, attrs, whitespace, and unknown raw XML all survive.
+type documentJSON struct {
+	Version  int           `json:"version"`
+	Elements []elementJSON `json:"elements"`
+}
+
+type elementJSON struct {
+	Type      ElementType     `json:"type"`
+	ID        string          `json:"id,omitempty"`
+	Parent    string          `json:"parent,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Comment   string          `json:"comment,omitempty"`
+	Leading   string          `json:"leading,omitempty"`
+	Trailing  string          `json:"trailing,omitempty"`
+	Namespace string          `json:"namespace,omitempty"`
+	Raw       string          `json:"raw,omitempty"`
+	CDATA     bool            `json:"cdata,omitempty"`
+	AttrOrder string          `json:"attr_order,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+// MarshalJSON encodes the document as an ordered element list with per-element payloads.
+func (d Document) MarshalJSON() ([]byte, error) {
+	out := documentJSON{Version: documentJSONVersion, Elements: []elementJSON{}}
+	for _, el := range d.resolveOrder() {
+		ej := elementJSON{
+			Type:      el.Type,
+			ID:        el.ID,
+			Parent:    el.Parent,
+			Name:      el.Name,
+			Comment:   el.Comment,
+			Leading:   el.Leading,
+			Trailing:  el.Trailing,
+			Namespace: el.Namespace,
+			Raw:       el.RawXML,
+			CDATA:     el.CDATA,
+			AttrOrder: el.AttrOrder,
+		}
+		if el.Type != ElementUnknown {
+			payload, err := d.payloadValue(el)
+			if err != nil {
+				return nil, err
+			}
+			body, err := json.Marshal(payload)
+			if err != nil {
+				return nil, fmt.Errorf("marshal %s payload: %w", el.Type, err)
+			}
+			ej.Payload = body
+		}
+		out.Elements = append(out.Elements, ej)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON rebuilds a document from MarshalJSON output, restoring backing slices and Elements.
+func (d *Document) UnmarshalJSON(data []byte) error {
+	var in documentJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Version > documentJSONVersion {
+		return fmt.Errorf("unmarshal document: unsupported version %d", in.Version)
+	}
+	doc := Document{nextID: 1}
+	for i, ej := range in.Elements {
+		idx, err := doc.appendPayload(ej.Type, ej.Payload)
+		if err != nil {
+			return fmt.Errorf("unmarshal document: elements[%d]: %w", i, err)
+		}
+		el := Element{
+			Type:      ej.Type,
+			Index:     idx,
+			Name:      ej.Name,
+			RawXML:    ej.Raw,
+			Comment:   ej.Comment,
+			ID:        ej.ID,
+			Parent:    ej.Parent,
+			Leading:   ej.Leading,
+			Trailing:  ej.Trailing,
+			Namespace: ej.Namespace,
+			CDATA:     ej.CDATA,
+			AttrOrder: ej.AttrOrder,
+		}
+		if el.Parent == "" {
+			el.Parent = rootParentID
+		}
+		doc.Elements = append(doc.Elements, el)
+	}
+	doc.syncNextID()
+	for i := range doc.Elements {
+		if doc.Elements[i].ID == "" {
+			doc.Elements[i].ID = doc.freshID()
+		}
+	}
+	*d = doc
+	return nil
+}
+
+// payloadValue returns the concrete payload for el, erroring when its index is out of range.
+func (d Document) payloadValue(el Element) (any, error) {
+	p := d.payloadFor(el)
+	var v any
+	switch {
+	case p.Meta != nil:
+		v = p.Meta
+	case p.Role != nil:
+		v = p.Role
+	case p.Task != nil:
+		v = p.Task
+	case p.Input != nil:
+		v = p.Input
+	case p.DocRef != nil:
+		v = p.DocRef
+	case p.Style != nil:
+		v = p.Style
+	case p.Audio != nil:
+		v = p.Audio
+	case p.Video != nil:
+		v = p.Video
+	case p.OutputFormat != nil:
+		v = p.OutputFormat
+	case p.Hint != nil:
+		v = p.Hint
+	case p.Example != nil:
+		v = p.Example
+	case p.ContentPart != nil:
+		v = p.ContentPart
+	case p.Object != nil:
+		v = p.Object
+	case p.Image != nil:
+		v = p.Image
+	case p.Message != nil:
+		v = p.Message
+	case p.ToolDef != nil:
+		v = p.ToolDef
+	case p.ToolReq != nil:
+		v = p.ToolReq
+	case p.ToolResp != nil:
+		v = p.ToolResp
+	case p.ToolResult != nil:
+		v = p.ToolResult
+	case p.ToolError != nil:
+		v = p.ToolError
+	case p.Schema != nil:
+		v = p.Schema
+	case p.Runtime != nil:
+		v = p.Runtime
+	case p.Trace != nil:
+		v = p.Trace
+	case p.Diagram != nil:
+		v = p.Diagram
+	case el.Type == ElementOutputSchema:
+		v = &d.Schema
+	default:
+		return nil, fmt.Errorf("marshal %s: index %d out of range", el.Type, el.Index)
+	}
+	return v, nil
+}
+
+// appendPayload decodes raw into the backing slot for t and returns the element index (-1 for singletons).
+func (d *Document) appendPayload(t ElementType, raw json.RawMessage) (int, error) {
+	decode := func(v any) error {
+		if len(raw) == 0 {
+			return nil
+		}
+		return json.Unmarshal(raw, v)
+	}
+	switch t {
+	case ElementMeta:
+		return -1, decode(&d.Meta)
+	case ElementRole:
+		return -1, decode(&d.Role)
+	case ElementOutputSchema:
+		return -1, decode(&d.Schema)
+	case ElementUnknown:
+		return -1, nil
+	case ElementTask:
+		var v Block
+		err := decode(&v)
+		d.Tasks = append(d.Tasks, v)
+		return len(d.Tasks) - 1, err
+	case ElementInput:
+		var v Input
+		err := decode(&v)
+		d.Inputs = append(d.Inputs, v)
+		return len(d.Inputs) - 1, err
+	case ElementDocument:
+		var v DocRef
+		err := decode(&v)
+		d.Documents = append(d.Documents, v)
+		return len(d.Documents) - 1, err
+	case ElementStyle:
+		var v Style
+		err := decode(&v)
+		d.Styles = append(d.Styles, v)
+		return len(d.Styles) - 1, err
+	case ElementOutputFormat:
+		var v OutputFormat
+		err := decode(&v)
+		d.OutFormats = append(d.OutFormats, v)
+		return len(d.OutFormats) - 1, err
+	case ElementHint:
+		var v Hint
+		err := decode(&v)
+		d.Hints = append(d.Hints, v)
+		return len(d.Hints) - 1, err
+	case ElementExample:
+		var v Example
+		err := decode(&v)
+		v.Pair = parseExamplePair(v.Body)
+		d.Examples = append(d.Examples, v)
+		return len(d.Examples) - 1, err
+	case ElementContentPart:
+		var v ContentPart
+		err := decode(&v)
+		d.ContentParts = append(d.ContentParts, v)
+		return len(d.ContentParts) - 1, err
+	case ElementObject:
+		var v ObjectTag
+		err := decode(&v)
+		d.Objects = append(d.Objects, v)
+		return len(d.Objects) - 1, err
+	case ElementAudio:
+		var v Media
+		err := decode(&v)
+		d.Audios = append(d.Audios, v)
+		return len(d.Audios) - 1, err
+	case ElementVideo:
+		var v Media
+		err := decode(&v)
+		d.Videos = append(d.Videos, v)
+		return len(d.Videos) - 1, err
+	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+		var v Message
+		err := decode(&v)
+		d.Messages = append(d.Messages, v)
+		return len(d.Messages) - 1, err
+	case ElementToolDefinition:
+		var v ToolDefinition
+		err := decode(&v)
+		d.ToolDefs = append(d.ToolDefs, v)
+		return len(d.ToolDefs) - 1, err
+	case ElementToolRequest:
+		var v ToolRequest
+		err := decode(&v)
+		d.ToolReqs = append(d.ToolReqs, v)
+		return len(d.ToolReqs) - 1, err
+	case ElementToolResponse:
+		var v ToolResponse
+		err := decode(&v)
+		d.ToolResps = append(d.ToolResps, v)
+		return len(d.ToolResps) - 1, err
+	case ElementToolResult:
+		var v ToolResult
+		err := decode(&v)
+		d.ToolResults = append(d.ToolResults, v)
+		return len(d.ToolResults) - 1, err
+	case ElementToolError:
+		var v ToolError
+		err := decode(&v)
+		d.ToolErrors = append(d.ToolErrors, v)
+		return len(d.ToolErrors) - 1, err
+	case ElementRuntime:
+		var v Runtime
+		err := decode(&v)
+		d.Runtimes = append(d.Runtimes, v)
+		return len(d.Runtimes) - 1, err
+	case ElementTrace:
+		var v Trace
+		err := decode(&v)
+		d.Traces = append(d.Traces, v)
+		return len(d.Traces) - 1, err
+	case ElementImage:
+		var v Image
+		err := decode(&v)
+		d.Images = append(d.Images, v)
+		return len(d.Images) - 1, err
+	case ElementDiagram:
+		var v Diagram
+		err := decode(&v)
+		d.Diagrams = append(d.Diagrams, v)
+		return len(d.Diagrams) - 1, err
+	}
+	return -1, fmt.Errorf("unknown element type %q", t)
+}
+
+// syncNextID advances the ID counter past any "el-N" IDs already present.
+func (d *Document) syncNextID() {
+	if d.nextID == 0 {
+		d.nextID = 1
+	}
+	for _, el := range d.Elements {
+		if n, err := strconv.Atoi(strings.TrimPrefix(el.ID, "el-")); err == nil && strings.HasPrefix(el.ID, "el-") && n >= d.nextID {
+			d.nextID = n + 1
+		}
+	}
+}
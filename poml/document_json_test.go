@@ -0,0 +1,86 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestDocumentJSONRoundTripExamples(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "examples", "*.poml"))
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	diagrams, _ := filepath.Glob(filepath.Join("testdata", "diagrams", "*.poml"))
+	files = append(files, diagrams...)
+	if len(files) == 0 {
+		t.Fatalf("no fixtures found")
+	}
+	opts := EncodeOptions{PreserveOrder: true, PreserveWS: true}
+	for _, path := range files {
+		doc, err := ParseFile(path)
+		if err != nil {
+			t.Fatalf("parse %s: %v", path, err)
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatalf("marshal %s: %v", path, err)
+		}
+		var back Document
+		if err := json.Unmarshal(data, &back); err != nil {
+			t.Fatalf("unmarshal %s: %v", path, err)
+		}
+		again, err := json.Marshal(back)
+		if err != nil {
+			t.Fatalf("re-marshal %s: %v", path, err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Fatalf("%s: JSON not stable across round trip", path)
+		}
+		var want, got bytes.Buffer
+		if err := doc.EncodeWithOptions(&want, opts); err != nil {
+			t.Fatalf("encode %s: %v", path, err)
+		}
+		if err := back.EncodeWithOptions(&got, opts); err != nil {
+			t.Fatalf("encode round trip %s: %v", path, err)
+		}
+		if want.String() != got.String() {
+			t.Fatalf("%s: XML mismatch after JSON round trip\nwant:\n%s\ngot:\n%s", path, want.String(), got.String())
+		}
+	}
+}
+
+func TestDocumentJSONPreservesUnknownAndIDs(t *testing.T) {
+	src := `<poml><task>a</task><x:custom xmlns:x="urn:x" k="v">keep &amp; me</x:custom><hint caption="h">b</hint></poml>`
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var back Document
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(back.Elements) != len(doc.Elements) {
+		t.Fatalf("element count mismatch: got %d want %d", len(back.Elements), len(doc.Elements))
+	}
+	for i, el := range doc.Elements {
+		if back.Elements[i] != el {
+			t.Fatalf("element %d mismatch: got %+v want %+v", i, back.Elements[i], el)
+		}
+	}
+	if attrs := back.Hints[0].Attrs; len(attrs) != 1 || attrs[0].Name.Local != "caption" || attrs[0].Value != "h" {
+		t.Fatalf("hint attrs lost: %+v", back.Hints[0])
+	}
+	idx := back.AddTask("new")
+	if id := back.Elements[len(back.Elements)-1].ID; id == doc.Elements[0].ID || idx != 1 {
+		t.Fatalf("fresh ID collides after unmarshal: %s (idx %d)", id, idx)
+	}
+	if err := json.Unmarshal([]byte(`{"version":1,"elements":[{"type":"bogus"}]}`), &back); err == nil {
+		t.Fatalf("expected error for unknown element type")
+	}
+}
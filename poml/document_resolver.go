@@ -0,0 +1,187 @@
+package poml
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// DocumentResolver fetches the contents referenced by <document src="...">.
+type DocumentResolver interface {
+	ResolveDocument(ref DocRef) ([]byte, error)
+}
+
+// ContextDocumentResolver is a DocumentResolver that can abandon a read when ctx is done.
+// ConvertContext prefers it over ResolveDocument.
+type ContextDocumentResolver interface {
+	DocumentResolver
+	ResolveDocumentContext(ctx context.Context, ref DocRef) ([]byte, error)
+}
+
+// resolveDocumentContext resolves ref through resolver, passing ctx when the resolver accepts one.
+func resolveDocumentContext(ctx context.Context, resolver DocumentResolver, ref DocRef) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if r, ok := resolver.(ContextDocumentResolver); ok {
+		return r.ResolveDocumentContext(ctx, ref)
+	}
+	return resolver.ResolveDocument(ref)
+}
+
+// DocumentResolverFunc adapts a function to DocumentResolver.
+type DocumentResolverFunc func(ref DocRef) ([]byte, error)
+
+// ResolveDocument calls f(ref).
+func (f DocumentResolverFunc) ResolveDocument(ref DocRef) ([]byte, error) { return f(ref) }
+
+const defaultMaxDocumentBytes int64 = 10 << 20 // 10MB safeguard for <document> contents
+
+// FileDocumentResolver reads file:// and relative document sources from disk or an fs.FS.
+// Disk reads reuse the converter path rules: BaseDir containment (symlink-aware) and no
+// absolute paths unless AllowAbsPaths is set.
+type FileDocumentResolver struct {
+	// BaseDir resolves relative paths on disk; ignored when FS is set.
+	BaseDir string
+	// FS, when non-nil, serves documents instead of the host filesystem.
+	FS fs.FS
+	// AllowAbsPaths permits absolute paths on disk when BaseDir is empty.
+	AllowAbsPaths bool
+	// MaxBytes caps bytes read; zero applies a default cap, negative disables the cap.
+	MaxBytes int64
+}
+
+// ResolveDocument reads the referenced document.
+func (r FileDocumentResolver) ResolveDocument(ref DocRef) ([]byte, error) {
+	return r.ResolveDocumentContext(context.Background(), ref)
+}
+
+// ResolveDocumentContext is ResolveDocument bound to ctx; reads stop once ctx is done.
+func (r FileDocumentResolver) ResolveDocumentContext(ctx context.Context, ref DocRef) ([]byte, error) {
+	src := strings.TrimSpace(ref.Src)
+	if src == "" {
+		return nil, fmt.Errorf("document src is empty")
+	}
+	if strings.Contains(src, "{{") {
+		return nil, fmt.Errorf("document src %s is a template expression", src)
+	}
+	src = strings.TrimPrefix(src, "file://")
+	if i := strings.Index(src, "://"); i >= 0 {
+		return nil, fmt.Errorf("document src %s uses unsupported scheme %s", ref.Src, src[:i])
+	}
+	limit := r.MaxBytes
+	if limit == 0 {
+		limit = defaultMaxDocumentBytes
+	}
+	if r.FS != nil {
+		name := path.Clean(strings.TrimPrefix(src, "/"))
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("document path %s is not valid for fs.FS", ref.Src)
+		}
+		f, err := r.FS.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("read document %s: %w", ref.Src, err)
+		}
+		defer f.Close()
+		return readAllWithLimit(ctx, f, limit, ref.Src)
+	}
+	if err := hostFileAccess(); err != nil {
+		return nil, fmt.Errorf("read document %s: %w", ref.Src, err)
+	}
+	resolved, err := resolveImagePath(src, ConvertOptions{BaseDir: r.BaseDir, AllowAbsImagePaths: r.AllowAbsPaths})
+	if err != nil {
+		return nil, err
+	}
+	data, err := readFileWithLimit(ctx, resolved, limit)
+	if err != nil {
+		return nil, fmt.Errorf("read document %s: %w", resolved, err)
+	}
+	return data, nil
+}
+
+func readAllWithLimit(ctx context.Context, r io.Reader, limit int64, label string) ([]byte, error) {
+	r = contextReader{ctx: ctx, r: r}
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("file %s exceeds max size %d bytes", label, limit)
+	}
+	return data, nil
+}
+
+// contextReader fails reads once ctx is done so large local reads can be cancelled between chunks.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// ResolveDocuments fetches every <document> source via resolver and stores the result in DocRef.Content.
+// References that already carry content are left untouched.
+func (d *Document) ResolveDocuments(resolver DocumentResolver) error {
+	if resolver == nil {
+		resolver = FileDocumentResolver{}
+	}
+	for i := range d.Documents {
+		if d.Documents[i].Content != "" {
+			continue
+		}
+		data, err := resolver.ResolveDocument(d.Documents[i])
+		if err != nil {
+			return &POMLError{Type: ErrDecode, Code: CodeDecode, Message: fmt.Sprintf("resolve document[%d]", i), Err: err}
+		}
+		d.Documents[i].Content = string(data)
+	}
+	return nil
+}
+
+// documentContent returns resolved text for a <document>, consulting opts.DocumentResolver (or
+// opts.AssetFetcher for http(s) sources) when the reference was not resolved at parse time.
+// ok is false when no content is available.
+func (d Document) documentContent(el Element, opts ConvertOptions) (string, bool, error) {
+	if el.Index < 0 || el.Index >= len(d.Documents) {
+		return "", false, nil
+	}
+	ref := d.Documents[el.Index]
+	if ref.Content != "" {
+		return ref.Content, true, nil
+	}
+	resolver := opts.DocumentResolver
+	if resolver == nil && opts.AssetFetcher != nil && isRemoteSrc(ref.Src) {
+		resolver = DocumentResolverFunc(func(ref DocRef) ([]byte, error) {
+			data, _, err := fetchRemoteAsset(strings.TrimSpace(ref.Src), defaultMaxDocumentBytes, opts)
+			return data, err
+		})
+	}
+	if resolver == nil && opts.FS != nil && !isRemoteSrc(ref.Src) {
+		resolver = DocumentResolverFunc(func(ref DocRef) ([]byte, error) {
+			name, err := fsAssetPath(ref.Src, opts.BaseDir)
+			if err != nil {
+				return nil, err
+			}
+			return FileDocumentResolver{FS: opts.FS}.ResolveDocumentContext(opts.readContext(), DocRef{Src: name})
+		})
+	}
+	if resolver == nil {
+		return "", false, nil
+	}
+	data, err := resolveDocumentContext(opts.readContext(), resolver, ref)
+	if err != nil {
+		return "", false, fmt.Errorf("resolve document %s: %w", ref.Src, err)
+	}
+	return string(data), true, nil
+}
@@ -0,0 +1,78 @@
+package poml
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseResolveDocumentsFromFS(t *testing.T) {
+	fsys := fstest.MapFS{"docs/notes.txt": &fstest.MapFile{Data: []byte("release notes")}}
+	src := `<poml><human-msg>Summarize</human-msg><document src="file://docs/notes.txt"/></poml>`
+	doc, err := ParseReaderWithOptions(strings.NewReader(src), ParseOptions{
+		ResolveDocuments: true,
+		DocumentResolver: FileDocumentResolver{FS: fsys},
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if doc.Documents[0].Content != "release notes" {
+		t.Fatalf("document content not resolved: %+v", doc.Documents[0])
+	}
+	outAny, err := Convert(doc, FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	msgs := outAny.(map[string]any)["messages"].([]map[string]any)
+	if len(msgs) != 2 || msgs[1]["content"] != "release notes" {
+		t.Fatalf("expected document content as user message, got %+v", msgs)
+	}
+	var buf strings.Builder
+	if err := doc.Encode(&buf); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if strings.Contains(buf.String(), "release notes") {
+		t.Fatalf("resolved content must not be encoded: %s", buf.String())
+	}
+	if _, err := ParseReaderWithOptions(strings.NewReader(`<poml><document src="missing.txt"/></poml>`), ParseOptions{
+		ResolveDocuments: true,
+		DocumentResolver: FileDocumentResolver{FS: fsys},
+	}); err == nil {
+		t.Fatalf("expected error for missing document")
+	}
+}
+
+func TestConvertResolvesDocumentsWithBaseDir(t *testing.T) {
+	base := t.TempDir()
+	if err := os.WriteFile(filepath.Join(base, "a.txt"), []byte("alpha"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	doc, err := ParseString(`<poml><document src="a.txt"/></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out, err := Convert(doc, FormatLangChain, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert without resolver: %v", err)
+	}
+	if msgs := out.(map[string]any)["messages"].([]map[string]any); len(msgs) != 0 {
+		t.Fatalf("unresolved documents should be skipped, got %+v", msgs)
+	}
+	out, err = Convert(doc, FormatMessageDict, ConvertOptions{DocumentResolver: FileDocumentResolver{BaseDir: base}})
+	if err != nil {
+		t.Fatalf("convert with resolver: %v", err)
+	}
+	if msgs := out.([]messageDict); len(msgs) != 1 || msgs[0].Content != "alpha" {
+		t.Fatalf("unexpected messages: %+v", msgs)
+	}
+
+	escape, _ := ParseString(`<poml><document src="../outside.txt"/></poml>`)
+	if _, err := Convert(escape, FormatOpenAIChat, ConvertOptions{DocumentResolver: FileDocumentResolver{BaseDir: base}}); err == nil {
+		t.Fatalf("expected BaseDir escape error")
+	}
+	if _, err := (FileDocumentResolver{}).ResolveDocument(DocRef{Src: "https://example.com/x"}); err == nil {
+		t.Fatalf("expected unsupported scheme error")
+	}
+}
@@ -0,0 +1,339 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// EncodeYAML writes the document as YAML for people who would rather not edit XML. The layout
+// is a "poml" list with one entry per element in document order:
+//
+//	poml:
+//	  - role: You are a careful analyst.
+//	  - task:
+//	      id: summarize
+//	      body: Summarize the report.
+//	  - human-msg:
+//	      xml: Compare <img src="q1.png"/> with last quarter.
+//	  - raw: <custom-tag a="1">kept as written</custom-tag>
+//
+// An entry maps the element's tag to its body when it has no attributes, or to its attributes
+// plus "body" for plain text or "xml" for a body holding markup, entities, or CDATA. Attributes
+// named body, xml, or attrs go under an "attrs" mapping instead. <meta> maps to its id,
+// version, and owner; unknown elements are kept verbatim under "raw"; a comment preceding an
+// element is a "comment" key beside it. Bodies keep their whitespace, so ParseYAML rebuilds a
+// document Diff finds no changes in; a body with indented lines is written as a double-quoted
+// scalar broken after each \n.
+func (d Document) EncodeYAML(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("poml:")
+	for _, el := range d.resolveOrder() {
+		b.WriteString("\n  -")
+		sep := " "
+		if el.Comment != "" {
+			b.WriteString(" comment: ")
+			appendYAMLString(&b, el.Comment, 6)
+			sep = "\n    "
+		}
+		b.WriteString(sep)
+		if el.Type == ElementUnknown {
+			b.WriteString("raw: ")
+			appendYAMLString(&b, el.RawXML, 6)
+			continue
+		}
+		tag, attrs, inner, err := d.yamlElementParts(el)
+		if err != nil {
+			return err
+		}
+		appendYAMLString(&b, tag, 6)
+		b.WriteByte(':')
+		if el.Type == ElementMeta {
+			attrs, inner = nil, ""
+			for _, f := range [][2]string{{"id", d.Meta.ID}, {"version", d.Meta.Version}, {"owner", d.Meta.Owner}} {
+				if f[1] != "" {
+					attrs = append(attrs, xml.Attr{Name: xml.Name{Local: f[0]}, Value: f[1]})
+				}
+			}
+		}
+		textBody := !strings.ContainsAny(inner, "<&")
+		switch {
+		case len(attrs) == 0 && inner == "":
+			b.WriteString(" {}")
+			continue
+		case len(attrs) == 0 && textBody:
+			b.WriteByte(' ')
+			appendYAMLString(&b, inner, 6)
+			continue
+		}
+		var nested []xml.Attr
+		for _, a := range attrs {
+			switch a.Name.Local {
+			case "body", "xml", "attrs":
+				if a.Name.Space == "" {
+					nested = append(nested, a)
+					continue
+				}
+			}
+			b.WriteString("\n      ")
+			appendYAMLString(&b, yamlAttrName(a.Name), 8)
+			b.WriteString(": ")
+			appendYAMLAttr(&b, a.Value, 8)
+		}
+		if len(nested) > 0 {
+			b.WriteString("\n      attrs:")
+			for _, a := range nested {
+				b.WriteString("\n        ")
+				b.WriteString(a.Name.Local)
+				b.WriteString(": ")
+				appendYAMLAttr(&b, a.Value, 10)
+			}
+		}
+		if inner != "" {
+			if textBody {
+				b.WriteString("\n      body: ")
+			} else {
+				b.WriteString("\n      xml: ")
+			}
+			appendYAMLString(&b, inner, 8)
+		}
+	}
+	if len(d.Elements) == 0 {
+		b.WriteString(" []")
+	}
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// appendYAMLAttr writes an attribute value, unquoted when it reads back as an equal string,
+// number, or bool, so temperature: 0.2 stays readable.
+func appendYAMLAttr(b *strings.Builder, v string, indent int) {
+	if v != "" && v == strings.TrimSpace(v) && !strings.ContainsAny(v, "\n\t") {
+		if r, err := yamlScalar(v, 0); err == nil && r != nil && yamlScalarString(r) == v {
+			if _, isString := r.(string); !isString {
+				b.WriteString(v)
+				return
+			}
+		}
+	}
+	appendYAMLString(b, v, indent)
+}
+
+func yamlAttrName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+// yamlElementParts encodes el on its own and splits it into its tag, attributes (as written,
+// with namespace prefixes), and inner XML.
+func (d Document) yamlElementParts(el Element) (string, []xml.Attr, string, error) {
+	el.Comment = ""
+	var buf bytes.Buffer
+	if err := d.EncodeElement(&buf, el, EncodeOptions{Compact: true}); err != nil {
+		return "", nil, "", err
+	}
+	src := buf.String()
+	dec := xml.NewDecoder(strings.NewReader(src))
+	var start xml.StartElement
+	var innerStart int64
+	depth := 0
+	for {
+		before := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			return "", nil, "", fmt.Errorf("encode yaml: %s element is empty", el.Type)
+		}
+		if err != nil {
+			return "", nil, "", fmt.Errorf("encode yaml: %s: %w", el.Type, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				start, innerStart = t.Copy(), dec.InputOffset()
+			}
+			depth++
+		case xml.EndElement:
+			if depth--; depth == 0 {
+				inner := src[innerStart:max(before, innerStart)]
+				return yamlAttrName(start.Name), start.Attr, inner, nil
+			}
+		}
+	}
+}
+
+// ParseYAML decodes a document from the YAML layout EncodeYAML writes, with the same options
+// ParseReader uses.
+func ParseYAML(r io.Reader) (Document, error) {
+	return ParseYAMLWithOptions(r, defaultParseOptions)
+}
+
+// ParseYAMLWithOptions decodes a document from YAML, rebuilding the POML markup and parsing it
+// with opts. Entry keys other than the tag, "comment", and "raw" are rejected, as are
+// attribute values that are not scalars.
+func ParseYAMLWithOptions(r io.Reader, opts ParseOptions) (Document, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return Document{}, err
+	}
+	markup, err := yamlToPOML(string(bytes.TrimPrefix(src, utf8BOM)))
+	if err != nil {
+		return Document{}, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "parse yaml", Err: err}
+	}
+	return parseWithOptions(strings.NewReader(markup), opts)
+}
+
+// yamlToPOML converts the EncodeYAML layout back to POML markup.
+func yamlToPOML(src string) (string, error) {
+	v, err := parseYAMLOrdered(src)
+	if err != nil {
+		return "", err
+	}
+	root, ok := v.(*yamlMap)
+	if !ok || len(root.keys) != 1 || root.keys[0] != "poml" {
+		return "", errors.New(`expected a mapping with a single "poml" key`)
+	}
+	entries, ok := root.values["poml"].([]any)
+	if !ok && root.values["poml"] != nil {
+		return "", errors.New(`"poml" must be a list of elements`)
+	}
+	var b strings.Builder
+	b.WriteString("<poml>")
+	for i, e := range entries {
+		b.WriteString("\n  ")
+		if err := writeYAMLEntry(&b, e); err != nil {
+			return "", fmt.Errorf("poml[%d]: %w", i, err)
+		}
+	}
+	b.WriteString("\n</poml>\n")
+	return b.String(), nil
+}
+
+func writeYAMLEntry(b *strings.Builder, e any) error {
+	entry, ok := e.(*yamlMap)
+	if !ok {
+		return errors.New("expected a mapping of an element tag to its content")
+	}
+	var tag string
+	for _, k := range entry.keys {
+		if k == "comment" {
+			continue
+		}
+		if tag != "" {
+			return fmt.Errorf("expected one element, found %q and %q", tag, k)
+		}
+		tag = k
+	}
+	if c, ok := entry.values["comment"]; ok {
+		b.WriteString("<!--" + commentText(yamlScalarString(c)) + "-->\n  ")
+	}
+	if tag == "" {
+		return errors.New("missing element tag")
+	}
+	content := entry.values[tag]
+	if tag == "raw" {
+		raw, ok := content.(string)
+		if !ok {
+			return errors.New("raw must be a string of XML")
+		}
+		b.WriteString(raw)
+		return nil
+	}
+	if !validXMLName(tag) {
+		return fmt.Errorf("invalid element tag %q", tag)
+	}
+	var attrs, inner strings.Builder
+	switch c := content.(type) {
+	case nil:
+	case []any:
+		return fmt.Errorf("%s: expected text or a mapping, found a list", tag)
+	case *yamlMap:
+		for _, k := range c.keys {
+			v := c.values[k]
+			var err error
+			switch {
+			case k == "body":
+				var s string
+				if s, err = yamlAttrValue(v); err == nil {
+					inner.WriteString(yamlBodyEscaper.Replace(s))
+				}
+			case k == "xml":
+				var s string
+				if s, err = yamlAttrValue(v); err == nil {
+					inner.WriteString(s)
+				}
+			case k == "attrs":
+				nested, ok := v.(*yamlMap)
+				if !ok {
+					return fmt.Errorf("%s: attrs must be a mapping", tag)
+				}
+				for _, nk := range nested.keys {
+					if err = writeYAMLAttr(&attrs, nk, nested.values[nk]); err != nil {
+						break
+					}
+				}
+			case tag == "meta":
+				var s string
+				if s, err = yamlAttrValue(v); err == nil {
+					fmt.Fprintf(&inner, "<%s>%s</%s>", k, yamlBodyEscaper.Replace(s), k)
+				}
+			default:
+				err = writeYAMLAttr(&attrs, k, v)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", tag, err)
+			}
+		}
+	default:
+		inner.WriteString(yamlBodyEscaper.Replace(yamlScalarString(c)))
+	}
+	fmt.Fprintf(b, "<%s%s>%s</%s>", tag, attrs.String(), inner.String(), tag)
+	return nil
+}
+
+// yamlBodyEscaper escapes the characters plain text cannot hold in markup, leaving '>' alone
+// so text bodies come back exactly as EncodeYAML wrote them.
+var yamlBodyEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;")
+
+func writeYAMLAttr(b *strings.Builder, name string, v any) error {
+	if !validXMLName(name) {
+		return fmt.Errorf("invalid attribute name %q", name)
+	}
+	s, err := yamlAttrValue(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	b.WriteString(" " + name + `="`)
+	_ = xml.EscapeText(b, []byte(s))
+	b.WriteByte('"')
+	return nil
+}
+
+func yamlAttrValue(v any) (string, error) {
+	switch v.(type) {
+	case *yamlMap, []any:
+		return "", errors.New("expected a scalar")
+	}
+	return yamlScalarString(v), nil
+}
+
+// validXMLName reports whether s can be used as an element or attribute name.
+func validXMLName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || r == ':' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= 0x80:
+		case i > 0 && (r == '-' || r == '.' || r >= '0' && r <= '9'):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,205 @@
+package poml
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDocumentYAMLRoundTripExamples(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "examples", "*.poml"))
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	diagrams, _ := filepath.Glob(filepath.Join("testdata", "diagrams", "*.poml"))
+	files = append(files, diagrams...)
+	if len(files) == 0 {
+		t.Fatalf("no fixtures found")
+	}
+	for _, path := range files {
+		doc, err := ParseFile(path)
+		if err != nil {
+			t.Fatalf("parse %s: %v", path, err)
+		}
+		var first strings.Builder
+		if err := doc.EncodeYAML(&first); err != nil {
+			t.Fatalf("encode %s: %v", path, err)
+		}
+		back, err := ParseYAML(strings.NewReader(first.String()))
+		if err != nil {
+			t.Fatalf("parse yaml %s: %v\n%s", path, err, first.String())
+		}
+		var second strings.Builder
+		if err := back.EncodeYAML(&second); err != nil {
+			t.Fatalf("re-encode %s: %v", path, err)
+		}
+		if first.String() != second.String() {
+			t.Fatalf("%s: YAML not stable across round trip\nfirst:\n%s\nsecond:\n%s", path, first.String(), second.String())
+		}
+		if changes := Diff(doc, back); len(changes) > 0 {
+			var buf strings.Builder
+			_ = WriteChanges(&buf, changes)
+			t.Fatalf("%s: document changed across YAML round trip:\n%s", path, buf.String())
+		}
+		for _, format := range []Format{FormatDict, FormatOpenAIChat} {
+			want, wantErr := Convert(doc, format, ConvertOptions{})
+			got, gotErr := Convert(back, format, ConvertOptions{})
+			if (wantErr == nil) != (gotErr == nil) {
+				t.Fatalf("%s %s: convert errors differ: %v vs %v", path, format, wantErr, gotErr)
+			}
+			w, _ := json.Marshal(want)
+			g, _ := json.Marshal(got)
+			if string(w) != string(g) {
+				t.Fatalf("%s %s: output differs after YAML round trip\nwant: %s\ngot:  %s", path, format, w, g)
+			}
+		}
+	}
+}
+
+func TestParseYAMLHandWritten(t *testing.T) {
+	src := `poml:
+  - meta: {id: report, version: "1.0"}
+  - role: You are a careful analyst.
+  # comments in the YAML are ignored
+  - comment: main instruction
+    task:
+      id: summarize
+      body: |
+        Summarize Q1 & Q2.
+        Keep it short.
+  - runtime: {model: gpt-4o, temperature: 0.2}
+  - tool-definition:
+      name: lookup
+      description: Find a record
+      body: '{"type": "object"}'
+  - human-msg: Hi <there>
+  - raw: <custom a="1">x</custom>
+`
+	doc, err := ParseYAML(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if doc.Meta.ID != "report" || doc.Meta.Version != "1.0" || doc.Role.Body != "You are a careful analyst." {
+		t.Fatalf("unexpected meta/role: %+v %+v", doc.Meta, doc.Role)
+	}
+	if doc.Tasks[0].Body != "Summarize Q1 &amp; Q2.\nKeep it short.\n" || !reflect.DeepEqual(doc.Tasks[0].Attrs[0].Value, "summarize") {
+		t.Fatalf("unexpected task: %+v", doc.Tasks[0])
+	}
+	if el := doc.Elements[2]; el.Comment != "main instruction" {
+		t.Fatalf("comment not kept: %+v", el)
+	}
+	if len(doc.Runtimes) != 1 || !strings.Contains(doc.Runtimes[0].Attrs[1].Value, "0.2") {
+		t.Fatalf("unexpected runtime: %+v", doc.Runtimes)
+	}
+	if doc.ToolDefs[0].Name != "lookup" || doc.ToolDefs[0].Body != `{"type": "object"}` {
+		t.Fatalf("unexpected tool: %+v", doc.ToolDefs[0])
+	}
+	if doc.Messages[0].Body != "Hi &lt;there>" {
+		t.Fatalf("unexpected message body: %q", doc.Messages[0].Body)
+	}
+	last := doc.Elements[len(doc.Elements)-1]
+	if last.Type != ElementUnknown || last.RawXML != `<custom a="1">x</custom>` {
+		t.Fatalf("unexpected unknown element: %+v", last)
+	}
+
+	if _, err := ParseYAML(strings.NewReader("poml:\n  - task: a\n    hint: b\n")); err == nil || !strings.Contains(err.Error(), "poml[0]") {
+		t.Fatalf("expected an error for two tags in one entry, got %v", err)
+	}
+}
+
+func TestDocumentYAMLRoundTripEdgeCases(t *testing.T) {
+	src := `<poml>
+  <!-- two
+  lines -->
+  <task id="007" body="b" xml="x" attrs="a" caption="one&#xA;two&#xA;&#xA;" note=" padded: yes # no">Do it.</task>
+  <hint>- starts like a list item</hint>
+  <hint>42</hint>
+  <human-msg>line one
+  line two</human-msg>
+  <output-format>Use <b>bold</b> &amp; tabs	here.</output-format>
+  <example></example>
+  <hint caption="end&#xA;&#xA;">last</hint>
+</poml>`
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var first strings.Builder
+	if err := doc.EncodeYAML(&first); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	for _, want := range []string{"id: \"007\"", "attrs:\n        body: b\n        xml: x\n        attrs: a", "caption: |+\n", "note: \" padded: yes # no\"", "- hint: \"- starts like a list item\"", "- hint: 42", "- example: {}", `xml: "Use <b>bold</b> &amp; tabs\there."`} {
+		if !strings.Contains(first.String(), want) {
+			t.Fatalf("encoded YAML lacks %q:\n%s", want, first.String())
+		}
+	}
+	back, err := ParseYAML(strings.NewReader(first.String()))
+	if err != nil {
+		t.Fatalf("parse yaml: %v\n%s", err, first.String())
+	}
+	var second strings.Builder
+	if err := back.EncodeYAML(&second); err != nil {
+		t.Fatalf("re-encode: %v", err)
+	}
+	if first.String() != second.String() {
+		t.Fatalf("YAML not stable across round trip\nfirst:\n%s\nsecond:\n%s", first.String(), second.String())
+	}
+	if got := back.Tasks[0].Attrs.Get("caption"); got != "one\ntwo\n\n" {
+		t.Fatalf("caption = %q", got)
+	}
+	if got := back.Hints[2].Attrs.Get("caption"); got != "end\n\n" {
+		t.Fatalf("caption at the end of the document = %q", got)
+	}
+	if back.Elements[0].Comment != doc.Elements[0].Comment || back.Hints[0].Body != "- starts like a list item" {
+		t.Fatalf("comment or hint changed: %+v %+v", back.Elements[0], back.Hints[0])
+	}
+
+	var empty strings.Builder
+	if err := (Document{}).EncodeYAML(&empty); err != nil || empty.String() != "poml: []\n" {
+		t.Fatalf("empty document: %q, %v", empty.String(), err)
+	}
+	for _, src := range []string{"poml: []\n", "poml:\n"} {
+		if doc, err := ParseYAML(strings.NewReader(src)); err != nil || len(doc.Elements) != 0 {
+			t.Fatalf("%q: %d elements, %v", src, len(doc.Elements), err)
+		}
+	}
+}
+
+func TestParseYAMLInvalid(t *testing.T) {
+	cases := []struct {
+		name, src, want string
+	}{
+		{"not a mapping", "- task: a", `expected a mapping with a single "poml" key`},
+		{"extra key", "poml: []\nextra: 1", `expected a mapping with a single "poml" key`},
+		{"not a list", "poml: task", `"poml" must be a list of elements`},
+		{"scalar entry", "poml:\n  - task", "poml[0]: expected a mapping of an element tag to its content"},
+		{"missing tag", "poml:\n  - role: r\n  - comment: c", "poml[1]: missing element tag"},
+		{"raw mapping", "poml:\n  - raw: {a: 1}", "poml[0]: raw must be a string of XML"},
+		{"invalid tag", "poml:\n  - 'bad tag': x", `poml[0]: invalid element tag "bad tag"`},
+		{"list content", "poml:\n  - task: [a]", "poml[0]: task: expected text or a mapping, found a list"},
+		{"attrs scalar", "poml:\n  - task:\n      attrs: x", "poml[0]: task: attrs must be a mapping"},
+		{"attribute list", "poml:\n  - task:\n      id: [1]", "poml[0]: task: id: expected a scalar"},
+		{"attribute name", "poml:\n  - task:\n      'a b': 1", `poml[0]: task: invalid attribute name "a b"`},
+		{"nested attribute name", "poml:\n  - task:\n      attrs: {'<x': 1}", `poml[0]: task: invalid attribute name "<x"`},
+		{"body mapping", "poml:\n  - task:\n      body: {a: 1}", "poml[0]: task: expected a scalar"},
+		{"meta field", "poml:\n  - meta:\n      id: [x]", "poml[0]: meta: expected a scalar"},
+		{"yaml syntax", "poml:\n  - task: &a x", "yaml: line 2: anchors, aliases, and tags are not supported"},
+	}
+	for _, tc := range cases {
+		_, err := ParseYAML(strings.NewReader(tc.src))
+		var pe *POMLError
+		if !errors.As(err, &pe) || pe.Code != CodeDecode || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: got %v, want a CodeDecode error containing %q", tc.name, err, tc.want)
+		}
+	}
+
+	// Markup carried under raw or xml is parsed as POML, so malformed XML fails there.
+	for _, src := range []string{"poml:\n  - raw: <custom>", "poml:\n  - task:\n      xml: <b>unclosed"} {
+		if _, err := ParseYAML(strings.NewReader(src)); err == nil {
+			t.Fatalf("%q: expected an XML error", src)
+		}
+	}
+}
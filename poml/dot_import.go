@@ -0,0 +1,548 @@
+package poml
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ParseDOT parses Graphviz DOT source into a Diagram. It understands graph/digraph headers,
+// node and edge statements (including chains and subgraph operands), node/edge/graph attribute
+// defaults, and subgraphs: named subgraphs become Graph.Groups (nested by parent, labelled from
+// their label attribute) and their IDs become the Group of the nodes they declare.
+// Attributes GraphvizRenderer emits map back onto Diagram fields (label, pos, shape, fillcolor,
+// color, penwidth, style, weight); anything else is kept in Attrs.
+func ParseDOT(src string) (Diagram, error) {
+	toks, err := lexDOT(src)
+	if err != nil {
+		return Diagram{}, err
+	}
+	p := &dotParser{toks: toks, nodes: make(map[string]*dotNode)}
+	if err := p.parseGraph(); err != nil {
+		return Diagram{}, err
+	}
+	return p.diagram(), nil
+}
+
+type dotTokenKind int
+
+const (
+	dotID dotTokenKind = iota
+	dotPunct
+	dotEdgeOp
+)
+
+type dotToken struct {
+	kind   dotTokenKind
+	val    string
+	quoted bool
+	line   int
+}
+
+func lexDOT(src string) ([]dotToken, error) {
+	var toks []dotToken
+	line := 1
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' && (i == 0 || rs[i-1] == '\n'):
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(rs) && rs[i+1] == '/':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			j := i + 2
+			for j+1 < len(rs) && (rs[j] != '*' || rs[j+1] != '/') {
+				if rs[j] == '\n' {
+					line++
+				}
+				j++
+			}
+			if j+1 >= len(rs) {
+				return nil, fmt.Errorf("parse dot: unterminated comment on line %d", line)
+			}
+			i = j + 2
+		case c == '-' && i+1 < len(rs) && (rs[i+1] == '>' || rs[i+1] == '-'):
+			toks = append(toks, dotToken{kind: dotEdgeOp, val: string(rs[i : i+2]), line: line})
+			i += 2
+		case strings.ContainsRune("{}[];,=:", c):
+			toks = append(toks, dotToken{kind: dotPunct, val: string(c), line: line})
+			i++
+		case c == '"':
+			var sb strings.Builder
+			start := line
+			i++
+			for ; i < len(rs) && rs[i] != '"'; i++ {
+				if rs[i] == '\\' && i+1 < len(rs) && rs[i+1] == '"' {
+					i++
+				} else if rs[i] == '\\' && i+1 < len(rs) && rs[i+1] == '\n' {
+					i++
+					line++
+					continue
+				} else if rs[i] == '\n' {
+					line++
+				}
+				sb.WriteRune(rs[i])
+			}
+			if i >= len(rs) {
+				return nil, fmt.Errorf("parse dot: unterminated string on line %d", start)
+			}
+			i++
+			toks = append(toks, dotToken{kind: dotID, val: sb.String(), quoted: true, line: start})
+		case c == '<':
+			depth := 0
+			j := i
+			for ; j < len(rs); j++ {
+				if rs[j] == '<' {
+					depth++
+				} else if rs[j] == '>' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("parse dot: unterminated HTML string on line %d", line)
+			}
+			body := string(rs[i+1 : j])
+			toks = append(toks, dotToken{kind: dotID, val: body, quoted: true, line: line})
+			line += strings.Count(body, "\n")
+			i = j + 1
+		case c == '_' || c == '.' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			j := i
+			for j < len(rs) && (rs[j] == '_' || rs[j] == '.' || unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) ||
+				(rs[j] == '-' && j == i)) {
+				j++
+			}
+			toks = append(toks, dotToken{kind: dotID, val: string(rs[i:j]), line: line})
+			i = j
+		default:
+			return nil, fmt.Errorf("parse dot: unexpected %q on line %d", c, line)
+		}
+	}
+	return toks, nil
+}
+
+type dotNode struct {
+	id    string
+	group string
+	attrs map[string]string
+}
+
+type dotEdge struct {
+	from, to string
+	attrs    map[string]string
+}
+
+type dotScope struct {
+	group        string
+	nodeDefaults map[string]string
+	edgeDefaults map[string]string
+}
+
+type dotParser struct {
+	toks       []dotToken
+	pos        int
+	id         string
+	directed   bool
+	graphAttrs map[string]string
+	nodes      map[string]*dotNode
+	order      []string
+	edges      []dotEdge
+	groups     []DiagramGroup
+}
+
+func (p *dotParser) peek() (dotToken, bool) {
+	if p.pos >= len(p.toks) {
+		return dotToken{}, false
+	}
+	return p.toks[p.pos], true
+}
+
+func (p *dotParser) next() (dotToken, bool) {
+	t, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return t, ok
+}
+
+func (p *dotParser) isPunct(val string) bool {
+	t, ok := p.peek()
+	return ok && t.kind == dotPunct && t.val == val
+}
+
+func (p *dotParser) isKeyword(word string) bool {
+	t, ok := p.peek()
+	return ok && t.kind == dotID && !t.quoted && strings.EqualFold(t.val, word)
+}
+
+func (p *dotParser) expect(val string) error {
+	t, ok := p.next()
+	if !ok {
+		return fmt.Errorf("parse dot: expected %q, got end of input", val)
+	}
+	if t.kind == dotID || t.val != val {
+		return fmt.Errorf("parse dot: expected %q, got %q on line %d", val, t.val, t.line)
+	}
+	return nil
+}
+
+func (p *dotParser) parseGraph() error {
+	if p.isKeyword("strict") {
+		p.pos++
+	}
+	switch {
+	case p.isKeyword("digraph"):
+		p.directed = true
+	case p.isKeyword("graph"):
+	default:
+		return fmt.Errorf("parse dot: expected graph or digraph")
+	}
+	p.pos++
+	if t, ok := p.peek(); ok && t.kind == dotID {
+		p.id = t.val
+		p.pos++
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	p.graphAttrs = map[string]string{}
+	scope := dotScope{nodeDefaults: map[string]string{}, edgeDefaults: map[string]string{}}
+	if _, err := p.parseStmts(scope, true); err != nil {
+		return err
+	}
+	if err := p.expect("}"); err != nil {
+		return err
+	}
+	if t, ok := p.peek(); ok {
+		return fmt.Errorf("parse dot: unexpected %q after graph on line %d", t.val, t.line)
+	}
+	return nil
+}
+
+// parseStmts parses statements up to the closing brace and returns the node IDs they mention.
+func (p *dotParser) parseStmts(scope dotScope, root bool) ([]string, error) {
+	var mentioned []string
+	for {
+		t, ok := p.peek()
+		if !ok {
+			return nil, fmt.Errorf("parse dot: unexpected end of input (missing '}')")
+		}
+		if t.kind == dotPunct && t.val == "}" {
+			return mentioned, nil
+		}
+		if t.kind == dotPunct && t.val == ";" {
+			p.pos++
+			continue
+		}
+		if t.kind == dotID && !t.quoted && (strings.EqualFold(t.val, "graph") || strings.EqualFold(t.val, "node") || strings.EqualFold(t.val, "edge")) &&
+			p.pos+1 < len(p.toks) && p.toks[p.pos+1].val == "[" && p.toks[p.pos+1].kind == dotPunct {
+			p.pos++
+			attrs, err := p.parseAttrLists()
+			if err != nil {
+				return nil, err
+			}
+			switch strings.ToLower(t.val) {
+			case "graph":
+				if root {
+					mergeDOTAttrs(p.graphAttrs, attrs)
+				} else if label, ok := attrs["label"]; ok {
+					p.setGroupLabel(scope.group, label)
+				}
+			case "node":
+				mergeDOTAttrs(scope.nodeDefaults, attrs)
+			case "edge":
+				mergeDOTAttrs(scope.edgeDefaults, attrs)
+			}
+			continue
+		}
+		if t.kind == dotID && p.pos+1 < len(p.toks) && p.toks[p.pos+1].kind == dotPunct && p.toks[p.pos+1].val == "=" {
+			p.pos += 2
+			val, ok := p.next()
+			if !ok || val.kind != dotID {
+				return nil, fmt.Errorf("parse dot: expected value for %s on line %d", t.val, t.line)
+			}
+			if root {
+				p.graphAttrs[t.val] = val.val
+			} else if t.val == "label" {
+				p.setGroupLabel(scope.group, val.val)
+			}
+			continue
+		}
+		ids, err := p.parseEdgeOrNode(scope)
+		if err != nil {
+			return nil, err
+		}
+		mentioned = append(mentioned, ids...)
+	}
+}
+
+// parseOperand parses a node ID (with optional port) or a subgraph and returns its node IDs.
+func (p *dotParser) parseOperand(scope dotScope) ([]string, bool, error) {
+	if p.isKeyword("subgraph") || p.isPunct("{") {
+		sub := dotScope{group: scope.group, nodeDefaults: copyDOTAttrs(scope.nodeDefaults), edgeDefaults: copyDOTAttrs(scope.edgeDefaults)}
+		if p.isKeyword("subgraph") {
+			p.pos++
+			if t, ok := p.peek(); ok && t.kind == dotID {
+				p.addGroup(t.val, scope.group)
+				sub.group = t.val
+				p.pos++
+			}
+		}
+		if err := p.expect("{"); err != nil {
+			return nil, false, err
+		}
+		ids, err := p.parseStmts(sub, false)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, false, err
+		}
+		return ids, true, nil
+	}
+	t, ok := p.next()
+	if !ok || t.kind != dotID {
+		if !ok {
+			return nil, false, fmt.Errorf("parse dot: unexpected end of input")
+		}
+		return nil, false, fmt.Errorf("parse dot: unexpected %q on line %d", t.val, t.line)
+	}
+	// Ports (node:port[:compass]) do not change node identity.
+	for p.isPunct(":") {
+		p.pos++
+		if _, ok := p.next(); !ok {
+			return nil, false, fmt.Errorf("parse dot: missing port after %s", t.val)
+		}
+	}
+	p.ensureNode(t.val, scope)
+	return []string{t.val}, false, nil
+}
+
+func (p *dotParser) parseEdgeOrNode(scope dotScope) ([]string, error) {
+	first, isSub, err := p.parseOperand(scope)
+	if err != nil {
+		return nil, err
+	}
+	operands := [][]string{first}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != dotEdgeOp {
+			break
+		}
+		p.pos++
+		next, _, err := p.parseOperand(scope)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+	var attrs map[string]string
+	if p.isPunct("[") {
+		if attrs, err = p.parseAttrLists(); err != nil {
+			return nil, err
+		}
+	}
+	var mentioned []string
+	for _, ids := range operands {
+		mentioned = append(mentioned, ids...)
+	}
+	if len(operands) == 1 {
+		if !isSub {
+			mergeDOTAttrs(p.nodes[first[0]].attrs, attrs)
+		}
+		return mentioned, nil
+	}
+	for i := 0; i+1 < len(operands); i++ {
+		for _, from := range operands[i] {
+			for _, to := range operands[i+1] {
+				ea := copyDOTAttrs(scope.edgeDefaults)
+				mergeDOTAttrs(ea, attrs)
+				p.edges = append(p.edges, dotEdge{from: from, to: to, attrs: ea})
+			}
+		}
+	}
+	return mentioned, nil
+}
+
+func (p *dotParser) parseAttrLists() (map[string]string, error) {
+	attrs := map[string]string{}
+	for p.isPunct("[") {
+		p.pos++
+		for !p.isPunct("]") {
+			key, ok := p.next()
+			if !ok || key.kind != dotID {
+				if !ok {
+					return nil, fmt.Errorf("parse dot: unterminated attribute list")
+				}
+				return nil, fmt.Errorf("parse dot: expected attribute name, got %q on line %d", key.val, key.line)
+			}
+			val := "true"
+			if p.isPunct("=") {
+				p.pos++
+				v, ok := p.next()
+				if !ok || v.kind != dotID {
+					return nil, fmt.Errorf("parse dot: expected value for %s on line %d", key.val, key.line)
+				}
+				val = v.val
+			}
+			attrs[key.val] = val
+			if p.isPunct(",") || p.isPunct(";") {
+				p.pos++
+			}
+		}
+		p.pos++
+	}
+	return attrs, nil
+}
+
+// addGroup records a named subgraph as a DiagramGroup nested in parent (first declaration wins).
+func (p *dotParser) addGroup(id, parent string) {
+	for _, g := range p.groups {
+		if g.ID == id {
+			return
+		}
+	}
+	p.groups = append(p.groups, DiagramGroup{ID: id, Parent: parent})
+}
+
+func (p *dotParser) setGroupLabel(id, label string) {
+	for i := range p.groups {
+		if p.groups[i].ID == id {
+			p.groups[i].Label = label
+		}
+	}
+}
+
+func (p *dotParser) ensureNode(id string, scope dotScope) {
+	if _, ok := p.nodes[id]; ok {
+		return
+	}
+	p.nodes[id] = &dotNode{id: id, group: scope.group, attrs: copyDOTAttrs(scope.nodeDefaults)}
+	p.order = append(p.order, id)
+}
+
+func (p *dotParser) diagram() Diagram {
+	id := p.id
+	if id == "" {
+		id = "G"
+	}
+	d := Diagram{ID: id, Attrs: attrsFromMap(p.graphAttrs)}
+	for _, nid := range p.order {
+		n := p.nodes[nid]
+		node := DiagramNode{ID: n.id, Group: n.group}
+		style := map[string]string{}
+		rest := map[string]string{}
+		for k, v := range n.attrs {
+			switch k {
+			case "label":
+				node.Label = v
+			case "group":
+				node.Group = v
+			case "weight":
+				node.Weight = v
+			case "pos":
+				coords := strings.Split(strings.TrimSuffix(v, "!"), ",")
+				if len(coords) >= 2 {
+					node.X, node.Y = strings.TrimSpace(coords[0]), strings.TrimSpace(coords[1])
+				}
+				if len(coords) >= 3 {
+					node.Z = strings.TrimSpace(coords[2])
+				}
+			case "shape":
+				style["shape"] = v
+			case "fillcolor":
+				style["color"] = v
+			case "color":
+				style["stroke"] = v
+			case "style":
+				if v != "filled" || n.attrs["fillcolor"] == "" {
+					rest[k] = v
+				}
+			default:
+				rest[k] = v
+			}
+		}
+		node.Styles = stylesFromMap(style)
+		node.Attrs = attrsFromMap(rest)
+		d.Graph.Nodes = append(d.Graph.Nodes, node)
+	}
+	for _, e := range p.edges {
+		edge := DiagramEdge{From: e.from, To: e.to, Directed: ptrBool(p.directed)}
+		style := map[string]string{}
+		rest := map[string]string{}
+		for k, v := range e.attrs {
+			switch k {
+			case "label":
+				edge.Kind = v
+			case "weight":
+				edge.Weight = v
+			case "color":
+				style["stroke"] = v
+			case "penwidth":
+				style["width"] = v
+			case "style":
+				style["dash"] = v
+			case "pos":
+				edge.Points = dotEdgePoints(v)
+			default:
+				rest[k] = v
+			}
+		}
+		edge.Styles = stylesFromMap(style)
+		edge.Attrs = attrsFromMap(rest)
+		d.Graph.Edges = append(d.Graph.Edges, edge)
+	}
+	d.Graph.Groups = p.groups
+	return d
+}
+
+// dotEdgePoints reads the waypoints of an edge pos spline: its distinct points between the two
+// ends, skipping the s,/e, arrowhead markers. A spline GraphvizRenderer wrote comes back as the
+// waypoints it was written from; curved splines keep their control points.
+func dotEdgePoints(pos string) []DiagramPoint {
+	var pts []DiagramPoint
+	for _, f := range strings.Fields(pos) {
+		if strings.HasPrefix(f, "s,") || strings.HasPrefix(f, "e,") {
+			continue
+		}
+		coords := strings.Split(f, ",")
+		if len(coords) < 2 {
+			continue
+		}
+		p := DiagramPoint{X: coords[0], Y: coords[1]}
+		if len(coords) >= 3 {
+			p.Z = coords[2]
+		}
+		if n := len(pts); n == 0 || pts[n-1] != p {
+			pts = append(pts, p)
+		}
+	}
+	if len(pts) <= 2 {
+		return nil
+	}
+	return pts[1 : len(pts)-1]
+}
+
+func mergeDOTAttrs(dst, src map[string]string) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+func copyDOTAttrs(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	mergeDOTAttrs(out, m)
+	return out
+}
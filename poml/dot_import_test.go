@@ -0,0 +1,76 @@
+package poml
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDOTImportRoundTripsGraphvizRenderer(t *testing.T) {
+	want, err := os.ReadFile(filepath.Join("testdata", "diagrams", "chain_sample.dot"))
+	if err != nil {
+		t.Fatalf("read dot: %v", err)
+	}
+	reg := NewConverterRegistry()
+	registerDefaultConverters(reg)
+	out, err := reg.Convert(context.Background(), "dot", "diagram", want, nil)
+	if err != nil {
+		t.Fatalf("dot->diagram: %v", err)
+	}
+	diagram := out.(Diagram)
+	if err := ValidateDiagram(diagram); err != nil {
+		t.Fatalf("imported diagram invalid: %v", err)
+	}
+	scene, err := DiagramToScene(diagram)
+	if err != nil {
+		t.Fatalf("scene: %v", err)
+	}
+	dot, err := (GraphvizRenderer{}).Render(scene)
+	if err != nil {
+		t.Fatalf("render dot: %v", err)
+	}
+	if strings.TrimSpace(string(dot)) != strings.TrimSpace(string(want)) {
+		t.Fatalf("dot round trip mismatch.\n got:\n%s\nwant:\n%s", dot, want)
+	}
+}
+
+func TestParseDOTSubgraphsAndDefaults(t *testing.T) {
+	src := `graph arch {
+  // services
+  rankdir=LR
+  node [shape=box];
+  subgraph cluster_api { label="API"; gw [label="Gateway"]; auth }
+  gw -- {db cache} [label=reads, color=red];
+  /* block
+     comment */
+  auth:out -- db
+}`
+	d, err := ParseDOT(src)
+	if err != nil {
+		t.Fatalf("parse dot: %v", err)
+	}
+	if d.ID != "arch" || len(d.Attrs) != 1 || d.Attrs[0].Value != "LR" {
+		t.Fatalf("graph header mismatch: %+v", d)
+	}
+	if len(d.Graph.Nodes) != 4 || len(d.Graph.Edges) != 3 {
+		t.Fatalf("expected 4 nodes and 3 edges, got %d/%d", len(d.Graph.Nodes), len(d.Graph.Edges))
+	}
+	gw := d.Graph.Nodes[0]
+	if gw.ID != "gw" || gw.Label != "Gateway" || gw.Group != "cluster_api" || gw.Styles[0].Shape != "box" {
+		t.Fatalf("node mismatch: %+v", gw)
+	}
+	e := d.Graph.Edges[1]
+	if e.From != "gw" || e.To != "cache" || e.Kind != "reads" || *e.Directed || e.Styles[0].Stroke != "red" {
+		t.Fatalf("edge mismatch: %+v", e)
+	}
+	if err := ValidateDiagram(d); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	for _, bad := range []string{"", "digraph {", `digraph { a -> }`, `digraph { "a }`} {
+		if _, err := ParseDOT(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package poml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodeElement(t *testing.T) {
+	src := `<poml>
+  <task>Summarize</task>
+  <!-- greeting -->
+  <human-msg speaker="a">Hi &amp; bye</human-msg>
+  <diagram id="d"><graph><node id="a"/><node id="b"/><edge from="a" to="b" directed="true"/></graph></diagram>
+</poml>`
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	msg, err := doc.ElementToString(doc.Elements[1])
+	if err != nil {
+		t.Fatalf("encode message: %v", err)
+	}
+	if msg != `<!-- greeting --><human-msg speaker="a">Hi &amp; bye</human-msg>` {
+		t.Fatalf("unexpected message XML %q", msg)
+	}
+
+	var b strings.Builder
+	if err := doc.EncodeElement(&b, doc.Elements[2], EncodeOptions{Compact: true, IncludeHeader: true}); err != nil {
+		t.Fatalf("encode diagram: %v", err)
+	}
+	out := b.String()
+	if !strings.HasPrefix(out, "<?xml") || strings.Contains(out, "<poml") || strings.Contains(out, "<task") || !strings.Contains(out, `<edge from="a" to="b"`) {
+		t.Fatalf("unexpected diagram XML %q", out)
+	}
+	reparsed, err := ParseString("<poml>" + strings.TrimPrefix(out, `<?xml version="1.0" encoding="UTF-8"?>`+"\n") + "</poml>")
+	if err != nil || len(reparsed.Diagrams) != 1 || len(reparsed.Diagrams[0].Graph.Edges) != 1 {
+		t.Fatalf("diagram did not round-trip: %v %+v", err, reparsed.Diagrams)
+	}
+
+	if _, err := doc.ElementToString(Element{Type: ElementTask, Index: 5}); err == nil {
+		t.Fatal("expected error for out-of-range element")
+	}
+}
+
+func TestAppendEncodeMatchesEncode(t *testing.T) {
+	doc, err := ParseString("<poml>\n  <!-- lead -->\n  <task>Summarize</task>\n  <human-msg>Hi &amp; bye</human-msg>\n</poml>")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for _, opts := range []EncodeOptions{
+		{Indent: "  ", IncludeHeader: true, PreserveOrder: true},
+		{Compact: true},
+		{PreserveOrder: true, PreserveWS: true},
+		{Indent: "\t", PreserveOrder: true},
+	} {
+		var b strings.Builder
+		if err := doc.EncodeWithOptions(&b, opts); err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		// Repeat so pooled encoders are reused across option sets.
+		for i := 0; i < 3; i++ {
+			got, err := AppendEncode([]byte("x"), doc, opts)
+			if err != nil {
+				t.Fatalf("append encode: %v", err)
+			}
+			if string(got) != "x"+b.String() {
+				t.Fatalf("%+v: AppendEncode = %q, want %q", opts, got, b.String())
+			}
+		}
+	}
+}
+
+// writeSizes records the size of every write.
+type writeSizes []int
+
+func (w *writeSizes) Write(p []byte) (int, error) {
+	*w = append(*w, len(p))
+	return len(p), nil
+}
+
+func TestEncodeStreams(t *testing.T) {
+	var src strings.Builder
+	src.WriteString("<poml>")
+	for i := 0; i < 2000; i++ {
+		src.WriteString("<hint>Keep answers short and cite the source.</hint>")
+	}
+	src.WriteString("</poml>")
+	doc, err := ParseString(src.String())
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var w writeSizes
+	if err := doc.Encode(&w); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	total, largest := 0, 0
+	for _, n := range w {
+		total += n
+		largest = max(largest, n)
+	}
+	if total < 100000 || largest > 4096 {
+		t.Fatalf("encode should stream in buffer-sized writes: %d bytes, largest write %d", total, largest)
+	}
+}
@@ -0,0 +1,64 @@
+package poml
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"sync"
+)
+
+// writerPool holds the buffered writers encoders stream through, reused across Encode calls so
+// serving many documents does not allocate them each time. xml.NewEncoder adopts a *bufio.Writer
+// as is, so each encode only allocates the small xml.Encoder itself, whose indentation state must
+// start fresh.
+var writerPool = sync.Pool{New: func() any { return bufio.NewWriter(nil) }}
+
+// AppendEncode appends doc encoded with opts to dst and returns the extended slice, writing
+// straight into it. The output is the same as EncodeWithOptions writes; on error dst is returned
+// unchanged.
+func AppendEncode(dst []byte, doc Document, opts EncodeOptions) ([]byte, error) {
+	out := appendWriter{b: dst}
+	if err := encodePooled(&out, opts, func(enc *xml.Encoder, w io.Writer) error {
+		return encodeDocument(enc, w, doc, opts)
+	}); err != nil {
+		return dst, err
+	}
+	return out.b, nil
+}
+
+// appendWriter appends everything written to it to b.
+type appendWriter struct{ b []byte }
+
+func (a *appendWriter) Write(p []byte) (int, error) {
+	a.b = append(a.b, p...)
+	return len(p), nil
+}
+
+// encodePooled runs encode on an encoder configured for opts that streams to w through a pooled
+// buffered writer, so memory use does not grow with the document. encode writes raw text to the
+// io.Writer it is given, after flushing the encoder.
+func encodePooled(w io.Writer, opts EncodeOptions, encode func(enc *xml.Encoder, out io.Writer) error) error {
+	header, err := opts.declaration()
+	if err != nil {
+		return err
+	}
+	bw := writerPool.Get().(*bufio.Writer)
+	bw.Reset(w)
+	defer func() {
+		bw.Reset(nil) // drop w so the pool does not keep it alive
+		writerPool.Put(bw)
+	}()
+	enc := xml.NewEncoder(bw)
+	if opts.Compact {
+		enc.Indent("", "")
+	} else if opts.Indent != "" {
+		enc.Indent("", opts.Indent)
+	}
+	if _, err := bw.WriteString(header); err != nil {
+		return err
+	}
+	if err := encode(enc, bw); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
@@ -0,0 +1,277 @@
+package poml
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// ErrorCode is a stable, machine-readable identifier for a validation rule or failure kind.
+// Codes never change once published, so they are safe to allow-list in CI. An ErrorCode is
+// itself an error: errors.Is(err, CodeToolReqRef) reports whether err (a POMLError or
+// ValidationError, possibly wrapped) carries that code.
+type ErrorCode string
+
+func (c ErrorCode) Error() string { return string(c) }
+
+// Error codes carried by POMLError.Code.
+const (
+	CodeValidation     ErrorCode = "POML-VALIDATE"        // Document.Validate found issues; see ValidationError
+	CodeDecode         ErrorCode = "POML-DECODE"          // malformed XML or an element that failed to decode
+	CodeRuntime        ErrorCode = "POML-RUNTIME"         // a <runtime> attribute has the wrong type
+	CodeMerge          ErrorCode = "POML-MERGE"           // documents could not be merged
+	CodeLimit          ErrorCode = "POML-LIMIT"           // input exceeded a ParseOptions size, count, or depth limit
+	CodeTemplate       ErrorCode = "POML-TEMPLATE"        // ParseTemplate could not parse or execute the template
+	CodeBudget         ErrorCode = "POML-BUDGET"          // Truncate could not fit the document in its token budget
+	CodeUnknownElement ErrorCode = "POML-UNKNOWN-ELEMENT" // ParseOptions.DisallowUnknown found tags POML does not define
+	CodeInputMissing   ErrorCode = "POML-INPUT-MISSING"   // ConvertOptions.Inputs left a required or referenced input without a value; see MissingInputError
+	CodeObjectDecode   ErrorCode = "POML-OBJECT-DECODE"   // ObjectTag.Value or Decode could not parse the payload in its syntax
+	CodeExtends        ErrorCode = "POML-EXTENDS"         // LoadExtends could not load a parent, or the extends chain has a cycle
+	CodeControlFlow    ErrorCode = "POML-CONTROL-FLOW"    // an if or for attribute, or a {{ }} expression, failed in ExpandControlFlow
+	CodeOutput         ErrorCode = "POML-OUTPUT"          // ValidateOutput rejected a model response; see ValidationError
+	CodeOutputSchema   ErrorCode = "POML-OUTPUT-SCHEMA"   // ValidateOutput found no <output-schema>, or one that is not a usable JSON Schema
+)
+
+// Validation codes carried by ValidationDetail.Code.
+const (
+	CodeMetaRequired  ErrorCode = "POML-META-001" // no <meta> section
+	CodeMetaDuplicate ErrorCode = "POML-META-002" // more than one <meta>
+	CodeMetaID        ErrorCode = "POML-META-003" // meta.id missing
+	CodeMetaVersion   ErrorCode = "POML-META-004" // meta.version missing
+	CodeMetaOwner     ErrorCode = "POML-META-005" // meta.owner missing
+	CodeRoleRequired  ErrorCode = "POML-ROLE-001" // no <role>
+	CodeRoleDuplicate ErrorCode = "POML-ROLE-002" // more than one <role>
+	CodeTaskRequired  ErrorCode = "POML-TASK-001" // no <task>
+
+	CodeInputName      ErrorCode = "POML-INPUT-NAME"      // <input> without a name
+	CodeInputDuplicate ErrorCode = "POML-INPUT-DUP"       // two inputs share a name
+	CodeInputUnused    ErrorCode = "POML-INPUT-UNUSED"    // <input> named by no {{ }} expression
+	CodeInputUndefined ErrorCode = "POML-INPUT-UNDEFINED" // {{inputs.NAME}} names no <input>
+	CodeDocumentSrc    ErrorCode = "POML-DOCUMENT-SRC"    // <document> without src
+	CodeStyleFormat    ErrorCode = "POML-STYLE-FORMAT"    // <output> in <style> without format
+
+	CodeToolDefName      ErrorCode = "POML-TOOL-DEF-NAME"     // <tool-definition> without a name
+	CodeToolDefDuplicate ErrorCode = "POML-TOOL-DEF-DUP"      // two tool definitions share a name
+	CodeToolDefRef       ErrorCode = "POML-TOOL-DEF-REF"      // a tool call names an undefined tool
+	CodeToolDefBody      ErrorCode = "POML-TOOL-DEF-BODY"     // <tool-definition> body (parser absent or json) is not a JSON parameters schema
+	CodeToolID           ErrorCode = "POML-TOOL-ID"           // tool request/response/result/error without id
+	CodeToolName         ErrorCode = "POML-TOOL-NAME"         // tool request/response/result/error without name
+	CodeToolReqDuplicate ErrorCode = "POML-TOOL-REQ-DUP"      // two tool requests share an id
+	CodeToolReqRef       ErrorCode = "POML-TOOL-REQ-REF"      // a response/result/error id matches no request
+	CodeToolReqMismatch  ErrorCode = "POML-TOOL-REQ-MISMATCH" // a response/result/error names a different tool than its request
+
+	CodeSchemaEmpty     ErrorCode = "POML-SCHEMA-EMPTY" // <output-schema> without body or attributes
+	CodeImageSrc        ErrorCode = "POML-IMG-SRC"      // <img> without src or body
+	CodeImageAlt        ErrorCode = "POML-IMG-ALT"      // <img> without alt text
+	CodeHintBody        ErrorCode = "POML-HINT-BODY"    // empty <hint>
+	CodeExampleBody     ErrorCode = "POML-EXAMPLE-BODY" // empty <example>
+	CodeContentPartBody ErrorCode = "POML-CP-BODY"      // empty <cp>
+	CodeObjectData      ErrorCode = "POML-OBJECT-DATA"  // <object> without data or body
+	CodeMsgRole         ErrorCode = "POML-MSG-ROLE"     // <msg> without a role
+
+	CodeMetaIDPattern    ErrorCode = "POML-META-ID-PATTERN"    // meta.id fails a MetaIDPattern validator
+	CodeMetaOwnerAllowed ErrorCode = "POML-META-OWNER-ALLOWED" // meta.owner is not in an AllowedOwners list
+	CodeBannedPhrase     ErrorCode = "POML-BANNED-PHRASE"      // an element body contains a BannedPhrases phrase
+)
+
+// Diagram validation codes, reported by ValidateDiagram and Document.Validate.
+const (
+	CodeDiagram               ErrorCode = "POML-DIAGRAM"               // diagram validation failed without details
+	CodeDiagramID             ErrorCode = "POML-DIAGRAM-ID"            // <diagram> without id
+	CodeDiagramNodeID         ErrorCode = "POML-DIAGRAM-NODE-ID"       // node without id
+	CodeDiagramNodeDuplicate  ErrorCode = "POML-DIAGRAM-NODE-DUP"      // two nodes share an id
+	CodeDiagramEdgeEnds       ErrorCode = "POML-DIAGRAM-EDGE-ENDS"     // edge without from or to
+	CodeDiagramEdgeRef        ErrorCode = "POML-DIAGRAM-EDGE-REF"      // edge endpoint names no node
+	CodeDiagramEdgeDirected   ErrorCode = "POML-DIAGRAM-EDGE-DIRECTED" // edge without a directed flag
+	CodeDiagramEdgePoint      ErrorCode = "POML-DIAGRAM-EDGE-POINT"    // edge <point> without numeric x and y
+	CodeDiagramGroupID        ErrorCode = "POML-DIAGRAM-GROUP-ID"      // group without id
+	CodeDiagramGroupDuplicate ErrorCode = "POML-DIAGRAM-GROUP-DUP"     // two groups share an id
+	CodeDiagramGroupMember    ErrorCode = "POML-DIAGRAM-GROUP-MEMBER"  // member names no node
+	CodeDiagramGroupOverlap   ErrorCode = "POML-DIAGRAM-GROUP-OVERLAP" // node listed in two groups
+	CodeDiagramGroupParent    ErrorCode = "POML-DIAGRAM-GROUP-PARENT"  // parent names no group
+	CodeDiagramGroupCycle     ErrorCode = "POML-DIAGRAM-GROUP-CYCLE"   // group is its own ancestor
+	CodeDiagramFrameTime      ErrorCode = "POML-DIAGRAM-FRAME-T"       // <frame> time is missing or not a number
+	CodeDiagramFrameNode      ErrorCode = "POML-DIAGRAM-FRAME-NODE"    // frame node names no node
+	CodeDiagramNumber         ErrorCode = "POML-DIAGRAM-NUMBER"        // numeric attribute is not a number (StrictNumbers only)
+)
+
+// Output validation codes, reported by ValidateOutput.
+const (
+	CodeOutputJSON  ErrorCode = "POML-OUTPUT-JSON"  // the response is not a single JSON value
+	CodeOutputField ErrorCode = "POML-OUTPUT-FIELD" // the value at the detail's Field (a JSON Pointer) violates the schema
+)
+
+// Severity ranks validation issues. Errors make a document unusable; warnings flag likely
+// mistakes, such as an empty hint or an image without alt text, that still convert.
+type Severity int
+
+const (
+	SeverityWarning Severity = iota + 1
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText encodes the severity as "warning" or "error".
+func (s Severity) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// UnmarshalText accepts "warning" or "error", so severities read from flags and config files.
+func (s *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "warning":
+		*s = SeverityWarning
+	case "error":
+		*s = SeverityError
+	default:
+		return fmt.Errorf("unknown severity %q (want warning or error)", text)
+	}
+	return nil
+}
+
+// warningCodes are the validation codes reported as warnings; every other code is an error.
+var warningCodes = map[ErrorCode]bool{
+	CodeInputUnused:     true,
+	CodeImageAlt:        true,
+	CodeHintBody:        true,
+	CodeExampleBody:     true,
+	CodeContentPartBody: true,
+	CodeToolDefBody:     true,
+}
+
+// Severity returns the severity validation reports c with.
+func (c ErrorCode) Severity() Severity {
+	if warningCodes[c] {
+		return SeverityWarning
+	}
+	return SeverityError
+}
+
+// Is reports whether target is e's Code, so errors.Is(err, CodeValidation) works.
+func (e *POMLError) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+	return ok && code != "" && code == e.Code
+}
+
+// Is reports whether any detail carries the target ErrorCode.
+func (v *ValidationError) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+	if !ok {
+		return false
+	}
+	for _, d := range v.Details {
+		if d.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Codes returns the distinct detail codes in sorted order.
+func (v *ValidationError) Codes() []ErrorCode {
+	var out []ErrorCode
+	for _, d := range v.Details {
+		if d.Code != "" && !slices.Contains(out, d.Code) {
+			out = append(out, d.Code)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// Without returns a copy of v minus the issues whose code is listed, or nil when none remain.
+func (v *ValidationError) Without(codes ...ErrorCode) *ValidationError {
+	return v.filter(func(d ValidationDetail) bool { return !slices.Contains(codes, d.Code) })
+}
+
+// AtLeast returns a copy of v with only the issues of severity s or higher, or nil when none
+// remain. Details without a Severity count as errors.
+func (v *ValidationError) AtLeast(s Severity) *ValidationError {
+	return v.filter(func(d ValidationDetail) bool { return d.severity() >= s })
+}
+
+// filter returns a copy of v with the issues keep accepts, or nil when none remain.
+func (v *ValidationError) filter(keep func(ValidationDetail) bool) *ValidationError {
+	out := &ValidationError{}
+	for i, d := range v.Details {
+		if !keep(d) {
+			continue
+		}
+		out.Details = append(out.Details, d)
+		if i < len(v.Issues) {
+			out.Issues = append(out.Issues, v.Issues[i])
+		}
+	}
+	if len(out.Details) == 0 {
+		return nil
+	}
+	return out
+}
+
+// validationIssueJSON is the wire form of one ValidationError entry.
+type validationIssueJSON struct {
+	Code      ErrorCode   `json:"code,omitempty"`
+	Severity  Severity    `json:"severity,omitempty"`
+	Element   ElementType `json:"element,omitempty"`
+	ElementID string      `json:"element_id,omitempty"`
+	Field     string      `json:"field,omitempty"`
+	Message   string      `json:"message"`
+	Detail    string      `json:"detail,omitempty"`
+}
+
+// MarshalJSON encodes the error as {"error": ..., "issues": [{"code", "severity", "element",
+// "element_id", "field", "message", "detail"}]}, pairing each issue with its detail.
+func (v *ValidationError) MarshalJSON() ([]byte, error) {
+	issues := make([]validationIssueJSON, 0, max(len(v.Issues), len(v.Details)))
+	for i := 0; i < max(len(v.Issues), len(v.Details)); i++ {
+		var entry validationIssueJSON
+		if i < len(v.Details) {
+			d := v.Details[i]
+			entry = validationIssueJSON{Code: d.Code, Severity: d.severity(), Element: d.Element, ElementID: d.ElementID, Field: d.Field, Message: d.Message}
+		}
+		if i < len(v.Issues) {
+			entry.Message, entry.Detail = v.Issues[i], entry.Message
+		}
+		issues = append(issues, entry)
+	}
+	return json.Marshal(struct {
+		Error  string                `json:"error"`
+		Issues []validationIssueJSON `json:"issues"`
+	}{v.Error(), issues})
+}
+
+// AllowCodes drops validation issues whose code is allow-listed. It returns nil when every
+// issue was allowed and otherwise err narrowed to the remaining issues (wrapped in a copy of its
+// POMLError when it had one). Errors without a ValidationError are returned unchanged.
+func AllowCodes(err error, codes ...ErrorCode) error {
+	return narrowValidation(err, func(ve *ValidationError) *ValidationError { return ve.Without(codes...) })
+}
+
+// narrowValidation replaces the ValidationError in err with narrow's result, as AllowCodes
+// describes.
+func narrowValidation(err error, narrow func(*ValidationError) *ValidationError) error {
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		return err
+	}
+	rest := narrow(ve)
+	if rest == nil {
+		return nil
+	}
+	var pe *POMLError
+	if errors.As(err, &pe) && pe.Err == error(ve) {
+		out := *pe
+		out.Err = rest
+		return &out
+	}
+	return rest
+}
@@ -0,0 +1,119 @@
+package poml
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidationErrorCodes(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <meta><id>x</id><version>1</version><owner>o</owner></meta>
+  <role>r</role>
+  <task>t</task>
+  <input/>
+  <tool-request id="c1" name="search" parameters="{}"/>
+  <tool-response id="c2" name="search">none</tool-response>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	err = doc.Validate()
+	if !errors.Is(err, CodeValidation) || !errors.Is(err, CodeToolReqRef) || errors.Is(err, CodeMetaRequired) {
+		t.Fatalf("errors.Is mismatch for %v", err)
+	}
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Issues) != len(ve.Details) {
+		t.Fatalf("issues and details should line up: %+v", ve)
+	}
+	want := []ErrorCode{CodeInputName, CodeToolDefRef, CodeToolReqRef}
+	if got := ve.Codes(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("codes = %v, want %v", got, want)
+	}
+
+	raw, merr := json.Marshal(ve)
+	if merr != nil {
+		t.Fatalf("marshal: %v", merr)
+	}
+	var decoded struct {
+		Issues []map[string]string `json:"issues"`
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	first := decoded.Issues[0]
+	if first["code"] != "POML-INPUT-NAME" || first["element"] != "input" || first["message"] != "input.name is required" || first["detail"] != "input 0 missing name" {
+		t.Fatalf("unexpected JSON issue: %s", raw)
+	}
+
+	rest := AllowCodes(err, CodeInputName, CodeToolDefRef)
+	var pe *POMLError
+	if !errors.As(rest, &pe) || pe.Code != CodeValidation || !errors.Is(rest, CodeToolReqRef) || errors.Is(rest, CodeInputName) {
+		t.Fatalf("AllowCodes should keep the POMLError and drop allowed codes: %v", rest)
+	}
+	if AllowCodes(err, want...) != nil {
+		t.Fatalf("all issues allowed should yield nil")
+	}
+	if _, err := ParseString("<poml><task></poml>"); !errors.Is(err, CodeDecode) {
+		t.Fatalf("expected decode code, got %v", err)
+	}
+}
+
+func TestValidateWithOptionsSplitsSeverities(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <meta><id>x</id><version>1</version><owner>o</owner></meta>
+  <role>r</role>
+  <task>t</task>
+  <hint></hint>
+  <img src="a.png"/>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := doc.ValidateWithOptions(ValidateOptions{FailOn: SeverityWarning}); !errors.Is(err, CodeImageAlt) {
+		t.Fatalf("FailOn warning should fail on warnings, got %v", err)
+	}
+	warnings, err := doc.ValidateWithOptions(ValidateOptions{})
+	if err != nil {
+		t.Fatalf("a document with only warnings should pass by default: %v", err)
+	}
+	if got := warnings.Codes(); !reflect.DeepEqual(got, []ErrorCode{CodeHintBody, CodeImageAlt}) {
+		t.Fatalf("warning codes = %v", got)
+	}
+	for _, d := range warnings.Details {
+		if d.Severity != SeverityWarning {
+			t.Fatalf("detail %+v should be a warning", d)
+		}
+	}
+	raw, _ := json.Marshal(warnings)
+	if !strings.Contains(string(raw), `"severity":"warning"`) {
+		t.Fatalf("JSON should carry severity: %s", raw)
+	}
+
+	doc.Meta = Meta{}
+	warnings, err = doc.ValidateWithOptions(ValidateOptions{FailOn: SeverityError})
+	if !errors.Is(err, CodeMetaID) || errors.Is(err, CodeHintBody) || len(warnings.Issues) != 2 {
+		t.Fatalf("missing meta should stay an error: %v (warnings %v)", err, warnings)
+	}
+}
+
+func TestWarningsDoNotFailStrictParsing(t *testing.T) {
+	src := `<poml>
+  <meta><id>x</id><version>1</version><owner>o</owner></meta>
+  <role>r</role>
+  <task>t</task>
+  <img src="chart.png"/>
+</poml>`
+	doc, err := ParseStringStrict(src)
+	if err != nil {
+		t.Fatalf("an img without alt should still parse strictly: %v", err)
+	}
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("Validate should not fail on warnings: %v", err)
+	}
+	if warnings, _ := doc.ValidateWithOptions(ValidateOptions{}); !errors.Is(warnings, CodeImageAlt) {
+		t.Fatalf("the missing alt should still be reported as a warning, got %v", warnings)
+	}
+}
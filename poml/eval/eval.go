@@ -0,0 +1,281 @@
+// Package eval runs prompt regression tests against a POML document, so the expectations for a
+// prompt live in the prompt itself.
+//
+// Every <example> written as one <input> and one <output> child is a case: the example is held
+// out of the prompt, its input is appended as the final human message, and the converted payload
+// is passed to a Target standing in for the model. The reply passes when it matches the
+// example's output and, if the document has an <output-schema>, when it is JSON valid against the
+// schema (see poml.ValidateOutput). A document with a schema but no paired examples runs one
+// case checking only the schema.
+//
+//	report, err := eval.Run(ctx, doc, target, eval.Options{})
+//	if err != nil { ... }
+//	if err := report.Err(); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// An example's match attribute selects how its output is compared: "exact" (the default, after
+// trimming whitespace), "contains", "regex", or "json" (equal once both sides are decoded).
+package eval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+// Target sends a converted payload to a model (or a stub) and returns the reply text.
+type Target func(ctx context.Context, payload any) (string, error)
+
+// Match modes for an example's match attribute.
+const (
+	MatchExact    = "exact"
+	MatchContains = "contains"
+	MatchRegex    = "regex"
+	MatchJSON     = "json"
+)
+
+// Options configures Run.
+type Options struct {
+	// Format is the payload shape handed to the Target; empty means poml.FormatOpenAIChat.
+	Format poml.Format
+	// Convert is passed to poml.ConvertContext for every case.
+	Convert poml.ConvertOptions
+}
+
+// Case is one evaluation derived from a document.
+type Case struct {
+	Name     string       // the example's name or id attribute, else "example[N]"; "schema" for the shape-only case
+	Element  poml.Element // the held-out <example>; zero for the shape-only case
+	Input    string       // inner XML of the example's <input>, appended as a human message
+	Expected string       // text of the example's <output>; empty for the shape-only case
+	Match    string       // one of the Match constants
+}
+
+// Result is the outcome of one Case.
+type Result struct {
+	Case     Case
+	Output   string   // the Target's reply
+	Failures []string // assertion failures; empty when the case passed
+	Err      error    // conversion or Target error; the case then fails without assertions
+}
+
+// Passed reports whether the case ran and every assertion held.
+func (r Result) Passed() bool { return r.Err == nil && len(r.Failures) == 0 }
+
+// Report collects the results of Run in case order.
+type Report struct {
+	Results []Result
+}
+
+// Failed returns the results that did not pass.
+func (r Report) Failed() []Result {
+	var failed []Result
+	for _, res := range r.Results {
+		if !res.Passed() {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Err returns an error describing every failed case, or nil when all passed.
+func (r Report) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(failed))
+	for _, res := range failed {
+		reason := strings.Join(res.Failures, "; ")
+		if res.Err != nil {
+			reason = res.Err.Error()
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", res.Case.Name, reason))
+	}
+	return fmt.Errorf("eval: %d of %d cases failed:\n%s", len(failed), len(r.Results), strings.Join(lines, "\n"))
+}
+
+// String summarizes the report as one PASS/FAIL line per case.
+func (r Report) String() string {
+	var b strings.Builder
+	for _, res := range r.Results {
+		status := "PASS"
+		if !res.Passed() {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "%s %s\n", status, res.Case.Name)
+	}
+	fmt.Fprintf(&b, "%d/%d passed\n", len(r.Results)-len(r.Failed()), len(r.Results))
+	return b.String()
+}
+
+// Cases returns the evaluations Run performs for doc, in document order.
+func Cases(doc poml.Document) []Case {
+	var cases []Case
+	for _, el := range doc.Elements {
+		if el.Type != poml.ElementExample || el.Index < 0 || el.Index >= len(doc.Examples) {
+			continue
+		}
+		ex := doc.Examples[el.Index]
+		pair := doc.ExamplePair(el)
+		if pair == nil {
+			continue
+		}
+		name := ex.Attr("name")
+		if name == "" {
+			name = ex.Attr("id")
+		}
+		if name == "" {
+			name = fmt.Sprintf("example[%d]", el.Index)
+		}
+		match := strings.ToLower(strings.TrimSpace(ex.Attr("match")))
+		if match == "" {
+			match = MatchExact
+		}
+		cases = append(cases, Case{Name: name, Element: el, Input: pair.Input, Expected: innerText(pair.Output), Match: match})
+	}
+	if len(cases) == 0 && strings.TrimSpace(doc.Schema.Body) != "" {
+		cases = append(cases, Case{Name: "schema"})
+	}
+	return cases
+}
+
+// Run evaluates every case of doc against target. The returned error is for problems with the
+// document itself (an unreadable <output-schema>); case failures are reported in the Report.
+func Run(ctx context.Context, doc poml.Document, target Target, opts Options) (Report, error) {
+	if opts.Format == "" {
+		opts.Format = poml.FormatOpenAIChat
+	}
+	hasSchema := strings.TrimSpace(doc.Schema.Body) != ""
+	if hasSchema {
+		// Validating an empty reply reports an unusable schema before any case runs.
+		var pe *poml.POMLError
+		if err := poml.ValidateOutput(doc, nil); errors.As(err, &pe) && pe.Code == poml.CodeOutputSchema {
+			return Report{}, fmt.Errorf("eval: %w", err)
+		}
+	}
+	var report Report
+	for _, c := range Cases(doc) {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		res := Result{Case: c}
+		payload, err := poml.ConvertContext(ctx, caseDocument(doc, c), opts.Format, opts.Convert)
+		if err == nil {
+			res.Output, err = target(ctx, payload)
+		}
+		if err != nil {
+			res.Err = err
+		} else {
+			res.Failures = check(c, doc, hasSchema, res.Output)
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report, nil
+}
+
+// caseDocument returns a copy of doc with c's example removed and its input appended as the last
+// human message.
+func caseDocument(doc poml.Document, c Case) poml.Document {
+	out := doc.Clone()
+	if c.Element.ID == "" {
+		return out
+	}
+	_ = out.Mutate(func(el poml.Element, _ poml.ElementPayload, m *poml.Mutator) error {
+		if el.ID == c.Element.ID {
+			m.Remove(el)
+		}
+		return nil
+	})
+	out.AddMessage("human", c.Input)
+	return out
+}
+
+// check returns the assertion failures for output.
+func check(c Case, doc poml.Document, hasSchema bool, output string) []string {
+	var failures []string
+	got := strings.TrimSpace(output)
+	if c.Element.ID != "" {
+		if msg := compare(c.Match, c.Expected, got); msg != "" {
+			failures = append(failures, msg)
+		}
+	}
+	if hasSchema {
+		var ve *poml.ValidationError
+		if err := poml.ValidateOutput(doc, []byte(got)); errors.As(err, &ve) {
+			failures = append(failures, ve.Issues...)
+		} else if err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	return failures
+}
+
+func compare(match, want, got string) string {
+	switch match {
+	case MatchExact:
+		if got != strings.TrimSpace(want) {
+			return fmt.Sprintf("output %q, want %q", got, strings.TrimSpace(want))
+		}
+	case MatchContains:
+		if !strings.Contains(got, want) {
+			return fmt.Sprintf("output %q does not contain %q", got, want)
+		}
+	case MatchRegex:
+		re, err := regexp.Compile(want)
+		if err != nil {
+			return fmt.Sprintf("bad regex %q: %v", want, err)
+		}
+		if !re.MatchString(got) {
+			return fmt.Sprintf("output %q does not match %q", got, want)
+		}
+	case MatchJSON:
+		var w, g any
+		if err := json.Unmarshal([]byte(want), &w); err != nil {
+			return fmt.Sprintf("expected output is not JSON: %v", err)
+		}
+		if err := json.Unmarshal([]byte(got), &g); err != nil {
+			return fmt.Sprintf("output is not JSON: %v", err)
+		}
+		if !reflect.DeepEqual(w, g) {
+			return fmt.Sprintf("output %s, want JSON %s", got, want)
+		}
+	default:
+		return fmt.Sprintf("unknown match mode %q", match)
+	}
+	return ""
+}
+
+// innerText decodes inner XML to its text (entities and CDATA resolved), returning s unchanged
+// when it holds markup.
+func innerText(s string) string {
+	var text bytes.Buffer
+	dec := xml.NewDecoder(strings.NewReader("<t>" + s + "</t>"))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return text.String()
+			}
+			return s
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.StartElement:
+			if t.Name.Local != "t" {
+				return s
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+const sentimentPrompt = `<poml>
+  <role>Classify sentiment.</role>
+  <task>Reply with JSON.</task>
+  <output-schema>{"type":"object","required":["label"]}</output-schema>
+  <example name="happy"><input>I love it</input><output>{"label": "positive"}</output></example>
+  <example name="sad" match="json"><input>I hate it</input><output>{"label":"negative"}</output></example>
+  <example match="contains"><input>It is fine</input><output>neutral</output></example>
+</poml>`
+
+// stubTarget answers from a canned table keyed by the last message of an openai_chat payload.
+func stubTarget(replies map[string]string) Target {
+	return func(_ context.Context, payload any) (string, error) {
+		msgs := payload.(map[string]any)["messages"].([]map[string]any)
+		last, _ := msgs[len(msgs)-1]["content"].(string)
+		for _, m := range msgs[:len(msgs)-1] {
+			if m["content"] == last {
+				return "", errors.New("held-out example leaked into the prompt")
+			}
+		}
+		reply, ok := replies[last]
+		if !ok {
+			return "", errors.New("no reply for " + last)
+		}
+		return reply, nil
+	}
+}
+
+func TestRunReportsPassAndFail(t *testing.T) {
+	doc, err := poml.ParseString(sentimentPrompt)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	report, err := Run(context.Background(), doc, stubTarget(map[string]string{
+		"I love it":  `{"label": "positive"}`,
+		"I hate it":  `{"label": "negative", "score": 1}`,
+		"It is fine": `{"label": "neutral"}`,
+	}), Options{})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(report.Results) != 3 || report.Results[2].Case.Name != "example[2]" {
+		t.Fatalf("unexpected cases: %+v", report.Results)
+	}
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0].Case.Name != "sad" || failed[0].Err != nil {
+		t.Fatalf("only the json mismatch should fail: %s", report)
+	}
+	if err := report.Err(); err == nil || !strings.Contains(err.Error(), "1 of 3 cases failed") {
+		t.Fatalf("report error = %v", err)
+	}
+	if got := report.String(); !strings.HasPrefix(got, "PASS happy\nFAIL sad\nPASS example[2]\n") || !strings.HasSuffix(got, "2/3 passed\n") {
+		t.Fatalf("summary = %q", got)
+	}
+
+	report, _ = Run(context.Background(), doc, stubTarget(map[string]string{
+		"I love it":  `{"label": "positive"}`,
+		"I hate it":  `{"label":"negative"}`,
+		"It is fine": `neutral`,
+	}), Options{})
+	if f := report.Failed(); len(f) != 1 || !strings.Contains(f[0].Failures[0], "not JSON") {
+		t.Fatalf("schema shape should fail non-JSON replies: %s", report)
+	}
+}
+
+func TestRunSchemaOnlyCase(t *testing.T) {
+	doc, err := poml.ParseString(`<poml><task>Give a list.</task><output-schema>{"type":"array"}</output-schema></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	target := func(_ context.Context, _ any) (string, error) { return `{"items":[]}`, nil }
+	report, err := Run(context.Background(), doc, target, Options{Format: poml.FormatDict})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(report.Results) != 1 || report.Results[0].Case.Name != "schema" || report.Err() == nil {
+		t.Fatalf("object reply should fail an array schema: %s", report)
+	}
+	if got := report.Results[0].Failures; len(got) != 1 || got[0] != "(root): got object, want array" {
+		t.Fatalf("failures = %q", got)
+	}
+
+	doc, err = poml.ParseString(`<poml><task>t</task><output-schema>{"$ref": "other.json"}</output-schema></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := Run(context.Background(), doc, target, Options{}); !errors.Is(err, poml.CodeOutputSchema) {
+		t.Fatalf("unusable schema should fail the run, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package poml
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// ExamplePair is the typed form of an <example> written as one <input> and one <output> child.
+// Input and Output are the children's inner XML, trimmed.
+type ExamplePair struct {
+	Input  string
+	Output string
+}
+
+// parseExamplePair recognizes an example body made of exactly one <input> and one <output>
+// (whitespace and comments aside), returning nil for any other body.
+func parseExamplePair(body string) *ExamplePair {
+	if !strings.Contains(body, "<input") || !strings.Contains(body, "<output") {
+		return nil
+	}
+	type child struct {
+		Body string `xml:",innerxml"`
+	}
+	var ex struct {
+		Text    string     `xml:",chardata"`
+		Inputs  []child    `xml:"input"`
+		Outputs []child    `xml:"output"`
+		Other   []xml.Name `xml:",any"`
+	}
+	if err := xml.Unmarshal([]byte("<example>"+body+"</example>"), &ex); err != nil {
+		return nil
+	}
+	if len(ex.Inputs) != 1 || len(ex.Outputs) != 1 || len(ex.Other) > 0 || strings.TrimSpace(ex.Text) != "" {
+		return nil
+	}
+	return &ExamplePair{Input: strings.TrimSpace(ex.Inputs[0].Body), Output: strings.TrimSpace(ex.Outputs[0].Body)}
+}
+
+// examplePair returns the few-shot pair for an <example> element, or nil when the element is not
+// a paired example or opts.FlattenExamples is set. Examples built or edited without Pair are
+// recognized from their Body.
+func (d Document) examplePair(el Element, opts ConvertOptions) *ExamplePair {
+	if opts.FlattenExamples || el.Type != ElementExample || el.Index < 0 || el.Index >= len(d.Examples) {
+		return nil
+	}
+	ex := d.Examples[el.Index]
+	if ex.Pair != nil {
+		return ex.Pair
+	}
+	return parseExamplePair(ex.Body)
+}
+
+// ExamplePair returns the few-shot pair of el when it is an <example> of one <input> and one
+// <output>, or nil otherwise.
+func (d Document) ExamplePair(el Element) *ExamplePair {
+	return d.examplePair(el, ConvertOptions{})
+}
@@ -0,0 +1,96 @@
+package poml
+
+import (
+	"reflect"
+	"testing"
+)
+
+const pairedExampleDoc = `<poml>
+  <task>Translate to French</task>
+  <example>
+    <!-- greeting -->
+    <input>Hello</input>
+    <output>Bonjour</output>
+  </example>
+  <example>Free-form <b>example</b></example>
+  <human-msg>Goodbye</human-msg>
+</poml>`
+
+func TestExamplePairParsing(t *testing.T) {
+	doc, err := ParseString(pairedExampleDoc)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := doc.Examples[0].Pair; got == nil || *got != (ExamplePair{Input: "Hello", Output: "Bonjour"}) {
+		t.Fatalf("unexpected pair %+v", got)
+	}
+	if doc.Examples[1].Pair != nil {
+		t.Fatalf("free-form example should not pair: %+v", doc.Examples[1].Pair)
+	}
+	for _, body := range []string{
+		"<input>a</input>",
+		"<input>a</input><output>b</output><output>c</output>",
+		"text <input>a</input><output>b</output>",
+		"<input>a</input><note/><output>b</output>",
+	} {
+		if pair := parseExamplePair(body); pair != nil {
+			t.Fatalf("%q should not pair, got %+v", body, pair)
+		}
+	}
+
+	built := NewBuilder().Task("t").ExamplePair("2+2", "4").Build()
+	out, err := Convert(built, FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert built: %v", err)
+	}
+	msgs := out.(map[string]any)["messages"].([]map[string]any)
+	if len(msgs) != 2 || msgs[1]["content"] != "4" {
+		t.Fatalf("unexpected built messages %v", msgs)
+	}
+}
+
+func TestChatConvertersEmitExamplePairs(t *testing.T) {
+	doc, err := ParseString(pairedExampleDoc)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out, err := Convert(doc, FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("openai_chat: %v", err)
+	}
+	var roles, contents []any
+	for _, m := range out.(map[string]any)["messages"].([]map[string]any) {
+		roles, contents = append(roles, m["role"]), append(contents, m["content"])
+	}
+	if want := []any{"user", "assistant", "user", "user"}; !reflect.DeepEqual(roles, want) {
+		t.Fatalf("roles = %v, want %v", roles, want)
+	}
+	if contents[0] != "Hello" || contents[1] != "Bonjour" {
+		t.Fatalf("unexpected pair contents %v", contents)
+	}
+
+	cohere, err := Convert(doc, FormatCohere, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("cohere: %v", err)
+	}
+	history := cohere.(map[string]any)["chat_history"].([]map[string]any)
+	if history[1]["role"] != "CHATBOT" || history[1]["message"] != "Bonjour" {
+		t.Fatalf("unexpected cohere history %v", history)
+	}
+
+	bedrock, err := Convert(doc, FormatBedrockConverse, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("bedrock: %v", err)
+	}
+	if msgs := bedrock.(map[string]any)["messages"].([]map[string]any); len(msgs) != 3 || msgs[1]["role"] != "assistant" {
+		t.Fatalf("unexpected bedrock messages %v", msgs)
+	}
+
+	flat, err := Convert(doc, FormatOpenAIChat, ConvertOptions{FlattenExamples: true})
+	if err != nil {
+		t.Fatalf("flattened: %v", err)
+	}
+	if msgs := flat.(map[string]any)["messages"].([]map[string]any); len(msgs) != 3 || msgs[0]["role"] != "user" {
+		t.Fatalf("FlattenExamples should keep one user message per example, got %v", msgs)
+	}
+}
@@ -9,6 +9,7 @@ import (
 )
 
 // Parity check: ensure converters produce stable shapes matching Python fixture expectations.
+// The fixtures use the legacy tool-definition reading, where a body doubles as the description.
 func TestConverterParityFixtures(t *testing.T) {
 	fixture := filepath.Join("testdata", "examples", "parity_basic.poml")
 	doc, err := ParseFile(fixture)
@@ -19,16 +20,17 @@ func TestConverterParityFixtures(t *testing.T) {
 		t.Fatalf("validate fixture: %v", err)
 	}
 
+	legacy := ConvertOptions{LegacyToolDefinitions: true}
 	tests := []struct {
 		name     string
 		format   Format
 		expected string
 		opts     ConvertOptions
 	}{
-		{"message_dict", FormatMessageDict, filepath.Join("testdata", "examples", "parity_basic.message_dict.json"), ConvertOptions{}},
-		{"dict", FormatDict, filepath.Join("testdata", "examples", "parity_basic.dict.json"), ConvertOptions{}},
-		{"openai_chat", FormatOpenAIChat, filepath.Join("testdata", "examples", "parity_basic.openai_chat.json"), ConvertOptions{}},
-		{"langchain", FormatLangChain, filepath.Join("testdata", "examples", "parity_basic.langchain.json"), ConvertOptions{}},
+		{"message_dict", FormatMessageDict, filepath.Join("testdata", "examples", "parity_basic.message_dict.json"), legacy},
+		{"dict", FormatDict, filepath.Join("testdata", "examples", "parity_basic.dict.json"), legacy},
+		{"openai_chat", FormatOpenAIChat, filepath.Join("testdata", "examples", "parity_basic.openai_chat.json"), legacy},
+		{"langchain", FormatLangChain, filepath.Join("testdata", "examples", "parity_basic.langchain.json"), legacy},
 	}
 
 	for _, tc := range tests {
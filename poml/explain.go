@@ -0,0 +1,205 @@
+package poml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConvertAction says what a converter does with one element.
+type ConvertAction string
+
+const (
+	ActionEmitted    ConvertAction = "emitted"
+	ActionDowngraded ConvertAction = "downgraded" // sent, but losing information (a custom role, an error flag)
+	ActionDropped    ConvertAction = "dropped"    // silently left out of the output
+	ActionError      ConvertAction = "error"      // Convert fails on the element
+)
+
+// ConvertNote is the fate of one element under a target format.
+type ConvertNote struct {
+	Element Element
+	Action  ConvertAction
+	Reason  string // empty for plain emitted elements
+}
+
+// ConvertExplanation lists, in document order, what converting to Format does with each
+// element. It is built from the converters' known capabilities without converting, so assets are
+// not read and a document that fails to load still reports as emitted.
+type ConvertExplanation struct {
+	Format Format
+	Notes  []ConvertNote
+}
+
+// Lossy returns the notes whose element is not emitted as written: downgraded, dropped, or
+// failing.
+func (e ConvertExplanation) Lossy() []ConvertNote {
+	var out []ConvertNote
+	for _, n := range e.Notes {
+		if n.Action != ActionEmitted {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// String renders one line per element, e.g. "video[0]: dropped (text output has no media)".
+func (e ConvertExplanation) String() string {
+	var b strings.Builder
+	for _, n := range e.Notes {
+		fmt.Fprintf(&b, "%s: %s", noteLabel(n.Element), n.Action)
+		if n.Reason != "" {
+			fmt.Fprintf(&b, " (%s)", n.Reason)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func noteLabel(el Element) string {
+	switch {
+	case el.Type == ElementUnknown:
+		return "<" + el.Name + ">"
+	case el.Index < 0:
+		return string(el.Type)
+	default:
+		return fmt.Sprintf("%s[%d]", el.Type, el.Index)
+	}
+}
+
+// ExplainConvert reports which elements of doc Convert emits, drops, or downgrades for format
+// with default options. <meta> is not listed; no format sends it as content.
+func ExplainConvert(doc Document, format Format) (ConvertExplanation, error) {
+	return ExplainConvertWithOptions(doc, format, ConvertOptions{})
+}
+
+// ExplainConvertWithOptions is ExplainConvert honoring the options that change what is sent:
+// SystemPrompt.Enabled and RoleMap.
+func ExplainConvertWithOptions(doc Document, format Format, opts ConvertOptions) (ConvertExplanation, error) {
+	switch format {
+	case FormatMessageDict, FormatDict, FormatPydantic, FormatOpenAIChat, FormatLangChain, FormatBedrockConverse,
+		FormatOllama, FormatCohere, FormatText, FormatHFChat, FormatMistral:
+	default:
+		return ConvertExplanation{}, fmt.Errorf("explain convert: %s: %w", format, ErrNotImplemented)
+	}
+	out := ConvertExplanation{Format: format}
+	for _, el := range doc.resolveOrder() {
+		if el.Type == ElementMeta {
+			continue
+		}
+		action, reason := explainElement(doc, el, format, opts)
+		out.Notes = append(out.Notes, ConvertNote{Element: el, Action: action, Reason: reason})
+	}
+	return out, nil
+}
+
+// explainElement mirrors the element switches of the converters; keep the two in step.
+func explainElement(doc Document, el Element, format Format, opts ConvertOptions) (ConvertAction, string) {
+	switch el.Type {
+	case ElementRole, ElementTask, ElementInput, ElementStyle, ElementOutputFormat:
+		switch format {
+		case FormatText:
+			return ActionEmitted, ""
+		case FormatOpenAIChat, FormatMistral, FormatLangChain, FormatHFChat, FormatOllama:
+			if opts.SystemPrompt.Enabled {
+				return ActionEmitted, "composed into the system message"
+			}
+			return ActionDropped, "sent only with ConvertOptions.SystemPrompt enabled"
+		}
+		return ActionDropped, fmt.Sprintf("%s has no system prompt sections", format)
+	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+		role := doc.Messages[el.Index].Role
+		if to, ok := opts.RoleMap[role]; ok {
+			role = to
+		}
+		switch role {
+		case "", "human", "user", "assistant", "ai", "system":
+			return ActionEmitted, ""
+		}
+		var sent string
+		switch format {
+		case FormatText:
+			return ActionEmitted, ""
+		case FormatLangChain:
+			sent = roleToLangChain(role)
+		case FormatMessageDict, FormatDict, FormatPydantic:
+			sent = roleToSpeaker(role)
+		case FormatCohere:
+			sent = "USER"
+		default:
+			sent = roleToOpenAI(role)
+		}
+		return ActionDowngraded, fmt.Sprintf("role %q sent as %q; map it with ConvertOptions.RoleMap", role, sent)
+	case ElementHint, ElementExample, ElementContentPart, ElementDocument, ElementObject:
+		return ActionEmitted, ""
+	case ElementImage, ElementAudio, ElementVideo:
+		switch format {
+		case FormatText:
+			return ActionDropped, "text output has no media"
+		case FormatCohere:
+			return ActionError, fmt.Sprintf("%s content is not supported by Cohere chat", el.Type)
+		case FormatOllama:
+			if el.Type != ElementImage {
+				return ActionError, fmt.Sprintf("%s content is not supported by /api/chat", el.Type)
+			}
+		case FormatBedrockConverse:
+			if el.Type == ElementAudio {
+				return ActionError, "audio content is not supported by Converse"
+			}
+		}
+		return ActionEmitted, ""
+	case ElementToolRequest:
+		switch format {
+		case FormatText:
+			return ActionDropped, "text output has no tool calls"
+		case FormatMessageDict, FormatDict, FormatPydantic:
+			return ActionDropped, fmt.Sprintf("%s messages have no tool calls", format)
+		}
+		return ActionEmitted, ""
+	case ElementToolResponse, ElementToolResult, ElementToolError:
+		if format == FormatText {
+			return ActionDropped, "text output has no tool messages"
+		}
+		if el.Type == ElementToolError {
+			switch format {
+			case FormatHFChat, FormatOllama:
+				return ActionDowngraded, `sent as a tool message prefixed with "error: "`
+			case FormatMistral:
+				return ActionDowngraded, "sent as a plain tool message; Mistral has no error flag"
+			}
+		}
+		return ActionEmitted, ""
+	case ElementToolDefinition:
+		switch format {
+		case FormatText, FormatMessageDict:
+			return ActionDropped, fmt.Sprintf("%s output has no tools", format)
+		case FormatHFChat:
+			return ActionDropped, "hf_chat is a bare message list; RenderChatTemplate passes tools to the template"
+		}
+		return ActionEmitted, ""
+	case ElementOutputSchema:
+		switch format {
+		case FormatMessageDict, FormatHFChat:
+			return ActionDropped, fmt.Sprintf("%s is a bare message list", format)
+		case FormatBedrockConverse:
+			return ActionDropped, "Converse has no response format"
+		}
+		return ActionEmitted, ""
+	case ElementRuntime:
+		switch format {
+		case FormatText, FormatMessageDict, FormatHFChat:
+			return ActionDropped, fmt.Sprintf("%s output has no request parameters", format)
+		}
+		return ActionEmitted, ""
+	case ElementTrace:
+		switch format {
+		case FormatOpenAIChat, FormatLangChain, FormatDict, FormatPydantic, FormatBedrockConverse:
+			return ActionEmitted, ""
+		}
+		return ActionDropped, fmt.Sprintf("%s has no metadata field", format)
+	case ElementDiagram:
+		return ActionDropped, "diagrams are not part of converted prompts"
+	case ElementUnknown:
+		return ActionDropped, fmt.Sprintf("unknown element <%s>", el.Name)
+	}
+	return ActionDropped, fmt.Sprintf("%s elements are not converted", el.Type)
+}
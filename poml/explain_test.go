@@ -0,0 +1,80 @@
+package poml
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplainConvertReportsLoss(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <role>Reviewer</role>
+  <msg role="critic">Too long.</msg>
+  <human-msg>Shorten it.</human-msg>
+  <video src="data:video/mp4;base64,QQ==" syntax="video/mp4"/>
+  <tool-error id="c1" name="lookup">timeout</tool-error>
+  <x-note>internal</x-note>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	exp, err := ExplainConvert(doc, FormatText)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	want := "role: emitted\n" +
+		"msg[0]: emitted\n" +
+		"human_msg[1]: emitted\n" +
+		"video[0]: dropped (text output has no media)\n" +
+		"tool_error[0]: dropped (text output has no tool messages)\n" +
+		"<x-note>: dropped (unknown element <x-note>)\n"
+	if got := exp.String(); got != want {
+		t.Fatalf("text explanation:\n%s\nwant:\n%s", got, want)
+	}
+
+	exp, _ = ExplainConvertWithOptions(doc, FormatOpenAIChat, ConvertOptions{SystemPrompt: SystemPromptOptions{Enabled: true}})
+	lossy := exp.Lossy()
+	if len(lossy) != 2 || lossy[0].Action != ActionDowngraded || !strings.Contains(lossy[0].Reason, `role "critic" sent as "user"`) || lossy[1].Element.Type != ElementUnknown {
+		t.Fatalf("openai_chat lossy notes: %+v", lossy)
+	}
+	exp, _ = ExplainConvertWithOptions(doc, FormatOpenAIChat, ConvertOptions{RoleMap: map[string]string{"critic": "assistant"}})
+	if n := exp.Notes[0]; n.Action != ActionDropped || !strings.Contains(n.Reason, "SystemPrompt") {
+		t.Fatalf("role without a system prompt: %+v", n)
+	}
+	if n := exp.Notes[1]; n.Action != ActionEmitted {
+		t.Fatalf("mapped role should be emitted: %+v", n)
+	}
+
+	if _, err := ExplainConvert(doc, Format("yaml")); !errors.Is(err, ErrNotImplemented) {
+		t.Fatalf("unknown format should be unsupported, got %v", err)
+	}
+}
+
+// TestExplainConvertMatchesConvertErrors keeps the capability table honest: a format reports an
+// error note exactly when Convert fails on the fixture.
+func TestExplainConvertMatchesConvertErrors(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "examples", "207_multimedia.poml"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	doc, err := ParseString(string(data))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for _, format := range []Format{FormatMessageDict, FormatDict, FormatPydantic, FormatOpenAIChat, FormatLangChain,
+		FormatBedrockConverse, FormatOllama, FormatCohere, FormatText, FormatHFChat, FormatMistral} {
+		exp, err := ExplainConvert(doc, format)
+		if err != nil {
+			t.Fatalf("%s: explain: %v", format, err)
+		}
+		predicted := false
+		for _, n := range exp.Notes {
+			predicted = predicted || n.Action == ActionError
+		}
+		if _, err := Convert(doc, format, ConvertOptions{}); (err != nil) != predicted {
+			t.Fatalf("%s: convert error %v, explanation:\n%s", format, err, exp)
+		}
+	}
+}
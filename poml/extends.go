@@ -0,0 +1,147 @@
+package poml
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// maxExtendsDepth bounds how many parents a chain may have.
+const maxExtendsDepth = 32
+
+// ExtendsOptions controls LoadExtends.
+type ExtendsOptions struct {
+	// FS, when set, is where the document and its parents are read from, with slash-separated
+	// names as for ParseFS. Otherwise they are read from disk.
+	FS fs.FS
+	// Parse is applied to every file in the chain; nil parses like ParseFile. Validate, if set,
+	// runs once on the merged result, since a child alone usually lacks required sections.
+	Parse *ParseOptions
+	// Policy merges each child over its parent; nil uses DefaultMergePolicy.
+	Policy *MergePolicy
+}
+
+// LoadExtends parses the POML file name and, when its root reads <poml extends="base.poml">,
+// merges it over its parent, resolved relative to the file's directory and itself loaded the
+// same way. With the default policy the child's role, meta, and output schema replace the
+// parent's, inputs and tool definitions with a matching name are overridden, and tasks and
+// everything else are appended; runtime settings override key by key because later <runtime>
+// attributes win. Relative sources in a parent (<img src>, <document src>, ...) are rewritten to
+// stay correct from the child's directory. The result has no Extends. A cycle, a chain deeper
+// than 32, or a parent that cannot be read fails with CodeExtends.
+func LoadExtends(name string, opts ExtendsOptions) (Document, error) {
+	parseOpts := defaultParseOptions
+	if opts.Parse != nil {
+		parseOpts = *opts.Parse
+	}
+	validate := parseOpts.Validate
+	parseOpts.Validate = false
+	doc, err := loadExtends(name, opts, parseOpts, nil)
+	if err != nil {
+		return Document{}, err
+	}
+	if validate {
+		if err := doc.Validate(); err != nil {
+			return Document{}, err
+		}
+	}
+	return doc, nil
+}
+
+func loadExtends(name string, opts ExtendsOptions, parseOpts ParseOptions, chain []string) (Document, error) {
+	name = cleanExtendsPath(opts.FS, name)
+	if i := slices.Index(chain, name); i >= 0 {
+		cycle := strings.Join(chain[i:], " -> ") + " -> " + name
+		return Document{}, &POMLError{Type: ErrDecode, Code: CodeExtends, Message: "extends cycle: " + cycle}
+	}
+	if len(chain) > maxExtendsDepth {
+		return Document{}, &POMLError{Type: ErrDecode, Code: CodeExtends, Message: fmt.Sprintf("extends chain deeper than %d at %s", maxExtendsDepth, name)}
+	}
+	child, err := parseExtendsFile(name, opts.FS, parseOpts)
+	if err != nil {
+		if len(chain) == 0 {
+			return Document{}, err
+		}
+		return Document{}, &POMLError{Type: ErrDecode, Code: CodeExtends, Message: fmt.Sprintf("%s: extended by %s", name, chain[len(chain)-1]), Err: err}
+	}
+	if child.Extends == "" {
+		return child, nil
+	}
+	dir := extendsDir(opts.FS, name)
+	parentName := joinExtendsPath(opts.FS, dir, child.Extends)
+	parent, err := loadExtends(parentName, opts, parseOpts, append(chain, name))
+	if err != nil {
+		return Document{}, err
+	}
+	if parentDir := extendsDir(opts.FS, parentName); parentDir != dir {
+		err := parent.RewriteSources(func(_, src string) (string, error) {
+			return rebaseExtendsSource(src, parentDir, dir), nil
+		})
+		if err != nil {
+			return Document{}, err
+		}
+	}
+	policy := DefaultMergePolicy()
+	if opts.Policy != nil {
+		policy = *opts.Policy
+	}
+	child.Extends = ""
+	return Merge(parent, child, policy)
+}
+
+func parseExtendsFile(name string, fsys fs.FS, opts ParseOptions) (Document, error) {
+	if fsys != nil {
+		return ParseFS(fsys, name, opts)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return Document{}, err
+	}
+	defer f.Close()
+	return parseWithOptions(f, opts)
+}
+
+func cleanExtendsPath(fsys fs.FS, name string) string {
+	if fsys != nil {
+		return path.Clean(name)
+	}
+	return filepath.Clean(name)
+}
+
+func extendsDir(fsys fs.FS, name string) string {
+	if fsys != nil {
+		return path.Dir(name)
+	}
+	return filepath.Dir(name)
+}
+
+func joinExtendsPath(fsys fs.FS, dir, ref string) string {
+	if fsys != nil {
+		if strings.HasPrefix(ref, "/") {
+			return path.Clean(ref[1:])
+		}
+		return path.Join(dir, ref)
+	}
+	if filepath.IsAbs(ref) {
+		return ref
+	}
+	return filepath.Join(dir, filepath.FromSlash(ref))
+}
+
+// rebaseExtendsSource rewrites a parent-relative src so it resolves the same from childDir.
+// URLs, data: URIs, absolute paths, and template expressions are returned unchanged.
+func rebaseExtendsSource(src, parentDir, childDir string) string {
+	if strings.Contains(src, ":") || strings.Contains(src, "{{") || strings.HasPrefix(src, "/") || filepath.IsAbs(src) {
+		return src
+	}
+	target := filepath.Join(filepath.FromSlash(parentDir), filepath.FromSlash(src))
+	rel, err := filepath.Rel(filepath.FromSlash(childDir), target)
+	if err != nil {
+		return src
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,64 @@
+package poml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadExtends(t *testing.T) {
+	fsys := fstest.MapFS{
+		"shared/base.poml": {Data: []byte(`<poml>
+  <meta><id>base</id><version>1</version><owner>team</owner></meta>
+  <role>Generic assistant.</role>
+  <task>Answer briefly.</task>
+  <runtime model="m1" temperature="0.2"/>
+  <img src="logo.png" alt="logo"/>
+</poml>`)},
+		"prompts/child.poml": {Data: []byte(`<poml extends="../shared/base.poml">
+  <role>Billing assistant.</role>
+  <task>Cite the invoice number.</task>
+  <runtime temperature="0.7"/>
+</poml>`)},
+		"loop/a.poml": {Data: []byte(`<poml extends="b.poml"><task>a</task></poml>`)},
+		"loop/b.poml": {Data: []byte(`<poml extends="a.poml"><task>b</task></poml>`)},
+		"orphan.poml": {Data: []byte(`<poml extends="missing.poml"><task>x</task></poml>`)},
+	}
+	doc, err := LoadExtends("prompts/child.poml", ExtendsOptions{FS: fsys, Parse: &ParseOptions{Validate: true}})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if doc.Extends != "" || strings.TrimSpace(doc.Role.Body) != "Billing assistant." || doc.Meta.ID != "base" {
+		t.Fatalf("child should replace the role and inherit meta: %+v", doc)
+	}
+	if len(doc.Tasks) != 2 || strings.TrimSpace(doc.Tasks[1].Body) != "Cite the invoice number." {
+		t.Fatalf("tasks should append: %+v", doc.Tasks)
+	}
+	if rt := collectRuntime(doc, ""); rt["temperature"] != 0.7 || rt["model"] != "m1" {
+		t.Fatalf("runtime should override key by key: %v", rt)
+	}
+	if got := doc.Images[0].Src; got != "../shared/logo.png" {
+		t.Fatalf("parent src should be rebased, got %q", got)
+	}
+
+	if _, err := LoadExtends("loop/a.poml", ExtendsOptions{FS: fsys}); !errors.Is(err, CodeExtends) || !strings.Contains(err.Error(), "loop/a.poml -> loop/b.poml -> loop/a.poml") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	if _, err := LoadExtends("orphan.poml", ExtendsOptions{FS: fsys}); !errors.Is(err, CodeExtends) || !strings.Contains(err.Error(), "extended by orphan.poml") {
+		t.Fatalf("expected a missing parent error, got %v", err)
+	}
+}
+
+func TestExtendsAttributeRoundTrip(t *testing.T) {
+	doc, err := ParseString(`<poml extends="base.poml"><task>t</task></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if doc.Extends != "base.poml" {
+		t.Fatalf("extends = %q", doc.Extends)
+	}
+	if out := encodeString(t, doc, EncodeOptions{}); !strings.HasPrefix(out, `<poml extends="base.poml">`) {
+		t.Fatalf("encode dropped extends: %s", out)
+	}
+}
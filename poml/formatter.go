@@ -0,0 +1,306 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// FormatStyle controls the canonical layout produced by FormatDocument.
+type FormatStyle struct {
+	Indent        string // indentation per nesting level; default "  "
+	SortAttrs     bool   // sort attributes by name (namespace declarations first)
+	PreferCDATA   bool   // wrap text containing '<' or '&' in CDATA instead of escaping it
+	IncludeHeader bool   // emit the XML declaration before <poml>
+}
+
+// FormatDocument renders doc in a deterministic, canonical layout, much like gofmt does for Go:
+// one element per line, consistent indentation, self-closing empty elements, uniform text
+// escaping, and a trailing newline. Preserved whitespace is discarded while comments, including
+// those before <poml>, are kept, so the output is stable regardless of how the source was laid
+// out and formatting the parsed result again yields identical bytes.
+//
+// Element bodies that contain text are trimmed but otherwise left alone: CDATA sections stay
+// CDATA, single-line text stays inline, and multi-line text moves to its own lines with interior
+// lines untouched. The function is not named Format because that identifier is the conversion
+// Format type.
+func FormatDocument(doc Document, style FormatStyle) ([]byte, error) {
+	var encoded bytes.Buffer
+	if err := doc.EncodeWithOptions(&encoded, EncodeOptions{PreserveOrder: true, PreserveWS: true, Compact: true, PreservePrefix: true}); err != nil {
+		return nil, err
+	}
+	nodes, err := parseFormatNodes(encoded.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	p := formatPrinter{style: style}
+	if p.style.Indent == "" {
+		p.style.Indent = "  "
+	}
+	if style.IncludeHeader {
+		p.buf.WriteString(strings.TrimSpace(xml.Header))
+		p.buf.WriteByte('\n')
+	}
+	for _, n := range nodes {
+		if n.kind == fmtText || (n.kind == fmtProcInst && n.name.Local == "xml") {
+			continue
+		}
+		p.block(n, 0)
+	}
+	return p.buf.Bytes(), nil
+}
+
+type fmtKind int
+
+const (
+	fmtElement fmtKind = iota
+	fmtText
+	fmtComment
+	fmtProcInst
+	fmtDirective
+)
+
+type fmtNode struct {
+	kind     fmtKind
+	name     xml.Name
+	attrs    []xml.Attr
+	text     string
+	cdata    bool // text came from a CDATA section
+	children []*fmtNode
+}
+
+// parseFormatNodes builds a lightweight tree using raw tokens so namespace prefixes survive.
+func parseFormatNodes(src []byte) ([]*fmtNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(src))
+	dec.Strict = false
+	var roots []*fmtNode
+	var stack []*fmtNode
+	add := func(n *fmtNode) {
+		if len(stack) == 0 {
+			roots = append(roots, n)
+			return
+		}
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, n)
+	}
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, wrapXMLError(err, "format poml")
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &fmtNode{kind: fmtElement, name: t.Name, attrs: append([]xml.Attr(nil), t.Attr...)}
+			add(n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "unexpected closing tag " + t.Name.Local}
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			add(&fmtNode{kind: fmtText, text: string(t), cdata: bytes.HasPrefix(src[offset:], []byte("<![CDATA["))})
+		case xml.Comment:
+			add(&fmtNode{kind: fmtComment, text: string(t)})
+		case xml.ProcInst:
+			add(&fmtNode{kind: fmtProcInst, name: xml.Name{Local: t.Target}, text: string(t.Inst)})
+		case xml.Directive:
+			add(&fmtNode{kind: fmtDirective, text: string(t)})
+		}
+	}
+	if len(stack) != 0 {
+		return nil, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "unclosed element " + stack[len(stack)-1].name.Local}
+	}
+	return roots, nil
+}
+
+type formatPrinter struct {
+	buf   bytes.Buffer
+	style FormatStyle
+}
+
+func (p *formatPrinter) indent(depth int) {
+	for i := 0; i < depth; i++ {
+		p.buf.WriteString(p.style.Indent)
+	}
+}
+
+// block writes n on its own line(s) at depth.
+func (p *formatPrinter) block(n *fmtNode, depth int) {
+	p.indent(depth)
+	switch n.kind {
+	case fmtComment:
+		p.buf.WriteString("<!--" + n.text + "-->\n")
+		return
+	case fmtProcInst:
+		p.buf.WriteString("<?" + n.name.Local + " " + strings.TrimSpace(n.text) + "?>\n")
+		return
+	case fmtDirective:
+		p.buf.WriteString("<!" + n.text + ">\n")
+		return
+	}
+	children := trimFormatChildren(n.children)
+	if len(children) == 0 {
+		p.startTag(n, true)
+		p.buf.WriteByte('\n')
+		return
+	}
+	p.startTag(n, false)
+	switch {
+	case !hasFormatText(children):
+		p.buf.WriteByte('\n')
+		for _, c := range children {
+			p.block(c, depth+1)
+		}
+		p.indent(depth)
+	case fitsOnLine(children):
+		for _, c := range children {
+			p.inline(c)
+		}
+	default:
+		p.buf.WriteByte('\n')
+		p.indent(depth + 1)
+		for _, c := range children {
+			p.inline(c)
+		}
+		p.buf.WriteByte('\n')
+		p.indent(depth)
+	}
+	p.buf.WriteString("</" + formatName(n.name) + ">\n")
+}
+
+// inline writes n verbatim apart from escaping and attribute order; used inside text content.
+func (p *formatPrinter) inline(n *fmtNode) {
+	switch n.kind {
+	case fmtText:
+		p.text(n.text, n.cdata)
+	case fmtComment:
+		p.buf.WriteString("<!--" + n.text + "-->")
+	case fmtProcInst:
+		p.buf.WriteString("<?" + n.name.Local + " " + strings.TrimSpace(n.text) + "?>")
+	case fmtDirective:
+		p.buf.WriteString("<!" + n.text + ">")
+	case fmtElement:
+		if len(n.children) == 0 {
+			p.startTag(n, true)
+			return
+		}
+		p.startTag(n, false)
+		for _, c := range n.children {
+			p.inline(c)
+		}
+		p.buf.WriteString("</" + formatName(n.name) + ">")
+	}
+}
+
+func (p *formatPrinter) startTag(n *fmtNode, selfClose bool) {
+	p.buf.WriteString("<" + formatName(n.name))
+	attrs := n.attrs
+	if p.style.SortAttrs {
+		attrs = append([]xml.Attr(nil), attrs...)
+		sort.SliceStable(attrs, func(i, j int) bool {
+			ni, nj := isNamespaceAttr(attrs[i].Name), isNamespaceAttr(attrs[j].Name)
+			if ni != nj {
+				return ni
+			}
+			return formatName(attrs[i].Name) < formatName(attrs[j].Name)
+		})
+	}
+	for _, a := range attrs {
+		p.buf.WriteString(" " + formatName(a.Name) + `="`)
+		p.buf.WriteString(escapeFormatAttr(a.Value))
+		p.buf.WriteByte('"')
+	}
+	if selfClose {
+		p.buf.WriteString("/>")
+		return
+	}
+	p.buf.WriteByte('>')
+}
+
+// text writes character data, keeping CDATA sections as CDATA.
+func (p *formatPrinter) text(s string, cdata bool) {
+	if cdata || (p.style.PreferCDATA && strings.ContainsAny(s, "<&") && !strings.Contains(s, "]]>")) {
+		p.buf.WriteString("<![CDATA[" + s + "]]>")
+		return
+	}
+	p.buf.WriteString(escapeBodyText(s))
+}
+
+// trimFormatChildren drops whitespace-only text in element-only content and trims the outer
+// edges of text content so surrounding layout does not leak into the canonical form.
+func trimFormatChildren(children []*fmtNode) []*fmtNode {
+	if !hasFormatText(children) {
+		out := make([]*fmtNode, 0, len(children))
+		for _, c := range children {
+			if c.kind != fmtText {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	out := append([]*fmtNode(nil), children...)
+	for len(out) > 0 && out[0].kind == fmtText && strings.TrimSpace(out[0].text) == "" {
+		out = out[1:]
+	}
+	for len(out) > 0 && out[len(out)-1].kind == fmtText && strings.TrimSpace(out[len(out)-1].text) == "" {
+		out = out[:len(out)-1]
+	}
+	if len(out) > 0 && out[0].kind == fmtText {
+		out[0] = &fmtNode{kind: fmtText, text: strings.TrimLeft(out[0].text, " \t\r\n"), cdata: out[0].cdata}
+	}
+	if last := len(out) - 1; last >= 0 && out[last].kind == fmtText {
+		out[last] = &fmtNode{kind: fmtText, text: strings.TrimRight(out[last].text, " \t\r\n"), cdata: out[last].cdata}
+	}
+	return out
+}
+
+func hasFormatText(children []*fmtNode) bool {
+	for _, c := range children {
+		if c.kind == fmtText && strings.TrimSpace(c.text) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// fitsOnLine reports whether text content fits on the tag's line (no newlines).
+func fitsOnLine(children []*fmtNode) bool {
+	for _, c := range children {
+		if strings.Contains(c.text, "\n") || (c.kind == fmtElement && !fitsOnLine(c.children)) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+func isNamespaceAttr(n xml.Name) bool {
+	return n.Space == "xmlns" || (n.Space == "" && n.Local == "xmlns")
+}
+
+var formatAttrEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	`"`, "&quot;",
+	"\n", "&#xA;",
+	"\r", "&#xD;",
+	"\t", "&#x9;",
+)
+
+func escapeFormatAttr(s string) string {
+	return formatAttrEscaper.Replace(s)
+}
@@ -0,0 +1,81 @@
+package poml
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatDocumentIsIdempotentOnFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "examples", "*.poml"))
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	diagrams, _ := filepath.Glob(filepath.Join("testdata", "diagrams", "*.poml"))
+	files = append(files, diagrams...)
+	if len(files) == 0 {
+		t.Fatalf("no fixtures found")
+	}
+	for _, path := range files {
+		doc, err := ParseFile(path)
+		if err != nil {
+			t.Fatalf("parse %s: %v", path, err)
+		}
+		first, err := FormatDocument(doc, FormatStyle{SortAttrs: true})
+		if err != nil {
+			t.Fatalf("format %s: %v", path, err)
+		}
+		again, err := ParseString(string(first))
+		if err != nil {
+			t.Fatalf("reparse %s: %v\n%s", path, err, first)
+		}
+		second, err := FormatDocument(again, FormatStyle{SortAttrs: true})
+		if err != nil {
+			t.Fatalf("reformat %s: %v", path, err)
+		}
+		if !bytes.Equal(first, second) {
+			t.Fatalf("%s: formatting not idempotent.\nfirst:\n%s\nsecond:\n%s", path, first, second)
+		}
+	}
+}
+
+func TestFormatDocumentCanonicalLayout(t *testing.T) {
+	src := `<?xml version="1.0"?><!-- header --><poml>   <role>
+   r  </role><!-- keep -->
+
+<task>line one
+    line two</task><input required="false" name="q">a &amp; b</input>
+<img src="a.png"   alt="x"></img><hint><![CDATA[use <b> & co]]></hint></poml>`
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	got, err := FormatDocument(doc, FormatStyle{SortAttrs: true, IncludeHeader: true})
+	if err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<!-- header -->
+<poml>
+  <role>r</role>
+  <!-- keep -->
+  <task>
+    line one
+    line two
+  </task>
+  <input name="q" required="false">a &amp; b</input>
+  <img alt="x" src="a.png" syntax=""/>
+  <hint><![CDATA[use <b> & co]]></hint>
+</poml>
+`
+	if string(got) != want {
+		t.Fatalf("unexpected layout.\n got:\n%s\nwant:\n%s", got, want)
+	}
+	cdata, err := FormatDocument(doc, FormatStyle{Indent: "\t", PreferCDATA: true})
+	if err != nil {
+		t.Fatalf("format cdata: %v", err)
+	}
+	if !bytes.Contains(cdata, []byte("\t<hint><![CDATA[use <b> & co]]></hint>\n")) || !bytes.Contains(cdata, []byte(`<img src="a.png" alt="x"`)) {
+		t.Fatalf("expected CDATA text and source attribute order:\n%s", cdata)
+	}
+}
@@ -0,0 +1,52 @@
+package poml
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseFSAndConvertFromFS(t *testing.T) {
+	png, _ := base64.StdEncoding.DecodeString(pngData)
+	fsys := fstest.MapFS{
+		"prompts/p.poml":       {Data: []byte(`<poml><task>t</task><document src="notes.txt" /><img src="assets/a.png" alt="a" /></poml>`)},
+		"prompts/notes.txt":    {Data: []byte("embedded notes")},
+		"prompts/assets/a.png": {Data: png},
+	}
+	doc, err := ParseFS(fsys, "prompts/p.poml", ParseOptions{ResolveDocuments: true})
+	if err != nil {
+		t.Fatalf("parse fs: %v", err)
+	}
+	if doc.Documents[0].Content != "embedded notes" {
+		t.Fatalf("document not resolved from fs: %+v", doc.Documents[0])
+	}
+	if _, err := Convert(doc, FormatOpenAIChat, ConvertOptions{BaseDir: t.TempDir()}); err == nil {
+		t.Fatalf("expected host filesystem lookup to miss the embedded image")
+	}
+	outAny, err := Convert(doc, FormatOpenAIChat, ConvertOptions{FS: fsys, BaseDir: "prompts"})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	msgs := outAny.(map[string]any)["messages"].([]map[string]any)
+	url := msgs[len(msgs)-1]["content"].([]any)[1].(map[string]any)["image_url"].(map[string]any)["url"].(string)
+	if url != "data:image/png;base64,"+pngData {
+		t.Fatalf("image not read from fs: %s", url)
+	}
+
+	unresolved, err := ParseFS(fsys, "prompts/p.poml", ParseOptions{})
+	if err != nil {
+		t.Fatalf("parse fs: %v", err)
+	}
+	outAny, err = Convert(unresolved, FormatMessageDict, ConvertOptions{FS: fsys, BaseDir: "prompts"})
+	if err != nil {
+		t.Fatalf("convert unresolved: %v", err)
+	}
+	if got := outAny.([]messageDict)[0].Content; got != "embedded notes" {
+		t.Fatalf("document not resolved through ConvertOptions.FS: %v", got)
+	}
+	bad := `<poml><img src="../../etc/passwd" /></poml>`
+	if _, err := ConvertString(bad, FormatOpenAIChat, ConvertOptions{FS: fsys}); err == nil || !strings.Contains(err.Error(), "escapes") {
+		t.Fatalf("expected escape rejection, got %v", err)
+	}
+}
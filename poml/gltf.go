@@ -0,0 +1,428 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// glTF enum values used by the renderer.
+const (
+	gltfFloat         = 5126
+	gltfUnsignedShort = 5123
+	gltfArrayBuffer   = 34962
+	gltfElementBuffer = 34963
+	gltfModeLines     = 1
+	gltfModeTriangles = 4
+)
+
+// gltfDefaultColor is the base color of nodes and edges whose style sets none.
+const gltfDefaultColor = "#9e9e9e"
+
+// GLTFRenderer emits a Scene as a glTF 2.0 asset (.gltf JSON with an embedded base64 buffer)
+// for three.js and other 3D viewers. Each node becomes a glTF node translated to its X/Y/Z
+// position and scaled by its style size, drawn as a unit box, or a sphere when its style
+// shape is "circle", "sphere", or "ellipse". Style colors become PBR materials shared between
+// nodes of the same color; edges are line primitives grouped by stroke color. Coordinates are
+// used as-is, so scene Y is glTF's up axis. Labels, owners, groups, and tags are kept in node
+// extras (three.js userData). A camera is added when the scene camera sets a distance.
+type GLTFRenderer struct {
+	// NodeSize is the edge length of a node with size 1; zero means 0.5.
+	NodeSize float64
+}
+
+type gltfDoc struct {
+	Asset       gltfAssetInfo    `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes,omitempty"`
+	Materials   []gltfMaterial   `json:"materials,omitempty"`
+	Cameras     []gltfCamera     `json:"cameras,omitempty"`
+	Accessors   []gltfAccessor   `json:"accessors,omitempty"`
+	BufferViews []gltfBufferView `json:"bufferViews,omitempty"`
+	Buffers     []gltfBuffer     `json:"buffers,omitempty"`
+}
+
+type gltfAssetInfo struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator,omitempty"`
+}
+
+type gltfScene struct {
+	Name  string `json:"name,omitempty"`
+	Nodes []int  `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name        string         `json:"name,omitempty"`
+	Mesh        *int           `json:"mesh,omitempty"`
+	Camera      *int           `json:"camera,omitempty"`
+	Translation []float64      `json:"translation,omitempty"`
+	Rotation    []float64      `json:"rotation,omitempty"`
+	Scale       []float64      `json:"scale,omitempty"`
+	Extras      map[string]any `json:"extras,omitempty"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name,omitempty"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices,omitempty"`
+	Material   int            `json:"material"`
+	Mode       int            `json:"mode"`
+}
+
+type gltfMaterial struct {
+	Name string  `json:"name,omitempty"`
+	PBR  gltfPBR `json:"pbrMetallicRoughness"`
+}
+
+type gltfPBR struct {
+	BaseColorFactor [4]float64 `json:"baseColorFactor"`
+	MetallicFactor  float64    `json:"metallicFactor"`
+	RoughnessFactor float64    `json:"roughnessFactor"`
+}
+
+type gltfCamera struct {
+	Type        string          `json:"type"`
+	Perspective gltfPerspective `json:"perspective"`
+}
+
+type gltfPerspective struct {
+	YFov  float64 `json:"yfov"`
+	ZNear float64 `json:"znear"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float64 `json:"min,omitempty"`
+	Max           []float64 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target,omitempty"`
+}
+
+type gltfBuffer struct {
+	ByteLength int    `json:"byteLength"`
+	URI        string `json:"uri"`
+}
+
+// gltfBuilder accumulates the document and its single binary buffer.
+type gltfBuilder struct {
+	doc       gltfDoc
+	bin       bytes.Buffer
+	materials map[string]int
+	meshes    map[string]int
+	shapes    map[string][3]int // shape -> POSITION, NORMAL, and indices accessors
+}
+
+// Render converts the scene into a glTF 2.0 JSON document. Nodes are sorted by ID for stable output.
+func (r GLTFRenderer) Render(scene Scene) ([]byte, error) {
+	size := r.NodeSize
+	if size <= 0 {
+		size = 0.5
+	}
+	b := &gltfBuilder{
+		materials: map[string]int{},
+		meshes:    map[string]int{},
+		shapes:    map[string][3]int{},
+	}
+	b.doc.Asset = gltfAssetInfo{Version: "2.0", Generator: "poml-go-sdk"}
+	root := gltfScene{Name: scene.ID, Nodes: []int{}}
+
+	nodes := append([]SceneNode(nil), scene.Nodes...)
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	positions := make(map[string][3]float64, len(nodes))
+	for _, n := range nodes {
+		positions[n.ID] = n.Position
+		shape := "box"
+		switch strings.ToLower(n.Style["shape"]) {
+		case "circle", "sphere", "ellipse":
+			shape = "sphere"
+		}
+		mesh := b.mesh(shape, n.Style["color"])
+		scale := size
+		if s, err := n.SizeValue(); err == nil && s > 0 {
+			scale *= s
+		}
+		node := gltfNode{
+			Name:        n.ID,
+			Mesh:        &mesh,
+			Translation: []float64{n.Position[0], n.Position[1], n.Position[2]},
+			Scale:       []float64{scale, scale, scale},
+			Extras:      gltfNodeExtras(n),
+		}
+		root.Nodes = append(root.Nodes, len(b.doc.Nodes))
+		b.doc.Nodes = append(b.doc.Nodes, node)
+	}
+
+	if mesh, ok := b.edgeMesh(scene.Edges, positions); ok {
+		root.Nodes = append(root.Nodes, len(b.doc.Nodes))
+		b.doc.Nodes = append(b.doc.Nodes, gltfNode{Name: "edges", Mesh: &mesh})
+	}
+
+	if cam, ok := gltfCameraNode(scene); ok {
+		root.Nodes = append(root.Nodes, len(b.doc.Nodes))
+		b.doc.Nodes = append(b.doc.Nodes, cam)
+		b.doc.Cameras = append(b.doc.Cameras, gltfCamera{Type: "perspective", Perspective: gltfPerspective{YFov: 0.8, ZNear: 0.01}})
+	}
+
+	b.doc.Scenes = []gltfScene{root}
+	if b.bin.Len() > 0 {
+		b.doc.Buffers = []gltfBuffer{{
+			ByteLength: b.bin.Len(),
+			URI:        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(b.bin.Bytes()),
+		}}
+	}
+	out, err := json.MarshalIndent(b.doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("gltf: %w", err)
+	}
+	return out, nil
+}
+
+// mesh returns the mesh drawing shape in color, adding it on first use.
+func (b *gltfBuilder) mesh(shape, color string) int {
+	mat := b.material(color)
+	key := shape + "|" + strconv.Itoa(mat)
+	if i, ok := b.meshes[key]; ok {
+		return i
+	}
+	geo, ok := b.shapes[shape]
+	if !ok {
+		var pos, norm []float32
+		var idx []uint16
+		if shape == "sphere" {
+			pos, norm, idx = gltfSphere(12, 8)
+		} else {
+			pos, norm, idx = gltfBox()
+		}
+		geo = [3]int{b.vec3Accessor(pos), b.vec3Accessor(norm), b.indexAccessor(idx)}
+		b.shapes[shape] = geo
+	}
+	indices := geo[2]
+	i := len(b.doc.Meshes)
+	b.doc.Meshes = append(b.doc.Meshes, gltfMesh{
+		Name: shape + " " + b.doc.Materials[mat].Name,
+		Primitives: []gltfPrimitive{{
+			Attributes: map[string]int{"POSITION": geo[0], "NORMAL": geo[1]},
+			Indices:    &indices,
+			Material:   mat,
+			Mode:       gltfModeTriangles,
+		}},
+	})
+	b.meshes[key] = i
+	return i
+}
+
+// edgeMesh adds one mesh holding every edge between known nodes as line segments, with a
+// primitive per stroke color.
+func (b *gltfBuilder) edgeMesh(edges []SceneEdge, positions map[string][3]float64) (int, bool) {
+	byColor := map[int][]float32{}
+	for _, e := range edges {
+		from, okFrom := positions[e.From]
+		to, okTo := positions[e.To]
+		if !okFrom || !okTo {
+			continue
+		}
+		mat := b.material(e.Style["stroke"])
+		for _, p := range [][3]float64{from, to} {
+			byColor[mat] = append(byColor[mat], float32(p[0]), float32(p[1]), float32(p[2]))
+		}
+	}
+	if len(byColor) == 0 {
+		return 0, false
+	}
+	mats := make([]int, 0, len(byColor))
+	for mat := range byColor {
+		mats = append(mats, mat)
+	}
+	sort.Ints(mats)
+	mesh := gltfMesh{Name: "edges"}
+	for _, mat := range mats {
+		mesh.Primitives = append(mesh.Primitives, gltfPrimitive{
+			Attributes: map[string]int{"POSITION": b.vec3Accessor(byColor[mat])},
+			Material:   mat,
+			Mode:       gltfModeLines,
+		})
+	}
+	b.doc.Meshes = append(b.doc.Meshes, mesh)
+	return len(b.doc.Meshes) - 1, true
+}
+
+// material returns the material for a "#rgb" or "#rrggbb" color, adding it on first use.
+// Other values get the default color.
+func (b *gltfBuilder) material(color string) int {
+	rgb, ok := parseHexColor(color)
+	if !ok {
+		rgb, _ = parseHexColor(gltfDefaultColor)
+	}
+	name := fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+	if i, ok := b.materials[name]; ok {
+		return i
+	}
+	var factor [4]float64
+	for i, c := range rgb {
+		factor[i] = gltfLinear(c)
+	}
+	factor[3] = 1
+	i := len(b.doc.Materials)
+	b.doc.Materials = append(b.doc.Materials, gltfMaterial{
+		Name: name,
+		PBR:  gltfPBR{BaseColorFactor: factor, MetallicFactor: 0, RoughnessFactor: 0.8},
+	})
+	b.materials[name] = i
+	return i
+}
+
+// vec3Accessor appends float32 triples to the buffer and returns their accessor, with the
+// bounds glTF requires for POSITION.
+func (b *gltfBuilder) vec3Accessor(data []float32) int {
+	lo := []float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	hi := []float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for i, v := range data {
+		lo[i%3] = math.Min(lo[i%3], float64(v))
+		hi[i%3] = math.Max(hi[i%3], float64(v))
+	}
+	view := b.bufferView(data, gltfArrayBuffer)
+	b.doc.Accessors = append(b.doc.Accessors, gltfAccessor{
+		BufferView: view, ComponentType: gltfFloat, Count: len(data) / 3, Type: "VEC3", Min: lo, Max: hi,
+	})
+	return len(b.doc.Accessors) - 1
+}
+
+func (b *gltfBuilder) indexAccessor(data []uint16) int {
+	view := b.bufferView(data, gltfElementBuffer)
+	b.doc.Accessors = append(b.doc.Accessors, gltfAccessor{
+		BufferView: view, ComponentType: gltfUnsignedShort, Count: len(data), Type: "SCALAR",
+	})
+	return len(b.doc.Accessors) - 1
+}
+
+// bufferView writes data little-endian at the next 4-byte boundary of the buffer.
+func (b *gltfBuilder) bufferView(data any, target int) int {
+	for b.bin.Len()%4 != 0 {
+		b.bin.WriteByte(0)
+	}
+	offset := b.bin.Len()
+	_ = binary.Write(&b.bin, binary.LittleEndian, data)
+	b.doc.BufferViews = append(b.doc.BufferViews, gltfBufferView{
+		ByteOffset: offset, ByteLength: b.bin.Len() - offset, Target: target,
+	})
+	return len(b.doc.BufferViews) - 1
+}
+
+// gltfBox returns a unit box centered on the origin with per-face normals.
+func gltfBox() (pos, norm []float32, idx []uint16) {
+	for axis := 0; axis < 3; axis++ {
+		for _, sign := range []float32{1, -1} {
+			u, v := (axis+1)%3, (axis+2)%3
+			base := uint16(len(pos) / 3)
+			for _, c := range [][2]float32{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}} {
+				var p, n [3]float32
+				p[axis], p[u], p[v] = 0.5*sign, 0.5*c[0]*sign, 0.5*c[1]
+				n[axis] = sign
+				pos = append(pos, p[:]...)
+				norm = append(norm, n[:]...)
+			}
+			idx = append(idx, base, base+1, base+2, base, base+2, base+3)
+		}
+	}
+	return pos, norm, idx
+}
+
+// gltfSphere returns a UV sphere of diameter 1 centered on the origin.
+func gltfSphere(segments, rings int) (pos, norm []float32, idx []uint16) {
+	for r := 0; r <= rings; r++ {
+		phi := math.Pi * float64(r) / float64(rings)
+		for s := 0; s <= segments; s++ {
+			theta := 2 * math.Pi * float64(s) / float64(segments)
+			n := [3]float32{
+				float32(math.Sin(phi) * math.Sin(theta)),
+				float32(math.Cos(phi)),
+				float32(math.Sin(phi) * math.Cos(theta)),
+			}
+			norm = append(norm, n[:]...)
+			pos = append(pos, n[0]/2, n[1]/2, n[2]/2)
+		}
+	}
+	row := uint16(segments + 1)
+	for r := uint16(0); r < uint16(rings); r++ {
+		for s := uint16(0); s < uint16(segments); s++ {
+			a, b := r*row+s, (r+1)*row+s
+			idx = append(idx, a, b, a+1, a+1, b, b+1)
+		}
+	}
+	return pos, norm, idx
+}
+
+// gltfLinear converts an 8-bit sRGB channel to the linear factor glTF materials use.
+func gltfLinear(c int) float64 {
+	v := float64(c) / 255
+	if v <= 0.04045 {
+		v /= 12.92
+	} else {
+		v = math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return math.Round(v*1e4) / 1e4
+}
+
+func gltfNodeExtras(n SceneNode) map[string]any {
+	extras := map[string]any{}
+	for k, v := range map[string]string{"label": n.Label, "owner": n.Owner, "group": n.Group, "weight": n.Weight, "pct_complete": n.PctComplete} {
+		if v != "" {
+			extras[k] = v
+		}
+	}
+	if len(n.Tags) > 0 {
+		extras["tags"] = n.Tags
+	}
+	if len(extras) == 0 {
+		return nil
+	}
+	return extras
+}
+
+// gltfCameraNode places a camera at the scene camera's azimuth, elevation (degrees), and
+// distance from the centroid of the nodes, looking at it.
+func gltfCameraNode(scene Scene) (gltfNode, bool) {
+	c, err := scene.ViewCamera()
+	if err != nil || c.Distance <= 0 {
+		return gltfNode{}, false
+	}
+	az, el := c.Azimuth*math.Pi/180, c.Elevation*math.Pi/180
+	// glTF cameras look down -Z: pitch down by the elevation, then turn by the azimuth.
+	qy := [4]float64{0, math.Sin(az / 2), 0, math.Cos(az / 2)}
+	qx := [4]float64{math.Sin(-el / 2), 0, 0, math.Cos(-el / 2)}
+	rot := []float64{
+		qy[3]*qx[0] + qy[0]*qx[3] + qy[1]*qx[2] - qy[2]*qx[1],
+		qy[3]*qx[1] - qy[0]*qx[2] + qy[1]*qx[3] + qy[2]*qx[0],
+		qy[3]*qx[2] + qy[0]*qx[1] - qy[1]*qx[0] + qy[2]*qx[3],
+		qy[3]*qx[3] - qy[0]*qx[0] - qy[1]*qx[1] - qy[2]*qx[2],
+	}
+	cam := 0
+	eye := c.Eye()
+	return gltfNode{
+		Name:        "camera",
+		Camera:      &cam,
+		Translation: eye[:],
+		Rotation:    rot,
+	}, true
+}
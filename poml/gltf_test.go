@@ -0,0 +1,109 @@
+package poml
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGLTFRendererAsset(t *testing.T) {
+	scene := Scene{
+		ID: "d",
+		Nodes: []SceneNode{
+			{ID: "b", Position: [3]float64{2, 0, 1}, Style: map[string]string{"color": "#ff0000", "shape": "circle", "size": "2"}},
+			{ID: "a", Label: "Start", Position: [3]float64{0, 1, 0}, Style: map[string]string{"color": "#f00"}},
+			{ID: "c", Position: [3]float64{0, 0, 3}},
+		},
+		Edges: []SceneEdge{
+			{From: "a", To: "b", Style: map[string]string{"stroke": "#0000ff"}},
+			{From: "a", To: "missing"},
+		},
+		Camera: SceneCamera{Azimuth: "90", Elevation: "0", Distance: "10"},
+	}
+	out, err := (GLTFRenderer{}).Render(scene)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	var doc gltfDoc
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, out)
+	}
+	if doc.Asset.Version != "2.0" || len(doc.Scenes) != 1 || len(doc.Scenes[0].Nodes) != 5 {
+		t.Fatalf("unexpected asset/scenes: %+v %+v", doc.Asset, doc.Scenes)
+	}
+	a, b, c := doc.Nodes[0], doc.Nodes[1], doc.Nodes[2]
+	if a.Name != "a" || a.Extras["label"] != "Start" || a.Translation[1] != 1 || a.Scale[0] != 0.5 {
+		t.Fatalf("unexpected node a: %+v", a)
+	}
+	if b.Scale[0] != 1 || doc.Meshes[*b.Mesh].Primitives[0].Material != doc.Meshes[*a.Mesh].Primitives[0].Material {
+		t.Fatalf("expected b scaled by size and sharing a's red material: %+v %+v", b, doc.Meshes)
+	}
+	if *a.Mesh == *b.Mesh || *a.Mesh == *c.Mesh {
+		t.Fatalf("expected distinct box, sphere, and default-color meshes: %+v", doc.Nodes)
+	}
+	red := doc.Materials[doc.Meshes[*a.Mesh].Primitives[0].Material]
+	if red.Name != "#ff0000" || red.PBR.BaseColorFactor != [4]float64{1, 0, 0, 1} {
+		t.Fatalf("unexpected material: %+v", red)
+	}
+	edges := doc.Meshes[*doc.Nodes[3].Mesh]
+	if len(edges.Primitives) != 1 || edges.Primitives[0].Mode != gltfModeLines || doc.Accessors[edges.Primitives[0].Attributes["POSITION"]].Count != 2 {
+		t.Fatalf("expected one line segment for the resolvable edge: %+v", edges)
+	}
+	cam := doc.Nodes[4]
+	if cam.Camera == nil || len(doc.Cameras) != 1 || math.Abs(cam.Translation[0]-(10+2.0/3)) > 1e-9 {
+		t.Fatalf("unexpected camera node: %+v", cam)
+	}
+
+	// Every accessor fits in its view and every view in the embedded buffer.
+	if len(doc.Buffers) != 1 || !strings.HasPrefix(doc.Buffers[0].URI, "data:application/octet-stream;base64,") {
+		t.Fatalf("expected one embedded buffer: %+v", doc.Buffers)
+	}
+	bin, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(doc.Buffers[0].URI, "data:application/octet-stream;base64,"))
+	if err != nil || len(bin) != doc.Buffers[0].ByteLength {
+		t.Fatalf("buffer length %d != %d (%v)", len(bin), doc.Buffers[0].ByteLength, err)
+	}
+	for i, acc := range doc.Accessors {
+		view := doc.BufferViews[acc.BufferView]
+		width := 2
+		if acc.Type == "VEC3" {
+			width = 12
+		}
+		if view.ByteOffset%4 != 0 || acc.Count*width != view.ByteLength || view.ByteOffset+view.ByteLength > len(bin) {
+			t.Fatalf("accessor %d does not match its view: %+v %+v", i, acc, view)
+		}
+	}
+	// Box faces wind counter-clockwise when seen from outside.
+	prim := doc.Meshes[*a.Mesh].Primitives[0]
+	pos := readVec3(bin, doc, prim.Attributes["POSITION"])
+	norm := readVec3(bin, doc, prim.Attributes["NORMAL"])
+	idxView := doc.BufferViews[doc.Accessors[*prim.Indices].BufferView]
+	for i := 0; i < doc.Accessors[*prim.Indices].Count; i += 3 {
+		var tri [3]int
+		for k := range tri {
+			tri[k] = int(binary.LittleEndian.Uint16(bin[idxView.ByteOffset+2*(i+k):]))
+		}
+		p0, p1, p2 := pos[tri[0]], pos[tri[1]], pos[tri[2]]
+		e1 := [3]float32{p1[0] - p0[0], p1[1] - p0[1], p1[2] - p0[2]}
+		e2 := [3]float32{p2[0] - p0[0], p2[1] - p0[1], p2[2] - p0[2]}
+		cross := [3]float32{e1[1]*e2[2] - e1[2]*e2[1], e1[2]*e2[0] - e1[0]*e2[2], e1[0]*e2[1] - e1[1]*e2[0]}
+		n := norm[tri[0]]
+		if cross[0]*n[0]+cross[1]*n[1]+cross[2]*n[2] <= 0 {
+			t.Fatalf("triangle %v faces inward", tri)
+		}
+	}
+}
+
+func readVec3(bin []byte, doc gltfDoc, accessor int) [][3]float32 {
+	acc := doc.Accessors[accessor]
+	off := doc.BufferViews[acc.BufferView].ByteOffset
+	out := make([][3]float32, acc.Count)
+	for i := range out {
+		for k := range out[i] {
+			out[i][k] = math.Float32frombits(binary.LittleEndian.Uint32(bin[off+12*i+4*k:]))
+		}
+	}
+	return out
+}
@@ -0,0 +1,339 @@
+// Package graph analyses diagrams as graphs: topological order, cycles, connected components,
+// the critical (heaviest) path, and per-node degree and centrality metrics. It works on the
+// normalized Scene, so diagrams imported from DOT or GraphML are handled the same way as
+// hand-written ones.
+//
+// Directed edges drive the order-sensitive analyses (TopologicalSort, Cycles, CriticalPath,
+// in/out degree); undirected edges only join components and count towards Degree. Edges that
+// name an unknown node are ignored, and repeated directed edges count once.
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+// Graph is an index-based view of a scene. Build one with FromScene or FromDiagram.
+type Graph struct {
+	ids     []string
+	index   map[string]int
+	weight  []float64 // node weights, 0 when absent
+	out, in [][]arc   // directed edges
+	undir   [][]int   // undirected neighbours, both directions
+}
+
+type arc struct {
+	node   int
+	weight float64
+}
+
+// FromScene indexes the nodes and edges of scene.
+func FromScene(scene poml.Scene) *Graph {
+	g := &Graph{index: make(map[string]int, len(scene.Nodes))}
+	for _, n := range scene.Nodes {
+		if _, dup := g.index[n.ID]; dup {
+			continue
+		}
+		g.index[n.ID] = len(g.ids)
+		g.ids = append(g.ids, n.ID)
+		g.weight = append(g.weight, parseWeight(n.Weight))
+	}
+	g.out = make([][]arc, len(g.ids))
+	g.in = make([][]arc, len(g.ids))
+	g.undir = make([][]int, len(g.ids))
+	seen := make(map[[2]int]bool)
+	for _, e := range scene.Edges {
+		from, ok1 := g.index[e.From]
+		to, ok2 := g.index[e.To]
+		if !ok1 || !ok2 {
+			continue
+		}
+		if !e.Directed {
+			g.undir[from] = append(g.undir[from], to)
+			if from != to {
+				g.undir[to] = append(g.undir[to], from)
+			}
+			continue
+		}
+		if seen[[2]int{from, to}] {
+			continue
+		}
+		seen[[2]int{from, to}] = true
+		w := parseWeight(e.Weight)
+		g.out[from] = append(g.out[from], arc{to, w})
+		g.in[to] = append(g.in[to], arc{from, w})
+	}
+	return g
+}
+
+// FromDiagram normalizes d with poml.DiagramToScene and indexes the result.
+func FromDiagram(d poml.Diagram) (*Graph, error) {
+	scene, err := poml.DiagramToScene(d)
+	if err != nil {
+		return nil, err
+	}
+	return FromScene(scene), nil
+}
+
+// Nodes returns the node IDs in scene order.
+func (g *Graph) Nodes() []string {
+	return append([]string(nil), g.ids...)
+}
+
+// CycleError reports that an analysis needing an acyclic graph found directed cycles.
+type CycleError struct {
+	Cycles [][]string // as returned by Graph.Cycles
+}
+
+func (e *CycleError) Error() string {
+	parts := make([]string, len(e.Cycles))
+	for i, c := range e.Cycles {
+		parts[i] = strings.Join(c, ", ")
+	}
+	return fmt.Sprintf("graph: %d cycle(s): [%s]", len(e.Cycles), strings.Join(parts, "]; ["))
+}
+
+// TopologicalSort orders the nodes so every directed edge points forward. Among nodes that are
+// ready at the same time the smallest ID comes first, so the order is deterministic. A graph
+// with directed cycles returns a *CycleError.
+func (g *Graph) TopologicalSort() ([]string, error) {
+	order, ok := g.topo()
+	if !ok {
+		return nil, &CycleError{Cycles: g.Cycles()}
+	}
+	out := make([]string, len(order))
+	for i, v := range order {
+		out[i] = g.ids[v]
+	}
+	return out, nil
+}
+
+func (g *Graph) topo() ([]int, bool) {
+	indeg := make([]int, len(g.ids))
+	for v := range g.ids {
+		indeg[v] = len(g.in[v])
+	}
+	var ready []int
+	for v, d := range indeg {
+		if d == 0 {
+			ready = append(ready, v)
+		}
+	}
+	order := make([]int, 0, len(g.ids))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(a, b int) bool { return g.ids[ready[a]] < g.ids[ready[b]] })
+		v := ready[0]
+		ready = ready[1:]
+		order = append(order, v)
+		for _, a := range g.out[v] {
+			if indeg[a.node]--; indeg[a.node] == 0 {
+				ready = append(ready, a.node)
+			}
+		}
+	}
+	return order, len(order) == len(g.ids)
+}
+
+// Cycles returns the groups of nodes that lie on directed cycles: each strongly connected
+// component with more than one node, plus any node with a self-loop. IDs within a group are
+// sorted and groups are ordered by their first ID; nil means the directed edges form a DAG.
+func (g *Graph) Cycles() [][]string {
+	var out [][]string
+	for _, comp := range g.strongComponents() {
+		if len(comp) == 1 && !g.hasSelfLoop(comp[0]) {
+			continue
+		}
+		out = append(out, g.names(comp))
+	}
+	sortGroups(out)
+	return out
+}
+
+func (g *Graph) hasSelfLoop(v int) bool {
+	for _, a := range g.out[v] {
+		if a.node == v {
+			return true
+		}
+	}
+	return false
+}
+
+// strongComponents runs Tarjan's algorithm iteratively so deep chains cannot exhaust the stack.
+func (g *Graph) strongComponents() [][]int {
+	n := len(g.ids)
+	index := make([]int, n)
+	low := make([]int, n)
+	onStack := make([]bool, n)
+	for i := range index {
+		index[i] = -1
+	}
+	var stack []int
+	var comps [][]int
+	next := 0
+	type frame struct{ v, edge int }
+	for root := 0; root < n; root++ {
+		if index[root] >= 0 {
+			continue
+		}
+		call := []frame{{root, 0}}
+		index[root], low[root] = next, next
+		next++
+		stack = append(stack, root)
+		onStack[root] = true
+		for len(call) > 0 {
+			f := &call[len(call)-1]
+			if f.edge < len(g.out[f.v]) {
+				w := g.out[f.v][f.edge].node
+				f.edge++
+				switch {
+				case index[w] < 0:
+					index[w], low[w] = next, next
+					next++
+					stack = append(stack, w)
+					onStack[w] = true
+					call = append(call, frame{w, 0})
+				case onStack[w]:
+					low[f.v] = min(low[f.v], index[w])
+				}
+				continue
+			}
+			v := f.v
+			call = call[:len(call)-1]
+			if len(call) > 0 {
+				parent := call[len(call)-1].v
+				low[parent] = min(low[parent], low[v])
+			}
+			if low[v] != index[v] {
+				continue
+			}
+			var comp []int
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				comp = append(comp, w)
+				if w == v {
+					break
+				}
+			}
+			comps = append(comps, comp)
+		}
+	}
+	return comps
+}
+
+// ConnectedComponents returns the weakly connected components: nodes joined by edges of either
+// kind, ignoring direction. IDs within a component are sorted; larger components come first,
+// ties broken by first ID.
+func (g *Graph) ConnectedComponents() [][]string {
+	n := len(g.ids)
+	seen := make([]bool, n)
+	var out [][]string
+	for root := 0; root < n; root++ {
+		if seen[root] {
+			continue
+		}
+		seen[root] = true
+		comp := []int{root}
+		for i := 0; i < len(comp); i++ {
+			for _, w := range g.neighbours(comp[i]) {
+				if !seen[w] {
+					seen[w] = true
+					comp = append(comp, w)
+				}
+			}
+		}
+		out = append(out, g.names(comp))
+	}
+	sort.SliceStable(out, func(a, b int) bool {
+		if len(out[a]) != len(out[b]) {
+			return len(out[a]) > len(out[b])
+		}
+		return out[a][0] < out[b][0]
+	})
+	return out
+}
+
+// neighbours lists every node adjacent to v regardless of direction (with repeats).
+func (g *Graph) neighbours(v int) []int {
+	out := append([]int(nil), g.undir[v]...)
+	for _, a := range g.out[v] {
+		out = append(out, a.node)
+	}
+	for _, a := range g.in[v] {
+		out = append(out, a.node)
+	}
+	return out
+}
+
+// Path is a chain of nodes along directed edges.
+type Path struct {
+	Nodes  []string `json:"nodes"`
+	Weight float64  `json:"weight"` // node weights plus edge weights along the path
+}
+
+// CriticalPath returns the heaviest path through the directed edges, scoring each path by the
+// weights of its nodes and edges (a missing or non-numeric weight counts as zero). Ties prefer
+// the path with more nodes, so an unweighted graph yields its longest chain. A graph with
+// directed cycles returns a *CycleError.
+func (g *Graph) CriticalPath() (Path, error) {
+	order, ok := g.topo()
+	if !ok {
+		return Path{}, &CycleError{Cycles: g.Cycles()}
+	}
+	if len(order) == 0 {
+		return Path{}, nil
+	}
+	n := len(g.ids)
+	best := make([]float64, n)
+	length := make([]int, n)
+	prev := make([]int, n)
+	for _, v := range order {
+		best[v], length[v], prev[v] = g.weight[v], 1, -1
+		for _, a := range g.in[v] {
+			w, l := best[a.node]+a.weight+g.weight[v], length[a.node]+1
+			if w > best[v] || (w == best[v] && l > length[v]) {
+				best[v], length[v], prev[v] = w, l, a.node
+			}
+		}
+	}
+	end := order[0]
+	for _, v := range order[1:] {
+		if best[v] > best[end] || (best[v] == best[end] && length[v] > length[end]) {
+			end = v
+		}
+	}
+	var nodes []string
+	for v := end; v >= 0; v = prev[v] {
+		nodes = append(nodes, g.ids[v])
+	}
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	return Path{Nodes: nodes, Weight: best[end]}, nil
+}
+
+func (g *Graph) names(vs []int) []string {
+	out := make([]string, len(vs))
+	for i, v := range vs {
+		out[i] = g.ids[v]
+	}
+	sort.Strings(out)
+	return out
+}
+
+func sortGroups(groups [][]string) {
+	sort.Slice(groups, func(a, b int) bool { return groups[a][0] < groups[b][0] })
+}
+
+func parseWeight(s string) float64 {
+	w, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return w
+}
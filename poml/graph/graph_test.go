@@ -0,0 +1,123 @@
+package graph
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+const deps = `<poml>
+  <diagram id="deps">
+    <graph>
+      <node id="design" weight="3"/>
+      <node id="api" weight="5"/>
+      <node id="ui" weight="2"/>
+      <node id="docs" weight="1"/>
+      <node id="ship"/>
+      <node id="notes"/>
+      <node id="wiki"/>
+      <edge from="design" to="api" directed="true"/>
+      <edge from="design" to="ui" directed="true" weight="4"/>
+      <edge from="api" to="ship" directed="true"/>
+      <edge from="ui" to="ship" directed="true"/>
+      <edge from="docs" to="ship" directed="true"/>
+      <edge from="notes" to="wiki" directed="false"/>
+    </graph>
+  </diagram>
+</poml>`
+
+func graphFor(t *testing.T, src string) *Graph {
+	t.Helper()
+	doc, err := poml.ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	g, err := FromDiagram(doc.Diagrams[0])
+	if err != nil {
+		t.Fatalf("graph: %v", err)
+	}
+	return g
+}
+
+func TestOrderAndCriticalPath(t *testing.T) {
+	g := graphFor(t, deps)
+	order, err := g.TopologicalSort()
+	if err != nil {
+		t.Fatalf("topo: %v", err)
+	}
+	want := []string{"design", "api", "docs", "notes", "ui", "ship", "wiki"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("topo order %v, want %v", order, want)
+	}
+	if cycles := g.Cycles(); cycles != nil {
+		t.Fatalf("expected no cycles, got %v", cycles)
+	}
+	path, err := g.CriticalPath()
+	if err != nil {
+		t.Fatalf("critical path: %v", err)
+	}
+	if !reflect.DeepEqual(path.Nodes, []string{"design", "ui", "ship"}) || path.Weight != 9 {
+		t.Fatalf("critical path %+v", path)
+	}
+	comps := g.ConnectedComponents()
+	if !reflect.DeepEqual(comps, [][]string{{"api", "design", "docs", "ship", "ui"}, {"notes", "wiki"}}) {
+		t.Fatalf("components %v", comps)
+	}
+}
+
+func TestCyclesAreReported(t *testing.T) {
+	scene := poml.Scene{
+		Nodes: []poml.SceneNode{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}},
+		Edges: []poml.SceneEdge{
+			{From: "a", To: "b", Directed: true},
+			{From: "b", To: "c", Directed: true},
+			{From: "c", To: "a", Directed: true},
+			{From: "d", To: "d", Directed: true},
+			{From: "c", To: "missing", Directed: true},
+		},
+	}
+	g := FromScene(scene)
+	want := [][]string{{"a", "b", "c"}, {"d"}}
+	if got := g.Cycles(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("cycles %v, want %v", got, want)
+	}
+	_, err := g.TopologicalSort()
+	var cerr *CycleError
+	if !errors.As(err, &cerr) || !reflect.DeepEqual(cerr.Cycles, want) {
+		t.Fatalf("expected CycleError, got %v", err)
+	}
+	if _, err := g.CriticalPath(); !errors.As(err, &cerr) {
+		t.Fatalf("expected CycleError from CriticalPath, got %v", err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	// a -> b -> c, plus an undirected b - d spoke.
+	g := FromScene(poml.Scene{
+		Nodes: []poml.SceneNode{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}},
+		Edges: []poml.SceneEdge{
+			{From: "a", To: "b", Directed: true},
+			{From: "b", To: "c", Directed: true},
+			{From: "b", To: "d"},
+		},
+	})
+	m := g.Metrics()
+	b := m[1]
+	if b.ID != "b" || b.InDegree != 1 || b.OutDegree != 1 || b.Degree != 3 || b.DegreeCentrality != 1 {
+		t.Fatalf("unexpected degrees for b: %+v", b)
+	}
+	// Shortest paths through b: a->c, a->d, d->c of the 6 ordered pairs among a, c, d.
+	if math.Abs(b.Betweenness-0.5) > 1e-9 {
+		t.Fatalf("betweenness for b = %v, want 0.5", b.Betweenness)
+	}
+	if m[0].Betweenness != 0 || m[2].Closeness != 0 {
+		t.Fatalf("unexpected metrics %+v", m)
+	}
+	// a reaches b (1 hop), c and d (2 hops): 3/5 * 3/3.
+	if math.Abs(m[0].Closeness-0.6) > 1e-9 {
+		t.Fatalf("closeness for a = %v, want 0.6", m[0].Closeness)
+	}
+}
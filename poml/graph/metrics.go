@@ -0,0 +1,88 @@
+package graph
+
+// NodeMetrics holds degree and centrality figures for one node. Centralities are normalized to
+// [0, 1] the way networkx normalizes them for directed graphs, so values can be compared
+// across diagrams of different sizes.
+type NodeMetrics struct {
+	ID        string `json:"id"`
+	InDegree  int    `json:"in_degree"`  // directed edges ending here
+	OutDegree int    `json:"out_degree"` // directed edges starting here
+	Degree    int    `json:"degree"`     // all incident edges, undirected ones included
+	// DegreeCentrality is Degree divided by the number of other nodes.
+	DegreeCentrality float64 `json:"degree_centrality"`
+	// Closeness is the inverse mean hop distance to the nodes reachable from this one, scaled
+	// by the fraction of the graph reachable (Wasserman-Faust), so isolated nodes score 0.
+	Closeness float64 `json:"closeness"`
+	// Betweenness is the fraction of shortest paths between other node pairs that pass
+	// through this node.
+	Betweenness float64 `json:"betweenness"`
+}
+
+// Metrics returns NodeMetrics for every node in scene order. Distances count hops: directed
+// edges are followed forwards and undirected edges either way; weights are not used.
+func (g *Graph) Metrics() []NodeMetrics {
+	n := len(g.ids)
+	out := make([]NodeMetrics, n)
+	for v := range g.ids {
+		m := NodeMetrics{ID: g.ids[v], InDegree: len(g.in[v]), OutDegree: len(g.out[v])}
+		m.Degree = m.InDegree + m.OutDegree + len(g.undir[v])
+		if n > 1 {
+			m.DegreeCentrality = float64(m.Degree) / float64(n-1)
+		}
+		out[v] = m
+	}
+	succ := make([][]int, n)
+	for v := range g.ids {
+		succ[v] = append(succ[v], g.undir[v]...)
+		for _, a := range g.out[v] {
+			succ[v] = append(succ[v], a.node)
+		}
+	}
+	betweenness := make([]float64, n)
+	for s := 0; s < n; s++ {
+		// Brandes: BFS from s counting shortest paths, then accumulate dependencies backwards.
+		dist := make([]int, n)
+		sigma := make([]float64, n)
+		preds := make([][]int, n)
+		for i := range dist {
+			dist[i] = -1
+		}
+		dist[s], sigma[s] = 0, 1
+		queue := []int{s}
+		for i := 0; i < len(queue); i++ {
+			v := queue[i]
+			for _, w := range succ[v] {
+				if dist[w] < 0 {
+					dist[w] = dist[v] + 1
+					queue = append(queue, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					preds[w] = append(preds[w], v)
+				}
+			}
+		}
+		var total int
+		for _, v := range queue[1:] {
+			total += dist[v]
+		}
+		if reached := len(queue) - 1; reached > 0 && n > 1 {
+			out[s].Closeness = float64(reached) / float64(total) * float64(reached) / float64(n-1)
+		}
+		delta := make([]float64, n)
+		for i := len(queue) - 1; i > 0; i-- {
+			w := queue[i]
+			for _, v := range preds[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			betweenness[w] += delta[w]
+		}
+	}
+	if n > 2 {
+		scale := 1 / float64((n-1)*(n-2))
+		for v := range out {
+			out[v].Betweenness = betweenness[v] * scale
+		}
+	}
+	return out
+}
@@ -0,0 +1,488 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const graphmlNS = "http://graphml.graphdrawing.org/xmlns"
+
+// graphmlGroupPrefix marks group nodes whose name collides with a regular node ID.
+const graphmlGroupPrefix = "group:"
+
+// GraphMLRenderer emits GraphML for a Scene so diagrams can be opened in yEd, Gephi, and other
+// GraphML tools. Node fields (label, owner, weight, pct_complete, x/y/z, tags), style entries
+// (as "style.<name>"), and raw attrs become <data> values with generated <key> declarations.
+// Grouped nodes are nested inside a group node's subgraph.
+type GraphMLRenderer struct{}
+
+// Render converts the scene into a GraphML document. Keys, nodes, and edges are sorted for stable output.
+func (r GraphMLRenderer) Render(scene Scene) ([]byte, error) {
+	nodeKeys, edgeKeys := graphmlSceneKeys(scene)
+	ids := map[string]string{}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	fmt.Fprintf(&buf, "<graphml xmlns=%q>\n", graphmlNS)
+	writeKeys := func(domain string, names []string) {
+		for _, name := range names {
+			id := "d" + strconv.Itoa(len(ids))
+			ids[domain+"/"+name] = id
+			typ := "string"
+			if graphmlDoubleKey(scene, domain, name) {
+				typ = "double"
+			}
+			fmt.Fprintf(&buf, "  <key id=%q for=%q attr.name=%s attr.type=%q/>\n", id, domain, graphmlAttr(name), typ)
+		}
+	}
+	graphKeys := graphmlCameraKeys(scene.Camera)
+	writeKeys("graph", sortedKeys(graphKeys))
+	writeKeys("node", nodeKeys)
+	writeKeys("edge", edgeKeys)
+
+	edgeDefault := "undirected"
+	for _, e := range scene.Edges {
+		if e.Directed {
+			edgeDefault = "directed"
+			break
+		}
+	}
+	writeData := func(indent, domain string, values map[string]string) {
+		for _, name := range sortedKeys(values) {
+			if values[name] == "" {
+				continue
+			}
+			fmt.Fprintf(&buf, "%s<data key=%q>%s</data>\n", indent, ids[domain+"/"+name], graphmlEscape(values[name]))
+		}
+	}
+	graphID := scene.ID
+	if graphID == "" {
+		graphID = "G"
+	}
+	fmt.Fprintf(&buf, "  <graph id=%s edgedefault=%q>\n", graphmlAttr(graphID), edgeDefault)
+	writeData("    ", "graph", graphKeys)
+
+	nodes := append([]SceneNode(nil), scene.Nodes...)
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	nodeIDs := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		nodeIDs[n.ID] = true
+	}
+	writeNode := func(indent string, n SceneNode) {
+		values := graphmlNodeValues(n)
+		if len(values) == 0 {
+			fmt.Fprintf(&buf, "%s<node id=%s/>\n", indent, graphmlAttr(n.ID))
+			return
+		}
+		fmt.Fprintf(&buf, "%s<node id=%s>\n", indent, graphmlAttr(n.ID))
+		writeData(indent+"  ", "node", values)
+		fmt.Fprintf(&buf, "%s</node>\n", indent)
+	}
+	groups := map[string][]SceneNode{}
+	for _, n := range nodes {
+		if n.Group == "" {
+			writeNode("    ", n)
+			continue
+		}
+		groups[n.Group] = append(groups[n.Group], n)
+	}
+	for _, group := range sortedKeys(groups) {
+		id := group
+		if nodeIDs[id] {
+			id = graphmlGroupPrefix + group
+		}
+		fmt.Fprintf(&buf, "    <node id=%s>\n", graphmlAttr(id))
+		writeData("      ", "node", map[string]string{"label": group})
+		fmt.Fprintf(&buf, "      <graph id=%s edgedefault=%q>\n", graphmlAttr(id+"::"), edgeDefault)
+		for _, n := range groups[group] {
+			writeNode("        ", n)
+		}
+		buf.WriteString("      </graph>\n")
+		buf.WriteString("    </node>\n")
+	}
+
+	edges := append([]SceneEdge(nil), scene.Edges...)
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	for i, e := range edges {
+		directed := ""
+		if e.Directed != (edgeDefault == "directed") {
+			directed = fmt.Sprintf(" directed=%q", strconv.FormatBool(e.Directed))
+		}
+		values := graphmlEdgeValues(e)
+		fmt.Fprintf(&buf, "    <edge id=\"e%d\" source=%s target=%s%s", i, graphmlAttr(e.From), graphmlAttr(e.To), directed)
+		if len(values) == 0 {
+			buf.WriteString("/>\n")
+			continue
+		}
+		buf.WriteString(">\n")
+		writeData("      ", "edge", values)
+		buf.WriteString("    </edge>\n")
+	}
+	buf.WriteString("  </graph>\n</graphml>\n")
+	return buf.Bytes(), nil
+}
+
+// graphmlNodeValues flattens a node into GraphML data values keyed by attr.name.
+func graphmlNodeValues(n SceneNode) map[string]string {
+	values := map[string]string{}
+	for k, v := range n.Attrs {
+		values[k] = v
+	}
+	for k, v := range n.Style {
+		values["style."+k] = v
+	}
+	values["label"] = n.Label
+	values["owner"] = n.Owner
+	values["weight"] = n.Weight
+	values["pct_complete"] = n.PctComplete
+	for i, axis := range []string{"x", "y", "z"} {
+		if n.Position[i] != 0 {
+			values[axis] = formatFloat(n.Position[i])
+		}
+	}
+	if len(n.Tags) > 0 {
+		if data, err := json.Marshal(n.Tags); err == nil {
+			values["tags"] = string(data)
+		}
+	}
+	for k, v := range values {
+		if v == "" {
+			delete(values, k)
+		}
+	}
+	return values
+}
+
+// graphmlEdgeValues flattens an edge into GraphML data values; Kind is written as "label" so
+// GraphML tools display it.
+func graphmlEdgeValues(e SceneEdge) map[string]string {
+	values := map[string]string{}
+	for k, v := range e.Attrs {
+		values[k] = v
+	}
+	for k, v := range e.Style {
+		values["style."+k] = v
+	}
+	values["label"] = e.Kind
+	values["weight"] = e.Weight
+	for k, v := range values {
+		if v == "" {
+			delete(values, k)
+		}
+	}
+	return values
+}
+
+func graphmlCameraKeys(c SceneCamera) map[string]string {
+	values := map[string]string{}
+	if c.Azimuth != "" {
+		values["camera.azimuth"] = c.Azimuth
+	}
+	if c.Elevation != "" {
+		values["camera.elevation"] = c.Elevation
+	}
+	if c.Distance != "" {
+		values["camera.distance"] = c.Distance
+	}
+	return values
+}
+
+// graphmlSceneKeys collects the node and edge attr.names used anywhere in the scene. Group
+// nodes carry a label, so "label" is always declared when groups are present.
+func graphmlSceneKeys(scene Scene) (nodeKeys, edgeKeys []string) {
+	nodeSet := map[string]bool{}
+	for _, n := range scene.Nodes {
+		for k := range graphmlNodeValues(n) {
+			nodeSet[k] = true
+		}
+		if n.Group != "" {
+			nodeSet["label"] = true
+		}
+	}
+	edgeSet := map[string]bool{}
+	for _, e := range scene.Edges {
+		for k := range graphmlEdgeValues(e) {
+			edgeSet[k] = true
+		}
+	}
+	return sortedKeys(nodeSet), sortedKeys(edgeSet)
+}
+
+// graphmlDoubleKey reports whether a key should be declared as attr.type="double": positions
+// always are, weights are when every value parses as a number.
+func graphmlDoubleKey(scene Scene, domain, name string) bool {
+	switch {
+	case domain == "node" && (name == "x" || name == "y" || name == "z"):
+		return true
+	case domain == "graph" && strings.HasPrefix(name, "camera."):
+		return false
+	case name != "weight":
+		return false
+	}
+	check := func(v string) bool {
+		if v == "" {
+			return true
+		}
+		_, err := strconv.ParseFloat(v, 64)
+		return err == nil
+	}
+	if domain == "node" {
+		for _, n := range scene.Nodes {
+			if !check(n.Weight) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, e := range scene.Edges {
+		if !check(e.Weight) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func graphmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// graphmlAttr quotes s as an XML attribute value.
+func graphmlAttr(s string) string {
+	return `"` + graphmlEscape(s) + `"`
+}
+
+type graphmlFile struct {
+	XMLName xml.Name       `xml:"graphml"`
+	Keys    []graphmlKey   `xml:"key"`
+	Graphs  []graphmlGraph `xml:"graph"`
+}
+
+type graphmlKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+}
+
+type graphmlGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Data        []graphmlData `xml:"data"`
+	Nodes       []graphmlNode `xml:"node"`
+	Edges       []graphmlEdge `xml:"edge"`
+}
+
+type graphmlNode struct {
+	ID    string        `xml:"id,attr"`
+	Data  []graphmlData `xml:"data"`
+	Graph *graphmlGraph `xml:"graph"`
+}
+
+type graphmlEdge struct {
+	Source   string        `xml:"source,attr"`
+	Target   string        `xml:"target,attr"`
+	Directed string        `xml:"directed,attr"`
+	Data     []graphmlData `xml:"data"`
+}
+
+// graphmlData keeps only character data; tool-specific payloads such as yEd's <y:ShapeNode>
+// are reduced to their text.
+type graphmlData struct {
+	Key   string
+	Value string
+}
+
+func (d *graphmlData) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	for _, a := range start.Attr {
+		if a.Name.Local == "key" {
+			d.Key = a.Value
+		}
+	}
+	var text strings.Builder
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			text.Write(t)
+		}
+	}
+	d.Value = text.String()
+	return nil
+}
+
+// ParseGraphML reads a GraphML document (as written by GraphMLRenderer, yEd, or Gephi) into a
+// Diagram. Data keys are resolved through their attr.name; label, owner, weight, pct_complete,
+// x/y/z, and tags map onto node fields, "style.<name>" onto styles, and anything else is kept in
+// Attrs. For edges, "label" or "kind" sets Kind. Nodes containing a nested graph are treated as
+// groups: their members get the group node's label (or ID) as Group, and the group node itself
+// is dropped.
+func ParseGraphML(data []byte) (Diagram, error) {
+	var file graphmlFile
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return Diagram{}, wrapXMLError(err, "graphml")
+	}
+	if len(file.Graphs) == 0 {
+		return Diagram{}, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "graphml: document has no <graph>"}
+	}
+	names := make(map[string]string, len(file.Keys))
+	for _, k := range file.Keys {
+		name := strings.TrimSpace(k.Name)
+		if name == "" {
+			name = k.ID
+		}
+		names[k.ID] = name
+	}
+	values := func(data []graphmlData) map[string]string {
+		out := make(map[string]string, len(data))
+		for _, d := range data {
+			name, ok := names[d.Key]
+			if !ok {
+				name = d.Key
+			}
+			if v := strings.TrimSpace(d.Value); v != "" {
+				out[name] = v
+			}
+		}
+		return out
+	}
+
+	root := file.Graphs[0]
+	scene := Scene{ID: root.ID}
+	for k, v := range values(root.Data) {
+		switch k {
+		case "camera.azimuth":
+			scene.Camera.Azimuth = v
+		case "camera.elevation":
+			scene.Camera.Elevation = v
+		case "camera.distance":
+			scene.Camera.Distance = v
+		}
+	}
+	var walk func(g graphmlGraph, group string, directed bool) error
+	walk = func(g graphmlGraph, group string, directed bool) error {
+		switch strings.ToLower(g.EdgeDefault) {
+		case "directed":
+			directed = true
+		case "undirected":
+			directed = false
+		}
+		for _, n := range g.Nodes {
+			v := values(n.Data)
+			if n.Graph != nil {
+				name := v["label"]
+				if name == "" {
+					name = strings.TrimPrefix(n.ID, graphmlGroupPrefix)
+				}
+				if err := walk(*n.Graph, name, directed); err != nil {
+					return err
+				}
+				continue
+			}
+			if n.ID == "" {
+				return &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "graphml: node without id"}
+			}
+			scene.Nodes = append(scene.Nodes, graphmlSceneNode(n.ID, group, v))
+		}
+		for _, e := range g.Edges {
+			if e.Source == "" || e.Target == "" {
+				return &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "graphml: edge without source or target"}
+			}
+			edgeDirected := directed
+			if e.Directed != "" {
+				b, err := strconv.ParseBool(e.Directed)
+				if err != nil {
+					return &POMLError{Type: ErrDecode, Code: CodeDecode, Message: fmt.Sprintf("graphml: edge %s->%s: invalid directed %q", e.Source, e.Target, e.Directed), Err: err}
+				}
+				edgeDirected = b
+			}
+			scene.Edges = append(scene.Edges, graphmlSceneEdge(e.Source, e.Target, edgeDirected, values(e.Data)))
+		}
+		return nil
+	}
+	if err := walk(root, "", true); err != nil {
+		return Diagram{}, err
+	}
+	return sceneToDiagram(scene), nil
+}
+
+func graphmlSceneNode(id, group string, values map[string]string) SceneNode {
+	n := SceneNode{ID: id, Group: group}
+	for k, v := range values {
+		switch {
+		case k == "label":
+			n.Label = v
+		case k == "owner":
+			n.Owner = v
+		case k == "weight":
+			n.Weight = v
+		case k == "pct_complete":
+			n.PctComplete = v
+		case k == "x" || k == "y" || k == "z":
+			n.Position[strings.Index("xyz", k)] = parseFloat(v)
+		case k == "tags":
+			if err := json.Unmarshal([]byte(v), &n.Tags); err != nil {
+				n.Tags = strings.Split(v, ",")
+			}
+		case strings.HasPrefix(k, "style."):
+			if n.Style == nil {
+				n.Style = map[string]string{}
+			}
+			n.Style[strings.TrimPrefix(k, "style.")] = v
+		default:
+			if n.Attrs == nil {
+				n.Attrs = map[string]string{}
+			}
+			n.Attrs[k] = v
+		}
+	}
+	return n
+}
+
+func graphmlSceneEdge(from, to string, directed bool, values map[string]string) SceneEdge {
+	e := SceneEdge{From: from, To: to, Directed: directed}
+	for k, v := range values {
+		switch {
+		case k == "label" || k == "kind":
+			e.Kind = v
+		case k == "weight":
+			e.Weight = v
+		case strings.HasPrefix(k, "style."):
+			if e.Style == nil {
+				e.Style = map[string]string{}
+			}
+			e.Style[strings.TrimPrefix(k, "style.")] = v
+		default:
+			if e.Attrs == nil {
+				e.Attrs = map[string]string{}
+			}
+			e.Attrs[k] = v
+		}
+	}
+	return e
+}
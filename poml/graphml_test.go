@@ -0,0 +1,96 @@
+package poml
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGraphMLRoundTripsDiagramFixtures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "diagrams", "*.poml"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("glob diagrams: %v", err)
+	}
+	reg := NewConverterRegistry()
+	registerDefaultConverters(reg)
+	for _, path := range paths {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		doc, err := ParseString(string(body))
+		if err != nil {
+			t.Fatalf("parse %s: %v", path, err)
+		}
+		want, err := DiagramToScene(doc.Diagrams[0])
+		if err != nil {
+			t.Fatalf("scene %s: %v", path, err)
+		}
+		out, err := (GraphMLRenderer{}).Render(want)
+		if err != nil {
+			t.Fatalf("render graphml %s: %v", path, err)
+		}
+		converted, err := reg.Convert(context.Background(), "graphml", "diagram", out, nil)
+		if err != nil {
+			t.Fatalf("graphml->diagram %s: %v\n%s", path, err, out)
+		}
+		got, err := DiagramToScene(converted.(Diagram))
+		if err != nil {
+			t.Fatalf("scene from graphml %s: %v", path, err)
+		}
+		if !reflect.DeepEqual(got.Nodes, want.Nodes) || !reflect.DeepEqual(got.Edges, want.Edges) || got.Camera != want.Camera {
+			t.Fatalf("graphml round trip mismatch for %s.\n got: %+v\nwant: %+v\n%s", path, got, want, out)
+		}
+	}
+}
+
+func TestParseGraphMLNestedGroupsAndYEdData(t *testing.T) {
+	src := `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns" xmlns:y="http://www.yworks.com/xml/graphml">
+  <key id="d0" for="node" attr.name="label" attr.type="string"/>
+  <key id="d1" for="edge" attr.name="weight" attr.type="double"/>
+  <key id="d2" for="node" attr.name="team" attr.type="string"/>
+  <key id="d3" for="node" yfiles.type="nodegraphics"/>
+  <graph id="arch" edgedefault="undirected">
+    <node id="api">
+      <data key="d0">API</data>
+      <graph id="api::" edgedefault="undirected">
+        <node id="gw"><data key="d0">Gateway</data><data key="d2">edge</data></node>
+        <node id="auth"><data key="d3"><y:ShapeNode><y:NodeLabel>Auth</y:NodeLabel></y:ShapeNode></data></node>
+      </graph>
+    </node>
+    <node id="db"/>
+    <edge source="gw" target="db" directed="true"><data key="d1">2.5</data></edge>
+    <edge source="auth" target="db"/>
+  </graph>
+</graphml>`
+	d, err := ParseGraphML([]byte(src))
+	if err != nil {
+		t.Fatalf("parse graphml: %v", err)
+	}
+	if d.ID != "arch" || len(d.Graph.Nodes) != 3 || len(d.Graph.Edges) != 2 {
+		t.Fatalf("expected 3 nodes and 2 edges in arch, got %+v", d)
+	}
+	gw := d.Graph.Nodes[0]
+	if gw.ID != "gw" || gw.Label != "Gateway" || gw.Group != "API" || len(gw.Attrs) != 1 || gw.Attrs[0].Value != "edge" {
+		t.Fatalf("node mismatch: %+v", gw)
+	}
+	if auth := d.Graph.Nodes[1]; auth.Group != "API" || len(auth.Attrs) != 1 || strings.TrimSpace(auth.Attrs[0].Value) != "Auth" {
+		t.Fatalf("yEd node graphics should reduce to text: %+v", auth)
+	}
+	e0, e1 := d.Graph.Edges[0], d.Graph.Edges[1]
+	if !*e0.Directed || e0.Weight != "2.5" || *e1.Directed {
+		t.Fatalf("edge mismatch: %+v / %+v", e0, e1)
+	}
+	if err := ValidateDiagram(d); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	for _, bad := range []string{"", "<graphml/>", `<graphml><graph><edge source="a"/></graph></graphml>`} {
+		if _, err := ParseGraphML([]byte(bad)); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
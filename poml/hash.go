@@ -0,0 +1,119 @@
+package poml
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// HashOptions controls what Document.Hash covers.
+type HashOptions struct {
+	// ExcludeTypes leaves elements of these types out of the document hash (they still get
+	// per-element hashes), e.g. ElementMeta to ignore version bumps or ElementRuntime to ignore
+	// per-environment model settings.
+	ExcludeTypes []ElementType
+	// KeepWhitespace hashes text exactly instead of collapsing runs of whitespace to one space.
+	KeepWhitespace bool
+}
+
+// ElementHash is the content hash of a single element.
+type ElementHash struct {
+	ID   string
+	Type ElementType
+	Sum  string // hex SHA-256 of the element's canonical form
+}
+
+// DocumentHash is a canonical content hash of a document and its elements.
+type DocumentHash struct {
+	Sum      string // hex SHA-256 over the ordered element hashes not excluded by HashOptions
+	Elements []ElementHash
+}
+
+// Hash computes a content hash that survives formatting changes: whitespace between elements,
+// comments, attribute order, CDATA versus escaped text, and (unless KeepWhitespace is set)
+// whitespace runs inside text do not affect it, while element order, names, attributes, and
+// text do. Element IDs are not hashed, so the same source always yields the same sums.
+func (d Document) Hash(opts HashOptions) (DocumentHash, error) {
+	var out DocumentHash
+	doc := sha256.New()
+	for _, el := range d.resolveOrder() {
+		canon, err := canonicalElementXML(elementXML(d, el), opts.KeepWhitespace)
+		if err != nil {
+			return DocumentHash{}, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "hash " + diffTypeLabel(el), Err: err}
+		}
+		sum := sha256.Sum256([]byte(canon))
+		h := ElementHash{ID: el.ID, Type: el.Type, Sum: hex.EncodeToString(sum[:])}
+		out.Elements = append(out.Elements, h)
+		if slices.Contains(opts.ExcludeTypes, el.Type) {
+			continue
+		}
+		io.WriteString(doc, string(el.Type)+"\x00"+h.Sum+"\n")
+	}
+	out.Sum = hex.EncodeToString(doc.Sum(nil))
+	return out, nil
+}
+
+// canonicalElementXML re-serializes an element with sorted attributes and no comments or
+// processing instructions; text is collapsed unless keepWS is set.
+func canonicalElementXML(raw string, keepWS bool) (string, error) {
+	dec := xml.NewDecoder(strings.NewReader(raw))
+	dec.Strict = false
+	var buf bytes.Buffer
+	var text strings.Builder
+	flush := func() {
+		s := text.String()
+		text.Reset()
+		if !keepWS {
+			s = strings.Join(strings.Fields(s), " ")
+		}
+		if s != "" {
+			_ = xml.EscapeText(&buf, []byte(s))
+		}
+	}
+	name := func(n xml.Name) string {
+		if n.Space != "" {
+			return n.Space + ":" + n.Local
+		}
+		return n.Local
+	}
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.StartElement:
+			flush()
+			attrs := slices.Clone(t.Attr)
+			sort.Slice(attrs, func(i, j int) bool {
+				if attrs[i].Name.Space != attrs[j].Name.Space {
+					return attrs[i].Name.Space < attrs[j].Name.Space
+				}
+				return attrs[i].Name.Local < attrs[j].Name.Local
+			})
+			buf.WriteString("<" + name(t.Name))
+			for _, a := range attrs {
+				buf.WriteString(" " + name(a.Name) + `="`)
+				_ = xml.EscapeText(&buf, []byte(a.Value))
+				buf.WriteString(`"`)
+			}
+			buf.WriteString(">")
+		case xml.EndElement:
+			flush()
+			buf.WriteString("</" + name(t.Name) + ">")
+		}
+	}
+	flush()
+	return buf.String(), nil
+}
@@ -0,0 +1,56 @@
+package poml
+
+import "testing"
+
+func TestHashIgnoresFormattingButNotContent(t *testing.T) {
+	a, err := ParseString(`<poml>
+  <role>You are   a
+    careful analyst.</role>
+  <!-- task list -->
+  <task>Summarize the report.</task>
+  <tool-request id="c1" name="search" parameters="{}"/>
+  <runtime temperature="0.2"/>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse a: %v", err)
+	}
+	b, err := ParseString(`<poml><role>You are a careful analyst.</role><task><![CDATA[Summarize the report.]]></task><tool-request name="search" parameters="{}" id="c1"/><runtime temperature="0.7"/></poml>`)
+	if err != nil {
+		t.Fatalf("parse b: %v", err)
+	}
+	ha, err := a.Hash(HashOptions{})
+	if err != nil {
+		t.Fatalf("hash a: %v", err)
+	}
+	hb, err := b.Hash(HashOptions{})
+	if err != nil {
+		t.Fatalf("hash b: %v", err)
+	}
+	if ha.Sum == hb.Sum {
+		t.Fatalf("runtime change should alter the document hash")
+	}
+	for i := 0; i < 3; i++ {
+		if ha.Elements[i].Sum != hb.Elements[i].Sum {
+			t.Fatalf("element %d (%s) hash differs across formatting-only changes", i, ha.Elements[i].Type)
+		}
+	}
+	if ha.Elements[3].Sum == hb.Elements[3].Sum {
+		t.Fatalf("runtime element hashes should differ")
+	}
+
+	opts := HashOptions{ExcludeTypes: []ElementType{ElementRuntime}}
+	ha, _ = a.Hash(opts)
+	hb, _ = b.Hash(opts)
+	if ha.Sum != hb.Sum || len(ha.Sum) != 64 {
+		t.Fatalf("excluding runtime should make hashes match: %s vs %s", ha.Sum, hb.Sum)
+	}
+	if ws, _ := a.Hash(HashOptions{KeepWhitespace: true, ExcludeTypes: opts.ExcludeTypes}); ws.Sum == ha.Sum {
+		t.Fatalf("KeepWhitespace should hash the role's line breaks")
+	}
+
+	a.Tasks[0].Body = "Summarize the report in French."
+	changed, _ := a.Hash(opts)
+	if changed.Sum == ha.Sum || changed.Elements[1].Sum == ha.Elements[1].Sum || changed.Elements[0].Sum != ha.Elements[0].Sum {
+		t.Fatalf("task edit should change only the task hash and the document hash")
+	}
+}
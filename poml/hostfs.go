@@ -0,0 +1,7 @@
+//go:build !js
+
+package poml
+
+// hostFileAccess reports whether local paths may be read from the host filesystem when no
+// fs.FS is configured.
+func hostFileAccess() error { return nil }
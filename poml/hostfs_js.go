@@ -0,0 +1,9 @@
+package poml
+
+import "errors"
+
+// errNoHostFilesystem is returned for local asset and document paths under GOOS=js, where
+// browsers have no filesystem to read.
+var errNoHostFilesystem = errors.New("host filesystem unavailable under GOOS=js; set ConvertOptions.FS (or FileDocumentResolver.FS) or use an AssetFetcher for remote sources")
+
+func hostFileAccess() error { return errNoHostFilesystem }
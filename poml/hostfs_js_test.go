@@ -0,0 +1,21 @@
+package poml
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLocalAssetsNeedFSUnderJS(t *testing.T) {
+	doc, err := ParseString(`<poml><img src="a.png" alt="x"/></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := Convert(doc, FormatOpenAIChat, ConvertOptions{}); !errors.Is(err, errNoHostFilesystem) {
+		t.Fatalf("expected host filesystem error, got %v", err)
+	}
+	files := fstest.MapFS{"a.png": {Data: []byte("png")}}
+	if _, err := Convert(doc, FormatOpenAIChat, ConvertOptions{FS: files}); err != nil {
+		t.Fatalf("FS-backed conversion: %v", err)
+	}
+}
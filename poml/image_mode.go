@@ -0,0 +1,96 @@
+package poml
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+)
+
+// ImageMode selects how openai_chat, mistral, and langchain output carries images; see
+// ConvertOptions.ImageMode. Formats whose providers only accept bytes always inline.
+type ImageMode string
+
+const (
+	// ImageModeInline embeds every image as Base64 (a data: URL for openai_chat). The default.
+	ImageModeInline ImageMode = "inline"
+	// ImageModeURL passes http(s) sources through as URLs without fetching them; local files and
+	// data: URIs are still inlined.
+	ImageModeURL ImageMode = "url"
+	// ImageModeUpload passes http(s) sources through like ImageModeURL and hands every other
+	// image, after any downscaling, to ConvertOptions.ImageUploader, sending the URL it returns.
+	ImageModeUpload ImageMode = "upload"
+)
+
+// ImageUploader stores an encoded image (mimeType is its type, such as "image/png") and returns a
+// URL the model provider can fetch. It is called once per image occurrence, so implementations
+// that see the same image often may want to key uploads by a hash of data.
+type ImageUploader func(ctx context.Context, data []byte, mimeType string) (string, error)
+
+// imageSource is an image as a URL-capable format sends it: by reference when URL is set,
+// otherwise as Base64 Data.
+type imageSource struct {
+	URL  string
+	MIME string
+	Data string
+}
+
+// dataURL returns the reference URL, or a data: URL of the inline payload.
+func (s imageSource) dataURL() string {
+	if s.URL != "" {
+		return s.URL
+	}
+	return "data:" + s.MIME + ";base64," + s.Data
+}
+
+// resolveImageSource applies opts.ImageMode to im.
+func resolveImageSource(im Image, opts ConvertOptions) (imageSource, error) {
+	switch opts.ImageMode {
+	case "", ImageModeInline:
+	case ImageModeURL, ImageModeUpload:
+		if isRemoteSrc(im.Src) {
+			mime := im.Syntax
+			if mime == "" {
+				mime = guessMime(im.Src)
+			}
+			return imageSource{URL: im.Src, MIME: mime}, nil
+		}
+	default:
+		return imageSource{}, fmt.Errorf("unknown image mode %q", opts.ImageMode)
+	}
+	part, err := buildImagePart(im, opts)
+	if err != nil {
+		return imageSource{}, err
+	}
+	src := imageSource{MIME: part["type"].(string), Data: part["base64"].(string)}
+	if opts.ImageMode != ImageModeUpload {
+		return src, nil
+	}
+	label := im.Src
+	if label == "" || len(label) > 64 {
+		label = "image"
+	}
+	if opts.ImageUploader == nil {
+		return imageSource{}, fmt.Errorf("upload %s: ImageModeUpload requires ConvertOptions.ImageUploader", label)
+	}
+	raw, err := base64.StdEncoding.DecodeString(src.Data)
+	if err != nil {
+		return imageSource{}, fmt.Errorf("upload %s: %w", label, err)
+	}
+	url, err := opts.ImageUploader(opts.readContext(), raw, src.MIME)
+	if err != nil {
+		return imageSource{}, fmt.Errorf("upload %s: %w", label, err)
+	}
+	return imageSource{URL: url, MIME: src.MIME}, nil
+}
+
+// langChainImageBlock is the LangChain standard content block for src.
+func langChainImageBlock(src imageSource) map[string]any {
+	if src.URL != "" {
+		block := map[string]any{"type": "image", "source_type": "url", "url": src.URL}
+		if src.MIME != "" {
+			block["mime_type"] = src.MIME
+		}
+		return block
+	}
+	return map[string]any{"type": "image", "source_type": "base64", "mime_type": src.MIME, "data": src.Data}
+}
@@ -0,0 +1,92 @@
+package poml
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const imageModeDoc = `<poml>
+  <img src="https://cdn.example.com/chart.png" alt="chart"/>
+  <img src="data:image/png;base64,iVBORw0KGgo=" syntax="image/png" alt="local"/>
+</poml>`
+
+func TestImageModeOpenAIAndLangChain(t *testing.T) {
+	doc, err := ParseString(imageModeDoc)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	urls := func(out any) []string {
+		b, _ := json.Marshal(out)
+		var payload struct {
+			Messages []struct {
+				Content []struct {
+					ImageURL struct{ URL string } `json:"image_url"`
+				}
+			}
+		}
+		if err := json.Unmarshal(b, &payload); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		var got []string
+		for _, m := range payload.Messages {
+			for _, c := range m.Content {
+				if c.ImageURL.URL != "" {
+					got = append(got, c.ImageURL.URL)
+				}
+			}
+		}
+		return got
+	}
+
+	if _, err := Convert(doc, FormatOpenAIChat, ConvertOptions{}); err == nil || !strings.Contains(err.Error(), "AssetFetcher") {
+		t.Fatalf("inline mode should still fetch remote images, got %v", err)
+	}
+	out, err := Convert(doc, FormatOpenAIChat, ConvertOptions{ImageMode: ImageModeURL})
+	if err != nil {
+		t.Fatalf("url mode: %v", err)
+	}
+	if got := urls(out); len(got) != 2 || got[0] != "https://cdn.example.com/chart.png" || got[1] != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Fatalf("url mode urls = %v", got)
+	}
+
+	var uploads []string
+	opts := ConvertOptions{ImageMode: ImageModeUpload, ImageUploader: func(_ context.Context, data []byte, mime string) (string, error) {
+		uploads = append(uploads, mime+":"+string(data[1:4]))
+		return "https://uploads.example.com/1", nil
+	}}
+	out, err = Convert(doc, FormatOpenAIChat, opts)
+	if err != nil {
+		t.Fatalf("upload mode: %v", err)
+	}
+	if got := urls(out); len(got) != 2 || got[0] != "https://cdn.example.com/chart.png" || got[1] != "https://uploads.example.com/1" {
+		t.Fatalf("upload mode urls = %v", got)
+	}
+	if len(uploads) != 1 || uploads[0] != "image/png:PNG" {
+		t.Fatalf("only the local image should be uploaded: %v", uploads)
+	}
+
+	out, err = Convert(doc, FormatLangChain, ConvertOptions{ImageMode: ImageModeURL})
+	if err != nil {
+		t.Fatalf("langchain url mode: %v", err)
+	}
+	b, _ := json.Marshal(out)
+	if !strings.Contains(string(b), `{"mime_type":"image/png","source_type":"url","type":"image","url":"https://cdn.example.com/chart.png"}`) ||
+		!strings.Contains(string(b), `"source_type":"base64"`) {
+		t.Fatalf("langchain blocks: %s", b)
+	}
+}
+
+func TestImageModeErrors(t *testing.T) {
+	doc, err := ParseString(imageModeDoc)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := Convert(doc, FormatOpenAIChat, ConvertOptions{ImageMode: ImageModeUpload}); err == nil || !strings.Contains(err.Error(), "ImageUploader") {
+		t.Fatalf("upload without uploader: %v", err)
+	}
+	if _, err := Convert(doc, FormatLangChain, ConvertOptions{ImageMode: "s3"}); err == nil || !strings.Contains(err.Error(), `unknown image mode "s3"`) {
+		t.Fatalf("unknown mode: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// maxResizePixels bounds the images downscaleImage decodes (about 8K×4K). Decoding needs memory
+// for every pixel the header claims, so a few-KB file declaring 60000×60000 would otherwise
+// allocate gigabytes; larger images are sent as is.
+const maxResizePixels = 1 << 25
+
+// downscaleImage shrinks a Base64 PNG or JPEG payload to fit within opts.MaxImageWidth and
+// opts.MaxImageHeight, keeping its aspect ratio, and returns the new payload and MIME type.
+// Payloads that already fit, other formats, images above maxResizePixels, and data that does not
+// decode are returned as is: the limits are a request-size optimization, not validation.
+func downscaleImage(data, mime string, opts ConvertOptions) (string, string) {
+	maxW, maxH := opts.MaxImageWidth, opts.MaxImageHeight
+	if maxW <= 0 && maxH <= 0 {
+		return data, mime
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return data, mime
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || (format != "png" && format != "jpeg") || int64(cfg.Width)*int64(cfg.Height) > maxResizePixels {
+		return data, mime
+	}
+	w, h := fitImageSize(cfg.Width, cfg.Height, maxW, maxH)
+	if w == cfg.Width && h == cfg.Height {
+		return data, mime
+	}
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return data, mime
+	}
+	dst := boxResize(src, w, h)
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		quality := opts.ImageQuality
+		if quality <= 0 || quality > 100 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return data, mime
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), "image/" + format
+}
+
+// fitImageSize scales w×h down to fit within maxW×maxH (a non-positive bound is unlimited),
+// rounding to the nearest pixel and never below 1.
+func fitImageSize(w, h, maxW, maxH int) (int, int) {
+	scale := 1.0
+	if maxW > 0 && w > maxW {
+		scale = float64(maxW) / float64(w)
+	}
+	if maxH > 0 && h > maxH {
+		scale = min(scale, float64(maxH)/float64(h))
+	}
+	if scale == 1 {
+		return w, h
+	}
+	return max(1, int(float64(w)*scale+0.5)), max(1, int(float64(h)*scale+0.5))
+}
+
+// boxResize downsamples src to w×h by averaging the premultiplied source pixels each
+// destination pixel covers, which avoids the aliasing of nearest-neighbor sampling on text-heavy
+// screenshots.
+func boxResize(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	}
+	sw, sh := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride+x0*4 : sy*rgba.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			n := (x1 - x0) * (y1 - y0)
+			off := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[off+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,107 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodeTestImage(t *testing.T, w, h int, format string) string {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatalf("encode %s: %v", format, err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestBuildImagePartDownscales(t *testing.T) {
+	pngData := encodeTestImage(t, 400, 200, "png")
+	jpegData := encodeTestImage(t, 300, 600, "jpeg")
+	cases := []struct {
+		name       string
+		img        Image
+		opts       ConvertOptions
+		w, h       int
+		format     string
+		unmodified bool
+	}{
+		{"png by width", Image{Src: "data:image/png;base64," + pngData, Syntax: "image/png"}, ConvertOptions{MaxImageWidth: 100}, 100, 50, "png", false},
+		{"jpeg by height", Image{Src: "data:image/jpeg;base64," + jpegData, Syntax: "image/jpeg"}, ConvertOptions{MaxImageWidth: 1000, MaxImageHeight: 90, ImageQuality: 60}, 45, 90, "jpeg", false},
+		{"fits", Image{Src: "data:image/png;base64," + pngData, Syntax: "image/png"}, ConvertOptions{MaxImageWidth: 400, MaxImageHeight: 400}, 400, 200, "png", true},
+		{"no limits", Image{Src: "data:image/png;base64," + pngData, Syntax: "image/png"}, ConvertOptions{}, 400, 200, "png", true},
+	}
+	for _, tc := range cases {
+		part, err := buildImagePart(tc.img, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		data := part["base64"].(string)
+		if tc.unmodified != (data == pngData) {
+			t.Fatalf("%s: payload modified = %v, want %v", tc.name, data != pngData, !tc.unmodified)
+		}
+		raw, _ := base64.StdEncoding.DecodeString(data)
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+		if err != nil || cfg.Width != tc.w || cfg.Height != tc.h || format != tc.format || part["mime"] != "image/"+tc.format {
+			t.Fatalf("%s: got %dx%d %s (%v), mime %v", tc.name, cfg.Width, cfg.Height, format, err, part["mime"])
+		}
+	}
+
+	// Formats the resizer does not handle pass through, even when limits are set.
+	gif := Image{Src: "data:image/gif;base64,R0lGODlhAQABAAAAACw=", Syntax: "image/gif"}
+	if part, err := buildImagePart(gif, ConvertOptions{MaxImageWidth: 1}); err != nil || part["base64"] != "R0lGODlhAQABAAAAACw=" {
+		t.Fatalf("gif should be unchanged: %v %v", part, err)
+	}
+}
+
+func TestDownscaleImageSkipsHugeDimensions(t *testing.T) {
+	// A 1×1 PNG whose header claims 60000×60000 pixels: decoding it would allocate gigabytes.
+	raw, _ := base64.StdEncoding.DecodeString(encodeTestImage(t, 1, 1, "png"))
+	ihdr := raw[12:29] // chunk type, width, height, ...
+	binary.BigEndian.PutUint32(ihdr[4:], 60000)
+	binary.BigEndian.PutUint32(ihdr[8:], 60000)
+	binary.BigEndian.PutUint32(raw[29:], crc32.ChecksumIEEE(ihdr))
+	bomb := base64.StdEncoding.EncodeToString(raw)
+	if cfg, err := png.DecodeConfig(bytes.NewReader(raw)); err != nil || cfg.Width != 60000 {
+		t.Fatalf("patched header: %+v %v", cfg, err)
+	}
+	if data, mime := downscaleImage(bomb, "image/png", ConvertOptions{MaxImageWidth: 100}); data != bomb || mime != "image/png" {
+		t.Fatalf("an image above maxResizePixels should be sent as is")
+	}
+}
+
+func TestBoxResizeAverages(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		c := color.RGBA{A: 255}
+		if x%2 == 1 {
+			c = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+		}
+		src.Set(x, 0, c)
+		src.Set(x, 1, c)
+	}
+	dst := boxResize(src, 2, 1)
+	for x := 0; x < 2; x++ {
+		if got := dst.RGBAAt(x, 0); got != (color.RGBA{R: 128, G: 128, B: 128, A: 255}) {
+			t.Fatalf("pixel %d = %v, want mid gray", x, got)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package poml
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ImportLangChain maps LangChain message dicts back into a Document. The payload may be the
+// object convertLangChain emits ({"messages": [...], "tools": [...], "schema": ..., "runtime": {...}})
+// or a bare message array as produced by langchain's messages_to_dict. Messages may carry their
+// fields under "data" or at the top level.
+//
+// human/ai/system messages become <human-msg>/<assistant-msg>/<system-msg> (image, audio, and
+// video blocks become <img>/<audio>/<video>); ai tool_calls become <tool-request>; tool messages
+// become <tool-response>, or <tool-result>/<tool-error> when flagged with result/error (or
+// status "error"). Meta is left empty, as with ImportOpenAIChat.
+func ImportLangChain(payload []byte) (Document, error) {
+	var doc Document
+	doc.nextID = 1
+	trimmed := strings.TrimSpace(string(payload))
+	if trimmed == "" {
+		return doc, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "import langchain: empty payload"}
+	}
+	var req map[string]json.RawMessage
+	if strings.HasPrefix(trimmed, "[") {
+		req = map[string]json.RawMessage{"messages": json.RawMessage(trimmed)}
+	} else if err := json.Unmarshal([]byte(trimmed), &req); err != nil {
+		return doc, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "import langchain", Err: err}
+	}
+
+	var messages []map[string]any
+	if raw, ok := req["messages"]; ok {
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			return doc, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "import langchain: messages", Err: err}
+		}
+	}
+	callNames := make(map[string]string)
+	for i, msg := range messages {
+		if err := importLangChainMessage(&doc, msg, callNames); err != nil {
+			return doc, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: fmt.Sprintf("import langchain: messages[%d]", i), Err: err}
+		}
+	}
+
+	if raw, ok := req["tools"]; ok {
+		var tools []map[string]any
+		if err := json.Unmarshal(raw, &tools); err != nil {
+			return doc, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "import langchain: tools", Err: err}
+		}
+		for _, tool := range tools {
+			importOpenAITool(&doc, tool)
+		}
+	}
+	if raw, ok := req["schema"]; ok && string(raw) != "null" {
+		var schema any
+		if err := json.Unmarshal(raw, &schema); err != nil {
+			return doc, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "import langchain: schema", Err: err}
+		}
+		if b, err := json.Marshal(schema); err == nil {
+			doc.AddOutputSchema(escapeBodyText(string(b)))
+		}
+	}
+	if raw, ok := req["runtime"]; ok {
+		var rt map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &rt); err != nil {
+			return doc, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "import langchain: runtime", Err: err}
+		}
+		keys := make([]string, 0, len(rt))
+		for k := range rt {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var attrs []xml.Attr
+		for _, k := range keys {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: k}, Value: runtimeAttrValue(rt[k])})
+		}
+		if len(attrs) > 0 {
+			doc.AddRuntime(attrs...)
+		}
+	}
+	return doc, nil
+}
+
+func importLangChainMessage(doc *Document, msg map[string]any, callNames map[string]string) error {
+	typ, _ := msg["type"].(string)
+	data, ok := msg["data"].(map[string]any)
+	if !ok {
+		data = msg
+	}
+	if typ == "chat" {
+		typ, _ = data["role"].(string)
+	}
+	// Accept class names too (HumanMessage, AIMessageChunk, ...).
+	kind := strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(typ), "chunk"), "message")
+	var role string
+	switch kind {
+	case "human", "user":
+		role = "human"
+	case "ai", "assistant":
+		role = "assistant"
+	case "system":
+		role = "system"
+	case "tool", "function":
+		id, _ := data["tool_call_id"].(string)
+		name, _ := data["name"].(string)
+		if name == "" {
+			name = callNames[id]
+		}
+		body := escapeBodyText(openAIContentText(data["content"]))
+		status, _ := data["status"].(string)
+		switch {
+		case data["error"] == true || status == "error":
+			doc.AddToolError(id, name, body)
+		case data["result"] == true:
+			doc.AddToolResult(id, name, body)
+		default:
+			doc.AddToolResponse(id, name, body)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported message type %q", typ)
+	}
+
+	switch content := data["content"].(type) {
+	case nil:
+	case string:
+		if content != "" || role != "assistant" {
+			doc.AddMessage(role, escapeBodyText(content))
+		}
+	case []any:
+		if err := importLangChainContentBlocks(doc, role, content); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported content type %T", content)
+	}
+
+	calls, _ := data["tool_calls"].([]any)
+	if len(calls) == 0 {
+		// Older dumps keep OpenAI-shaped calls in additional_kwargs.
+		if kw, ok := data["additional_kwargs"].(map[string]any); ok {
+			calls, _ = kw["tool_calls"].([]any)
+		}
+	}
+	for _, c := range calls {
+		call, _ := c.(map[string]any)
+		id, _ := call["id"].(string)
+		name, _ := call["name"].(string)
+		args := call["args"]
+		if fn, ok := call["function"].(map[string]any); ok {
+			name, _ = fn["name"].(string)
+			args = fn["arguments"]
+		}
+		params := ""
+		switch v := args.(type) {
+		case string:
+			params = v
+		case nil:
+		default:
+			if b, err := json.Marshal(v); err == nil {
+				params = string(b)
+			}
+		}
+		callNames[id] = name
+		doc.AddToolRequest(id, name, params)
+	}
+	return nil
+}
+
+// importLangChainContentBlocks maps a LangChain content block list. Text blocks join into one
+// message; image/audio/video blocks (base64 or url sources) become media elements.
+func importLangChainContentBlocks(doc *Document, role string, blocks []any) error {
+	var texts []string
+	var media []ElementPayload
+	for _, b := range blocks {
+		var block map[string]any
+		switch v := b.(type) {
+		case string:
+			texts = append(texts, v)
+			continue
+		case map[string]any:
+			block = v
+		default:
+			return fmt.Errorf("unsupported content block %T", b)
+		}
+		typ, _ := block["type"].(string)
+		switch typ {
+		case "text":
+			if s, _ := block["text"].(string); s != "" {
+				texts = append(texts, s)
+			}
+		case "image_url":
+			// OpenAI-style blocks are also accepted by LangChain chat models.
+			url, _ := block["image_url"].(string)
+			if m, ok := block["image_url"].(map[string]any); ok {
+				url, _ = m["url"].(string)
+			}
+			img := imageFromURL(url)
+			media = append(media, ElementPayload{Image: &img})
+		case "image", "audio", "video":
+			src, mime := langChainBlockSource(block)
+			if typ == "image" {
+				img := Image{Src: src, Syntax: mime}
+				media = append(media, ElementPayload{Image: &img})
+				continue
+			}
+			m := Media{Src: src, Syntax: mime}
+			if typ == "audio" {
+				media = append(media, ElementPayload{Audio: &m})
+			} else {
+				media = append(media, ElementPayload{Video: &m})
+			}
+		default:
+			return fmt.Errorf("unsupported content block type %q", typ)
+		}
+	}
+	if len(texts) > 0 {
+		doc.AddMessage(role, escapeBodyText(strings.Join(texts, "\n")))
+	}
+	for _, p := range media {
+		switch {
+		case p.Image != nil:
+			doc.AddImage(*p.Image)
+		case p.Audio != nil:
+			doc.Audios = append(doc.Audios, *p.Audio)
+			doc.Elements = append(doc.Elements, doc.newElement(ElementAudio, len(doc.Audios)-1, ""))
+		case p.Video != nil:
+			doc.Videos = append(doc.Videos, *p.Video)
+			doc.Elements = append(doc.Elements, doc.newElement(ElementVideo, len(doc.Videos)-1, ""))
+		}
+	}
+	return nil
+}
+
+// langChainBlockSource returns a src (data URI for base64 blocks) and MIME type for a LangChain
+// multimodal block.
+func langChainBlockSource(block map[string]any) (string, string) {
+	mime, _ := block["mime_type"].(string)
+	if url, _ := block["url"].(string); url != "" {
+		if mime == "" && strings.HasPrefix(url, "data:") {
+			mime = imageFromURL(url).Syntax
+		}
+		return url, mime
+	}
+	data, _ := block["data"].(string)
+	if data == "" {
+		data, _ = block["base64"].(string)
+	}
+	if mime == "" {
+		mime = "application/octet-stream"
+	}
+	return "data:" + mime + ";base64," + data, mime
+}
@@ -0,0 +1,77 @@
+package poml
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImportLangChainRoundTrip(t *testing.T) {
+	doc, err := ParseFile(filepath.Join("testdata", "examples", "parity_basic.poml"))
+	if err != nil {
+		t.Fatalf("parse fixture: %v", err)
+	}
+	out, err := Convert(doc, FormatLangChain, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	payload, err := json.Marshal(out)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	imported, err := ImportLangChain(payload)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(imported.ToolReqs) != 1 || len(imported.ToolResps) != 1 || len(imported.ToolResults) != 1 ||
+		len(imported.ToolErrors) != 1 || len(imported.Images) != 1 || len(imported.ToolDefs) != 1 {
+		t.Fatalf("unexpected element counts: %+v", imported.Elements)
+	}
+	if !imported.hasSchema() || len(imported.Runtimes) != 1 {
+		t.Fatalf("schema/runtime missing: %+v %+v", imported.Schema, imported.Runtimes)
+	}
+
+	again, err := Convert(imported, FormatLangChain, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert imported: %v", err)
+	}
+	want := canonicalizeJSON(t, out).(map[string]any)
+	got := canonicalizeJSON(t, again).(map[string]any)
+	for _, key := range []string{"messages", "schema", "runtime"} {
+		if !reflect.DeepEqual(got[key], want[key]) {
+			t.Fatalf("%s mismatch after round trip:\n got: %s\nwant: %s", key, prettyJSON(t, got[key]), prettyJSON(t, want[key]))
+		}
+	}
+}
+
+func TestImportLangChainMessagesToDict(t *testing.T) {
+	doc, err := ImportLangChain([]byte(`[
+  {"type":"system","data":{"content":"Be terse & exact","additional_kwargs":{}}},
+  {"type":"human","data":{"content":[{"type":"text","text":"What is this?"},{"type":"image","source_type":"url","url":"https://example.com/a.png"}]}},
+  {"type":"ai","data":{"content":"","tool_calls":[{"id":"c1","name":"lookup","args":{"q":"a.png"}}]}},
+  {"type":"tool","data":{"content":"not found","tool_call_id":"c1","status":"error"}},
+  {"type":"AIMessageChunk","content":"I could not find it."}
+]`))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(doc.Messages) != 3 || doc.Messages[0].Body != "Be terse &amp; exact" || doc.Messages[2].Role != "assistant" {
+		t.Fatalf("messages mismatch: %+v", doc.Messages)
+	}
+	if len(doc.Images) != 1 || doc.Images[0].Src != "https://example.com/a.png" {
+		t.Fatalf("image block mismatch: %+v", doc.Images)
+	}
+	if len(doc.ToolReqs) != 1 || doc.ToolReqs[0].Parameters != `{"q":"a.png"}` {
+		t.Fatalf("tool call mismatch: %+v", doc.ToolReqs)
+	}
+	if len(doc.ToolErrors) != 1 || doc.ToolErrors[0].Name != "lookup" {
+		t.Fatalf("tool error should infer name from call id: %+v", doc.ToolErrors)
+	}
+	if _, err := ImportLangChain([]byte(`[{"type":"robot","data":{"content":"x"}}]`)); err == nil {
+		t.Fatalf("expected error for unsupported message type")
+	}
+	if _, err := ImportLangChain([]byte(` `)); err == nil {
+		t.Fatalf("expected error for empty payload")
+	}
+}
@@ -0,0 +1,310 @@
+package poml
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ImportOpenAIChat maps an OpenAI chat payload back into a Document.
+// The payload may be a request object ({"messages": [...], "tools": [...], ...}) or a bare message array.
+// Messages, tool_calls, tool results, response_format, and tool definitions populate Messages, ToolReqs,
+// ToolResps/ToolResults/ToolErrors, Schema, and ToolDefs; remaining scalar keys become a <runtime> entry.
+// Meta is left empty so callers can stamp their own id/version/owner before validation.
+func ImportOpenAIChat(payload []byte) (Document, error) {
+	var doc Document
+	doc.nextID = 1
+	trimmed := strings.TrimSpace(string(payload))
+	if trimmed == "" {
+		return doc, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "import openai_chat: empty payload"}
+	}
+	var req map[string]json.RawMessage
+	if strings.HasPrefix(trimmed, "[") {
+		req = map[string]json.RawMessage{"messages": json.RawMessage(trimmed)}
+	} else if err := json.Unmarshal([]byte(trimmed), &req); err != nil {
+		return doc, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "import openai_chat", Err: err}
+	}
+
+	var messages []map[string]any
+	if raw, ok := req["messages"]; ok {
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			return doc, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "import openai_chat: messages", Err: err}
+		}
+	}
+	callNames := make(map[string]string)
+	for i, msg := range messages {
+		if err := importOpenAIMessage(&doc, msg, callNames); err != nil {
+			return doc, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: fmt.Sprintf("import openai_chat: messages[%d]", i), Err: err}
+		}
+	}
+
+	if raw, ok := req["tools"]; ok {
+		var tools []map[string]any
+		if err := json.Unmarshal(raw, &tools); err != nil {
+			return doc, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "import openai_chat: tools", Err: err}
+		}
+		for _, tool := range tools {
+			importOpenAITool(&doc, tool)
+		}
+	}
+
+	if raw, ok := req["response_format"]; ok {
+		var rf map[string]any
+		if err := json.Unmarshal(raw, &rf); err != nil {
+			return doc, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "import openai_chat: response_format", Err: err}
+		}
+		importOpenAIResponseFormat(&doc, rf)
+	}
+
+	var runtimeAttrs []xml.Attr
+	keys := make([]string, 0, len(req))
+	for k := range req {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		switch k {
+		case "messages", "tools", "response_format":
+			continue
+		}
+		runtimeAttrs = append(runtimeAttrs, xml.Attr{Name: xml.Name{Local: k}, Value: runtimeAttrValue(req[k])})
+	}
+	if len(runtimeAttrs) > 0 {
+		doc.AddRuntime(runtimeAttrs...)
+	}
+	return doc, nil
+}
+
+func importOpenAIMessage(doc *Document, msg map[string]any, callNames map[string]string) error {
+	role, _ := msg["role"].(string)
+	switch role {
+	case "tool", "function":
+		id, _ := msg["tool_call_id"].(string)
+		name, _ := msg["name"].(string)
+		if name == "" {
+			name = callNames[id]
+		}
+		body := escapeBodyText(openAIContentText(msg["content"]))
+		switch msg["type"] {
+		case "result":
+			doc.AddToolResult(id, name, body)
+		case "error":
+			doc.AddToolError(id, name, body)
+		default:
+			doc.AddToolResponse(id, name, body)
+		}
+		return nil
+	case "user", "assistant", "system", "developer":
+	default:
+		return fmt.Errorf("unsupported role %q", role)
+	}
+
+	pomlRole := "human"
+	switch role {
+	case "assistant":
+		pomlRole = "assistant"
+	case "system", "developer":
+		pomlRole = "system"
+	}
+	switch content := msg["content"].(type) {
+	case nil:
+	case string:
+		doc.AddMessage(pomlRole, escapeBodyText(content))
+	case []any:
+		if err := importOpenAIContentParts(doc, pomlRole, content); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported content type %T", content)
+	}
+
+	if calls, ok := msg["tool_calls"].([]any); ok {
+		for _, c := range calls {
+			call, _ := c.(map[string]any)
+			fn, _ := call["function"].(map[string]any)
+			id, _ := call["id"].(string)
+			name, _ := fn["name"].(string)
+			args := ""
+			switch v := fn["arguments"].(type) {
+			case string:
+				args = v
+			case nil:
+			default:
+				if b, err := json.Marshal(v); err == nil {
+					args = string(b)
+				}
+			}
+			callNames[id] = name
+			doc.AddToolRequest(id, name, args)
+		}
+	}
+	return nil
+}
+
+// importOpenAIContentParts maps a multi-part content array onto one message whose body keeps the
+// parts in order: text parts as text (adjacent ones joined by newlines), and images and audio as
+// nested <img> and <audio> elements, which the converters emit as the same parts again.
+func importOpenAIContentParts(doc *Document, role string, parts []any) error {
+	var body strings.Builder
+	afterText := false
+	for _, p := range parts {
+		part, ok := p.(map[string]any)
+		if !ok {
+			return fmt.Errorf("unsupported content part %T", p)
+		}
+		switch part["type"] {
+		case "text":
+			if s, _ := part["text"].(string); s != "" {
+				if afterText {
+					body.WriteByte('\n')
+				}
+				body.WriteString(escapeBodyText(s))
+				afterText = true
+			}
+			continue
+		case "image_url":
+			img, _ := part["image_url"].(map[string]any)
+			url, _ := img["url"].(string)
+			im := imageFromURL(url)
+			writeInlineMedia(&body, "img", im.Src, im.Syntax)
+		case "input_audio":
+			au, _ := part["input_audio"].(map[string]any)
+			if au == nil {
+				au, _ = part["audio"].(map[string]any)
+			}
+			m := mediaFromOpenAIAudio(au)
+			writeInlineMedia(&body, "audio", m.Src, m.Syntax)
+		default:
+			return fmt.Errorf("unsupported content part type %v", part["type"])
+		}
+		afterText = false
+	}
+	if body.Len() > 0 {
+		doc.AddMessage(role, body.String())
+	}
+	return nil
+}
+
+// writeInlineMedia writes an empty <img> or <audio> element for a message body.
+func writeInlineMedia(b *strings.Builder, tag, src, syntax string) {
+	b.WriteString("<" + tag + ` src="`)
+	_ = xml.EscapeText(b, []byte(src))
+	if syntax != "" {
+		b.WriteString(`" syntax="`)
+		_ = xml.EscapeText(b, []byte(syntax))
+	}
+	b.WriteString(`"/>`)
+}
+
+func imageFromURL(url string) Image {
+	img := Image{Src: url}
+	if strings.HasPrefix(url, "data:") {
+		meta := strings.TrimPrefix(strings.SplitN(url, ",", 2)[0], "data:")
+		img.Syntax = strings.TrimSuffix(meta, ";base64")
+	}
+	return img
+}
+
+func mediaFromOpenAIAudio(au map[string]any) Media {
+	data, _ := au["data"].(string)
+	if data == "" {
+		data, _ = au["base64"].(string)
+	}
+	mime, _ := au["mime_type"].(string)
+	if mime == "" {
+		if format, _ := au["format"].(string); format != "" {
+			mime = "audio/" + format
+		}
+	}
+	if mime == "" {
+		mime = "application/octet-stream"
+	}
+	return Media{Src: "data:" + mime + ";base64," + data, Syntax: mime}
+}
+
+func importOpenAITool(doc *Document, tool map[string]any) {
+	fn, ok := tool["function"].(map[string]any)
+	if !ok {
+		// flat tool shape (dict/langchain style)
+		fn = tool
+	}
+	name, _ := fn["name"].(string)
+	desc, _ := fn["description"].(string)
+	td := ToolDefinition{Name: name}
+	if params, ok := fn["parameters"]; ok && params != nil {
+		if b, err := json.Marshal(params); err == nil {
+			td.Body = escapeBodyText(string(b))
+		}
+		// convertOpenAIChat falls back to the schema body when description is empty; drop the echo.
+		if _, isJSON := parseJSONIfStruct(desc); isJSON {
+			desc = ""
+		}
+	}
+	td.Description = desc
+	if attrs, ok := fn["attrs"].(map[string]any); ok {
+		m := make(map[string]string, len(attrs))
+		for k, v := range attrs {
+			m[k] = fmt.Sprint(v)
+		}
+		td.Attrs = attrsFromMap(m)
+	}
+	doc.ToolDefs = append(doc.ToolDefs, td)
+	doc.Elements = append(doc.Elements, doc.newElement(ElementToolDefinition, len(doc.ToolDefs)-1, ""))
+}
+
+func importOpenAIResponseFormat(doc *Document, rf map[string]any) {
+	switch rf["type"] {
+	case "json_schema":
+		js, _ := rf["json_schema"].(map[string]any)
+		schema, ok := js["schema"]
+		if !ok {
+			return
+		}
+		if b, err := json.Marshal(schema); err == nil {
+			doc.AddOutputSchema(escapeBodyText(string(b)))
+		}
+	case "json_object":
+		doc.AddOutputSchema(`{"type":"object"}`)
+	}
+}
+
+// openAIContentText flattens string or text-part content into plain text.
+func openAIContentText(content any) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []any:
+		var texts []string
+		for _, p := range v {
+			if part, ok := p.(map[string]any); ok {
+				if s, _ := part["text"].(string); s != "" {
+					texts = append(texts, s)
+				}
+			}
+		}
+		return strings.Join(texts, "\n")
+	case nil:
+		return ""
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// runtimeAttrValue renders a JSON value as a runtime attribute string (strings unquoted).
+func runtimeAttrValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+var bodyTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escapeBodyText escapes plain text for storage in an innerxml Body field.
+func escapeBodyText(s string) string {
+	return bodyTextEscaper.Replace(s)
+}
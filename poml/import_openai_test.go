@@ -0,0 +1,124 @@
+package poml
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImportOpenAIChatRoundTrip(t *testing.T) {
+	doc, err := ParseFile(filepath.Join("testdata", "examples", "parity_basic.poml"))
+	if err != nil {
+		t.Fatalf("parse fixture: %v", err)
+	}
+	out, err := Convert(doc, FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	payload, err := json.Marshal(out)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	imported, err := ImportOpenAIChat(payload)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(imported.Messages) != 3 || len(imported.ToolReqs) != 1 || len(imported.ToolResps) != 1 ||
+		len(imported.ToolResults) != 1 || len(imported.ToolErrors) != 1 || len(imported.Images) != 0 {
+		t.Fatalf("unexpected element counts: %+v", imported.Elements)
+	}
+	if len(imported.ToolDefs) != 1 || imported.ToolDefs[0].Name != "calc" || imported.ToolDefs[0].Description != "" {
+		t.Fatalf("tool definition mismatch: %+v", imported.ToolDefs)
+	}
+	if body := imported.Messages[2].Body; body != `tiny<img src="data:image/png;base64,AA==" syntax="image/png"/>` {
+		t.Fatalf("image message mismatch: %q", body)
+	}
+	if !imported.hasSchema() || len(imported.Runtimes) != 1 {
+		t.Fatalf("schema/runtime missing: %+v %+v", imported.Schema, imported.Runtimes)
+	}
+
+	again, err := Convert(imported, FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert imported: %v", err)
+	}
+	want := canonicalizeJSON(t, out).(map[string]any)
+	got := canonicalizeJSON(t, again).(map[string]any)
+	for _, key := range []string{"messages", "response_format", "temperature", "max_tokens"} {
+		if !reflect.DeepEqual(got[key], want[key]) {
+			t.Fatalf("%s mismatch after round trip:\n got: %s\nwant: %s", key, prettyJSON(t, got[key]), prettyJSON(t, want[key]))
+		}
+	}
+}
+
+func TestImportOpenAIChatContentPartsRoundTrip(t *testing.T) {
+	payload := []byte(`{"messages":[
+  {"role":"system","content":"Be brief."},
+  {"role":"user","content":[{"type":"text","text":"What is in this image?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AA=="}}]},
+  {"role":"user","content":[
+    {"type":"image_url","image_url":{"url":"data:image/png;base64,AQ=="}},
+    {"type":"text","text":"Compare it with this one"},
+    {"type":"text","text":"then say which is larger."},
+    {"type":"image_url","image_url":{"url":"data:image/jpeg;base64,Ag=="}}
+  ]}
+]}`)
+	doc, err := ImportOpenAIChat(payload)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(doc.Images) != 0 || len(doc.Messages) != 3 {
+		t.Fatalf("want three messages and no top-level images: %+v", doc.Elements)
+	}
+	wantBodies := []string{
+		`What is in this image?<img src="data:image/png;base64,AA==" syntax="image/png"/>`,
+		`<img src="data:image/png;base64,AQ==" syntax="image/png"/>Compare it with this one` + "\n" +
+			`then say which is larger.<img src="data:image/jpeg;base64,Ag==" syntax="image/jpeg"/>`,
+	}
+	for i, want := range wantBodies {
+		if got := doc.Messages[i+1].Body; got != want {
+			t.Fatalf("message %d body:\n got %q\nwant %q", i+1, got, want)
+		}
+	}
+
+	out, err := Convert(doc, FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	var in map[string]any
+	if err := json.Unmarshal(payload, &in); err != nil {
+		t.Fatal(err)
+	}
+	in["messages"].([]any)[2].(map[string]any)["content"] = []any{
+		map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64,AQ=="}},
+		map[string]any{"type": "text", "text": "Compare it with this one\nthen say which is larger."},
+		map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/jpeg;base64,Ag=="}},
+	}
+	want := canonicalizeJSON(t, in).(map[string]any)["messages"]
+	got := canonicalizeJSON(t, out).(map[string]any)["messages"]
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("messages mismatch after round trip:\n got: %s\nwant: %s", prettyJSON(t, got), prettyJSON(t, want))
+	}
+}
+
+func TestImportOpenAIChatMessageArrayAndErrors(t *testing.T) {
+	doc, err := ImportOpenAIChat([]byte(`[
+  {"role":"system","content":"Be terse & exact"},
+  {"role":"assistant","content":null,"tool_calls":[{"id":"c1","type":"function","function":{"name":"calc","arguments":"{\"x\":1}"}}]},
+  {"role":"tool","tool_call_id":"c1","content":"2"}
+]`))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if doc.Messages[0].Role != "system" || doc.Messages[0].Body != "Be terse &amp; exact" {
+		t.Fatalf("system message mismatch: %+v", doc.Messages[0])
+	}
+	if len(doc.ToolResps) != 1 || doc.ToolResps[0].Name != "calc" {
+		t.Fatalf("tool name not inferred from call id: %+v", doc.ToolResps)
+	}
+	if _, err := ImportOpenAIChat([]byte(`{"messages":[{"role":"robot","content":"x"}]}`)); err == nil {
+		t.Fatalf("expected error for unsupported role")
+	}
+	if _, err := ImportOpenAIChat([]byte(`  `)); err == nil {
+		t.Fatalf("expected error for empty payload")
+	}
+}
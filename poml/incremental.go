@@ -0,0 +1,351 @@
+package poml
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+)
+
+// TextEdit replaces the byte range [Start, End) of the current source with Text.
+type TextEdit struct {
+	Start, End int
+	Text       string
+}
+
+// ReparseResult reports what an edit changed.
+type ReparseResult struct {
+	// Full is true when the edit could not be confined to top-level elements (it touched the
+	// root tag or prolog, the patched range did not parse on its own, or it left a duplicate
+	// meta, role, or output-schema) and the whole source was parsed again.
+	Full bool
+	// Removed lists the IDs of elements that were replaced. Unchanged elements keep their IDs,
+	// and a re-parsed element keeps its ID when the edit did not change the number or types of
+	// the elements it touched.
+	Removed []string
+	// Elements are the re-parsed elements, in document order.
+	Elements []Element
+}
+
+// IncrementalParser keeps a Document together with its source so editor integrations can apply
+// byte-range edits without parsing the whole file on every keystroke. Only the top-level
+// elements overlapping or touching an edit are decoded again; the rest of Elements and the
+// backing slices are patched in place.
+//
+// ParseOptions are honored as in a full parse. Validate, StableIDs, and ResolveDocuments run over
+// the whole document after each edit; Recover always falls back to full parses because issues
+// carry absolute positions. The source is taken as UTF-8 text, as editors hold it: byte order
+// marks and other encodings are not converted, since edits address its bytes directly.
+type IncrementalParser struct {
+	opts  ParseOptions
+	src   []byte
+	doc   Document
+	spans parseSpans
+	stale bool // the last edit failed to parse; the next edit needs a full parse
+}
+
+// NewIncrementalParser parses src and records element offsets for later edits.
+func NewIncrementalParser(src []byte, opts ParseOptions) (*IncrementalParser, error) {
+	p := &IncrementalParser{opts: opts, src: slices.Clone(src)}
+	if err := p.parseFull(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Document returns the current document. After a failed Apply it is the last document that
+// parsed successfully. The result shares storage with the parser and changes on the next Apply.
+func (p *IncrementalParser) Document() Document { return p.doc }
+
+// Source returns the current source, including edits that failed to parse.
+func (p *IncrementalParser) Source() []byte { return p.src }
+
+// Apply patches the source with edit and re-parses the affected elements. On a parse error the
+// source still reflects the edit (so later offsets stay valid), Document keeps its previous
+// value, and the next Apply parses the whole source.
+func (p *IncrementalParser) Apply(edit TextEdit) (ReparseResult, error) {
+	if edit.Start < 0 || edit.End < edit.Start || edit.End > len(p.src) {
+		return ReparseResult{}, fmt.Errorf("incremental parse: edit range [%d,%d) outside source of %d bytes", edit.Start, edit.End, len(p.src))
+	}
+	oldSrc := p.src
+	p.src = slices.Concat(oldSrc[:edit.Start], []byte(edit.Text), oldSrc[edit.End:])
+	if !p.stale && !p.opts.Recover {
+		if res, ok := p.patch(edit); ok {
+			return res, p.finish()
+		}
+	}
+	removed := make([]string, 0, len(p.doc.Elements))
+	for _, el := range p.doc.Elements {
+		removed = append(removed, el.ID)
+	}
+	if err := p.parseFull(); err != nil {
+		return ReparseResult{}, err
+	}
+	return ReparseResult{Full: true, Removed: removed, Elements: p.doc.Elements}, nil
+}
+
+func (p *IncrementalParser) parseFull() error {
+	var spans parseSpans
+	doc, err := parseWithSpans(bytes.NewReader(p.src), p.opts, &spans)
+	if err != nil {
+		p.stale = true
+		return err
+	}
+	p.doc, p.spans, p.stale = doc, spans, false
+	return nil
+}
+
+// patch re-decodes the elements overlapping edit. ok is false when a full parse is needed.
+func (p *IncrementalParser) patch(edit TextEdit) (ReparseResult, bool) {
+	spans := p.spans.elements
+	if len(spans) == 0 || len(spans) != len(p.doc.Elements) || edit.Start < p.spans.rootEnd || edit.End > p.spans.closeStart {
+		return ReparseResult{}, false
+	}
+	// The last element owns the trailing text before </poml>.
+	spanEnd := func(i int) int {
+		if i == len(spans)-1 {
+			return p.spans.closeStart
+		}
+		return spans[i].end
+	}
+	from, to := -1, 0
+	for i, sp := range spans {
+		if sp.start <= edit.End && spanEnd(i) >= edit.Start {
+			if from < 0 {
+				from = i
+			}
+			to = i + 1
+		}
+	}
+	if from < 0 {
+		return ReparseResult{}, false
+	}
+	delta := len(edit.Text) - (edit.End - edit.Start)
+	regionStart := spans[from].start
+
+	rootTag := p.src[p.spans.rootStart:p.spans.rootEnd]
+	opts := p.opts
+	opts.Validate, opts.StableIDs, opts.ResolveDocuments = false, false, false
+	var frag Document
+	var fragSpans parseSpans
+	for {
+		fragment := slices.Concat(rootTag, p.src[regionStart:spanEnd(to-1)+delta], []byte("</poml>"))
+		fragSpans = parseSpans{}
+		var err error
+		frag, err = parseWithSpans(bytes.NewReader(fragment), opts, &fragSpans)
+		// An edit that closes </poml> early or leaves content outside elements cannot be patched.
+		if err != nil || fragSpans.closeStart != len(fragment)-len("</poml>") || len(frag.Elements) == 0 {
+			return ReparseResult{}, false
+		}
+		// Text the edit left after the region's last element leads the next element, as in a
+		// full parse, so take that element into the region too.
+		if to == len(spans) || fragSpans.elements[len(fragSpans.elements)-1].end == fragSpans.closeStart {
+			break
+		}
+		to++
+	}
+
+	// A full parse keeps the last of duplicate singletons, which a patch cannot track.
+	if duplicateSingleton(p.doc.Elements) || duplicateSingleton(slices.Concat(p.doc.Elements[:from], frag.Elements, p.doc.Elements[to:])) {
+		return ReparseResult{}, false
+	}
+
+	res := ReparseResult{}
+	for _, el := range p.doc.Elements[from:to] {
+		res.Removed = append(res.Removed, el.ID)
+	}
+	res.Elements = p.doc.spliceElements(from, to, frag)
+
+	shift := regionStart - len(rootTag)
+	newSpans := make([]sourceSpan, 0, len(spans)-(to-from)+len(fragSpans.elements))
+	newSpans = append(newSpans, spans[:from]...)
+	for _, sp := range fragSpans.elements {
+		newSpans = append(newSpans, sourceSpan{start: sp.start + shift, end: sp.end + shift})
+	}
+	for _, sp := range spans[to:] {
+		newSpans = append(newSpans, sourceSpan{start: sp.start + delta, end: sp.end + delta})
+	}
+	p.spans.elements = newSpans
+	p.spans.closeStart += delta
+	return res, true
+}
+
+// duplicateSingleton reports whether els hold more than one meta, role, or output-schema.
+func duplicateSingleton(els []Element) bool {
+	seen := map[ElementType]bool{}
+	for _, el := range els {
+		switch el.Type {
+		case ElementMeta, ElementRole, ElementOutputSchema:
+			if seen[el.Type] {
+				return true
+			}
+			seen[el.Type] = true
+		}
+	}
+	return false
+}
+
+// finish applies the whole-document ParseOptions after a patch.
+func (p *IncrementalParser) finish() error {
+	if p.opts.StableIDs {
+		p.doc.AssignStableIDs()
+	}
+	if p.opts.ResolveDocuments {
+		if err := p.doc.ResolveDocuments(p.opts.DocumentResolver); err != nil {
+			return err
+		}
+	}
+	if p.opts.Validate {
+		return p.doc.Validate()
+	}
+	return nil
+}
+
+// spliceElements replaces d.Elements[from:to] (and their payloads) with frag's elements and
+// returns the inserted elements.
+func (d *Document) spliceElements(from, to int, frag Document) []Element {
+	old := slices.Clone(d.Elements[from:to])
+	for i := len(old) - 1; i >= 0; i-- {
+		d.removePayload(old[i])
+	}
+	keepIDs := len(old) == len(frag.Elements)
+	for i := 0; keepIDs && i < len(old); i++ {
+		keepIDs = old[i].Type == frag.Elements[i].Type
+	}
+	counts := map[ElementType]int{}
+	for _, el := range d.Elements[:from] {
+		counts[indexGroup(el.Type)]++
+	}
+	inserted := make([]Element, 0, len(frag.Elements))
+	for i, fe := range frag.Elements {
+		payload := frag.payloadFor(fe)
+		el := fe
+		switch fe.Type {
+		case ElementMeta:
+			d.Meta = *payload.Meta
+		case ElementRole:
+			d.Role = *payload.Role
+		case ElementOutputSchema:
+			d.Schema = *payload.Schema
+		case ElementUnknown:
+		default:
+			group := indexGroup(fe.Type)
+			d.insertPayloadAt(counts[group], payload)
+			el.Index = counts[group]
+			counts[group]++
+		}
+		if keepIDs {
+			el.ID = old[i].ID
+		} else {
+			el.ID = d.freshID()
+		}
+		inserted = append(inserted, el)
+	}
+	d.Elements = slices.Concat(d.Elements[:from], inserted, d.Elements[to:])
+	d.reindex()
+	return inserted
+}
+
+// removePayload deletes el's entry from its backing slice (or clears a singleton).
+func (d *Document) removePayload(el Element) {
+	del := func(n int) bool { return el.Index >= 0 && el.Index < n }
+	switch el.Type {
+	case ElementMeta:
+		d.Meta = Meta{}
+	case ElementRole:
+		d.Role = Block{}
+	case ElementOutputSchema:
+		d.Schema = OutputSchema{}
+	case ElementTask:
+		if del(len(d.Tasks)) {
+			d.Tasks = deleteAt(d.Tasks, el.Index)
+		}
+	case ElementInput:
+		if del(len(d.Inputs)) {
+			d.Inputs = deleteAt(d.Inputs, el.Index)
+		}
+	case ElementDocument:
+		if del(len(d.Documents)) {
+			d.Documents = deleteAt(d.Documents, el.Index)
+		}
+	case ElementStyle:
+		if del(len(d.Styles)) {
+			d.Styles = deleteAt(d.Styles, el.Index)
+		}
+	case ElementAudio:
+		if del(len(d.Audios)) {
+			d.Audios = deleteAt(d.Audios, el.Index)
+		}
+	case ElementVideo:
+		if del(len(d.Videos)) {
+			d.Videos = deleteAt(d.Videos, el.Index)
+		}
+	case ElementOutputFormat:
+		if del(len(d.OutFormats)) {
+			d.OutFormats = deleteAt(d.OutFormats, el.Index)
+		}
+	case ElementHint:
+		if del(len(d.Hints)) {
+			d.Hints = deleteAt(d.Hints, el.Index)
+		}
+	case ElementExample:
+		if del(len(d.Examples)) {
+			d.Examples = deleteAt(d.Examples, el.Index)
+		}
+	case ElementContentPart:
+		if del(len(d.ContentParts)) {
+			d.ContentParts = deleteAt(d.ContentParts, el.Index)
+		}
+	case ElementObject:
+		if del(len(d.Objects)) {
+			d.Objects = deleteAt(d.Objects, el.Index)
+		}
+	case ElementImage:
+		if del(len(d.Images)) {
+			d.Images = deleteAt(d.Images, el.Index)
+		}
+	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+		if del(len(d.Messages)) {
+			d.Messages = deleteAt(d.Messages, el.Index)
+		}
+	case ElementToolDefinition:
+		if del(len(d.ToolDefs)) {
+			d.ToolDefs = deleteAt(d.ToolDefs, el.Index)
+		}
+	case ElementToolRequest:
+		if del(len(d.ToolReqs)) {
+			d.ToolReqs = deleteAt(d.ToolReqs, el.Index)
+		}
+	case ElementToolResponse:
+		if del(len(d.ToolResps)) {
+			d.ToolResps = deleteAt(d.ToolResps, el.Index)
+		}
+	case ElementToolResult:
+		if del(len(d.ToolResults)) {
+			d.ToolResults = deleteAt(d.ToolResults, el.Index)
+		}
+	case ElementToolError:
+		if del(len(d.ToolErrors)) {
+			d.ToolErrors = deleteAt(d.ToolErrors, el.Index)
+		}
+	case ElementRuntime:
+		if del(len(d.Runtimes)) {
+			d.Runtimes = deleteAt(d.Runtimes, el.Index)
+		}
+	case ElementTrace:
+		if del(len(d.Traces)) {
+			d.Traces = deleteAt(d.Traces, el.Index)
+		}
+	case ElementDiagram:
+		if del(len(d.Diagrams)) {
+			d.Diagrams = deleteAt(d.Diagrams, el.Index)
+		}
+	}
+}
+
+// deleteAt removes s[i], returning nil for an emptied slice as a full parse leaves it.
+func deleteAt[T any](s []T, i int) []T {
+	if s = slices.Delete(s, i, i+1); len(s) == 0 {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package poml
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIncrementalParserMatchesFullParse(t *testing.T) {
+	src := `<poml>
+  <role>Analyst</role>
+  <task>Summarize the report.</task>
+  <hint>Be brief.</hint>
+  <human-msg>hello</human-msg>
+  <task>Second task.</task>
+</poml>`
+	p, err := NewIncrementalParser([]byte(src), defaultParseOptions)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	roleID := p.Document().Elements[0].ID
+
+	edits := []struct {
+		name   string
+		find   string
+		repl   string
+		full   bool
+		keepID bool
+	}{
+		{name: "edit body", find: "Summarize", repl: "Condense", keepID: true},
+		{name: "insert element", find: "<hint>", repl: "<example>e.g.</example>\n  <hint>"},
+		{name: "delete element", find: "<human-msg>hello</human-msg>", repl: ""},
+		{name: "stray text", find: "<hint>Be brief.</", repl: "<cp>c</cp>"},
+		{name: "trailing text", find: "Second task.</task>\n", repl: "Second task.</task>\n  <!-- done -->\n"},
+		{name: "duplicate role", find: "<task>Second", repl: "<role>Other</role>\n  <task>Second", full: true},
+		{name: "root attr", find: "<poml>", repl: `<poml lang="en">`, full: true},
+	}
+	for _, e := range edits {
+		cur := string(p.Source())
+		start := strings.Index(cur, e.find)
+		res, err := p.Apply(TextEdit{Start: start, End: start + len(e.find), Text: e.repl})
+		if err != nil {
+			t.Fatalf("%s: apply: %v", e.name, err)
+		}
+		if res.Full != e.full {
+			t.Fatalf("%s: full=%v, want %v", e.name, res.Full, e.full)
+		}
+		want, err := ParseString(string(p.Source()))
+		if err != nil {
+			t.Fatalf("%s: full parse: %v", e.name, err)
+		}
+		got := p.Document()
+		if g, w := withoutIDs(got), withoutIDs(want); !reflect.DeepEqual(g, w) {
+			t.Fatalf("%s: patched document differs from a full parse.\n got: %+v\nwant: %+v", e.name, g, w)
+		}
+		var gotXML, wantXML bytes.Buffer
+		if err := got.Encode(&gotXML); err != nil {
+			t.Fatalf("%s: encode: %v", e.name, err)
+		}
+		_ = want.Encode(&wantXML)
+		if gotXML.String() != wantXML.String() {
+			t.Fatalf("%s: encoded mismatch.\n got:\n%s\nwant:\n%s", e.name, gotXML.String(), wantXML.String())
+		}
+		if e.keepID && got.Tasks[0].Body != "Condense the report." {
+			t.Fatalf("%s: task body not updated: %q", e.name, got.Tasks[0].Body)
+		}
+		if !e.full && got.Elements[0].ID != roleID {
+			t.Fatalf("%s: untouched role lost its ID", e.name)
+		}
+	}
+}
+
+// withoutIDs returns a copy of d without element IDs, which a patch keeps from before the edit.
+func withoutIDs(d Document) Document {
+	d = d.Clone()
+	for i := range d.Elements {
+		d.Elements[i].ID, d.Elements[i].Parent = "", ""
+	}
+	d.nextID = 0
+	return d
+}
+
+func TestIncrementalParserRecoversFromBrokenEdits(t *testing.T) {
+	src := "<poml>\n  <task>one</task>\n  <task>two</task>\n</poml>"
+	p, err := NewIncrementalParser([]byte(src), defaultParseOptions)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	open := strings.Index(src, "<task>two")
+	if _, err := p.Apply(TextEdit{Start: open, End: open + len("<task>"), Text: "<task"}); err == nil {
+		t.Fatalf("expected error for unterminated tag")
+	}
+	if got := p.Document().Tasks; len(got) != 2 || got[1].Body != "two" {
+		t.Fatalf("previous document should be kept, got %+v", got)
+	}
+	res, err := p.Apply(TextEdit{Start: open + len("<task"), End: open + len("<task"), Text: ">"})
+	if err != nil || !res.Full {
+		t.Fatalf("expected full re-parse after a failed edit, got %+v (%v)", res, err)
+	}
+	if _, err := p.Apply(TextEdit{Start: 3, End: 1}); err == nil {
+		t.Fatalf("expected range error")
+	}
+	early := strings.Index(string(p.Source()), "<task>one</task>") + len("<task>one</task>")
+	res, err = p.Apply(TextEdit{Start: early, End: early, Text: "</poml>"})
+	if err != nil || !res.Full || len(p.Document().Tasks) != 1 {
+		t.Fatalf("expected early </poml> to force a full parse, got %+v (%v)", res, err)
+	}
+}
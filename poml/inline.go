@@ -0,0 +1,312 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// InlineKind identifies the type of an InlineNode.
+type InlineKind string
+
+const (
+	InlineText        InlineKind = "text"
+	InlineImage       InlineKind = "image"
+	InlineAudio       InlineKind = "audio"
+	InlineObject      InlineKind = "object"
+	InlineContentPart InlineKind = "content_part"
+	InlineElement     InlineKind = "element" // any other markup, kept with its children
+)
+
+// InlineNode is one node of a structured element body (see ParseOptions.ParseInlineContent).
+type InlineNode struct {
+	Kind     InlineKind
+	Text     string       // unescaped text for InlineText
+	Image    *Image       // set for InlineImage
+	Audio    *Media       // set for InlineAudio
+	Object   *ObjectTag   // set for InlineObject
+	Name     string       // tag name for InlineContentPart and InlineElement
+	Attrs    Attrs        // attributes for InlineContentPart and InlineElement
+	Children []InlineNode // nested nodes for InlineContentPart and InlineElement
+}
+
+// ParseInline parses an innerxml body into inline nodes: text, <img>, <audio>, <object>, <cp>, and other
+// markup (kept as InlineElement). Comments and processing instructions are dropped.
+func ParseInline(body string) ([]InlineNode, error) {
+	dec := xml.NewDecoder(strings.NewReader("<inline>" + body + "</inline>"))
+	dec.Strict = true
+	if _, err := dec.Token(); err != nil {
+		return nil, wrapXMLError(err, "parse inline content")
+	}
+	nodes, err := decodeInline(dec)
+	if err != nil {
+		return nil, wrapXMLError(err, "parse inline content")
+	}
+	return nodes, nil
+}
+
+func decodeInline(dec *xml.Decoder) ([]InlineNode, error) {
+	var nodes []InlineNode
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.EndElement:
+			return nodes, nil
+		case xml.CharData:
+			if n := len(nodes); n > 0 && nodes[n-1].Kind == InlineText {
+				nodes[n-1].Text += string(t)
+				continue
+			}
+			nodes = append(nodes, InlineNode{Kind: InlineText, Text: string(t)})
+		case xml.StartElement:
+			switch strings.ToLower(t.Name.Local) {
+			case "img":
+				var im Image
+				if err := dec.DecodeElement(&im, &t); err != nil {
+					return nil, err
+				}
+				nodes = append(nodes, InlineNode{Kind: InlineImage, Image: &im})
+			case "audio":
+				var au Media
+				if err := dec.DecodeElement(&au, &t); err != nil {
+					return nil, err
+				}
+				nodes = append(nodes, InlineNode{Kind: InlineAudio, Audio: &au})
+			case "object":
+				var obj ObjectTag
+				if err := dec.DecodeElement(&obj, &t); err != nil {
+					return nil, err
+				}
+				nodes = append(nodes, InlineNode{Kind: InlineObject, Object: &obj})
+			default:
+				kind := InlineElement
+				if strings.EqualFold(t.Name.Local, "cp") {
+					kind = InlineContentPart
+				}
+				children, err := decodeInline(dec)
+				if err != nil {
+					return nil, err
+				}
+				nodes = append(nodes, InlineNode{Kind: kind, Name: t.Name.Local, Attrs: t.Attr, Children: children})
+			}
+		}
+	}
+}
+
+// populateInlineContent fills the Content trees of message, hint, example, and cp payloads.
+func (d *Document) populateInlineContent() error {
+	for i := range d.Messages {
+		content, err := ParseInline(d.Messages[i].Body)
+		if err != nil {
+			return err
+		}
+		d.Messages[i].Content = content
+	}
+	for i := range d.Hints {
+		content, err := ParseInline(d.Hints[i].Body)
+		if err != nil {
+			return err
+		}
+		d.Hints[i].Content = content
+	}
+	for i := range d.Examples {
+		content, err := ParseInline(d.Examples[i].Body)
+		if err != nil {
+			return err
+		}
+		d.Examples[i].Content = content
+	}
+	for i := range d.ContentParts {
+		content, err := ParseInline(d.ContentParts[i].Body)
+		if err != nil {
+			return err
+		}
+		d.ContentParts[i].Content = content
+	}
+	return nil
+}
+
+// elementContent returns the parsed inline tree for message/hint/example/cp elements, or nil
+// when the document was parsed without ParseInlineContent. Message bodies that nest <img> or
+// <audio> are parsed on demand so converters emit one multi-part message either way.
+func (d Document) elementContent(el Element) []InlineNode {
+	switch el.Type {
+	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+		if el.Index >= 0 && el.Index < len(d.Messages) {
+			msg := d.Messages[el.Index]
+			if msg.Content == nil && hasInlineMedia(msg.Body) {
+				// A body that fails to parse falls back to the plain string, as before.
+				content, _ := ParseInline(msg.Body)
+				return content
+			}
+			return msg.Content
+		}
+	case ElementHint:
+		if el.Index >= 0 && el.Index < len(d.Hints) {
+			return d.Hints[el.Index].Content
+		}
+	case ElementExample:
+		if el.Index >= 0 && el.Index < len(d.Examples) {
+			return d.Examples[el.Index].Content
+		}
+	case ElementContentPart:
+		if el.Index >= 0 && el.Index < len(d.ContentParts) {
+			return d.ContentParts[el.Index].Content
+		}
+	}
+	return nil
+}
+
+var inlineMediaRe = regexp.MustCompile(`(?i)<(img|audio)[\s/>]`)
+
+// hasInlineMedia reports whether body nests an <img> or <audio> element.
+func hasInlineMedia(body string) bool {
+	return inlineMediaRe.MatchString(body)
+}
+
+// inlineSegment is a run of text, an image, or an audio clip, in reading order.
+type inlineSegment struct {
+	Text  string
+	Image *Image
+	Audio *Media
+}
+
+// inlineSegments flattens nodes into text runs split at images and audio. Other markup is kept
+// as XML so text matches the flattened body; objects contribute their body (or data) as a
+// separate run. ok is false when nodes contain no media, in which case callers keep the plain body.
+func inlineSegments(nodes []InlineNode) (segs []inlineSegment, ok bool) {
+	var text strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(text.String()); s != "" {
+			segs = append(segs, inlineSegment{Text: s})
+		}
+		text.Reset()
+	}
+	var walk func([]InlineNode)
+	walk = func(nodes []InlineNode) {
+		for _, n := range nodes {
+			switch n.Kind {
+			case InlineText:
+				text.WriteString(escapeBodyText(n.Text))
+			case InlineImage:
+				flush()
+				segs = append(segs, inlineSegment{Image: n.Image})
+				ok = true
+			case InlineAudio:
+				flush()
+				segs = append(segs, inlineSegment{Audio: n.Audio})
+				ok = true
+			case InlineObject:
+				flush()
+				body := strings.TrimSpace(n.Object.Body)
+				if body == "" {
+					body = strings.TrimSpace(n.Object.Data)
+				}
+				text.WriteString(body)
+				flush()
+			case InlineContentPart:
+				flush()
+				walk(n.Children)
+				flush()
+			default:
+				text.WriteString(inlineStartTag(n))
+				walk(n.Children)
+				text.WriteString("</" + n.Name + ">")
+			}
+		}
+	}
+	walk(nodes)
+	flush()
+	return segs, ok
+}
+
+func inlineStartTag(n InlineNode) string {
+	var buf bytes.Buffer
+	buf.WriteString("<" + n.Name)
+	for _, a := range n.Attrs {
+		buf.WriteString(" " + a.Name.Local + `="`)
+		_ = xml.EscapeText(&buf, []byte(a.Value))
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('>')
+	return buf.String()
+}
+
+// inlineParts converts nodes into format-specific content parts via part. ok is false when the
+// content has no media, in which case callers keep emitting the plain string body.
+func inlineParts(nodes []InlineNode, part func(inlineSegment) (any, error)) ([]any, bool, error) {
+	segs, ok := inlineSegments(nodes)
+	if !ok {
+		return nil, false, nil
+	}
+	out := make([]any, 0, len(segs))
+	for _, seg := range segs {
+		p, err := part(seg)
+		if err != nil {
+			return nil, false, err
+		}
+		out = append(out, p)
+	}
+	return out, true, nil
+}
+
+func openAIInlinePart(opts ConvertOptions) func(inlineSegment) (any, error) {
+	return func(seg inlineSegment) (any, error) {
+		if seg.Audio != nil {
+			part, err := buildMediaPart(*seg.Audio, opts)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"type": "input_audio", "audio": part}, nil
+		}
+		if seg.Image == nil {
+			return map[string]any{"type": "text", "text": seg.Text}, nil
+		}
+		src, err := resolveImageSource(*seg.Image, opts)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "image_url", "image_url": map[string]any{"url": src.dataURL()}}, nil
+	}
+}
+
+func langChainInlinePart(opts ConvertOptions) func(inlineSegment) (any, error) {
+	return func(seg inlineSegment) (any, error) {
+		if seg.Audio != nil {
+			part, err := buildMediaPart(*seg.Audio, opts)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"type": "audio", "source_type": "base64", "mime_type": part["type"], "data": part["base64"]}, nil
+		}
+		if seg.Image == nil {
+			return map[string]any{"type": "text", "text": seg.Text}, nil
+		}
+		src, err := resolveImageSource(*seg.Image, opts)
+		if err != nil {
+			return nil, err
+		}
+		return langChainImageBlock(src), nil
+	}
+}
+
+func messageDictInlinePart(opts ConvertOptions) func(inlineSegment) (any, error) {
+	return func(seg inlineSegment) (any, error) {
+		if seg.Audio != nil {
+			return buildMediaPart(*seg.Audio, opts)
+		}
+		if seg.Image == nil {
+			return seg.Text, nil
+		}
+		return buildImagePart(*seg.Image, opts)
+	}
+}
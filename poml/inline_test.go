@@ -0,0 +1,125 @@
+package poml
+
+import (
+	"strings"
+	"testing"
+)
+
+const inlineSample = `<poml>
+  <human-msg>Compare <b>these</b> charts: <img src="data:image/png;base64,` + pngData + `" alt="q1"/> and <cp caption="Notes">see <object data="x.csv" syntax="csv"/></cp></human-msg>
+  <hint>plain &amp; simple</hint>
+</poml>`
+
+func TestParseInlineContentBuildsTree(t *testing.T) {
+	doc, err := ParseReaderWithOptions(strings.NewReader(inlineSample), ParseOptions{ParseInlineContent: true})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	content := doc.Messages[0].Content
+	var kinds []string
+	for _, n := range content {
+		kinds = append(kinds, string(n.Kind))
+	}
+	if got := strings.Join(kinds, ","); got != "text,element,text,image,text,content_part" {
+		t.Fatalf("unexpected node kinds: %s", got)
+	}
+	if content[3].Image.Alt != "q1" || content[1].Name != "b" || content[1].Children[0].Text != "these" {
+		t.Fatalf("unexpected nodes: %+v", content)
+	}
+	cp := content[5]
+	if cp.Attrs[0].Value != "Notes" || cp.Children[1].Kind != InlineObject || cp.Children[1].Object.Data != "x.csv" {
+		t.Fatalf("unexpected cp node: %+v", cp)
+	}
+	if doc.Hints[0].Content[0].Text != "plain & simple" {
+		t.Fatalf("expected unescaped hint text, got %+v", doc.Hints[0].Content)
+	}
+	if _, err := ParseInline("<b>unclosed"); err == nil {
+		t.Fatalf("expected error for malformed inline content")
+	}
+}
+
+func TestConvertEmitsMultipartInlineContent(t *testing.T) {
+	plain, err := ConvertString(inlineSample, FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert plain: %v", err)
+	}
+	if parts, ok := plain.(map[string]any)["messages"].([]map[string]any)[0]["content"].([]any); !ok || len(parts) != 5 {
+		t.Fatalf("expected nested media to yield parts without ParseInlineContent, got %+v", plain)
+	}
+
+	doc, err := ParseReaderWithOptions(strings.NewReader(inlineSample), ParseOptions{ParseInlineContent: true})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out, err := Convert(doc, FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	msgs := out.(map[string]any)["messages"].([]map[string]any)
+	parts := msgs[0]["content"].([]any)
+	if len(parts) != 5 {
+		t.Fatalf("expected 5 parts, got %+v", parts)
+	}
+	if parts[0].(map[string]any)["text"] != "Compare <b>these</b> charts:" || parts[4].(map[string]any)["text"] != "x.csv" {
+		t.Fatalf("unexpected text parts: %+v", parts)
+	}
+	if url := parts[1].(map[string]any)["image_url"].(map[string]any)["url"]; url != "data:image/png;base64,"+pngData {
+		t.Fatalf("unexpected image part: %v", url)
+	}
+	if msgs[1]["content"] != "plain &amp; simple" {
+		t.Fatalf("image-free content should stay a string, got %+v", msgs[1])
+	}
+
+	lc, err := Convert(doc, FormatLangChain, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert langchain: %v", err)
+	}
+	lcParts := lc.(map[string]any)["messages"].([]map[string]any)[0]["data"].(map[string]any)["content"].([]any)
+	if lcParts[1].(map[string]any)["type"] != "image" {
+		t.Fatalf("expected langchain image part, got %+v", lcParts)
+	}
+	br, err := Convert(doc, FormatBedrockConverse, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert bedrock: %v", err)
+	}
+	blocks := br.(map[string]any)["messages"].([]map[string]any)[0]["content"].([]any)
+	if _, ok := blocks[1].(map[string]any)["image"]; !ok || len(blocks) != 6 {
+		t.Fatalf("unexpected bedrock blocks: %+v", blocks)
+	}
+}
+
+func TestMessageNestedAudioIsOneMultipartMessage(t *testing.T) {
+	src := `<poml>
+  <human-msg>Transcribe this: <audio src="data:audio/wav;base64,UklGRg==" syntax="audio/wav"/> then <img src="data:image/png;base64,` + pngData + `"/></human-msg>
+</poml>`
+	out, err := ConvertString(src, FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	msgs := out.(map[string]any)["messages"].([]map[string]any)
+	if len(msgs) != 1 || msgs[0]["role"] != "user" {
+		t.Fatalf("expected a single user message, got %+v", msgs)
+	}
+	parts := msgs[0]["content"].([]any)
+	var types []string
+	for _, p := range parts {
+		types = append(types, p.(map[string]any)["type"].(string))
+	}
+	if got := strings.Join(types, ","); got != "text,input_audio,text,image_url" {
+		t.Fatalf("unexpected part types: %s", got)
+	}
+	if audio := parts[1].(map[string]any)["audio"].(map[string]any); audio["base64"] != "UklGRg==" || audio["type"] != "audio/wav" {
+		t.Fatalf("unexpected audio part: %+v", audio)
+	}
+
+	dict, err := ConvertString(src, FormatMessageDict, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert message_dict: %v", err)
+	}
+	if content := dict.([]messageDict)[0].Content.([]any); len(content) != 4 || content[0] != "Transcribe this:" {
+		t.Fatalf("unexpected message_dict content: %+v", content)
+	}
+	if _, err := ConvertString(src, FormatBedrockConverse, ConvertOptions{}); err == nil || !strings.Contains(err.Error(), "audio") {
+		t.Fatalf("expected bedrock to reject nested audio, got %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package poml
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// MissingInputError lists the inputs ConvertOptions.Inputs left without a value.
+type MissingInputError struct {
+	Required   []string // required <input> elements with neither a bound value nor a body
+	Referenced []string // names in {{inputs.NAME}} references with no value (StrictInputs only)
+}
+
+func (e *MissingInputError) Error() string {
+	var parts []string
+	if len(e.Required) > 0 {
+		parts = append(parts, "required "+strings.Join(e.Required, ", "))
+	}
+	if len(e.Referenced) > 0 {
+		parts = append(parts, "referenced "+strings.Join(e.Referenced, ", "))
+	}
+	return "missing inputs: " + strings.Join(parts, "; ")
+}
+
+// inputRefRe matches {{inputs.NAME}} and {{ inputs.NAME.field }} references.
+var inputRefRe = regexp.MustCompile(`\{\{\s*inputs\.([A-Za-z_][\w-]*(?:\.[\w-]+)*)\s*\}\}`)
+
+// bindInputs fills d's inputs from opts.Inputs and substitutes {{inputs.NAME}} references in
+// element bodies. d must be a copy owned by the caller.
+func (d *Document) bindInputs(opts ConvertOptions) error {
+	values := make(map[string]any, len(d.Inputs)+len(opts.Inputs))
+	var missing MissingInputError
+	for i, in := range d.Inputs {
+		if v, ok := opts.Inputs[in.Name]; ok {
+			d.Inputs[i].Body = escapeBodyText(inputText(v))
+			values[in.Name] = v
+			continue
+		}
+		if text := strings.TrimSpace(plainText(in.Body)); text != "" {
+			values[in.Name] = text
+		} else if in.Required {
+			missing.Required = append(missing.Required, in.Name)
+		}
+	}
+	for name, v := range opts.Inputs {
+		if _, ok := values[name]; !ok {
+			values[name] = v
+		}
+	}
+	substitute := func(body string) string {
+		return inputRefRe.ReplaceAllStringFunc(body, func(ref string) string {
+			path := strings.Split(inputRefRe.FindStringSubmatch(ref)[1], ".")
+			v, ok := lookupInput(values, path)
+			if !ok {
+				if name := strings.Join(path, "."); !slices.Contains(missing.Referenced, name) {
+					missing.Referenced = append(missing.Referenced, name)
+				}
+				return ref
+			}
+			return escapeBodyText(inputText(v))
+		})
+	}
+	for _, el := range d.resolveOrder() {
+		if el.Type == ElementInput {
+			continue
+		}
+		if body := d.bodyRef(el); body != nil && strings.Contains(*body, "{{") {
+			*body = substitute(*body)
+			d.refreshContent(el)
+		}
+		switch el.Type {
+		case ElementStyle:
+			for i := range d.Styles[el.Index].Outputs {
+				out := &d.Styles[el.Index].Outputs[i]
+				out.Body = substitute(out.Body)
+			}
+		case ElementExample:
+			if pair := d.Examples[el.Index].Pair; pair != nil {
+				bound := ExamplePair{Input: substitute(pair.Input), Output: substitute(pair.Output)}
+				d.Examples[el.Index].Pair = &bound
+			}
+		}
+	}
+	if len(missing.Required) > 0 || (opts.StrictInputs && len(missing.Referenced) > 0) {
+		if !opts.StrictInputs {
+			missing.Referenced = nil
+		}
+		return &POMLError{Type: ErrValidate, Code: CodeInputMissing, Message: "bind inputs", Err: &missing}
+	}
+	return nil
+}
+
+// templateExprRe matches a {{ ... }} expression; exprNameRe matches the names inside one that
+// can refer to an input: inputs.NAME, or a bare identifier.
+var (
+	templateExprRe = regexp.MustCompile(`(?s)\{\{(.*?)\}\}`)
+	exprNameRe     = regexp.MustCompile(`inputs\s*\.\s*([A-Za-z_][\w-]*)|([A-Za-z_]\w*)`)
+)
+
+// validateInputRefs reports inputs no {{ }} expression in a body refers to, by inputs.NAME or
+// by bare name, and {{inputs.NAME}} references that match no input.
+func (d Document) validateInputRefs(issues *[]string, details *[]ValidationDetail) {
+	declared := make(map[string]bool, len(d.Inputs))
+	for _, in := range d.Inputs {
+		declared[in.Name] = true
+	}
+	used := map[string]bool{}
+	var inputs []Element
+	for _, el := range d.resolveOrder() {
+		if el.Type == ElementInput {
+			inputs = append(inputs, el)
+			continue
+		}
+		var bodies []string
+		if body := d.bodyRef(el); body != nil {
+			bodies = append(bodies, *body)
+		}
+		switch el.Type {
+		case ElementUnknown:
+			bodies = append(bodies, el.RawXML)
+		case ElementStyle:
+			for _, out := range d.Styles[el.Index].Outputs {
+				bodies = append(bodies, out.Body)
+			}
+		case ElementExample:
+			if pair := d.Examples[el.Index].Pair; pair != nil {
+				bodies = append(bodies, pair.Input, pair.Output)
+			}
+		}
+		var undefined []string
+		for _, body := range bodies {
+			if !strings.Contains(body, "{{") {
+				continue
+			}
+			for _, expr := range templateExprRe.FindAllStringSubmatch(body, -1) {
+				for _, m := range exprNameRe.FindAllStringSubmatchIndex(expr[1], -1) {
+					if m[0] > 0 && expr[1][m[0]-1] == '.' {
+						continue
+					}
+					used[expr[1][max(m[2], m[4]):max(m[3], m[5])]] = true
+				}
+			}
+			for _, ref := range inputRefRe.FindAllStringSubmatch(body, -1) {
+				name, _, _ := strings.Cut(ref[1], ".")
+				if !declared[name] && !slices.Contains(undefined, name) {
+					undefined = append(undefined, name)
+				}
+			}
+		}
+		for _, name := range undefined {
+			*issues = append(*issues, fmt.Sprintf("%s (%s) references undefined input %q", el.Type, el.ID, name))
+			*details = append(*details, ValidationDetail{Code: CodeInputUndefined, Element: el.Type, ElementID: el.ID, Field: "body", Message: "undefined input " + name})
+		}
+	}
+	for _, el := range inputs {
+		if el.Index < 0 || el.Index >= len(d.Inputs) {
+			continue
+		}
+		name := d.Inputs[el.Index].Name
+		if name == "" || used[name] {
+			continue
+		}
+		*issues = append(*issues, fmt.Sprintf("input %q is never referenced", name))
+		*details = append(*details, ValidationDetail{Code: CodeInputUnused, Element: ElementInput, ElementID: el.ID, Field: "name", Message: "unused input " + name})
+	}
+}
+
+// refreshContent re-parses the inline content of el after its body changed.
+func (d *Document) refreshContent(el Element) {
+	switch el.Type {
+	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+		if msg := &d.Messages[el.Index]; msg.Content != nil {
+			msg.Content, _ = ParseInline(msg.Body)
+		}
+	case ElementHint:
+		if h := &d.Hints[el.Index]; h.Content != nil {
+			h.Content, _ = ParseInline(h.Body)
+		}
+	case ElementExample:
+		if ex := &d.Examples[el.Index]; ex.Content != nil {
+			ex.Content, _ = ParseInline(ex.Body)
+		}
+	case ElementContentPart:
+		if cp := &d.ContentParts[el.Index]; cp.Content != nil {
+			cp.Content, _ = ParseInline(cp.Body)
+		}
+	}
+}
+
+// lookupInput resolves a reference path: the input name, then keys into map values.
+func lookupInput(values map[string]any, path []string) (any, bool) {
+	v, ok := values[path[0]]
+	for _, key := range path[1:] {
+		if !ok {
+			break
+		}
+		m, isMap := v.(map[string]any)
+		if !isMap {
+			return nil, false
+		}
+		v, ok = m[key]
+	}
+	return v, ok
+}
+
+// inputText formats a bound value as text: strings and fmt.Stringers as themselves, numbers
+// and booleans with fmt, and everything else as JSON.
+func inputText(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case fmt.Stringer:
+		return val.String()
+	case nil:
+		return ""
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(val)
+	}
+	if data, err := json.Marshal(v); err == nil {
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
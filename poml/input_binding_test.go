@@ -0,0 +1,136 @@
+package poml
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const inputSample = `<poml>
+  <task>Summarize the ticket for {{ inputs.user.name }}.</task>
+  <input name="status" required="true"/>
+  <input name="tone">friendly</input>
+  <human-msg>Status is {{inputs.status}}; keep it {{inputs.tone}}. Limit: {{inputs.limit}}.</human-msg>
+</poml>`
+
+func TestConvertBindsInputs(t *testing.T) {
+	doc, err := ParseString(inputSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	opts := ConvertOptions{
+		Inputs: map[string]any{
+			"status": "open & <urgent>",
+			"limit":  3,
+			"user":   map[string]any{"name": "Ada"},
+		},
+		SystemPrompt: SystemPromptOptions{Enabled: true},
+	}
+	out, err := Convert(doc, FormatOpenAIChat, opts)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	msgs := out.(map[string]any)["messages"].([]map[string]any)
+	if sys := msgs[0]["content"].(string); !strings.Contains(sys, "Summarize the ticket for Ada.") || !strings.Contains(sys, "Input (status):\nopen & <urgent>") {
+		t.Fatalf("system prompt not bound: %q", sys)
+	}
+	if got := msgs[1]["content"]; got != "Status is open &amp; &lt;urgent&gt;; keep it friendly. Limit: 3." {
+		t.Fatalf("message not bound: %q", got)
+	}
+	if !strings.Contains(doc.Messages[0].Body, "{{inputs.status}}") || doc.Inputs[0].Body != "" {
+		t.Fatalf("Convert modified the caller's document")
+	}
+
+	// Without Inputs nothing is bound.
+	out, err = Convert(doc, FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert unbound: %v", err)
+	}
+	if got := out.(map[string]any)["messages"].([]map[string]any)[0]["content"]; !strings.Contains(got.(string), "{{inputs.status}}") {
+		t.Fatalf("expected references left alone, got %q", got)
+	}
+}
+
+func TestConvertReportsMissingInputs(t *testing.T) {
+	doc, err := ParseString(inputSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	_, err = Convert(doc, FormatOpenAIChat, ConvertOptions{Inputs: map[string]any{}})
+	var missing *MissingInputError
+	if !errors.Is(err, CodeInputMissing) || !errors.As(err, &missing) {
+		t.Fatalf("expected %s, got %v", CodeInputMissing, err)
+	}
+	if !reflect.DeepEqual(missing.Required, []string{"status"}) || missing.Referenced != nil {
+		t.Fatalf("unexpected missing inputs: %+v", missing)
+	}
+
+	_, err = Convert(doc, FormatOpenAIChat, ConvertOptions{Inputs: map[string]any{"status": "open"}, StrictInputs: true})
+	if !errors.As(err, &missing) || !reflect.DeepEqual(missing.Referenced, []string{"user.name", "limit"}) || missing.Required != nil {
+		t.Fatalf("expected unfilled references reported, got %v", err)
+	}
+	if got := err.Error(); !strings.Contains(got, "missing inputs: referenced user.name, limit") {
+		t.Fatalf("unexpected message: %s", got)
+	}
+}
+
+func TestValidateReportsInputReferences(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <meta><id>refs</id><version>1</version><owner>me</owner></meta>
+  <role>r</role>
+  <task>Greet {{ user.name }} in {{inputs.lang}}; mention {{inputs.plan.tier}}.</task>
+  <input name="user"/>
+  <input name="lang"/>
+  <input name="stale"/>
+  <hint>{{ stale_copy }} and {{ inputs.missing }}</hint>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	_, err = doc.ValidateWithOptions(ValidateOptions{FailOn: SeverityWarning})
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	want := map[string]ValidationDetail{
+		"plan":    {Code: CodeInputUndefined, Element: ElementTask, ElementID: doc.Elements[2].ID, Field: "body", Message: "undefined input plan", Severity: SeverityError},
+		"missing": {Code: CodeInputUndefined, Element: ElementHint, ElementID: doc.Elements[6].ID, Field: "body", Message: "undefined input missing", Severity: SeverityError},
+		"stale":   {Code: CodeInputUnused, Element: ElementInput, ElementID: doc.Elements[5].ID, Field: "name", Message: "unused input stale", Severity: SeverityWarning},
+	}
+	if len(ve.Details) != len(want) {
+		t.Fatalf("unexpected issues: %v", ve.Issues)
+	}
+	for _, d := range ve.Details {
+		name := d.Message[strings.LastIndex(d.Message, " ")+1:]
+		if d != want[name] {
+			t.Fatalf("unexpected detail for %s: %+v", name, d)
+		}
+	}
+	if AllowCodes(err, CodeInputUnused, CodeInputUndefined) != nil {
+		t.Fatalf("expected the reference checks to be allow-listable")
+	}
+}
+
+func TestUnusedInputIsWarning(t *testing.T) {
+	src := `<poml>
+  <meta><id>unused</id><version>1</version><owner>me</owner></meta>
+  <role>r</role>
+  <task>Summarize the ticket.</task>
+  <input name="ticket">body</input>
+</poml>`
+	doc, err := ParseStringStrict(src)
+	if err != nil {
+		t.Fatalf("an unused input should not fail strict parsing: %v", err)
+	}
+	warnings, err := doc.ValidateWithOptions(ValidateOptions{})
+	if err != nil || warnings == nil || len(warnings.Details) != 1 {
+		t.Fatalf("want one warning and no error, got %v / %v", warnings, err)
+	}
+	if det := warnings.Details[0]; det.Code != CodeInputUnused || det.Severity != SeverityWarning || det.ElementID != doc.Elements[3].ID {
+		t.Fatalf("warning = %+v", det)
+	}
+	if _, err := doc.ValidateWithOptions(ValidateOptions{FailOn: SeverityWarning}); !errors.Is(err, CodeInputUnused) {
+		t.Fatalf("FailOn warning should fail on the unused input, got %v", err)
+	}
+}
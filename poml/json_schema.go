@@ -0,0 +1,690 @@
+package poml
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// jsonSchema validates decoded JSON (numbers as json.Number) against a JSON Schema written for
+// draft 2020-12, 2019-09, draft-07, draft-06, or draft-04, limited to these keywords:
+//
+//   - any value: type, enum, const, allOf, anyOf, oneOf, not, if/then/else, and $ref to a JSON
+//     Pointer within the same schema ("#", "#/$defs/item"), with $defs or definitions;
+//   - numbers: multipleOf, minimum, maximum, exclusiveMinimum, exclusiveMaximum (as numbers, or
+//     as draft-04 booleans);
+//   - strings: minLength, maxLength, pattern, and format (see jsonFormats);
+//   - arrays: items, prefixItems, additionalItems, contains, minContains, maxContains, minItems,
+//     maxItems, uniqueItems;
+//   - objects: properties, patternProperties, additionalProperties, propertyNames, required,
+//     minProperties, maxProperties, dependentRequired, dependentSchemas, dependencies.
+//
+// $schema and $id (on the root only), $comment, title, description, default, examples,
+// deprecated, readOnly, writeOnly, contentMediaType, and contentEncoding are annotations and
+// are accepted without effect. Any other keyword, such as unevaluatedProperties, $anchor, or
+// $dynamicRef, fails compileJSONSchema rather than being skipped, so a schema never validates
+// less than it says. pattern uses Go's RE2 syntax, which lacks lookaround and backreferences.
+type jsonSchema struct {
+	root     any
+	patterns map[string]*regexp.Regexp
+	depth    int
+}
+
+// schemaViolation is one failed assertion.
+type schemaViolation struct {
+	Pointer string // JSON Pointer to the offending value; "" is the whole document
+	Message string
+}
+
+// String returns the violation as "pointer: message".
+func (v schemaViolation) String() string { return displayPointer(v.Pointer) + ": " + v.Message }
+
+// maxSchemaDepth bounds nested schema applications, so "$ref": "#" inside itself fails rather
+// than recursing forever.
+const maxSchemaDepth = 512
+
+// jsonSchemaKeywords lists the keywords jsonSchema implements.
+var jsonSchemaKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true, "allOf": true, "anyOf": true, "oneOf": true,
+	"not": true, "if": true, "then": true, "else": true, "$ref": true, "$defs": true,
+	"definitions": true, "multipleOf": true, "minimum": true, "maximum": true,
+	"exclusiveMinimum": true, "exclusiveMaximum": true, "minLength": true, "maxLength": true,
+	"pattern": true, "format": true, "items": true, "prefixItems": true, "additionalItems": true,
+	"contains": true, "minContains": true, "maxContains": true, "minItems": true,
+	"maxItems": true, "uniqueItems": true, "properties": true, "patternProperties": true,
+	"additionalProperties": true, "propertyNames": true, "required": true,
+	"minProperties": true, "maxProperties": true, "dependentRequired": true,
+	"dependentSchemas": true, "dependencies": true,
+	// annotations
+	"$comment": true, "title": true, "description": true, "default": true, "examples": true,
+	"deprecated": true, "readOnly": true, "writeOnly": true, "contentMediaType": true,
+	"contentEncoding": true,
+}
+
+// jsonSchemaDrafts lists the $schema URIs whose keywords jsonSchema understands.
+var jsonSchemaDrafts = map[string]bool{
+	"https://json-schema.org/draft/2020-12/schema": true,
+	"https://json-schema.org/draft/2019-09/schema": true,
+	"http://json-schema.org/draft-07/schema":       true,
+	"http://json-schema.org/draft-06/schema":       true,
+	"http://json-schema.org/draft-04/schema":       true,
+}
+
+// jsonFormats holds the format checks jsonSchema asserts; other formats fail compileJSONSchema.
+var jsonFormats = map[string]func(string) bool{
+	"date-time": func(s string) bool {
+		_, err := time.Parse(time.RFC3339Nano, strings.ToUpper(s))
+		return err == nil
+	},
+	"date": func(s string) bool {
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	},
+	"time": func(s string) bool {
+		_, err := time.Parse("15:04:05Z07:00", strings.ToUpper(s))
+		return err == nil
+	},
+	"email": func(s string) bool {
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	},
+	"hostname": validHostname,
+	"ipv4": func(s string) bool {
+		addr, err := netip.ParseAddr(s)
+		return err == nil && addr.Is4()
+	},
+	"ipv6": func(s string) bool {
+		addr, err := netip.ParseAddr(s)
+		return err == nil && addr.Is6() && addr.Zone() == ""
+	},
+	"uri": func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.IsAbs() && !strings.ContainsAny(s, " \t\n")
+	},
+	"uri-reference": func(s string) bool {
+		_, err := url.Parse(s)
+		return err == nil && !strings.ContainsAny(s, " \t\n")
+	},
+	"uuid": regexp.MustCompile(`^[0-9a-fA-F]{8}(-[0-9a-fA-F]{4}){3}-[0-9a-fA-F]{12}$`).MatchString,
+	"regex": func(s string) bool {
+		_, err := regexp.Compile(s)
+		return err == nil
+	},
+	"json-pointer": func(s string) bool {
+		if s != "" && s[0] != '/' {
+			return false
+		}
+		for i := strings.IndexByte(s, '~'); i >= 0; i = strings.IndexByte(s, '~') {
+			if i+1 == len(s) || (s[i+1] != '0' && s[i+1] != '1') {
+				return false
+			}
+			s = s[i+2:]
+		}
+		return true
+	},
+}
+
+// validHostname reports whether s is a DNS name of 1-63 character labels of letters, digits,
+// and inner hyphens, at most 253 characters long.
+func validHostname(s string) bool {
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(s, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compileJSONSchema checks that root is a schema (an object or boolean) that uses only the
+// supported keywords and formats, whose patterns compile, and whose references resolve.
+func compileJSONSchema(root any) (*jsonSchema, error) {
+	s := &jsonSchema{root: root, patterns: map[string]*regexp.Regexp{}}
+	if err := s.check(root, ""); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// check walks the subschemas of sch, found at the schema pointer at.
+func (s *jsonSchema) check(sch any, at string) error {
+	if _, ok := sch.(bool); ok {
+		return nil
+	}
+	m, ok := sch.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s: schema must be an object or boolean", displayPointer(at))
+	}
+	for _, kw := range sortedKeys(m) {
+		switch {
+		case (kw == "$schema" || kw == "$id") && at != "":
+			return fmt.Errorf("%s/%s: %s is only supported on the root schema", at, kw, kw)
+		case kw == "$schema":
+			uri, _ := m[kw].(string)
+			if !jsonSchemaDrafts[strings.TrimSuffix(uri, "#")] {
+				return fmt.Errorf("/$schema: unsupported JSON Schema draft %q", uri)
+			}
+		case kw == "$id":
+		case !jsonSchemaKeywords[kw]:
+			return fmt.Errorf("%s/%s: unsupported keyword %q", at, escapePointer(kw), kw)
+		}
+	}
+	if f, ok := m["format"].(string); ok && jsonFormats[f] == nil {
+		return fmt.Errorf("%s/format: unsupported format %q", at, f)
+	}
+	if p, ok := m["pattern"].(string); ok {
+		if err := s.compile(p); err != nil {
+			return fmt.Errorf("%s/pattern: %w", at, err)
+		}
+	}
+	if ref, ok := m["$ref"].(string); ok {
+		if _, err := s.resolve(ref); err != nil {
+			return fmt.Errorf("%s/$ref: %w", at, err)
+		}
+	}
+	for _, kw := range []string{"not", "if", "then", "else", "additionalItems", "additionalProperties", "contains", "propertyNames"} {
+		if sub, ok := m[kw]; ok {
+			if err := s.check(sub, at+"/"+kw); err != nil {
+				return err
+			}
+		}
+	}
+	for _, kw := range []string{"allOf", "anyOf", "oneOf", "prefixItems", "items"} {
+		list, ok := m[kw].([]any)
+		if !ok {
+			continue
+		}
+		for i, sub := range list {
+			if err := s.check(sub, fmt.Sprintf("%s/%s/%d", at, kw, i)); err != nil {
+				return err
+			}
+		}
+	}
+	if items, ok := m["items"]; ok {
+		if _, tuple := items.([]any); !tuple {
+			if err := s.check(items, at+"/items"); err != nil {
+				return err
+			}
+		}
+	}
+	for _, kw := range []string{"properties", "patternProperties", "$defs", "definitions", "dependentSchemas", "dependencies"} {
+		subs, ok := m[kw].(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, name := range sortedKeys(subs) {
+			if kw == "patternProperties" {
+				if err := s.compile(name); err != nil {
+					return fmt.Errorf("%s/%s: %w", at, kw, err)
+				}
+			}
+			if _, names := subs[name].([]any); names && kw == "dependencies" {
+				continue
+			}
+			if err := s.check(subs[name], at+"/"+kw+"/"+escapePointer(name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *jsonSchema) compile(pattern string) error {
+	if _, ok := s.patterns[pattern]; ok {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("pattern %q: %w", pattern, err)
+	}
+	s.patterns[pattern] = re
+	return nil
+}
+
+// resolve returns the subschema a local $ref names.
+func (s *jsonSchema) resolve(ref string) (any, error) {
+	frag, ok := strings.CutPrefix(ref, "#")
+	if !ok || (frag != "" && !strings.HasPrefix(frag, "/")) {
+		return nil, fmt.Errorf("unsupported $ref %q (only #/... references within the schema)", ref)
+	}
+	cur := s.root
+	if frag == "" {
+		return cur, nil
+	}
+	for _, tok := range strings.Split(frag[1:], "/") {
+		tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+		switch c := cur.(type) {
+		case map[string]any:
+			next, ok := c[tok]
+			if !ok {
+				return nil, fmt.Errorf("$ref %q does not resolve", ref)
+			}
+			cur = next
+		case []any:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, fmt.Errorf("$ref %q does not resolve", ref)
+			}
+			cur = c[i]
+		default:
+			return nil, fmt.Errorf("$ref %q does not resolve", ref)
+		}
+	}
+	return cur, nil
+}
+
+// Validate returns the violations of v, in document order.
+func (s *jsonSchema) Validate(v any) []schemaViolation {
+	var out []schemaViolation
+	s.validate(s.root, v, "", &out)
+	return out
+}
+
+// valid reports whether v satisfies sch without recording violations.
+func (s *jsonSchema) valid(sch, v any, ptr string) bool {
+	var out []schemaViolation
+	s.validate(sch, v, ptr, &out)
+	return len(out) == 0
+}
+
+func (s *jsonSchema) validate(sch, v any, ptr string, out *[]schemaViolation) {
+	fail := func(format string, args ...any) {
+		*out = append(*out, schemaViolation{Pointer: ptr, Message: fmt.Sprintf(format, args...)})
+	}
+	if b, ok := sch.(bool); ok {
+		if !b {
+			fail("no value is allowed here")
+		}
+		return
+	}
+	m, _ := sch.(map[string]any)
+	if s.depth >= maxSchemaDepth {
+		fail("schema nests too deeply (recursive $ref?)")
+		return
+	}
+	s.depth++
+	defer func() { s.depth-- }()
+
+	if ref, ok := m["$ref"].(string); ok {
+		target, _ := s.resolve(ref) // compileJSONSchema resolved it already
+		s.validate(target, v, ptr, out)
+	}
+	if t, ok := m["type"]; ok && !typeMatches(t, v) {
+		fail("got %s, want %s", jsonTypeOf(v), typeNames(t))
+		return
+	}
+	if enum, ok := m["enum"].([]any); ok && !containsJSON(enum, v) {
+		fail("value %s is not one of %s", compactJSON(v), compactJSON(enum))
+	}
+	if c, ok := m["const"]; ok && !equalJSON(c, v) {
+		fail("value %s is not %s", compactJSON(v), compactJSON(c))
+	}
+	switch v := v.(type) {
+	case json.Number:
+		s.validateNumber(m, v, fail)
+	case string:
+		s.validateString(m, v, fail)
+	case []any:
+		s.validateArray(m, v, ptr, out, fail)
+	case map[string]any:
+		s.validateObject(m, v, ptr, out, fail)
+	}
+
+	if all, ok := m["allOf"].([]any); ok {
+		for _, sub := range all {
+			s.validate(sub, v, ptr, out)
+		}
+	}
+	if anyOf, ok := m["anyOf"].([]any); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if s.valid(sub, v, ptr) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("matches none of the anyOf schemas")
+		}
+	}
+	if oneOf, ok := m["oneOf"].([]any); ok {
+		n := 0
+		for _, sub := range oneOf {
+			if s.valid(sub, v, ptr) {
+				n++
+			}
+		}
+		if n != 1 {
+			fail("matches %d of the oneOf schemas, want exactly 1", n)
+		}
+	}
+	if not, ok := m["not"]; ok && s.valid(not, v, ptr) {
+		fail("matches the schema under not")
+	}
+	if cond, ok := m["if"]; ok {
+		if s.valid(cond, v, ptr) {
+			if then, ok := m["then"]; ok {
+				s.validate(then, v, ptr, out)
+			}
+		} else if els, ok := m["else"]; ok {
+			s.validate(els, v, ptr, out)
+		}
+	}
+}
+
+func (s *jsonSchema) validateNumber(m map[string]any, v json.Number, fail func(string, ...any)) {
+	n, ok := ratOf(v)
+	if !ok {
+		return
+	}
+	if div, ok := ratOf(m["multipleOf"]); ok && div.Sign() > 0 {
+		if !new(big.Rat).Quo(n, div).IsInt() {
+			fail("%s is not a multiple of %s", v, m["multipleOf"])
+		}
+	}
+	bound := func(kw string, lower, exclusive bool) {
+		b, ok := ratOf(m[kw])
+		if !ok {
+			return
+		}
+		c, beyond, within := n.Cmp(b), "above", "below"
+		if lower {
+			c, beyond, within = -c, "below", "above"
+		}
+		switch {
+		case c > 0:
+			fail("%s is %s %s %s", v, beyond, kw, m[kw])
+		case c == 0 && exclusive:
+			fail("%s is not %s %s %s", v, within, kw, m[kw])
+		}
+	}
+	// Draft 4 writes exclusiveMinimum and exclusiveMaximum as booleans modifying minimum and
+	// maximum; later drafts write them as numbers of their own.
+	exMin, _ := m["exclusiveMinimum"].(bool)
+	exMax, _ := m["exclusiveMaximum"].(bool)
+	bound("minimum", true, exMin)
+	bound("maximum", false, exMax)
+	bound("exclusiveMinimum", true, true)
+	bound("exclusiveMaximum", false, true)
+}
+
+func (s *jsonSchema) validateString(m map[string]any, v string, fail func(string, ...any)) {
+	n := utf8.RuneCountInString(v)
+	if limit, ok := intOf(m["minLength"]); ok && n < limit {
+		fail("string has %d characters, want at least %d", n, limit)
+	}
+	if limit, ok := intOf(m["maxLength"]); ok && n > limit {
+		fail("string has %d characters, want at most %d", n, limit)
+	}
+	if p, ok := m["pattern"].(string); ok && !s.patterns[p].MatchString(v) {
+		fail("string %q does not match pattern %q", v, p)
+	}
+	if f, ok := m["format"].(string); ok && !jsonFormats[f](v) {
+		fail("string %q is not a valid %s", v, f)
+	}
+}
+
+func (s *jsonSchema) validateArray(m map[string]any, v []any, ptr string, out *[]schemaViolation, fail func(string, ...any)) {
+	if limit, ok := intOf(m["minItems"]); ok && len(v) < limit {
+		fail("array has %d items, want at least %d", len(v), limit)
+	}
+	if limit, ok := intOf(m["maxItems"]); ok && len(v) > limit {
+		fail("array has %d items, want at most %d", len(v), limit)
+	}
+	if unique, _ := m["uniqueItems"].(bool); unique {
+	dup:
+		for i := range v {
+			for j := i + 1; j < len(v); j++ {
+				if equalJSON(v[i], v[j]) {
+					fail("items %d and %d are equal, want unique items", i, j)
+					break dup
+				}
+			}
+		}
+	}
+	// prefixItems (2020-12) or an items array (draft-07) describes the leading items, and items
+	// (2020-12) or additionalItems (draft-07) the rest.
+	prefix, _ := m["prefixItems"].([]any)
+	rest, hasRest := m["items"]
+	if tuple, ok := rest.([]any); ok {
+		prefix = tuple
+		rest, hasRest = m["additionalItems"]
+	}
+	for i, item := range v {
+		itemPtr := ptr + "/" + strconv.Itoa(i)
+		switch {
+		case i < len(prefix):
+			s.validate(prefix[i], item, itemPtr, out)
+		case hasRest:
+			s.validate(rest, item, itemPtr, out)
+		}
+	}
+	if contains, ok := m["contains"]; ok {
+		n := 0
+		for i, item := range v {
+			if s.valid(contains, item, ptr+"/"+strconv.Itoa(i)) {
+				n++
+			}
+		}
+		least, ok := intOf(m["minContains"])
+		if !ok {
+			least = 1
+		}
+		if n < least {
+			fail("array has %d items matching contains, want at least %d", n, least)
+		}
+		if most, ok := intOf(m["maxContains"]); ok && n > most {
+			fail("array has %d items matching contains, want at most %d", n, most)
+		}
+	}
+}
+
+func (s *jsonSchema) validateObject(m map[string]any, v map[string]any, ptr string, out *[]schemaViolation, fail func(string, ...any)) {
+	if limit, ok := intOf(m["minProperties"]); ok && len(v) < limit {
+		fail("object has %d properties, want at least %d", len(v), limit)
+	}
+	if limit, ok := intOf(m["maxProperties"]); ok && len(v) > limit {
+		fail("object has %d properties, want at most %d", len(v), limit)
+	}
+	require := func(names []any, why string) {
+		for _, n := range names {
+			name, _ := n.(string)
+			if _, ok := v[name]; !ok {
+				*out = append(*out, schemaViolation{Pointer: ptr + "/" + escapePointer(name), Message: "required property is missing" + why})
+			}
+		}
+	}
+	required, _ := m["required"].([]any)
+	require(required, "")
+	props, _ := m["properties"].(map[string]any)
+	patternProps, _ := m["patternProperties"].(map[string]any)
+	extra, hasExtra := m["additionalProperties"]
+	deps, _ := m["dependencies"].(map[string]any)
+	depRequired, _ := m["dependentRequired"].(map[string]any)
+	depSchemas, _ := m["dependentSchemas"].(map[string]any)
+	names, hasNames := m["propertyNames"]
+	for _, name := range sortedKeys(v) {
+		val, propPtr := v[name], ptr+"/"+escapePointer(name)
+		if hasNames && !s.valid(names, name, propPtr) {
+			*out = append(*out, schemaViolation{Pointer: propPtr, Message: fmt.Sprintf("property name %q is not allowed by propertyNames", name)})
+		}
+		matched := false
+		if sub, ok := props[name]; ok {
+			matched = true
+			s.validate(sub, val, propPtr, out)
+		}
+		for _, p := range sortedKeys(patternProps) {
+			if s.patterns[p].MatchString(name) {
+				matched = true
+				s.validate(patternProps[p], val, propPtr, out)
+			}
+		}
+		if !matched && hasExtra {
+			if allowed, ok := extra.(bool); ok && !allowed {
+				*out = append(*out, schemaViolation{Pointer: propPtr, Message: "property is not allowed"})
+			} else {
+				s.validate(extra, val, propPtr, out)
+			}
+		}
+		why := fmt.Sprintf(" (required when %q is present)", name)
+		if list, ok := depRequired[name].([]any); ok {
+			require(list, why)
+		}
+		if sub, ok := depSchemas[name]; ok {
+			s.validate(sub, v, ptr, out)
+		}
+		switch dep := deps[name].(type) {
+		case []any:
+			require(dep, why)
+		case map[string]any, bool:
+			s.validate(dep, v, ptr, out)
+		}
+	}
+}
+
+// jsonTypeOf names the JSON type of v; numbers with no fractional part are integers.
+func jsonTypeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if r, ok := ratOf(v); ok && r.IsInt() {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
+
+// typeMatches reports whether v has the type, or one of the types, t names.
+func typeMatches(t, v any) bool {
+	got := jsonTypeOf(v)
+	match := func(want any) bool { return want == got || (want == "number" && got == "integer") }
+	if list, ok := t.([]any); ok {
+		for _, want := range list {
+			if match(want) {
+				return true
+			}
+		}
+		return false
+	}
+	return match(t)
+}
+
+func typeNames(t any) string {
+	list, ok := t.([]any)
+	if !ok {
+		return fmt.Sprint(t)
+	}
+	names := make([]string, len(list))
+	for i, n := range list {
+		names[i] = fmt.Sprint(n)
+	}
+	return strings.Join(names, " or ")
+}
+
+// equalJSON compares decoded JSON values, treating numbers by value so 1 equals 1.0.
+func equalJSON(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		ra, okA := ratOf(a)
+		rb, okB := ratOf(b)
+		return okA && okB && ra.Cmp(rb) == 0
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalJSON(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !equalJSON(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func containsJSON(list []any, v any) bool {
+	for _, item := range list {
+		if equalJSON(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// ratOf returns a JSON number exactly, so bounds and multipleOf do not suffer float rounding.
+func ratOf(v any) (*big.Rat, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Rat).SetString(string(n))
+}
+
+func intOf(v any) (int, bool) {
+	r, ok := ratOf(v)
+	if !ok || !r.IsInt() || !r.Num().IsInt64() {
+		return 0, false
+	}
+	return int(r.Num().Int64()), true
+}
+
+func compactJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// escapePointer escapes a property name as a JSON Pointer reference token (RFC 6901).
+func escapePointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+// displayPointer is ptr, or "(root)" for the empty pointer to the whole document.
+func displayPointer(ptr string) string {
+	if ptr == "" {
+		return "(root)"
+	}
+	return ptr
+}
@@ -0,0 +1,132 @@
+package poml
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchemaKeywords(t *testing.T) {
+	cases := []struct {
+		schema, value string
+		want          []string // violations as "pointer: message"; nil means valid
+	}{
+		{`{"type": ["string", "null"]}`, `null`, nil},
+		{`{"type": "integer"}`, `2.0`, nil},
+		{`{"type": "integer"}`, `2.5`, []string{"(root): got number, want integer"}},
+		{`{"enum": [1, "a", {"k": [true]}]}`, `{"k": [true]}`, nil},
+		{`{"enum": [1, "a"]}`, `1.00`, nil},
+		{`{"const": "a"}`, `"b"`, []string{`(root): value "b" is not "a"`}},
+		{`{"multipleOf": 0.1}`, `0.3`, nil},
+		{`{"multipleOf": 0.1}`, `0.35`, []string{"(root): 0.35 is not a multiple of 0.1"}},
+		{`{"exclusiveMaximum": 10}`, `10`, []string{"(root): 10 is not below exclusiveMaximum 10"}},
+		{`{"minimum": 5, "exclusiveMinimum": true}`, `5`, []string{"(root): 5 is not above minimum 5"}},
+		{`{"maxLength": 2}`, `"héé"`, []string{"(root): string has 3 characters, want at most 2"}},
+		{`{"prefixItems": [{"type": "string"}], "items": false}`, `["a", 1]`, []string{"/1: no value is allowed here"}},
+		{`{"items": [{"type": "string"}], "additionalItems": {"type": "integer"}}`, `["a", 1, "b"]`, []string{"/2: got string, want integer"}},
+		{`{"uniqueItems": true}`, `[1, {"a": 1}, {"a": 1.0}]`, []string{"(root): items 1 and 2 are equal, want unique items"}},
+		{`{"contains": {"const": 1}, "maxContains": 1}`, `[1, 2, 1]`, []string{"(root): array has 2 items matching contains, want at most 1"}},
+		{`{"patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": {"type": "integer"}}`, `{"x-a": "s", "b": "c"}`, []string{"/b: got string, want integer"}},
+		{`{"propertyNames": {"maxLength": 3}}`, `{"a/b~c": 1}`, []string{`/a~1b~0c: property name "a/b~c" is not allowed by propertyNames`}},
+		{`{"dependentRequired": {"card": ["cvv"]}}`, `{"card": "4111"}`, []string{`/cvv: required property is missing (required when "card" is present)`}},
+		{`{"dependencies": {"card": {"required": ["zip"]}}}`, `{"card": "4111"}`, []string{"/zip: required property is missing"}},
+		{`{"anyOf": [{"type": "string"}, {"minimum": 3}]}`, `2`, []string{"(root): matches none of the anyOf schemas"}},
+		{`{"oneOf": [{"type": "integer"}, {"minimum": 0}]}`, `1`, []string{"(root): matches 2 of the oneOf schemas, want exactly 1"}},
+		{`{"not": {"type": "null"}}`, `null`, []string{"(root): matches the schema under not"}},
+		{`{"if": {"properties": {"kind": {"const": "a"}}}, "then": {"required": ["a"]}, "else": {"required": ["b"]}}`, `{"kind": "c"}`, []string{"/b: required property is missing"}},
+		{`{"$defs": {"node": {"type": "object", "properties": {"next": {"$ref": "#/$defs/node"}, "v": {"type": "integer"}}}}, "$ref": "#/$defs/node"}`, `{"next": {"next": {"v": "x"}}}`, []string{"/next/next/v: got string, want integer"}},
+		{`{"$ref": "#"}`, `1`, []string{"(root): schema nests too deeply (recursive $ref?)"}},
+		{`{"format": "email", "title": "annotation", "$comment": "ignored"}`, `"not an email"`, []string{`(root): string "not an email" is not a valid email`}},
+		{`{"format": "email"}`, `42`, nil},
+
+		// $ref
+		{`{"$defs": {"name": {"type": "string"}}, "properties": {"a": {"$ref": "#/$defs/name"}}}`, `{"a": "x"}`, nil},
+		{`{"definitions": {"name": {"type": "string"}}, "items": {"$ref": "#/definitions/name"}}`, `["x", 1]`, []string{"/1: got integer, want string"}},
+		{`{"$defs": {"a/b~c": {"minimum": 2}}, "$ref": "#/$defs/a~1b~0c"}`, `1`, []string{"(root): 1 is below minimum 2"}},
+		{`{"$defs": {"short": {"maxLength": 2}}, "$ref": "#/$defs/short", "pattern": "^a"}`, `"bcd"`, []string{"(root): string has 3 characters, want at most 2", `(root): string "bcd" does not match pattern "^a"`}},
+		{`{"anyOf": [{"type": "integer"}, {"$ref": "#/anyOf/0"}]}`, `"x"`, []string{"(root): matches none of the anyOf schemas"}},
+
+		// combinators
+		{`{"allOf": [{"minimum": 2}, {"multipleOf": 2}]}`, `3`, []string{"(root): 3 is not a multiple of 2"}},
+		{`{"allOf": [{"required": ["a"]}, {"required": ["b"]}]}`, `{}`, []string{"/a: required property is missing", "/b: required property is missing"}},
+		{`{"anyOf": [{"type": "string"}, {"minimum": 3}]}`, `3`, nil},
+		{`{"oneOf": [{"type": "string"}, {"type": "boolean"}]}`, `1`, []string{"(root): matches 0 of the oneOf schemas, want exactly 1"}},
+		{`{"oneOf": [{"type": "string"}, {"type": "boolean"}]}`, `true`, nil},
+		{`{"properties": {"v": {"anyOf": [{"const": 1}, {"allOf": [{"type": "string"}, {"not": {"const": ""}}]}]}}}`, `{"v": ""}`, []string{"/v: matches none of the anyOf schemas"}},
+
+		// formats
+		{`{"format": "date-time"}`, `"2024-03-05T14:07:09.5+01:00"`, nil},
+		{`{"format": "date-time"}`, `"2024-03-05 14:07"`, []string{`(root): string "2024-03-05 14:07" is not a valid date-time`}},
+		{`{"format": "date"}`, `"2024-02-30"`, []string{`(root): string "2024-02-30" is not a valid date`}},
+		{`{"format": "time"}`, `"14:07:09z"`, nil},
+		{`{"format": "email"}`, `"Ada <ada@example.com>"`, []string{`(root): string "Ada <ada@example.com>" is not a valid email`}},
+		{`{"format": "hostname"}`, `"api-1.example.com"`, nil},
+		{`{"format": "hostname"}`, `"-bad.example"`, []string{`(root): string "-bad.example" is not a valid hostname`}},
+		{`{"format": "ipv4"}`, `"192.168.001.1"`, []string{`(root): string "192.168.001.1" is not a valid ipv4`}},
+		{`{"format": "ipv6"}`, `"::1"`, nil},
+		{`{"format": "uri"}`, `"/relative/path"`, []string{`(root): string "/relative/path" is not a valid uri`}},
+		{`{"format": "uri-reference"}`, `"/relative/path"`, nil},
+		{`{"format": "uuid"}`, `"123e4567-e89b-12d3-a456-426614174000"`, nil},
+		{`{"format": "regex"}`, `"(unclosed"`, []string{`(root): string "(unclosed" is not a valid regex`}},
+		{`{"format": "json-pointer"}`, `"/a~2"`, []string{`(root): string "/a~2" is not a valid json-pointer`}},
+	}
+	for _, c := range cases {
+		var root, value any
+		if err := decodeTestJSON(c.schema, &root); err != nil {
+			t.Fatalf("schema %s: %v", c.schema, err)
+		}
+		if err := decodeTestJSON(c.value, &value); err != nil {
+			t.Fatalf("value %s: %v", c.value, err)
+		}
+		schema, err := compileJSONSchema(root)
+		if err != nil {
+			t.Fatalf("compile %s: %v", c.schema, err)
+		}
+		var got []string
+		for _, v := range schema.Validate(value) {
+			got = append(got, v.String())
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%s against %s:\n got %q\nwant %q", c.value, c.schema, got, c.want)
+		}
+	}
+}
+
+func TestJSONSchemaUnsupported(t *testing.T) {
+	for schema, want := range map[string]string{
+		`{"unevaluatedProperties": false}`:                            `/unevaluatedProperties: unsupported keyword "unevaluatedProperties"`,
+		`{"items": {"$dynamicRef": "#meta"}}`:                         `/items/$dynamicRef: unsupported keyword "$dynamicRef"`,
+		`{"$defs": {"a": {"$anchor": "a"}}}`:                          `/$defs/a/$anchor: unsupported keyword "$anchor"`,
+		`{"properties": {"a": {"type": "string", "nullable": true}}}`: `/properties/a/nullable: unsupported keyword "nullable"`,
+		`{"properties": {"a": {"$id": "a.json"}}}`:                    `/properties/a/$id: $id is only supported on the root schema`,
+		`{"$schema": "http://json-schema.org/draft-03/schema#"}`:      `/$schema: unsupported JSON Schema draft`,
+		`{"format": "duration"}`:                                      `/format: unsupported format "duration"`,
+		`{"$ref": "other.json#/a"}`:                                   `unsupported $ref "other.json#/a"`,
+		`{"$ref": "#/$defs/missing"}`:                                 `$ref "#/$defs/missing" does not resolve`,
+		`{"patternProperties": {"(?=x)": {}}}`:                        `/patternProperties: pattern "(?=x)"`,
+		`{"allOf": [{"type": "string"}, 3]}`:                          `/allOf/1: schema must be an object or boolean`,
+	} {
+		var root any
+		if err := decodeTestJSON(schema, &root); err != nil {
+			t.Fatalf("schema %s: %v", schema, err)
+		}
+		if _, err := compileJSONSchema(root); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: got %v, want an error containing %q", schema, err, want)
+		}
+	}
+
+	var root any
+	if err := decodeTestJSON(`{"$schema": "http://json-schema.org/draft-07/schema#", "$id": "https://example.com/item.json", "description": "d", "default": {"x": 1}, "examples": [{"nullable": 1}], "readOnly": true}`, &root); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := compileJSONSchema(root); err != nil {
+		t.Fatalf("annotations rejected: %v", err)
+	}
+}
+
+func decodeTestJSON(src string, v any) error {
+	dec := json.NewDecoder(strings.NewReader(src))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,95 @@
+package poml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExportJSONL converts doc to format and writes its messages one JSON object per line, the
+// layout fine-tuning pipelines consume. Any format whose output holds a message list works:
+// the list itself (message_dict, hf_chat), its "messages" key, or Cohere's "chat_history".
+// Tools, runtime settings, and other request fields are not written; FormatText has no
+// messages and fails with ErrNotImplemented.
+func ExportJSONL(doc Document, w io.Writer, format Format) error {
+	out, err := Convert(doc, format, ConvertOptions{})
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("export jsonl: %w", err)
+	}
+	raw := buf.Bytes()
+	var messages []json.RawMessage
+	if err := json.Unmarshal(raw, &messages); err != nil {
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(raw, &obj) != nil {
+			return fmt.Errorf("export jsonl: %s output has no messages: %w", format, ErrNotImplemented)
+		}
+		list, ok := obj["messages"]
+		if !ok {
+			list, ok = obj["chat_history"]
+		}
+		if !ok || json.Unmarshal(list, &messages) != nil {
+			return fmt.Errorf("export jsonl: %s output has no messages: %w", format, ErrNotImplemented)
+		}
+	}
+	bw := bufio.NewWriter(w)
+	for _, msg := range messages {
+		var line bytes.Buffer
+		if err := json.Compact(&line, msg); err != nil {
+			return fmt.Errorf("export jsonl: %w", err)
+		}
+		line.WriteByte('\n')
+		if _, err := bw.Write(line.Bytes()); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ImportJSONL reads one message per line, as ExportJSONL writes them, back into a Document.
+// Blank lines are skipped. FormatLangChain lines go through ImportLangChain; openai_chat,
+// mistral, and hf_chat lines through ImportOpenAIChat. Other formats fail with
+// ErrNotImplemented.
+func ImportJSONL(r io.Reader, format Format) (Document, error) {
+	var importer func([]byte) (Document, error)
+	switch format {
+	case FormatOpenAIChat, FormatMistral, FormatHFChat:
+		importer = ImportOpenAIChat
+	case FormatLangChain:
+		importer = ImportLangChain
+	default:
+		return Document{}, fmt.Errorf("import jsonl: %s: %w", format, ErrNotImplemented)
+	}
+	var messages []json.RawMessage
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 64<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if !json.Valid([]byte(line)) {
+			return Document{}, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: fmt.Sprintf("import jsonl: line %d is not JSON", n)}
+		}
+		messages = append(messages, json.RawMessage(line))
+	}
+	if err := sc.Err(); err != nil {
+		return Document{}, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "import jsonl", Err: err}
+	}
+	if len(messages) == 0 {
+		return Document{}, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "import jsonl: no messages"}
+	}
+	payload, err := json.Marshal(messages)
+	if err != nil {
+		return Document{}, err
+	}
+	return importer(payload)
+}
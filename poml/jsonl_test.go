@@ -0,0 +1,49 @@
+package poml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSONLRoundTrip(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <system-msg>Be terse.</system-msg>
+  <human-msg>Weather in Oslo?</human-msg>
+  <tool-request id="c1" name="weather" parameters='{"city":"Oslo"}'/>
+  <tool-response id="c1" name="weather">rain</tool-response>
+  <assistant-msg>It rains <b>hard</b>.</assistant-msg>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for _, format := range []Format{FormatOpenAIChat, FormatLangChain} {
+		var buf bytes.Buffer
+		if err := ExportJSONL(doc, &buf, format); err != nil {
+			t.Fatalf("%s export: %v", format, err)
+		}
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		if len(lines) != 5 {
+			t.Fatalf("%s: want one line per message, got:\n%s", format, buf.String())
+		}
+		back, err := ImportJSONL(&buf, format)
+		if err != nil {
+			t.Fatalf("%s import: %v", format, err)
+		}
+		if len(back.Messages) != 3 || len(back.ToolReqs) != 1 || len(back.ToolResps) != 1 || back.ToolResps[0].ID != "c1" {
+			t.Fatalf("%s: round trip lost content: %+v", format, back)
+		}
+		if got := back.Messages[2].Body; got != "It rains &lt;b&gt;hard&lt;/b&gt;." {
+			t.Fatalf("%s: assistant body = %q", format, got)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ExportJSONL(doc, &buf, FormatText); !errors.Is(err, ErrNotImplemented) {
+		t.Fatalf("text export should be unsupported, got %v", err)
+	}
+	if _, err := ImportJSONL(strings.NewReader("{\"role\":\"user\"}\nnot json\n"), FormatOpenAIChat); !errors.Is(err, CodeDecode) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected a line-numbered decode error, got %v", err)
+	}
+}
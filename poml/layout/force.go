@@ -0,0 +1,91 @@
+package layout
+
+import "math"
+
+// force runs a Fruchterman-Reingold simulation. Free nodes start on a circle (in ID order, so
+// the result is deterministic) and move under edge attraction and pairwise repulsion while the
+// step size cools linearly to zero; fixed nodes push and pull but never move.
+func (g *graph) force(opts LayoutOptions) {
+	k := opts.Spacing
+	n := len(g.ids)
+	var cx, cy float64
+	var anchors int
+	for i := range g.ids {
+		if g.fixed[i] {
+			cx += g.pos[i][0]
+			cy += g.pos[i][1]
+			anchors++
+		}
+	}
+	if anchors > 0 {
+		cx, cy = cx/float64(anchors), cy/float64(anchors)
+	}
+	radius := k * math.Sqrt(float64(len(g.free)))
+	for j, i := range g.free {
+		angle := 2 * math.Pi * float64(j) / float64(len(g.free))
+		g.pos[i] = [2]float64{cx + radius*math.Cos(angle), cy + radius*math.Sin(angle)}
+	}
+
+	disp := make([][2]float64, n)
+	temp := k * math.Sqrt(float64(n))
+	cool := temp / float64(opts.Iterations)
+	for iter := 0; iter < opts.Iterations; iter++ {
+		for i := range disp {
+			disp[i] = [2]float64{}
+		}
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				dx, dy, d := g.delta(i, j)
+				f := k * k / d
+				disp[i][0] += dx / d * f
+				disp[i][1] += dy / d * f
+				disp[j][0] -= dx / d * f
+				disp[j][1] -= dy / d * f
+			}
+		}
+		for _, e := range g.edges {
+			dx, dy, d := g.delta(e[0], e[1])
+			f := d * d / k
+			disp[e[0]][0] -= dx / d * f
+			disp[e[0]][1] -= dy / d * f
+			disp[e[1]][0] += dx / d * f
+			disp[e[1]][1] += dy / d * f
+		}
+		for _, i := range g.free {
+			l := math.Hypot(disp[i][0], disp[i][1])
+			if l == 0 {
+				continue
+			}
+			step := math.Min(l, temp)
+			g.pos[i][0] += disp[i][0] / l * step
+			g.pos[i][1] += disp[i][1] / l * step
+		}
+		temp -= cool
+	}
+	if anchors == 0 {
+		// Nothing pins the drawing, so centre it on the origin.
+		var mx, my float64
+		for _, i := range g.free {
+			mx += g.pos[i][0]
+			my += g.pos[i][1]
+		}
+		mx, my = mx/float64(len(g.free)), my/float64(len(g.free))
+		for _, i := range g.free {
+			g.pos[i][0] -= mx
+			g.pos[i][1] -= my
+		}
+	}
+}
+
+// delta returns the vector from j to i and its length. Coincident nodes are separated along a
+// direction derived from their indexes so the simulation stays deterministic.
+func (g *graph) delta(i, j int) (float64, float64, float64) {
+	dx := g.pos[i][0] - g.pos[j][0]
+	dy := g.pos[i][1] - g.pos[j][1]
+	d := math.Hypot(dx, dy)
+	if d < 1e-9 {
+		angle := float64(i*7+j*13) * 0.1
+		dx, dy, d = 1e-3*math.Cos(angle), 1e-3*math.Sin(angle), 1e-3
+	}
+	return dx, dy, d
+}
@@ -0,0 +1,186 @@
+package layout
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// layeredSweeps is how many down/up barycenter passes run when ordering ranks.
+const layeredSweeps = 8
+
+// layered places the free nodes in four Sugiyama phases: cycles are broken by reversing DFS
+// back edges, nodes get their longest-path rank, edges spanning several ranks are split with
+// virtual nodes, and each rank is reordered by the barycenter of its neighbours to reduce
+// crossings. Only edges between free nodes are considered; when fixed nodes exist the result
+// is shifted to sit beside them.
+func (g *graph) layered(opts LayoutOptions) {
+	local := make(map[int]int, len(g.free))
+	for li, i := range g.free {
+		local[i] = li
+	}
+	n := len(g.free)
+	out := make([][]int, n)
+	for _, e := range g.edges {
+		from, ok1 := local[e[0]]
+		to, ok2 := local[e[1]]
+		if ok1 && ok2 {
+			out[from] = append(out[from], to)
+		}
+	}
+
+	// Break cycles: DFS in ID order, reversing edges that point back into the current path.
+	state := make([]int, n) // 0 unvisited, 1 on stack, 2 done
+	var edges [][2]int
+	var visit func(v int)
+	visit = func(v int) {
+		state[v] = 1
+		for _, w := range out[v] {
+			switch state[w] {
+			case 0:
+				edges = append(edges, [2]int{v, w})
+				visit(w)
+			case 1:
+				edges = append(edges, [2]int{w, v})
+			default:
+				edges = append(edges, [2]int{v, w})
+			}
+		}
+		state[v] = 2
+	}
+	for v := 0; v < n; v++ {
+		if state[v] == 0 {
+			visit(v)
+		}
+	}
+
+	// Longest-path ranking over the now acyclic graph.
+	rank := make([]int, n)
+	indeg := make([]int, n)
+	succ := make([][]int, n)
+	for _, e := range edges {
+		succ[e[0]] = append(succ[e[0]], e[1])
+		indeg[e[1]]++
+	}
+	queue := make([]int, 0, n)
+	for v := 0; v < n; v++ {
+		if indeg[v] == 0 {
+			queue = append(queue, v)
+		}
+	}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, w := range succ[v] {
+			rank[w] = max(rank[w], rank[v]+1)
+			if indeg[w]--; indeg[w] == 0 {
+				queue = append(queue, w)
+			}
+		}
+	}
+
+	// Split long edges with virtual nodes (indexes >= n) so every edge joins adjacent ranks.
+	maxRank := 0
+	for _, r := range rank {
+		maxRank = max(maxRank, r)
+	}
+	layers := make([][]int, maxRank+1)
+	for v := 0; v < n; v++ {
+		layers[rank[v]] = append(layers[rank[v]], v)
+	}
+	var up, down [][]int // neighbours in the rank above / below, indexed by vertex
+	grow := func(v int) {
+		for len(up) <= v {
+			up, down = append(up, nil), append(down, nil)
+		}
+	}
+	grow(n - 1)
+	vertices := n
+	for _, e := range edges {
+		prev := e[0]
+		for r := rank[e[0]] + 1; r < rank[e[1]]; r++ {
+			v := vertices
+			vertices++
+			grow(v)
+			layers[r] = append(layers[r], v)
+			down[prev] = append(down[prev], v)
+			up[v] = append(up[v], prev)
+			prev = v
+		}
+		down[prev] = append(down[prev], e[1])
+		up[e[1]] = append(up[e[1]], prev)
+	}
+
+	// Barycenter ordering, alternating downward and upward sweeps.
+	order := make([]float64, vertices)
+	for _, layer := range layers {
+		for i, v := range layer {
+			order[v] = float64(i)
+		}
+	}
+	reorder := func(layer []int, neighbours [][]int) {
+		bary := make(map[int]float64, len(layer))
+		for _, v := range layer {
+			bary[v] = order[v]
+			if len(neighbours[v]) > 0 {
+				var sum float64
+				for _, w := range neighbours[v] {
+					sum += order[w]
+				}
+				bary[v] = sum / float64(len(neighbours[v]))
+			}
+		}
+		sort.SliceStable(layer, func(a, b int) bool { return bary[layer[a]] < bary[layer[b]] })
+		for i, v := range layer {
+			order[v] = float64(i)
+		}
+	}
+	for sweep := 0; sweep < layeredSweeps; sweep++ {
+		if sweep%2 == 0 {
+			for r := 1; r < len(layers); r++ {
+				reorder(layers[r], up)
+			}
+		} else {
+			for r := len(layers) - 2; r >= 0; r-- {
+				reorder(layers[r], down)
+			}
+		}
+	}
+
+	// Centre each rank; virtual nodes keep their slots so long edges pass between real nodes.
+	lr := strings.EqualFold(strings.TrimSpace(opts.Direction), "LR")
+	minAcross := math.Inf(1)
+	for r, layer := range layers {
+		for i, v := range layer {
+			if v >= n {
+				continue
+			}
+			across := (float64(i) - float64(len(layer)-1)/2) * opts.Spacing
+			along := float64(r) * opts.LayerSpacing
+			minAcross = math.Min(minAcross, across)
+			if lr {
+				g.pos[g.free[v]] = [2]float64{along, across}
+			} else {
+				g.pos[g.free[v]] = [2]float64{across, along}
+			}
+		}
+	}
+
+	// Keep clear of nodes that were already placed.
+	axis := 0
+	if lr {
+		axis = 1
+	}
+	maxFixed := math.Inf(-1)
+	for i, fixed := range g.fixed {
+		if fixed {
+			maxFixed = math.Max(maxFixed, g.pos[i][axis])
+		}
+	}
+	if !math.IsInf(maxFixed, -1) {
+		shift := maxFixed + opts.Spacing - minAcross
+		for _, i := range g.free {
+			g.pos[i][axis] += shift
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package layout assigns positions to scene nodes for diagrams authored without coordinates.
+// DiagramToScene leaves such nodes at the origin, so every renderer draws them on top of each
+// other; ApplyLayout spreads them out with a force-directed or layered (Sugiyama-style) layout.
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+// LayoutAlgo selects a layout algorithm.
+type LayoutAlgo int
+
+const (
+	// ForceDirected simulates springs along edges and repulsion between nodes
+	// (Fruchterman-Reingold). Suited to undirected or cyclic graphs.
+	ForceDirected LayoutAlgo = iota
+	// Layered ranks nodes along edge direction and orders each rank to reduce crossings.
+	// Suited to dependency graphs and flows.
+	Layered
+)
+
+func (a LayoutAlgo) String() string {
+	switch a {
+	case ForceDirected:
+		return "force"
+	case Layered:
+		return "layered"
+	}
+	return fmt.Sprintf("layout(%d)", int(a))
+}
+
+// ParseLayoutAlgo maps a name ("force", "layered", or the aliases "force-directed", "sugiyama",
+// "dagre") to a LayoutAlgo.
+func ParseLayoutAlgo(name string) (LayoutAlgo, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "force", "force-directed", "fdp":
+		return ForceDirected, nil
+	case "layered", "sugiyama", "dagre", "hierarchical":
+		return Layered, nil
+	}
+	return 0, fmt.Errorf("%w: %q", UnknownAlgoError, name)
+}
+
+// UnknownAlgoError indicates an unsupported layout algorithm.
+var UnknownAlgoError = errors.New("unknown layout algorithm")
+
+// LayoutOptions tunes ApplyLayout. The zero value is usable.
+type LayoutOptions struct {
+	// Spacing is the preferred distance between neighbouring nodes (default 1, matching the
+	// unit grid used by hand-placed diagrams).
+	Spacing float64
+	// LayerSpacing is the distance between ranks in a layered layout (default Spacing).
+	LayerSpacing float64
+	// Direction orients a layered layout: "TB" (default, ranks grow along +Y) or "LR" (+X).
+	Direction string
+	// Iterations bounds the force-directed simulation (default 300).
+	Iterations int
+	// Overwrite repositions every node. By default nodes that already have a position are
+	// left alone: they anchor a force-directed layout, and a layered layout of the remaining
+	// nodes is placed beside them.
+	Overwrite bool
+}
+
+func (o LayoutOptions) withDefaults() LayoutOptions {
+	if o.Spacing <= 0 {
+		o.Spacing = 1
+	}
+	if o.LayerSpacing <= 0 {
+		o.LayerSpacing = o.Spacing
+	}
+	if o.Iterations <= 0 {
+		o.Iterations = 300
+	}
+	return o
+}
+
+// ApplyLayout fills in X/Y positions for nodes whose coordinates are absent. A Scene cannot
+// tell an omitted coordinate from an explicit zero, so a node counts as unplaced when its
+// position is the origin. Z is never changed. Results are deterministic for a given scene.
+func ApplyLayout(scene *poml.Scene, algo LayoutAlgo, opts LayoutOptions) error {
+	if scene == nil {
+		return errors.New("layout: scene is nil")
+	}
+	opts = opts.withDefaults()
+	g := newGraph(scene, opts.Overwrite)
+	if len(g.free) == 0 {
+		return nil
+	}
+	switch algo {
+	case ForceDirected:
+		g.force(opts)
+	case Layered:
+		g.layered(opts)
+	default:
+		return fmt.Errorf("layout: %w: %s", UnknownAlgoError, algo)
+	}
+	for _, i := range g.free {
+		scene.Nodes[i].Position[0] = round(g.pos[i][0])
+		scene.Nodes[i].Position[1] = round(g.pos[i][1])
+	}
+	return nil
+}
+
+// graph is the index-based view of a scene the algorithms work on.
+type graph struct {
+	ids   []string
+	pos   [][2]float64
+	fixed []bool
+	free  []int    // indexes of nodes to place, sorted by ID
+	edges [][2]int // deduplicated, no self-loops, endpoints known
+}
+
+func newGraph(scene *poml.Scene, overwrite bool) *graph {
+	g := &graph{}
+	index := make(map[string]int, len(scene.Nodes))
+	for i, n := range scene.Nodes {
+		index[n.ID] = i
+		g.ids = append(g.ids, n.ID)
+		g.pos = append(g.pos, [2]float64{n.Position[0], n.Position[1]})
+		fixed := !overwrite && n.Position != [3]float64{}
+		g.fixed = append(g.fixed, fixed)
+		if !fixed {
+			g.free = append(g.free, i)
+		}
+	}
+	sort.Slice(g.free, func(a, b int) bool { return g.ids[g.free[a]] < g.ids[g.free[b]] })
+	seen := make(map[[2]int]bool)
+	for _, e := range scene.Edges {
+		from, ok1 := index[e.From]
+		to, ok2 := index[e.To]
+		if !ok1 || !ok2 || from == to || seen[[2]int{from, to}] {
+			continue
+		}
+		seen[[2]int{from, to}] = true
+		g.edges = append(g.edges, [2]int{from, to})
+	}
+	return g
+}
+
+// round trims positions to the precision renderers print, keeping output stable.
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
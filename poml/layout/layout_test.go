@@ -0,0 +1,118 @@
+package layout
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+const unplaced = `<poml>
+  <diagram id="flow">
+    <graph>
+      <node id="a"/>
+      <node id="b"/>
+      <node id="c"/>
+      <node id="d"/>
+      <node id="e"/>
+      <node id="anchor" x="10" y="4" z="2"/>
+      <edge from="a" to="b" directed="true"/>
+      <edge from="a" to="c" directed="true"/>
+      <edge from="b" to="d" directed="true"/>
+      <edge from="c" to="d" directed="true"/>
+      <edge from="a" to="d" directed="true"/>
+      <edge from="d" to="a" directed="true"/>
+      <edge from="d" to="e" directed="true"/>
+      <edge from="e" to="anchor" directed="true"/>
+    </graph>
+  </diagram>
+</poml>`
+
+func sceneFor(t *testing.T, src string) poml.Scene {
+	t.Helper()
+	doc, err := poml.ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	scene, err := poml.DiagramToScene(doc.Diagrams[0])
+	if err != nil {
+		t.Fatalf("scene: %v", err)
+	}
+	return scene
+}
+
+func positions(scene poml.Scene) map[string][3]float64 {
+	out := make(map[string][3]float64, len(scene.Nodes))
+	for _, n := range scene.Nodes {
+		out[n.ID] = n.Position
+	}
+	return out
+}
+
+func TestLayeredLayout(t *testing.T) {
+	scene := sceneFor(t, unplaced)
+	if err := ApplyLayout(&scene, Layered, LayoutOptions{Spacing: 2}); err != nil {
+		t.Fatalf("layout: %v", err)
+	}
+	pos := positions(scene)
+	if pos["anchor"] != [3]float64{10, 4, 2} {
+		t.Fatalf("placed node moved: %v", pos["anchor"])
+	}
+	// Ranks follow edge direction (the d->a back edge is reversed), two units apart.
+	for id, y := range map[string]float64{"a": 0, "b": 2, "c": 2, "d": 4, "e": 6} {
+		if pos[id][1] != y {
+			t.Fatalf("%s at y=%v, want %v (%v)", id, pos[id][1], y, pos)
+		}
+	}
+	if pos["b"][0] == pos["c"][0] || math.Abs(pos["b"][0]-pos["c"][0]) < 2 {
+		t.Fatalf("rank members overlap: %v %v", pos["b"], pos["c"])
+	}
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if pos[id][0] < 12 {
+			t.Fatalf("%s should sit beside the placed node: %v", id, pos)
+		}
+	}
+
+	lr := sceneFor(t, unplaced)
+	_ = ApplyLayout(&lr, Layered, LayoutOptions{Direction: "LR", Overwrite: true})
+	if p := positions(lr); p["e"][0] != 3 || p["anchor"][0] != 4 || p["anchor"][2] != 2 {
+		t.Fatalf("LR ranks should grow along x and keep z: %v", p)
+	}
+}
+
+func TestForceDirectedLayout(t *testing.T) {
+	scene := sceneFor(t, unplaced)
+	again := sceneFor(t, unplaced)
+	if err := ApplyLayout(&scene, ForceDirected, LayoutOptions{}); err != nil {
+		t.Fatalf("layout: %v", err)
+	}
+	_ = ApplyLayout(&again, ForceDirected, LayoutOptions{})
+	if !reflect.DeepEqual(scene, again) {
+		t.Fatalf("layout is not deterministic")
+	}
+	pos := positions(scene)
+	if pos["anchor"] != [3]float64{10, 4, 2} {
+		t.Fatalf("placed node moved: %v", pos["anchor"])
+	}
+	ids := []string{"a", "b", "c", "d", "e", "anchor"}
+	for i, x := range ids {
+		for _, y := range ids[i+1:] {
+			if d := math.Hypot(pos[x][0]-pos[y][0], pos[x][1]-pos[y][1]); d < 0.5 {
+				t.Fatalf("%s and %s overlap (distance %.3f): %v", x, y, d, pos)
+			}
+		}
+	}
+
+	before := positions(scene)
+	if err := ApplyLayout(&scene, Layered, LayoutOptions{}); err != nil || !reflect.DeepEqual(positions(scene), before) {
+		t.Fatalf("fully placed scene should be left alone (%v)", err)
+	}
+	if err := ApplyLayout(&scene, LayoutAlgo(9), LayoutOptions{Overwrite: true}); !errors.Is(err, UnknownAlgoError) {
+		t.Fatalf("expected UnknownAlgoError, got %v", err)
+	}
+	if algo, err := ParseLayoutAlgo("Sugiyama"); err != nil || algo != Layered {
+		t.Fatalf("parse algo: %v %v", algo, err)
+	}
+}
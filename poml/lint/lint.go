@@ -0,0 +1,171 @@
+// Package lint runs style and quality checks over parsed POML documents. Validation in the poml
+// package covers structural requirements; lint rules flag prompts that are valid but likely to
+// underperform (overlong roles, vague tasks, images without alt text, and so on).
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+// Severity ranks how strongly a finding should be acted upon.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// LintFinding is a single issue reported by a rule. ElementID is empty for document-level findings.
+type LintFinding struct {
+	Rule      string
+	Severity  Severity
+	ElementID string
+	Element   poml.ElementType
+	Message   string
+}
+
+func (f LintFinding) String() string {
+	loc := "document"
+	if f.ElementID != "" {
+		loc = fmt.Sprintf("%s (%s)", f.Element, f.ElementID)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", f.Severity, loc, f.Message, f.Rule)
+}
+
+// Rule inspects a document and returns its findings.
+type Rule interface {
+	Name() string
+	Check(doc poml.Document) []LintFinding
+}
+
+// RuleFunc adapts a function into a Rule. Findings returned by Fn take their Rule and Severity
+// from the RuleFunc.
+type RuleFunc struct {
+	ID       string
+	Severity Severity
+	Fn       func(doc poml.Document) []LintFinding
+}
+
+// Name returns the rule ID.
+func (r RuleFunc) Name() string { return r.ID }
+
+// Check runs Fn and stamps the rule name and severity onto its findings.
+func (r RuleFunc) Check(doc poml.Document) []LintFinding {
+	if r.Fn == nil {
+		return nil
+	}
+	findings := r.Fn(doc)
+	for i := range findings {
+		findings[i].Rule = r.ID
+		findings[i].Severity = r.Severity
+	}
+	return findings
+}
+
+// RuleExistsError indicates a duplicate rule registration.
+var RuleExistsError = errors.New("lint rule already registered")
+
+// Registry is a threadsafe set of rules keyed by name.
+type Registry struct {
+	mu    sync.RWMutex
+	rules map[string]Rule
+}
+
+// NewRegistry builds an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{rules: make(map[string]Rule)}
+}
+
+// DefaultRegistry returns a new registry holding the built-in rules.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	for _, rule := range BuiltinRules() {
+		_ = r.Register(rule)
+	}
+	return r
+}
+
+// Register adds a rule. Returns RuleExistsError when the name is already taken.
+func (r *Registry) Register(rule Rule) error {
+	if rule == nil {
+		return errors.New("lint rule is nil")
+	}
+	name := rule.Name()
+	if name == "" {
+		return errors.New("lint rule name is empty")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.rules[name]; exists {
+		return fmt.Errorf("%w: %s", RuleExistsError, name)
+	}
+	r.rules[name] = rule
+	return nil
+}
+
+// Unregister removes a rule by name, reporting whether it was present.
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.rules[name]
+	delete(r.rules, name)
+	return ok
+}
+
+// List returns registered rule names in sorted order.
+func (r *Registry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.rules))
+	for name := range r.rules {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Lint runs every registered rule in name order and returns findings sorted by severity
+// (highest first), then by rule name.
+func (r *Registry) Lint(doc poml.Document) []LintFinding {
+	r.mu.RLock()
+	rules := make([]Rule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		rules = append(rules, rule)
+	}
+	r.mu.RUnlock()
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name() < rules[j].Name() })
+	var findings []LintFinding
+	for _, rule := range rules {
+		findings = append(findings, rule.Check(doc)...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity > findings[j].Severity
+		}
+		return findings[i].Rule < findings[j].Rule
+	})
+	return findings
+}
+
+// Lint runs the built-in rules against doc.
+func Lint(doc poml.Document) []LintFinding {
+	return DefaultRegistry().Lint(doc)
+}
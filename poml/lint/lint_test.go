@@ -0,0 +1,77 @@
+package lint
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+func TestBuiltinRules(t *testing.T) {
+	src := `<poml>
+  <role>` + strings.Repeat("word ", MaxRoleWords+1) + `</role>
+  <task>The quarterly numbers</task>
+  <task>Summarize the report for {{ audience }}.</task>
+  <input name="audience">execs</input>
+  <input name="tone">formal</input>
+  <img src="data:image/png;base64,AAAA" />
+  <tsak>Typo</tsak>
+</poml>`
+	doc, err := poml.ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	findings := Lint(doc)
+	byRule := map[string][]LintFinding{}
+	for _, f := range findings {
+		byRule[f.Rule] = append(byRule[f.Rule], f)
+	}
+	for _, rule := range []string{"role-too-long", "task-without-verb", "missing-output-format", "unreferenced-input", "image-without-alt", "unknown-element"} {
+		if len(byRule[rule]) != 1 {
+			t.Fatalf("expected one %s finding, got %+v", rule, findings)
+		}
+	}
+	if f := byRule["unreferenced-input"][0]; !strings.Contains(f.Message, `"tone"`) || f.Element != poml.ElementInput || f.ElementID == "" {
+		t.Fatalf("unexpected unreferenced-input finding: %+v", f)
+	}
+	if findings[0].Severity != SeverityWarning || findings[len(findings)-1].Severity != SeverityInfo {
+		t.Fatalf("findings not sorted by severity: %+v", findings)
+	}
+	if got := byRule["image-without-alt"][0].String(); !strings.HasPrefix(got, "warning: image (el-") {
+		t.Fatalf("unexpected finding string: %s", got)
+	}
+	if got := byRule["unknown-element"][0].Message; got != "unknown element <tsak>; did you mean <task>?" {
+		t.Fatalf("unexpected unknown-element message: %s", got)
+	}
+}
+
+func TestRegistryCustomRules(t *testing.T) {
+	reg := NewRegistry()
+	rule := RuleFunc{ID: "no-tasks", Severity: SeverityError, Fn: func(doc poml.Document) []LintFinding {
+		if len(doc.Tasks) == 0 {
+			return []LintFinding{{Message: "document has no tasks"}}
+		}
+		return nil
+	}}
+	if err := reg.Register(rule); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := reg.Register(rule); !errors.Is(err, RuleExistsError) {
+		t.Fatalf("expected RuleExistsError, got %v", err)
+	}
+	doc, err := poml.ParseString(`<poml><role>r</role></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	findings := reg.Lint(doc)
+	if len(findings) != 1 || findings[0].Rule != "no-tasks" || findings[0].Severity != SeverityError {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+	if !reg.Unregister("no-tasks") || len(reg.List()) != 0 {
+		t.Fatalf("unregister failed")
+	}
+	if got := DefaultRegistry().List(); len(got) != len(BuiltinRules()) {
+		t.Fatalf("default registry rules mismatch: %v", got)
+	}
+}
@@ -0,0 +1,180 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+// MaxRoleWords is the word count above which role-too-long reports a finding.
+const MaxRoleWords = 150
+
+// BuiltinRules returns the rules registered by DefaultRegistry.
+func BuiltinRules() []Rule {
+	return []Rule{
+		RuleFunc{ID: "role-too-long", Severity: SeverityWarning, Fn: checkRoleLength},
+		RuleFunc{ID: "task-without-verb", Severity: SeverityInfo, Fn: checkTaskVerbs},
+		RuleFunc{ID: "missing-output-format", Severity: SeverityInfo, Fn: checkOutputFormat},
+		RuleFunc{ID: "unreferenced-input", Severity: SeverityWarning, Fn: checkInputReferences},
+		RuleFunc{ID: "image-without-alt", Severity: SeverityWarning, Fn: checkImageAlt},
+		RuleFunc{ID: "unknown-element", Severity: SeverityWarning, Fn: checkUnknownElements},
+	}
+}
+
+func finding(el poml.Element, format string, args ...any) LintFinding {
+	return LintFinding{ElementID: el.ID, Element: el.Type, Message: fmt.Sprintf(format, args...)}
+}
+
+// elements returns doc's elements in document order with their payloads.
+func elements(doc poml.Document) ([]poml.Element, []poml.ElementPayload) {
+	var els []poml.Element
+	var payloads []poml.ElementPayload
+	_ = doc.Walk(func(el poml.Element, p poml.ElementPayload) error {
+		els = append(els, el)
+		payloads = append(payloads, p)
+		return nil
+	})
+	return els, payloads
+}
+
+func checkRoleLength(doc poml.Document) []LintFinding {
+	words := len(strings.Fields(doc.RoleText()))
+	if words <= MaxRoleWords {
+		return nil
+	}
+	els, _ := elements(doc)
+	for _, el := range els {
+		if el.Type == poml.ElementRole {
+			return []LintFinding{finding(el, "role has %d words (max %d); move details into tasks or hints", words, MaxRoleWords)}
+		}
+	}
+	return []LintFinding{{Element: poml.ElementRole, Message: fmt.Sprintf("role has %d words (max %d)", words, MaxRoleWords)}}
+}
+
+// instructionVerbs are common imperative verbs; a task mentioning none of them is probably a
+// topic rather than an instruction.
+var instructionVerbs = map[string]struct{}{}
+
+func init() {
+	for _, v := range strings.Fields(`add analyze answer apply assess build calculate check choose classify
+		compare compose compute convert create critique debug decide define describe design detect determine
+		draft edit estimate evaluate explain extract fill find fix format generate give identify implement
+		infer label list make map match name note outline paraphrase plan predict prepare produce propose
+		provide rank rate read recommend refactor reply report respond return review revise rewrite score
+		select show solve sort suggest summarize tag tell test transform translate use validate verify write`) {
+		instructionVerbs[v] = struct{}{}
+	}
+}
+
+var wordRe = regexp.MustCompile(`[A-Za-z]+`)
+
+func checkTaskVerbs(doc poml.Document) []LintFinding {
+	var out []LintFinding
+	els, payloads := elements(doc)
+	for i, el := range els {
+		if el.Type != poml.ElementTask || payloads[i].Task == nil {
+			continue
+		}
+		body := strings.TrimSpace(payloads[i].Task.Body)
+		if body == "" || strings.HasSuffix(body, "?") || hasInstructionVerb(body) {
+			continue
+		}
+		out = append(out, finding(el, "task has no instruction verb; state what the model should do"))
+	}
+	return out
+}
+
+func hasInstructionVerb(text string) bool {
+	for _, w := range wordRe.FindAllString(text, -1) {
+		if _, ok := instructionVerbs[strings.ToLower(w)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func checkOutputFormat(doc poml.Document) []LintFinding {
+	if len(doc.OutFormats) > 0 || len(doc.Styles) > 0 || strings.TrimSpace(doc.Schema.Body) != "" || len(doc.Tasks) == 0 {
+		return nil
+	}
+	return []LintFinding{{Message: "no <output-format>, <style>, or <output-schema>; describe the expected response shape"}}
+}
+
+func checkInputReferences(doc poml.Document) []LintFinding {
+	var texts []string
+	els, payloads := elements(doc)
+	for i, el := range els {
+		if el.Type == poml.ElementInput {
+			continue
+		}
+		if b := bodyText(payloads[i]); b != "" {
+			texts = append(texts, b)
+		}
+	}
+	corpus := strings.Join(texts, "\n")
+	var out []LintFinding
+	for i, el := range els {
+		in := payloads[i].Input
+		if el.Type != poml.ElementInput || in == nil || strings.TrimSpace(in.Name) == "" {
+			continue
+		}
+		name := strings.TrimSpace(in.Name)
+		ref := regexp.MustCompile(`(^|[^A-Za-z0-9_])` + regexp.QuoteMeta(name) + `($|[^A-Za-z0-9_])`)
+		if !ref.MatchString(corpus) {
+			out = append(out, finding(el, "input %q is never referenced by the role, tasks, or other content", name))
+		}
+	}
+	return out
+}
+
+// bodyText returns the free text of prompt-bearing payloads.
+func bodyText(p poml.ElementPayload) string {
+	switch {
+	case p.Role != nil:
+		return p.Role.Body
+	case p.Task != nil:
+		return p.Task.Body
+	case p.Hint != nil:
+		return p.Hint.Body
+	case p.Example != nil:
+		return p.Example.Body
+	case p.ContentPart != nil:
+		return p.ContentPart.Body
+	case p.Message != nil:
+		return p.Message.Body
+	case p.OutputFormat != nil:
+		return p.OutputFormat.Body
+	}
+	return ""
+}
+
+func checkImageAlt(doc poml.Document) []LintFinding {
+	var out []LintFinding
+	els, payloads := elements(doc)
+	for i, el := range els {
+		if im := payloads[i].Image; el.Type == poml.ElementImage && im != nil && strings.TrimSpace(im.Alt) == "" {
+			out = append(out, finding(el, "image %q has no alt text for models without vision", im.Src))
+		}
+	}
+	return out
+}
+
+// checkUnknownElements reports top-level tags POML does not define; they are kept for
+// round-tripping but ignored by every converter, so they are usually typos.
+func checkUnknownElements(doc poml.Document) []LintFinding {
+	var out []LintFinding
+	els, _ := elements(doc)
+	for _, el := range els {
+		if el.Type != poml.ElementUnknown {
+			continue
+		}
+		if tag := poml.SuggestTag(el.Name); tag != "" {
+			out = append(out, finding(el, "unknown element <%s>; did you mean <%s>?", el.Name, tag))
+		} else {
+			out = append(out, finding(el, "unknown element <%s> is ignored by converters", el.Name))
+		}
+	}
+	return out
+}
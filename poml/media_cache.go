@@ -0,0 +1,161 @@
+package poml
+
+import (
+	"container/list"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// MediaCache stores encoded image, audio, and video payloads so repeated conversions skip the
+// read and Base64 step. Keys are built by the converter and change whenever the source does:
+// local files are keyed by resolved path, modification time, and size; remote sources by URL,
+// with the entry's ETag revalidated through a ConditionalAssetFetcher (remote sources are not
+// cached when the AssetFetcher cannot revalidate). Implementations must be safe for concurrent
+// use.
+type MediaCache interface {
+	Get(key string) (CachedMedia, bool)
+	Put(key string, media CachedMedia)
+}
+
+// CachedMedia is one MediaCache entry.
+type CachedMedia struct {
+	Data        string // Base64 payload
+	ContentType string // media type a remote fetch reported, if any
+	ETag        string // remote validator; empty for local files
+	Size        int64  // decoded size in bytes, checked against MaxImageBytes/MaxMediaBytes
+}
+
+// readAssetCached is readAsset through opts.MediaCache.
+func readAssetCached(src string, limit int64, opts ConvertOptions, kind string) (string, string, error) {
+	cache := opts.MediaCache
+	if isRemoteSrc(src) {
+		fetcher, ok := opts.AssetFetcher.(ConditionalAssetFetcher)
+		if !ok {
+			return readAsset(src, limit, opts, kind)
+		}
+		ctx := opts.readContext()
+		if err := ctx.Err(); err != nil {
+			return "", "", fmt.Errorf("fetch asset %s: %w", src, err)
+		}
+		key := "url:" + src
+		cached, hit := cache.Get(key)
+		etag := ""
+		if hit {
+			etag = cached.ETag
+		}
+		raw, contentType, newETag, notModified, err := fetcher.FetchAssetConditional(ctx, src, limit, etag)
+		if err != nil {
+			return "", "", err
+		}
+		if notModified && hit {
+			if err := enforceByteLimit(cached.Size, limit, "file "+src); err != nil {
+				return "", "", err
+			}
+			return cached.Data, cached.ContentType, nil
+		}
+		data := base64.StdEncoding.EncodeToString(raw)
+		if newETag != "" {
+			cache.Put(key, CachedMedia{Data: data, ContentType: contentType, ETag: newETag, Size: int64(len(raw))})
+		}
+		return data, contentType, nil
+	}
+
+	key, ok := localAssetKey(src, opts)
+	if !ok {
+		return readAsset(src, limit, opts, kind)
+	}
+	if cached, hit := cache.Get(key); hit {
+		if err := enforceByteLimit(cached.Size, limit, kind+" "+src); err != nil {
+			return "", "", err
+		}
+		return cached.Data, cached.ContentType, nil
+	}
+	raw, err := readLocalAsset(src, limit, opts, kind)
+	if err != nil {
+		return "", "", err
+	}
+	data := base64.StdEncoding.EncodeToString(raw)
+	cache.Put(key, CachedMedia{Data: data, Size: int64(len(raw))})
+	return data, "", nil
+}
+
+// localAssetKey identifies the current version of a local src, or reports false when it cannot
+// be resolved or stat'ed (the read then reports the error).
+func localAssetKey(src string, opts ConvertOptions) (string, bool) {
+	var info fs.FileInfo
+	var name string
+	var err error
+	switch {
+	case opts.FS != nil:
+		if name, err = fsAssetPath(src, opts.BaseDir); err == nil {
+			info, err = fs.Stat(opts.FS, name)
+			name = "fs:" + name
+		}
+	case hostFileAccess() != nil:
+		return "", false
+	default:
+		if name, err = resolveImagePath(src, opts); err == nil {
+			info, err = os.Stat(name)
+			name = "file:" + name
+		}
+	}
+	if err != nil {
+		return "", false
+	}
+	return name + "@" + strconv.FormatInt(info.ModTime().UnixNano(), 10) + ":" + strconv.FormatInt(info.Size(), 10), true
+}
+
+// NewMemoryMediaCache returns an in-process MediaCache that evicts the least recently used
+// entries once their Base64 payloads exceed maxBytes (unbounded when maxBytes <= 0).
+func NewMemoryMediaCache(maxBytes int64) MediaCache {
+	return &memoryMediaCache{max: maxBytes, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+type memoryMediaCache struct {
+	mu      sync.Mutex
+	max     int64
+	size    int64
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+}
+
+type memoryMediaEntry struct {
+	key   string
+	media CachedMedia
+}
+
+func (c *memoryMediaCache) Get(key string) (CachedMedia, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return CachedMedia{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*memoryMediaEntry).media, true
+}
+
+func (c *memoryMediaCache) Put(key string, media CachedMedia) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.max > 0 && int64(len(media.Data)) > c.max {
+		return
+	}
+	if el, ok := c.entries[key]; ok {
+		c.size -= int64(len(el.Value.(*memoryMediaEntry).media.Data))
+		c.order.Remove(el)
+	}
+	c.entries[key] = c.order.PushFront(&memoryMediaEntry{key: key, media: media})
+	c.size += int64(len(media.Data))
+	for c.max > 0 && c.size > c.max {
+		oldest := c.order.Back()
+		e := oldest.Value.(*memoryMediaEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, e.key)
+		c.size -= int64(len(e.media.Data))
+	}
+}
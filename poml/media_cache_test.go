@@ -0,0 +1,108 @@
+package poml
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+type countingMediaCache struct {
+	MediaCache
+	hits, puts int
+}
+
+func (c *countingMediaCache) Get(key string) (CachedMedia, bool) {
+	m, ok := c.MediaCache.Get(key)
+	if ok {
+		c.hits++
+	}
+	return m, ok
+}
+
+func (c *countingMediaCache) Put(key string, m CachedMedia) {
+	c.puts++
+	c.MediaCache.Put(key, m)
+}
+
+func TestMediaCacheLocalAndRemote(t *testing.T) {
+	png, err := base64.StdEncoding.DecodeString(pngData)
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.png"), png, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var full, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(png)
+	}))
+	defer srv.Close()
+
+	doc, err := ParseString(`<poml><img src="a.png" alt="local"/><img src="` + srv.URL + `/b.png" alt="remote"/></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	cache := &countingMediaCache{MediaCache: NewMemoryMediaCache(0)}
+	opts := ConvertOptions{BaseDir: dir, AssetFetcher: HTTPAssetFetcher{}, MediaCache: cache}
+	first, err := Convert(doc, FormatOpenAIChat, opts)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	second, err := Convert(doc, FormatOpenAIChat, opts)
+	if err != nil {
+		t.Fatalf("convert again: %v", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("cached conversion differs")
+	}
+	if cache.puts != 2 || cache.hits != 2 || full.Load() != 1 || notModified.Load() != 1 {
+		t.Fatalf("puts=%d hits=%d full=%d notModified=%d", cache.puts, cache.hits, full.Load(), notModified.Load())
+	}
+
+	// Changing the file changes its key, so the stale payload is not reused.
+	if err := os.WriteFile(filepath.Join(dir, "a.png"), append(png, 0), 0o644); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if _, err := Convert(doc, FormatOpenAIChat, opts); err != nil {
+		t.Fatalf("convert after change: %v", err)
+	}
+	if cache.puts != 3 {
+		t.Fatalf("changed file should be re-read, puts=%d", cache.puts)
+	}
+	opts.MaxImageBytes = 8
+	if _, err := Convert(doc, FormatOpenAIChat, opts); err == nil {
+		t.Fatalf("cached payloads must still respect MaxImageBytes")
+	}
+}
+
+func TestMemoryMediaCacheEvicts(t *testing.T) {
+	cache := NewMemoryMediaCache(8)
+	cache.Put("a", CachedMedia{Data: "aaaa"})
+	cache.Put("b", CachedMedia{Data: "bbbb"})
+	cache.Get("a")
+	cache.Put("c", CachedMedia{Data: "cccc"})
+	if _, ok := cache.Get("b"); ok {
+		t.Fatalf("least recently used entry should be evicted")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatalf("recently used entry should survive")
+	}
+	cache.Put("huge", CachedMedia{Data: "0123456789"})
+	if _, ok := cache.Get("huge"); ok {
+		t.Fatalf("entries larger than the cache should be skipped")
+	}
+}
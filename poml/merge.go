@@ -0,0 +1,176 @@
+package poml
+
+import "fmt"
+
+// MergeStrategy decides how overlay elements of one type combine with the base document.
+type MergeStrategy string
+
+const (
+	// MergeAppend places overlay elements after the last base element of the same type (or at the
+	// end when the base has none). Singletons (meta, role, output-schema) are replaced instead.
+	MergeAppend MergeStrategy = "append"
+	// MergeReplace drops every base element of the type and puts the overlay's in their place.
+	MergeReplace MergeStrategy = "replace"
+	// MergeDedupe replaces base elements sharing a natural key with the overlay element (input
+	// name, tool name, tool call id, document src, diagram id) and appends the rest.
+	MergeDedupe MergeStrategy = "dedupe"
+	// MergeKeepBase ignores overlay elements when the base already has that type.
+	MergeKeepBase MergeStrategy = "keep_base"
+	// MergeError fails the merge when both documents contain the type.
+	MergeError MergeStrategy = "error"
+)
+
+// MergePolicy maps element types to strategies; types not listed use Default (MergeAppend when empty).
+type MergePolicy struct {
+	Default MergeStrategy
+	ByType  map[ElementType]MergeStrategy
+}
+
+// DefaultMergePolicy composes a base prompt with an overlay: the overlay's role, meta, and output
+// schema replace the base ones, inputs and tool definitions dedupe by name, and everything else
+// appends.
+func DefaultMergePolicy() MergePolicy {
+	return MergePolicy{
+		Default: MergeAppend,
+		ByType: map[ElementType]MergeStrategy{
+			ElementMeta:           MergeReplace,
+			ElementRole:           MergeReplace,
+			ElementOutputSchema:   MergeReplace,
+			ElementInput:          MergeDedupe,
+			ElementToolDefinition: MergeDedupe,
+		},
+	}
+}
+
+func (p MergePolicy) strategy(t ElementType) MergeStrategy {
+	if s, ok := p.ByType[t]; ok && s != "" {
+		return s
+	}
+	if p.Default != "" {
+		return p.Default
+	}
+	return MergeAppend
+}
+
+// mergeEntry is an element with its payload as it will appear in the merged document.
+type mergeEntry struct {
+	el       Element
+	payload  ElementPayload
+	key      string
+	fromBase bool
+}
+
+// Merge combines base and overlay into a new document according to policy. Neither input is
+// modified; element IDs are reassigned in the result. Whitespace and comments travel with
+// their elements.
+func Merge(base, overlay Document, policy MergePolicy) (Document, error) {
+	var entries []mergeEntry
+	baseTypes := map[ElementType]bool{}
+	for _, el := range base.resolveOrder() {
+		entries = append(entries, mergeEntry{el: el, payload: base.payloadFor(el), key: diffNaturalKey(base, el), fromBase: true})
+		baseTypes[el.Type] = true
+	}
+	replaced := map[ElementType]bool{}
+	for _, el := range overlay.resolveOrder() {
+		entry := mergeEntry{el: el, payload: overlay.payloadFor(el), key: diffNaturalKey(overlay, el)}
+		strategy := policy.strategy(el.Type)
+		if strategy == MergeAppend && isSingletonElement(el.Type) {
+			strategy = MergeReplace
+		}
+		switch strategy {
+		case MergeAppend:
+			entries = insertAfterLastOfType(entries, entry)
+		case MergeReplace:
+			if replaced[el.Type] {
+				entries = insertAfterLastOfType(entries, entry)
+				continue
+			}
+			replaced[el.Type] = true
+			pos := -1
+			kept := entries[:0:0]
+			for _, e := range entries {
+				if e.fromBase && e.el.Type == el.Type {
+					if pos < 0 {
+						pos = len(kept)
+					}
+					continue
+				}
+				kept = append(kept, e)
+			}
+			if pos < 0 {
+				entries = append(kept, entry)
+				continue
+			}
+			entries = append(kept[:pos], append([]mergeEntry{entry}, kept[pos:]...)...)
+		case MergeDedupe:
+			found := false
+			if entry.key != "" {
+				for i := range entries {
+					if entries[i].el.Type == el.Type && entries[i].key == entry.key {
+						entry.el.Leading, entry.el.Trailing = entries[i].el.Leading, entries[i].el.Trailing
+						entries[i] = entry
+						found = true
+						break
+					}
+				}
+			}
+			if !found {
+				entries = insertAfterLastOfType(entries, entry)
+			}
+		case MergeKeepBase:
+			if !baseTypes[el.Type] {
+				entries = insertAfterLastOfType(entries, entry)
+			}
+		case MergeError:
+			if baseTypes[el.Type] {
+				return Document{}, &POMLError{Type: ErrValidate, Code: CodeMerge, Message: fmt.Sprintf("merge: both documents define %s", el.Type)}
+			}
+			entries = insertAfterLastOfType(entries, entry)
+		default:
+			return Document{}, fmt.Errorf("merge: unknown strategy %q for %s", strategy, el.Type)
+		}
+	}
+	return buildMergedDocument(entries), nil
+}
+
+func isSingletonElement(t ElementType) bool {
+	return t == ElementMeta || t == ElementRole || t == ElementOutputSchema
+}
+
+func insertAfterLastOfType(entries []mergeEntry, entry mergeEntry) []mergeEntry {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].el.Type == entry.el.Type {
+			return append(entries[:i+1], append([]mergeEntry{entry}, entries[i+1:]...)...)
+		}
+	}
+	return append(entries, entry)
+}
+
+func buildMergedDocument(entries []mergeEntry) Document {
+	doc := Document{nextID: 1}
+	counts := map[ElementType]int{}
+	for _, e := range entries {
+		var el Element
+		switch t := e.el.Type; {
+		case t == ElementMeta && e.payload.Meta != nil:
+			doc.Meta = *e.payload.Meta
+			el = doc.newElement(t, -1, e.el.Name)
+		case t == ElementRole && e.payload.Role != nil:
+			doc.Role = *e.payload.Role
+			el = doc.newElement(t, -1, e.el.Name)
+		case t == ElementOutputSchema && e.payload.Schema != nil:
+			doc.Schema = *e.payload.Schema
+			el = doc.newElement(t, -1, e.el.Name)
+		case t == ElementUnknown:
+			el = doc.newElement(t, -1, e.el.Name, e.el.RawXML)
+		default:
+			group := indexGroup(t)
+			doc.insertPayloadAt(counts[group], e.payload)
+			el = doc.newElement(t, counts[group], e.el.Name)
+			counts[group]++
+		}
+		el.Comment, el.Leading, el.Trailing, el.Namespace = e.el.Comment, e.el.Leading, e.el.Trailing, e.el.Namespace
+		doc.Elements = append(doc.Elements, el)
+	}
+	return doc
+}
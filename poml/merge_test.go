@@ -0,0 +1,90 @@
+package poml
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMergeDefaultPolicy(t *testing.T) {
+	base, err := ParseString(`<poml>
+  <role>Base role</role>
+  <task>Base task</task>
+  <input name="topic">base topic</input>
+  <input name="tone">neutral</input>
+  <tool-definition name="search" description="v1" />
+  <human-msg>hello</human-msg>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse base: %v", err)
+	}
+	overlay, err := ParseString(`<poml>
+  <role>Team role</role>
+  <task>Team task</task>
+  <input name="topic">team topic</input>
+  <input name="audience">analysts</input>
+  <tool-definition name="search" description="v2" />
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse overlay: %v", err)
+	}
+	merged, err := Merge(base, overlay, DefaultMergePolicy())
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if merged.RoleText() != "Team role" {
+		t.Fatalf("role not replaced: %q", merged.Role.Body)
+	}
+	if got := strings.Join(merged.TaskBodies(), "|"); got != "Base task|Team task" {
+		t.Fatalf("tasks not appended: %s", got)
+	}
+	var inputs []string
+	for _, in := range merged.Inputs {
+		inputs = append(inputs, in.Name+"="+strings.TrimSpace(in.Body))
+	}
+	if got := strings.Join(inputs, ","); got != "topic=team topic,tone=neutral,audience=analysts" {
+		t.Fatalf("inputs not deduped in place: %s", got)
+	}
+	if len(merged.ToolDefs) != 1 || merged.ToolDefs[0].Description != "v2" {
+		t.Fatalf("tool definitions not deduped: %+v", merged.ToolDefs)
+	}
+	var order []string
+	for _, el := range merged.Elements {
+		order = append(order, string(el.Type))
+	}
+	if got := strings.Join(order, ","); got != "role,task,task,input,input,input,tool_definition,human_msg" {
+		t.Fatalf("unexpected element order: %s", got)
+	}
+	var buf bytes.Buffer
+	if err := merged.EncodeWithOptions(&buf, EncodeOptions{PreserveOrder: true}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if _, err := ParseString(buf.String()); err != nil {
+		t.Fatalf("merged document does not reparse: %v\n%s", err, buf.String())
+	}
+	if strings.TrimSpace(base.Inputs[0].Body) != "base topic" {
+		t.Fatalf("merge mutated base document")
+	}
+}
+
+func TestMergeStrategies(t *testing.T) {
+	base, _ := ParseString(`<poml><task>a</task><task>b</task><hint>h</hint></poml>`)
+	overlay, _ := ParseString(`<poml><task>c</task><hint>o</hint></poml>`)
+
+	replaced, err := Merge(base, overlay, MergePolicy{ByType: map[ElementType]MergeStrategy{ElementTask: MergeReplace, ElementHint: MergeKeepBase}})
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if got := strings.Join(replaced.TaskBodies(), "|"); got != "c" || len(replaced.Hints) != 1 || replaced.Hints[0].Body != "h" {
+		t.Fatalf("replace/keep_base mismatch: tasks=%s hints=%+v", got, replaced.Hints)
+	}
+	_, err = Merge(base, overlay, MergePolicy{Default: MergeError})
+	var perr *POMLError
+	if !errors.As(err, &perr) || perr.Type != ErrValidate {
+		t.Fatalf("expected validation error for conflicting types, got %v", err)
+	}
+	if _, err := Merge(base, overlay, MergePolicy{Default: "bogus"}); err == nil {
+		t.Fatalf("expected unknown strategy error")
+	}
+}
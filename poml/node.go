@@ -0,0 +1,216 @@
+package poml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// NodeKind identifies the type of a Node.
+type NodeKind string
+
+const (
+	NodeElement NodeKind = "element"
+	NodeText    NodeKind = "text"
+	NodeComment NodeKind = "comment"
+)
+
+// Node is a generic XML tree for markup the parser keeps as raw XML, such as extension tags
+// (ElementUnknown). Names keep their prefix as written ("x:custom"), so namespaced markup
+// re-serializes unchanged; CDATA sections become plain text.
+type Node struct {
+	Kind     NodeKind
+	Name     string // tag name for NodeElement
+	Attrs    Attrs  // attributes for NodeElement, names as written
+	Text     string // unescaped text for NodeText, comment body for NodeComment
+	Children []Node // child elements, text, and comments of a NodeElement
+}
+
+// ParseNode parses raw, which must hold exactly one element (surrounding whitespace and
+// comments aside), into a Node tree.
+func ParseNode(raw string) (Node, error) {
+	dec := xml.NewDecoder(strings.NewReader(raw))
+	var root *Node
+	for {
+		tok, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Node{}, wrapXMLError(err, "parse node")
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if root != nil {
+				return Node{}, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "parse node: more than one root element"}
+			}
+			n, err := decodeNode(dec, t)
+			if err != nil {
+				return Node{}, wrapXMLError(err, "parse node")
+			}
+			root = &n
+		case xml.CharData:
+			if strings.TrimSpace(string(t)) != "" {
+				return Node{}, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "parse node: text outside the root element"}
+			}
+		}
+	}
+	if root == nil {
+		return Node{}, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "parse node: no element"}
+	}
+	return *root, nil
+}
+
+func decodeNode(dec *xml.Decoder, start xml.StartElement) (Node, error) {
+	n := Node{Kind: NodeElement, Name: formatName(start.Name), Attrs: Attrs(start.Copy().Attr)}
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return Node{}, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeNode(dec, t)
+			if err != nil {
+				return Node{}, err
+			}
+			n.Children = append(n.Children, child)
+		case xml.EndElement:
+			if name := formatName(t.Name); name != n.Name {
+				return Node{}, fmt.Errorf("element <%s> closed by </%s>", n.Name, name)
+			}
+			return n, nil
+		case xml.CharData:
+			if last := len(n.Children) - 1; last >= 0 && n.Children[last].Kind == NodeText {
+				n.Children[last].Text += string(t)
+				continue
+			}
+			n.Children = append(n.Children, Node{Kind: NodeText, Text: string(t)})
+		case xml.Comment:
+			n.Children = append(n.Children, Node{Kind: NodeComment, Text: string(t)})
+		}
+	}
+}
+
+// XML serializes n. Empty elements are written self-closing.
+func (n Node) XML() string {
+	var b strings.Builder
+	n.writeXML(&b)
+	return b.String()
+}
+
+func (n Node) writeXML(b *strings.Builder) {
+	switch n.Kind {
+	case NodeText:
+		b.WriteString(escapeBodyText(n.Text))
+	case NodeComment:
+		b.WriteString("<!--" + n.Text + "-->")
+	default:
+		b.WriteString("<" + n.Name)
+		for _, a := range n.Attrs {
+			b.WriteString(" " + formatName(a.Name) + `="` + escapeFormatAttr(a.Value) + `"`)
+		}
+		if len(n.Children) == 0 {
+			b.WriteString("/>")
+			return
+		}
+		b.WriteByte('>')
+		for _, c := range n.Children {
+			c.writeXML(b)
+		}
+		b.WriteString("</" + n.Name + ">")
+	}
+}
+
+// InnerText returns the text of n and its descendants, concatenated in order.
+func (n Node) InnerText() string {
+	if n.Kind == NodeText {
+		return n.Text
+	}
+	var b strings.Builder
+	for _, c := range n.Children {
+		if c.Kind != NodeComment {
+			b.WriteString(c.InnerText())
+		}
+	}
+	return b.String()
+}
+
+// SetText replaces n's children with a single text node (none when text is empty).
+func (n *Node) SetText(text string) {
+	n.Children = nil
+	if text != "" {
+		n.Children = []Node{{Kind: NodeText, Text: text}}
+	}
+}
+
+// Child returns a pointer to the first child element named name, or nil. Edits through the
+// pointer change n.
+func (n *Node) Child(name string) *Node {
+	for i := range n.Children {
+		if c := &n.Children[i]; c.isElement() && c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Elements returns the child elements of n, skipping text and comments.
+func (n Node) Elements() []Node {
+	var out []Node
+	for _, c := range n.Children {
+		if c.isElement() {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// isElement reports whether n is an element; a zero Kind counts as one, so nodes built as
+// Node{Name: "x"} work.
+func (n Node) isElement() bool { return n.Kind == NodeElement || n.Kind == "" }
+
+// AppendChild adds c as the last child of n.
+func (n *Node) AppendChild(c Node) {
+	n.Children = append(n.Children, c)
+}
+
+// UnknownNode parses the raw XML of an unknown element into a Node. Parsing happens on each
+// call; edit the result and store it back with Mutator.SetUnknownNode.
+func (d Document) UnknownNode(el Element) (Node, error) {
+	if el.Type != ElementUnknown {
+		return Node{}, fmt.Errorf("unknown node: %s is a %s element", el.ID, el.Type)
+	}
+	return ParseNode(el.RawXML)
+}
+
+// SetUnknownNode replaces the raw XML of the unknown element el (matched by ID) with n
+// serialized, and updates the element's Name to n's local name.
+func (m *Mutator) SetUnknownNode(el Element, n Node) error {
+	if !n.isElement() {
+		return fmt.Errorf("set unknown node %s: %s node is not an element", el.ID, n.Kind)
+	}
+	n.Kind = NodeElement
+	for i := range m.doc.Elements {
+		cur := &m.doc.Elements[i]
+		if cur.ID != el.ID {
+			continue
+		}
+		if cur.Type != ElementUnknown {
+			return fmt.Errorf("set unknown node %s: %s is not an unknown element", el.ID, cur.Type)
+		}
+		_, local, ok := strings.Cut(n.Name, ":")
+		if !ok {
+			local = n.Name
+		}
+		cur.Name, cur.RawXML = local, n.XML()
+		m.modified = true
+		return nil
+	}
+	return fmt.Errorf("set unknown node %s: element not found", el.ID)
+}
@@ -0,0 +1,50 @@
+package poml
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestUnknownNodeEditRoundTrip(t *testing.T) {
+	raw := `<x:policy xmlns:x="urn:x" level="2"><!-- keep --><x:rule id="a">no &lt;secrets&gt;</x:rule><note/></x:policy>`
+	doc, err := ParseString("<poml>\n  <task>t</task>\n  " + raw + "\n</poml>")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	n, err := doc.UnknownNode(doc.Elements[1])
+	if err != nil {
+		t.Fatalf("unknown node: %v", err)
+	}
+	if n.XML() != raw {
+		t.Fatalf("unedited node should re-serialize unchanged:\n%s", n.XML())
+	}
+	rule := n.Child("x:rule")
+	if rule == nil || rule.Attrs.Get("id") != "a" || rule.InnerText() != "no <secrets>" || len(n.Elements()) != 2 {
+		t.Fatalf("unexpected tree: %+v", n)
+	}
+	rule.SetText("be & kind")
+	n.Attrs.Set("level", "3")
+	n.AppendChild(Node{Name: "x:rule", Attrs: Attrs{{Name: xml.Name{Local: "id"}, Value: "b"}}})
+
+	err = doc.Mutate(func(el Element, _ ElementPayload, m *Mutator) error {
+		if el.Type == ElementUnknown {
+			return m.SetUnknownNode(el, n)
+		}
+		if err := m.SetUnknownNode(el, n); err == nil {
+			t.Fatalf("known elements should be rejected")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("mutate: %v", err)
+	}
+	out := encodeString(t, doc, EncodeOptions{PreserveOrder: true, PreserveWS: true})
+	want := `<x:policy xmlns:x="urn:x" level="3"><!-- keep --><x:rule id="a">be &amp; kind</x:rule><note/><x:rule id="b"/></x:policy>`
+	if !strings.Contains(out, "\n  "+want+"\n") || doc.Elements[1].Name != "policy" {
+		t.Fatalf("edited node not encoded:\n%s", out)
+	}
+	if _, err := ParseNode("<a/><b/>"); err == nil {
+		t.Fatalf("two roots should fail")
+	}
+}
@@ -0,0 +1,335 @@
+package poml
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// objectSyntax normalizes an <object> syntax attribute; empty means json.
+func objectSyntax(syntax string) string {
+	switch s := strings.ToLower(strings.TrimSpace(syntax)); s {
+	case "", "json":
+		return "json"
+	case "yml":
+		return "yaml"
+	default:
+		return s
+	}
+}
+
+// Payload returns the text the object carries: its body, or the data attribute when the body is
+// empty, with the indentation shared by its lines removed. Bodies of syntaxes other than xml
+// are read as text, so CDATA sections are unwrapped and entities decoded.
+func (o ObjectTag) Payload() string {
+	body := o.Body
+	if strings.TrimSpace(body) == "" {
+		body = o.Data
+	} else if objectSyntax(o.Syntax) != "xml" || isCDATABody(body) {
+		body = plainText(body)
+	}
+	return dedentText(body)
+}
+
+// Value parses the payload according to the syntax attribute (json, the default; yaml; csv;
+// tsv; or xml) into plain Go values:
+//   - json and yaml give map[string]any, []any, string, float64 (int for yaml integers), bool,
+//     and nil.
+//   - csv and tsv give []map[string]any, one map per record keyed by the header row, with string
+//     cells.
+//   - xml gives map[string]any{root: element}, where an element is its text when it has no
+//     attributes or children, and otherwise a map holding "@name" attributes, child elements
+//     (a []any when a name repeats), and "#text".
+//
+// Errors are POMLErrors with CodeObjectDecode.
+func (o ObjectTag) Value() (any, error) {
+	syntax := objectSyntax(o.Syntax)
+	v, err := objectValue(syntax, o.Payload())
+	if err != nil {
+		return nil, objectError(syntax, err)
+	}
+	return v, nil
+}
+
+// Decode parses the payload into v, which must be a non-nil pointer. json and yaml decode like
+// encoding/json, and xml like encoding/xml unless v points to an interface or map, which get
+// Value's form. csv and tsv fill a *[][]string with the raw records, header included, or a
+// pointer to a slice of structs (or struct pointers) whose fields match header columns by json
+// tag or name, case-insensitively; cells convert to strings, bools, numbers, pointers to those
+// (an empty cell leaves nil), and encoding.TextUnmarshaler. Other targets get Value's form
+// through encoding/json.
+func (o ObjectTag) Decode(v any) error {
+	syntax := objectSyntax(o.Syntax)
+	if err := decodeObject(syntax, o.Payload(), v); err != nil {
+		return objectError(syntax, err)
+	}
+	return nil
+}
+
+func objectError(syntax string, err error) error {
+	return &POMLError{Type: ErrDecode, Code: CodeObjectDecode, Message: "decode " + syntax + " object", Err: err}
+}
+
+func objectValue(syntax, payload string) (any, error) {
+	switch syntax {
+	case "json":
+		var v any
+		err := json.Unmarshal([]byte(payload), &v)
+		return v, err
+	case "yaml":
+		return parseYAML(payload)
+	case "csv", "tsv":
+		records, err := readObjectRecords(syntax, payload)
+		if err != nil {
+			return nil, err
+		}
+		rows := []map[string]any{}
+		if len(records) == 0 {
+			return rows, nil
+		}
+		for _, rec := range records[1:] {
+			row := make(map[string]any, len(rec))
+			for i, cell := range rec {
+				row[records[0][i]] = cell
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	case "xml":
+		return xmlObjectValue(payload)
+	}
+	return nil, fmt.Errorf("unsupported syntax %q", syntax)
+}
+
+func decodeObject(syntax, payload string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("decode target must be a non-nil pointer, got %T", v)
+	}
+	switch syntax {
+	case "json":
+		return json.Unmarshal([]byte(payload), v)
+	case "csv", "tsv":
+		records, err := readObjectRecords(syntax, payload)
+		if err != nil {
+			return err
+		}
+		if out, ok := v.(*[][]string); ok {
+			*out = records
+			return nil
+		}
+		if elem := rv.Elem(); elem.Kind() == reflect.Slice && structType(elem.Type().Elem()) != nil {
+			return decodeRecords(records, elem)
+		}
+	case "xml":
+		if k := rv.Elem().Kind(); k != reflect.Interface && k != reflect.Map {
+			return xml.Unmarshal([]byte(payload), v)
+		}
+	}
+	value, err := objectValue(syntax, payload)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func readObjectRecords(syntax, payload string) ([][]string, error) {
+	r := csv.NewReader(strings.NewReader(payload))
+	if syntax == "tsv" {
+		r.Comma = '\t'
+		r.LazyQuotes = true
+	}
+	r.TrimLeadingSpace = syntax == "csv"
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 {
+		seen := map[string]bool{}
+		for i, h := range records[0] {
+			h = strings.TrimSpace(h)
+			if seen[h] {
+				return nil, fmt.Errorf("duplicate column %q", h)
+			}
+			seen[h] = true
+			records[0][i] = h
+		}
+	}
+	return records, nil
+}
+
+// structType returns the struct type t or *t names, or nil.
+func structType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+// decodeRecords fills the slice out with one struct per record after the header.
+func decodeRecords(records [][]string, out reflect.Value) error {
+	elemType := out.Type().Elem()
+	st := structType(elemType)
+	fields := map[string][]int{}
+	for _, f := range reflect.VisibleFields(st) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		fields[strings.ToLower(name)] = f.Index
+	}
+	rows := reflect.MakeSlice(out.Type(), 0, max(len(records)-1, 0))
+	for r := 1; r < len(records); r++ {
+		elem := reflect.New(st).Elem()
+		for c, cell := range records[r] {
+			index, ok := fields[strings.ToLower(records[0][c])]
+			if !ok {
+				continue
+			}
+			if err := setCell(elem.FieldByIndex(index), cell); err != nil {
+				return fmt.Errorf("row %d, column %q: %w", r, records[0][c], err)
+			}
+		}
+		if elemType.Kind() == reflect.Pointer {
+			elem = elem.Addr()
+		}
+		rows = reflect.Append(rows, elem)
+	}
+	out.Set(rows)
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setCell converts a CSV cell into the field f.
+func setCell(f reflect.Value, cell string) error {
+	if reflect.PointerTo(f.Type()).Implements(textUnmarshalerType) {
+		return f.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(cell))
+	}
+	cell = strings.TrimSpace(cell)
+	switch f.Kind() {
+	case reflect.Pointer:
+		if cell == "" {
+			return nil
+		}
+		p := reflect.New(f.Type().Elem())
+		if err := setCell(p.Elem(), cell); err != nil {
+			return err
+		}
+		f.Set(p)
+		return nil
+	case reflect.String:
+		f.SetString(cell)
+		return nil
+	case reflect.Interface:
+		if f.NumMethod() == 0 {
+			f.Set(reflect.ValueOf(cell))
+			return nil
+		}
+	}
+	if cell == "" {
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(cell)
+		f.SetBool(b)
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(cell, 10, f.Type().Bits())
+		f.SetInt(n)
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(cell, 10, f.Type().Bits())
+		f.SetUint(n)
+		return err
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(cell, f.Type().Bits())
+		f.SetFloat(n)
+		return err
+	}
+	return fmt.Errorf("cannot decode into %s", f.Type())
+}
+
+// xmlObjectValue converts an XML payload into Value's generic form.
+func xmlObjectValue(payload string) (any, error) {
+	dec := xml.NewDecoder(strings.NewReader(payload))
+	dec.CharsetReader = charsetReader
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("no root element")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			v, err := xmlElementValue(dec, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{start.Name.Local: v}, nil
+		}
+	}
+}
+
+func xmlElementValue(dec *xml.Decoder, start xml.StartElement) (any, error) {
+	m := map[string]any{}
+	for _, a := range start.Attr {
+		m["@"+a.Name.Local] = a.Value
+	}
+	var text bytes.Buffer
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := xmlElementValue(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch prev := m[name].(type) {
+			case nil:
+				m[name] = child
+			case []any:
+				m[name] = append(prev, child)
+			default:
+				m[name] = []any{prev, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(m) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				m["#text"] = s
+			}
+			return m, nil
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package poml
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const objectSample = `<poml>
+  <object syntax="csv">
+    region, revenue, growth, note
+    EMEA, 1200, 0.12, "steady, strong"
+    APAC, 950, , new &amp; growing
+  </object>
+  <object syntax="yaml"><![CDATA[
+    owner: ops
+    targets:
+      - 10
+      - 20
+  ]]></object>
+  <object syntax="xml"><report id="q1"><item>a</item><item>b</item><total>2</total></report></object>
+  <object data='{"ok": true}'/>
+</poml>`
+
+type regionRow struct {
+	Region  string
+	Revenue int      `json:"revenue"`
+	Growth  *float64 `json:"growth"`
+	Note    string
+}
+
+func TestObjectDecode(t *testing.T) {
+	doc, err := ParseString(objectSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var rows []regionRow
+	if err := doc.Objects[0].Decode(&rows); err != nil {
+		t.Fatalf("decode csv: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Revenue != 1200 || rows[0].Growth == nil || *rows[0].Growth != 0.12 || rows[0].Note != "steady, strong" {
+		t.Fatalf("unexpected rows: %+v", rows)
+	}
+	if rows[1].Growth != nil || rows[1].Note != "new & growing" {
+		t.Fatalf("unexpected second row: %+v", rows[1])
+	}
+	var records [][]string
+	if err := doc.Objects[0].Decode(&records); err != nil || len(records) != 3 || records[0][0] != "region" {
+		t.Fatalf("unexpected records: %v %v", records, err)
+	}
+
+	var cfg struct {
+		Owner   string `json:"owner"`
+		Targets []int  `json:"targets"`
+	}
+	if err := doc.Objects[1].Decode(&cfg); err != nil || cfg.Owner != "ops" || !reflect.DeepEqual(cfg.Targets, []int{10, 20}) {
+		t.Fatalf("unexpected yaml decode: %+v %v", cfg, err)
+	}
+
+	var report struct {
+		ID    string   `xml:"id,attr"`
+		Items []string `xml:"item"`
+	}
+	if err := doc.Objects[2].Decode(&report); err != nil || report.ID != "q1" || len(report.Items) != 2 {
+		t.Fatalf("unexpected xml decode: %+v %v", report, err)
+	}
+	v, err := doc.Objects[2].Value()
+	want := map[string]any{"report": map[string]any{"@id": "q1", "item": []any{"a", "b"}, "total": "2"}}
+	if err != nil || !reflect.DeepEqual(v, want) {
+		t.Fatalf("unexpected xml value: %#v %v", v, err)
+	}
+
+	if v, err := doc.Objects[3].Value(); err != nil || !reflect.DeepEqual(v, map[string]any{"ok": true}) {
+		t.Fatalf("unexpected json value: %#v %v", v, err)
+	}
+
+	bad := ObjectTag{Syntax: "json", Body: "{oops"}
+	if err := bad.Decode(&v); !errors.Is(err, CodeObjectDecode) {
+		t.Fatalf("expected CodeObjectDecode, got %v", err)
+	}
+}
+
+func TestDictObjectValue(t *testing.T) {
+	doc, err := ParseString(objectSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	res, err := ConvertTypedDict(doc, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	raw, _ := json.Marshal(res)
+	msgs := res.Messages
+	rows, ok := msgs[0].Content.Object.Value.([]any)
+	if !ok || len(rows) != 2 || rows[1].(map[string]any)["region"] != "APAC" {
+		t.Fatalf("unexpected csv value: %s", raw)
+	}
+	if !strings.Contains(string(raw), `"value":{"ok":true}`) {
+		t.Fatalf("json object missing its value: %s", raw)
+	}
+}
@@ -0,0 +1,91 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ValidateOutput checks a model's reply against doc's <output-schema>, closing the loop between
+// a prompt and the structured response it asks for. modelResponse must hold a single JSON value;
+// surrounding whitespace is ignored.
+//
+// A reply that is not JSON or breaks the schema yields a POMLError with CodeOutput wrapping a
+// ValidationError: one CodeOutputJSON issue, or one CodeOutputField issue per violation whose
+// Field is a JSON Pointer to the offending value ("/items/0/name", "" for the whole reply) and
+// whose issue text reads "/items/0/name: got integer, want string". A document without a
+// schema, or with one that is not JSON, uses a keyword or format the validator does not
+// implement, has a pattern RE2 cannot compile, or a $ref outside the schema, yields a POMLError
+// with CodeOutputSchema instead.
+//
+// The bundled validator implements the assertion keywords of JSON Schema draft 2020-12 and
+// draft-07 (type, enum, const, numeric, string, array, and object bounds, properties,
+// patternProperties, additionalProperties, dependencies, allOf, anyOf, oneOf, not, if/then/else),
+// $ref within the schema, and the common formats (date-time, date, time, email, hostname, ipv4,
+// ipv6, uri, uri-reference, uuid, regex, json-pointer). Annotations such as title and default
+// are accepted and ignored; any other keyword, such as unevaluatedProperties, is rejected.
+func ValidateOutput(doc Document, modelResponse []byte) error {
+	schema, err := doc.outputSchema()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(modelResponse))
+	dec.UseNumber()
+	var v any
+	err = dec.Decode(&v)
+	if err == nil {
+		if _, extra := dec.Token(); !errors.Is(extra, io.EOF) {
+			err = errors.New("data after the JSON value")
+		}
+	}
+	if err != nil {
+		msg := "response is not JSON: " + err.Error()
+		return outputError([]string{msg}, []ValidationDetail{{Code: CodeOutputJSON, Element: ElementOutputSchema, Message: msg}})
+	}
+	violations := schema.Validate(v)
+	if len(violations) == 0 {
+		return nil
+	}
+	issues := make([]string, len(violations))
+	details := make([]ValidationDetail, len(violations))
+	for i, sv := range violations {
+		issues[i] = sv.String()
+		details[i] = ValidationDetail{Code: CodeOutputField, Element: ElementOutputSchema, Field: sv.Pointer, Message: sv.Message}
+	}
+	return outputError(issues, details)
+}
+
+func outputError(issues []string, details []ValidationDetail) error {
+	return &POMLError{
+		Type:    ErrValidate,
+		Code:    CodeOutput,
+		Message: "model response does not match output-schema",
+		Err:     &ValidationError{Issues: issues, Details: withSeverities(details)},
+	}
+}
+
+// outputSchema compiles the JSON body of the document's <output-schema>, which may be escaped
+// text or CDATA.
+func (d Document) outputSchema() (*jsonSchema, error) {
+	body := d.Schema.Body
+	if text, ok := charDataText(body); ok {
+		body = text
+	}
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, &POMLError{Type: ErrInvalidSchema, Code: CodeOutputSchema, Message: "document has no output-schema to validate against"}
+	}
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var root any
+	if err := dec.Decode(&root); err != nil {
+		return nil, &POMLError{Type: ErrInvalidSchema, Code: CodeOutputSchema, Message: "output-schema is not JSON", Err: err}
+	}
+	schema, err := compileJSONSchema(root)
+	if err != nil {
+		return nil, &POMLError{Type: ErrInvalidSchema, Code: CodeOutputSchema, Message: "output-schema", Err: err}
+	}
+	return schema, nil
+}
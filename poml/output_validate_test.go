@@ -0,0 +1,86 @@
+package poml
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const outputSchemaDoc = `<poml>
+  <task>Extract the line items.</task>
+  <output-schema><![CDATA[{
+    "type": "object",
+    "required": ["invoice", "items"],
+    "additionalProperties": false,
+    "properties": {
+      "invoice": {"type": "string", "pattern": "^INV-[0-9]+$"},
+      "items": {"type": "array", "minItems": 1, "items": {"$ref": "#/$defs/item"}}
+    },
+    "$defs": {
+      "item": {
+        "type": "object",
+        "required": ["name", "qty"],
+        "properties": {"name": {"type": "string"}, "qty": {"type": "integer", "minimum": 1}}
+      }
+    }
+  }]]></output-schema>
+</poml>`
+
+func TestValidateOutput(t *testing.T) {
+	doc, err := ParseString(outputSchemaDoc)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if err := ValidateOutput(doc, []byte(` {"invoice": "INV-7", "items": [{"name": "bolt", "qty": 3}]}`+"\n")); err != nil {
+		t.Fatalf("valid reply rejected: %v", err)
+	}
+
+	err = ValidateOutput(doc, []byte(`{"invoice": "7", "items": [{"name": 4, "qty": 0}, {"qty": 1.0}], "note": "x"}`))
+	var ve *ValidationError
+	if !errors.Is(err, CodeOutput) || !errors.Is(err, CodeOutputField) || !errors.As(err, &ve) {
+		t.Fatalf("want a CodeOutput validation error, got %v", err)
+	}
+	want := []string{
+		`/invoice: string "7" does not match pattern "^INV-[0-9]+$"`,
+		`/items/0/name: got integer, want string`,
+		`/items/0/qty: 0 is below minimum 1`,
+		`/items/1/name: required property is missing`,
+		`/note: property is not allowed`,
+	}
+	if !reflect.DeepEqual(ve.Issues, want) {
+		t.Fatalf("issues:\n got %q\nwant %q", ve.Issues, want)
+	}
+	if ve.Details[1].Field != "/items/0/name" || ve.Details[1].Message != "got integer, want string" {
+		t.Fatalf("detail = %+v", ve.Details[1])
+	}
+
+	for _, reply := range []string{`Sure! {"invoice": "INV-1"}`, `{"invoice": "INV-1", "items": []} trailing`, ``} {
+		err := ValidateOutput(doc, []byte(reply))
+		if !errors.Is(err, CodeOutputJSON) || !errors.As(err, &ve) || ve.Details[0].Field != "" {
+			t.Fatalf("reply %q: want CodeOutputJSON, got %v", reply, err)
+		}
+	}
+}
+
+func TestValidateOutputSchemaErrors(t *testing.T) {
+	for name, src := range map[string]string{
+		"missing":    `<poml><task>t</task></poml>`,
+		"not json":   `<poml><task>t</task><output-schema>an object with a name</output-schema></poml>`,
+		"not schema": `<poml><task>t</task><output-schema>["object"]</output-schema></poml>`,
+		"pattern":    `<poml><task>t</task><output-schema>{"properties": {"a": {"pattern": "(?&lt;=x)"}}}</output-schema></poml>`,
+		"remote ref": `<poml><task>t</task><output-schema>{"$ref": "https://example.com/item.json"}</output-schema></poml>`,
+		"dangling":   `<poml><task>t</task><output-schema>{"items": {"$ref": "#/$defs/nope"}}</output-schema></poml>`,
+		"keyword":    `<poml><task>t</task><output-schema>{"type": "object", "unevaluatedProperties": false}</output-schema></poml>`,
+		"format":     `<poml><task>t</task><output-schema>{"properties": {"a": {"format": "duration"}}}</output-schema></poml>`,
+	} {
+		doc, err := ParseString(src)
+		if err != nil {
+			t.Fatalf("%s: parse: %v", name, err)
+		}
+		err = ValidateOutput(doc, []byte(`{}`))
+		var pe *POMLError
+		if !errors.As(err, &pe) || pe.Code != CodeOutputSchema || pe.Type != ErrInvalidSchema {
+			t.Fatalf("%s: want CodeOutputSchema, got %v", name, err)
+		}
+	}
+}
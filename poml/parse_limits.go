@@ -0,0 +1,76 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// LimitError reports input that exceeded a ParseOptions limit.
+type LimitError struct {
+	Limit  string // "MaxDocumentBytes", "MaxElementCount", or "MaxNestingDepth"
+	Max    int64  // the configured limit
+	Offset int64  // byte offset where the limit was crossed
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("input exceeds %s (%d) at byte %d", e.Limit, e.Max, e.Offset)
+}
+
+func (o ParseOptions) hasLimits() bool {
+	return o.MaxDocumentBytes > 0 || o.MaxElementCount > 0 || o.MaxNestingDepth > 0
+}
+
+func limitError(name string, limit, offset int64) error {
+	return &POMLError{Type: ErrDecode, Code: CodeLimit, Message: "parse poml", Err: &LimitError{Limit: name, Max: limit, Offset: offset}}
+}
+
+// readLimited buffers r, reading at most limit+1 bytes when limit is positive.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &POMLError{Type: ErrDecode, Code: CodeDecode, Message: "parse poml", Err: err}
+	}
+	if limit > 0 && int64(len(data)) > limit {
+		return nil, limitError("MaxDocumentBytes", limit, limit)
+	}
+	return data, nil
+}
+
+// checkParseLimits counts elements and nesting depth with a raw token pass, stopping at the
+// first limit crossed. Syntax errors end the scan quietly; the decoder reports them with context.
+func checkParseLimits(src []byte, opts ParseOptions) error {
+	if opts.MaxDocumentBytes > 0 && int64(len(src)) > opts.MaxDocumentBytes {
+		return limitError("MaxDocumentBytes", opts.MaxDocumentBytes, opts.MaxDocumentBytes)
+	}
+	if opts.MaxElementCount <= 0 && opts.MaxNestingDepth <= 0 {
+		return nil
+	}
+	dec := xml.NewDecoder(bytes.NewReader(src))
+	dec.CharsetReader = charsetReader
+	dec.Strict = false
+	count, depth := 0, 0
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			return nil
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			count++
+			depth++
+			if opts.MaxElementCount > 0 && count > opts.MaxElementCount {
+				return limitError("MaxElementCount", int64(opts.MaxElementCount), dec.InputOffset())
+			}
+			if opts.MaxNestingDepth > 0 && depth > opts.MaxNestingDepth {
+				return limitError("MaxNestingDepth", int64(opts.MaxNestingDepth), dec.InputOffset())
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package poml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseLimits(t *testing.T) {
+	src := `<poml><task>a</task><hint>b <b>c</b></hint></poml>`
+	cases := []struct {
+		opts  ParseOptions
+		limit string
+	}{
+		{ParseOptions{MaxDocumentBytes: int64(len(src))}, ""},
+		{ParseOptions{MaxDocumentBytes: 10}, "MaxDocumentBytes"},
+		{ParseOptions{MaxElementCount: 4}, ""},
+		{ParseOptions{MaxElementCount: 3}, "MaxElementCount"},
+		{ParseOptions{MaxNestingDepth: 3}, ""},
+		{ParseOptions{MaxNestingDepth: 2}, "MaxNestingDepth"},
+		{ParseOptions{MaxNestingDepth: 2, Recover: true}, "MaxNestingDepth"},
+	}
+	for _, tc := range cases {
+		doc, err := ParseReaderWithOptions(strings.NewReader(src), tc.opts)
+		if tc.limit == "" {
+			if err != nil || len(doc.Hints) != 1 {
+				t.Fatalf("%+v: expected success, got %v", tc.opts, err)
+			}
+			continue
+		}
+		var le *LimitError
+		if !errors.As(err, &le) || le.Limit != tc.limit || !errors.Is(err, CodeLimit) {
+			t.Fatalf("%+v: expected %s limit error, got %v", tc.opts, tc.limit, err)
+		}
+	}
+
+	// Deep nesting is rejected before the decoder recurses into it.
+	deep := "<poml><hint>" + strings.Repeat("<b>", 100000) + strings.Repeat("</b>", 100000) + "</hint></poml>"
+	_, err := ParseReaderWithOptions(strings.NewReader(deep), ParseOptions{MaxNestingDepth: 64})
+	var le *LimitError
+	if !errors.As(err, &le) || le.Limit != "MaxNestingDepth" || le.Offset == 0 {
+		t.Fatalf("expected nesting limit error, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// recoverChild decodes a top-level child in ParseOptions.Recover mode. The element's original bytes
+// are sliced from src and decoded in isolation, so a bad attribute or body only loses that element:
+// it is recorded as a ParseIssue and kept as unknown raw XML. ok is false when the tokenizer itself
+// failed and parsing cannot continue.
+func (doc *Document) recoverChild(dec *pomlDecoder, start xml.StartElement, src []byte, offset int64) (Element, bool) {
+	name := start.Name.Local
+	if err := dec.Skip(); err != nil {
+		doc.recordIssue(src, offset, name, recoverMessage(err, "<"+name+">"))
+		return Element{}, false
+	}
+	raw := string(src[offset:dec.InputOffset()])
+	sub := newPOMLDecoder(strings.NewReader(raw))
+	sub.Strict = false
+	for {
+		subStart := sub.InputOffset()
+		tok, err := sub.Token()
+		if err != nil {
+			doc.recordIssue(src, offset, name, recoverMessage(err, "<"+name+">"))
+			el := doc.newElement(ElementUnknown, -1, name, raw)
+			el.Namespace = start.Name.Space
+			return el, true
+		}
+		t, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		el, err := doc.decodeChild(sub, t, subStart)
+		if err != nil {
+			doc.recordIssue(src, offset, name, recoverMessage(err, "<"+name+">"))
+			el := doc.newElement(ElementUnknown, -1, name, raw)
+			el.Namespace = start.Name.Space
+			return el, true
+		}
+		return el, true
+	}
+}
+
+// recordIssue appends a ParseIssue positioned at byte offset off within src.
+func (doc *Document) recordIssue(src []byte, off int64, element, msg string) {
+	if off > int64(len(src)) {
+		off = int64(len(src))
+	}
+	prefix := src[:off]
+	line := bytes.Count(prefix, []byte("\n")) + 1
+	col := len(prefix) - bytes.LastIndexByte(prefix, '\n')
+	doc.Issues = append(doc.Issues, ParseIssue{Line: line, Column: col, Element: element, Message: msg})
+}
+
+func recoverMessage(err error, context string) string {
+	if errors.Is(err, io.EOF) {
+		return fmt.Sprintf("%s: unexpected EOF before </poml>", context)
+	}
+	var pe *POMLError
+	if errors.As(err, &pe) && pe.Err != nil {
+		err = pe.Err
+	}
+	return fmt.Sprintf("%s: %v", context, err)
+}
@@ -0,0 +1,51 @@
+package poml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRecoverCollectsIssues(t *testing.T) {
+	src := "<poml>\n  <task>ok</task>\n  <input name=\"x\" required=\"maybe\">hi</input>\n  <hint>a &bogus; b</hint>\n  <task>tail"
+	if _, err := ParseString(src); err == nil {
+		t.Fatalf("expected strict parse to fail")
+	}
+	doc, err := ParseReaderWithOptions(strings.NewReader(src), ParseOptions{PreserveWhitespace: true, Recover: true})
+	if err != nil {
+		t.Fatalf("recover parse: %v", err)
+	}
+	if len(doc.Issues) != 2 {
+		t.Fatalf("expected 2 issues, got %+v", doc.Issues)
+	}
+	if is := doc.Issues[0]; is.Line != 3 || is.Column != 3 || is.Element != "input" || !strings.Contains(is.Message, "maybe") {
+		t.Fatalf("unexpected input issue: %+v", is)
+	}
+	if is := doc.Issues[1]; is.Line != 5 || is.Element != "task" {
+		t.Fatalf("unexpected truncation issue: %+v", is)
+	}
+	if len(doc.Tasks) != 1 || doc.Tasks[0].Body != "ok" || len(doc.Hints) != 1 {
+		t.Fatalf("expected usable content, got tasks=%+v hints=%+v", doc.Tasks, doc.Hints)
+	}
+	if el := doc.Elements[1]; el.Type != ElementUnknown || el.RawXML != `<input name="x" required="maybe">hi</input>` {
+		t.Fatalf("failed element should be kept raw: %+v", el)
+	}
+}
+
+func TestParseRecoverCleanInputHasNoIssues(t *testing.T) {
+	doc, err := ParseReaderWithOptions(strings.NewReader(sample), ParseOptions{PreserveWhitespace: true, Recover: true})
+	if err != nil {
+		t.Fatalf("recover parse: %v", err)
+	}
+	want, err := ParseString(sample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(doc.Issues) != 0 || len(doc.Elements) != len(want.Elements) {
+		t.Fatalf("recover mode diverged on clean input: issues=%+v elements=%d want %d", doc.Issues, len(doc.Elements), len(want.Elements))
+	}
+	for i := range want.Elements {
+		if doc.Elements[i] != want.Elements[i] {
+			t.Fatalf("element %d mismatch: got %+v want %+v", i, doc.Elements[i], want.Elements[i])
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package poml
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FileSet holds the results of parsing many files at once with ParseGlob or ParseDir. Every
+// matched path appears in exactly one of Documents and Errors.
+type FileSet struct {
+	Documents map[string]Document // parsed documents keyed by path
+	Errors    map[string]error    // read and parse failures keyed by path
+}
+
+// ParseGlob parses the files matching pattern (filepath.Match syntax; ** is not special)
+// concurrently with opts. Files ending in .yaml or .yml are read as the YAML form ParseYAML
+// accepts. With opts.ResolveDocuments and no DocumentResolver, <document src> paths resolve
+// relative to each file's directory. The error reports a malformed pattern; per-file failures
+// are in the FileSet.
+func ParseGlob(pattern string, opts ParseOptions) (*FileSet, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			continue
+		}
+		files = append(files, p)
+	}
+	return parseFiles(files, opts), nil
+}
+
+// ParseDir parses every .poml file under dir, recursively, as ParseGlob does. Directories
+// whose names start with a dot are skipped. The error reports a walk failure.
+func ParseDir(dir string, opts ParseOptions) (*FileSet, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() {
+			if p != dir && strings.HasPrefix(e.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(p), ".poml") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseFiles(files, opts), nil
+}
+
+func parseFiles(paths []string, opts ParseOptions) *FileSet {
+	docs := make([]Document, len(paths))
+	errs := make([]error, len(paths))
+	parallel(len(paths), func(i int) {
+		docs[i], errs[i] = parseSetFile(paths[i], opts)
+	})
+	set := &FileSet{Documents: map[string]Document{}, Errors: map[string]error{}}
+	for i, p := range paths {
+		if errs[i] != nil {
+			set.Errors[p] = errs[i]
+		} else {
+			set.Documents[p] = docs[i]
+		}
+	}
+	return set
+}
+
+func parseSetFile(path string, opts ParseOptions) (Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Document{}, err
+	}
+	defer f.Close()
+	if opts.ResolveDocuments && opts.DocumentResolver == nil {
+		opts.DocumentResolver = FileDocumentResolver{BaseDir: filepath.Dir(path)}
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		return ParseYAMLWithOptions(f, opts)
+	}
+	return parseWithOptions(f, opts)
+}
+
+// parallel calls fn(0..n-1) on up to GOMAXPROCS goroutines and waits for them to finish.
+func parallel(n int, fn func(i int)) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := min(runtime.GOMAXPROCS(0), n); w > 0; w-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// Paths returns every path in the set, parsed or failed, in sorted order.
+func (s *FileSet) Paths() []string {
+	out := make([]string, 0, len(s.Documents)+len(s.Errors))
+	for p := range s.Documents {
+		out = append(out, p)
+	}
+	for p := range s.Errors {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ValidationReport aggregates Document.Validate over a FileSet.
+type ValidationReport struct {
+	Checked int                         // documents validated
+	Failed  map[string]*ValidationError // issues keyed by path, for documents that failed
+	Other   map[string]error            // Validate errors that carry no ValidationError
+	Codes   map[ErrorCode]int           // issue count per code across all documents
+}
+
+// OK reports whether every validated document passed.
+func (r *ValidationReport) OK() bool { return len(r.Failed) == 0 && len(r.Other) == 0 }
+
+// Validate validates every parsed document concurrently. Allowed codes are dropped first, as
+// AllowCodes drops them. Files in s.Errors are not counted.
+func (s *FileSet) Validate(allow ...ErrorCode) *ValidationReport {
+	paths := make([]string, 0, len(s.Documents))
+	for p := range s.Documents {
+		paths = append(paths, p)
+	}
+	errs := make([]error, len(paths))
+	parallel(len(paths), func(i int) {
+		errs[i] = AllowCodes(s.Documents[paths[i]].Validate(), allow...)
+	})
+	r := &ValidationReport{Checked: len(paths), Failed: map[string]*ValidationError{}, Other: map[string]error{}, Codes: map[ErrorCode]int{}}
+	for i, err := range errs {
+		var ve *ValidationError
+		switch {
+		case err == nil:
+		case errors.As(err, &ve):
+			r.Failed[paths[i]] = ve
+			for _, d := range ve.Details {
+				r.Codes[d.Code]++
+			}
+		default:
+			r.Other[paths[i]] = err
+		}
+	}
+	return r
+}
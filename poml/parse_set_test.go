@@ -0,0 +1,64 @@
+package poml
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDirAndGlob(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.poml":          `<poml><meta><id>a</id><version>1</version><owner>me</owner></meta><role>r</role><task>t</task></poml>`,
+		"nested/b.poml":   `<poml><role>r</role><task>t</task></poml>`,
+		"nested/c.poml":   `<poml><task>`,
+		"nested/notes.md": `not a prompt`,
+		".git/d.poml":     `<poml/>`,
+		"e.yaml":          "poml:\n  - task: from yaml\n",
+	}
+	for name, src := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, b, c := filepath.Join(dir, "a.poml"), filepath.Join(dir, "nested", "b.poml"), filepath.Join(dir, "nested", "c.poml")
+
+	set, err := ParseDir(dir, ParseOptions{})
+	if err != nil {
+		t.Fatalf("parse dir: %v", err)
+	}
+	if got := set.Paths(); !reflect.DeepEqual(got, []string{a, b, c}) {
+		t.Fatalf("unexpected paths: %v", got)
+	}
+	if set.Errors[c] == nil || set.Documents[a].Meta.ID != "a" {
+		t.Fatalf("unexpected results: %+v", set)
+	}
+	report := set.Validate()
+	if report.OK() || report.Checked != 2 || report.Failed[a] != nil || report.Failed[b] == nil {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Codes[CodeMetaRequired] != 1 || report.Codes[CodeMetaID] != 1 {
+		t.Fatalf("unexpected code counts: %v", report.Codes)
+	}
+	allowed := set.Validate(CodeMetaRequired, CodeMetaID, CodeMetaVersion, CodeMetaOwner)
+	if !allowed.OK() {
+		t.Fatalf("expected allowed codes to pass: %+v", allowed.Failed[b])
+	}
+
+	set, err = ParseGlob(filepath.Join(dir, "*"), ParseOptions{})
+	if err != nil {
+		t.Fatalf("parse glob: %v", err)
+	}
+	yml := filepath.Join(dir, "e.yaml")
+	if got := set.Paths(); !reflect.DeepEqual(got, []string{a, yml}) || set.Documents[yml].Tasks[0].Body != "from yaml" {
+		t.Fatalf("unexpected glob results: %v %+v", got, set.Errors)
+	}
+	if _, err := ParseGlob("[", ParseOptions{}); err == nil {
+		t.Fatalf("expected a pattern error")
+	}
+}
@@ -2,11 +2,15 @@ package poml
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
+	"slices"
 	"strings"
 )
 
@@ -23,6 +27,7 @@ const (
 	ElementHumanMsg       ElementType = "human_msg"
 	ElementAssistantMsg   ElementType = "assistant_msg"
 	ElementSystemMsg      ElementType = "system_msg"
+	ElementMsg            ElementType = "msg" // <msg role="...">, for custom roles such as "critic"
 	ElementToolDefinition ElementType = "tool_definition"
 	ElementToolRequest    ElementType = "tool_request"
 	ElementToolResponse   ElementType = "tool_response"
@@ -37,6 +42,7 @@ const (
 	ElementContentPart    ElementType = "content_part"
 	ElementObject         ElementType = "object"
 	ElementRuntime        ElementType = "runtime"
+	ElementTrace          ElementType = "trace"
 	ElementImage          ElementType = "image"
 	ElementDiagram        ElementType = "diagram"
 	ElementUnknown        ElementType = "unknown"
@@ -45,15 +51,18 @@ const (
 // Element tracks an entry's type and its position in the backing slices on Document.
 // Index is -1 for singleton fields (meta/role) or unknown elements.
 type Element struct {
-	Type     ElementType
-	Index    int
-	Name     string // filled for unknown elements
-	RawXML   string // raw XML for unknown elements to preserve round-trips
-	Comment  string // optional leading comment retained when available
-	ID       string // stable element ID for mutation/lookups
-	Parent   string // parent element ID (root for top-level)
-	Leading  string // whitespace/comments preceding this element
-	Trailing string // whitespace/comments following this element (before next element/end)
+	Type      ElementType
+	Index     int
+	Name      string // filled for unknown elements
+	RawXML    string // raw XML for unknown elements to preserve round-trips
+	Comment   string // text of the comments directly preceding the element; set when parsing with PreserveWhitespace and emitted on encode
+	ID        string // stable element ID for mutation/lookups
+	Parent    string // parent element ID (root for top-level)
+	Leading   string // whitespace/comments preceding this element
+	Trailing  string // whitespace/comments following this element (before next element/end)
+	Namespace string // namespace URI resolved by the decoder (bare prefix when undeclared)
+	CDATA     bool   // body was written as CDATA; encoding keeps it CDATA even after the body is replaced with escaped text
+	AttrOrder string // space-separated attribute names in source order; set when parsing with PreserveWhitespace and honored on encode with PreserveWS
 }
 
 // Document represents a POML file.
@@ -79,11 +88,14 @@ type Document struct {
 	ToolResults  []ToolResult
 	ToolErrors   []ToolError
 	Runtimes     []Runtime
+	Traces       []Trace
 	Schema       OutputSchema
 	Images       []Image
 	Diagrams     []Diagram
+	Extends      string // extends attribute of the <poml> root; see LoadExtends
 	Elements     []Element
-	rawPrefix    string // leading text before root (e.g., XML decl); kept for future extension
+	Issues       []ParseIssue // problems skipped while parsing with ParseOptions.Recover
+	rawPrefix    string       // markup before the root (declaration, comments, doctype); see EncodeOptions.PreservePrefix
 
 	nextID int // internal counter for element IDs
 }
@@ -97,143 +109,158 @@ type Meta struct {
 
 // Block holds free-form body content for task/role/style sections.
 type Block struct {
-	Body  string     `xml:",innerxml"`
-	Attrs []xml.Attr `xml:",any,attr"`
+	Body  string `xml:",innerxml"`
+	Attrs Attrs  `xml:",any,attr"`
 }
 
 // Input represents a named input block.
 type Input struct {
-	Name     string     `xml:"name,attr"`
-	Required bool       `xml:"required,attr"`
-	Body     string     `xml:",innerxml"`
-	Attrs    []xml.Attr `xml:",any,attr"`
+	Name     string `xml:"name,attr"`
+	Required bool   `xml:"required,attr"`
+	Body     string `xml:",innerxml"`
+	Attrs    Attrs  `xml:",any,attr"`
 }
 
 // DocRef links to an external source document.
 type DocRef struct {
-	Src   string     `xml:"src,attr"`
-	Attrs []xml.Attr `xml:",any,attr"`
+	Src   string `xml:"src,attr"`
+	Attrs Attrs  `xml:",any,attr"`
+	// Content holds the fetched document text when resolution is enabled; it is never encoded.
+	Content string `xml:"-"`
 }
 
 // Style represents an <style><output format=...> block.
 type Style struct {
-	Outputs []Output   `xml:"output"`
-	Attrs   []xml.Attr `xml:",any,attr"`
+	Outputs []Output `xml:"output"`
+	Attrs   Attrs    `xml:",any,attr"`
 }
 
 // OutputFormat is a simplified format hint (<output-format>...</output-format>).
 type OutputFormat struct {
-	Body  string     `xml:",innerxml"`
-	Attrs []xml.Attr `xml:",any,attr"`
+	Body  string `xml:",innerxml"`
+	Attrs Attrs  `xml:",any,attr"`
 }
 
 // Hint represents a <hint> block that wraps supporting context.
 type Hint struct {
-	Body  string     `xml:",innerxml"`
-	Attrs []xml.Attr `xml:",any,attr"`
+	Body    string       `xml:",innerxml"`
+	Attrs   Attrs        `xml:",any,attr"`
+	Content []InlineNode `xml:"-" json:"-"` // parsed Body; set by ParseOptions.ParseInlineContent
 }
 
 // Example represents an <example> block.
 type Example struct {
-	Body  string     `xml:",innerxml"`
-	Attrs []xml.Attr `xml:",any,attr"`
+	Body    string       `xml:",innerxml"`
+	Attrs   Attrs        `xml:",any,attr"`
+	Content []InlineNode `xml:"-" json:"-"` // parsed Body; set by ParseOptions.ParseInlineContent
+	// Pair is set when Body is one <input> and one <output>; the chat converters then emit a
+	// user/assistant message pair. Body remains what Encode writes.
+	Pair *ExamplePair `xml:"-" json:"-"`
 }
 
 // ContentPart represents a captioned content part (<cp>).
 type ContentPart struct {
-	Body  string     `xml:",innerxml"`
-	Attrs []xml.Attr `xml:",any,attr"`
+	Body    string       `xml:",innerxml"`
+	Attrs   Attrs        `xml:",any,attr"`
+	Content []InlineNode `xml:"-" json:"-"` // parsed Body; set by ParseOptions.ParseInlineContent
 }
 
 // ObjectTag represents an <object> wrapper for data payloads.
 type ObjectTag struct {
-	Data   string     `xml:"data,attr"`
-	Syntax string     `xml:"syntax,attr"`
-	Body   string     `xml:",innerxml"`
-	Attrs  []xml.Attr `xml:",any,attr"`
+	Data   string `xml:"data,attr"`
+	Syntax string `xml:"syntax,attr"`
+	Body   string `xml:",innerxml"`
+	Attrs  Attrs  `xml:",any,attr"`
 }
 
 // Image represents an <img> block (often used for multimedia).
 type Image struct {
-	Src    string     `xml:"src,attr"`
-	Alt    string     `xml:"alt,attr"`
-	Syntax string     `xml:"syntax,attr"`
-	Body   string     `xml:",innerxml"`
-	Attrs  []xml.Attr `xml:",any,attr"`
+	Src    string `xml:"src,attr"`
+	Alt    string `xml:"alt,attr"`
+	Syntax string `xml:"syntax,attr"`
+	Body   string `xml:",innerxml"`
+	Attrs  Attrs  `xml:",any,attr"`
 }
 
 // Message represents <human-msg>, <assistant-msg>, or <system-msg>.
 type Message struct {
-	Role  string     `xml:"-"`
-	Body  string     `xml:",innerxml"`
-	Attrs []xml.Attr `xml:",any,attr"`
+	Role    string       `xml:"-"`
+	Body    string       `xml:",innerxml"`
+	Attrs   Attrs        `xml:",any,attr"`
+	Content []InlineNode `xml:"-" json:"-"` // parsed Body; set by ParseOptions.ParseInlineContent
 }
 
 // ToolDefinition describes a tool/function exposed to the model.
 type ToolDefinition struct {
-	Name        string     `xml:"name,attr"`
-	Description string     `xml:"description,attr"`
-	Body        string     `xml:",innerxml"`
-	Attrs       []xml.Attr `xml:",any,attr"`
+	Name        string `xml:"name,attr"`
+	Description string `xml:"description,attr"`
+	Body        string `xml:",innerxml"`
+	Attrs       Attrs  `xml:",any,attr"`
 }
 
 // ToolRequest captures a tool call issued by the model.
 type ToolRequest struct {
-	ID         string     `xml:"id,attr"`
-	Name       string     `xml:"name,attr"`
-	Parameters string     `xml:"parameters,attr"`
-	Attrs      []xml.Attr `xml:",any,attr"`
+	ID         string `xml:"id,attr"`
+	Name       string `xml:"name,attr"`
+	Parameters string `xml:"parameters,attr"`
+	Attrs      Attrs  `xml:",any,attr"`
 }
 
 // ToolResponse captures a tool response.
 type ToolResponse struct {
-	ID    string     `xml:"id,attr"`
-	Name  string     `xml:"name,attr"`
-	Body  string     `xml:",innerxml"`
-	Attrs []xml.Attr `xml:",any,attr"`
+	ID    string `xml:"id,attr"`
+	Name  string `xml:"name,attr"`
+	Body  string `xml:",innerxml"`
+	Attrs Attrs  `xml:",any,attr"`
 }
 
 // ToolResult captures a tool call result (success).
 type ToolResult struct {
-	ID    string     `xml:"id,attr"`
-	Name  string     `xml:"name,attr"`
-	Body  string     `xml:",innerxml"`
-	Attrs []xml.Attr `xml:",any,attr"`
+	ID    string `xml:"id,attr"`
+	Name  string `xml:"name,attr"`
+	Body  string `xml:",innerxml"`
+	Attrs Attrs  `xml:",any,attr"`
 }
 
 // ToolError captures an error from a tool call.
 type ToolError struct {
-	ID    string     `xml:"id,attr"`
-	Name  string     `xml:"name,attr"`
-	Body  string     `xml:",innerxml"`
-	Attrs []xml.Attr `xml:",any,attr"`
+	ID    string `xml:"id,attr"`
+	Name  string `xml:"name,attr"`
+	Body  string `xml:",innerxml"`
+	Attrs Attrs  `xml:",any,attr"`
 }
 
 // OutputSchema represents a JSON schema block.
 type OutputSchema struct {
-	Body  string     `xml:",innerxml"`
-	Attrs []xml.Attr `xml:",any,attr"`
+	Body  string `xml:",innerxml"`
+	Attrs Attrs  `xml:",any,attr"`
 }
 
 // Runtime captures model/runtime hints.
 type Runtime struct {
-	Attrs []xml.Attr `xml:",any,attr"`
+	Attrs Attrs `xml:",any,attr"`
+}
+
+// Trace carries observability metadata (experiment, dataset, tags, ...) as attributes; see
+// Document.TraceMetadata.
+type Trace struct {
+	Attrs Attrs `xml:",any,attr"`
 }
 
 // Output holds a single output format entry.
 type Output struct {
-	Format string     `xml:"format,attr"`
-	Body   string     `xml:",innerxml"`
-	Attrs  []xml.Attr `xml:",any,attr"`
+	Format string `xml:"format,attr"`
+	Body   string `xml:",innerxml"`
+	Attrs  Attrs  `xml:",any,attr"`
 }
 
 // Media represents audio/video payloads.
 type Media struct {
-	Src    string     `xml:"src,attr"`
-	Alt    string     `xml:"alt,attr"`
-	Syntax string     `xml:"syntax,attr"`
-	Body   string     `xml:",innerxml"`
-	Attrs  []xml.Attr `xml:",any,attr"`
+	Src    string `xml:"src,attr"`
+	Alt    string `xml:"alt,attr"`
+	Syntax string `xml:"syntax,attr"`
+	Body   string `xml:",innerxml"`
+	Attrs  Attrs  `xml:",any,attr"`
 }
 
 // EncodeOptions controls XML serialization.
@@ -241,8 +268,55 @@ type EncodeOptions struct {
 	Indent        string // indentation used for Encode/EncodeWithOptions; default "  "
 	IncludeHeader bool   // emit xml.Header when true
 	PreserveOrder bool   // when true and Elements populated, emit in original order
-	PreserveWS    bool   // when true, emit preserved Leading/Trailing whitespace/comments
+	PreserveWS    bool   // when true, emit preserved Leading/Trailing whitespace/comments and source attribute order
 	Compact       bool   // when true, disable indentation
+	ForceCDATA    bool   // write text-only bodies containing '<' or '&' as CDATA instead of escaped text
+
+	// Declaration customizes the XML declaration; setting any field writes one, as IncludeHeader does.
+	Declaration XMLDeclaration
+	// Doctype, when set, writes <!DOCTYPE Doctype> before <poml>, e.g. `poml SYSTEM "poml.dtd"`.
+	// It replaces a doctype kept by PreservePrefix.
+	Doctype string
+	// PreservePrefix writes the markup that preceded <poml> in the parsed source (license header
+	// comments, processing instructions, a doctype) before the root. A declaration in it is kept,
+	// relabeled UTF-8 because the output always is, unless IncludeHeader or Declaration writes one.
+	PreservePrefix bool
+
+	depth int // nesting level of the element being encoded: 1 inside <poml>, 0 for EncodeElement
+}
+
+// XMLDeclaration holds the attributes of the <?xml ...?> declaration Encode writes.
+type XMLDeclaration struct {
+	Version    string // "1.0" (the default) or "1.1"
+	Encoding   string // a UTF-8 label such as "UTF-8" (the default) or "utf-8"; output is always UTF-8
+	Standalone string // "yes", "no", or "" to leave the attribute out
+}
+
+// declaration returns the XML declaration and newline opts asks for, or "" for none.
+func (o EncodeOptions) declaration() (string, error) {
+	d := o.Declaration
+	if d == (XMLDeclaration{}) {
+		if o.IncludeHeader {
+			return xml.Header, nil
+		}
+		return "", nil
+	}
+	version, encoding := cmp.Or(d.Version, "1.0"), cmp.Or(d.Encoding, "UTF-8")
+	if version != "1.0" && version != "1.1" {
+		return "", fmt.Errorf("encode declaration: unsupported version %q", d.Version)
+	}
+	if charsetKind(encoding) != "utf-8" {
+		return "", fmt.Errorf("encode declaration: encoding %q does not match the UTF-8 output", d.Encoding)
+	}
+	decl := `<?xml version="` + version + `" encoding="` + encoding + `"`
+	switch d.Standalone {
+	case "":
+	case "yes", "no":
+		decl += ` standalone="` + d.Standalone + `"`
+	default:
+		return "", fmt.Errorf("encode declaration: standalone %q is not yes or no", d.Standalone)
+	}
+	return decl + "?>\n", nil
 }
 
 // ParseOptions controls parsing fidelity.
@@ -253,6 +327,40 @@ type ParseOptions struct {
 	// Validate runs structural validation (meta/role/task, diagrams, etc.) after parsing.
 	// When false, parsing succeeds even if required fields are missing.
 	Validate bool
+	// ResolveDocuments fetches <document src> contents after parsing and stores them in DocRef.Content.
+	ResolveDocuments bool
+	// DocumentResolver overrides how documents are fetched; nil uses FileDocumentResolver relative to the working directory.
+	DocumentResolver DocumentResolver
+	// Recover keeps parsing after malformed children, recording each problem in Document.Issues.
+	// Elements that fail to decode are kept as unknown raw XML; a syntax error that stops the
+	// tokenizer ends parsing early and returns the content decoded so far.
+	Recover bool
+	// ParseInlineContent parses message, hint, example, and cp bodies into Content trees of
+	// text, <img>, <object>, and <cp> nodes so converters can emit multi-part content.
+	ParseInlineContent bool
+	// StableIDs derives element IDs from content (id attributes, natural keys, or per-type
+	// position) instead of el-N counters; see Document.AssignStableIDs.
+	StableIDs bool
+	// MaxDocumentBytes, MaxElementCount, and MaxNestingDepth bound untrusted input: the size of
+	// the source, the number of elements (every start tag, including <poml>), and how deeply
+	// elements nest (<poml> is depth 1). Zero means unlimited. Exceeding one fails the parse, even
+	// with Recover, with a POMLError wrapping a *LimitError.
+	MaxDocumentBytes int64
+	MaxElementCount  int
+	MaxNestingDepth  int
+	// DisallowUnknown rejects top-level tags POML does not define (such as a mistyped <tsak>)
+	// instead of keeping them as ElementUnknown. Parsing fails with a POMLError carrying
+	// CodeUnknownElement and wrapping an *UnknownElementError that lists every such tag with its
+	// line; with Recover, each one is recorded in Document.Issues instead.
+	DisallowUnknown bool
+}
+
+// ParseIssue describes a problem skipped while parsing with ParseOptions.Recover.
+type ParseIssue struct {
+	Line    int    // 1-based line of the offending element or error position
+	Column  int    // 1-based column, 0 when unknown
+	Element string // local name of the offending element; empty for document-level problems
+	Message string
 }
 
 var defaultParseOptions = ParseOptions{PreserveWhitespace: true}
@@ -265,23 +373,37 @@ const (
 	ErrInvalidSchema ErrorType = "invalid_schema"
 	ErrDecode        ErrorType = "decode_error"
 	ErrValidate      ErrorType = "validation_error"
+	ErrTemplate      ErrorType = "template_error"
 )
 
 // POMLError wraps decoding/validation issues with context and type.
 type POMLError struct {
 	Type    ErrorType
+	Code    ErrorCode // stable code for the failure; empty when none applies
 	Message string
 	Err     error
+	// Location pinpoints the source of a CodeDecode failure returned by Parse; nil otherwise.
+	Location *DecodeLocation
 }
 
 // ValidationDetail provides structured validation info.
 type ValidationDetail struct {
-	Field   string
-	Element ElementType
-	Message string
+	Code      ErrorCode
+	Field     string
+	Element   ElementType
+	ElementID string // ID of the offending element, when the check pins it to one
+	Message   string
+	Severity  Severity // Code.Severity() for issues Validate reports; zero counts as an error
 }
 
-// ValidationError groups structural problems.
+func (d ValidationDetail) severity() Severity {
+	if d.Severity == 0 {
+		return SeverityError
+	}
+	return d.Severity
+}
+
+// ValidationError groups structural problems. Details[i] describes Issues[i].
 type ValidationError struct {
 	Issues  []string
 	Details []ValidationDetail
@@ -330,6 +452,27 @@ func ParseFileFast(path string) (Document, error) {
 	return parseWithOptions(f, fastParseOptions)
 }
 
+// ParseFS decodes the POML file name from fsys (e.g. an embed.FS). When opts.ResolveDocuments is
+// set without a DocumentResolver, <document src> paths resolve inside fsys relative to name's
+// directory, so embedded prompt bundles never touch the host filesystem.
+func ParseFS(fsys fs.FS, name string, opts ParseOptions) (Document, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return Document{}, err
+	}
+	defer f.Close()
+	if opts.ResolveDocuments && opts.DocumentResolver == nil {
+		dir := fsys
+		if d := path.Dir(name); d != "." {
+			if dir, err = fs.Sub(fsys, d); err != nil {
+				return Document{}, err
+			}
+		}
+		opts.DocumentResolver = FileDocumentResolver{FS: dir}
+	}
+	return parseWithOptions(f, opts)
+}
+
 // ParseReader decodes a POML document from an io.Reader.
 func ParseReader(r io.Reader) (Document, error) {
 	return parseWithOptions(r, defaultParseOptions)
@@ -377,21 +520,28 @@ func (d Document) Encode(w io.Writer) error {
 
 // EncodeWithOptions writes a POML document with configurable formatting.
 func (d Document) EncodeWithOptions(w io.Writer, opts EncodeOptions) error {
-	enc := xml.NewEncoder(w)
-	if opts.Compact {
-		enc.Indent("", "")
-	} else if opts.Indent != "" {
-		enc.Indent("", opts.Indent)
-	}
-	if opts.IncludeHeader {
-		if _, err := w.Write([]byte(xml.Header)); err != nil {
-			return err
-		}
-	}
-	if err := encodeDocument(enc, w, d, opts); err != nil {
-		return err
+	return encodePooled(w, opts, func(enc *xml.Encoder, out io.Writer) error {
+		return encodeDocument(enc, out, d, opts)
+	})
+}
+
+// EncodeElement writes el on its own, without <poml> or the other elements, so tools can show or
+// copy a single task, message, or diagram. Indent, Compact, and IncludeHeader apply as in
+// EncodeWithOptions; the element's comment is kept but not the whitespace around it.
+func (d Document) EncodeElement(w io.Writer, el Element, opts EncodeOptions) error {
+	el.Leading, el.Trailing = "", ""
+	return encodePooled(w, opts, func(enc *xml.Encoder, out io.Writer) error {
+		return encodeElement(enc, out, d, el, opts)
+	})
+}
+
+// ElementToString returns el encoded on its own with two-space indentation.
+func (d Document) ElementToString(el Element) (string, error) {
+	var b strings.Builder
+	if err := d.EncodeElement(&b, el, EncodeOptions{Indent: "  "}); err != nil {
+		return "", err
 	}
-	return enc.Flush()
+	return b.String(), nil
 }
 
 // WalkInputs applies fn to each input block.
@@ -480,16 +630,23 @@ func (d *Document) AddStyle(outputs ...Output) int {
 func (d *Document) AddMessage(role string, body string, attrs ...xml.Attr) int {
 	msg := Message{Role: role, Body: body, Attrs: attrs}
 	d.Messages = append(d.Messages, msg)
-	elType := ElementHumanMsg
+	idx := len(d.Messages) - 1
+	d.Elements = append(d.Elements, d.newElement(messageElementType(role), idx, ""))
+	return idx
+}
+
+// messageElementType returns the element type a message with role is written as: the dedicated
+// tag for the human, assistant, and system roles, and <msg role="..."> for any other.
+func messageElementType(role string) ElementType {
 	switch role {
+	case "", "human", "user":
+		return ElementHumanMsg
 	case "assistant":
-		elType = ElementAssistantMsg
+		return ElementAssistantMsg
 	case "system":
-		elType = ElementSystemMsg
+		return ElementSystemMsg
 	}
-	idx := len(d.Messages) - 1
-	d.Elements = append(d.Elements, d.newElement(elType, idx, ""))
-	return idx
+	return ElementMsg
 }
 
 // AddToolDefinition appends a tool-definition.
@@ -560,6 +717,14 @@ func (d *Document) AddRuntime(attrs ...xml.Attr) int {
 	return idx
 }
 
+// AddTrace appends a trace entry with attributes.
+func (d *Document) AddTrace(attrs ...xml.Attr) int {
+	d.Traces = append(d.Traces, Trace{Attrs: attrs})
+	idx := len(d.Traces) - 1
+	d.Elements = append(d.Elements, d.newElement(ElementTrace, idx, ""))
+	return idx
+}
+
 // AddImage appends an image node.
 func (d *Document) AddImage(img Image) int {
 	d.Images = append(d.Images, img)
@@ -568,8 +733,16 @@ func (d *Document) AddImage(img Image) int {
 	return idx
 }
 
-// Validate ensures required metadata exists and inputs are well-formed.
+// Validate ensures required metadata exists and inputs are well-formed, then runs the
+// validators added with RegisterValidator. Only error-severity issues fail it; warnings, such as
+// an image without alt text, are collected by ValidateWithOptions.
 func (d Document) Validate() error {
+	_, err := d.ValidateWithOptions(ValidateOptions{})
+	return err
+}
+
+// validate runs the built-in checks, then the registered validators, then opts.Validators.
+func (d Document) validate(opts ValidateOptions) error {
 	var issues []string
 	var details []ValidationDetail
 	metaCount, roleCount, taskCount := 0, 0, len(d.Tasks)
@@ -595,64 +768,62 @@ func (d Document) Validate() error {
 
 	if metaCount == 0 {
 		issues = append(issues, "meta section is required")
-		details = append(details, ValidationDetail{Element: ElementMeta, Message: "missing meta"})
+		details = append(details, ValidationDetail{Code: CodeMetaRequired, Element: ElementMeta, Message: "missing meta"})
 	}
 	if roleCount == 0 {
 		issues = append(issues, "role section is required")
-		details = append(details, ValidationDetail{Element: ElementRole, Message: "missing role"})
+		details = append(details, ValidationDetail{Code: CodeRoleRequired, Element: ElementRole, Message: "missing role"})
 	}
 	if taskCount == 0 {
 		issues = append(issues, "at least one task is required")
-		details = append(details, ValidationDetail{Element: ElementTask, Message: "missing task"})
+		details = append(details, ValidationDetail{Code: CodeTaskRequired, Element: ElementTask, Message: "missing task"})
 	}
 	if metaCount > 1 {
 		issues = append(issues, "only one meta section is allowed")
-		details = append(details, ValidationDetail{Element: ElementMeta, Message: "duplicate meta"})
+		details = append(details, ValidationDetail{Code: CodeMetaDuplicate, Element: ElementMeta, Message: "duplicate meta"})
 	}
 	if roleCount > 1 {
 		issues = append(issues, "only one role section is allowed")
-		details = append(details, ValidationDetail{Element: ElementRole, Message: "duplicate role"})
+		details = append(details, ValidationDetail{Code: CodeRoleDuplicate, Element: ElementRole, Message: "duplicate role"})
 	}
 	if strings.TrimSpace(d.Meta.ID) == "" {
 		issues = append(issues, "meta.id is required")
-		details = append(details, ValidationDetail{Element: ElementMeta, Field: "id", Message: "missing id"})
+		details = append(details, ValidationDetail{Code: CodeMetaID, Element: ElementMeta, Field: "id", Message: "missing id"})
 	}
 	if strings.TrimSpace(d.Meta.Version) == "" {
 		issues = append(issues, "meta.version is required")
-		details = append(details, ValidationDetail{Element: ElementMeta, Field: "version", Message: "missing version"})
+		details = append(details, ValidationDetail{Code: CodeMetaVersion, Element: ElementMeta, Field: "version", Message: "missing version"})
 	}
 	if strings.TrimSpace(d.Meta.Owner) == "" {
 		issues = append(issues, "meta.owner is required")
-		details = append(details, ValidationDetail{Element: ElementMeta, Field: "owner", Message: "missing owner"})
+		details = append(details, ValidationDetail{Code: CodeMetaOwner, Element: ElementMeta, Field: "owner", Message: "missing owner"})
 	}
 	nameSeen := make(map[string]struct{})
 	inputIndex := 0
 	for _, in := range d.Inputs {
 		if strings.TrimSpace(in.Name) == "" {
 			issues = append(issues, "input.name is required")
-			details = append(details, ValidationDetail{Element: ElementInput, Field: "name", Message: "missing name"})
+			details = append(details, ValidationDetail{Code: CodeInputName, Element: ElementInput, Field: "name", Message: fmt.Sprintf("input %d missing name", inputIndex)})
 		}
 		if _, ok := nameSeen[in.Name]; ok && in.Name != "" {
 			issues = append(issues, fmt.Sprintf("duplicate input name %q", in.Name))
-			details = append(details, ValidationDetail{Element: ElementInput, Field: "name", Message: "duplicate name " + in.Name})
+			details = append(details, ValidationDetail{Code: CodeInputDuplicate, Element: ElementInput, Field: "name", Message: "duplicate name " + in.Name})
 		}
 		nameSeen[in.Name] = struct{}{}
-		if strings.TrimSpace(in.Name) == "" {
-			details = append(details, ValidationDetail{Element: ElementInput, Field: "name", Message: fmt.Sprintf("input %d missing name", inputIndex)})
-		}
 		inputIndex++
 	}
+	d.validateInputRefs(&issues, &details)
 	for _, doc := range d.Documents {
 		if strings.TrimSpace(doc.Src) == "" {
 			issues = append(issues, "document src is required")
-			details = append(details, ValidationDetail{Element: ElementDocument, Field: "src", Message: "missing src"})
+			details = append(details, ValidationDetail{Code: CodeDocumentSrc, Element: ElementDocument, Field: "src", Message: "missing src"})
 		}
 	}
 	for _, st := range d.Styles {
 		for _, out := range st.Outputs {
 			if strings.TrimSpace(out.Format) == "" {
 				issues = append(issues, "style output format is required")
-				details = append(details, ValidationDetail{Element: ElementStyle, Field: "format", Message: "missing format"})
+				details = append(details, ValidationDetail{Code: CodeStyleFormat, Element: ElementStyle, Field: "format", Message: "missing format"})
 			}
 		}
 	}
@@ -661,15 +832,21 @@ func (d Document) Validate() error {
 		name := strings.TrimSpace(td.Name)
 		if name == "" {
 			issues = append(issues, "tool-definition name is required")
-			details = append(details, ValidationDetail{Element: ElementToolDefinition, Field: "name", Message: "missing name"})
+			details = append(details, ValidationDetail{Code: CodeToolDefName, Element: ElementToolDefinition, Field: "name", Message: "missing name"})
 		}
 		if name != "" {
 			if _, ok := toolNames[name]; ok {
 				issues = append(issues, fmt.Sprintf("duplicate tool-definition name %q", name))
-				details = append(details, ValidationDetail{Element: ElementToolDefinition, Field: "name", Message: "duplicate name " + name})
+				details = append(details, ValidationDetail{Code: CodeToolDefDuplicate, Element: ElementToolDefinition, Field: "name", Message: "duplicate name " + name})
 			}
 			toolNames[name] = struct{}{}
 		}
+		if body := stripCDATA(strings.TrimSpace(td.Body)); body != "" && !opts.LegacyToolDefinitions && isJSONToolParser(td.Attrs.Get("parser")) {
+			if _, ok := parseJSONIfStruct(body); !ok {
+				issues = append(issues, fmt.Sprintf("tool-definition %q body is not a JSON parameters schema", name))
+				details = append(details, ValidationDetail{Code: CodeToolDefBody, Element: ElementToolDefinition, Field: "body", Message: "body is not a JSON parameters schema; put prose in the description attribute"})
+			}
+		}
 	}
 	toolReqs := make(map[string]string)
 	for i, tr := range d.ToolReqs {
@@ -677,22 +854,22 @@ func (d Document) Validate() error {
 		name := strings.TrimSpace(tr.Name)
 		if id == "" {
 			issues = append(issues, "tool-request id is required")
-			details = append(details, ValidationDetail{Element: ElementToolRequest, Field: "id", Message: "missing id"})
+			details = append(details, ValidationDetail{Code: CodeToolID, Element: ElementToolRequest, Field: "id", Message: "missing id"})
 		}
 		if name == "" {
 			issues = append(issues, "tool-request name is required")
-			details = append(details, ValidationDetail{Element: ElementToolRequest, Field: "name", Message: "missing name"})
+			details = append(details, ValidationDetail{Code: CodeToolName, Element: ElementToolRequest, Field: "name", Message: "missing name"})
 		}
 		if name != "" {
 			if _, ok := toolNames[name]; !ok {
 				issues = append(issues, fmt.Sprintf("tool-request %q references unknown tool-definition %q", labelOrIndex(id, i), name))
-				details = append(details, ValidationDetail{Element: ElementToolRequest, Field: "name", Message: "unknown tool-definition " + name})
+				details = append(details, ValidationDetail{Code: CodeToolDefRef, Element: ElementToolRequest, Field: "name", Message: "unknown tool-definition " + name})
 			}
 		}
 		if id != "" {
 			if existing, ok := toolReqs[id]; ok {
 				issues = append(issues, fmt.Sprintf("duplicate tool-request id %q", id))
-				details = append(details, ValidationDetail{Element: ElementToolRequest, Field: "id", Message: "duplicate id " + id + " (also used by " + existing + ")"})
+				details = append(details, ValidationDetail{Code: CodeToolReqDuplicate, Element: ElementToolRequest, Field: "id", Message: "duplicate id " + id + " (also used by " + existing + ")"})
 			} else {
 				toolReqs[id] = name
 			}
@@ -703,11 +880,11 @@ func (d Document) Validate() error {
 		name := strings.TrimSpace(tr.Name)
 		if id == "" {
 			issues = append(issues, "tool-response id is required")
-			details = append(details, ValidationDetail{Element: ElementToolResponse, Field: "id", Message: "missing id"})
+			details = append(details, ValidationDetail{Code: CodeToolID, Element: ElementToolResponse, Field: "id", Message: "missing id"})
 		}
 		if name == "" {
 			issues = append(issues, "tool-response name is required")
-			details = append(details, ValidationDetail{Element: ElementToolResponse, Field: "name", Message: "missing name"})
+			details = append(details, ValidationDetail{Code: CodeToolName, Element: ElementToolResponse, Field: "name", Message: "missing name"})
 		}
 		validateToolReference("tool-response", i, id, name, toolNames, toolReqs, ElementToolResponse, &issues, &details)
 	}
@@ -716,11 +893,11 @@ func (d Document) Validate() error {
 		name := strings.TrimSpace(tr.Name)
 		if id == "" {
 			issues = append(issues, "tool-result id is required")
-			details = append(details, ValidationDetail{Element: ElementToolResult, Field: "id", Message: "missing id"})
+			details = append(details, ValidationDetail{Code: CodeToolID, Element: ElementToolResult, Field: "id", Message: "missing id"})
 		}
 		if name == "" {
 			issues = append(issues, "tool-result name is required")
-			details = append(details, ValidationDetail{Element: ElementToolResult, Field: "name", Message: "missing name"})
+			details = append(details, ValidationDetail{Code: CodeToolName, Element: ElementToolResult, Field: "name", Message: "missing name"})
 		}
 		validateToolReference("tool-result", i, id, name, toolNames, toolReqs, ElementToolResult, &issues, &details)
 	}
@@ -729,22 +906,26 @@ func (d Document) Validate() error {
 		name := strings.TrimSpace(tr.Name)
 		if id == "" {
 			issues = append(issues, "tool-error id is required")
-			details = append(details, ValidationDetail{Element: ElementToolError, Field: "id", Message: "missing id"})
+			details = append(details, ValidationDetail{Code: CodeToolID, Element: ElementToolError, Field: "id", Message: "missing id"})
 		}
 		if name == "" {
 			issues = append(issues, "tool-error name is required")
-			details = append(details, ValidationDetail{Element: ElementToolError, Field: "name", Message: "missing name"})
+			details = append(details, ValidationDetail{Code: CodeToolName, Element: ElementToolError, Field: "name", Message: "missing name"})
 		}
 		validateToolReference("tool-error", i, id, name, toolNames, toolReqs, ElementToolError, &issues, &details)
 	}
 	if d.hasSchema() && strings.TrimSpace(d.Schema.Body) == "" && len(d.Schema.Attrs) == 0 {
 		issues = append(issues, "output-schema requires body or attributes")
-		details = append(details, ValidationDetail{Element: ElementOutputSchema, Message: "missing schema content"})
+		details = append(details, ValidationDetail{Code: CodeSchemaEmpty, Element: ElementOutputSchema, Message: "missing schema content"})
 	}
 	for _, img := range d.Images {
 		if strings.TrimSpace(img.Src) == "" && strings.TrimSpace(img.Body) == "" {
 			issues = append(issues, "img requires src or inline body")
-			details = append(details, ValidationDetail{Element: ElementImage, Field: "src", Message: "missing src/body"})
+			details = append(details, ValidationDetail{Code: CodeImageSrc, Element: ElementImage, Field: "src", Message: "missing src/body"})
+		}
+		if strings.TrimSpace(img.Alt) == "" {
+			issues = append(issues, fmt.Sprintf("img %q has no alt text", img.Src))
+			details = append(details, ValidationDetail{Code: CodeImageAlt, Element: ElementImage, Field: "alt", Message: "missing alt"})
 		}
 	}
 	for i, dg := range d.Diagrams {
@@ -765,47 +946,103 @@ func (d Document) Validate() error {
 				}
 			} else {
 				issues = append(issues, fmt.Sprintf("diagram[%d]: %v", i, err))
-				details = append(details, ValidationDetail{Element: ElementDiagram, Message: err.Error()})
+				details = append(details, ValidationDetail{Code: CodeDiagram, Element: ElementDiagram, Message: err.Error()})
 			}
 		}
 	}
 	for i, h := range d.Hints {
 		if strings.TrimSpace(h.Body) == "" {
 			issues = append(issues, fmt.Sprintf("hint[%d] requires body content", i))
-			details = append(details, ValidationDetail{Element: ElementHint, Message: "missing body"})
+			details = append(details, ValidationDetail{Code: CodeHintBody, Element: ElementHint, Message: "missing body"})
 		}
 	}
 	for i, ex := range d.Examples {
 		if strings.TrimSpace(ex.Body) == "" {
 			issues = append(issues, fmt.Sprintf("example[%d] requires body content", i))
-			details = append(details, ValidationDetail{Element: ElementExample, Message: "missing body"})
+			details = append(details, ValidationDetail{Code: CodeExampleBody, Element: ElementExample, Message: "missing body"})
 		}
 	}
 	for i, cp := range d.ContentParts {
 		if strings.TrimSpace(cp.Body) == "" {
 			issues = append(issues, fmt.Sprintf("cp[%d] requires body content", i))
-			details = append(details, ValidationDetail{Element: ElementContentPart, Message: "missing body"})
+			details = append(details, ValidationDetail{Code: CodeContentPartBody, Element: ElementContentPart, Message: "missing body"})
+		}
+	}
+	for _, el := range d.Elements {
+		if el.Type == ElementMsg && el.Index >= 0 && el.Index < len(d.Messages) && strings.TrimSpace(d.Messages[el.Index].Role) == "" {
+			issues = append(issues, fmt.Sprintf("msg[%d] requires a role", el.Index))
+			details = append(details, ValidationDetail{Code: CodeMsgRole, Element: ElementMsg, ElementID: el.ID, Field: "role", Message: "missing role"})
 		}
 	}
 	for i, obj := range d.Objects {
 		if strings.TrimSpace(obj.Data) == "" && strings.TrimSpace(obj.Body) == "" {
 			issues = append(issues, fmt.Sprintf("object[%d] requires data or body", i))
-			details = append(details, ValidationDetail{Element: ElementObject, Message: "missing data/body"})
+			details = append(details, ValidationDetail{Code: CodeObjectData, Element: ElementObject, Message: "missing data/body"})
 		}
 	}
+	d.runValidators(registeredValidators(), &issues, &details)
+	d.runValidators(opts.Validators, &issues, &details)
 	if len(issues) == 0 {
 		return nil
 	}
 	return &POMLError{
 		Type:    ErrValidate,
+		Code:    CodeValidation,
 		Message: "validation failed",
 		Err: &ValidationError{
 			Issues:  issues,
-			Details: details,
+			Details: withSeverities(details),
 		},
 	}
 }
 
+// ValidateOptions controls ValidateWithOptions.
+type ValidateOptions struct {
+	// FailOn is the lowest severity that fails validation. Zero means SeverityError, so warnings
+	// are returned separately, as with Validate; SeverityWarning fails on every issue.
+	FailOn Severity
+	// Validators run after the built-in checks and those added with RegisterValidator, for this
+	// call only.
+	Validators []Validator
+	// LegacyToolDefinitions accepts prose <tool-definition> bodies, which
+	// ConvertOptions.LegacyToolDefinitions reads as the description, instead of warning with
+	// CodeToolDefBody.
+	LegacyToolDefinitions bool
+}
+
+// ValidateWithOptions runs Validate's checks and splits the issues by severity: err holds the
+// issues at or above opts.FailOn (nil when there are none), and warnings the ones below it, so
+// CI can fail on errors while still printing warnings. Both are nil for a clean document.
+func (d Document) ValidateWithOptions(opts ValidateOptions) (warnings *ValidationError, err error) {
+	failOn := opts.FailOn
+	if failOn == 0 {
+		failOn = SeverityError
+	}
+	err = d.validate(opts)
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		warnings = ve.filter(func(det ValidationDetail) bool { return det.severity() < failOn })
+	}
+	return warnings, narrowValidation(err, func(ve *ValidationError) *ValidationError { return ve.AtLeast(failOn) })
+}
+
+// withSeverities sets each detail's Severity from its code where it is unset.
+func withSeverities(details []ValidationDetail) []ValidationDetail {
+	for i := range details {
+		if details[i].Severity == 0 {
+			details[i].Severity = details[i].Code.Severity()
+		}
+	}
+	return details
+}
+
+// isJSONToolParser reports whether a <tool-definition> parser attribute means a JSON body. Other
+// parsers, such as upstream POML's parser="eval" for zod schemas, are not checked.
+func isJSONToolParser(parser string) bool {
+	parser = strings.ToLower(strings.TrimSpace(parser))
+	return parser == "" || parser == "json"
+}
+
 func labelOrIndex(id string, idx int) string {
 	if strings.TrimSpace(id) != "" {
 		return id
@@ -817,7 +1054,7 @@ func validateToolReference(kind string, idx int, id string, name string, toolNam
 	if name != "" {
 		if _, ok := toolNames[name]; !ok {
 			*issues = append(*issues, fmt.Sprintf("%s %q references unknown tool-definition %q", kind, labelOrIndex(id, idx), name))
-			*details = append(*details, ValidationDetail{Element: element, Field: "name", Message: "unknown tool-definition " + name})
+			*details = append(*details, ValidationDetail{Code: CodeToolDefRef, Element: element, Field: "name", Message: "unknown tool-definition " + name})
 		}
 	}
 	if id == "" {
@@ -826,12 +1063,12 @@ func validateToolReference(kind string, idx int, id string, name string, toolNam
 	reqName, ok := toolReqs[id]
 	if !ok {
 		*issues = append(*issues, fmt.Sprintf("%s id %q does not match a tool-request", kind, id))
-		*details = append(*details, ValidationDetail{Element: element, Field: "id", Message: "missing tool-request for id " + id})
+		*details = append(*details, ValidationDetail{Code: CodeToolReqRef, Element: element, Field: "id", Message: "missing tool-request for id " + id})
 		return
 	}
 	if name != "" && reqName != "" && name != reqName {
 		*issues = append(*issues, fmt.Sprintf("%s id %q uses tool %q but request used %q", kind, id, name, reqName))
-		*details = append(*details, ValidationDetail{Element: element, Field: "name", Message: "mismatched tool for id " + id})
+		*details = append(*details, ValidationDetail{Code: CodeToolReqMismatch, Element: element, Field: "name", Message: "mismatched tool for id " + id})
 	}
 }
 
@@ -881,6 +1118,18 @@ func (d *Document) Mutate(fn func(Element, ElementPayload, *Mutator) error) erro
 	return nil
 }
 
+// MutateTx runs Mutate against a deep copy of the document and keeps the result only when every
+// call to fn returns nil. On error d is left exactly as it was, with no partial edits or
+// reindexing, and the error is returned unchanged.
+func (d *Document) MutateTx(fn func(Element, ElementPayload, *Mutator) error) error {
+	tx := d.Clone()
+	if err := tx.Mutate(fn); err != nil {
+		return err
+	}
+	*d = tx
+	return nil
+}
+
 // ElementPayload resolves the concrete node for an Element.
 type ElementPayload struct {
 	Meta         *Meta
@@ -905,6 +1154,7 @@ type ElementPayload struct {
 	ToolError    *ToolError
 	Schema       *OutputSchema
 	Runtime      *Runtime
+	Trace        *Trace
 	Diagram      *Diagram
 	Raw          string
 }
@@ -920,7 +1170,19 @@ func (m *Mutator) MarkModified() {
 	m.modified = true
 }
 
-// ReplaceBody updates the textual body of role/task/input/style nodes.
+// SetComment replaces the leading comment of el (matched by ID); an empty text removes it.
+func (m *Mutator) SetComment(el Element, text string) {
+	for i := range m.doc.Elements {
+		if m.doc.Elements[i].ID == el.ID {
+			m.doc.Elements[i].Comment = text
+			m.modified = true
+			return
+		}
+	}
+}
+
+// ReplaceBody updates the textual body of role/task/input/style nodes. body is stored as
+// innerxml, so it must already be escaped; SetBodyText and SetBodyXML make the intent explicit.
 func (m *Mutator) ReplaceBody(el Element, body string) {
 	d := m.doc
 	switch el.Type {
@@ -943,9 +1205,10 @@ func (m *Mutator) ReplaceBody(el Element, body string) {
 		if el.Index >= 0 && el.Index < len(d.OutFormats) {
 			d.OutFormats[el.Index].Body = body
 		}
-	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg:
+	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
 		if el.Index >= 0 && el.Index < len(d.Messages) {
 			d.Messages[el.Index].Body = body
+			d.Messages[el.Index].Content = nil
 		}
 	case ElementToolResponse:
 		if el.Index >= 0 && el.Index < len(d.ToolResps) {
@@ -961,6 +1224,49 @@ func (m *Mutator) ReplaceBody(el Element, body string) {
 	m.modified = true
 }
 
+// SetBodyText replaces el's body (matched by ID) with plain text, escaping '<' and '&' so the
+// text round-trips literally through encode. Use SetBodyXML to set markup instead.
+func (m *Mutator) SetBodyText(el Element, text string) error {
+	var buf strings.Builder
+	_ = xml.EscapeText(&buf, []byte(text))
+	return m.setBody(el, buf.String())
+}
+
+// SetBodyXML replaces el's body (matched by ID) with markup, written as-is on encode. It returns
+// an error, leaving the body unchanged, when markup is not well-formed XML content.
+func (m *Mutator) SetBodyXML(el Element, markup string) error {
+	dec := xml.NewDecoder(strings.NewReader("<body>" + markup + "</body>"))
+	for {
+		_, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("set body of %s: %w", el.ID, err)
+		}
+	}
+	return m.setBody(el, markup)
+}
+
+func (m *Mutator) setBody(el Element, body string) error {
+	for _, cur := range m.doc.Elements {
+		if cur.ID != el.ID {
+			continue
+		}
+		ref := m.doc.bodyRef(cur)
+		if ref == nil {
+			return fmt.Errorf("set body of %s: %s has no body", el.ID, cur.Type)
+		}
+		*ref = body
+		if cur.Type == ElementHumanMsg || cur.Type == ElementAssistantMsg || cur.Type == ElementSystemMsg || cur.Type == ElementMsg {
+			m.doc.Messages[cur.Index].Content = nil
+		}
+		m.modified = true
+		return nil
+	}
+	return fmt.Errorf("set body of %s: element not found", el.ID)
+}
+
 // Remove deletes the given element and its backing slice entry (where applicable).
 func (m *Mutator) Remove(el Element) {
 	d := m.doc
@@ -989,7 +1295,7 @@ func (m *Mutator) Remove(el Element) {
 		d.Role = Block{}
 	case ElementMeta:
 		d.Meta = Meta{}
-	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg:
+	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
 		if el.Index >= 0 && el.Index < len(d.Messages) {
 			d.Messages = append(d.Messages[:el.Index], d.Messages[el.Index+1:]...)
 		}
@@ -1011,10 +1317,50 @@ func (m *Mutator) Remove(el Element) {
 		if el.Index >= 0 && el.Index < len(d.Runtimes) {
 			d.Runtimes = append(d.Runtimes[:el.Index], d.Runtimes[el.Index+1:]...)
 		}
+	case ElementTrace:
+		if el.Index >= 0 && el.Index < len(d.Traces) {
+			d.Traces = append(d.Traces[:el.Index], d.Traces[el.Index+1:]...)
+		}
 	case ElementImage:
 		if el.Index >= 0 && el.Index < len(d.Images) {
 			d.Images = append(d.Images[:el.Index], d.Images[el.Index+1:]...)
 		}
+	case ElementHint:
+		if el.Index >= 0 && el.Index < len(d.Hints) {
+			d.Hints = append(d.Hints[:el.Index], d.Hints[el.Index+1:]...)
+		}
+	case ElementExample:
+		if el.Index >= 0 && el.Index < len(d.Examples) {
+			d.Examples = append(d.Examples[:el.Index], d.Examples[el.Index+1:]...)
+		}
+	case ElementContentPart:
+		if el.Index >= 0 && el.Index < len(d.ContentParts) {
+			d.ContentParts = append(d.ContentParts[:el.Index], d.ContentParts[el.Index+1:]...)
+		}
+	case ElementObject:
+		if el.Index >= 0 && el.Index < len(d.Objects) {
+			d.Objects = append(d.Objects[:el.Index], d.Objects[el.Index+1:]...)
+		}
+	case ElementToolResult:
+		if el.Index >= 0 && el.Index < len(d.ToolResults) {
+			d.ToolResults = append(d.ToolResults[:el.Index], d.ToolResults[el.Index+1:]...)
+		}
+	case ElementToolError:
+		if el.Index >= 0 && el.Index < len(d.ToolErrors) {
+			d.ToolErrors = append(d.ToolErrors[:el.Index], d.ToolErrors[el.Index+1:]...)
+		}
+	case ElementAudio:
+		if el.Index >= 0 && el.Index < len(d.Audios) {
+			d.Audios = append(d.Audios[:el.Index], d.Audios[el.Index+1:]...)
+		}
+	case ElementVideo:
+		if el.Index >= 0 && el.Index < len(d.Videos) {
+			d.Videos = append(d.Videos[:el.Index], d.Videos[el.Index+1:]...)
+		}
+	case ElementDiagram:
+		if el.Index >= 0 && el.Index < len(d.Diagrams) {
+			d.Diagrams = append(d.Diagrams[:el.Index], d.Diagrams[el.Index+1:]...)
+		}
 	}
 	for i, e := range d.Elements {
 		if e.ID == el.ID {
@@ -1027,38 +1373,234 @@ func (m *Mutator) Remove(el Element) {
 
 // InsertTaskAfter inserts a task after the given element and returns the new element ID.
 func (m *Mutator) InsertTaskAfter(after Element, body string) Element {
-	d := m.doc
-	d.Tasks = append(d.Tasks, Block{Body: body})
-	newEl := d.newElement(ElementTask, len(d.Tasks)-1, "")
-	d.insertElement(after, newEl)
-	return newEl
+	el, _ := m.InsertAfter(after, ElementPayload{Task: &Block{Body: body}})
+	return el
 }
 
 // InsertInputAfter inserts an input after the given element.
 func (m *Mutator) InsertInputAfter(after Element, in Input) Element {
-	d := m.doc
-	d.Inputs = append(d.Inputs, in)
-	newEl := d.newElement(ElementInput, len(d.Inputs)-1, "")
-	d.insertElement(after, newEl)
-	return newEl
+	el, _ := m.InsertAfter(after, ElementPayload{Input: &in})
+	return el
 }
 
 // InsertDocumentAfter inserts a document reference after the given element.
 func (m *Mutator) InsertDocumentAfter(after Element, src string) Element {
-	d := m.doc
-	d.Documents = append(d.Documents, DocRef{Src: src})
-	newEl := d.newElement(ElementDocument, len(d.Documents)-1, "")
-	d.insertElement(after, newEl)
-	return newEl
+	el, _ := m.InsertAfter(after, ElementPayload{DocRef: &DocRef{Src: src}})
+	return el
 }
 
 // InsertStyleAfter inserts a style after the given element.
 func (m *Mutator) InsertStyleAfter(after Element, st Style) Element {
+	el, _ := m.InsertAfter(after, ElementPayload{Style: &st})
+	return el
+}
+
+// InsertMessageAfter inserts a human/assistant/system message after the given element.
+func (m *Mutator) InsertMessageAfter(after Element, role, body string, attrs ...xml.Attr) Element {
+	el, _ := m.InsertAfter(after, ElementPayload{Message: &Message{Role: role, Body: body, Attrs: attrs}})
+	return el
+}
+
+// InsertToolDefinitionAfter inserts a tool definition after the given element.
+func (m *Mutator) InsertToolDefinitionAfter(after Element, td ToolDefinition) Element {
+	el, _ := m.InsertAfter(after, ElementPayload{ToolDef: &td})
+	return el
+}
+
+// InsertHintAfter inserts a hint after the given element.
+func (m *Mutator) InsertHintAfter(after Element, h Hint) Element {
+	el, _ := m.InsertAfter(after, ElementPayload{Hint: &h})
+	return el
+}
+
+// InsertImageAfter inserts an image after the given element.
+func (m *Mutator) InsertImageAfter(after Element, img Image) Element {
+	el, _ := m.InsertAfter(after, ElementPayload{Image: &img})
+	return el
+}
+
+// InsertDiagramAfter inserts a diagram after the given element.
+func (m *Mutator) InsertDiagramAfter(after Element, dg Diagram) Element {
+	el, _ := m.InsertAfter(after, ElementPayload{Diagram: &dg})
+	return el
+}
+
+// InsertAfter inserts the element described by payload after the given element (at the end when
+// after is not found). Exactly one payload field should be set; Message.Role selects the message
+// element type and Raw inserts an unknown element. The backing slice entry is placed in document
+// order so indices stay consistent. Singletons (meta/role/output-schema) overwrite the current value
+// and keep their existing position when the document already records one.
+func (m *Mutator) InsertAfter(after Element, payload ElementPayload) (Element, error) {
 	d := m.doc
-	d.Styles = append(d.Styles, st)
-	newEl := d.newElement(ElementStyle, len(d.Styles)-1, "")
+	if len(d.Elements) == 0 {
+		d.Elements = d.defaultElements()
+	}
+	pos := len(d.Elements)
+	for i, e := range d.Elements {
+		if e.ID == after.ID {
+			pos = i + 1
+			break
+		}
+	}
+	t, name, ok := payloadElementType(payload)
+	if !ok {
+		return Element{}, fmt.Errorf("insert: payload has no element set")
+	}
+	switch t {
+	case ElementMeta, ElementRole, ElementOutputSchema:
+		switch t {
+		case ElementMeta:
+			d.Meta = *payload.Meta
+		case ElementRole:
+			d.Role = *payload.Role
+		default:
+			d.Schema = *payload.Schema
+		}
+		m.modified = true
+		for _, e := range d.Elements {
+			if e.Type == t {
+				return e, nil
+			}
+		}
+		newEl := d.newElement(t, -1, "")
+		d.insertElement(after, newEl)
+		return newEl, nil
+	case ElementUnknown:
+		newEl := d.newElement(ElementUnknown, -1, name, payload.Raw)
+		d.insertElement(after, newEl)
+		m.modified = true
+		return newEl, nil
+	}
+	idx := 0
+	for _, e := range d.Elements[:pos] {
+		if indexGroup(e.Type) == indexGroup(t) {
+			idx++
+		}
+	}
+	d.insertPayloadAt(idx, payload)
+	newEl := d.newElement(t, idx, "")
 	d.insertElement(after, newEl)
-	return newEl
+	m.modified = true
+	return newEl, nil
+}
+
+// payloadElementType reports the element type for the first populated payload field.
+func payloadElementType(p ElementPayload) (ElementType, string, bool) {
+	switch {
+	case p.Meta != nil:
+		return ElementMeta, "", true
+	case p.Role != nil:
+		return ElementRole, "", true
+	case p.Schema != nil:
+		return ElementOutputSchema, "", true
+	case p.Task != nil:
+		return ElementTask, "", true
+	case p.Input != nil:
+		return ElementInput, "", true
+	case p.DocRef != nil:
+		return ElementDocument, "", true
+	case p.Style != nil:
+		return ElementStyle, "", true
+	case p.Audio != nil:
+		return ElementAudio, "", true
+	case p.Video != nil:
+		return ElementVideo, "", true
+	case p.OutputFormat != nil:
+		return ElementOutputFormat, "", true
+	case p.Hint != nil:
+		return ElementHint, "", true
+	case p.Example != nil:
+		return ElementExample, "", true
+	case p.ContentPart != nil:
+		return ElementContentPart, "", true
+	case p.Object != nil:
+		return ElementObject, "", true
+	case p.Image != nil:
+		return ElementImage, "", true
+	case p.Message != nil:
+		return messageElementType(p.Message.Role), "", true
+	case p.ToolDef != nil:
+		return ElementToolDefinition, "", true
+	case p.ToolReq != nil:
+		return ElementToolRequest, "", true
+	case p.ToolResp != nil:
+		return ElementToolResponse, "", true
+	case p.ToolResult != nil:
+		return ElementToolResult, "", true
+	case p.ToolError != nil:
+		return ElementToolError, "", true
+	case p.Runtime != nil:
+		return ElementRuntime, "", true
+	case p.Trace != nil:
+		return ElementTrace, "", true
+	case p.Diagram != nil:
+		return ElementDiagram, "", true
+	case p.Raw != "":
+		return ElementUnknown, rawElementName(p.Raw), true
+	}
+	return "", "", false
+}
+
+// insertPayloadAt inserts the populated slice-backed payload field at idx in its backing slice.
+func (d *Document) insertPayloadAt(idx int, p ElementPayload) {
+	switch {
+	case p.Task != nil:
+		d.Tasks = slices.Insert(d.Tasks, idx, *p.Task)
+	case p.Input != nil:
+		d.Inputs = slices.Insert(d.Inputs, idx, *p.Input)
+	case p.DocRef != nil:
+		d.Documents = slices.Insert(d.Documents, idx, *p.DocRef)
+	case p.Style != nil:
+		d.Styles = slices.Insert(d.Styles, idx, *p.Style)
+	case p.Audio != nil:
+		d.Audios = slices.Insert(d.Audios, idx, *p.Audio)
+	case p.Video != nil:
+		d.Videos = slices.Insert(d.Videos, idx, *p.Video)
+	case p.OutputFormat != nil:
+		d.OutFormats = slices.Insert(d.OutFormats, idx, *p.OutputFormat)
+	case p.Hint != nil:
+		d.Hints = slices.Insert(d.Hints, idx, *p.Hint)
+	case p.Example != nil:
+		d.Examples = slices.Insert(d.Examples, idx, *p.Example)
+	case p.ContentPart != nil:
+		d.ContentParts = slices.Insert(d.ContentParts, idx, *p.ContentPart)
+	case p.Object != nil:
+		d.Objects = slices.Insert(d.Objects, idx, *p.Object)
+	case p.Image != nil:
+		d.Images = slices.Insert(d.Images, idx, *p.Image)
+	case p.Message != nil:
+		d.Messages = slices.Insert(d.Messages, idx, *p.Message)
+	case p.ToolDef != nil:
+		d.ToolDefs = slices.Insert(d.ToolDefs, idx, *p.ToolDef)
+	case p.ToolReq != nil:
+		d.ToolReqs = slices.Insert(d.ToolReqs, idx, *p.ToolReq)
+	case p.ToolResp != nil:
+		d.ToolResps = slices.Insert(d.ToolResps, idx, *p.ToolResp)
+	case p.ToolResult != nil:
+		d.ToolResults = slices.Insert(d.ToolResults, idx, *p.ToolResult)
+	case p.ToolError != nil:
+		d.ToolErrors = slices.Insert(d.ToolErrors, idx, *p.ToolError)
+	case p.Runtime != nil:
+		d.Runtimes = slices.Insert(d.Runtimes, idx, *p.Runtime)
+	case p.Trace != nil:
+		d.Traces = slices.Insert(d.Traces, idx, *p.Trace)
+	case p.Diagram != nil:
+		d.Diagrams = slices.Insert(d.Diagrams, idx, *p.Diagram)
+	}
+}
+
+// rawElementName returns the local tag name of a raw XML fragment.
+func rawElementName(raw string) string {
+	dec := xml.NewDecoder(strings.NewReader(raw))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
 }
 
 // InsertBefore inserts newEl before the given element.
@@ -1098,31 +1640,79 @@ func (d *Document) insertElement(after Element, newEl Element) {
 }
 
 func parseWithOptions(r io.Reader, opts ParseOptions) (Document, error) {
-	dec := xml.NewDecoder(r)
-	dec.Strict = true
+	return parseWithSpans(r, opts, nil)
+}
+
+// parseWithSpans parses like parseWithOptions and, when spans is non-nil, records element offsets.
+func parseWithSpans(r io.Reader, opts ParseOptions, spans *parseSpans) (Document, error) {
+	if spans == nil {
+		// Spans are byte offsets into the caller's source, so only unrecorded parses transcode.
+		r = utf8Input(r)
+	}
+	var src []byte
+	if opts.Recover || opts.hasLimits() {
+		// Recovery slices failed elements out of the original bytes, and limits are checked
+		// against them before decoding, so buffer the input.
+		data, err := readLimited(r, opts.MaxDocumentBytes)
+		if err != nil {
+			return Document{}, err
+		}
+		src = data
+		r = bytes.NewReader(data)
+	}
+	dec := newPOMLDecoder(r)
+	dec.Strict = !opts.Recover
+	dec.spans = spans
 
+	var prefix strings.Builder // markup before <poml>; see EncodeOptions.PreservePrefix
 	for {
+		offset := dec.InputOffset()
 		tok, err := dec.Token()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				return Document{}, fmt.Errorf("parse poml: unexpected EOF (missing <poml> root?)")
 			}
-			return Document{}, wrapXMLError(err, "parse poml")
+			return Document{}, dec.locate(wrapXMLError(err, "parse poml"), "")
 		}
 		start, ok := tok.(xml.StartElement)
 		if !ok {
+			if text, isText := tok.(xml.CharData); isText {
+				prefix.Write(text)
+			} else {
+				prefix.WriteString(renderToken(tok))
+			}
 			continue
 		}
 		if start.Name.Local != "poml" {
 			return Document{}, &POMLError{
 				Type:    ErrDecode,
+				Code:    CodeDecode,
 				Message: fmt.Sprintf("parse poml: expected <poml> root, got <%s>", start.Name.Local),
 			}
 		}
-		doc, err := decodePoml(dec, opts)
+		if spans != nil {
+			spans.rootStart, spans.rootEnd = int(offset), int(dec.InputOffset())
+		}
+		doc, err := decodePoml(dec, opts, src)
 		if err != nil {
 			return Document{}, err
 		}
+		doc.Extends = Attrs(start.Attr).Get("extends")
+		doc.rawPrefix = prefix.String()
+		doc.markCDATABodies()
+		if opts.StableIDs {
+			doc.AssignStableIDs()
+		}
+		if opts.ParseInlineContent {
+			if err := doc.populateInlineContent(); err != nil {
+				return Document{}, err
+			}
+		}
+		if opts.ResolveDocuments {
+			if err := doc.ResolveDocuments(opts.DocumentResolver); err != nil {
+				return Document{}, err
+			}
+		}
 		if opts.Validate {
 			if err := doc.Validate(); err != nil {
 				return Document{}, err
@@ -1132,19 +1722,36 @@ func parseWithOptions(r io.Reader, opts ParseOptions) (Document, error) {
 	}
 }
 
-func decodePoml(dec *xml.Decoder, opts ParseOptions) (Document, error) {
+// decodePoml decodes the children of <poml>. src holds the buffered input when recovering and is nil otherwise.
+func decodePoml(dec *pomlDecoder, opts ParseOptions, src []byte) (Document, error) {
 	var doc Document
+	if opts.hasLimits() {
+		if err := checkParseLimits(src, opts); err != nil {
+			return doc, err
+		}
+	}
 	doc.nextID = 1
 	var lastElement *Element
+	var unknown []UnknownElement
 	pending := ""
 	preserveWS := opts.PreserveWhitespace
+	spanStart := int(dec.InputOffset())
 	for {
+		dec.mark()
+		offset := dec.InputOffset()
 		tok, err := dec.Token()
 		if err != nil {
+			if opts.Recover {
+				doc.recordIssue(src, dec.InputOffset(), "", recoverMessage(err, "parse poml"))
+				if preserveWS && lastElement != nil && pending != "" {
+					lastElement.Trailing = pending
+				}
+				return doc, nil
+			}
 			if errors.Is(err, io.EOF) {
 				return doc, fmt.Errorf("parse poml: unexpected EOF before </poml>")
 			}
-			return doc, wrapXMLError(err, "parse poml")
+			return doc, dec.locate(wrapXMLError(err, "parse poml"), doc.lastElementID())
 		}
 		switch t := tok.(type) {
 		case xml.CharData:
@@ -1158,283 +1765,44 @@ func decodePoml(dec *xml.Decoder, opts ParseOptions) (Document, error) {
 		case xml.StartElement:
 			leading := pending
 			pending = ""
-			switch t.Name.Local {
-			case "meta":
-				var m Meta
-				if err := dec.DecodeElement(&m, &t); err != nil {
-					return doc, wrapXMLError(err, "<meta>")
-				}
-				doc.Meta = m
-				el := doc.newElement(ElementMeta, -1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "role":
-				var b Block
-				if err := dec.DecodeElement(&b, &t); err != nil {
-					return doc, wrapXMLError(err, "<role>")
-				}
-				doc.Role = b
-				el := doc.newElement(ElementRole, -1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "task":
-				var b Block
-				if err := dec.DecodeElement(&b, &t); err != nil {
-					return doc, wrapXMLError(err, "<task>")
-				}
-				doc.Tasks = append(doc.Tasks, b)
-				el := doc.newElement(ElementTask, len(doc.Tasks)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "input":
-				var in Input
-				if err := dec.DecodeElement(&in, &t); err != nil {
-					return doc, wrapXMLError(err, "<input>")
-				}
-				doc.Inputs = append(doc.Inputs, in)
-				el := doc.newElement(ElementInput, len(doc.Inputs)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "document", "Document":
-				var dr DocRef
-				if err := dec.DecodeElement(&dr, &t); err != nil {
-					return doc, wrapXMLError(err, "<document>")
-				}
-				doc.Documents = append(doc.Documents, dr)
-				el := doc.newElement(ElementDocument, len(doc.Documents)-1, t.Name.Local)
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "style":
-				var st Style
-				if err := dec.DecodeElement(&st, &t); err != nil {
-					return doc, wrapXMLError(err, "<style>")
-				}
-				doc.Styles = append(doc.Styles, st)
-				el := doc.newElement(ElementStyle, len(doc.Styles)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "hint":
-				var h Hint
-				if err := dec.DecodeElement(&h, &t); err != nil {
-					return doc, wrapXMLError(err, "<hint>")
-				}
-				doc.Hints = append(doc.Hints, h)
-				el := doc.newElement(ElementHint, len(doc.Hints)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "example":
-				var ex Example
-				if err := dec.DecodeElement(&ex, &t); err != nil {
-					return doc, wrapXMLError(err, "<example>")
-				}
-				doc.Examples = append(doc.Examples, ex)
-				el := doc.newElement(ElementExample, len(doc.Examples)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "cp":
-				var cp ContentPart
-				if err := dec.DecodeElement(&cp, &t); err != nil {
-					return doc, wrapXMLError(err, "<cp>")
-				}
-				doc.ContentParts = append(doc.ContentParts, cp)
-				el := doc.newElement(ElementContentPart, len(doc.ContentParts)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "human-msg", "assistant-msg", "system-msg", "ai-msg":
-				var msg Message
-				if err := dec.DecodeElement(&msg, &t); err != nil {
-					return doc, wrapXMLError(err, "<msg>")
-				}
-				msg.Role = strings.TrimSuffix(t.Name.Local, "-msg")
-				if t.Name.Local == "ai-msg" {
-					msg.Role = "assistant"
-				}
-				doc.Messages = append(doc.Messages, msg)
-				elType := ElementHumanMsg
-				switch msg.Role {
-				case "assistant":
-					elType = ElementAssistantMsg
-				case "system":
-					elType = ElementSystemMsg
-				}
-				el := doc.newElement(elType, len(doc.Messages)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "tool-definition", "tool":
-				var td ToolDefinition
-				if err := dec.DecodeElement(&td, &t); err != nil {
-					return doc, wrapXMLError(err, "<tool-definition>")
-				}
-				doc.ToolDefs = append(doc.ToolDefs, td)
-				el := doc.newElement(ElementToolDefinition, len(doc.ToolDefs)-1, t.Name.Local)
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "tool-request":
-				var tr ToolRequest
-				if err := dec.DecodeElement(&tr, &t); err != nil {
-					return doc, wrapXMLError(err, "<tool-request>")
-				}
-				doc.ToolReqs = append(doc.ToolReqs, tr)
-				el := doc.newElement(ElementToolRequest, len(doc.ToolReqs)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "tool-response":
-				var tr ToolResponse
-				if err := dec.DecodeElement(&tr, &t); err != nil {
-					return doc, wrapXMLError(err, "<tool-response>")
-				}
-				doc.ToolResps = append(doc.ToolResps, tr)
-				el := doc.newElement(ElementToolResponse, len(doc.ToolResps)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "tool-result":
-				var tr ToolResult
-				if err := dec.DecodeElement(&tr, &t); err != nil {
-					return doc, wrapXMLError(err, "<tool-result>")
-				}
-				doc.ToolResults = append(doc.ToolResults, tr)
-				el := doc.newElement(ElementToolResult, len(doc.ToolResults)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "tool-error":
-				var te ToolError
-				if err := dec.DecodeElement(&te, &t); err != nil {
-					return doc, wrapXMLError(err, "<tool-error>")
-				}
-				doc.ToolErrors = append(doc.ToolErrors, te)
-				el := doc.newElement(ElementToolError, len(doc.ToolErrors)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "output-schema":
-				var os OutputSchema
-				if err := dec.DecodeElement(&os, &t); err != nil {
-					return doc, wrapXMLError(err, "<output-schema>")
-				}
-				doc.Schema = os
-				el := doc.newElement(ElementOutputSchema, -1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "output-format":
-				var of OutputFormat
-				if err := dec.DecodeElement(&of, &t); err != nil {
-					return doc, wrapXMLError(err, "<output-format>")
-				}
-				doc.OutFormats = append(doc.OutFormats, of)
-				el := doc.newElement(ElementOutputFormat, len(doc.OutFormats)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "runtime":
-				var rt Runtime
-				if err := dec.DecodeElement(&rt, &t); err != nil {
-					return doc, wrapXMLError(err, "<runtime>")
-				}
-				doc.Runtimes = append(doc.Runtimes, rt)
-				el := doc.newElement(ElementRuntime, len(doc.Runtimes)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "img":
-				var im Image
-				if err := dec.DecodeElement(&im, &t); err != nil {
-					return doc, wrapXMLError(err, "<img>")
-				}
-				doc.Images = append(doc.Images, im)
-				el := doc.newElement(ElementImage, len(doc.Images)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "audio":
-				var au Media
-				if err := dec.DecodeElement(&au, &t); err != nil {
-					return doc, wrapXMLError(err, "<audio>")
-				}
-				doc.Audios = append(doc.Audios, au)
-				el := doc.newElement(ElementAudio, len(doc.Audios)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "video":
-				var vd Media
-				if err := dec.DecodeElement(&vd, &t); err != nil {
-					return doc, wrapXMLError(err, "<video>")
-				}
-				doc.Videos = append(doc.Videos, vd)
-				el := doc.newElement(ElementVideo, len(doc.Videos)-1, "")
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "object", "Object":
-				var obj ObjectTag
-				if err := dec.DecodeElement(&obj, &t); err != nil {
-					return doc, wrapXMLError(err, "<object>")
-				}
-				doc.Objects = append(doc.Objects, obj)
-				el := doc.newElement(ElementObject, len(doc.Objects)-1, t.Name.Local)
-				if preserveWS {
-					el.Leading = leading
-				}
-				doc.Elements = append(doc.Elements, el)
-			case "diagram":
-				var dg Diagram
-				if err := dec.DecodeElement(&dg, &t); err != nil {
-					return doc, wrapXMLError(err, "<diagram>")
+			line, _ := dec.InputPos()
+			var el Element
+			if opts.Recover {
+				issues := len(doc.Issues)
+				var ok bool
+				el, ok = doc.recoverChild(dec, t, src, offset)
+				if !ok {
+					if preserveWS && lastElement != nil && leading != "" {
+						lastElement.Trailing = leading
+					}
+					return doc, nil
 				}
-				doc.Diagrams = append(doc.Diagrams, dg)
-				el := doc.newElement(ElementDiagram, len(doc.Diagrams)-1, "")
-				if preserveWS {
-					el.Leading = leading
+				if opts.DisallowUnknown && el.Type == ElementUnknown && len(doc.Issues) == issues {
+					name := t.Name.Local
+					doc.recordIssue(src, offset, name, "unknown element <"+name+">"+didYouMean(SuggestTag(name)))
 				}
-				doc.Elements = append(doc.Elements, el)
-			default:
-				// Preserve unknown elements as raw where possible.
-				raw, err := consumeRaw(dec, t)
+			} else {
+				el, err = doc.decodeChild(dec, t, offset)
 				if err != nil {
-					return doc, wrapXMLError(err, fmt.Sprintf("<%s>", t.Name.Local))
+					return doc, dec.locate(err, doc.lastElementID())
+				}
+				if opts.DisallowUnknown && el.Type == ElementUnknown {
+					unknown = append(unknown, UnknownElement{Name: t.Name.Local, Line: line, Suggestion: SuggestTag(t.Name.Local)})
 				}
-				el := doc.newElement(ElementUnknown, -1, t.Name.Local, raw)
-				if preserveWS {
-					el.Leading = leading
+			}
+			if preserveWS {
+				el.Leading = leading
+				el.Comment = leadingComment(leading)
+				if el.Type != ElementUnknown {
+					el.AttrOrder = attrOrder(t.Attr)
 				}
-				doc.Elements = append(doc.Elements, el)
 			}
+			if dec.spans != nil {
+				end := int(dec.InputOffset())
+				dec.spans.elements = append(dec.spans.elements, sourceSpan{start: spanStart, end: end})
+				spanStart = end
+			}
+			doc.Elements = append(doc.Elements, el)
 			if preserveWS && lastElement != nil && pending != "" {
 				lastElement.Trailing = pending
 			}
@@ -1442,50 +1810,285 @@ func decodePoml(dec *xml.Decoder, opts ParseOptions) (Document, error) {
 			pending = ""
 		case xml.EndElement:
 			if t.Name.Local == "poml" {
+				if dec.spans != nil {
+					dec.spans.closeStart = int(offset)
+				}
 				if preserveWS && lastElement != nil && pending != "" {
 					lastElement.Trailing = pending
 				}
+				if len(unknown) > 0 {
+					return doc, &POMLError{Type: ErrDecode, Code: CodeUnknownElement, Message: "parse poml", Err: &UnknownElementError{Elements: unknown}}
+				}
 				return doc, nil
 			}
 		}
 	}
 }
 
-// consumeRaw reads the current element (start already consumed) and returns the raw XML string.
-func consumeRaw(dec *xml.Decoder, start xml.StartElement) (string, error) {
-	var buf bytes.Buffer
-	enc := xml.NewEncoder(&buf)
-	if err := enc.EncodeToken(start); err != nil {
-		return "", err
-	}
-	depth := 1
-	for depth > 0 {
-		tok, err := dec.Token()
-		if err != nil {
-			return "", err
-		}
-		switch tok.(type) {
-		case xml.StartElement:
-			depth++
-		case xml.EndElement:
-			depth--
+// decodeChild decodes a single top-level child of <poml> into the backing slices on doc
+// and returns its Element. start is the input offset of the child's start tag.
+// Leading/Trailing are left for the caller to populate.
+func (doc *Document) decodeChild(dec *pomlDecoder, t xml.StartElement, start int64) (Element, error) {
+	el, err := doc.decodeChildElement(dec, t, start)
+	if err != nil {
+		return Element{}, err
+	}
+	el.Namespace = t.Name.Space
+	return el, nil
+}
+
+// elementTags lists the top-level tags decodeChildElement recognizes and the payload each decodes
+// into, first spelling canonical. SuggestTag and XSD read it; keep it in step with the switch below.
+var elementTags = []struct {
+	Names   []string
+	Payload any
+}{
+	{[]string{"meta"}, Meta{}},
+	{[]string{"role"}, Block{}},
+	{[]string{"task"}, Block{}},
+	{[]string{"input"}, Input{}},
+	{[]string{"document", "Document"}, DocRef{}},
+	{[]string{"style"}, Style{}},
+	{[]string{"hint"}, Hint{}},
+	{[]string{"example"}, Example{}},
+	{[]string{"cp"}, ContentPart{}},
+	{[]string{"human-msg"}, Message{}},
+	{[]string{"assistant-msg"}, Message{}},
+	{[]string{"system-msg"}, Message{}},
+	{[]string{"ai-msg"}, Message{}},
+	{[]string{"msg"}, Message{}},
+	{[]string{"tool-definition", "tool"}, ToolDefinition{}},
+	{[]string{"tool-request"}, ToolRequest{}},
+	{[]string{"tool-response"}, ToolResponse{}},
+	{[]string{"tool-result"}, ToolResult{}},
+	{[]string{"tool-error"}, ToolError{}},
+	{[]string{"output-schema"}, OutputSchema{}},
+	{[]string{"output-format"}, OutputFormat{}},
+	{[]string{"runtime"}, Runtime{}},
+	{[]string{"trace"}, Trace{}},
+	{[]string{"img"}, Image{}},
+	{[]string{"audio"}, Media{}},
+	{[]string{"video"}, Media{}},
+	{[]string{"object", "Object"}, ObjectTag{}},
+	{[]string{"diagram"}, Diagram{}},
+}
+
+func (doc *Document) decodeChildElement(dec *pomlDecoder, t xml.StartElement, start int64) (Element, error) {
+	switch t.Name.Local {
+	case "meta":
+		var m Meta
+		if err := dec.DecodeElement(&m, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<meta>")
+		}
+		doc.Meta = m
+		return doc.newElement(ElementMeta, -1, ""), nil
+	case "role":
+		var b Block
+		if err := dec.DecodeElement(&b, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<role>")
+		}
+		doc.Role = b
+		return doc.newElement(ElementRole, -1, ""), nil
+	case "task":
+		var b Block
+		if err := dec.DecodeElement(&b, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<task>")
+		}
+		doc.Tasks = append(doc.Tasks, b)
+		return doc.newElement(ElementTask, len(doc.Tasks)-1, ""), nil
+	case "input":
+		var in Input
+		if err := dec.DecodeElement(&in, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<input>")
+		}
+		doc.Inputs = append(doc.Inputs, in)
+		return doc.newElement(ElementInput, len(doc.Inputs)-1, ""), nil
+	case "document", "Document":
+		var dr DocRef
+		if err := dec.DecodeElement(&dr, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<document>")
+		}
+		doc.Documents = append(doc.Documents, dr)
+		return doc.newElement(ElementDocument, len(doc.Documents)-1, t.Name.Local), nil
+	case "style":
+		var st Style
+		if err := dec.DecodeElement(&st, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<style>")
+		}
+		doc.Styles = append(doc.Styles, st)
+		return doc.newElement(ElementStyle, len(doc.Styles)-1, ""), nil
+	case "hint":
+		var h Hint
+		if err := dec.DecodeElement(&h, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<hint>")
+		}
+		doc.Hints = append(doc.Hints, h)
+		return doc.newElement(ElementHint, len(doc.Hints)-1, ""), nil
+	case "example":
+		var ex Example
+		if err := dec.DecodeElement(&ex, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<example>")
+		}
+		ex.Pair = parseExamplePair(ex.Body)
+		doc.Examples = append(doc.Examples, ex)
+		return doc.newElement(ElementExample, len(doc.Examples)-1, ""), nil
+	case "cp":
+		var cp ContentPart
+		if err := dec.DecodeElement(&cp, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<cp>")
+		}
+		doc.ContentParts = append(doc.ContentParts, cp)
+		return doc.newElement(ElementContentPart, len(doc.ContentParts)-1, ""), nil
+	case "human-msg", "assistant-msg", "system-msg", "ai-msg":
+		var msg Message
+		if err := dec.DecodeElement(&msg, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<msg>")
+		}
+		msg.Role = strings.TrimSuffix(t.Name.Local, "-msg")
+		if t.Name.Local == "ai-msg" {
+			msg.Role = "assistant"
+		}
+		doc.Messages = append(doc.Messages, msg)
+		elType := ElementHumanMsg
+		switch msg.Role {
+		case "assistant":
+			elType = ElementAssistantMsg
+		case "system":
+			elType = ElementSystemMsg
 		}
-		if err := enc.EncodeToken(tok); err != nil {
-			return "", err
+		return doc.newElement(elType, len(doc.Messages)-1, ""), nil
+	case "msg":
+		var msg Message
+		if err := dec.DecodeElement(&msg, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<msg>")
+		}
+		// The role attribute lives in Message.Role and is written back from there.
+		msg.Role, _ = msg.Attrs.Lookup("role")
+		msg.Attrs.Delete("role")
+		doc.Messages = append(doc.Messages, msg)
+		return doc.newElement(ElementMsg, len(doc.Messages)-1, ""), nil
+	case "tool-definition", "tool":
+		var td ToolDefinition
+		if err := dec.DecodeElement(&td, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<tool-definition>")
+		}
+		doc.ToolDefs = append(doc.ToolDefs, td)
+		return doc.newElement(ElementToolDefinition, len(doc.ToolDefs)-1, t.Name.Local), nil
+	case "tool-request":
+		var tr ToolRequest
+		if err := dec.DecodeElement(&tr, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<tool-request>")
+		}
+		doc.ToolReqs = append(doc.ToolReqs, tr)
+		return doc.newElement(ElementToolRequest, len(doc.ToolReqs)-1, ""), nil
+	case "tool-response":
+		var tr ToolResponse
+		if err := dec.DecodeElement(&tr, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<tool-response>")
+		}
+		doc.ToolResps = append(doc.ToolResps, tr)
+		return doc.newElement(ElementToolResponse, len(doc.ToolResps)-1, ""), nil
+	case "tool-result":
+		var tr ToolResult
+		if err := dec.DecodeElement(&tr, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<tool-result>")
+		}
+		doc.ToolResults = append(doc.ToolResults, tr)
+		return doc.newElement(ElementToolResult, len(doc.ToolResults)-1, ""), nil
+	case "tool-error":
+		var te ToolError
+		if err := dec.DecodeElement(&te, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<tool-error>")
+		}
+		doc.ToolErrors = append(doc.ToolErrors, te)
+		return doc.newElement(ElementToolError, len(doc.ToolErrors)-1, ""), nil
+	case "output-schema":
+		var os OutputSchema
+		if err := dec.DecodeElement(&os, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<output-schema>")
+		}
+		doc.Schema = os
+		return doc.newElement(ElementOutputSchema, -1, ""), nil
+	case "output-format":
+		var of OutputFormat
+		if err := dec.DecodeElement(&of, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<output-format>")
+		}
+		doc.OutFormats = append(doc.OutFormats, of)
+		return doc.newElement(ElementOutputFormat, len(doc.OutFormats)-1, ""), nil
+	case "runtime":
+		var rt Runtime
+		if err := dec.DecodeElement(&rt, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<runtime>")
+		}
+		doc.Runtimes = append(doc.Runtimes, rt)
+		return doc.newElement(ElementRuntime, len(doc.Runtimes)-1, ""), nil
+	case "trace":
+		var tr Trace
+		if err := dec.DecodeElement(&tr, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<trace>")
+		}
+		doc.Traces = append(doc.Traces, tr)
+		return doc.newElement(ElementTrace, len(doc.Traces)-1, ""), nil
+	case "img":
+		var im Image
+		if err := dec.DecodeElement(&im, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<img>")
+		}
+		doc.Images = append(doc.Images, im)
+		return doc.newElement(ElementImage, len(doc.Images)-1, ""), nil
+	case "audio":
+		var au Media
+		if err := dec.DecodeElement(&au, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<audio>")
+		}
+		doc.Audios = append(doc.Audios, au)
+		return doc.newElement(ElementAudio, len(doc.Audios)-1, ""), nil
+	case "video":
+		var vd Media
+		if err := dec.DecodeElement(&vd, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<video>")
+		}
+		doc.Videos = append(doc.Videos, vd)
+		return doc.newElement(ElementVideo, len(doc.Videos)-1, ""), nil
+	case "object", "Object":
+		var obj ObjectTag
+		if err := dec.DecodeElement(&obj, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<object>")
+		}
+		doc.Objects = append(doc.Objects, obj)
+		return doc.newElement(ElementObject, len(doc.Objects)-1, t.Name.Local), nil
+	case "diagram":
+		var dg Diagram
+		if err := dec.DecodeElement(&dg, &t); err != nil {
+			return Element{}, wrapXMLError(err, "<diagram>")
+		}
+		doc.Diagrams = append(doc.Diagrams, dg)
+		return doc.newElement(ElementDiagram, len(doc.Diagrams)-1, ""), nil
+	default:
+		// Preserve unknown elements verbatim from the source bytes.
+		raw, err := dec.skipRaw(start)
+		if err != nil {
+			return Element{}, wrapXMLError(err, fmt.Sprintf("<%s>", t.Name.Local))
 		}
+		return doc.newElement(ElementUnknown, -1, t.Name.Local, raw), nil
 	}
-	if err := enc.Flush(); err != nil {
-		return "", err
-	}
-	return buf.String(), nil
+
 }
 
 // encodeDocument writes a poml root element with ordered children.
 func encodeDocument(enc *xml.Encoder, out io.Writer, doc Document, opts EncodeOptions) error {
+	if err := writePrologue(out, doc, opts); err != nil {
+		return err
+	}
 	start := xml.StartElement{Name: xml.Name{Local: "poml"}}
+	if doc.Extends != "" {
+		start.Attr = []xml.Attr{{Name: xml.Name{Local: "extends"}, Value: doc.Extends}}
+	}
 	if err := enc.EncodeToken(start); err != nil {
 		return err
 	}
+	opts.depth = 1
 	for _, el := range doc.resolveOrderWithFallback(opts.PreserveOrder) {
 		if err := encodeElement(enc, out, doc, el, opts); err != nil {
 			return err
@@ -1494,15 +2097,85 @@ func encodeDocument(enc *xml.Encoder, out io.Writer, doc Document, opts EncodeOp
 	return enc.EncodeToken(start.End())
 }
 
+// writePrologue writes what follows the declaration and precedes <poml>: the prefix kept by
+// opts.PreservePrefix, then opts.Doctype. Prefix items opts overrides are dropped along with the
+// whitespace after them.
+func writePrologue(out io.Writer, doc Document, opts EncodeOptions) error {
+	var b strings.Builder
+	if opts.PreservePrefix && doc.rawPrefix != "" {
+		dec := xml.NewDecoder(strings.NewReader(doc.rawPrefix))
+		dec.CharsetReader = charsetReader
+		dropped := false
+		for {
+			tok, err := dec.RawToken()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("encode prefix: %w", err)
+			}
+			switch t := tok.(type) {
+			case xml.CharData:
+				if !dropped || strings.TrimSpace(string(t)) != "" {
+					b.Write(t)
+				}
+				continue
+			case xml.ProcInst:
+				if t.Target == "xml" {
+					if opts.IncludeHeader || opts.Declaration != (XMLDeclaration{}) {
+						dropped = true
+						continue
+					}
+					t.Inst = prologEncodingRe.ReplaceAll(t.Inst, []byte(` encoding="UTF-8"`))
+					tok = t
+				}
+			case xml.Directive:
+				if opts.Doctype != "" && isDoctype(t) {
+					dropped = true
+					continue
+				}
+			}
+			dropped = false
+			b.WriteString(renderToken(tok))
+		}
+	}
+	if opts.Doctype != "" {
+		b.WriteString("<!DOCTYPE " + opts.Doctype + ">\n")
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// isDoctype reports whether directive d is a <!DOCTYPE ...> declaration.
+func isDoctype(d xml.Directive) bool {
+	name, _, _ := strings.Cut(string(d), " ")
+	return strings.EqualFold(strings.TrimSpace(name), "DOCTYPE")
+}
+
 func encodeElement(enc *xml.Encoder, out io.Writer, doc Document, el Element, opts EncodeOptions) error {
 	if opts.PreserveWS && el.Leading != "" {
 		if err := enc.Flush(); err != nil {
 			return err
 		}
-		if _, err := io.WriteString(out, el.Leading); err != nil {
+		if _, err := io.WriteString(out, withComment(el.Leading, el.Comment)); err != nil {
+			return err
+		}
+	} else if el.Comment != "" {
+		if err := enc.EncodeToken(xml.Comment(commentText(el.Comment))); err != nil {
 			return err
 		}
 	}
+	if el.CDATA || opts.ForceCDATA {
+		doc, el = doc.withCDATABody(el)
+	}
+	// With source attribute order to honor, the payload is encoded into a scratch buffer and
+	// re-emitted through the real encoder with its attributes reordered.
+	var ordered *bytes.Buffer
+	target := enc
+	if opts.PreserveWS && el.AttrOrder != "" && el.Type != ElementUnknown {
+		ordered = new(bytes.Buffer)
+		enc = newScratchEncoder(ordered, opts)
+	}
 	var err error
 	switch el.Type {
 	case ElementMeta:
@@ -1548,18 +2221,21 @@ func encodeElement(enc *xml.Encoder, out io.Writer, doc Document, el Element, op
 			return fmt.Errorf("encode cp: index %d out of range", el.Index)
 		}
 		err = enc.EncodeElement(doc.ContentParts[el.Index], xml.StartElement{Name: xml.Name{Local: "cp"}})
-	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg:
+	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
 		if el.Index < 0 || el.Index >= len(doc.Messages) {
 			return fmt.Errorf("encode message: index %d out of range", el.Index)
 		}
-		tag := "human-msg"
+		start := xml.StartElement{Name: xml.Name{Local: "human-msg"}}
 		switch el.Type {
 		case ElementAssistantMsg:
-			tag = "assistant-msg"
+			start.Name.Local = "assistant-msg"
 		case ElementSystemMsg:
-			tag = "system-msg"
+			start.Name.Local = "system-msg"
+		case ElementMsg:
+			start.Name.Local = "msg"
+			start.Attr = []xml.Attr{{Name: xml.Name{Local: "role"}, Value: doc.Messages[el.Index].Role}}
 		}
-		err = enc.EncodeElement(doc.Messages[el.Index], xml.StartElement{Name: xml.Name{Local: tag}})
+		err = enc.EncodeElement(doc.Messages[el.Index], start)
 	case ElementToolDefinition:
 		if el.Index < 0 || el.Index >= len(doc.ToolDefs) {
 			return fmt.Errorf("encode tool definition: index %d out of range", el.Index)
@@ -1611,6 +2287,11 @@ func encodeElement(enc *xml.Encoder, out io.Writer, doc Document, el Element, op
 			return fmt.Errorf("encode runtime: index %d out of range", el.Index)
 		}
 		err = enc.EncodeElement(doc.Runtimes[el.Index], xml.StartElement{Name: xml.Name{Local: "runtime"}})
+	case ElementTrace:
+		if el.Index < 0 || el.Index >= len(doc.Traces) {
+			return fmt.Errorf("encode trace: index %d out of range", el.Index)
+		}
+		err = enc.EncodeElement(doc.Traces[el.Index], xml.StartElement{Name: xml.Name{Local: "trace"}})
 	case ElementImage:
 		if el.Index < 0 || el.Index >= len(doc.Images) {
 			return fmt.Errorf("encode image: index %d out of range", el.Index)
@@ -1642,6 +2323,15 @@ func encodeElement(enc *xml.Encoder, out io.Writer, doc Document, el Element, op
 	if err != nil {
 		return err
 	}
+	if ordered != nil {
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+		enc = target
+		if err := encodeInAttrOrder(enc, ordered.Bytes(), el.AttrOrder); err != nil {
+			return err
+		}
+	}
 	if opts.PreserveWS && el.Trailing != "" {
 		if err := enc.Flush(); err != nil {
 			return err
@@ -1701,14 +2391,7 @@ func (d *Document) defaultElements() []Element {
 	}
 	for i := range d.Messages {
 		// Preserve role-specific element types.
-		elType := ElementHumanMsg
-		switch d.Messages[i].Role {
-		case "assistant":
-			elType = ElementAssistantMsg
-		case "system":
-			elType = ElementSystemMsg
-		}
-		out = append(out, d.newElement(elType, i, ""))
+		out = append(out, d.newElement(messageElementType(d.Messages[i].Role), i, ""))
 	}
 	for i := range d.ToolDefs {
 		out = append(out, d.newElement(ElementToolDefinition, i, ""))
@@ -1731,6 +2414,9 @@ func (d *Document) defaultElements() []Element {
 	for i := range d.Runtimes {
 		out = append(out, d.newElement(ElementRuntime, i, ""))
 	}
+	for i := range d.Traces {
+		out = append(out, d.newElement(ElementTrace, i, ""))
+	}
 	for i := range d.Audios {
 		out = append(out, d.newElement(ElementAudio, i, ""))
 	}
@@ -1753,8 +2439,8 @@ func (d Document) hasSchema() bool {
 	return d.Schema.Body != "" || len(d.Schema.Attrs) > 0
 }
 
-// payloadFor resolves concrete pointers for an element.
-func (d Document) payloadFor(el Element) ElementPayload {
+// payloadFor resolves concrete pointers for an element; meta, role, and output-schema point into d.
+func (d *Document) payloadFor(el Element) ElementPayload {
 	switch el.Type {
 	case ElementMeta:
 		return ElementPayload{Meta: &d.Meta}
@@ -1808,7 +2494,7 @@ func (d Document) payloadFor(el Element) ElementPayload {
 		if el.Index >= 0 && el.Index < len(d.Images) {
 			return ElementPayload{Image: &d.Images[el.Index]}
 		}
-	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg:
+	case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
 		if el.Index >= 0 && el.Index < len(d.Messages) {
 			return ElementPayload{Message: &d.Messages[el.Index]}
 		}
@@ -1840,6 +2526,10 @@ func (d Document) payloadFor(el Element) ElementPayload {
 		if el.Index >= 0 && el.Index < len(d.Runtimes) {
 			return ElementPayload{Runtime: &d.Runtimes[el.Index]}
 		}
+	case ElementTrace:
+		if el.Index >= 0 && el.Index < len(d.Traces) {
+			return ElementPayload{Trace: &d.Traces[el.Index]}
+		}
 	case ElementDiagram:
 		if el.Index >= 0 && el.Index < len(d.Diagrams) {
 			return ElementPayload{Diagram: &d.Diagrams[el.Index]}
@@ -1853,13 +2543,13 @@ func (d Document) payloadFor(el Element) ElementPayload {
 func wrapXMLError(err error, context string) error {
 	var se *xml.SyntaxError
 	if errors.As(err, &se) {
-		return &POMLError{Type: ErrDecode, Message: fmt.Sprintf("%s (line %d)", context, se.Line), Err: err}
+		return &POMLError{Type: ErrDecode, Code: CodeDecode, Message: fmt.Sprintf("%s (line %d)", context, se.Line), Err: err}
 	}
 	var ue *xml.UnmarshalError
 	if errors.As(err, &ue) {
-		return &POMLError{Type: ErrDecode, Message: context, Err: err}
+		return &POMLError{Type: ErrDecode, Code: CodeDecode, Message: context, Err: err}
 	}
-	return &POMLError{Type: ErrDecode, Message: context, Err: err}
+	return &POMLError{Type: ErrDecode, Code: CodeDecode, Message: context, Err: err}
 }
 
 func (d *Document) newElement(t ElementType, idx int, name string, raw ...string) Element {
@@ -1879,6 +2569,14 @@ func (d *Document) newElement(t ElementType, idx int, name string, raw ...string
 	return el
 }
 
+// lastElementID returns the ID of the last top-level element, or "" when there is none.
+func (d *Document) lastElementID() string {
+	if len(d.Elements) == 0 {
+		return ""
+	}
+	return d.Elements[len(d.Elements)-1].ID
+}
+
 func (d *Document) freshID() string {
 	id := fmt.Sprintf("el-%d", d.nextID)
 	d.nextID++
@@ -1895,10 +2593,19 @@ func renderToken(tok xml.Token) string {
 	return buf.String()
 }
 
+// indexGroup maps element types sharing a backing slice onto a single counter key.
+func indexGroup(t ElementType) ElementType {
+	switch t {
+	case ElementAssistantMsg, ElementSystemMsg, ElementMsg:
+		return ElementHumanMsg
+	}
+	return t
+}
+
 // reindex updates element indices to match current slice state after mutations.
 func (d *Document) reindex() {
 	taskIdx, inputIdx, docIdx, styleIdx, hintIdx, exIdx, cpIdx, outFmtIdx := 0, 0, 0, 0, 0, 0, 0, 0
-	msgIdx, toolDefIdx, toolReqIdx, toolRespIdx, toolResultIdx, toolErrorIdx, runtimeIdx, audioIdx, videoIdx, objIdx, imageIdx, diagramIdx := 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0
+	msgIdx, toolDefIdx, toolReqIdx, toolRespIdx, toolResultIdx, toolErrorIdx, runtimeIdx, traceIdx, audioIdx, videoIdx, objIdx, imageIdx, diagramIdx := 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0
 	for i := range d.Elements {
 		switch d.Elements[i].Type {
 		case ElementTask:
@@ -1925,7 +2632,7 @@ func (d *Document) reindex() {
 		case ElementOutputFormat:
 			d.Elements[i].Index = outFmtIdx
 			outFmtIdx++
-		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg:
+		case ElementHumanMsg, ElementAssistantMsg, ElementSystemMsg, ElementMsg:
 			d.Elements[i].Index = msgIdx
 			msgIdx++
 		case ElementToolDefinition:
@@ -1946,6 +2653,9 @@ func (d *Document) reindex() {
 		case ElementRuntime:
 			d.Elements[i].Index = runtimeIdx
 			runtimeIdx++
+		case ElementTrace:
+			d.Elements[i].Index = traceIdx
+			traceIdx++
 		case ElementAudio:
 			d.Elements[i].Index = audioIdx
 			audioIdx++
@@ -5,6 +5,8 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -107,6 +109,26 @@ func TestRoundTripPreservesOrderAndUnknown(t *testing.T) {
 	}
 }
 
+func TestUnknownNamespacedElementRoundTripsVerbatim(t *testing.T) {
+	raw := `<x:custom xmlns:x="urn:x" x:k='v'><x:child/><plain a="1"></plain></x:custom>`
+	src := "<poml>\n  <task>t</task>\n  " + raw + "\n</poml>"
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	el := doc.Elements[1]
+	if el.Type != ElementUnknown || el.RawXML != raw || el.Namespace != "urn:x" || el.Name != "custom" {
+		t.Fatalf("unknown element not preserved verbatim: %+v", el)
+	}
+	var buf bytes.Buffer
+	if err := doc.EncodeWithOptions(&buf, EncodeOptions{PreserveOrder: true, PreserveWS: true}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if !strings.Contains(buf.String(), "\n  "+raw+"\n") {
+		t.Fatalf("encoded output lost namespaced element:\n%s", buf.String())
+	}
+}
+
 func TestAttrsPreserved(t *testing.T) {
 	src := `<poml>
   <task foo="bar">body</task>
@@ -175,6 +197,39 @@ func TestPreserveTrailingWhitespace(t *testing.T) {
 	}
 }
 
+func TestEncodePrologueOptions(t *testing.T) {
+	src := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<!-- Copyright Example Corp -->\n<!DOCTYPE poml>\n<poml><task>caf\xe9</task></poml>"
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	encode := func(opts EncodeOptions) string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := doc.EncodeWithOptions(&buf, opts); err != nil {
+			t.Fatalf("encode %+v: %v", opts, err)
+		}
+		return buf.String()
+	}
+	if got := encode(EncodeOptions{}); got != "<poml><task>café</task></poml>" {
+		t.Fatalf("the prefix should be opt-in, got %q", got)
+	}
+	want := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!-- Copyright Example Corp -->\n<!DOCTYPE poml>\n<poml>"
+	if got := encode(EncodeOptions{PreservePrefix: true}); !strings.HasPrefix(got, want) {
+		t.Fatalf("kept prefix should be relabeled UTF-8, got %q", got)
+	}
+	opts := EncodeOptions{PreservePrefix: true, Declaration: XMLDeclaration{Standalone: "yes"}, Doctype: `poml SYSTEM "poml.dtd"`}
+	want = "<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"yes\"?>\n<!-- Copyright Example Corp -->\n<!DOCTYPE poml SYSTEM \"poml.dtd\">\n<poml>"
+	if got := encode(opts); !strings.HasPrefix(got, want) {
+		t.Fatalf("options should replace the kept declaration and doctype, got %q", got)
+	}
+	for _, decl := range []XMLDeclaration{{Encoding: "ISO-8859-1"}, {Standalone: "maybe"}, {Version: "2.0"}} {
+		if err := doc.EncodeWithOptions(io.Discard, EncodeOptions{Declaration: decl}); err == nil {
+			t.Fatalf("declaration %+v should be rejected", decl)
+		}
+	}
+}
+
 func TestParseOptionsDisableWhitespace(t *testing.T) {
 	src := "<poml><task>one</task><!-- gap --><task>two</task></poml>"
 	doc, err := ParseReaderWithOptions(strings.NewReader(src), ParseOptions{PreserveWhitespace: false})
@@ -404,6 +459,85 @@ func TestMutatorRemoveAndReplaceBodyBranches(t *testing.T) {
 	}
 }
 
+func TestMutatorSetBodyTextAndXML(t *testing.T) {
+	doc, err := ParseString("<poml>\n  <human-msg>hi</human-msg>\n  <assistant-msg>ok</assistant-msg>\n  <runtime temperature=\"0\"/>\n</poml>")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	err = doc.Mutate(func(el Element, _ ElementPayload, m *Mutator) error {
+		switch el.Type {
+		case ElementHumanMsg:
+			return m.SetBodyText(el, "is a < b & c?")
+		case ElementAssistantMsg:
+			if err := m.SetBodyXML(el, "<b>broken"); err == nil {
+				t.Fatalf("malformed markup should be rejected")
+			}
+			return m.SetBodyXML(el, "see <img src=\"a.png\"/> &amp; more")
+		case ElementRuntime:
+			if err := m.SetBodyText(el, "x"); err == nil {
+				t.Fatalf("runtime has no body to set")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("mutate: %v", err)
+	}
+	out := encodeString(t, doc, EncodeOptions{PreserveOrder: true})
+	for _, want := range []string{"<human-msg>is a &lt; b &amp; c?</human-msg>", `<assistant-msg>see <img src="a.png"/> &amp; more</assistant-msg>`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %s in:\n%s", want, out)
+		}
+	}
+	back, err := ParseString(out)
+	if err != nil {
+		t.Fatalf("reparse: %v", err)
+	}
+	if text, _ := charDataText(back.Messages[0].Body); text != "is a < b & c?" {
+		t.Fatalf("text body did not round-trip: %q", text)
+	}
+}
+
+func TestCustomRoleMessages(t *testing.T) {
+	src := "<poml>\n  <human-msg>Draft a slogan.</human-msg>\n  <msg role=\"critic\" tone=\"harsh\">Too long.</msg>\n  <msg role=\"planner\">Shorten it.</msg>\n</poml>"
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	critic := doc.Messages[1]
+	if doc.Elements[1].Type != ElementMsg || critic.Role != "critic" || critic.Attrs.Get("role") != "" || critic.Attrs.Get("tone") != "harsh" {
+		t.Fatalf("unexpected custom message: %+v %+v", doc.Elements[1], critic)
+	}
+	if out := encodeString(t, doc, EncodeOptions{PreserveOrder: true, PreserveWS: true}); out != src {
+		t.Fatalf("custom roles did not round-trip:\n%s", out)
+	}
+
+	out, err := Convert(doc, FormatOpenAIChat, ConvertOptions{RoleMap: map[string]string{"critic": "assistant", "planner": "system"}})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	msgs := out.(map[string]any)["messages"].([]map[string]any)
+	if msgs[1]["role"] != "assistant" || msgs[2]["role"] != "system" || doc.Messages[1].Role != "critic" {
+		t.Fatalf("RoleMap not applied (or leaked into the document): %v", msgs)
+	}
+	out, _ = Convert(doc, FormatLangChain, ConvertOptions{})
+	if lc := out.(map[string]any)["messages"].([]map[string]any); lc[1]["type"] != "human" {
+		t.Fatalf("unmapped custom roles should be sent as user turns: %v", lc)
+	}
+	if conv := doc.Conversation(); conv.Turns[1].Role != "critic" {
+		t.Fatalf("conversation role = %q", conv.Turns[1].Role)
+	}
+	text, _ := Convert(doc, FormatText, ConvertOptions{})
+	if !strings.Contains(text.(string), "Message (critic):\nToo long.") {
+		t.Fatalf("text output = %q", text)
+	}
+
+	doc.Messages[2].Role = ""
+	if err := doc.Validate(); !errors.Is(err, CodeMsgRole) {
+		t.Fatalf("msg without role should fail validation, got %v", err)
+	}
+}
+
 func TestWrapXMLError(t *testing.T) {
 	syn := &xml.SyntaxError{Line: 3}
 	err := wrapXMLError(syn, "ctx")
@@ -964,3 +1098,94 @@ func TestMutatorInsertDocumentAndStyle(t *testing.T) {
 		t.Fatalf("expected reindexed elements for docs/styles, got docs=%d styles=%d", seenDocs, seenStyles)
 	}
 }
+
+func TestMutatorInsertAfterKeepsDocumentOrder(t *testing.T) {
+	doc, err := ParseString(`<poml><task>A</task><human-msg>hi</human-msg><task>B</task><assistant-msg>yo</assistant-msg></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	err = doc.Mutate(func(el Element, payload ElementPayload, m *Mutator) error {
+		switch {
+		case el.Type == ElementTask && el.Index == 0:
+			m.InsertTaskAfter(el, "A2")
+		case el.Type == ElementHumanMsg:
+			m.InsertMessageAfter(el, "system", "sys")
+			m.InsertHintAfter(el, Hint{Body: "hint"})
+		case el.Type == ElementAssistantMsg:
+			if _, err := m.InsertAfter(el, ElementPayload{Raw: `<extra a="1"/>`}); err != nil {
+				return err
+			}
+			if _, err := m.InsertAfter(el, ElementPayload{}); err == nil {
+				t.Fatalf("expected error for empty payload")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("mutate: %v", err)
+	}
+	if got := strings.Join(doc.TaskBodies(), ","); got != "A,A2,B" {
+		t.Fatalf("task slice out of document order: %s", got)
+	}
+	var order []string
+	for _, el := range doc.Elements {
+		p := doc.payloadFor(el)
+		switch {
+		case p.Task != nil:
+			order = append(order, p.Task.Body)
+		case p.Message != nil:
+			order = append(order, p.Message.Role+":"+p.Message.Body)
+		case p.Hint != nil:
+			order = append(order, p.Hint.Body)
+		default:
+			order = append(order, el.Name)
+		}
+	}
+	want := "A,A2,human:hi,hint,system:sys,B,assistant:yo,extra"
+	if got := strings.Join(order, ","); got != want {
+		t.Fatalf("element order mismatch:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestMutateTxRollsBackOnError(t *testing.T) {
+	doc, err := ParseString(sample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	doc.Tasks[0].Attrs = []xml.Attr{{Name: xml.Name{Local: "caption"}, Value: "first"}}
+	var before bytes.Buffer
+	_ = doc.Encode(&before)
+	elements := slices.Clone(doc.Elements)
+
+	boom := errors.New("boom")
+	err = doc.MutateTx(func(el Element, payload ElementPayload, m *Mutator) error {
+		switch el.Type {
+		case ElementTask:
+			m.ReplaceBody(el, "half done")
+			payload.Task.Attrs = append(payload.Task.Attrs[:0], xml.Attr{Name: xml.Name{Local: "x"}, Value: "1"})
+			m.InsertTaskAfter(el, "inserted")
+		case ElementInput:
+			m.Remove(el)
+			return boom
+		}
+		return nil
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected fn error, got %v", err)
+	}
+	var after bytes.Buffer
+	_ = doc.Encode(&after)
+	if after.String() != before.String() || !slices.Equal(doc.Elements, elements) {
+		t.Fatalf("failed transaction modified the document:\n%s", after.String())
+	}
+
+	err = doc.MutateTx(func(el Element, payload ElementPayload, m *Mutator) error {
+		if el.Type == ElementTask {
+			m.ReplaceBody(el, "committed")
+		}
+		return nil
+	})
+	if err != nil || doc.Tasks[0].Body != "committed" {
+		t.Fatalf("successful transaction not committed: %v %+v", err, doc.Tasks)
+	}
+}
@@ -0,0 +1,126 @@
+package poml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// pomlDecoder is an xml.Decoder that also keeps the source bytes of the element being decoded,
+// so unknown elements can be preserved verbatim (prefixes, xmlns declarations, self-closing tags)
+// instead of being re-encoded token by token.
+type pomlDecoder struct {
+	*xml.Decoder
+	raw   *rawReader
+	spans *parseSpans // when non-nil, decodePoml records top-level element offsets here
+}
+
+// parseSpans records where the root tag and each top-level element sit in the input.
+// Element spans start where the element's leading whitespace begins, so consecutive spans
+// tile the content between the root start tag and </poml>.
+type parseSpans struct {
+	rootStart, rootEnd int // <poml ...> start tag
+	closeStart         int // </poml> end tag
+	elements           []sourceSpan
+}
+
+type sourceSpan struct {
+	start, end int
+}
+
+func newPOMLDecoder(r io.Reader) *pomlDecoder {
+	raw := newRawReader(r)
+	dec := &pomlDecoder{Decoder: xml.NewDecoder(raw), raw: raw}
+	dec.CharsetReader = charsetReader
+	return dec
+}
+
+// mark discards retained bytes before the current input offset; call it between top-level tokens.
+func (d *pomlDecoder) mark() {
+	d.raw.discard(d.InputOffset())
+}
+
+// skipRaw consumes the element whose start tag began at offset start and returns its source text.
+func (d *pomlDecoder) skipRaw(start int64) (string, error) {
+	if err := d.Skip(); err != nil {
+		return "", err
+	}
+	return d.raw.slice(start, d.InputOffset()), nil
+}
+
+// rawReader is an io.ByteReader, which xml.Decoder reads from directly without its own buffering,
+// so InputOffset maps exactly onto the bytes retained here.
+type rawReader struct {
+	r    io.ByteReader
+	buf  []byte
+	base int64 // absolute input offset of buf[0]
+}
+
+func newRawReader(r io.Reader) *rawReader {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &rawReader{r: br}
+}
+
+func (r *rawReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.buf = append(r.buf, b)
+	}
+	return b, err
+}
+
+func (r *rawReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	p[0] = b
+	return 1, nil
+}
+
+func (r *rawReader) discard(upto int64) {
+	n := upto - r.base
+	if n <= 0 {
+		return
+	}
+	if n > int64(len(r.buf)) {
+		n = int64(len(r.buf))
+	}
+	r.buf = append(r.buf[:0], r.buf[n:]...)
+	r.base += n
+}
+
+func (r *rawReader) slice(from, to int64) string {
+	lo, hi := from-r.base, to-r.base
+	if lo < 0 || hi > int64(len(r.buf)) || lo > hi {
+		return ""
+	}
+	return string(r.buf[lo:hi])
+}
+
+// restOfLine returns up to max bytes of the line continuing at offset from, reading past the
+// retained bytes when needed. It consumes input, so use it only once decoding has stopped.
+func (r *rawReader) restOfLine(from int64, max int) string {
+	line := []byte(r.slice(from, r.base+int64(len(r.buf))))
+	for len(line) < max && bytes.IndexByte(line, '\n') < 0 {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			break
+		}
+		line = append(line, b)
+	}
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	if len(line) > max {
+		line = line[:max]
+	}
+	return string(line)
+}
@@ -25,18 +25,59 @@ func (r DeckGLRenderer) Render(scene Scene) ([]byte, error) {
 type GraphvizRenderer struct {
 	// Directed overrides the scene edge directed flag; when nil, uses edge.Directed.
 	Directed *bool
+	// Project writes node and edge positions as the scene camera sees them (see ProjectScene)
+	// instead of their x and y.
+	Project bool
 }
 
 // Render converts the scene into DOT. Deterministic ordering is preserved/sorted for stability.
 func (r GraphvizRenderer) Render(scene Scene) ([]byte, error) {
+	if r.Project {
+		projected, err := ProjectScene(scene)
+		if err != nil {
+			return nil, err
+		}
+		scene = projected
+	}
 	var buf bytes.Buffer
 	buf.WriteString("digraph G {\n")
 	// Nodes
 	nodes := append([]SceneNode(nil), scene.Nodes...)
 	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
-	for _, n := range nodes {
+	tree := newSceneGroupTree(scene.Groups, nodes)
+	for _, n := range tree.members[""] {
 		fmt.Fprintf(&buf, "  %q%s;\n", n.ID, buildDOTNodeAttrs(n))
 	}
+	// Groups become clusters, nested by parent.
+	var cluster func(id, indent string)
+	cluster = func(id, indent string) {
+		g := tree.groups[id]
+		name := id
+		if !strings.HasPrefix(name, "cluster") {
+			name = "cluster_" + name
+		}
+		label := g.Label
+		if label == "" {
+			label = id
+		}
+		attrs := map[string]string{"label": label, "color": g.Style["stroke"]}
+		if fill := g.Style["color"]; fill != "" {
+			attrs["fillcolor"] = fill
+			attrs["style"] = "filled"
+		}
+		fmt.Fprintf(&buf, "%ssubgraph %q {\n", indent, name)
+		fmt.Fprintf(&buf, "%s  graph%s;\n", indent, buildDOTAttrs(attrs))
+		for _, n := range tree.members[id] {
+			fmt.Fprintf(&buf, "%s  %q%s;\n", indent, n.ID, buildDOTNodeAttrs(n))
+		}
+		for _, child := range tree.children[id] {
+			cluster(child, indent+"  ")
+		}
+		fmt.Fprintf(&buf, "%s}\n", indent)
+	}
+	for _, id := range tree.children[""] {
+		cluster(id, "  ")
+	}
 	// Edges
 	edges := append([]SceneEdge(nil), scene.Edges...)
 	sort.Slice(edges, func(i, j int) bool {
@@ -60,6 +101,7 @@ func (r GraphvizRenderer) Render(scene Scene) ([]byte, error) {
 			"penwidth": e.Style["width"],
 			"style":    e.Style["dash"],
 			"weight":   e.Weight,
+			"pos":      dotEdgePos(e.Path),
 		})
 		fmt.Fprintf(&buf, "  %q %s %q%s;\n", e.From, arrow, e.To, attrs)
 	}
@@ -67,6 +109,169 @@ func (r GraphvizRenderer) Render(scene Scene) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// MermaidRenderer emits a Mermaid flowchart for a Scene; groups become nested subgraphs.
+type MermaidRenderer struct {
+	// Direction is the flowchart direction (TD, LR, BT, RL); defaults to TD.
+	Direction string
+}
+
+// Render converts the scene into Mermaid flowchart text. Node IDs are reduced to
+// [A-Za-z0-9_] (colliding IDs get a numeric suffix) and labels are quoted.
+func (r MermaidRenderer) Render(scene Scene) ([]byte, error) {
+	dir := strings.ToUpper(strings.TrimSpace(r.Direction))
+	if dir == "" {
+		dir = "TD"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "flowchart %s\n", dir)
+	nodes := append([]SceneNode(nil), scene.Nodes...)
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	tree := newSceneGroupTree(scene.Groups, nodes)
+
+	ids := map[string]string{}
+	used := map[string]bool{}
+	mermaidID := func(raw string) string {
+		if id, ok := ids[raw]; ok {
+			return id
+		}
+		id := strings.Map(func(r rune) rune {
+			if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+				return r
+			}
+			return '_'
+		}, raw)
+		if id == "" || id == "end" || id[0] >= '0' && id[0] <= '9' {
+			id = "n_" + id
+		}
+		base := id
+		for i := 2; used[id]; i++ {
+			id = fmt.Sprintf("%s_%d", base, i)
+		}
+		ids[raw], used[id] = id, true
+		return id
+	}
+	// Groups and nodes share Mermaid's ID space, so reserve group IDs first.
+	for _, id := range tree.order {
+		mermaidID("group:" + id)
+	}
+	writeNode := func(n SceneNode, indent string) {
+		label := n.Label
+		if label == "" {
+			label = n.ID
+		}
+		fmt.Fprintf(&buf, "%s%s%s\n", indent, mermaidID(n.ID), mermaidShape(n.Style["shape"], label))
+	}
+	for _, n := range tree.members[""] {
+		writeNode(n, "  ")
+	}
+	var subgraph func(id, indent string)
+	subgraph = func(id, indent string) {
+		g := tree.groups[id]
+		label := g.Label
+		if label == "" {
+			label = id
+		}
+		fmt.Fprintf(&buf, "%ssubgraph %s[%s]\n", indent, mermaidID("group:"+id), mermaidLabel(label))
+		for _, n := range tree.members[id] {
+			writeNode(n, indent+"  ")
+		}
+		for _, child := range tree.children[id] {
+			subgraph(child, indent+"  ")
+		}
+		fmt.Fprintf(&buf, "%send\n", indent)
+	}
+	for _, id := range tree.children[""] {
+		subgraph(id, "  ")
+	}
+
+	edges := append([]SceneEdge(nil), scene.Edges...)
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	for _, e := range edges {
+		arrow := "---"
+		if e.Directed {
+			arrow = "-->"
+		}
+		if e.Kind != "" {
+			arrow += "|" + mermaidLabel(e.Kind) + "|"
+		}
+		fmt.Fprintf(&buf, "  %s %s %s\n", mermaidID(e.From), arrow, mermaidID(e.To))
+	}
+	return buf.Bytes(), nil
+}
+
+// mermaidShape wraps a quoted label in the bracket pair for a scene shape.
+func mermaidShape(shape, label string) string {
+	l := mermaidLabel(label)
+	switch strings.ToLower(shape) {
+	case "circle":
+		return "((" + l + "))"
+	case "hex", "hexagon":
+		return "{{" + l + "}}"
+	case "diamond":
+		return "{" + l + "}"
+	}
+	return "[" + l + "]"
+}
+
+// mermaidLabel quotes text for Mermaid, using entity codes for characters that end a label.
+func mermaidLabel(s string) string {
+	s = strings.NewReplacer(`"`, "#quot;", "|", "#124;", "\n", " ").Replace(s)
+	return `"` + s + `"`
+}
+
+// sceneGroupTree indexes scene groups for renderers that nest containers. Groups with a missing
+// parent, or caught in a parent cycle, are treated as top-level.
+type sceneGroupTree struct {
+	groups   map[string]SceneGroup
+	order    []string               // group IDs, sorted
+	children map[string][]string    // parent group ID ("" for top level) -> child group IDs
+	members  map[string][]SceneNode // group ID ("" for ungrouped) -> nodes, in input order
+}
+
+func newSceneGroupTree(groups []SceneGroup, nodes []SceneNode) sceneGroupTree {
+	t := sceneGroupTree{
+		groups:   make(map[string]SceneGroup, len(groups)),
+		children: make(map[string][]string),
+		members:  make(map[string][]SceneNode),
+	}
+	for _, g := range groups {
+		if _, dup := t.groups[g.ID]; !dup {
+			t.groups[g.ID] = g
+			t.order = append(t.order, g.ID)
+		}
+	}
+	sort.Strings(t.order)
+	for _, id := range t.order {
+		parent := t.groups[id].Parent
+		seen := map[string]bool{id: true}
+		for p := parent; p != ""; p = t.groups[p].Parent {
+			if _, ok := t.groups[p]; !ok || seen[p] {
+				parent = ""
+				break
+			}
+			seen[p] = true
+		}
+		t.children[parent] = append(t.children[parent], id)
+	}
+	owner := make(map[string]string)
+	for _, id := range t.order {
+		for _, m := range t.groups[id].Members {
+			if _, ok := owner[m]; !ok {
+				owner[m] = id
+			}
+		}
+	}
+	for _, n := range nodes {
+		t.members[owner[n.ID]] = append(t.members[owner[n.ID]], n)
+	}
+	return t
+}
+
 func buildDOTNodeAttrs(n SceneNode) string {
 	attrs := map[string]string{}
 	label := n.Label
@@ -96,6 +301,21 @@ func buildDOTNodeAttrs(n SceneNode) string {
 	return buildDOTAttrs(attrs)
 }
 
+// dotEdgePos writes a routed edge's path as a DOT pos spline. Graphviz splines are cubic
+// B-splines of 3n+1 points, so each straight segment is written with its control points on its
+// ends (p0 p0 p1 p1 p1 p2 ...), which draws the polyline exactly.
+func dotEdgePos(path [][3]float64) string {
+	if len(path) < 2 {
+		return ""
+	}
+	pt := func(p [3]float64) string { return fmt.Sprintf("%.3f,%.3f", p[0], p[1]) }
+	parts := []string{pt(path[0])}
+	for i := 1; i < len(path); i++ {
+		parts = append(parts, pt(path[i-1]), pt(path[i]), pt(path[i]))
+	}
+	return strings.Join(parts, " ")
+}
+
 func buildDOTAttrs(m map[string]string) string {
 	var parts []string
 	for k, v := range m {
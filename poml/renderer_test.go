@@ -52,3 +52,61 @@ func TestGraphvizRendererDOT(t *testing.T) {
 		t.Fatalf("dot mismatch.\n got:\n%s\nwant:\n%s", string(dot), string(want))
 	}
 }
+
+func TestRenderersNestGroups(t *testing.T) {
+	doc, err := ParseFile(filepath.Join("testdata", "diagrams", "cluster_sample.poml"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	dg := doc.Diagrams[0]
+	if err := ValidateDiagram(dg); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	scene, err := DiagramToScene(dg)
+	if err != nil {
+		t.Fatalf("scene: %v", err)
+	}
+	if len(scene.Groups) != 2 || scene.Groups[1].ID != "storage" || strings.Join(scene.Groups[1].Members, ",") != "cache,db" {
+		t.Fatalf("scene groups mismatch: %+v", scene.Groups)
+	}
+	if scene.Nodes[0].ID != "api" || scene.Nodes[0].Group != "backend" {
+		t.Fatalf("member node should carry its group: %+v", scene.Nodes[0])
+	}
+
+	mermaid, err := (MermaidRenderer{Direction: "lr"}).Render(scene)
+	if err != nil {
+		t.Fatalf("render mermaid: %v", err)
+	}
+	want := `flowchart LR
+  web["Web app"]
+  subgraph group_backend["Backend"]
+    api{{"API"}}
+    subgraph group_storage["Storage"]
+      cache["Redis #quot;hot#quot;"]
+      db(("Postgres"))
+    end
+  end
+  api --- cache
+  api -->|"reads"| db
+  web -->|"calls"| api
+`
+	if string(mermaid) != want {
+		t.Fatalf("mermaid mismatch.\n got:\n%s\nwant:\n%s", mermaid, want)
+	}
+
+	dot, err := (GraphvizRenderer{}).Render(scene)
+	if err != nil {
+		t.Fatalf("render dot: %v", err)
+	}
+	back, err := ParseDOT(string(dot))
+	if err != nil {
+		t.Fatalf("parse rendered dot: %v\n%s", err, dot)
+	}
+	if len(back.Graph.Groups) != 2 || back.Graph.Groups[1].ID != "cluster_storage" || back.Graph.Groups[1].Parent != "cluster_backend" || back.Graph.Groups[1].Label != "Storage" {
+		t.Fatalf("clusters did not round-trip: %+v\n%s", back.Graph.Groups, dot)
+	}
+	roundTrip, _ := DiagramToScene(back)
+	if got := roundTrip.Groups[1].Members; strings.Join(got, ",") != "cache,db" {
+		t.Fatalf("cluster members mismatch: %v\n%s", got, dot)
+	}
+}
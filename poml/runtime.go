@@ -0,0 +1,213 @@
+package poml
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// RuntimeOptions is a typed view of <runtime> attributes. Keys are matched after the same
+// normalization converters apply (maxTokens, max-tokens, and max_tokens are one key); unset
+// numeric fields are nil. Attributes without a typed field land in Extra under their snake_case
+// key with the raw attribute value; vendor attributes (x-min_p) land in ExtraBody.
+type RuntimeOptions struct {
+	Model       string
+	Temperature *float64
+	MaxTokens   *int
+	TopP        *float64
+	// Stop accepts a single sequence (stop="END") or a JSON array (stop='["a","b"]').
+	Stop  []string
+	Seed  *int
+	Extra map[string]string
+	// ExtraBody holds x- prefixed attributes, keyed by the name after the prefix as written.
+	// Converters send them in the request's vendor extension map instead of as top-level keys:
+	// extra_body for openai_chat, dict, pydantic, and langchain, additionalModelRequestFields
+	// for bedrock_converse, and options for ollama. mistral and cohere drop them.
+	ExtraBody map[string]string
+}
+
+// vendorParamPrefix marks runtime attributes that are provider-specific request parameters
+// (x-guided_json, x-min_p) rather than standard ones.
+const vendorParamPrefix = "x-"
+
+// vendorParam returns the parameter name of an x- prefixed runtime attribute.
+func vendorParam(name string) (string, bool) {
+	param, ok := strings.CutPrefix(name, vendorParamPrefix)
+	return param, ok && param != ""
+}
+
+// ParseRuntimeOptions reads runtime attributes into RuntimeOptions. Later attributes override
+// earlier ones. Malformed numeric values return a POMLError of type ErrValidate.
+func ParseRuntimeOptions(attrs []xml.Attr) (RuntimeOptions, error) {
+	var o RuntimeOptions
+	if err := o.apply(attrs, "runtime"); err != nil {
+		return RuntimeOptions{}, err
+	}
+	return o, nil
+}
+
+// Options parses the runtime's attributes; see ParseRuntimeOptions.
+func (r Runtime) Options() (RuntimeOptions, error) {
+	return ParseRuntimeOptions(r.Attrs)
+}
+
+// RuntimeOptions merges the <runtime> entries that carry no profile, in document order,
+// matching how converters combine them when ConvertOptions.Profile is empty.
+func (d Document) RuntimeOptions() (RuntimeOptions, error) {
+	return d.ProfileRuntimeOptions("")
+}
+
+// ProfileRuntimeOptions merges the <runtime> entries that apply under profile, with the same
+// precedence as ConvertOptions.Profile.
+func (d Document) ProfileRuntimeOptions(profile string) (RuntimeOptions, error) {
+	var o RuntimeOptions
+	for _, i := range d.activeRuntimes(profile) {
+		if err := o.apply(d.Runtimes[i].Attrs, fmt.Sprintf("runtime[%d]", i)); err != nil {
+			return RuntimeOptions{}, err
+		}
+	}
+	return o, nil
+}
+
+// Profiles lists the profiles named by the runtime's profile attribute (comma- or
+// space-separated, as in profile="prod,staging"). A runtime without one applies under every
+// profile.
+func (r Runtime) Profiles() []string {
+	for _, a := range r.Attrs {
+		if normalizeRuntimeKey(a.Name.Local) == "profile" {
+			return strings.FieldsFunc(a.Value, func(c rune) bool { return c == ',' || unicode.IsSpace(c) })
+		}
+	}
+	return nil
+}
+
+// activeRuntimes returns the indexes of the runtimes that apply under profile, in precedence
+// order: entries without a profile first, then the profile's own entries, each group in document
+// order. Later entries override earlier ones, so a profile's values win over shared values
+// wherever they appear in the document.
+func (d Document) activeRuntimes(profile string) []int {
+	var shared, own []int
+	for i, rt := range d.Runtimes {
+		switch profiles := rt.Profiles(); {
+		case len(profiles) == 0:
+			shared = append(shared, i)
+		case profile != "" && slices.Contains(profiles, profile):
+			own = append(own, i)
+		}
+	}
+	return append(shared, own...)
+}
+
+func (o *RuntimeOptions) apply(attrs []xml.Attr, label string) error {
+	for _, a := range attrs {
+		if param, ok := vendorParam(a.Name.Local); ok {
+			if o.ExtraBody == nil {
+				o.ExtraBody = map[string]string{}
+			}
+			o.ExtraBody[param] = a.Value
+			continue
+		}
+		key := normalizeRuntimeKey(a.Name.Local)
+		val := strings.TrimSpace(a.Value)
+		invalid := func(err error) error {
+			return &POMLError{Type: ErrValidate, Code: CodeRuntime, Message: fmt.Sprintf("%s %s=%q is not a number", label, a.Name.Local, a.Value), Err: err}
+		}
+		switch key {
+		case "profile":
+		case "model":
+			o.Model = val
+		case "temperature", "top_p":
+			f, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return invalid(err)
+			}
+			if key == "temperature" {
+				o.Temperature = &f
+			} else {
+				o.TopP = &f
+			}
+		case "max_tokens", "seed":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return invalid(err)
+			}
+			if key == "max_tokens" {
+				o.MaxTokens = &n
+			} else {
+				o.Seed = &n
+			}
+		case "stop":
+			var list []string
+			if err := json.Unmarshal([]byte(val), &list); err != nil {
+				list = []string{a.Value}
+			}
+			o.Stop = list
+		default:
+			if o.Extra == nil {
+				o.Extra = map[string]string{}
+			}
+			o.Extra[key] = a.Value
+		}
+	}
+	return nil
+}
+
+// Attrs encodes the options as <runtime> attributes: typed fields first using the kebab-case
+// spelling POML files use (max-tokens, top-p), then Extra sorted by key, then ExtraBody sorted by
+// key with its x- prefix restored.
+func (o RuntimeOptions) Attrs() []xml.Attr {
+	var attrs []xml.Attr
+	add := func(name, val string) {
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: name}, Value: val})
+	}
+	if o.Model != "" {
+		add("model", o.Model)
+	}
+	if o.Temperature != nil {
+		add("temperature", formatFloat(*o.Temperature))
+	}
+	if o.MaxTokens != nil {
+		add("max-tokens", strconv.Itoa(*o.MaxTokens))
+	}
+	if o.TopP != nil {
+		add("top-p", formatFloat(*o.TopP))
+	}
+	switch len(o.Stop) {
+	case 0:
+	case 1:
+		add("stop", o.Stop[0])
+	default:
+		data, _ := json.Marshal(o.Stop)
+		add("stop", string(data))
+	}
+	if o.Seed != nil {
+		add("seed", strconv.Itoa(*o.Seed))
+	}
+	keys := make([]string, 0, len(o.Extra))
+	for k := range o.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add(k, o.Extra[k])
+	}
+	keys = keys[:0]
+	for k := range o.ExtraBody {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add(vendorParamPrefix+k, o.ExtraBody[k])
+	}
+	return attrs
+}
+
+// Runtime wraps the encoded attributes in a Runtime payload.
+func (o RuntimeOptions) Runtime() Runtime {
+	return Runtime{Attrs: o.Attrs()}
+}
@@ -0,0 +1,147 @@
+package poml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeOptionsParseMergeAndEncode(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <runtime model="gpt-4o" temperature="0.2" maxTokens="5" stop='["END","STOP"]'/>
+  <runtime max-tokens="7" top_p="0.9" seed="42" response-format="json"/>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	opts, err := doc.RuntimeOptions()
+	if err != nil {
+		t.Fatalf("runtime options: %v", err)
+	}
+	if opts.Model != "gpt-4o" || *opts.Temperature != 0.2 || *opts.MaxTokens != 7 || *opts.TopP != 0.9 || *opts.Seed != 42 {
+		t.Fatalf("typed fields mismatch: %+v", opts)
+	}
+	if !reflect.DeepEqual(opts.Stop, []string{"END", "STOP"}) || opts.Extra["response_format"] != "json" {
+		t.Fatalf("stop/extra mismatch: %+v", opts)
+	}
+
+	built := NewBuilder().RuntimeOptions(opts).Build()
+	out, err := Convert(built, FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	rt := out.(map[string]any)
+	assertRuntimeValue(t, rt, "max_tokens", "7")
+	assertRuntimeValue(t, rt, "top_p", "0.9")
+	assertRuntimeValue(t, rt, "stop", "[END STOP]")
+	assertRuntimeValue(t, rt, "response_format", "json")
+	again, err := built.Runtimes[0].Options()
+	if err != nil || !reflect.DeepEqual(again, opts) {
+		t.Fatalf("encode/parse round trip mismatch: %+v vs %+v (%v)", again, opts, err)
+	}
+
+	single, err := ParseRuntimeOptions(NewBuilder().RuntimeOptions(RuntimeOptions{Stop: []string{"END"}}).Build().Runtimes[0].Attrs)
+	if err != nil || !reflect.DeepEqual(single.Stop, []string{"END"}) {
+		t.Fatalf("single stop mismatch: %+v (%v)", single, err)
+	}
+}
+
+func TestRuntimeOptionsRejectsMalformedNumbers(t *testing.T) {
+	doc, err := ParseString(`<poml><runtime temperature="0.1"/><runtime max-tokens="lots"/></poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	_, err = doc.RuntimeOptions()
+	var perr *POMLError
+	if !errors.As(err, &perr) || perr.Type != ErrValidate || !strings.Contains(perr.Message, "runtime[1] max-tokens") {
+		t.Fatalf("expected validation error naming runtime[1], got %v", err)
+	}
+}
+
+func TestRuntimeProfilesSelectAndOverride(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <runtime profile="prod" model="gpt-4o" temperature="0"/>
+  <runtime model="gpt-4o-mini" temperature="0.7" max-tokens="256"/>
+  <runtime profile="dev, staging" temperature="1"/>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for _, tc := range []struct {
+		profile, model string
+		temperature    float64
+	}{
+		{"", "gpt-4o-mini", 0.7},
+		{"prod", "gpt-4o", 0},
+		{"staging", "gpt-4o-mini", 1},
+		{"unknown", "gpt-4o-mini", 0.7},
+	} {
+		out, err := Convert(doc, FormatOpenAIChat, ConvertOptions{Profile: tc.profile})
+		if err != nil {
+			t.Fatalf("convert %q: %v", tc.profile, err)
+		}
+		rt := out.(map[string]any)
+		assertRuntimeValue(t, rt, "model", tc.model)
+		assertRuntimeValue(t, rt, "temperature", fmt.Sprint(tc.temperature))
+		assertRuntimeValue(t, rt, "max_tokens", "256")
+		if _, ok := rt["profile"]; ok {
+			t.Fatalf("profile attribute leaked into output: %v", rt)
+		}
+		opts, err := doc.ProfileRuntimeOptions(tc.profile)
+		if err != nil || opts.Model != tc.model || *opts.Temperature != tc.temperature || len(opts.Extra) != 0 {
+			t.Fatalf("profile %q options: %+v %v", tc.profile, opts, err)
+		}
+	}
+	if got := doc.Runtimes[2].Profiles(); !reflect.DeepEqual(got, []string{"dev", "staging"}) {
+		t.Fatalf("unexpected profiles: %v", got)
+	}
+}
+
+func TestRuntimeVendorParamsGoToExtraBody(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <human-msg>hi</human-msg>
+  <runtime temperature="0.2" x-min_p="0.05" x-guided_json='{"type":"object"}'/>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out, err := Convert(doc, FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	req := out.(map[string]any)
+	want := map[string]any{"min_p": 0.05, "guided_json": map[string]any{"type": "object"}}
+	if !reflect.DeepEqual(req["extra_body"], want) || req["temperature"] != 0.2 {
+		t.Fatalf("openai_chat extra_body = %v, temperature = %v", req["extra_body"], req["temperature"])
+	}
+	if _, ok := req["x_min_p"]; ok {
+		t.Fatalf("vendor parameter leaked to the top level: %v", req)
+	}
+	out, err = Convert(doc, FormatBedrockConverse, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert bedrock: %v", err)
+	}
+	if extra := out.(map[string]any)["additionalModelRequestFields"]; !reflect.DeepEqual(extra, want) {
+		t.Fatalf("bedrock additionalModelRequestFields = %v", extra)
+	}
+	out, err = Convert(doc, FormatMistral, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert mistral: %v", err)
+	}
+	if _, ok := out.(map[string]any)["extra_body"]; ok {
+		t.Fatalf("mistral kept extra_body")
+	}
+
+	opts, err := doc.RuntimeOptions()
+	if err != nil {
+		t.Fatalf("runtime options: %v", err)
+	}
+	if opts.ExtraBody["min_p"] != "0.05" || len(opts.Extra) != 0 {
+		t.Fatalf("runtime options = %+v", opts)
+	}
+	if attrs := opts.Attrs(); attrs[len(attrs)-1].Name.Local != "x-min_p" {
+		t.Fatalf("attrs lost the x- prefix: %v", attrs)
+	}
+}
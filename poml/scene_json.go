@@ -0,0 +1,168 @@
+package poml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// SceneEncoder streams a Scene as JSON, one node or edge at a time, so a scene too large to
+// marshal whole (100k+ nodes) is written with memory bounded by its largest element. Write all
+// nodes, then all edges, then Close with the remaining fields. The output has the layout
+// DeckGLRenderer produces, except that empty node and edge lists are written as [] rather than
+// null, and it ends with a newline.
+type SceneEncoder struct {
+	w       *bufio.Writer
+	scratch bytes.Buffer
+	stage   int // 0: after "id"; 1: inside "nodes"; 2: inside "edges"; 3: closed
+	count   int // items written to the open array
+}
+
+// errSceneEncoderOrder reports nodes written after edges, or anything written after Close.
+var errSceneEncoderOrder = errors.New("scene encoder: nodes must precede edges, and nothing may follow Close")
+
+// NewSceneEncoder starts the JSON object for a scene with the given id on w.
+func NewSceneEncoder(w io.Writer, id string) *SceneEncoder {
+	e := &SceneEncoder{w: bufio.NewWriter(w)}
+	e.w.WriteString("{\n  \"id\": ")
+	e.value(id, "  ") // a string always marshals; write errors resurface at Close
+	return e
+}
+
+// WriteNode appends n to the "nodes" list.
+func (e *SceneEncoder) WriteNode(n SceneNode) error {
+	if e.stage > 1 {
+		return errSceneEncoderOrder
+	}
+	e.open(1)
+	return e.item(n)
+}
+
+// WriteEdge appends ed to the "edges" list, closing the node list.
+func (e *SceneEncoder) WriteEdge(ed SceneEdge) error {
+	if e.stage > 2 {
+		return errSceneEncoderOrder
+	}
+	e.open(2)
+	return e.item(ed)
+}
+
+// Close writes the fields of rest other than ID, Nodes, and Edges (layers, groups, camera,
+// meta, keyframes), ends the object, and flushes. It reports any earlier write error.
+func (e *SceneEncoder) Close(rest Scene) error {
+	if e.stage > 2 {
+		return errSceneEncoderOrder
+	}
+	e.open(2)
+	e.closeArray()
+	e.stage = 3
+	if len(rest.Layers) > 0 {
+		if err := e.array("layers", len(rest.Layers), func(i int) any { return rest.Layers[i] }); err != nil {
+			return err
+		}
+	}
+	if len(rest.Groups) > 0 {
+		if err := e.array("groups", len(rest.Groups), func(i int) any { return rest.Groups[i] }); err != nil {
+			return err
+		}
+	}
+	if err := e.field("camera", rest.Camera); err != nil {
+		return err
+	}
+	if len(rest.Meta) > 0 {
+		if err := e.field("meta", rest.Meta); err != nil {
+			return err
+		}
+	}
+	if len(rest.Keyframes) > 0 {
+		if err := e.array("keyframes", len(rest.Keyframes), func(i int) any { return rest.Keyframes[i] }); err != nil {
+			return err
+		}
+	}
+	e.w.WriteString("\n}\n")
+	return e.w.Flush()
+}
+
+// WriteJSON writes s to w through a SceneEncoder; see it for the output layout.
+func (s Scene) WriteJSON(w io.Writer) error {
+	enc := NewSceneEncoder(w, s.ID)
+	for _, n := range s.Nodes {
+		if err := enc.WriteNode(n); err != nil {
+			return err
+		}
+	}
+	for _, ed := range s.Edges {
+		if err := enc.WriteEdge(ed); err != nil {
+			return err
+		}
+	}
+	return enc.Close(s)
+}
+
+// open advances to stage, opening the node and edge arrays in turn.
+func (e *SceneEncoder) open(stage int) {
+	for e.stage < stage {
+		if e.stage == 1 {
+			e.closeArray()
+		}
+		e.stage++
+		e.w.WriteString(",\n  \"" + [...]string{1: "nodes", 2: "edges"}[e.stage] + "\": [")
+		e.count = 0
+	}
+}
+
+func (e *SceneEncoder) closeArray() {
+	if e.count > 0 {
+		e.w.WriteString("\n  ]")
+		return
+	}
+	e.w.WriteString("]")
+}
+
+// item writes v as the next element of the open array.
+func (e *SceneEncoder) item(v any) error {
+	if e.count > 0 {
+		e.w.WriteByte(',')
+	}
+	e.count++
+	e.w.WriteString("\n    ")
+	return e.value(v, "    ")
+}
+
+// field writes `"key": v` as the next member of the scene object.
+func (e *SceneEncoder) field(key string, v any) error {
+	e.w.WriteString(",\n  \"" + key + "\": ")
+	return e.value(v, "  ")
+}
+
+// array writes a member whose n elements are marshaled one at a time.
+func (e *SceneEncoder) array(key string, n int, at func(int) any) error {
+	e.w.WriteString(",\n  \"" + key + "\": [")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		e.w.WriteString("\n    ")
+		if err := e.value(at(i), "    "); err != nil {
+			return err
+		}
+	}
+	e.w.WriteString("\n  ]")
+	return nil
+}
+
+// value marshals v indented to sit at prefix and writes it, returning marshal and write errors.
+func (e *SceneEncoder) value(v any, prefix string) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.scratch.Reset()
+	if err := json.Indent(&e.scratch, raw, prefix, "  "); err != nil {
+		return err
+	}
+	_, err = e.w.Write(e.scratch.Bytes())
+	return err
+}
@@ -0,0 +1,70 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSceneWriteJSONMatchesMarshalIndent(t *testing.T) {
+	x := 2.5
+	scene := Scene{
+		ID: "g<1>",
+		Nodes: []SceneNode{
+			{ID: "a", Label: "A & B", Position: [3]float64{1, 2, 3}, Style: map[string]string{"color": "red"}, Tags: []string{"x"}},
+			{ID: "b"},
+		},
+		Edges:     []SceneEdge{{From: "a", To: "b", Directed: true, Waypoints: [][3]float64{{1, 1, 0}}}},
+		Layers:    []SceneLayer{{ID: "l1", Z: "1"}},
+		Groups:    []SceneGroup{{ID: "g", Members: []string{"a", "b"}}},
+		Camera:    SceneCamera{Azimuth: "30"},
+		Meta:      map[string]any{"title": "demo", "n": 2},
+		Keyframes: []SceneKeyframe{{T: 1, Nodes: []SceneNodeFrame{{ID: "a", X: &x}}}},
+	}
+	want, err := json.MarshalIndent(scene, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := scene.WriteJSON(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got := buf.String(); got != string(want)+"\n" {
+		t.Fatalf("streamed JSON differs:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := (Scene{ID: "empty"}).WriteJSON(&buf); err != nil {
+		t.Fatalf("write empty: %v", err)
+	}
+	if got := buf.String(); got != "{\n  \"id\": \"empty\",\n  \"nodes\": [],\n  \"edges\": [],\n  \"camera\": {}\n}\n" {
+		t.Fatalf("empty scene = %q", got)
+	}
+}
+
+func TestSceneEncoderStreamsInOrder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewSceneEncoder(&buf, "big")
+	for i := 0; i < 1000; i++ {
+		if err := enc.WriteNode(SceneNode{ID: "n" + strings.Repeat("x", i%3)}); err != nil {
+			t.Fatalf("node %d: %v", i, err)
+		}
+	}
+	if err := enc.WriteEdge(SceneEdge{From: "n", To: "nx"}); err != nil {
+		t.Fatalf("edge: %v", err)
+	}
+	if err := enc.WriteNode(SceneNode{ID: "late"}); err == nil {
+		t.Fatalf("a node after edges should fail")
+	}
+	if err := enc.Close(Scene{Camera: SceneCamera{Distance: "10"}}); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	var back Scene
+	if err := json.Unmarshal(buf.Bytes(), &back); err != nil {
+		t.Fatalf("streamed output is not JSON: %v", err)
+	}
+	if back.ID != "big" || len(back.Nodes) != 1000 || len(back.Edges) != 1 || back.Camera.Distance != "10" {
+		t.Fatalf("round trip lost content: id=%q nodes=%d edges=%d camera=%+v", back.ID, len(back.Nodes), len(back.Edges), back.Camera)
+	}
+}
@@ -0,0 +1,213 @@
+package poml
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// SceneCrossLink is an edge whose end nodes SplitScene placed in different sub-scenes.
+type SceneCrossLink struct {
+	Edge      SceneEdge `json:"edge"`
+	FromScene string    `json:"from_scene"`
+	ToScene   string    `json:"to_scene"`
+}
+
+// SplitScene partitions scene into sub-scenes of at most maxNodes nodes each, for renderers that
+// cannot display the whole graph. Connected components stay together when they fit, and small
+// ones are packed into the same sub-scene; a component larger than maxNodes is cut into connected
+// pieces in breadth-first order. Edge direction is ignored when finding components.
+//
+// Sub-scenes are named "<id>-1", "<id>-2", ... and keep the scene's layers and camera, the
+// groups and keyframe entries of their own nodes, and its Meta plus "part", "parts", and
+// "split_from" (mirrored into "diagram_attrs", so diagrams built from them carry the same
+// attributes). Edges between sub-scenes are left out of both and listed as []SceneCrossLink under
+// Meta["cross_links"] of each sub-scene they touch. A scene that already fits is returned as is.
+func SplitScene(scene Scene, maxNodes int) ([]Scene, error) {
+	if maxNodes < 1 {
+		return nil, fmt.Errorf("split scene: maxNodes must be positive, got %d", maxNodes)
+	}
+	if len(scene.Nodes) <= maxNodes {
+		return []Scene{scene}, nil
+	}
+	index := make(map[string]int, len(scene.Nodes))
+	for i, n := range scene.Nodes {
+		index[n.ID] = i
+	}
+	adj := make([][]int, len(scene.Nodes))
+	for _, e := range scene.Edges {
+		from, okFrom := index[e.From]
+		to, okTo := index[e.To]
+		if okFrom && okTo && from != to {
+			adj[from] = append(adj[from], to)
+			adj[to] = append(adj[to], from)
+		}
+	}
+	bins := packScenePieces(splitScenePieces(adj, maxNodes), maxNodes)
+
+	prefix := scene.ID
+	if prefix == "" {
+		prefix = "scene"
+	}
+	partOf := make([]int, len(scene.Nodes))
+	parts := make([]Scene, len(bins))
+	for p, bin := range bins {
+		slices.Sort(bin)
+		part := Scene{
+			ID:     prefix + "-" + strconv.Itoa(p+1),
+			Nodes:  make([]SceneNode, 0, len(bin)),
+			Layers: scene.Layers,
+			Camera: scene.Camera,
+			Meta:   maps.Clone(scene.Meta),
+		}
+		for _, i := range bin {
+			partOf[i] = p
+			part.Nodes = append(part.Nodes, scene.Nodes[i])
+		}
+		parts[p] = part
+	}
+
+	links := make([][]SceneCrossLink, len(parts))
+	for _, e := range scene.Edges {
+		from, okFrom := index[e.From]
+		to, okTo := index[e.To]
+		switch {
+		case okFrom && okTo && partOf[from] != partOf[to]:
+			pf, pt := partOf[from], partOf[to]
+			link := SceneCrossLink{Edge: e, FromScene: parts[pf].ID, ToScene: parts[pt].ID}
+			links[pf] = append(links[pf], link)
+			links[pt] = append(links[pt], link)
+		case okFrom:
+			parts[partOf[from]].Edges = append(parts[partOf[from]].Edges, e)
+		case okTo:
+			parts[partOf[to]].Edges = append(parts[partOf[to]].Edges, e)
+		default:
+			// Neither end is a node; keep the dangling edge rather than lose it.
+			parts[0].Edges = append(parts[0].Edges, e)
+		}
+	}
+
+	for p := range parts {
+		part := &parts[p]
+		in := make(map[string]bool, len(part.Nodes))
+		for _, n := range part.Nodes {
+			in[n.ID] = true
+		}
+		part.Groups = splitSceneGroups(scene.Groups, in)
+		for _, kf := range scene.Keyframes {
+			var frames []SceneNodeFrame
+			for _, nf := range kf.Nodes {
+				if in[nf.ID] {
+					frames = append(frames, nf)
+				}
+			}
+			if len(frames) > 0 {
+				part.Keyframes = append(part.Keyframes, SceneKeyframe{T: kf.T, Nodes: frames})
+			}
+		}
+		if part.Meta == nil {
+			part.Meta = map[string]any{}
+		}
+		part.Meta["part"], part.Meta["parts"], part.Meta["split_from"] = p+1, len(parts), scene.ID
+		if len(links[p]) > 0 {
+			part.Meta["cross_links"] = links[p]
+		}
+		attrs := map[string]string{}
+		switch v := scene.Meta["diagram_attrs"].(type) {
+		case map[string]string:
+			maps.Copy(attrs, v)
+		case map[string]any:
+			for k, val := range v {
+				if s, ok := val.(string); ok {
+					attrs[k] = s
+				}
+			}
+		}
+		attrs["part"], attrs["parts"], attrs["split_from"] = strconv.Itoa(p+1), strconv.Itoa(len(parts)), scene.ID
+		part.Meta["diagram_attrs"] = attrs
+	}
+	return parts, nil
+}
+
+// splitScenePieces returns connected sets of node indices of at most maxNodes each. Starting
+// from the lowest unassigned index, a component that fits is taken whole; otherwise the first
+// maxNodes nodes of its breadth-first order, which are connected, are cut off and the rest is
+// revisited.
+func splitScenePieces(adj [][]int, maxNodes int) [][]int {
+	assigned := make([]bool, len(adj))
+	seen := make([]int, len(adj)) // start+1 of the search that last reached each node
+	var pieces [][]int
+	for start := range adj {
+		if assigned[start] {
+			continue
+		}
+		seen[start] = start + 1
+		order := []int{start}
+		for i := 0; i < len(order); i++ {
+			for _, next := range adj[order[i]] {
+				if !assigned[next] && seen[next] != start+1 {
+					seen[next] = start + 1
+					order = append(order, next)
+				}
+			}
+		}
+		if len(order) > maxNodes {
+			order = order[:maxNodes]
+		}
+		for _, v := range order {
+			assigned[v] = true
+		}
+		pieces = append(pieces, order)
+	}
+	return pieces
+}
+
+// packScenePieces places pieces first-fit into bins of at most maxNodes nodes.
+func packScenePieces(pieces [][]int, maxNodes int) [][]int {
+	var bins [][]int
+	for _, piece := range pieces {
+		placed := false
+		for b := range bins {
+			if len(bins[b])+len(piece) <= maxNodes {
+				bins[b] = append(bins[b], piece...)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			bins = append(bins, slices.Clone(piece))
+		}
+	}
+	return bins
+}
+
+// splitSceneGroups keeps the groups with members in the sub-scene, plus their ancestors, with
+// members narrowed to its nodes.
+func splitSceneGroups(groups []SceneGroup, in map[string]bool) []SceneGroup {
+	byID := make(map[string]SceneGroup, len(groups))
+	for _, g := range groups {
+		byID[g.ID] = g
+	}
+	keep := map[string]bool{}
+	for _, g := range groups {
+		if !slices.ContainsFunc(g.Members, func(id string) bool { return in[id] }) {
+			continue
+		}
+		for id := g.ID; id != "" && !keep[id]; id = byID[id].Parent {
+			keep[id] = true
+		}
+	}
+	var out []SceneGroup
+	for _, g := range groups {
+		if !keep[g.ID] {
+			continue
+		}
+		g.Members = slices.DeleteFunc(slices.Clone(g.Members), func(id string) bool { return !in[id] })
+		if len(g.Members) == 0 {
+			g.Members = nil
+		}
+		out = append(out, g)
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+package poml
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSplitScene(t *testing.T) {
+	scene := Scene{ID: "deps", Camera: SceneCamera{Distance: "9"}}
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		scene.Nodes = append(scene.Nodes, SceneNode{ID: id})
+	}
+	for _, pair := range [][2]string{{"a", "b"}, {"b", "c"}, {"c", "d"}, {"d", "e"}} {
+		scene.Edges = append(scene.Edges, SceneEdge{From: pair[0], To: pair[1], Directed: true})
+	}
+	scene.Groups = []SceneGroup{{ID: "outer"}, {ID: "g1", Parent: "outer", Members: []string{"a", "e"}}}
+	scene.Keyframes = []SceneKeyframe{{T: 1, Nodes: []SceneNodeFrame{{ID: "g", Label: "late"}}}}
+
+	parts, err := SplitScene(scene, 3)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	var got []string
+	for _, p := range parts {
+		var ids []string
+		for _, n := range p.Nodes {
+			ids = append(ids, n.ID)
+		}
+		got = append(got, p.ID+":"+strings.Join(ids, ","))
+	}
+	if strings.Join(got, " ") != "deps-1:a,b,c deps-2:d,e,f deps-3:g" {
+		t.Fatalf("parts = %v", got)
+	}
+	links, _ := parts[0].Meta["cross_links"].([]SceneCrossLink)
+	if len(links) != 1 || links[0].Edge.From != "c" || links[0].ToScene != "deps-2" || len(parts[1].Meta["cross_links"].([]SceneCrossLink)) != 1 {
+		t.Fatalf("cross links = %+v", parts[0].Meta["cross_links"])
+	}
+	if len(parts[0].Edges) != 2 || len(parts[1].Edges) != 1 || parts[2].Edges != nil {
+		t.Fatalf("edges per part: %d %d %d", len(parts[0].Edges), len(parts[1].Edges), len(parts[2].Edges))
+	}
+	if g := parts[1].Groups; len(g) != 2 || g[1].Members[0] != "e" || len(g[1].Members) != 1 || parts[2].Groups != nil {
+		t.Fatalf("groups = %+v / %+v", parts[1].Groups, parts[2].Groups)
+	}
+	if parts[2].Keyframes == nil || parts[0].Keyframes != nil || parts[2].Camera.Distance != "9" || parts[2].Meta["parts"] != 3 {
+		t.Fatalf("part 3 = %+v", parts[2])
+	}
+
+	if same, _ := SplitScene(scene, 10); len(same) != 1 || same[0].ID != "deps" || same[0].Meta != nil {
+		t.Fatalf("a scene that fits should come back unchanged: %+v", same)
+	}
+	if _, err := SplitScene(scene, 0); err == nil {
+		t.Fatalf("maxNodes 0 should fail")
+	}
+
+	out, err := DefaultConverterRegistry.Convert(context.Background(), "scene", "poml", scene, map[string]any{"max_nodes": 3})
+	if err != nil {
+		t.Fatalf("scene->poml: %v", err)
+	}
+	doc, err := ParseString(out.(string))
+	if err != nil {
+		t.Fatalf("parse split poml: %v", err)
+	}
+	if len(doc.Diagrams) != 3 || doc.Diagrams[1].Attrs.Get("part") != "2" || doc.Diagrams[1].Attrs.Get("split_from") != "deps" {
+		t.Fatalf("want three <diagram> parts, got:\n%s", out)
+	}
+	for _, d := range doc.Diagrams {
+		if err := ValidateDiagram(d); err != nil {
+			t.Fatalf("%s should validate: %v", d.ID, err)
+		}
+	}
+}
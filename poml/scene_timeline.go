@@ -0,0 +1,222 @@
+package poml
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// At returns the scene as it looks at time t. Every node property a keyframe mentions follows
+// its own track: positions, pct_complete, and numeric style values are linearly interpolated,
+// #rgb/#rrggbb colors are blended per channel, and other strings (labels, shapes) switch when the
+// next keyframe is reached. The node's own value acts as an implicit keyframe at the scene's first
+// keyframe time, so a property first mentioned later blends in from it; before the start and after
+// the last keyframe of a track the nearest value holds. The result has no keyframes and records t
+// as Meta["time"]; s is not modified. Edge paths are re-anchored to their end nodes' positions.
+func (s Scene) At(t float64) Scene {
+	out := s
+	out.Keyframes = nil
+	out.Meta = maps.Clone(s.Meta)
+	if out.Meta == nil {
+		out.Meta = map[string]any{}
+	}
+	out.Meta["time"] = t
+	out.Nodes = make([]SceneNode, len(s.Nodes))
+	index := make(map[string]int, len(s.Nodes))
+	for i, n := range s.Nodes {
+		n.Style = maps.Clone(n.Style)
+		out.Nodes[i] = n
+		index[n.ID] = i
+	}
+
+	keyframes := sortedKeyframes(s.Keyframes)
+	tracks := map[string]map[string][]timelinePoint{}
+	for _, kf := range keyframes {
+		for _, nf := range kf.Nodes {
+			if _, ok := index[nf.ID]; !ok {
+				continue
+			}
+			props := tracks[nf.ID]
+			if props == nil {
+				props = map[string][]timelinePoint{}
+				tracks[nf.ID] = props
+			}
+			add := func(key, val string) {
+				if val != "" {
+					props[key] = append(props[key], timelinePoint{t: kf.T, val: val})
+				}
+			}
+			add("x", formatOptionalFloat(nf.X))
+			add("y", formatOptionalFloat(nf.Y))
+			add("z", formatOptionalFloat(nf.Z))
+			add("label", nf.Label)
+			add("pct_complete", nf.PctComplete)
+			for k, v := range nf.Style {
+				add("style."+k, v)
+			}
+		}
+	}
+
+	for id, props := range tracks {
+		n := &out.Nodes[index[id]]
+		for key, points := range props {
+			if start := keyframes[0].T; points[0].t > start {
+				if base := sceneNodeValue(*n, key); base != "" {
+					points = append([]timelinePoint{{t: start, val: base}}, points...)
+				}
+			}
+			val := sampleTrack(points, t)
+			switch key {
+			case "x", "y", "z":
+				n.Position[key[0]-'x'] = parseFloat(val)
+			case "label":
+				n.Label = val
+			case "pct_complete":
+				n.PctComplete = val
+			default:
+				if n.Style == nil {
+					n.Style = map[string]string{}
+				}
+				n.Style[strings.TrimPrefix(key, "style.")] = val
+			}
+		}
+	}
+	// Routed edges follow their end nodes; waypoints stay put.
+	out.Edges = slices.Clone(s.Edges)
+	for i, e := range out.Edges {
+		if len(e.Path) == 0 {
+			continue
+		}
+		path := slices.Clone(e.Path)
+		if j, ok := index[e.From]; ok {
+			path[0] = out.Nodes[j].Position
+		}
+		if j, ok := index[e.To]; ok {
+			path[len(path)-1] = out.Nodes[j].Position
+		}
+		out.Edges[i].Path = path
+	}
+	return out
+}
+
+// Timeline samples the scene every step from its first to its last keyframe, always including
+// the last keyframe's time. A step <= 0 samples each distinct keyframe time instead; a scene
+// without keyframes yields a single scene at time 0.
+func (s Scene) Timeline(step float64) []Scene {
+	keyframes := sortedKeyframes(s.Keyframes)
+	if len(keyframes) == 0 {
+		return []Scene{s.At(0)}
+	}
+	first, last := keyframes[0].T, keyframes[len(keyframes)-1].T
+	var times []float64
+	if step <= 0 {
+		for _, kf := range keyframes {
+			if len(times) == 0 || kf.T != times[len(times)-1] {
+				times = append(times, kf.T)
+			}
+		}
+	} else {
+		// Multiply rather than accumulate so long timelines don't drift.
+		n := int(math.Floor((last-first)/step + 1e-9))
+		for i := 0; i <= n; i++ {
+			times = append(times, first+float64(i)*step)
+		}
+		if times[len(times)-1] < last-1e-9 {
+			times = append(times, last)
+		}
+	}
+	scenes := make([]Scene, 0, len(times))
+	for _, at := range times {
+		scenes = append(scenes, s.At(at))
+	}
+	return scenes
+}
+
+// sceneNodeValue reads the property a track key names.
+func sceneNodeValue(n SceneNode, key string) string {
+	switch key {
+	case "x", "y", "z":
+		return formatFloat(n.Position[key[0]-'x'])
+	case "label":
+		return n.Label
+	case "pct_complete":
+		return n.PctComplete
+	}
+	return n.Style[strings.TrimPrefix(key, "style.")]
+}
+
+type timelinePoint struct {
+	t   float64
+	val string
+}
+
+func sortedKeyframes(keyframes []SceneKeyframe) []SceneKeyframe {
+	if sort.SliceIsSorted(keyframes, func(i, j int) bool { return keyframes[i].T < keyframes[j].T }) {
+		return keyframes
+	}
+	sorted := append([]SceneKeyframe(nil), keyframes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].T < sorted[j].T })
+	return sorted
+}
+
+// sampleTrack evaluates a track (sorted by time) at t. Keyframes sharing a time resolve to the
+// later one.
+func sampleTrack(points []timelinePoint, t float64) string {
+	if t < points[0].t {
+		return points[0].val
+	}
+	for i := len(points) - 1; i >= 0; i-- {
+		if points[i].t <= t {
+			if i == len(points)-1 || points[i].t == t {
+				return points[i].val
+			}
+			a, b := points[i], points[i+1]
+			return interpolateValue(a.val, b.val, (t-a.t)/(b.t-a.t))
+		}
+	}
+	return points[0].val
+}
+
+func interpolateValue(a, b string, frac float64) string {
+	if fa, err := strconv.ParseFloat(strings.TrimSpace(a), 64); err == nil {
+		if fb, err := strconv.ParseFloat(strings.TrimSpace(b), 64); err == nil {
+			return formatFloat(math.Round((fa+(fb-fa)*frac)*1000) / 1000)
+		}
+	}
+	if ca, ok := parseHexColor(a); ok {
+		if cb, ok := parseHexColor(b); ok {
+			var mixed [3]int
+			for i := range mixed {
+				mixed[i] = int(math.Round(float64(ca[i]) + float64(cb[i]-ca[i])*frac))
+			}
+			return fmt.Sprintf("#%02x%02x%02x", mixed[0], mixed[1], mixed[2])
+		}
+	}
+	return a
+}
+
+func parseHexColor(s string) ([3]int, bool) {
+	var rgb [3]int
+	hex, ok := strings.CutPrefix(strings.TrimSpace(s), "#")
+	if !ok {
+		return rgb, false
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return rgb, false
+	}
+	for i := range rgb {
+		v, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return rgb, false
+		}
+		rgb[i] = int(v)
+	}
+	return rgb, true
+}
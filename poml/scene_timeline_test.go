@@ -0,0 +1,97 @@
+package poml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const timelineSample = `<poml>
+  <diagram id="rollout">
+    <graph>
+      <node id="a" label="queued" pct_complete="0" x="0" y="0" z="0">
+        <style color="#000000" shape="circle" size="1"/>
+      </node>
+      <node id="b" label="static" x="5" y="5" z="0"/>
+    </graph>
+    <frame t="10">
+      <node id="a" x="100" label="done" pct_complete="1">
+        <style color="#ffffff" size="3"/>
+      </node>
+    </frame>
+    <frame t="0">
+      <node id="a" x="0" y="0"/>
+    </frame>
+  </diagram>
+</poml>`
+
+func TestSceneTimelineInterpolation(t *testing.T) {
+	doc, err := ParseString(timelineSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	scene, err := DiagramToScene(doc.Diagrams[0])
+	if err != nil {
+		t.Fatalf("diagram to scene: %v", err)
+	}
+	if len(scene.Keyframes) != 2 || scene.Keyframes[0].T != 0 || scene.Keyframes[1].T != 10 {
+		t.Fatalf("keyframes not sorted by time: %+v", scene.Keyframes)
+	}
+
+	mid := scene.At(5)
+	a, b := mid.Nodes[0], mid.Nodes[1]
+	if a.Position != [3]float64{50, 0, 0} || a.PctComplete != "0.5" || a.Label != "queued" {
+		t.Fatalf("node a at t=5: %+v", a)
+	}
+	if a.Style["color"] != "#808080" || a.Style["size"] != "2" || a.Style["shape"] != "circle" {
+		t.Fatalf("style at t=5: %+v", a.Style)
+	}
+	if b.Position != [3]float64{5, 5, 0} || b.Label != "static" {
+		t.Fatalf("untouched node changed: %+v", b)
+	}
+	if mid.Keyframes != nil || mid.Meta["time"] != 5.0 {
+		t.Fatalf("sampled scene should drop keyframes and record time: %+v", mid.Meta)
+	}
+	if scene.Nodes[0].Style["color"] != "#000000" {
+		t.Fatalf("At modified the source scene: %+v", scene.Nodes[0].Style)
+	}
+	if end := scene.At(20).Nodes[0]; end.Position[0] != 100 || end.Label != "done" {
+		t.Fatalf("values should hold after the last keyframe: %+v", end)
+	}
+
+	frames := scene.Timeline(4)
+	var xs []float64
+	for _, f := range frames {
+		xs = append(xs, f.Nodes[0].Position[0])
+	}
+	if len(frames) != 4 || xs[1] != 40 || xs[3] != 100 || frames[3].Meta["time"] != 10.0 {
+		t.Fatalf("timeline(4) = %v", xs)
+	}
+	if got := len(scene.Timeline(0)); got != 2 {
+		t.Fatalf("timeline(0) should sample keyframe times, got %d scenes", got)
+	}
+
+	back := sceneToDiagram(scene)
+	if len(back.Frames) != 2 || back.Frames[1].T != "10" || back.Frames[1].Nodes[0].X != "100" || back.Frames[1].Nodes[0].Y != "" {
+		t.Fatalf("frames not round-tripped: %+v", back.Frames)
+	}
+}
+
+func TestValidateDiagramFrames(t *testing.T) {
+	src := strings.Replace(timelineSample, `<frame t="0">`, `<frame t="soon"><node id="ghost" x="1"/></frame><frame t="0">`, 1)
+	doc, err := ParseString(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var vErr *ValidationError
+	if err := ValidateDiagram(doc.Diagrams[0]); !errors.As(err, &vErr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	codes := map[ErrorCode]bool{}
+	for _, d := range vErr.Details {
+		codes[d.Code] = true
+	}
+	if !codes[CodeDiagramFrameTime] || !codes[CodeDiagramFrameNode] {
+		t.Fatalf("expected frame time and node codes, got %+v", vErr.Details)
+	}
+}
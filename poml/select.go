@@ -0,0 +1,160 @@
+package poml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Match pairs an element selected by Document.Select with its payload.
+type Match struct {
+	Element Element
+	Payload ElementPayload
+}
+
+// Select returns top-level elements matching an XPath-like query, in document order.
+//
+//	task            every <task>
+//	task[1]         the first <task> (positions are 1-based)
+//	input[@name='status']
+//	tool-request[@id][2]
+//	*[@caption]     any element carrying a caption attribute
+//
+// Names match the POML tag (human-msg, img, tool-definition, ...) or the ElementType (human_msg).
+// Attribute predicates see typed fields and extra attrs alike; predicates apply left to right.
+func (d Document) Select(query string) ([]Match, error) {
+	sel, err := parseSelector(query)
+	if err != nil {
+		return nil, err
+	}
+	var out []Match
+	for _, el := range d.resolveOrder() {
+		tag, _ := elementTag(d, el)
+		if sel.name != "*" && sel.name != tag && sel.name != string(el.Type) {
+			continue
+		}
+		out = append(out, Match{Element: el, Payload: d.payloadFor(el)})
+	}
+	for _, pred := range sel.preds {
+		var next []Match
+		for i, m := range out {
+			if pred.matches(d, m.Element, i+1) {
+				next = append(next, m)
+			}
+		}
+		out = next
+	}
+	return out, nil
+}
+
+type selector struct {
+	name  string
+	preds []selectorPred
+}
+
+type selectorPred struct {
+	pos      int // 1-based position; 0 for attribute predicates
+	attr     string
+	value    string
+	hasValue bool
+}
+
+func (p selectorPred) matches(d Document, el Element, pos int) bool {
+	if p.pos > 0 {
+		return p.pos == pos
+	}
+	_, attrs := elementTag(d, el)
+	for _, a := range attrs {
+		if a.Name.Local == p.attr {
+			return !p.hasValue || a.Value == p.value
+		}
+	}
+	return false
+}
+
+func parseSelector(query string) (selector, error) {
+	q := strings.TrimSpace(query)
+	end := strings.IndexByte(q, '[')
+	if end < 0 {
+		end = len(q)
+	}
+	sel := selector{name: strings.TrimSpace(q[:end])}
+	if sel.name == "" {
+		return selector{}, fmt.Errorf("select %q: missing element name", query)
+	}
+	rest := q[end:]
+	for rest != "" {
+		if rest[0] != '[' {
+			return selector{}, fmt.Errorf("select %q: unexpected %q", query, rest)
+		}
+		closeIdx := predicateEnd(rest)
+		if closeIdx < 0 {
+			return selector{}, fmt.Errorf("select %q: unterminated predicate", query)
+		}
+		pred, err := parsePredicate(strings.TrimSpace(rest[1:closeIdx]))
+		if err != nil {
+			return selector{}, fmt.Errorf("select %q: %w", query, err)
+		}
+		sel.preds = append(sel.preds, pred)
+		rest = strings.TrimSpace(rest[closeIdx+1:])
+	}
+	return sel, nil
+}
+
+// predicateEnd finds the ']' closing the predicate at s[0], skipping quoted values.
+func predicateEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func parsePredicate(body string) (selectorPred, error) {
+	if n, err := strconv.Atoi(body); err == nil {
+		if n < 1 {
+			return selectorPred{}, fmt.Errorf("position %d must be >= 1", n)
+		}
+		return selectorPred{pos: n}, nil
+	}
+	if !strings.HasPrefix(body, "@") {
+		return selectorPred{}, fmt.Errorf("unsupported predicate [%s]", body)
+	}
+	name, value, hasValue := strings.Cut(body[1:], "=")
+	pred := selectorPred{attr: strings.TrimSpace(name), hasValue: hasValue}
+	if pred.attr == "" {
+		return selectorPred{}, fmt.Errorf("empty attribute name in [%s]", body)
+	}
+	if hasValue {
+		value = strings.TrimSpace(value)
+		if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] {
+			return selectorPred{}, fmt.Errorf("attribute value must be quoted in [%s]", body)
+		}
+		pred.value = value[1 : len(value)-1]
+	}
+	return pred, nil
+}
+
+// elementTag returns the tag name and attributes an element encodes with.
+func elementTag(d Document, el Element) (string, []xml.Attr) {
+	dec := xml.NewDecoder(strings.NewReader(elementXML(d, el)))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return el.Name, nil
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, start.Attr
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package poml
+
+import "testing"
+
+func TestSelectQueries(t *testing.T) {
+	doc, err := ParseString(`<poml>
+  <task>first</task>
+  <input name="topic">t</input>
+  <task>second</task>
+  <input name="status" required="true">s</input>
+  <human-msg>hi</human-msg>
+  <hint caption="c">h</hint>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	cases := []struct {
+		query string
+		want  int
+	}{
+		{"task", 2},
+		{"input[@name='status']", 1},
+		{"input[@required='true'][1]", 1},
+		{"human-msg", 1},
+		{"human_msg", 1},
+		{"*[@caption]", 1},
+		{"task[3]", 0},
+	}
+	for _, tc := range cases {
+		got, err := doc.Select(tc.query)
+		if err != nil {
+			t.Fatalf("select %s: %v", tc.query, err)
+		}
+		if len(got) != tc.want {
+			t.Fatalf("select %s: got %d matches, want %d", tc.query, len(got), tc.want)
+		}
+	}
+	got, err := doc.Select("task[2]")
+	if err != nil || len(got) != 1 || got[0].Payload.Task.Body != "second" {
+		t.Fatalf("task[2] mismatch: %+v %v", got, err)
+	}
+	for _, bad := range []string{"", "task[", "task[0]", "input[@name=status]", "task[text()]"} {
+		if _, err := doc.Select(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+// Formats lists the conversion targets accepted by /v1/convert and /v1/render.
+var Formats = []poml.Format{
+	poml.FormatMessageDict,
+	poml.FormatDict,
+	poml.FormatOpenAIChat,
+	poml.FormatLangChain,
+	poml.FormatPydantic,
+	poml.FormatBedrockConverse,
+	poml.FormatOllama,
+	poml.FormatCohere,
+	poml.FormatText,
+	poml.FormatHFChat,
+	poml.FormatMistral,
+}
+
+// OpenAPI returns the OpenAPI 3.0 description of the server's endpoints as a JSON-ready value.
+// Request body limits reflect the server's options.
+func (s *Server) OpenAPI() map[string]any {
+	formats := make([]any, len(Formats))
+	for i, f := range Formats {
+		formats[i] = string(f)
+	}
+	str := func(desc string) map[string]any { return map[string]any{"type": "string", "description": desc} }
+	object := func(required []string, props map[string]any) map[string]any {
+		schema := map[string]any{"type": "object", "properties": props}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	ref := func(name string) map[string]any { return map[string]any{"$ref": "#/components/schemas/" + name} }
+	op := func(id, summary, reqSchema, respSchema string) map[string]any {
+		jsonBody := func(schema string) map[string]any {
+			return map[string]any{"application/json": map[string]any{"schema": ref(schema)}}
+		}
+		errResp := func(desc string) map[string]any {
+			return map[string]any{"description": desc, "content": jsonBody("Error")}
+		}
+		return map[string]any{"post": map[string]any{
+			"operationId": id,
+			"summary":     summary,
+			"requestBody": map[string]any{"required": true, "content": jsonBody(reqSchema)},
+			"responses": map[string]any{
+				"200": map[string]any{"description": "OK", "content": jsonBody(respSchema)},
+				"400": errResp("malformed request, POML, or template; unknown format"),
+				"413": errResp("request or document exceeds a size limit"),
+				"422": errResp("validation failed or an asset could not be read"),
+			},
+		}}
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "POML",
+			"version": "1",
+			"description": "Parse, validate, convert, and render POML prompts. Request bodies are limited to " +
+				formatBytes(s.opts.MaxRequestBytes) + ".",
+		},
+		"paths": map[string]any{
+			"/v1/parse":    op("parse", "Parse POML into its JSON document form", "SourceRequest", "ParseResponse"),
+			"/v1/validate": op("validate", "Report structural validation issues", "SourceRequest", "ValidateResponse"),
+			"/v1/convert":  op("convert", "Convert POML to a chat format", "ConvertRequest", "ConvertResponse"),
+			"/v1/render":   op("render", "Render a POML template with data, optionally converting the result", "RenderRequest", "RenderResponse"),
+		},
+		"components": map[string]any{"schemas": map[string]any{
+			"SourceRequest": object([]string{"source"}, map[string]any{"source": str("POML source")}),
+			"Format":        map[string]any{"type": "string", "enum": formats, "default": string(poml.FormatOpenAIChat)},
+			"Options": object(nil, map[string]any{
+				"schema_name":         str("OpenAI json_schema response format name"),
+				"schema_strict":       map[string]any{"type": "boolean"},
+				"json_object":         map[string]any{"type": "boolean"},
+				"system_prompt":       map[string]any{"type": "boolean", "description": "synthesize a system message from role, task, and style sections"},
+				"tool_choice":         str("auto, none, required, any, or a tool name (openai_chat, mistral)"),
+				"parallel_tool_calls": map[string]any{"type": "boolean"},
+			}),
+			"ConvertRequest": object([]string{"source"}, map[string]any{
+				"source":  str("POML source"),
+				"format":  ref("Format"),
+				"options": ref("Options"),
+			}),
+			"RenderRequest": object([]string{"template"}, map[string]any{
+				"template": str("POML text/template source; printed values are XML-escaped"),
+				"data":     map[string]any{"description": "template data"},
+				"format":   ref("Format"),
+				"options":  ref("Options"),
+			}),
+			"ParseResponse": object([]string{"document"}, map[string]any{"document": map[string]any{"type": "object"}}),
+			"ValidateResponse": object([]string{"valid"}, map[string]any{
+				"valid":      map[string]any{"type": "boolean"},
+				"validation": ref("ValidationError"),
+				"warnings":   ref("ValidationError"),
+			}),
+			"ValidationError": object(nil, map[string]any{
+				"error": map[string]any{"type": "string"},
+				"issues": map[string]any{"type": "array", "items": object([]string{"message"}, map[string]any{
+					"code":    map[string]any{"type": "string"},
+					"element": map[string]any{"type": "string"},
+					"field":   map[string]any{"type": "string"},
+					"message": map[string]any{"type": "string"},
+					"detail":  map[string]any{"type": "string"},
+				})},
+			}),
+			"ConvertResponse": object([]string{"format", "output"}, map[string]any{
+				"format": ref("Format"),
+				"output": map[string]any{"description": "converted prompt; a string for the text format"},
+			}),
+			"RenderResponse": object([]string{"source"}, map[string]any{
+				"source": str("rendered POML"),
+				"format": ref("Format"),
+				"output": map[string]any{"description": "converted prompt, present when format was given"},
+			}),
+			"Error": object([]string{"error"}, map[string]any{
+				"error": map[string]any{"type": "string"},
+				"code":  str("POML error code, e.g. POML-DECODE or POML-LIMIT"),
+			}),
+		}},
+	}
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return strconv.FormatInt(n>>20, 10) + "MB"
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return strconv.FormatInt(n>>10, 10) + "KB"
+	}
+	return strconv.FormatInt(n, 10) + " bytes"
+}
@@ -0,0 +1,350 @@
+// Package server exposes the SDK over HTTP so services written in other languages can parse,
+// validate, convert, and render POML without re-implementing the converters. Every endpoint takes
+// and returns JSON:
+//
+//	POST /v1/parse     {"source"}                               -> {"document"}
+//	POST /v1/validate  {"source"}                               -> {"valid", "validation", "warnings"}
+//	POST /v1/convert   {"source", "format", "options"}          -> {"format", "output"}
+//	POST /v1/render    {"template", "data", "format", "options"} -> {"source", "output"}
+//	GET  /openapi.json                                          -> OpenAPI 3 description
+//
+// Failures are {"error", "code"} with the POMLError code when one applies. Only net/http is
+// used; a gRPC front end can wrap Handler's logic but is not provided here.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+const (
+	defaultMaxRequestBytes int64 = 4 << 20 // 4MB
+	defaultMaxElementCount       = 100000
+	defaultMaxNestingDepth       = 100
+	defaultShutdownTimeout       = 10 * time.Second
+	defaultRenderTimeout         = 5 * time.Second
+)
+
+// Options configures a Server. The zero value is safe for untrusted callers.
+type Options struct {
+	// MaxRequestBytes caps request bodies (default 4MB); larger requests get 413.
+	MaxRequestBytes int64
+	// Parse is used for every source and rendered template. When it sets no limits,
+	// MaxDocumentBytes defaults to MaxRequestBytes, MaxElementCount to 100000, and
+	// MaxNestingDepth to 100.
+	Parse poml.ParseOptions
+	// Convert is the base for every conversion; request options are layered on top. When FS is
+	// nil, local asset and document paths resolve inside an empty filesystem, so requests cannot
+	// read the server's disk. Set AssetFetcher to allow remote sources.
+	Convert poml.ConvertOptions
+	// RenderTimeout bounds how long /v1/render may execute a template (default 5s); a template
+	// still running then fails with POML-TEMPLATE.
+	RenderTimeout time.Duration
+	// ShutdownTimeout bounds how long ListenAndServe waits for in-flight requests once its
+	// context is done (default 10s).
+	ShutdownTimeout time.Duration
+}
+
+// Server serves the SDK endpoints. It is an http.Handler; use ListenAndServe or Serve for a
+// listener with graceful shutdown.
+type Server struct {
+	opts Options
+	mux  *http.ServeMux
+}
+
+// New returns a Server with opts' zero fields replaced by their defaults.
+func New(opts Options) *Server {
+	if opts.MaxRequestBytes <= 0 {
+		opts.MaxRequestBytes = defaultMaxRequestBytes
+	}
+	if opts.Parse.MaxDocumentBytes <= 0 && opts.Parse.MaxElementCount <= 0 && opts.Parse.MaxNestingDepth <= 0 {
+		opts.Parse.MaxDocumentBytes = opts.MaxRequestBytes
+		opts.Parse.MaxElementCount = defaultMaxElementCount
+		opts.Parse.MaxNestingDepth = defaultMaxNestingDepth
+	}
+	if opts.Convert.FS == nil {
+		opts.Convert.FS = emptyFS{}
+	}
+	if opts.Parse.ResolveDocuments && opts.Parse.DocumentResolver == nil {
+		opts.Parse.DocumentResolver = poml.FileDocumentResolver{FS: opts.Convert.FS}
+	}
+	if opts.RenderTimeout <= 0 {
+		opts.RenderTimeout = defaultRenderTimeout
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = defaultShutdownTimeout
+	}
+	s := &Server{opts: opts, mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /v1/parse", s.handleParse)
+	s.mux.HandleFunc("POST /v1/validate", s.handleValidate)
+	s.mux.HandleFunc("POST /v1/convert", s.handleConvert)
+	s.mux.HandleFunc("POST /v1/render", s.handleRender)
+	s.mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe listens on addr and serves until ctx is done, then shuts down gracefully.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is done, then stops accepting connections and waits up to
+// ShutdownTimeout for in-flight requests. It returns nil after a clean shutdown.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// RequestOptions are the per-request conversion settings of /v1/convert and /v1/render.
+type RequestOptions struct {
+	SchemaName        string         `json:"schema_name,omitempty"`
+	SchemaStrict      *bool          `json:"schema_strict,omitempty"`
+	JSONObject        bool           `json:"json_object,omitempty"`
+	SystemPrompt      bool           `json:"system_prompt,omitempty"`
+	ToolChoice        string         `json:"tool_choice,omitempty"`
+	ParallelToolCalls *bool          `json:"parallel_tool_calls,omitempty"`
+	Profile           string         `json:"profile,omitempty"`
+	Inputs            map[string]any `json:"inputs,omitempty"`
+	StrictInputs      bool           `json:"strict_inputs,omitempty"`
+}
+
+type sourceRequest struct {
+	Source string `json:"source"`
+}
+
+type convertRequest struct {
+	Source  string         `json:"source"`
+	Format  poml.Format    `json:"format"`
+	Options RequestOptions `json:"options"`
+}
+
+type renderRequest struct {
+	Template string         `json:"template"`
+	Data     any            `json:"data"`
+	Format   poml.Format    `json:"format,omitempty"`
+	Options  RequestOptions `json:"options"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+func (s *Server) handleParse(w http.ResponseWriter, r *http.Request) {
+	var req sourceRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	doc, err := s.parse(req.Source)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"document": doc})
+}
+
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	var req sourceRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	opts := s.opts.Parse
+	opts.Validate = false
+	doc, err := poml.ParseReaderWithOptions(strings.NewReader(req.Source), opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := map[string]any{"valid": true}
+	warnings, err := doc.ValidateWithOptions(poml.ValidateOptions{})
+	if warnings != nil {
+		resp["warnings"] = warnings
+	}
+	var vErr *poml.ValidationError
+	if errors.As(err, &vErr) {
+		resp["valid"], resp["validation"] = false, vErr
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleConvert(w http.ResponseWriter, r *http.Request) {
+	var req convertRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	if req.Format == "" {
+		req.Format = poml.FormatOpenAIChat
+	}
+	doc, err := s.parse(req.Source)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	out, err := poml.ConvertContext(r.Context(), doc, req.Format, s.convertOptions(req.Options))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"format": req.Format, "output": out})
+}
+
+func (s *Server) handleRender(w http.ResponseWriter, r *http.Request) {
+	var req renderRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.opts.RenderTimeout)
+	src, err := poml.RenderTemplateContext(ctx, req.Template, req.Data, poml.TemplateOptions{Funcs: s.templateFuncs(), Parse: s.opts.Parse})
+	cancel()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := map[string]any{"source": src}
+	if req.Format != "" {
+		doc, err := s.parse(src)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		out, err := poml.ConvertContext(r.Context(), doc, req.Format, s.convertOptions(req.Options))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		resp["format"], resp["output"] = req.Format, out
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.OpenAPI())
+}
+
+func (s *Server) parse(src string) (poml.Document, error) {
+	return poml.ParseReaderWithOptions(strings.NewReader(src), s.opts.Parse)
+}
+
+func (s *Server) convertOptions(req RequestOptions) poml.ConvertOptions {
+	opts := s.opts.Convert
+	if req.SchemaName != "" {
+		opts.SchemaName = req.SchemaName
+	}
+	if req.SchemaStrict != nil {
+		opts.SchemaStrict = req.SchemaStrict
+	}
+	opts.JSONObject = opts.JSONObject || req.JSONObject
+	opts.SystemPrompt.Enabled = opts.SystemPrompt.Enabled || req.SystemPrompt
+	if req.ToolChoice != "" {
+		opts.ToolChoice = req.ToolChoice
+	}
+	if req.ParallelToolCalls != nil {
+		opts.ParallelToolCalls = req.ParallelToolCalls
+	}
+	if req.Profile != "" {
+		opts.Profile = req.Profile
+	}
+	if req.Inputs != nil {
+		opts.Inputs = req.Inputs
+	}
+	opts.StrictInputs = opts.StrictInputs || req.StrictInputs
+	return opts
+}
+
+// decode reads a JSON request body within MaxRequestBytes, writing the error response and
+// reporting false when it cannot.
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxRequestBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{
+				Error: fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit),
+				Code:  string(poml.CodeLimit),
+			})
+			return false
+		}
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "decode request: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// writeError maps SDK errors to statuses: limits 413, malformed input and templates 400,
+// validation 422, unknown formats 400, cancellation 503, anything else (e.g. unreadable assets)
+// 422.
+func writeError(w http.ResponseWriter, err error) {
+	resp := errorResponse{Error: err.Error()}
+	status := http.StatusUnprocessableEntity
+	var pErr *poml.POMLError
+	var vErr *poml.ValidationError
+	switch {
+	case errors.As(err, &pErr):
+		resp.Code = string(pErr.Code)
+		switch pErr.Code {
+		case poml.CodeLimit:
+			status = http.StatusRequestEntityTooLarge
+		case poml.CodeValidation:
+			status = http.StatusUnprocessableEntity
+		default:
+			status = http.StatusBadRequest
+		}
+	case errors.As(err, &vErr):
+		resp.Code = string(poml.CodeValidation)
+	case errors.Is(err, poml.ErrNotImplemented):
+		status = http.StatusBadRequest
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// emptyFS is the default Convert.FS: every path is missing.
+type emptyFS struct{}
+
+func (emptyFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+const sample = `<poml>
+  <meta><id>s</id><version>1</version><owner>o</owner></meta>
+  <role>Helper</role>
+  <task>Summarize</task>
+  <human-msg>Hi &amp; bye</human-msg>
+</poml>`
+
+func post(t *testing.T, s *Server, path string, body any) (int, map[string]any) {
+	t.Helper()
+	raw, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(raw))))
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("%s: decode response %q: %v", path, rec.Body.String(), err)
+	}
+	return rec.Code, out
+}
+
+func TestServerEndpoints(t *testing.T) {
+	s := New(Options{})
+
+	code, out := post(t, s, "/v1/parse", map[string]any{"source": sample})
+	if code != http.StatusOK || out["document"] == nil {
+		t.Fatalf("parse: %d %v", code, out)
+	}
+
+	code, out = post(t, s, "/v1/validate", map[string]any{"source": `<poml><task>t</task></poml>`})
+	if code != http.StatusOK || out["valid"] != false {
+		t.Fatalf("validate: %d %v", code, out)
+	}
+	issues := out["validation"].(map[string]any)["issues"].([]any)
+	if len(issues) == 0 || issues[0].(map[string]any)["code"] == "" {
+		t.Fatalf("validate issues: %v", issues)
+	}
+	if code, out = post(t, s, "/v1/validate", map[string]any{"source": sample}); out["valid"] != true {
+		t.Fatalf("validate sample: %d %v", code, out)
+	}
+	noAlt := strings.Replace(sample, "</poml>", `<img src="chart.png"/></poml>`, 1)
+	if code, out = post(t, s, "/v1/validate", map[string]any{"source": noAlt}); out["valid"] != true || out["warnings"] == nil {
+		t.Fatalf("a missing alt should be a warning, not a failure: %d %v", code, out)
+	}
+
+	code, out = post(t, s, "/v1/convert", map[string]any{"source": sample, "format": "openai_chat", "options": map[string]any{"system_prompt": true}})
+	if code != http.StatusOK {
+		t.Fatalf("convert: %d %v", code, out)
+	}
+	msgs := out["output"].(map[string]any)["messages"].([]any)
+	if first := msgs[0].(map[string]any); first["role"] != "system" {
+		t.Fatalf("expected synthesized system message, got %v", msgs)
+	}
+	if last := msgs[len(msgs)-1].(map[string]any); !strings.HasPrefix(last["content"].(string), "Hi") {
+		t.Fatalf("unexpected user message %v", last)
+	}
+
+	code, out = post(t, s, "/v1/render", map[string]any{
+		"template": `<poml><role>{{ .role }}</role><task>t</task></poml>`,
+		"data":     map[string]any{"role": "<admin>"},
+		"format":   "text",
+	})
+	if code != http.StatusOK || !strings.Contains(out["source"].(string), "&lt;admin&gt;") || !strings.Contains(out["output"].(string), "<admin>") {
+		t.Fatalf("render: %d %v", code, out)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var spec map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil || spec["openapi"] != "3.0.3" {
+		t.Fatalf("openapi: %v %v", err, rec.Body.String())
+	}
+	paths := spec["paths"].(map[string]any)
+	for _, p := range []string{"/v1/parse", "/v1/validate", "/v1/convert", "/v1/render"} {
+		if paths[p] == nil {
+			t.Fatalf("spec missing %s", p)
+		}
+	}
+}
+
+func TestServerErrorsAndLimits(t *testing.T) {
+	s := New(Options{MaxRequestBytes: 512})
+	cases := []struct {
+		path   string
+		body   any
+		status int
+		code   string
+	}{
+		{"/v1/parse", map[string]any{"source": "<poml><task>"}, http.StatusBadRequest, string(poml.CodeDecode)},
+		{"/v1/parse", map[string]any{"source": strings.Repeat("x", 600)}, http.StatusRequestEntityTooLarge, string(poml.CodeLimit)},
+		{"/v1/parse", map[string]any{"source": sample, "extra": 1}, http.StatusBadRequest, ""},
+		{"/v1/convert", map[string]any{"source": sample, "format": "nope"}, http.StatusBadRequest, ""},
+		{"/v1/convert", map[string]any{"source": `<poml><img src="secret.png"/></poml>`}, http.StatusUnprocessableEntity, ""},
+		{"/v1/render", map[string]any{"template": `{{ .x`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ repeat 1000 "x" }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ indent 2000000000 "x" }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ nindent 2000000000 "x" }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ range list 1 2 3 4 5 6 7 8 }}{{ range list 1 2 3 4 5 6 7 8 }}0123456789{{ end }}{{ end }}`}, http.StatusRequestEntityTooLarge, string(poml.CodeLimit)},
+		{"/v1/render", map[string]any{"template": `{{ replace "x" "xxxxxxxxxx" (replace "x" "xxxxxxxxxx" (replace "x" "xxxxxxxxxx" (replace "x" "xxxxxxxxxx" "xxxxxxxxxx"))) }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ replace "" "0123456789" "0123456789012345678901234567890123456789012345678901234567890123456789" }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ $s := repeat 200 "x" }}{{ join "" (list $s $s $s) }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ $s := list 1 }}{{ range list 1 2 3 4 5 6 7 8 }}{{ $s = list $s $s }}{{ end }}{{ len $s }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ join (repeat 300 "," ) (list 1 2 3) }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ len (splitList "" (repeat 300 "x")) }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ printf "%999999999d" 1 }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ printf "%*d" 999999999 1 }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ len (xmlEscape (repeat 110 "&")) }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ len (js (js (js (repeat 100 "<")))) }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+		{"/v1/render", map[string]any{"template": `{{ $v := list }}{{ range list 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 }}{{ $v = list $v }}{{ end }}{{ len (toPrettyJson $v) }}`}, http.StatusBadRequest, string(poml.CodeTemplate)},
+	}
+	for _, tc := range cases {
+		status, out := post(t, s, tc.path, tc.body)
+		if status != tc.status || (tc.code != "" && out["code"] != tc.code) || out["error"] == "" {
+			t.Fatalf("%s %v: got %d %v, want %d %s", tc.path, tc.body, status, out, tc.status, tc.code)
+		}
+	}
+
+	if status, out := post(t, s, "/v1/render", map[string]any{"template": `<poml><task>{{ indent -3 "a" }}{{ repeat -1 "b" }}</task></poml>`}); status != http.StatusOK || out["source"] != "<poml><task>a</task></poml>" {
+		t.Fatalf("negative counts should render nothing: %d %v", status, out)
+	}
+
+	bounded := `<poml><task>{{ replace "a" "bb" "aa" }} {{ join "," (splitList ";" "1;2") }} {{ printf "%03d" 7 }} {{ toPrettyJson (dict "a" (list 1)) }}</task></poml>`
+	want := "<poml><task>bbbb 1,2 007 {\n  &#34;a&#34;: [\n    1\n  ]\n}</task></poml>"
+	if status, out := post(t, s, "/v1/render", map[string]any{"template": bounded}); status != http.StatusOK || out["source"] != want {
+		t.Fatalf("bounded helpers: %d %v", status, out)
+	}
+
+	withFS := New(Options{Convert: poml.ConvertOptions{FS: fstest.MapFS{"a.png": {Data: []byte("png")}}}})
+	if status, out := post(t, withFS, "/v1/convert", map[string]any{"source": `<poml><img src="a.png"/></poml>`}); status != http.StatusOK {
+		t.Fatalf("convert with FS: %d %v", status, out)
+	}
+}
+
+func TestServerRenderTimeout(t *testing.T) {
+	s := New(Options{RenderTimeout: 50 * time.Millisecond})
+	for _, tmpl := range []string{
+		`{{ range 300000000 }}{{ end }}`,
+		`{{ define "a" }}{{ if lt (len .) 40 }}{{ template "a" (print . "x") }}{{ template "a" (print . "x") }}{{ end }}{{ end }}{{ template "a" "" }}`,
+	} {
+		start := time.Now()
+		status, out := post(t, s, "/v1/render", map[string]any{"template": tmpl})
+		if status != http.StatusBadRequest || out["code"] != string(poml.CodeTemplate) || !strings.Contains(out["error"].(string), "deadline exceeded") {
+			t.Fatalf("%s: got %d %v, want a POML-TEMPLATE deadline error", tmpl, status, out)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("%s: render ran for %v after its deadline", tmpl, elapsed)
+		}
+	}
+}
+
+func TestServeShutsDownWithContext(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- New(Options{}).Serve(ctx, ln) }()
+
+	resp, err := http.Post("http://"+ln.Addr().String()+"/v1/validate", "application/json", strings.NewReader(`{"source":"<poml/>"}`))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after cancel")
+	}
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+// maxTemplateValueDepth bounds how deeply list and dict values may nest.
+const maxTemplateValueDepth = 100
+
+// templateFuncs replaces every helper that can grow a string or collection so no single call
+// builds a value larger than the document limit: repeat, indent, nindent, replace, join, and
+// splitList check the result size before building it, list and dict check the size of what they
+// collect, printf rejects padding wider than the limit, and the escaping and encoding helpers
+// check what they return. Together with the output cap this keeps a small template from
+// allocating far more than it may print.
+func (s *Server) templateFuncs() template.FuncMap {
+	limit := s.opts.Parse.MaxDocumentBytes
+	if limit <= 0 {
+		limit = s.opts.MaxRequestBytes
+	}
+	check := func(name string, n int64) error {
+		if n > limit {
+			return fmt.Errorf("%s: result exceeds %d bytes", name, limit)
+		}
+		return nil
+	}
+	capped := func(name string, out string) (string, error) {
+		if err := check(name, int64(len(out))); err != nil {
+			return "", err
+		}
+		return out, nil
+	}
+	indent := func(name string, n int, str string) (string, error) {
+		lines := int64(strings.Count(str, "\n")) + 1
+		if n > 0 {
+			if err := check(name, int64(len(str))+int64(n)*lines); err != nil {
+				return "", err
+			}
+		}
+		pad := strings.Repeat(" ", max(n, 0))
+		return pad + strings.ReplaceAll(str, "\n", "\n"+pad), nil
+	}
+	collect := func(name string, vals []any) error {
+		size, err := templateValueSize(vals, limit, 0)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return check(name, size)
+	}
+	base := poml.TemplateFuncs()
+	return template.FuncMap{
+		"repeat": func(n int, str string) (string, error) {
+			if n > 0 {
+				if err := check("repeat", int64(len(str))*int64(n)); err != nil {
+					return "", err
+				}
+			}
+			return strings.Repeat(str, max(n, 0)), nil
+		},
+		"indent": func(n int, str string) (string, error) { return indent("indent", n, str) },
+		"nindent": func(n int, str string) (string, error) {
+			out, err := indent("nindent", n, str)
+			return "\n" + out, err
+		},
+		"replace": func(old, new, str string) (string, error) {
+			n := int64(strings.Count(str, old))
+			if err := check("replace", int64(len(str))+n*int64(len(new)-len(old))); err != nil {
+				return "", err
+			}
+			return strings.ReplaceAll(str, old, new), nil
+		},
+		"splitList": func(sep, str string) ([]string, error) {
+			n := int64(strings.Count(str, sep)) + 1
+			if err := check("splitList", int64(len(str))+n*templateItemSize); err != nil {
+				return nil, err
+			}
+			return strings.Split(str, sep), nil
+		},
+		"join": func(sep string, v any) (string, error) {
+			rv := reflect.ValueOf(v)
+			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+				return capped("join", fmt.Sprint(v))
+			}
+			size := int64(max(rv.Len()-1, 0)) * int64(len(sep))
+			if err := check("join", size); err != nil {
+				return "", err
+			}
+			parts := make([]string, rv.Len())
+			for i := range parts {
+				parts[i] = fmt.Sprint(rv.Index(i).Interface())
+				if size += int64(len(parts[i])); size > limit {
+					return "", check("join", size)
+				}
+			}
+			return strings.Join(parts, sep), nil
+		},
+		"list": func(vals ...any) ([]any, error) {
+			if err := collect("list", vals); err != nil {
+				return nil, err
+			}
+			return vals, nil
+		},
+		"dict": func(kv ...any) (map[string]any, error) {
+			if err := collect("dict", kv); err != nil {
+				return nil, err
+			}
+			return base["dict"].(func(...any) (map[string]any, error))(kv...)
+		},
+		"printf": func(format string, args ...any) (string, error) {
+			if err := checkPrintfWidths(format, limit); err != nil {
+				return "", err
+			}
+			return capped("printf", fmt.Sprintf(format, args...))
+		},
+		"print":    func(args ...any) (string, error) { return capped("print", fmt.Sprint(args...)) },
+		"println":  func(args ...any) (string, error) { return capped("println", fmt.Sprintln(args...)) },
+		"html":     func(args ...any) (string, error) { return capped("html", template.HTMLEscaper(args...)) },
+		"js":       func(args ...any) (string, error) { return capped("js", template.JSEscaper(args...)) },
+		"urlquery": func(args ...any) (string, error) { return capped("urlquery", template.URLQueryEscaper(args...)) },
+		"upper":    func(str string) (string, error) { return capped("upper", strings.ToUpper(str)) },
+		"lower":    func(str string) (string, error) { return capped("lower", strings.ToLower(str)) },
+		"title":    func(str string) (string, error) { return capped("title", base["title"].(func(string) string)(str)) },
+		"quote":    func(v any) (string, error) { return capped("quote", base["quote"].(func(any) string)(v)) },
+		"squote":   func(v any) (string, error) { return capped("squote", base["squote"].(func(any) string)(v)) },
+		"xmlEscape": func(v any) (poml.RawXML, error) {
+			out := base["xmlEscape"].(func(any) poml.RawXML)(v)
+			return out, check("xmlEscape", int64(len(out)))
+		},
+		"toJson": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			return capped("toJson", string(data))
+		},
+		"toPrettyJson": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			if err := check("toPrettyJson", jsonIndentSize(data)); err != nil {
+				return "", err
+			}
+			out, err := json.MarshalIndent(v, "", "  ")
+			return string(out), err
+		},
+	}
+}
+
+// templateItemSize is what templateValueSize charges per collection item beyond its content, so
+// collections of empty strings are not free.
+const templateItemSize = 8
+
+// templateValueSize estimates how many bytes v takes to print, counting shared values once per
+// reference, and stops early once the estimate passes limit.
+func templateValueSize(v any, limit int64, depth int) (int64, error) {
+	if depth > maxTemplateValueDepth {
+		return 0, fmt.Errorf("values nest more than %d deep", maxTemplateValueDepth)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return int64(rv.Len()), nil
+	case reflect.Slice, reflect.Array:
+		size := int64(2)
+		for i := 0; i < rv.Len() && size <= limit; i++ {
+			n, err := templateValueSize(rv.Index(i).Interface(), limit, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			size += n + templateItemSize
+		}
+		return size, nil
+	case reflect.Map:
+		size := int64(5)
+		for it := rv.MapRange(); it.Next() && size <= limit; {
+			k, err := templateValueSize(it.Key().Interface(), limit, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			n, err := templateValueSize(it.Value().Interface(), limit, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			size += k + n + templateItemSize
+		}
+		return size, nil
+	}
+	return templateItemSize, nil
+}
+
+// checkPrintfWidths rejects widths and precisions that would pad a printf result past limit,
+// including ones taken from the arguments with *.
+func checkPrintfWidths(format string, limit int64) error {
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		for i++; i < len(format) && strings.IndexByte("+-# 0123456789.*[]", format[i]) >= 0; i++ {
+			if format[i] == '*' {
+				return errors.New("printf: * widths are not supported")
+			}
+			j := i
+			for j < len(format) && format[j] >= '0' && format[j] <= '9' {
+				j++
+			}
+			if j > i {
+				if n, err := strconv.ParseInt(format[i:j], 10, 64); err != nil || n > limit {
+					return fmt.Errorf("printf: width %s exceeds %d bytes", format[i:j], limit)
+				}
+				i = j - 1
+			}
+		}
+	}
+	return nil
+}
+
+// jsonIndentSize returns the length json.Indent with a two-space indent would give compact.
+func jsonIndentSize(compact []byte) int64 {
+	size := int64(len(compact))
+	depth := int64(0)
+	inString := false
+	for i := 0; i < len(compact); i++ {
+		c := compact[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			if i+1 < len(compact) && (compact[i+1] == '}' || compact[i+1] == ']') {
+				i++
+				continue
+			}
+			depth++
+			size += 1 + 2*depth
+		case '}', ']':
+			depth--
+			size += 1 + 2*depth
+		case ',':
+			size += 1 + 2*depth
+		case ':':
+			size++
+		}
+	}
+	return size
+}
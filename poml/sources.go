@@ -0,0 +1,83 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// sourceTagRe matches the start tag of an element that carries a src attribute in a body.
+var sourceTagRe = regexp.MustCompile(`(?i)<(img|audio|video|document)\b[^>]*>`)
+
+// srcAttrRe matches a src attribute inside a start tag.
+var srcAttrRe = regexp.MustCompile(`(\ssrc\s*=\s*)(?:"([^"]*)"|'([^']*)')`)
+
+// RewriteSources calls fn with the src of every <img>, <audio>, <video>, and <document>, both
+// top-level elements and tags inside element bodies (messages, hints, examples, ...), and
+// replaces it with the value fn returns. tag is the lower-case element name; empty sources are
+// skipped, as are CDATA bodies, which hold literal text. It stops at the first error, leaving
+// earlier rewrites in place, so callers wanting all-or-nothing should rewrite a Clone.
+func (d *Document) RewriteSources(fn func(tag, src string) (string, error)) error {
+	rewrite := func(tag string, src *string) error {
+		if strings.TrimSpace(*src) == "" {
+			return nil
+		}
+		out, err := fn(tag, *src)
+		if err != nil {
+			return err
+		}
+		*src = out
+		return nil
+	}
+	for _, el := range d.Elements {
+		var err error
+		switch el.Type {
+		case ElementImage:
+			err = rewrite("img", &d.Images[el.Index].Src)
+		case ElementAudio:
+			err = rewrite("audio", &d.Audios[el.Index].Src)
+		case ElementVideo:
+			err = rewrite("video", &d.Videos[el.Index].Src)
+		case ElementDocument:
+			err = rewrite("document", &d.Documents[el.Index].Src)
+		}
+		if err != nil {
+			return err
+		}
+		body := d.bodyRef(el)
+		if body == nil || isCDATABody(*body) || !sourceTagRe.MatchString(*body) {
+			continue
+		}
+		if *body, err = rewriteBodySources(*body, rewrite); err != nil {
+			return err
+		}
+		d.refreshContent(el)
+	}
+	return nil
+}
+
+// rewriteBodySources applies rewrite to the src attributes of source tags in body markup.
+func rewriteBodySources(body string, rewrite func(tag string, src *string) error) (string, error) {
+	var firstErr error
+	out := sourceTagRe.ReplaceAllStringFunc(body, func(start string) string {
+		if firstErr != nil {
+			return start
+		}
+		tag := strings.ToLower(sourceTagRe.FindStringSubmatch(start)[1])
+		return srcAttrRe.ReplaceAllStringFunc(start, func(attr string) string {
+			m := srcAttrRe.FindStringSubmatch(attr)
+			orig := html.UnescapeString(m[2] + m[3])
+			src := orig
+			if err := rewrite(tag, &src); err != nil || src == orig {
+				firstErr = err
+				return attr
+			}
+			var buf bytes.Buffer
+			_ = xml.EscapeText(&buf, []byte(src))
+			return m[1] + `"` + buf.String() + `"`
+		})
+	})
+	return out, firstErr
+}
@@ -0,0 +1,48 @@
+package poml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRewriteSources(t *testing.T) {
+	doc, err := ParseReaderWithOptions(strings.NewReader(`<poml>
+  <img src="a.png"/>
+  <audio src="b.mp3"/>
+  <human-msg>See <img src='a&amp;b.png' alt="x"/> and <IMG alt="y" src="c.png"/></human-msg>
+  <hint><![CDATA[<img src="literal.png"/>]]></hint>
+</poml>`), ParseOptions{PreserveWhitespace: true, ParseInlineContent: true})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var seen []string
+	err = doc.RewriteSources(func(tag, src string) (string, error) {
+		seen = append(seen, tag+":"+src)
+		return "cdn/" + src, nil
+	})
+	if err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if got := strings.Join(seen, ","); got != "img:a.png,audio:b.mp3,img:a&b.png,img:c.png" {
+		t.Fatalf("unexpected sources: %s", got)
+	}
+	if doc.Images[0].Src != "cdn/a.png" || doc.Audios[0].Src != "cdn/b.mp3" {
+		t.Fatalf("top-level sources not rewritten: %+v %+v", doc.Images, doc.Audios)
+	}
+	body := doc.Messages[0].Body
+	if !strings.Contains(body, `src="cdn/a&amp;b.png"`) || !strings.Contains(body, `src="cdn/c.png"`) {
+		t.Fatalf("unexpected body: %s", body)
+	}
+	if content := doc.Messages[0].Content; len(content) < 2 || content[1].Image == nil || content[1].Image.Src != "cdn/a&b.png" {
+		t.Fatalf("inline content not refreshed: %+v", content)
+	}
+	if !strings.Contains(doc.Hints[0].Body, `"literal.png"`) {
+		t.Fatalf("CDATA body should be left alone: %s", doc.Hints[0].Body)
+	}
+
+	boom := errors.New("boom")
+	if err := doc.RewriteSources(func(tag, src string) (string, error) { return "", boom }); !errors.Is(err, boom) {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package poml
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AssignStableIDs replaces counter-based element IDs (el-N) with IDs derived from content, so
+// the same element keeps its ID across parses and unrelated edits:
+//
+//   - an element carrying an id="..." attribute uses that value (prefixed with its type when
+//     another element already claimed it, e.g. a tool-result sharing its tool-request's id);
+//   - elements with a natural key (input name, tool name, document src, diagram id, ...) hash
+//     type and key, e.g. "input-1f3a9c2e";
+//   - everything else hashes type and its position among elements of that type.
+//
+// Remaining collisions get a numeric suffix. Elements inserted later still receive el-N IDs
+// until AssignStableIDs runs again. ParseOptions.StableIDs calls this after parsing.
+func (d *Document) AssignStableIDs() {
+	if len(d.Elements) == 0 {
+		d.Elements = d.defaultElements()
+	}
+	used := make(map[string]bool, len(d.Elements))
+	ordinals := map[ElementType]int{}
+	claim := func(candidates ...string) string {
+		for _, c := range candidates {
+			if c != "" && !used[c] {
+				used[c] = true
+				return c
+			}
+		}
+		base := candidates[len(candidates)-1]
+		for n := 2; ; n++ {
+			if c := base + "-" + strconv.Itoa(n); !used[c] {
+				used[c] = true
+				return c
+			}
+		}
+	}
+	for i := range d.Elements {
+		el := d.Elements[i]
+		ordinals[el.Type]++
+		if id := elementIDAttr(*d, el); id != "" {
+			d.Elements[i].ID = claim(id, string(el.Type)+":"+id)
+			continue
+		}
+		key := diffNaturalKey(*d, el)
+		if key == "" {
+			key = fmt.Sprintf("%s#%d", el.Type, ordinals[el.Type])
+		}
+		d.Elements[i].ID = claim(stableIDFor(el.Type, key))
+	}
+}
+
+// elementIDAttr returns the element's own id="..." attribute, if any.
+func elementIDAttr(d Document, el Element) string {
+	_, attrs := elementTag(d, el)
+	for _, a := range attrs {
+		if a.Name.Local == "id" && a.Name.Space == "" {
+			return strings.TrimSpace(a.Value)
+		}
+	}
+	return ""
+}
+
+func stableIDFor(t ElementType, key string) string {
+	sum := sha1.Sum([]byte(key))
+	return strings.ReplaceAll(string(t), "_", "-") + "-" + hex.EncodeToString(sum[:4])
+}
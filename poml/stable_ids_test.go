@@ -0,0 +1,53 @@
+package poml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStableIDsSurviveEdits(t *testing.T) {
+	opts := ParseOptions{StableIDs: true}
+	before, err := ParseReaderWithOptions(strings.NewReader(`<poml>
+  <task>Summarize</task>
+  <input name="topic">a</input>
+  <hint id="style-guide">Be brief</hint>
+  <tool-request id="t1" name="search" parameters="{}" />
+  <tool-result id="t1" name="search">ok</tool-result>
+</poml>`), opts)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	after, err := ParseReaderWithOptions(strings.NewReader(`<poml>
+  <input name="extra">x</input>
+  <task>Summarize, but edited</task>
+  <hint id="style-guide">Be very brief</hint>
+  <input name="topic">changed body</input>
+</poml>`), opts)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	ids := func(d Document) map[string]string {
+		out := map[string]string{}
+		for _, el := range d.Elements {
+			out[string(el.Type)+"/"+diffNaturalKey(d, el)] = el.ID
+		}
+		return out
+	}
+	a, b := ids(before), ids(after)
+	if a["input/input:topic"] == "" || a["input/input:topic"] != b["input/input:topic"] {
+		t.Fatalf("input ID not stable: %v vs %v", a, b)
+	}
+	if a["task/"] != b["task/"] || !strings.HasPrefix(a["task/"], "task-") {
+		t.Fatalf("task ID not stable: %v vs %v", a, b)
+	}
+	if el, _, ok := after.ElementByID("style-guide"); !ok || el.Type != ElementHint {
+		t.Fatalf("id attribute not honored: %+v", after.Elements)
+	}
+	if a["tool_request/tool_request:t1"] != "t1" || a["tool_result/tool_result:t1"] != "tool_result:t1" {
+		t.Fatalf("shared id attribute not disambiguated: %v", a)
+	}
+	again, _ := ParseReaderWithOptions(strings.NewReader(`<poml><input name="q"/><input name="q"/></poml>`), opts)
+	if again.Elements[0].ID == again.Elements[1].ID || again.Elements[1].ID != again.Elements[0].ID+"-2" {
+		t.Fatalf("expected suffixed duplicate IDs: %+v", again.Elements)
+	}
+}
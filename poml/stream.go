@@ -0,0 +1,129 @@
+package poml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// StreamDecoder yields top-level <poml> children one at a time without materializing a Document.
+// Each call to Next decodes a single element into a scratch Document, so memory stays bounded by
+// the largest element rather than the whole file.
+type StreamDecoder struct {
+	dec     *pomlDecoder
+	opts    ParseOptions
+	started bool
+	done    bool
+	nextID  int
+	lastID  string // ID of the last element returned, for error locations
+	pending string
+	counts  map[ElementType]int
+}
+
+// NewStreamDecoder builds a streaming decoder with default parse options (whitespace preserved).
+func NewStreamDecoder(r io.Reader) *StreamDecoder {
+	return NewStreamDecoderWithOptions(r, defaultParseOptions)
+}
+
+// NewStreamDecoderWithOptions builds a streaming decoder with fidelity controls.
+// Validate is ignored because structural validation requires the full document.
+func NewStreamDecoderWithOptions(r io.Reader, opts ParseOptions) *StreamDecoder {
+	dec := newPOMLDecoder(utf8Input(r))
+	dec.Strict = true
+	return &StreamDecoder{dec: dec, opts: opts, nextID: 1, counts: make(map[ElementType]int)}
+}
+
+// Next returns the next top-level element and its payload. It returns io.EOF after </poml>.
+// Element.Index mirrors the index the element would have in a fully parsed Document; the payload
+// pointers reference a per-element scratch document and remain valid after subsequent calls.
+// Trailing whitespace before </poml> is not reported because no element follows it.
+func (s *StreamDecoder) Next() (Element, ElementPayload, error) {
+	if s.done {
+		return Element{}, ElementPayload{}, io.EOF
+	}
+	if !s.started {
+		if err := s.findRoot(); err != nil {
+			s.done = true
+			return Element{}, ElementPayload{}, err
+		}
+		s.started = true
+	}
+	preserveWS := s.opts.PreserveWhitespace
+	for {
+		s.dec.mark()
+		offset := s.dec.InputOffset()
+		tok, err := s.dec.Token()
+		if err != nil {
+			s.done = true
+			if errors.Is(err, io.EOF) {
+				return Element{}, ElementPayload{}, fmt.Errorf("parse poml: unexpected EOF before </poml>")
+			}
+			return Element{}, ElementPayload{}, s.dec.locate(wrapXMLError(err, "parse poml"), s.lastID)
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			if preserveWS {
+				s.pending += string(t)
+			}
+		case xml.Comment:
+			if preserveWS {
+				s.pending += renderToken(t)
+			}
+		case xml.StartElement:
+			scratch := Document{nextID: s.nextID}
+			el, err := scratch.decodeChild(s.dec, t, offset)
+			if err != nil {
+				s.done = true
+				return Element{}, ElementPayload{}, s.dec.locate(err, s.lastID)
+			}
+			s.nextID = scratch.nextID
+			s.lastID = el.ID
+			if preserveWS {
+				el.Leading = s.pending
+				el.Comment = leadingComment(s.pending)
+				if el.Type != ElementUnknown {
+					el.AttrOrder = attrOrder(t.Attr)
+				}
+			}
+			s.pending = ""
+			payload := scratch.payloadFor(el)
+			if el.Index >= 0 {
+				group := indexGroup(el.Type)
+				el.Index = s.counts[group]
+				s.counts[group]++
+			}
+			return el, payload, nil
+		case xml.EndElement:
+			if t.Name.Local == "poml" {
+				s.done = true
+				return Element{}, ElementPayload{}, io.EOF
+			}
+		}
+	}
+}
+
+// findRoot advances the decoder past the <poml> start tag.
+func (s *StreamDecoder) findRoot() error {
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("parse poml: unexpected EOF (missing <poml> root?)")
+			}
+			return s.dec.locate(wrapXMLError(err, "parse poml"), "")
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "poml" {
+			return &POMLError{
+				Type:    ErrDecode,
+				Code:    CodeDecode,
+				Message: fmt.Sprintf("parse poml: expected <poml> root, got <%s>", start.Name.Local),
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,57 @@
+package poml
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStreamDecoderMatchesParse(t *testing.T) {
+	doc, err := ParseString(sample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	sd := NewStreamDecoder(strings.NewReader(sample))
+	var got []Element
+	for {
+		el, payload, err := sd.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		if el.Type == ElementTask && payload.Task == nil {
+			t.Fatalf("task payload missing for %+v", el)
+		}
+		if el.Type == ElementInput && payload.Input.Name != doc.Inputs[el.Index].Name {
+			t.Fatalf("input payload mismatch at %d: %+v", el.Index, payload.Input)
+		}
+		got = append(got, el)
+	}
+	if len(got) != len(doc.Elements) {
+		t.Fatalf("stream count mismatch: got %d want %d", len(got), len(doc.Elements))
+	}
+	for i, el := range doc.Elements {
+		if got[i].Type != el.Type || got[i].Index != el.Index || got[i].ID != el.ID || got[i].Leading != el.Leading {
+			t.Fatalf("element %d mismatch: got %+v want %+v", i, got[i], el)
+		}
+	}
+	if _, _, err := sd.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected sticky EOF, got %v", err)
+	}
+}
+
+func TestStreamDecoderErrors(t *testing.T) {
+	if _, _, err := NewStreamDecoder(strings.NewReader(`<root/>`)).Next(); err == nil {
+		t.Fatalf("expected error for non-poml root")
+	}
+	sd := NewStreamDecoderWithOptions(strings.NewReader(`<poml><task>a</task>`), fastParseOptions)
+	if el, _, err := sd.Next(); err != nil || el.Type != ElementTask || el.Leading != "" {
+		t.Fatalf("first element: %+v %v", el, err)
+	}
+	if _, _, err := sd.Next(); err == nil || errors.Is(err, io.EOF) {
+		t.Fatalf("expected truncated stream error, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package poml
+
+import (
+	"strings"
+	"text/template"
+)
+
+// SystemPromptOptions controls system prompt synthesis in the chat converters (openai_chat,
+// langchain, ollama, hf_chat). By default those converters emit only explicit messages and
+// content; with Enabled set, <role>, <task>, <input>, <style>, and <output-format> are composed
+// into one system message placed before everything else, as the Python SDK does.
+type SystemPromptOptions struct {
+	Enabled bool
+	// Template is a text/template executed with SystemPromptData (TemplateFuncs are available).
+	// Empty lays the sections out like FormatText, honoring ConvertOptions.Text headers.
+	Template string
+}
+
+// SystemPromptData is the data a SystemPromptOptions.Template receives. Bodies are plain text:
+// entities decoded, markup stripped, and indentation removed.
+type SystemPromptData struct {
+	Role          string
+	Tasks         []string
+	Inputs        []SystemPromptInput
+	Styles        []string
+	OutputFormats []string
+}
+
+// SystemPromptInput is one <input> in SystemPromptData.
+type SystemPromptInput struct {
+	Name     string
+	Required bool
+	Body     string
+}
+
+var systemPromptElements = map[ElementType]bool{
+	ElementRole:         true,
+	ElementTask:         true,
+	ElementInput:        true,
+	ElementStyle:        true,
+	ElementOutputFormat: true,
+}
+
+// systemPrompt returns the synthesized system message, or "" when synthesis is off or the
+// document has none of the sections.
+func systemPrompt(doc Document, opts ConvertOptions) (string, error) {
+	sp := opts.SystemPrompt
+	if !sp.Enabled {
+		return "", nil
+	}
+	if sp.Template == "" {
+		return textSections(doc, opts, systemPromptElements)
+	}
+	tmpl, err := template.New("system").Funcs(TemplateFuncs()).Parse(sp.Template)
+	if err != nil {
+		return "", &POMLError{Type: ErrTemplate, Code: CodeTemplate, Message: "system prompt template", Err: err}
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, systemPromptData(doc)); err != nil {
+		return "", &POMLError{Type: ErrTemplate, Code: CodeTemplate, Message: "system prompt template", Err: err}
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func systemPromptData(doc Document) SystemPromptData {
+	text := func(body string) string { return dedentText(plainText(body)) }
+	data := SystemPromptData{Role: text(doc.Role.Body)}
+	for _, el := range doc.resolveOrder() {
+		switch el.Type {
+		case ElementTask:
+			data.Tasks = append(data.Tasks, text(doc.Tasks[el.Index].Body))
+		case ElementInput:
+			in := doc.Inputs[el.Index]
+			data.Inputs = append(data.Inputs, SystemPromptInput{Name: in.Name, Required: in.Required, Body: text(in.Body)})
+		case ElementStyle:
+			for _, out := range doc.Styles[el.Index].Outputs {
+				data.Styles = append(data.Styles, text(out.Body))
+			}
+		case ElementOutputFormat:
+			data.OutputFormats = append(data.OutputFormats, text(doc.OutFormats[el.Index].Body))
+		}
+	}
+	return data
+}
@@ -0,0 +1,73 @@
+package poml
+
+import (
+	"errors"
+	"testing"
+)
+
+const systemPromptSample = `<poml>
+  <role>You are a careful &amp; concise analyst.</role>
+  <task>
+    Summarize the report.
+    Flag risks.
+  </task>
+  <input name="audience" required="true">executives</input>
+  <output-format>Three bullet points.</output-format>
+  <human-msg>Here is the report.</human-msg>
+</poml>`
+
+func TestSystemPromptSynthesis(t *testing.T) {
+	doc, err := ParseString(systemPromptSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out, err := Convert(doc, FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if msgs := out.(map[string]any)["messages"].([]map[string]any); len(msgs) != 1 || msgs[0]["role"] != "user" {
+		t.Fatalf("synthesis should be opt-in: %+v", msgs)
+	}
+
+	opts := ConvertOptions{SystemPrompt: SystemPromptOptions{Enabled: true}}
+	out, err = Convert(doc, FormatOpenAIChat, opts)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	msgs := out.(map[string]any)["messages"].([]map[string]any)
+	want := "Role:\nYou are a careful & concise analyst.\n\nTask:\nSummarize the report.\nFlag risks.\n\nInput (audience):\nexecutives\n\nOutput format:\nThree bullet points."
+	if len(msgs) != 2 || msgs[0]["role"] != "system" || msgs[0]["content"] != want {
+		t.Fatalf("system message mismatch: %+v", msgs)
+	}
+	for _, format := range []Format{FormatOllama, FormatHFChat} {
+		out, err := Convert(doc, format, opts)
+		if err != nil {
+			t.Fatalf("convert %s: %v", format, err)
+		}
+		var first map[string]any
+		switch v := out.(type) {
+		case map[string]any:
+			first = v["messages"].([]map[string]any)[0]
+		case []map[string]any:
+			first = v[0]
+		}
+		if first["role"] != "system" || first["content"] != want {
+			t.Fatalf("%s system message mismatch: %+v", format, first)
+		}
+	}
+
+	opts.SystemPrompt.Template = `{{ .Role }} Audience:{{ range .Inputs }} {{ .Name }}={{ .Body }}{{ end }}. {{ join " " .Tasks }}`
+	out, err = Convert(doc, FormatLangChain, opts)
+	if err != nil {
+		t.Fatalf("convert langchain: %v", err)
+	}
+	first := out.(map[string]any)["messages"].([]map[string]any)[0]
+	if first["type"] != "system" || first["data"].(map[string]any)["content"] != "You are a careful & concise analyst. Audience: audience=executives. Summarize the report.\nFlag risks." {
+		t.Fatalf("templated system message mismatch: %+v", first)
+	}
+
+	opts.SystemPrompt.Template = "{{ .Missing }}"
+	if _, err := Convert(doc, FormatOpenAIChat, opts); !errors.Is(err, CodeTemplate) {
+		t.Fatalf("expected template error, got %v", err)
+	}
+}
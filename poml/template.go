@@ -0,0 +1,298 @@
+package poml
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RawXML is trusted markup that ParseTemplate inserts without escaping; the raw helper converts
+// a string to it. Only use it for values that are already well-formed POML.
+type RawXML string
+
+// TemplateOptions configures ParseTemplateWithOptions and RenderTemplate.
+type TemplateOptions struct {
+	// Parse controls how the rendered POML is parsed.
+	Parse ParseOptions
+	// LeftDelim and RightDelim replace the default "{{" and "}}". Change them when the source also
+	// contains POML's own {{ ... }} expressions (e.g. tool-request parameters).
+	LeftDelim, RightDelim string
+	// Funcs are added to (and override) TemplateFuncs.
+	Funcs template.FuncMap
+}
+
+const (
+	templateEscapeFunc = "_poml_escape"
+	templateCheckFunc  = "_poml_check"
+)
+
+var xmlTemplateEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&#34;", "'", "&#39;")
+
+// ParseTemplate runs src through text/template with data and parses the result as POML. Every
+// value an action prints is XML-escaped, so data containing <, &, or quotes cannot break the
+// document whether it lands in element text or an attribute; wrap trusted markup with raw (or pass
+// a RawXML) to insert it verbatim. Missing values render as the empty string. TemplateFuncs lists
+// the available helpers.
+func ParseTemplate(src string, data any) (Document, error) {
+	return ParseTemplateWithOptions(src, data, TemplateOptions{Parse: defaultParseOptions})
+}
+
+// ParseTemplateWithOptions is ParseTemplate with custom delimiters, helpers, and parse options.
+func ParseTemplateWithOptions(src string, data any, opts TemplateOptions) (Document, error) {
+	rendered, err := RenderTemplate(src, data, opts)
+	if err != nil {
+		return Document{}, err
+	}
+	return parseWithOptions(strings.NewReader(rendered), opts.Parse)
+}
+
+// RenderTemplate returns the escaped POML source ParseTemplateWithOptions would parse. Of
+// opts.Parse only MaxDocumentBytes applies: rendering stops with a POML-LIMIT error once the output
+// passes it, so a loop over large data cannot grow the result without bound. Other template
+// failures are POMLErrors with code POML-TEMPLATE.
+func RenderTemplate(src string, data any, opts TemplateOptions) (string, error) {
+	return RenderTemplateContext(context.Background(), src, data, opts)
+}
+
+// RenderTemplateContext is RenderTemplate that stops once ctx is done: every range iteration and
+// template call checks ctx, so a loop that prints nothing still ends, with a POML-TEMPLATE error
+// wrapping ctx.Err().
+func RenderTemplateContext(ctx context.Context, src string, data any, opts TemplateOptions) (string, error) {
+	funcs := TemplateFuncs()
+	for name, fn := range opts.Funcs {
+		funcs[name] = fn
+	}
+	funcs[templateEscapeFunc] = templateEscape
+	funcs[templateCheckFunc] = func() (string, error) { return "", ctx.Err() }
+	tmpl, err := template.New("poml").Delims(opts.LeftDelim, opts.RightDelim).Funcs(funcs).Parse(src)
+	if err != nil {
+		return "", templateError(err)
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			escapeTemplateList(t.Tree.Root)
+			if ctx.Done() != nil {
+				checkTemplateList(t.Tree.Root)
+			}
+		}
+	}
+	out := &cappedWriter{max: opts.Parse.MaxDocumentBytes}
+	if err := tmpl.Execute(out, data); err != nil {
+		var le *LimitError
+		if errors.As(err, &le) {
+			return "", &POMLError{Type: ErrTemplate, Code: CodeLimit, Message: "render template", Err: le}
+		}
+		return "", templateError(err)
+	}
+	return out.buf.String(), nil
+}
+
+// cappedWriter collects template output, failing once it would pass max bytes (when positive).
+type cappedWriter struct {
+	buf strings.Builder
+	max int64
+}
+
+func (w *cappedWriter) Write(p []byte) (int, error) {
+	if w.max > 0 && int64(w.buf.Len()+len(p)) > w.max {
+		return 0, &LimitError{Limit: "MaxDocumentBytes", Max: w.max, Offset: int64(w.buf.Len())}
+	}
+	return w.buf.Write(p)
+}
+
+func templateError(err error) error {
+	return &POMLError{Type: ErrTemplate, Code: CodeTemplate, Message: "render template", Err: err}
+}
+
+// escapeTemplateList appends the escaper to every printing action, as html/template does, so
+// escaping cannot be forgotten in a pipeline.
+func escapeTemplateList(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.ActionNode:
+			if len(n.Pipe.Decl) == 0 {
+				n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+					NodeType: parse.NodeCommand,
+					Pos:      n.Pos,
+					Args:     []parse.Node{parse.NewIdentifier(templateEscapeFunc).SetPos(n.Pos)},
+				})
+			}
+		case *parse.IfNode:
+			escapeTemplateList(n.List)
+			escapeTemplateList(n.ElseList)
+		case *parse.RangeNode:
+			escapeTemplateList(n.List)
+			escapeTemplateList(n.ElseList)
+		case *parse.WithNode:
+			escapeTemplateList(n.List)
+			escapeTemplateList(n.ElseList)
+		}
+	}
+}
+
+// checkTemplateList puts a context check at the start of list, a template body, and of every
+// range body inside it: the only places a template can loop.
+func checkTemplateList(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	findTemplateRanges(list)
+	check := &parse.ActionNode{
+		NodeType: parse.NodeAction,
+		Pos:      list.Pos,
+		Pipe: &parse.PipeNode{NodeType: parse.NodePipe, Pos: list.Pos, Cmds: []*parse.CommandNode{{
+			NodeType: parse.NodeCommand,
+			Pos:      list.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(templateCheckFunc).SetPos(list.Pos)},
+		}}},
+	}
+	list.Nodes = append([]parse.Node{check}, list.Nodes...)
+}
+
+func findTemplateRanges(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.IfNode:
+			findTemplateRanges(n.List)
+			findTemplateRanges(n.ElseList)
+		case *parse.RangeNode:
+			checkTemplateList(n.List)
+			findTemplateRanges(n.ElseList)
+		case *parse.WithNode:
+			findTemplateRanges(n.List)
+			findTemplateRanges(n.ElseList)
+		}
+	}
+}
+
+func templateEscape(v any) RawXML {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case RawXML:
+		return v
+	}
+	return RawXML(xmlTemplateEscaper.Replace(fmt.Sprint(v)))
+}
+
+// TemplateFuncs returns the helpers ParseTemplate provides. Names and argument order follow sprig
+// so pipelines such as {{ .Name | default "anon" | upper }} port unchanged:
+//
+//	raw, xmlEscape                          trusted markup / explicit escaping
+//	default, empty, coalesce, ternary       fallbacks
+//	upper, lower, title, trim, trimPrefix, trimSuffix, replace, repeat, indent, nindent, quote, squote
+//	contains, hasPrefix, hasSuffix          string tests
+//	splitList, join, list, dict             collections
+//	toJson, toPrettyJson                    JSON encoding
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"raw":       func(s string) RawXML { return RawXML(s) },
+		"xmlEscape": func(v any) RawXML { return templateEscape(v) },
+		"default": func(dflt, v any) any {
+			if templateEmpty(v) {
+				return dflt
+			}
+			return v
+		},
+		"empty": templateEmpty,
+		"coalesce": func(vals ...any) any {
+			for _, v := range vals {
+				if !templateEmpty(v) {
+					return v
+				}
+			}
+			return nil
+		},
+		"ternary": func(a, b any, cond bool) any {
+			if cond {
+				return a
+			}
+			return b
+		},
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"title": func(s string) string {
+			words := strings.Fields(s)
+			for i, w := range words {
+				r, size := utf8.DecodeRuneInString(w)
+				words[i] = string(unicode.ToTitle(r)) + w[size:]
+			}
+			return strings.Join(words, " ")
+		},
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"repeat":     func(n int, s string) string { return strings.Repeat(s, max(n, 0)) },
+		"indent":     templateIndent,
+		"nindent":    func(n int, s string) string { return "\n" + templateIndent(n, s) },
+		"quote":      func(v any) string { return fmt.Sprintf("%q", fmt.Sprint(v)) },
+		"squote":     func(v any) string { return "'" + fmt.Sprint(v) + "'" },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"splitList":  func(sep, s string) []string { return strings.Split(s, sep) },
+		"join": func(sep string, v any) string {
+			rv := reflect.ValueOf(v)
+			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+				return fmt.Sprint(v)
+			}
+			parts := make([]string, rv.Len())
+			for i := range parts {
+				parts[i] = fmt.Sprint(rv.Index(i).Interface())
+			}
+			return strings.Join(parts, sep)
+		},
+		"list": func(vals ...any) []any { return vals },
+		"dict": func(kv ...any) (map[string]any, error) {
+			if len(kv)%2 != 0 {
+				return nil, fmt.Errorf("dict expects key/value pairs, got %d arguments", len(kv))
+			}
+			m := make(map[string]any, len(kv)/2)
+			for i := 0; i < len(kv); i += 2 {
+				m[fmt.Sprint(kv[i])] = kv[i+1]
+			}
+			return m, nil
+		},
+		"toJson": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"toPrettyJson": func(v any) (string, error) {
+			data, err := json.MarshalIndent(v, "", "  ")
+			return string(data), err
+		},
+	}
+}
+
+func templateEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+func templateIndent(n int, s string) string {
+	pad := strings.Repeat(" ", max(n, 0))
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
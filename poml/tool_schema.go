@@ -0,0 +1,175 @@
+package poml
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ToolDefinitionFromStruct builds a <tool-definition> whose body is the JSON Schema of v, a
+// struct or pointer to one, so the schema a model sees follows the Go type its handler decodes.
+// Properties are the fields encoding/json would marshal, named and ordered as it does (embedded
+// structs are flattened, `json:"-"` fields skipped); a field is required when tagged
+// `required:"true"`, and a `description:"..."` tag becomes its description. Nested structs,
+// slices, arrays, and string-keyed maps are described recursively, time.Time as a date-time
+// string, and []byte as a base64 string; interfaces and types with their own JSON encoding
+// accept any value. A struct type reached again inside itself is described as a bare object.
+func ToolDefinitionFromStruct(name, description string, v any) (ToolDefinition, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ToolDefinition{}, fmt.Errorf("tool definition %s: want a struct, got %T", name, v)
+	}
+	g := toolSchemaGen{active: map[reflect.Type]bool{}}
+	body, err := json.Marshal(g.schema(t))
+	if err != nil {
+		return ToolDefinition{}, fmt.Errorf("tool definition %s: %w", name, err)
+	}
+	return ToolDefinition{Name: name, Description: description, Body: string(body)}, nil
+}
+
+// toolSchema is the JSON Schema subset ToolDefinitionFromStruct emits. json.Marshal escapes
+// <, >, and &, so the encoded schema is safe as a <tool-definition> body.
+type toolSchema struct {
+	Type                 string          `json:"type,omitempty"`
+	Description          string          `json:"description,omitempty"`
+	Format               string          `json:"format,omitempty"`
+	ContentEncoding      string          `json:"contentEncoding,omitempty"`
+	Properties           toolSchemaProps `json:"properties,omitempty"`
+	Required             []string        `json:"required,omitempty"`
+	Items                *toolSchema     `json:"items,omitempty"`
+	AdditionalProperties *toolSchema     `json:"additionalProperties,omitempty"`
+}
+
+// toolSchemaProps keeps properties in field order, which map[string] would sort.
+type toolSchemaProps []toolSchemaProp
+
+type toolSchemaProp struct {
+	name   string
+	schema *toolSchema
+}
+
+// MarshalJSON writes the properties as an object in declaration order.
+func (p toolSchemaProps) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, prop := range p {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(prop.name)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(prop.schema)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+type toolSchemaGen struct {
+	active map[reflect.Type]bool // struct types being described, to stop on recursive types
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (g toolSchemaGen) schema(t reflect.Type) *toolSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &toolSchema{Type: "string", Format: "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return &toolSchema{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &toolSchema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &toolSchema{Type: "string"}
+	case reflect.Bool:
+		return &toolSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &toolSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &toolSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &toolSchema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &toolSchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return &toolSchema{Type: "object"}
+		}
+		return &toolSchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if g.active[t] {
+			return &toolSchema{Type: "object"}
+		}
+		g.active[t] = true
+		defer delete(g.active, t)
+		s := &toolSchema{Type: "object", Properties: toolSchemaProps{}}
+		g.fields(t, s)
+		return s
+	}
+	return &toolSchema{}
+}
+
+// fields adds the JSON-visible fields of struct t to s, flattening untagged embedded structs.
+func (g toolSchemaGen) fields(t reflect.Type, s *toolSchema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, s)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop := g.schema(f.Type)
+		if strings.Contains(","+opts+",", ",string,") {
+			switch prop.Type {
+			case "integer", "number", "boolean":
+				prop = &toolSchema{Type: "string"}
+			}
+		}
+		prop.Description = f.Tag.Get("description")
+		s.Properties = append(s.Properties, toolSchemaProp{name: name, schema: prop})
+		if f.Tag.Get("required") == "true" {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
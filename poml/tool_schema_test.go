@@ -0,0 +1,70 @@
+package poml
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type weatherArgs struct {
+	City    string         `json:"city" required:"true" description:"City name, e.g. Oslo & area"`
+	Days    int            `json:"days,omitempty" description:"Forecast length"`
+	Units   *string        `json:"units,omitempty"`
+	Since   time.Time      `json:"since"`
+	Tags    []string       `json:"tags"`
+	Labels  map[string]int `json:"labels"`
+	Where   *geoPoint      `json:"where" required:"true"`
+	Raw     []byte         `json:"raw"`
+	Count   int64          `json:"count,string"`
+	Skip    string         `json:"-"`
+	private string
+	Extra   map[string]string `json:"extra,omitempty"`
+	geoMeta
+}
+
+type geoPoint struct {
+	Lat  float64   `json:"lat" required:"true"`
+	Lon  float64   `json:"lon" required:"true"`
+	Near *geoPoint `json:"near,omitempty"`
+}
+
+type geoMeta struct {
+	Source string `json:"source"`
+}
+
+func TestToolDefinitionFromStruct(t *testing.T) {
+	td, err := ToolDefinitionFromStruct("weather", "Look up the forecast.", &weatherArgs{})
+	if err != nil {
+		t.Fatalf("from struct: %v", err)
+	}
+	want := `{"type":"object","properties":{` +
+		`"city":{"type":"string","description":"City name, e.g. Oslo \u0026 area"},` +
+		`"days":{"type":"integer","description":"Forecast length"},` +
+		`"units":{"type":"string"},` +
+		`"since":{"type":"string","format":"date-time"},` +
+		`"tags":{"type":"array","items":{"type":"string"}},` +
+		`"labels":{"type":"object","additionalProperties":{"type":"integer"}},` +
+		`"where":{"type":"object","properties":{"lat":{"type":"number"},"lon":{"type":"number"},"near":{"type":"object"}},"required":["lat","lon"]},` +
+		`"raw":{"type":"string","contentEncoding":"base64"},` +
+		`"count":{"type":"string"},` +
+		`"extra":{"type":"object","additionalProperties":{"type":"string"}},` +
+		`"source":{"type":"string"}},` +
+		`"required":["city","where"]}`
+	if td.Body != want {
+		t.Fatalf("schema:\n%s\nwant:\n%s", td.Body, want)
+	}
+	if td.Name != "weather" || td.Description != "Look up the forecast." {
+		t.Fatalf("definition = %+v", td)
+	}
+
+	doc, err := ParseString(encodeString(t, NewBuilder().ToolDefinition(td.Name, td.Description, td.Body).Build(), EncodeOptions{}))
+	if err != nil {
+		t.Fatalf("the schema body should stay XML-safe: %v", err)
+	}
+	if err := doc.Validate(); err != nil && strings.Contains(err.Error(), "tool-definition") {
+		t.Fatalf("generated body should pass tool-definition validation: %v", err)
+	}
+	if _, err := ToolDefinitionFromStruct("bad", "", 3); err == nil {
+		t.Fatalf("a non-struct should fail")
+	}
+}
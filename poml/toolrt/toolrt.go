@@ -0,0 +1,148 @@
+// Package toolrt executes the tool calls recorded in a POML document, turning a conversation
+// with <tool-request> elements into an executable agent trace.
+//
+// Handlers are registered by tool name; Dispatch runs the handler of every request that has no
+// <tool-response>, <tool-result>, or <tool-error> with its id yet, and appends the outcome with
+// the same id and name:
+//
+//	rt := toolrt.New()
+//	rt.Register("weather", toolrt.Func(func(ctx context.Context, args WeatherArgs) (Forecast, error) {
+//		return lookup(ctx, args.City)
+//	}))
+//	doc, err = rt.Dispatch(ctx, doc)
+//
+// A handler's value becomes a <tool-result>: strings are written as they are, json.RawMessage
+// and []byte as their text, and anything else as JSON. A handler error, a panic, parameters that
+// are not JSON, or a tool with no handler becomes a <tool-error> carrying the message, so the
+// model can see what went wrong on its next turn.
+package toolrt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+// Handler runs one tool call. args is the request's parameters as JSON ({} when empty).
+type Handler func(ctx context.Context, args json.RawMessage) (any, error)
+
+// Func adapts a typed function to a Handler, decoding the arguments into A. Pair it with
+// poml.ToolDefinitionFromStruct on the same A so the advertised schema matches what is decoded.
+func Func[A, R any](fn func(ctx context.Context, args A) (R, error)) Handler {
+	return func(ctx context.Context, raw json.RawMessage) (any, error) {
+		var args A
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return nil, fmt.Errorf("decode arguments: %w", err)
+		}
+		return fn(ctx, args)
+	}
+}
+
+// Runtime maps tool names to handlers. Register before calling Dispatch; a Runtime is not safe
+// for concurrent registration.
+type Runtime struct {
+	handlers map[string]Handler
+}
+
+// New returns an empty Runtime.
+func New() *Runtime {
+	return &Runtime{handlers: map[string]Handler{}}
+}
+
+// Register sets the handler for tool name, replacing any earlier one.
+func (r *Runtime) Register(name string, h Handler) {
+	r.handlers[name] = h
+}
+
+// Unresolved returns the tool requests in doc, in document order, that have an id and no
+// <tool-response>, <tool-result>, or <tool-error> with that id. Requests without an id are
+// never unresolved, since no outcome could be matched to them.
+func Unresolved(doc poml.Document) []poml.ToolRequest {
+	done := map[string]bool{}
+	for _, tr := range doc.ToolResps {
+		done[tr.ID] = true
+	}
+	for _, tr := range doc.ToolResults {
+		done[tr.ID] = true
+	}
+	for _, te := range doc.ToolErrors {
+		done[te.ID] = true
+	}
+	var out []poml.ToolRequest
+	for _, el := range doc.Elements {
+		if el.Type != poml.ElementToolRequest || el.Index < 0 || el.Index >= len(doc.ToolReqs) {
+			continue
+		}
+		tr := doc.ToolReqs[el.Index]
+		if tr.ID == "" || done[tr.ID] {
+			continue
+		}
+		done[tr.ID] = true // a repeated id is dispatched once
+		out = append(out, tr)
+	}
+	return out
+}
+
+// Dispatch runs the handlers for doc's unresolved requests one at a time, in document order, and
+// returns a copy of doc with a <tool-result> or <tool-error> appended for each; doc itself is not
+// modified. It stops with ctx's error, returning nothing, if ctx is done before a call starts.
+func (r *Runtime) Dispatch(ctx context.Context, doc poml.Document) (poml.Document, error) {
+	pending := Unresolved(doc)
+	out := doc.Clone()
+	for _, tr := range pending {
+		if err := ctx.Err(); err != nil {
+			return poml.Document{}, err
+		}
+		body, err := r.call(ctx, tr)
+		if err != nil {
+			out.AddToolError(tr.ID, tr.Name, escapeText(err.Error()))
+			continue
+		}
+		out.AddToolResult(tr.ID, tr.Name, escapeText(body))
+	}
+	return out, nil
+}
+
+// call runs tr's handler and renders its value as result text.
+func (r *Runtime) call(ctx context.Context, tr poml.ToolRequest) (body string, err error) {
+	h, ok := r.handlers[tr.Name]
+	if !ok {
+		return "", fmt.Errorf("no handler registered for tool %q", tr.Name)
+	}
+	args, err := tr.Arguments()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("tool %s panicked: %v", tr.Name, p)
+		}
+	}()
+	v, err := h(ctx, args)
+	if err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.RawMessage:
+		return string(v), nil
+	case []byte:
+		return string(v), nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("encode result of %s: %w", tr.Name, err)
+	}
+	return string(b), nil
+}
+
+var bodyTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escapeText makes plain text safe as a body, which is written to the document as inner XML.
+func escapeText(s string) string {
+	return bodyTextEscaper.Replace(s)
+}
@@ -0,0 +1,104 @@
+package toolrt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/atlas-foundry/poml-go-sdk/poml"
+)
+
+type addArgs struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+func TestDispatchAppendsResultsAndErrors(t *testing.T) {
+	doc, err := poml.ParseString(`<poml>
+  <human-msg>Add things.</human-msg>
+  <tool-request id="c0" name="add" parameters='{"a": 0, "b": 0}'/>
+  <tool-result id="c0" name="add">0</tool-result>
+  <tool-request id="c1" name="add" parameters="{{ {a: 2, b: 3} }}"/>
+  <tool-request id="c2" name="echo" parameters='{"text": "a &lt; b"}'/>
+  <tool-request id="c3" name="fail"/>
+  <tool-request id="c4" name="boom"/>
+  <tool-request id="c5" name="missing"/>
+  <tool-request name="add" parameters='{"a": 1, "b": 1}'/>
+</poml>`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	calls := 0
+	rt := New()
+	rt.Register("add", Func(func(_ context.Context, args addArgs) (map[string]int, error) {
+		calls++
+		return map[string]int{"sum": args.A + args.B}, nil
+	}))
+	rt.Register("echo", func(_ context.Context, args json.RawMessage) (any, error) {
+		var in struct{ Text string }
+		err := json.Unmarshal(args, &in)
+		return in.Text, err
+	})
+	rt.Register("fail", func(context.Context, json.RawMessage) (any, error) { return nil, errors.New("service unavailable") })
+	rt.Register("boom", func(context.Context, json.RawMessage) (any, error) { panic("nil map") })
+
+	out, err := rt.Dispatch(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("add should run only for the unresolved c1, ran %d times", calls)
+	}
+	if len(doc.ToolResults) != 1 || len(doc.ToolErrors) != 0 {
+		t.Fatalf("input document was modified: %+v %+v", doc.ToolResults, doc.ToolErrors)
+	}
+	if len(Unresolved(out)) != 0 {
+		t.Fatalf("requests left unresolved: %+v", Unresolved(out))
+	}
+
+	// Round-trip through XML so bodies are checked as a reader of the trace sees them.
+	var buf strings.Builder
+	if err := out.Encode(&buf); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	back, err := poml.ParseString(buf.String())
+	if err != nil {
+		t.Fatalf("reparse: %v\n%s", err, buf.String())
+	}
+	results := map[string]string{}
+	for _, r := range back.ToolResults {
+		results[r.ID] = r.Body
+	}
+	if results["c1"] != `{"sum":5}` || results["c2"] != "a &lt; b" {
+		t.Fatalf("unexpected results: %v", results)
+	}
+	errs := map[string]string{}
+	for _, e := range back.ToolErrors {
+		errs[e.ID] = e.Body
+	}
+	if errs["c3"] != "service unavailable" || !strings.Contains(errs["c4"], "boom panicked: nil map") ||
+		!strings.Contains(errs["c5"], `no handler registered for tool "missing"`) {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	last := back.Elements[len(back.Elements)-1]
+	if last.Type != poml.ElementToolError || back.ToolErrors[last.Index].ID != "c5" {
+		t.Fatalf("outcomes should be appended in request order, last is %+v", last)
+	}
+}
+
+func TestDispatchStopsOnCanceledContext(t *testing.T) {
+	doc := poml.Document{}
+	doc.AddToolRequest("c1", "add", `{"a":1,"b":2}`)
+	rt := New()
+	rt.Register("add", Func(func(context.Context, addArgs) (int, error) {
+		t.Fatal("handler ran after cancellation")
+		return 0, nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := rt.Dispatch(ctx, doc); !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package poml
+
+import "strings"
+
+// TraceMetadata returns the metadata converters attach to requests for a document with
+// <trace> elements, so prompt identity and experiment labels reach observability tools:
+//
+//	<trace experiment="tone-ab" dataset="support-golden" tags="billing,v2"/>
+//
+// The map starts with prompt_id and prompt_version from <meta>, then adds every trace
+// attribute under the same snake_case key runtime attributes get (experimentName becomes
+// experiment_name); later traces override earlier ones. Values stay strings, as OpenAI's
+// metadata requires. It returns nil when the document has no <trace>, so outputs of documents
+// without one are unchanged.
+//
+// openai_chat, dict, and pydantic emit it as "metadata", langchain as a top-level "metadata"
+// key, and bedrock_converse as "requestMetadata". mistral, ollama, cohere, hf_chat, text, and
+// message_dict have no metadata field and omit it.
+func (d Document) TraceMetadata() map[string]string {
+	if len(d.Traces) == 0 {
+		return nil
+	}
+	md := map[string]string{}
+	if id := strings.TrimSpace(d.Meta.ID); id != "" {
+		md["prompt_id"] = id
+	}
+	if v := strings.TrimSpace(d.Meta.Version); v != "" {
+		md["prompt_version"] = v
+	}
+	for _, tr := range d.Traces {
+		for _, a := range tr.Attrs {
+			md[normalizeRuntimeKey(a.Name.Local)] = a.Value
+		}
+	}
+	if len(md) == 0 {
+		return nil
+	}
+	return md
+}
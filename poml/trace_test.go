@@ -0,0 +1,75 @@
+package poml
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const traceSample = `<poml>
+  <meta><id>support.reply</id><version>3</version><owner>me</owner></meta>
+  <trace experimentName="tone-ab" dataset="support-golden"/>
+  <task>Reply to the ticket.</task>
+  <trace tags="billing,v2" prompt_version="3b"/>
+</poml>`
+
+func TestTraceMetadata(t *testing.T) {
+	doc, err := ParseString(traceSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := map[string]string{"prompt_id": "support.reply", "prompt_version": "3b", "experiment_name": "tone-ab", "dataset": "support-golden", "tags": "billing,v2"}
+	if got := doc.TraceMetadata(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected metadata: %v", got)
+	}
+
+	var buf bytes.Buffer
+	if err := doc.EncodeWithOptions(&buf, EncodeOptions{PreserveOrder: true}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if !strings.Contains(buf.String(), `<trace experimentName="tone-ab" dataset="support-golden"></trace>`) {
+		t.Fatalf("trace not encoded: %s", buf.String())
+	}
+	round, err := ParseString(buf.String())
+	if err != nil || !reflect.DeepEqual(round.TraceMetadata(), want) {
+		t.Fatalf("trace lost in round-trip: %v %v", round.TraceMetadata(), err)
+	}
+
+	for format, key := range map[Format]string{FormatOpenAIChat: "metadata", FormatLangChain: "metadata", FormatBedrockConverse: "requestMetadata"} {
+		out, err := Convert(doc, format, ConvertOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if got := out.(map[string]any)[key]; !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: unexpected %s: %v", format, key, got)
+		}
+	}
+	typed, err := ConvertTypedOpenAIChat(doc, ConvertOptions{})
+	if err != nil || !reflect.DeepEqual(typed.Metadata, want) || typed.Params["metadata"] != nil {
+		t.Fatalf("typed openai metadata: %+v %v", typed, err)
+	}
+	dict, err := ConvertTypedDict(doc, ConvertOptions{})
+	if err != nil || !reflect.DeepEqual(dict.Metadata, want) {
+		t.Fatalf("dict metadata: %+v %v", dict.Metadata, err)
+	}
+	out, err := Convert(doc, FormatMistral, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("mistral: %v", err)
+	}
+	if _, ok := out.(map[string]any)["metadata"]; ok {
+		t.Fatalf("mistral should not carry metadata")
+	}
+
+	plain, err := Convert(NewBuilder().Task("t").Build(), FormatOpenAIChat, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convert without trace: %v", err)
+	}
+	if _, ok := plain.(map[string]any)["metadata"]; ok {
+		t.Fatalf("documents without <trace> should not get metadata")
+	}
+	built := NewBuilder().Meta("p", "1", "me").Trace(map[string]string{"run": "r1"}).Build()
+	if got := built.TraceMetadata(); !reflect.DeepEqual(got, map[string]string{"prompt_id": "p", "prompt_version": "1", "run": "r1"}) {
+		t.Fatalf("builder trace: %v", got)
+	}
+}
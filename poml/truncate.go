@@ -0,0 +1,241 @@
+package poml
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TokenBudget is the size a prompt has to fit in.
+type TokenBudget struct {
+	MaxTokens int
+	// Tokenizer counts the tokens in a piece of text; nil uses EstimateTokens. Plug in the
+	// target model's tokenizer for exact results.
+	Tokenizer func(text string) int
+}
+
+// EstimateTokens approximates a BPE tokenizer at one token per four bytes, rounding up.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// Tokens counts doc against the budget: the sum of each element's serialized XML, so markup
+// and attributes count along with text.
+func (b TokenBudget) Tokens(doc Document) int {
+	total := 0
+	for _, el := range doc.resolveOrder() {
+		total += b.count(doc, el)
+	}
+	return total
+}
+
+func (b TokenBudget) count(doc Document, el Element) int {
+	tokenize := b.Tokenizer
+	if tokenize == nil {
+		tokenize = EstimateTokens
+	}
+	return tokenize(elementXML(doc, el))
+}
+
+// TruncateStep is one way Truncate shrinks a document.
+type TruncateStep string
+
+const (
+	// TruncateDropHints removes hints, last first.
+	TruncateDropHints TruncateStep = "drop-hints"
+	// TruncateSummarizeExamples shortens example bodies with TruncateStrategy.Summarize, last
+	// example first. Paired examples have their input and output shortened separately; other
+	// examples whose body holds markup are left for TruncateDropExamples.
+	TruncateSummarizeExamples TruncateStep = "summarize-examples"
+	// TruncateDropExamples removes examples, last first.
+	TruncateDropExamples TruncateStep = "drop-examples"
+	// TruncateOldestMessages removes conversation turns (human and assistant messages, tool
+	// requests and their responses) oldest first. System messages are kept, as are the
+	// TruncateStrategy.KeepMessages most recent turns; a tool request and every response
+	// carrying its ID are removed together.
+	TruncateOldestMessages TruncateStep = "oldest-messages"
+)
+
+// TruncateStrategy lists the steps Truncate may take, in order.
+type TruncateStrategy struct {
+	Steps []TruncateStep
+	// Summarize shortens an example body (XML-escaped text, as stored in Example.Body); nil
+	// keeps the first sentence or line.
+	Summarize func(body string) string
+	// KeepMessages is how many of the latest conversation turns TruncateOldestMessages never
+	// removes; zero keeps one, negative keeps none.
+	KeepMessages int
+}
+
+// DefaultTruncateStrategy drops hints, then summarizes and drops examples, then drops the
+// oldest conversation turns.
+var DefaultTruncateStrategy = TruncateStrategy{Steps: []TruncateStep{
+	TruncateDropHints,
+	TruncateSummarizeExamples,
+	TruncateDropExamples,
+	TruncateOldestMessages,
+}}
+
+// Truncate returns a copy of doc shrunk to fit budget by applying strategy's steps in order.
+// Each step changes one element at a time and stops as soon as the document fits, so the
+// result degrades as little as the strategy allows and is the same on every run. Meta, role,
+// task, inputs, schemas, tool definitions, documents, media, and system messages are never
+// touched. When the steps run out before the document fits, the smallest document reached is
+// returned with a POMLError carrying CodeBudget.
+func Truncate(doc Document, budget TokenBudget, strategy TruncateStrategy) (Document, error) {
+	out := doc.Clone()
+	out.Elements = out.resolveOrder()
+	t := truncator{doc: &out, budget: budget, strategy: strategy}
+	t.sizes = make(map[string]int, len(out.Elements))
+	for _, el := range out.Elements {
+		size := budget.count(out, el)
+		t.sizes[el.ID] = size
+		t.total += size
+	}
+	for _, step := range strategy.Steps {
+		if t.fits() {
+			break
+		}
+		switch step {
+		case TruncateDropHints:
+			t.dropLastFirst(ElementHint)
+		case TruncateSummarizeExamples:
+			t.summarizeExamples()
+		case TruncateDropExamples:
+			t.dropLastFirst(ElementExample)
+		case TruncateOldestMessages:
+			t.dropOldestMessages()
+		default:
+			return doc, &POMLError{Type: ErrValidate, Code: CodeBudget, Message: fmt.Sprintf("truncate: unknown step %q", step)}
+		}
+	}
+	out.reindex()
+	if !t.fits() {
+		return out, &POMLError{Type: ErrValidate, Code: CodeBudget, Message: fmt.Sprintf("truncate: %d tokens still exceed the budget of %d", t.total, budget.MaxTokens)}
+	}
+	return out, nil
+}
+
+type truncator struct {
+	doc      *Document
+	budget   TokenBudget
+	strategy TruncateStrategy
+	sizes    map[string]int
+	total    int
+}
+
+func (t *truncator) fits() bool {
+	return t.total <= t.budget.MaxTokens
+}
+
+// remove deletes the elements with the given IDs, keeping payload indexes consistent.
+func (t *truncator) remove(ids ...string) {
+	for i := len(t.doc.Elements) - 1; i >= 0; i-- {
+		el := t.doc.Elements[i]
+		if !slices.Contains(ids, el.ID) {
+			continue
+		}
+		t.total -= t.sizes[el.ID]
+		t.doc.removePayload(el)
+		t.doc.Elements = slices.Delete(t.doc.Elements, i, i+1)
+		t.doc.reindex()
+	}
+}
+
+func (t *truncator) dropLastFirst(typ ElementType) {
+	for i := len(t.doc.Elements) - 1; i >= 0 && !t.fits(); i-- {
+		if el := t.doc.Elements[i]; el.Type == typ {
+			t.remove(el.ID)
+		}
+	}
+}
+
+func (t *truncator) summarizeExamples() {
+	summarize := t.strategy.Summarize
+	if summarize == nil {
+		summarize = firstSentence
+	}
+	for i := len(t.doc.Elements) - 1; i >= 0 && !t.fits(); i-- {
+		el := t.doc.Elements[i]
+		if el.Type != ElementExample {
+			continue
+		}
+		ex := &t.doc.Examples[el.Index]
+		switch pair := t.doc.examplePair(el, ConvertOptions{}); {
+		case pair != nil:
+			short := &ExamplePair{Input: summarize(pair.Input), Output: summarize(pair.Output)}
+			ex.Pair = short
+			ex.Body = "<input>" + short.Input + "</input><output>" + short.Output + "</output>"
+		case !strings.Contains(ex.Body, "<"):
+			ex.Body = summarize(ex.Body)
+		default:
+			continue
+		}
+		if ex.Content != nil {
+			ex.Content, _ = ParseInline(ex.Body)
+		}
+		size := t.budget.count(*t.doc, el)
+		t.total += size - t.sizes[el.ID]
+		t.sizes[el.ID] = size
+	}
+}
+
+func (t *truncator) dropOldestMessages() {
+	keep := t.strategy.KeepMessages
+	if keep == 0 {
+		keep = 1
+	}
+	var turns []Element
+	for _, el := range t.doc.Elements {
+		switch el.Type {
+		case ElementHumanMsg, ElementAssistantMsg, ElementMsg, ElementToolRequest, ElementToolResponse, ElementToolResult, ElementToolError:
+			turns = append(turns, el)
+		}
+	}
+	dropped := map[string]bool{}
+	for i := 0; i < len(turns)-max(keep, 0) && !t.fits(); i++ {
+		el := turns[i]
+		if dropped[el.ID] {
+			continue
+		}
+		ids := []string{el.ID}
+		if el.Type == ElementToolRequest {
+			if id := t.doc.ToolReqs[el.Index].ID; id != "" {
+				for _, other := range turns[i+1:] {
+					if out, ok := t.doc.toolOutcome(other); ok && out.ID == id {
+						ids = append(ids, other.ID)
+					}
+				}
+			}
+		}
+		for _, id := range ids {
+			dropped[id] = true
+		}
+		t.remove(ids...)
+		// Indexes shift as payloads are removed; refresh the remaining turns.
+		for j := range turns {
+			if k := slices.IndexFunc(t.doc.Elements, func(e Element) bool { return e.ID == turns[j].ID }); k >= 0 {
+				turns[j] = t.doc.Elements[k]
+			}
+		}
+	}
+}
+
+// firstSentence keeps text up to the end of its first sentence or line, marking the cut.
+func firstSentence(body string) string {
+	text := strings.TrimSpace(body)
+	cut := len(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		cut = i
+	}
+	for i := 0; i+1 < cut; i++ {
+		if strings.IndexByte(".!?", text[i]) >= 0 && (text[i+1] == ' ' || text[i+1] == '\t') {
+			cut = i + 1
+			break
+		}
+	}
+	if cut == len(text) {
+		return text
+	}
+	return strings.TrimSpace(text[:cut]) + " …"
+}
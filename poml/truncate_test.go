@@ -0,0 +1,130 @@
+package poml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const truncateSample = `<poml>
+  <meta><id>t</id><version>1</version><owner>o</owner></meta>
+  <role>Helper</role>
+  <task>Answer questions.</task>
+  <tool-definition name="search" description="Search the manual">{"type":"object"}</tool-definition>
+  <hint>Be brief.</hint>
+  <hint>Cite the manual when quoting it, and mention the section number too.</hint>
+  <example>Q: what is 2+2? A: 4. It is basic arithmetic that everyone learns early.</example>
+  <example><input>Capital of France? Answer in one word.</input><output>Paris. It has been the capital for centuries.</output></example>
+  <system-msg>Stay polite.</system-msg>
+  <human-msg>First question, asked long ago.</human-msg>
+  <tool-request id="c1" name="search" parameters="{}"/>
+  <tool-response id="c1" name="search">an old and rather lengthy search result</tool-response>
+  <assistant-msg>An old answer.</assistant-msg>
+  <human-msg>Latest question?</human-msg>
+</poml>`
+
+func elementTypes(doc Document) string {
+	var out []string
+	for _, el := range doc.resolveOrder() {
+		out = append(out, string(el.Type))
+	}
+	return strings.Join(out, ",")
+}
+
+func TestTruncateDropsHintsFirst(t *testing.T) {
+	doc, err := ParseString(truncateSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var budget TokenBudget
+	full := budget.Tokens(doc)
+	lastHint := doc.Elements[5]
+	budget.MaxTokens = full - budget.count(doc, lastHint)
+
+	out, err := Truncate(doc, budget, DefaultTruncateStrategy)
+	if err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	if len(out.Hints) != 1 || out.Hints[0].Body != "Be brief." {
+		t.Fatalf("expected only the last hint dropped, got %+v", out.Hints)
+	}
+	if len(out.Examples) != 2 || len(out.Messages) != 4 || budget.Tokens(out) > budget.MaxTokens {
+		t.Fatalf("expected nothing else touched, got %s", elementTypes(out))
+	}
+	if len(doc.Hints) != 2 {
+		t.Fatalf("Truncate modified its input")
+	}
+	if err := out.Validate(); err != nil {
+		t.Fatalf("truncated document invalid: %v", err)
+	}
+}
+
+func TestTruncateSummarizesExamplesAndDropsOldTurns(t *testing.T) {
+	doc, err := ParseString(truncateSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var budget TokenBudget
+	strategy := TruncateStrategy{Steps: []TruncateStep{TruncateSummarizeExamples}}
+	budget.MaxTokens = budget.Tokens(doc) - 1
+	out, err := Truncate(doc, budget, strategy)
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	if got := out.Examples[1].Pair; got == nil || got.Output != "Paris. …" || got.Input != "Capital of France? …" {
+		t.Fatalf("expected the last example summarized, got %+v", got)
+	}
+	if out.Examples[0].Body != doc.Examples[0].Body {
+		t.Fatalf("summarized more examples than needed: %q", out.Examples[0].Body)
+	}
+
+	// Drop turns until only the latest question and the system message remain.
+	strategy = TruncateStrategy{Steps: []TruncateStep{TruncateOldestMessages}}
+	target := doc.Clone()
+	target.Elements = target.resolveOrder()
+	for i := len(target.Elements) - 1; i >= 0; i-- {
+		switch el := target.Elements[i]; {
+		case el.Type == ElementToolRequest, el.Type == ElementToolResponse, el.Type == ElementAssistantMsg,
+			el.Type == ElementHumanMsg && target.Messages[el.Index].Body != "Latest question?":
+			target.removePayload(el)
+			target.Elements = append(target.Elements[:i], target.Elements[i+1:]...)
+		}
+	}
+	target.reindex()
+	budget.MaxTokens = budget.Tokens(target)
+	out, err = Truncate(doc, budget, strategy)
+	if err != nil {
+		t.Fatalf("oldest messages: %v", err)
+	}
+	if got, want := elementTypes(out), elementTypes(target); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	// After the oldest question, one more token is needed: the tool request goes, and its
+	// response goes with it.
+	budget.MaxTokens = budget.Tokens(doc) - budget.count(doc, doc.Elements[10]) - 1
+	out, err = Truncate(doc, budget, strategy)
+	if err != nil {
+		t.Fatalf("tool pair: %v", err)
+	}
+	if len(out.ToolReqs) != 0 || len(out.ToolResps) != 0 || len(out.Messages) != 3 {
+		t.Fatalf("expected the tool exchange dropped together, got %s", elementTypes(out))
+	}
+}
+
+func TestTruncateReportsUnreachableBudget(t *testing.T) {
+	doc, err := ParseString(truncateSample)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out, err := Truncate(doc, TokenBudget{MaxTokens: 10}, DefaultTruncateStrategy)
+	if !errors.Is(err, CodeBudget) {
+		t.Fatalf("expected %s, got %v", CodeBudget, err)
+	}
+	if got := elementTypes(out); got != "meta,role,task,tool_definition,system_msg,human_msg" {
+		t.Fatalf("expected required elements and the latest turn kept, got %s", got)
+	}
+	if _, err := Truncate(doc, TokenBudget{}, TruncateStrategy{Steps: []TruncateStep{"shuffle"}}); !errors.Is(err, CodeBudget) {
+		t.Fatalf("expected unknown step error, got %v", err)
+	}
+}
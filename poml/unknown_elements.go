@@ -0,0 +1,89 @@
+package poml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnknownElement is a top-level tag the parser does not recognize.
+type UnknownElement struct {
+	Name       string
+	Line       int    // 1-based line of the start tag
+	Suggestion string // closest known tag when the name looks like a typo; see SuggestTag
+}
+
+func (u UnknownElement) String() string {
+	return fmt.Sprintf("<%s> at line %d%s", u.Name, u.Line, didYouMean(u.Suggestion))
+}
+
+func didYouMean(tag string) string {
+	if tag == "" {
+		return ""
+	}
+	return " (did you mean <" + tag + ">?)"
+}
+
+// UnknownElementError lists the top-level elements rejected by ParseOptions.DisallowUnknown.
+type UnknownElementError struct {
+	Elements []UnknownElement
+}
+
+func (e *UnknownElementError) Error() string {
+	parts := make([]string, len(e.Elements))
+	for i, u := range e.Elements {
+		parts[i] = u.String()
+	}
+	return "unknown element " + strings.Join(parts, "; ")
+}
+
+// knownTags are the lowercase top-level tags decodeChildElement recognizes.
+var knownTags = func() []string {
+	var tags []string
+	for _, et := range elementTags {
+		for _, name := range et.Names {
+			if name == strings.ToLower(name) {
+				tags = append(tags, name)
+			}
+		}
+	}
+	return tags
+}()
+
+// SuggestTag returns the known POML tag closest to name, or "" when none is within a typo's
+// reach (an edit distance of at most a third of the name's length, and at least 1).
+func SuggestTag(name string) string {
+	lower := strings.ToLower(name)
+	best, bestDist := "", max(len(lower)/3, 1)+1
+	for _, tag := range knownTags {
+		if d := editDistance(lower, tag); d < bestDist {
+			best, bestDist = tag, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Damerau-Levenshtein (optimal string alignment) distance between a and b,
+// so swapped neighbours such as "tsak" and "task" count as one edit.
+func editDistance(a, b string) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
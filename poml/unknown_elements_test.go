@@ -0,0 +1,59 @@
+package poml
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const typoSample = `<poml>
+  <role>r</role>
+  <tsak>Summarize</tsak>
+  <task>Answer</task>
+  <widget kind="x"/>
+</poml>`
+
+func TestDisallowUnknownRejectsTypos(t *testing.T) {
+	if _, err := ParseString(typoSample); err != nil {
+		t.Fatalf("default parse should keep unknown elements: %v", err)
+	}
+	_, err := ParseReaderWithOptions(strings.NewReader(typoSample), ParseOptions{DisallowUnknown: true})
+	if !errors.Is(err, CodeUnknownElement) {
+		t.Fatalf("expected %s, got %v", CodeUnknownElement, err)
+	}
+	var uerr *UnknownElementError
+	if !errors.As(err, &uerr) {
+		t.Fatalf("expected *UnknownElementError, got %T", err)
+	}
+	want := []UnknownElement{{Name: "tsak", Line: 3, Suggestion: "task"}, {Name: "widget", Line: 5}}
+	if !reflect.DeepEqual(uerr.Elements, want) {
+		t.Fatalf("unknown elements %+v, want %+v", uerr.Elements, want)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "<tsak> at line 3 (did you mean <task>?)") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+
+	doc, err := ParseReaderWithOptions(strings.NewReader(typoSample), ParseOptions{DisallowUnknown: true, Recover: true})
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if len(doc.Issues) != 2 || doc.Issues[0].Line != 3 || doc.Issues[0].Message != "unknown element <tsak> (did you mean <task>?)" {
+		t.Fatalf("unexpected issues %+v", doc.Issues)
+	}
+}
+
+func TestSuggestTag(t *testing.T) {
+	for name, want := range map[string]string{
+		"tsak":          "task",
+		"Hint":          "hint",
+		"human-mesage":  "human-msg",
+		"output_schema": "output-schema",
+		"x":             "",
+		"completely":    "",
+	} {
+		if got := SuggestTag(name); got != want {
+			t.Fatalf("SuggestTag(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
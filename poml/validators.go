@@ -0,0 +1,127 @@
+package poml
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Validator is a custom validation rule, such as an organization's naming convention for
+// meta.id. It returns one detail per issue; a detail's Severity defaults to its Code's, which is
+// SeverityError for codes the SDK does not define.
+type Validator func(Document) []ValidationDetail
+
+var customValidators struct {
+	mu   sync.RWMutex
+	next int
+	list []registeredValidator // in registration order
+}
+
+type registeredValidator struct {
+	id int
+	v  Validator
+}
+
+// RegisterValidator adds v to the rules Document.Validate runs after its built-in checks, so it
+// applies wherever documents are validated: ParseStringStrict and the other strict parsers,
+// ParseOptions.Validate, ValidateWithOptions, LoadExtends, the HTTP server, and the CLI. The
+// returned function removes v again.
+func RegisterValidator(v Validator) (unregister func()) {
+	reg := &customValidators
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	id := reg.next
+	reg.next++
+	reg.list = append(reg.list, registeredValidator{id: id, v: v})
+	return func() {
+		reg.mu.Lock()
+		defer reg.mu.Unlock()
+		reg.list = slices.DeleteFunc(slices.Clone(reg.list), func(r registeredValidator) bool { return r.id == id })
+	}
+}
+
+// registeredValidators returns the registered validators in registration order.
+func registeredValidators() []Validator {
+	reg := &customValidators
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	out := make([]Validator, len(reg.list))
+	for i, r := range reg.list {
+		out[i] = r.v
+	}
+	return out
+}
+
+// runValidators appends the issues of each validator to issues and details.
+func (d Document) runValidators(validators []Validator, issues *[]string, details *[]ValidationDetail) {
+	for _, v := range validators {
+		if v == nil {
+			continue
+		}
+		for _, det := range v(d) {
+			issue := det.Message
+			if issue == "" {
+				issue = string(det.Code)
+			}
+			*issues = append(*issues, issue)
+			*details = append(*details, det)
+		}
+	}
+}
+
+// MetaIDPattern reports a meta.id that does not match pattern with CodeMetaIDPattern. An empty
+// id is left to the built-in CodeMetaID check.
+func MetaIDPattern(pattern *regexp.Regexp) Validator {
+	return func(d Document) []ValidationDetail {
+		id := strings.TrimSpace(d.Meta.ID)
+		if id == "" || pattern.MatchString(id) {
+			return nil
+		}
+		return []ValidationDetail{{Code: CodeMetaIDPattern, Element: ElementMeta, Field: "id", Message: fmt.Sprintf("meta.id %q does not match %s", id, pattern)}}
+	}
+}
+
+// AllowedOwners reports a meta.owner not in owners with CodeMetaOwnerAllowed. An empty owner is
+// left to the built-in CodeMetaOwner check.
+func AllowedOwners(owners ...string) Validator {
+	allowed := make(map[string]bool, len(owners))
+	for _, o := range owners {
+		allowed[strings.TrimSpace(o)] = true
+	}
+	return func(d Document) []ValidationDetail {
+		owner := strings.TrimSpace(d.Meta.Owner)
+		if owner == "" || allowed[owner] {
+			return nil
+		}
+		return []ValidationDetail{{Code: CodeMetaOwnerAllowed, Element: ElementMeta, Field: "owner", Message: fmt.Sprintf("meta.owner %q is not an allowed owner", owner)}}
+	}
+}
+
+// BannedPhrases reports each element whose body contains one of phrases, ignoring case, with
+// CodeBannedPhrase.
+func BannedPhrases(phrases ...string) Validator {
+	var banned []string
+	for _, p := range phrases {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			banned = append(banned, p)
+		}
+	}
+	return func(d Document) []ValidationDetail {
+		var out []ValidationDetail
+		for _, el := range d.resolveOrder() {
+			body := d.bodyRef(el)
+			if body == nil {
+				continue
+			}
+			lower := strings.ToLower(*body)
+			for _, p := range banned {
+				if strings.Contains(lower, p) {
+					out = append(out, ValidationDetail{Code: CodeBannedPhrase, Element: el.Type, ElementID: el.ID, Message: fmt.Sprintf("%s contains banned phrase %q", el.Type, p)})
+				}
+			}
+		}
+		return out
+	}
+}
@@ -0,0 +1,100 @@
+package poml
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+)
+
+// WatchOptions configures Watch. The zero value is usable.
+type WatchOptions struct {
+	// Parse controls how each revision is parsed. Its Validate flag is ignored: validation runs
+	// separately so the callback receives the parsed document alongside validation errors.
+	Parse ParseOptions
+	// SkipValidate reports parse errors only.
+	SkipValidate bool
+	// Interval is how often the file is checked for changes (default 200ms).
+	Interval time.Duration
+	// Debounce is how long the content must stay unchanged before it is reloaded (default
+	// 100ms), so editors that save in several writes trigger one reload.
+	Debounce time.Duration
+}
+
+func (o WatchOptions) withDefaults() WatchOptions {
+	if o.Interval <= 0 {
+		o.Interval = 200 * time.Millisecond
+	}
+	if o.Debounce <= 0 {
+		o.Debounce = 100 * time.Millisecond
+	}
+	return o
+}
+
+// Watch is WatchContext without cancellation; it returns only when the file cannot be read at
+// startup.
+func Watch(path string, opts WatchOptions, fn func(Document, error)) error {
+	return WatchContext(context.Background(), path, opts, fn)
+}
+
+// WatchContext parses and validates path, calls fn with the result, and repeats every time the
+// file's content changes until ctx is done, returning ctx.Err(). Changes are detected by polling,
+// so it works on any filesystem (including editors that replace files on save) without extra
+// dependencies. A parse failure is reported as (Document{}, err); a validation failure as the
+// parsed document with the ValidationError. A file that disappears is reported once with the
+// read error and picked up again when it returns. fn runs on the calling goroutine.
+func WatchContext(ctx context.Context, path string, opts WatchOptions, fn func(Document, error)) error {
+	opts = opts.withDefaults()
+	parse := opts.Parse
+	parse.Validate = false
+	load := func(src []byte, readErr error) {
+		if readErr != nil {
+			fn(Document{}, readErr)
+			return
+		}
+		doc, err := parseWithOptions(bytes.NewReader(src), parse)
+		if err != nil {
+			fn(Document{}, err)
+			return
+		}
+		if !opts.SkipValidate {
+			err = doc.Validate()
+		}
+		fn(doc, err)
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	load(current, nil)
+	loadedMissing := false
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	var pending []byte
+	var pendingErr error
+	var changedAt time.Time
+	dirty := false
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			src, err := os.ReadFile(path)
+			changed := (err != nil) != (pendingErr != nil) || !bytes.Equal(src, pending)
+			if !dirty {
+				changed = (err != nil) != loadedMissing || (err == nil && !bytes.Equal(src, current))
+			}
+			if changed {
+				pending, pendingErr, changedAt, dirty = src, err, now, true
+				continue
+			}
+			if dirty && now.Sub(changedAt) >= opts.Debounce {
+				dirty = false
+				current, loadedMissing = pending, pendingErr != nil
+				load(pending, pendingErr)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package poml
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "w.poml")
+	valid := `<poml><meta><id>w</id><version>1</version><owner>me</owner></meta><role>r</role><task>one</task></poml>`
+	if err := os.WriteFile(path, []byte(valid), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	type result struct {
+		doc Document
+		err error
+	}
+	results := make(chan result, 8)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchContext(ctx, path, WatchOptions{Interval: 5 * time.Millisecond, Debounce: 20 * time.Millisecond}, func(doc Document, err error) {
+			results <- result{doc, err}
+		})
+	}()
+	next := func() result {
+		t.Helper()
+		select {
+		case r := <-results:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for reload")
+		}
+		return result{}
+	}
+
+	if r := next(); r.err != nil || r.doc.Tasks[0].Body != "one" {
+		t.Fatalf("initial load: %+v", r)
+	}
+	if err := os.WriteFile(path, []byte(`<poml><task>two</task></poml>`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	r := next()
+	if !errors.Is(r.err, CodeMetaRequired) || len(r.doc.Tasks) != 1 || r.doc.Tasks[0].Body != "two" {
+		t.Fatalf("expected validation error with parsed doc, got %+v", r)
+	}
+	if err := os.WriteFile(path, []byte(`<poml><task>`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if r := next(); !errors.Is(r.err, CodeDecode) {
+		t.Fatalf("expected decode error, got %+v", r)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if err := Watch(filepath.Join(t.TempDir(), "missing.poml"), WatchOptions{}, func(Document, error) {}); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
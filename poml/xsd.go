@@ -0,0 +1,152 @@
+package poml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// xsdElementAliases are extra spellings a payload's custom decoder accepts for a child element,
+// keyed by "Type/element".
+var xsdElementAliases = map[string][]string{
+	"DiagramGraph/group": {"subgraph"},
+}
+
+// XSD returns an XML Schema describing the POML dialect this SDK parses: the <poml> root, every
+// top-level element in the parser's element table, and the attributes and children of each
+// payload type, derived from their xml struct tags. Bodies the SDK keeps as raw markup are mixed
+// content whose nested elements are checked only when the schema declares them (<img> inside a
+// message, for example); extension attributes are allowed everywhere, as the parser keeps them.
+// Top-level tags the SDK does not know are rejected, matching ParseOptions.DisallowUnknown.
+func XSD() []byte {
+	g := xsdGen{seen: map[reflect.Type]bool{}}
+	var root, globals bytes.Buffer
+	for _, et := range elementTags {
+		typ := reflect.TypeOf(et.Payload)
+		for _, name := range et.Names {
+			fmt.Fprintf(&root, "        <xs:element ref=\"%s\"/>\n", name)
+			fmt.Fprintf(&globals, "  <xs:element name=\"%s\" type=\"%s\"/>\n", name, typ.Name())
+		}
+		g.add(typ)
+	}
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString("<!-- POML element set; generated by poml.XSD from the parser's element table. -->\n")
+	b.WriteString("<xs:schema xmlns:xs=\"http://www.w3.org/2001/XMLSchema\" elementFormDefault=\"unqualified\">\n")
+	b.WriteString("  <xs:element name=\"poml\">\n    <xs:complexType>\n      <xs:choice minOccurs=\"0\" maxOccurs=\"unbounded\">\n")
+	b.Write(root.Bytes())
+	b.WriteString("      </xs:choice>\n      <xs:anyAttribute processContents=\"skip\"/>\n    </xs:complexType>\n  </xs:element>\n")
+	b.Write(globals.Bytes())
+	for _, typ := range g.types {
+		g.writeType(&b, typ)
+	}
+	b.WriteString("</xs:schema>\n")
+	return b.Bytes()
+}
+
+type xsdGen struct {
+	types []reflect.Type
+	seen  map[reflect.Type]bool
+}
+
+// xsdField is one xml-tagged struct field.
+type xsdField struct {
+	name  string
+	flags string
+	typ   reflect.Type
+}
+
+// add registers typ and, depth first, the struct types of its child elements.
+func (g *xsdGen) add(typ reflect.Type) {
+	if g.seen[typ] {
+		return
+	}
+	g.seen[typ] = true
+	g.types = append(g.types, typ)
+	for _, f := range xsdFields(typ) {
+		if f.flags == "" && f.typ.Kind() == reflect.Struct {
+			g.add(f.typ)
+		}
+	}
+}
+
+func (g *xsdGen) writeType(b *bytes.Buffer, typ reflect.Type) {
+	fields := xsdFields(typ)
+	mixed, anyAttr := false, false
+	var elems, attrs []xsdField
+	for _, f := range fields {
+		switch f.flags {
+		case "innerxml", "chardata":
+			mixed = true
+		case "any,attr":
+			anyAttr = true
+		case "attr":
+			attrs = append(attrs, f)
+		case "":
+			elems = append(elems, f)
+		}
+	}
+	if mixed {
+		fmt.Fprintf(b, "  <xs:complexType name=\"%s\" mixed=\"true\">\n", typ.Name())
+		b.WriteString("    <xs:sequence>\n      <xs:any minOccurs=\"0\" maxOccurs=\"unbounded\" processContents=\"lax\"/>\n    </xs:sequence>\n")
+	} else {
+		fmt.Fprintf(b, "  <xs:complexType name=\"%s\">\n", typ.Name())
+		if len(elems) > 0 {
+			b.WriteString("    <xs:choice minOccurs=\"0\" maxOccurs=\"unbounded\">\n")
+			for _, f := range elems {
+				names := append([]string{f.name}, xsdElementAliases[typ.Name()+"/"+f.name]...)
+				for _, name := range names {
+					fmt.Fprintf(b, "      <xs:element name=\"%s\" type=\"%s\"/>\n", name, xsdType(f.typ))
+				}
+			}
+			b.WriteString("    </xs:choice>\n")
+		}
+	}
+	for _, f := range attrs {
+		fmt.Fprintf(b, "    <xs:attribute name=\"%s\" type=\"%s\"/>\n", f.name, xsdType(f.typ))
+	}
+	if anyAttr {
+		b.WriteString("    <xs:anyAttribute processContents=\"skip\"/>\n")
+	}
+	b.WriteString("  </xs:complexType>\n")
+}
+
+// xsdFields returns typ's fields as encoding/xml sees them, with slice and pointer types
+// reduced to their element type.
+func xsdFields(typ reflect.Type) []xsdField {
+	var out []xsdField
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		tag := sf.Tag.Get("xml")
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+		name, flags, _ := strings.Cut(tag, ",")
+		if name == "" && flags == "" {
+			name = sf.Name
+		}
+		ft := sf.Type
+		for ft.Kind() == reflect.Slice || ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		out = append(out, xsdField{name: name, flags: flags, typ: ft})
+	}
+	return out
+}
+
+// xsdType maps a Go field type to a schema type: payload structs by name, scalars to built-ins.
+func xsdType(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Struct:
+		return typ.Name()
+	case reflect.Bool:
+		return "xs:boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "xs:integer"
+	case reflect.Float32, reflect.Float64:
+		return "xs:decimal"
+	}
+	return "xs:string"
+}
@@ -0,0 +1,70 @@
+package poml
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestElementTagsMatchParser(t *testing.T) {
+	for _, et := range elementTags {
+		for _, name := range et.Names {
+			doc, err := ParseString("<poml><" + name + "/></poml>")
+			if err != nil {
+				t.Fatalf("<%s>: %v", name, err)
+			}
+			if len(doc.Elements) != 1 || doc.Elements[0].Type == ElementUnknown {
+				t.Fatalf("<%s> is in elementTags but the parser keeps it as unknown", name)
+			}
+		}
+	}
+	if SuggestTag("daigram") != "diagram" {
+		t.Fatalf("knownTags lost a tag")
+	}
+}
+
+func TestXSDDescribesElementSet(t *testing.T) {
+	schema := XSD()
+	var parsed struct {
+		Elements []struct {
+			Name string `xml:"name,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"element"`
+		Types []struct {
+			Name  string `xml:"name,attr"`
+			Mixed bool   `xml:"mixed,attr"`
+		} `xml:"complexType"`
+	}
+	if err := xml.Unmarshal(schema, &parsed); err != nil {
+		t.Fatalf("schema is not well-formed: %v", err)
+	}
+	declared := map[string]string{}
+	for _, el := range parsed.Elements {
+		declared[el.Name] = el.Type
+	}
+	for _, tag := range append(knownTags, "poml", "Document", "Object") {
+		if _, ok := declared[tag]; !ok {
+			t.Fatalf("schema does not declare <%s>", tag)
+		}
+	}
+	types := map[string]bool{}
+	for _, ct := range parsed.Types {
+		if _, dup := types[ct.Name]; dup {
+			t.Fatalf("type %s declared twice", ct.Name)
+		}
+		types[ct.Name] = ct.Mixed
+	}
+	if !types["Message"] || types["Style"] || declared["human-msg"] != "Message" {
+		t.Fatalf("unexpected types: %v", types)
+	}
+	for _, want := range []string{
+		`<xs:attribute name="required" type="xs:boolean"/>`,
+		`<xs:element name="subgraph" type="DiagramGroup"/>`,
+		`<xs:element name="output" type="Output"/>`,
+		`<xs:attribute name="directed" type="xs:boolean"/>`,
+	} {
+		if !strings.Contains(string(schema), want) {
+			t.Fatalf("schema missing %s", want)
+		}
+	}
+}
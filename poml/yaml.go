@@ -0,0 +1,939 @@
+package poml
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseYAML parses the YAML subset prompt payloads use: block mappings and sequences, flow
+// collections, plain, quoted, and block (| and >) scalars, comments, and a single document with
+// an optional "---" marker. Plain scalars resolve with the core schema to nil, bool, int, or
+// float64; .inf and .nan stay strings so every value is JSON-encodable. Mappings decode to
+// map[string]any and sequences to []any.
+//
+// Everything outside the subset is an error rather than a best-effort reading: anchors, aliases
+// (and so merge keys), tags, complex "?" keys, multiple documents, tab indentation, and plain
+// scalars that start with an indicator character or hold ": ".
+func parseYAML(src string) (any, error) {
+	v, err := parseYAMLOrdered(src)
+	if err != nil {
+		return nil, err
+	}
+	return unorderYAML(v), nil
+}
+
+// yamlMap is a mapping that remembers its key order.
+type yamlMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func newYAMLMap() *yamlMap { return &yamlMap{values: map[string]any{}} }
+
+func (m *yamlMap) set(key string, v any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = v
+}
+
+// unorderYAML replaces the *yamlMap values in v with map[string]any.
+func unorderYAML(v any) any {
+	switch t := v.(type) {
+	case *yamlMap:
+		out := make(map[string]any, len(t.keys))
+		for _, k := range t.keys {
+			out[k] = unorderYAML(t.values[k])
+		}
+		return out
+	case []any:
+		for i := range t {
+			t[i] = unorderYAML(t[i])
+		}
+	}
+	return v
+}
+
+// parseYAMLOrdered is parseYAML with mappings left as *yamlMap.
+func parseYAMLOrdered(src string) (any, error) {
+	p := &yamlParser{}
+	src = strings.TrimSuffix(strings.ReplaceAll(strings.TrimPrefix(src, "\ufeff"), "\r\n", "\n"), "\n")
+	for i, raw := range strings.Split(src, "\n") {
+		text := strings.TrimLeft(raw, " ")
+		p.lines = append(p.lines, yamlLine{
+			num:    i + 1,
+			indent: len(raw) - len(text),
+			text:   strings.TrimRight(stripYAMLComment(text), " \t"),
+			raw:    raw,
+		})
+	}
+	p.skipBlank()
+	for p.pos < len(p.lines) && strings.HasPrefix(p.lines[p.pos].text, "%") {
+		p.pos++
+		p.skipBlank()
+	}
+	if p.pos < len(p.lines) && p.docMarker(p.lines[p.pos]) == "---" {
+		l := &p.lines[p.pos]
+		if rest := strings.TrimSpace(l.text[3:]); rest != "" {
+			l.text, l.indent = rest, len(l.raw)-len(strings.TrimLeft(l.raw[3:], " \t"))
+		} else {
+			p.pos++
+		}
+	}
+	v, err := p.parseNode(-1)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		switch p.docMarker(l) {
+		case "...":
+			p.pos++
+			if p.skipBlank(); p.pos == len(p.lines) {
+				return v, nil
+			}
+			return nil, p.errorf(p.lines[p.pos].num, "multiple documents are not supported")
+		case "---":
+			return nil, p.errorf(l.num, "multiple documents are not supported")
+		}
+		return nil, p.errorf(l.num, "unexpected %q", l.text)
+	}
+	return v, nil
+}
+
+// yamlLine is one source line with its indentation measured.
+type yamlLine struct {
+	num    int    // 1-based line number
+	indent int    // leading spaces
+	text   string // content after the indentation, without a trailing comment
+	raw    string // the whole line, for block scalars
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(line int, format string, args ...any) error {
+	return fmt.Errorf("yaml: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].text == "" {
+		p.pos++
+	}
+}
+
+// docMarker returns "---" or "..." when l starts or ends a document.
+func (p *yamlParser) docMarker(l yamlLine) string {
+	if l.indent != 0 {
+		return ""
+	}
+	for _, m := range []string{"---", "..."} {
+		if l.text == m || strings.HasPrefix(l.text, m+" ") {
+			return m
+		}
+	}
+	return ""
+}
+
+// parseNode parses the node starting at the next non-blank line, which must be indented deeper
+// than parent; a shallower line means the node is empty.
+func (p *yamlParser) parseNode(parent int) (any, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= parent || p.docMarker(p.lines[p.pos]) != "" {
+		return nil, nil
+	}
+	l := p.lines[p.pos]
+	switch {
+	case strings.HasPrefix(l.text, "\t"):
+		return nil, p.errorf(l.num, "tabs are not allowed in indentation")
+	case isYAMLSeqItem(l.text):
+		return p.parseSeq(l.indent)
+	case yamlKeyEnd(l.text) >= 0:
+		return p.parseMap(l.indent)
+	}
+	p.pos++
+	return p.value(l.text, l.num, parent)
+}
+
+func (p *yamlParser) parseMap(indent int) (any, error) {
+	m := newYAMLMap()
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return m, nil
+		}
+		l := p.lines[p.pos]
+		if l.indent < indent || p.docMarker(l) != "" {
+			return m, nil
+		}
+		if strings.HasPrefix(l.text, "\t") {
+			return nil, p.errorf(l.num, "tabs are not allowed in indentation")
+		}
+		if l.indent > indent {
+			return nil, p.errorf(l.num, "unexpected indentation")
+		}
+		end := yamlKeyEnd(l.text)
+		if end < 0 {
+			if isYAMLSeqItem(l.text) {
+				return nil, p.errorf(l.num, "sequence item where a mapping key was expected")
+			}
+			return nil, p.errorf(l.num, "expected a mapping key, found %q", l.text)
+		}
+		key, err := p.key(l.text[:end], l.num)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := m.values[key]; dup {
+			return nil, p.errorf(l.num, "duplicate key %q", key)
+		}
+		p.pos++
+		var v any
+		if rest := strings.TrimSpace(l.text[end+1:]); rest != "" {
+			v, err = p.value(rest, l.num, indent)
+		} else if p.skipBlank(); p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSeqItem(p.lines[p.pos].text) {
+			// A sequence may sit at its key's indentation.
+			v, err = p.parseSeq(indent)
+		} else {
+			v, err = p.parseNode(indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m.set(key, v)
+	}
+}
+
+func (p *yamlParser) parseSeq(indent int) (any, error) {
+	seq := []any{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return seq, nil
+		}
+		l := p.lines[p.pos]
+		if l.indent < indent || p.docMarker(l) != "" || (l.indent == indent && !isYAMLSeqItem(l.text)) {
+			return seq, nil
+		}
+		if strings.HasPrefix(l.text, "\t") {
+			return nil, p.errorf(l.num, "tabs are not allowed in indentation")
+		}
+		if l.indent > indent {
+			return nil, p.errorf(l.num, "unexpected indentation")
+		}
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if rest == "" {
+			p.pos++
+		} else {
+			// Re-read the item's content as a node indented past the dash, so "- key: v"
+			// continues with keys aligned under "key".
+			p.lines[p.pos].indent += len(l.text) - len(rest)
+			p.lines[p.pos].text = rest
+		}
+		v, err := p.parseNode(indent)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, v)
+	}
+}
+
+// value parses the scalar or flow collection text found after a key or dash, reading further
+// lines for block scalars, multi-line flow collections, and folded quoted and plain scalars.
+func (p *yamlParser) value(text string, num, parent int) (any, error) {
+	switch text[0] {
+	case '|', '>':
+		return p.blockScalar(text, num, parent)
+	case '[', '{':
+		for !yamlFlowClosed(text) && p.pos < len(p.lines) {
+			text += " " + strings.TrimSpace(p.lines[p.pos].text)
+			p.pos++
+		}
+		return parseYAMLFlow(text, num)
+	case '"', '\'':
+		// Line breaks inside quotes fold: one becomes a space, each blank line a newline, and a
+		// break escaped with a trailing backslash disappears.
+		breaks := 0
+		for yamlQuoteEnd(text, 0) < 0 && p.pos < len(p.lines) {
+			line := strings.TrimSpace(p.lines[p.pos].raw)
+			p.pos++
+			if line == "" {
+				breaks++
+				continue
+			}
+			text = strings.TrimRight(text, " \t")
+			switch {
+			case breaks > 0:
+				text += strings.Repeat("\n", breaks)
+			case text[0] == '"' && (len(text)-len(strings.TrimRight(text, `\`)))%2 == 1:
+				text = text[:len(text)-1]
+			default:
+				text += " "
+			}
+			text += line
+			breaks = 0
+		}
+		return yamlScalar(strings.TrimRight(stripYAMLComment(text), " \t"), num)
+	}
+	if err := checkYAMLPlain(text, num); err != nil {
+		return nil, err
+	}
+	if yamlKeyEnd(text) >= 0 {
+		return nil, p.errorf(num, "mapping values are not allowed here")
+	}
+	// A plain scalar continues on deeper lines, folding like a quoted one, and ends at a comment.
+	for breaks, j := 0, p.pos; j < len(p.lines); j++ {
+		l := p.lines[j]
+		if strings.TrimSpace(l.raw) == "" {
+			breaks++
+			continue
+		}
+		if l.text == "" || l.indent <= parent || p.docMarker(l) != "" {
+			break
+		}
+		if yamlKeyEnd(l.text) >= 0 {
+			return nil, p.errorf(l.num, "mapping values are not allowed here")
+		}
+		if breaks > 0 {
+			text += strings.Repeat("\n", breaks)
+		} else {
+			text += " "
+		}
+		text += l.text
+		breaks, p.pos = 0, j+1
+	}
+	return yamlScalar(text, num)
+}
+
+// key returns a mapping key as a string; quoted keys are unquoted, plain keys kept as written.
+func (p *yamlParser) key(text string, num int) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || text[0] == '"' || text[0] == '\'' {
+		v, err := yamlScalar(text, num)
+		s, _ := v.(string)
+		return s, err
+	}
+	switch text[0] {
+	case '?':
+		return "", p.errorf(num, "complex keys are not supported")
+	case '&', '*', '!':
+		return "", p.errorf(num, "anchors, aliases, and tags are not supported")
+	}
+	return text, nil
+}
+
+// blockScalar reads a literal (|) or folded (>) block scalar whose header is text.
+func (p *yamlParser) blockScalar(header string, num, parent int) (any, error) {
+	literal := header[0] == '|'
+	var chomp rune
+	indent := 0
+	for _, c := range header[1:] {
+		switch {
+		case c == '-' || c == '+':
+			chomp = c
+		case c >= '1' && c <= '9':
+			indent = max(parent, 0) + int(c-'0')
+		default:
+			return nil, p.errorf(num, "invalid block scalar header %q", header)
+		}
+	}
+	var lines []string
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if strings.TrimSpace(l.raw) == "" {
+			// Spaces past the block's indentation are content, even on an otherwise blank line.
+			if indent > 0 && len(l.raw) > indent {
+				lines = append(lines, l.raw[indent:])
+			} else {
+				lines = append(lines, "")
+			}
+			p.pos++
+			continue
+		}
+		if l.indent <= parent || p.docMarker(l) != "" {
+			break
+		}
+		if indent == 0 {
+			indent = l.indent
+		}
+		if l.indent < indent {
+			break
+		}
+		lines = append(lines, l.raw[indent:])
+		p.pos++
+	}
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var s string
+	if literal {
+		s = strings.Join(lines, "\n")
+	} else {
+		s = foldYAMLLines(lines)
+	}
+	switch {
+	case chomp == '-':
+	case chomp == '+':
+		if s != "" {
+			s += "\n"
+		}
+		s += strings.Repeat("\n", trailing)
+	case s != "":
+		s += "\n"
+	}
+	return s, nil
+}
+
+// foldYAMLLines joins the lines of a folded block scalar: line breaks between text lines become
+// spaces, blank lines become newlines, and more-indented lines keep their breaks.
+func foldYAMLLines(lines []string) string {
+	var b strings.Builder
+	breaks, prevMore, started := 0, false, false
+	for _, l := range lines {
+		if l == "" {
+			breaks++
+			continue
+		}
+		more := l[0] == ' ' || l[0] == '\t'
+		switch {
+		case !started:
+			b.WriteString(strings.Repeat("\n", breaks))
+		case breaks > 0:
+			b.WriteString(strings.Repeat("\n", breaks))
+			if more || prevMore {
+				b.WriteByte('\n')
+			}
+		case more || prevMore:
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(' ')
+		}
+		b.WriteString(l)
+		breaks, prevMore, started = 0, more, true
+	}
+	return b.String()
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ") || strings.HasPrefix(text, "-\t")
+}
+
+// yamlKeyEnd returns the index of the colon ending the mapping key at the start of text, or -1
+// when text is not a key: value pair.
+func yamlKeyEnd(text string) int {
+	if text == "" || text[0] == '[' || text[0] == '{' || isYAMLSeqItem(text) {
+		return -1
+	}
+	i := 0
+	if text[0] == '"' || text[0] == '\'' {
+		if i = yamlQuoteEnd(text, 0); i < 0 {
+			return -1
+		}
+		i++
+		for i < len(text) && text[i] == ' ' {
+			i++
+		}
+		if i < len(text) && text[i] != ':' {
+			return -1
+		}
+	}
+	for ; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t') {
+			return i
+		}
+	}
+	return -1
+}
+
+// yamlQuoteEnd returns the index of the quote closing the quoted scalar that starts at s[start],
+// or -1 when it is unterminated.
+func yamlQuoteEnd(s string, start int) int {
+	q := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment drops a "#" comment from a line, ignoring "#" inside quoted scalars and
+// inside plain scalars (where it is not preceded by whitespace). A quote opens a quoted scalar
+// only where a scalar may start; one left open continues on the next line, so the rest of the
+// line is kept.
+func stripYAMLComment(s string) string {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		case (c == '"' || c == '\'') && yamlScalarStart(s[:i]):
+			end := yamlQuoteEnd(s, i)
+			if end < 0 {
+				return s
+			}
+			i = end
+		}
+	}
+	return s
+}
+
+// yamlScalarStart reports whether a scalar may start right after before: at the start of the
+// line, or after a key's colon, a sequence dash, or a flow indicator.
+func yamlScalarStart(before string) bool {
+	if before != "" && strings.IndexByte(" \t[{,", before[len(before)-1]) < 0 {
+		return false
+	}
+	before = strings.TrimRight(before, " \t")
+	return before == "" || strings.IndexByte(":-?[{,", before[len(before)-1]) >= 0
+}
+
+// yamlFlowClosed reports whether every bracket opened in text is closed.
+func yamlFlowClosed(text string) bool {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case '"', '\'':
+			end := yamlQuoteEnd(text, i)
+			if end < 0 {
+				return false
+			}
+			i = end
+		}
+	}
+	return depth <= 0
+}
+
+// yamlScalar resolves a single-line scalar or flow collection.
+func yamlScalar(s string, num int) (any, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	switch s[0] {
+	case '"', '\'':
+		end := yamlQuoteEnd(s, 0)
+		if end < 0 {
+			return nil, fmt.Errorf("yaml: line %d: unterminated quoted scalar", num)
+		}
+		if end != len(s)-1 {
+			return nil, fmt.Errorf("yaml: line %d: unexpected %q after quoted scalar", num, s[end+1:])
+		}
+		if s[0] == '\'' {
+			return strings.ReplaceAll(s[1:end], "''", "'"), nil
+		}
+		out, err := unquoteYAML(s[1:end])
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: %w", num, err)
+		}
+		return out, nil
+	case '[', '{':
+		return parseYAMLFlow(s, num)
+	}
+	if err := checkYAMLPlain(s, num); err != nil {
+		return nil, err
+	}
+	return resolveYAMLPlain(s), nil
+}
+
+// checkYAMLPlain rejects plain scalars that start with an indicator: the node properties and
+// complex keys the subset leaves out, and characters YAML reserves.
+func checkYAMLPlain(s string, num int) error {
+	spaced := len(s) == 1 || s[1] == ' ' || s[1] == '\t'
+	switch {
+	case strings.IndexByte("&*!", s[0]) >= 0:
+		return fmt.Errorf("yaml: line %d: anchors, aliases, and tags are not supported", num)
+	case s[0] == '?' && spaced:
+		return fmt.Errorf("yaml: line %d: complex keys are not supported", num)
+	case strings.IndexByte("-:", s[0]) >= 0 && spaced, strings.IndexByte("@`%|>]},", s[0]) >= 0:
+		return fmt.Errorf("yaml: line %d: %q cannot start a plain scalar", num, s[:1])
+	}
+	return nil
+}
+
+var (
+	yamlIntRe   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloatRe = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// resolveYAMLPlain applies the core schema to a plain scalar.
+func resolveYAMLPlain(s string) any {
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	switch {
+	case yamlIntRe.MatchString(s):
+		if n, err := strconv.ParseInt(s, 10, 0); err == nil {
+			return int(n)
+		}
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0o"):
+		base := 16
+		if s[1] == 'o' {
+			base = 8
+		}
+		if n, err := strconv.ParseInt(s[2:], base, 0); err == nil {
+			return int(n)
+		}
+		return s
+	}
+	if yamlFloatRe.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+// unquoteYAML interprets the escapes of a double-quoted scalar.
+func unquoteYAML(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return "", fmt.Errorf("trailing backslash in quoted scalar")
+		}
+		width := 0
+		switch c := s[i]; c {
+		case '0':
+			b.WriteByte(0)
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 't', '\t':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'v':
+			b.WriteByte('\v')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case 'e':
+			b.WriteByte(0x1b)
+		case ' ', '"', '/', '\\':
+			b.WriteByte(c)
+		case 'N':
+			b.WriteString("\u0085")
+		case '_':
+			b.WriteString("\u00a0")
+		case 'L':
+			b.WriteString("\u2028")
+		case 'P':
+			b.WriteString("\u2029")
+		case 'x':
+			width = 2
+		case 'u':
+			width = 4
+		case 'U':
+			width = 8
+		default:
+			return "", fmt.Errorf("invalid escape \\%c", c)
+		}
+		if width == 0 {
+			continue
+		}
+		if i+width >= len(s) {
+			return "", fmt.Errorf("short \\%c escape", s[i])
+		}
+		n, err := strconv.ParseUint(s[i+1:i+1+width], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(n)) {
+			return "", fmt.Errorf("invalid \\%c escape", s[i])
+		}
+		b.WriteRune(rune(n))
+		i += width
+	}
+	return b.String(), nil
+}
+
+// yamlFlow parses a flow collection.
+type yamlFlow struct {
+	s   string
+	i   int
+	num int
+}
+
+func parseYAMLFlow(s string, num int) (any, error) {
+	f := &yamlFlow{s: s, num: num}
+	v, err := f.value()
+	if err != nil {
+		return nil, err
+	}
+	if f.ws(); f.i < len(f.s) {
+		return nil, f.errorf("unexpected %q after flow collection", f.s[f.i:])
+	}
+	return v, nil
+}
+
+func (f *yamlFlow) errorf(format string, args ...any) error {
+	return fmt.Errorf("yaml: line %d: %s", f.num, fmt.Sprintf(format, args...))
+}
+
+func (f *yamlFlow) ws() {
+	for f.i < len(f.s) && (f.s[f.i] == ' ' || f.s[f.i] == '\t') {
+		f.i++
+	}
+}
+
+func (f *yamlFlow) value() (any, error) {
+	f.ws()
+	if f.i == len(f.s) {
+		return nil, f.errorf("unterminated flow collection")
+	}
+	switch f.s[f.i] {
+	case '[':
+		f.i++
+		seq := []any{}
+		for {
+			if f.ws(); f.i < len(f.s) && f.s[f.i] == ']' {
+				f.i++
+				return seq, nil
+			}
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.i++
+		m := newYAMLMap()
+		for {
+			if f.ws(); f.i < len(f.s) && f.s[f.i] == '}' {
+				f.i++
+				return m, nil
+			}
+			text, err := f.scalarText()
+			if err != nil {
+				return nil, err
+			}
+			k, err := yamlScalar(text, f.num)
+			if err != nil {
+				return nil, err
+			}
+			key := text
+			if s, ok := k.(string); ok && text != "" && (text[0] == '"' || text[0] == '\'') {
+				key = s
+			}
+			var v any
+			if f.ws(); f.i < len(f.s) && f.s[f.i] == ':' {
+				f.i++
+				if f.ws(); f.i < len(f.s) && f.s[f.i] != ',' && f.s[f.i] != '}' {
+					if v, err = f.value(); err != nil {
+						return nil, err
+					}
+				}
+			}
+			if _, dup := m.values[key]; dup {
+				return nil, f.errorf("duplicate key %q", key)
+			}
+			m.set(key, v)
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	}
+	text, err := f.scalarText()
+	if err != nil {
+		return nil, err
+	}
+	return yamlScalar(text, f.num)
+}
+
+// separator consumes the comma after an entry, or stops before the collection's closer.
+func (f *yamlFlow) separator(closer byte) error {
+	f.ws()
+	switch {
+	case f.i == len(f.s):
+		return f.errorf("unterminated flow collection")
+	case f.s[f.i] == ',':
+		f.i++
+	case f.s[f.i] != closer:
+		return f.errorf("expected ',' or %q, found %q", closer, f.s[f.i])
+	}
+	return nil
+}
+
+// scalarText returns the text of the quoted or plain scalar at the cursor.
+func (f *yamlFlow) scalarText() (string, error) {
+	f.ws()
+	start := f.i
+	if f.i < len(f.s) && (f.s[f.i] == '"' || f.s[f.i] == '\'') {
+		end := yamlQuoteEnd(f.s, f.i)
+		if end < 0 {
+			return "", f.errorf("unterminated quoted scalar")
+		}
+		f.i = end + 1
+		return f.s[start:f.i], nil
+	}
+	for ; f.i < len(f.s); f.i++ {
+		c := f.s[f.i]
+		if c == ',' || c == ']' || c == '}' || c == '[' || c == '{' {
+			break
+		}
+		if c == ':' && (f.i+1 == len(f.s) || strings.IndexByte(" \t,]}", f.s[f.i+1]) >= 0) {
+			break
+		}
+	}
+	return strings.TrimSpace(f.s[start:f.i]), nil
+}
+
+// appendYAMLString writes s as a scalar that parseYAML reads back as the same string: plain when
+// that is unambiguous, a literal block scalar for multi-line text whose lines allow it, and a
+// double-quoted scalar otherwise, broken after each \n escape so multi-line text stays readable.
+// indent is the column continuation lines start at.
+func appendYAMLString(b *strings.Builder, s string, indent int) {
+	switch {
+	case yamlPlainSafe(s):
+		b.WriteString(s)
+	case yamlLiteralSafe(s):
+		body := strings.TrimRight(s, "\n")
+		trailing := len(s) - len(body)
+		switch trailing {
+		case 0:
+			b.WriteString("|-")
+		case 1:
+			b.WriteString("|")
+		default:
+			b.WriteString("|+")
+		}
+		for _, line := range strings.Split(body, "\n") {
+			b.WriteByte('\n')
+			if line != "" {
+				b.WriteString(strings.Repeat(" ", indent))
+				b.WriteString(line)
+			}
+		}
+		b.WriteString(strings.Repeat("\n", max(trailing-1, 0)))
+	case strings.Contains(s, "\n"):
+		b.WriteByte('"')
+		for i, line := range strings.Split(s, "\n") {
+			if i > 0 {
+				// An escaped line break drops the next line's leading spaces; "\ " keeps one.
+				b.WriteString("\\n\\\n" + strings.Repeat(" ", indent))
+				if strings.HasPrefix(line, " ") {
+					b.WriteByte('\\')
+				}
+			}
+			q := quoteYAML(line)
+			b.WriteString(q[1 : len(q)-1])
+		}
+		b.WriteByte('"')
+	default:
+		b.WriteString(quoteYAML(s))
+	}
+}
+
+// yamlSpecialRune reports whether r must be escaped in a YAML scalar: control characters other
+// than tab, and the breaks and byte order mark YAML treats specially.
+func yamlSpecialRune(r rune) bool {
+	return (r < ' ' && r != '\t') || r == 0x7f || r == '\u0085' || r == '\u2028' || r == '\u2029' || r == '\ufeff'
+}
+
+// yamlPlainSafe reports whether s can be written as a plain scalar and still read back as s.
+func yamlPlainSafe(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") || strings.ContainsAny(s, "\t\n") {
+		return false
+	}
+	for _, r := range s {
+		if yamlSpecialRune(r) {
+			return false
+		}
+	}
+	return yamlScalarString(resolveYAMLPlain(s)) == s
+}
+
+// yamlLiteralSafe reports whether s can be written as a literal block scalar.
+func yamlLiteralSafe(s string) bool {
+	body := strings.TrimRight(s, "\n")
+	// The first line with text sets the block's indentation, so it must not be indented itself.
+	if first := strings.TrimLeft(body, "\n"); !strings.Contains(body, "\n") || first[0] == ' ' || first[0] == '\t' || !utf8.ValidString(s) {
+		return false
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if line != "" && strings.TrimSpace(line) == "" {
+			return false
+		}
+		for _, r := range line {
+			if yamlSpecialRune(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// quoteYAML returns s as a double-quoted scalar.
+func quoteYAML(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case yamlSpecialRune(r):
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// yamlScalarString formats a resolved scalar the way it reads in a string context.
+func yamlScalarString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case bool:
+		return strconv.FormatBool(t)
+	case int:
+		return strconv.Itoa(t)
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,153 @@
+package poml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	src := `---
+# team roster
+name: "Q1 review"   # inline comment
+owner: ops
+tags: [finance, 'q1', {k: v}]
+count: 3
+ratio: 0.5
+active: yes
+missing: ~
+members:
+- name: Ada
+  roles:
+    - lead
+    - reviewer
+- name: Lin
+  roles: []
+notes: |
+  line one
+    indented
+  line three
+summary: >-
+  folded
+  text
+
+  para
+url: http://example.com/a#frag
+escaped: "tab\there \u00e9"
+`
+	got, err := parseYAML(src)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := map[string]any{
+		"name":    "Q1 review",
+		"owner":   "ops",
+		"tags":    []any{"finance", "q1", map[string]any{"k": "v"}},
+		"count":   3,
+		"ratio":   0.5,
+		"active":  "yes",
+		"missing": nil,
+		"members": []any{
+			map[string]any{"name": "Ada", "roles": []any{"lead", "reviewer"}},
+			map[string]any{"name": "Lin", "roles": []any{}},
+		},
+		"notes":   "line one\n  indented\nline three\n",
+		"summary": "folded text\npara",
+		"url":     "http://example.com/a#frag",
+		"escaped": "tab\there é",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected value:\n got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestParseYAMLConstructs(t *testing.T) {
+	cases := []struct {
+		name, src string
+		want      any
+	}{
+		// block scalars and chomping
+		{"literal clip", "a: |\n  x\n  y\n\n", "x\ny\n"},
+		{"literal strip", "a: |-\n  x\n\n", "x"},
+		{"literal keep", "a: |+\n  x\n\n", "x\n\n"},
+		{"folded", "a: >\n  x\n  y\n\n  z\n", "x y\nz\n"},
+		{"folded more indented", "a: >-\n  x\n    more\n  y\n", "x\n  more\ny"},
+		{"folded keep", "a: >+\n  x\n\nb: 1", map[string]any{"a": "x\n\n", "b": 1}},
+		{"indentation indicator", "a: |2\n    x\n", "  x\n"},
+		{"spaces on a blank line", "a: |\n  x\n     \n  y\n", "x\n   \ny\n"},
+		{"hash in block", "a: |\n  # not a comment\nb: 1", map[string]any{"a": "# not a comment\n", "b": 1}},
+		{"top-level block", "--- |\n  x\n", "x\n"},
+
+		// flow collections
+		{"flow nesting", "a: [1, 'two', {b: c}, [], {}]", []any{1, "two", map[string]any{"b": "c"}, []any{}, map[string]any{}}},
+		{"flow lines", "a: [1,\n  2, # c\n  3, ]", []any{1, 2, 3}},
+		{"flow mapping", `a: {x: 1, y, 'k: v': "q"}`, map[string]any{"x": 1, "y": nil, "k: v": "q"}},
+		{"flow colons", "a: [http://x.com/a?b=c, a:1]", []any{"http://x.com/a?b=c", "a:1"}},
+
+		// quoted scalars
+		{"double escapes", `a: "\x41\u00e9\U0001F600\\\"\/\t"`, "Aé😀\\\"/\t"},
+		{"single quotes", "a: 'it''s # not'", "it's # not"},
+		{"double folding", "a: \"one # two\n  three\n\n  four \\\n  five\" # c", "one # two three\nfour five"},
+		{"single folding", "a: 'x\n  y'", "x y"},
+
+		// plain scalars
+		{"plain folding", "a: x\n  y\n\n  z\nb: 1", map[string]any{"a": "x y\nz", "b": 1}},
+		{"plain quote", "a: b 'c # d", "b 'c"},
+		{"core schema", "a: [~, null, True, FALSE, 0x1F, 0o17, 012, +1, -1.5, 1e3, .5, 1_000, .inf, yes]", []any{nil, nil, true, false, 31, 15, 12, 1, -1.5, 1000.0, 0.5, "1_000", ".inf", "yes"}},
+
+		// documents
+		{"byte order mark", "\ufeffa: 1", 1},
+		{"markers", "%YAML 1.2\n---\na: 1\n...\n# done\n", 1},
+	}
+	for _, tc := range cases {
+		got, err := parseYAML(tc.src)
+		if err != nil {
+			t.Fatalf("%s: parse: %v", tc.name, err)
+		}
+		if m, ok := got.(map[string]any); ok && len(m) == 1 {
+			got = m["a"]
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: got %#v, want %#v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		"a: 1\na: 2":            "duplicate key",
+		"a: &x 1\nb: *x":        "anchors",
+		"a: *x":                 "anchors, aliases",
+		"&x a: 1":               "anchors, aliases",
+		"<<: *base\na: 1":       "anchors, aliases",
+		"a: !!str 1":            "tags are not supported",
+		"- !custom\n  a: 1":     "tags are not supported",
+		"? a\n: b":              "line 1: complex keys",
+		"a:\n  b: 1\n c: 2":     "unexpected indentation",
+		"a:\n\tb: 1":            "line 2: tabs",
+		"a: [1, 2":              "unterminated",
+		"{a: 1, a: 2}":          "duplicate key",
+		"[a, b]: c":             "after flow collection",
+		"a: [1, 2] x":           "after flow collection",
+		"a: 1\n---\nb: 2":       "multiple documents",
+		"--- a\n--- b":          "multiple documents",
+		"a: 1\n...\nb: 2":       "line 3: multiple documents",
+		"a: 1\n  b: 2":          "mapping values",
+		"a: b: c":               "line 1: mapping values",
+		"a: ]":                  `"]" cannot start a plain scalar`,
+		"a: - b":                `"-" cannot start a plain scalar`,
+		"a: %x":                 `"%" cannot start a plain scalar`,
+		"a: [@x]":               `"@" cannot start a plain scalar`,
+		"a: |0\n  x":            "invalid block scalar header",
+		`a: "\q"`:               `invalid escape \q`,
+		`a: "\x4"`:              `short \x escape`,
+		"a: 'x'y":               "after quoted scalar",
+		"a: \"unterminated\nb:": "unterminated",
+		"a: 1\n- b":             "sequence item where a mapping key was expected",
+		"- a\nb: 1":             `unexpected "b: 1"`,
+	} {
+		if _, err := parseYAML(src); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: expected %q error, got %v", src, want, err)
+		}
+	}
+}